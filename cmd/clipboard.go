@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// outputResult is the part of a tool response naming the saved image
+type outputResult struct {
+	Success  bool   `json:"success"`
+	FilePath string `json:"file_path"`
+	Paths    struct {
+		FilePath string `json:"file_path"`
+	} `json:"paths"`
+	Files []struct {
+		FilePath string `json:"file_path"`
+	} `json:"files"`
+}
+
+// responseImagePath returns the image a successful tool response saved, the
+// first one when it saved several
+func responseImagePath(resp *protocol.CallToolResponse) (string, error) {
+	if resp == nil || len(resp.Content) == 0 {
+		return "", fmt.Errorf("empty response")
+	}
+	var result outputResult
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !result.Success {
+		return "", fmt.Errorf("the operation did not succeed")
+	}
+	switch {
+	case result.FilePath != "":
+		return result.FilePath, nil
+	case result.Paths.FilePath != "":
+		return result.Paths.FilePath, nil
+	case len(result.Files) > 0 && result.Files[0].FilePath != "":
+		return result.Files[0].FilePath, nil
+	}
+	return "", fmt.Errorf("the response names no saved image")
+}
+
+// copyImage places the image at path on the system clipboard
+func copyImage(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", appleScriptCopy(path, ext))
+	case "windows":
+		// PowerShell quotes single-quoted strings by doubling quotes
+		quoted := strings.ReplaceAll(path, "'", "''")
+		cmd = exec.Command("powershell", "-NoProfile", "-STA", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms, System.Drawing; "+
+				"[System.Windows.Forms.Clipboard]::SetImage([System.Drawing.Image]::FromFile('"+quoted+"'))")
+	default:
+		mimeType := mime.TypeByExtension(ext)
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		mimeType, _, _ = strings.Cut(mimeType, ";")
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmd = exec.Command("wl-copy", "--type", mimeType)
+		} else {
+			cmd = exec.Command("xclip", "-selection", "clipboard", "-t", mimeType)
+		}
+		cmd.Stdin = file
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// appleScriptCopy returns the AppleScript copying path as image data for the
+// formats the macOS clipboard holds natively, and as a file otherwise
+func appleScriptCopy(path, ext string) string {
+	quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path) + `"`
+	classes := map[string]string{
+		".png":  "«class PNGf»",
+		".jpg":  "JPEG picture",
+		".jpeg": "JPEG picture",
+		".gif":  "GIF picture",
+		".tif":  "TIFF picture",
+		".tiff": "TIFF picture",
+	}
+	if class, ok := classes[ext]; ok {
+		return fmt.Sprintf("set the clipboard to (read (POSIX file %s) as %s)", quoted, class)
+	}
+	return fmt.Sprintf("set the clipboard to (POSIX file %s)", quoted)
+}
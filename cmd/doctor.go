@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/config"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/provenance"
+	"github.com/gomcpgo/replicate_image_ai/pkg/transcode"
+)
+
+// Doctor settings
+const (
+	doctorTimeout      = 15 * time.Second // Per request
+	doctorPings        = 3                // Requests timed to measure API latency
+	doctorSlowLatency  = 2 * time.Second  // Median latency worth a warning
+	doctorModelWorkers = 8
+)
+
+// doctor runs the checks of the doctor subcommand and prints their results
+type doctor struct {
+	failures int
+	warnings int
+}
+
+// ok, warn, and fail print the outcome of one check, with a fix for problems
+func (d *doctor) ok(check, format string, args ...interface{}) {
+	fmt.Printf("[ok]   %s: %s\n", check, fmt.Sprintf(format, args...))
+}
+
+func (d *doctor) warn(check, detail, fix string) {
+	d.warnings++
+	fmt.Printf("[warn] %s: %s\n", check, detail)
+	fmt.Printf("       Fix: %s\n", fix)
+}
+
+func (d *doctor) fail(check, detail, fix string) {
+	d.failures++
+	fmt.Printf("[FAIL] %s: %s\n", check, detail)
+	fmt.Printf("       Fix: %s\n", fix)
+}
+
+// runDoctor checks the configuration, API token, model registry, storage,
+// and optional tools, and returns the process exit code: 1 when a check
+// failed, 0 otherwise
+func runDoctor() int {
+	fmt.Printf("Replicate Image AI v%s self-test\n\n", version)
+	d := &doctor{}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		d.fail("configuration", err.Error(), configFix(err))
+		return d.summary()
+	}
+	if err := cfg.Validate(); err != nil {
+		d.fail("configuration", err.Error(), configFix(err))
+		return d.summary()
+	}
+	d.ok("configuration", "loaded from the environment")
+
+	d.checkStorage(cfg.ReplicateImagesRoot)
+
+	ctx := context.Background()
+	replicate := client.NewReplicateClient(cfg.ReplicateAPIToken)
+	if cfg.CassetteMode == "replay" {
+		d.warn("token", "not checked in REPLICATE_CASSETTE_MODE=replay", "unset REPLICATE_CASSETTE_MODE to check the live API")
+	} else if d.checkToken(ctx, replicate) {
+		d.checkModels(ctx, replicate, cfg)
+	}
+
+	d.checkTools(cfg)
+	return d.summary()
+}
+
+// summary prints the totals and returns the exit code
+func (d *doctor) summary() int {
+	fmt.Println()
+	switch {
+	case d.failures > 0:
+		fmt.Printf("%d check(s) failed, %d warning(s). Fix the failures above before starting the server.\n", d.failures, d.warnings)
+		return 1
+	case d.warnings > 0:
+		fmt.Printf("All checks passed with %d warning(s).\n", d.warnings)
+	default:
+		fmt.Println("All checks passed.")
+	}
+	return 0
+}
+
+// configFix suggests how to fix a configuration error
+func configFix(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "REPLICATE_API_TOKEN"), strings.Contains(msg, "Replicate API token"):
+		return "create a token at https://replicate.com/account/api-tokens and export REPLICATE_API_TOKEN, or set it in your MCP client's env block"
+	case strings.Contains(msg, "_KEY is required"):
+		return "export the provider's API key, or route its models back to replicate with IMAGE_PROVIDER and PROVIDER_MODELS"
+	case strings.Contains(msg, "images root"):
+		return "point REPLICATE_IMAGES_ROOT_FOLDER at a directory this user can create and write"
+	default:
+		return "correct the environment variable named above; the README's Configuration section lists the accepted values"
+	}
+}
+
+// checkStorage checks that the images root exists and is writable
+func (d *doctor) checkStorage(root string) {
+	info, err := os.Stat(root)
+	if err != nil {
+		d.fail("storage", fmt.Sprintf("%s: %v", root, err), "create the directory or point REPLICATE_IMAGES_ROOT_FOLDER elsewhere")
+		return
+	}
+	if !info.IsDir() {
+		d.fail("storage", root+" is not a directory", "point REPLICATE_IMAGES_ROOT_FOLDER at a directory")
+		return
+	}
+
+	probe, err := os.CreateTemp(root, ".doctor-*")
+	if err == nil {
+		_, err = probe.WriteString("ok")
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+	if err != nil {
+		d.fail("storage", fmt.Sprintf("%s is not writable: %v", root, err),
+			fmt.Sprintf("run `chmod u+w %q` or change its owner to this user; in a container, mount the volume writable", root))
+		return
+	}
+	d.ok("storage", "%s is writable", root)
+}
+
+// checkToken checks the API token and measures API latency, reporting
+// whether the API can be used for further checks
+func (d *doctor) checkToken(ctx context.Context, replicate *client.ReplicateClient) bool {
+	var latencies []time.Duration
+	var account *client.Account
+	for i := 0; i < doctorPings; i++ {
+		reqCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+		start := time.Now()
+		a, err := replicate.GetAccount(reqCtx)
+		cancel()
+		if err != nil {
+			d.fail("token", err.Error(), apiFix(err))
+			return false
+		}
+		account = a
+		latencies = append(latencies, time.Since(start))
+	}
+	d.ok("token", "valid for %s account %s", account.Type, account.Username)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	median := latencies[len(latencies)/2]
+	detail := fmt.Sprintf("median %s, max %s over %d requests", median.Round(time.Millisecond), latencies[len(latencies)-1].Round(time.Millisecond), len(latencies))
+	if median > doctorSlowLatency {
+		d.warn("api latency", detail, "check the network path to api.replicate.com; a slow proxy or VPN adds this to every poll, so raise OPERATION_TIMEOUT_SECONDS or max_wait_seconds")
+		return true
+	}
+	d.ok("api latency", "%s", detail)
+	return true
+}
+
+// apiFix suggests how to fix an API error
+func apiFix(err error) string {
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		return "check network access to https://api.replicate.com; behind a proxy, export HTTPS_PROXY"
+	}
+	switch apiErr.Code {
+	case client.ErrCodeAuth:
+		return "REPLICATE_API_TOKEN was rejected; create a new token at https://replicate.com/account/api-tokens and check it has no quotes or whitespace around it"
+	case client.ErrCodeBilling:
+		return "add a payment method or credit at https://replicate.com/account/billing"
+	case client.ErrCodeRateLimit:
+		return "the account is rate limited; wait a minute and run doctor again"
+	default:
+		return "retry in a minute; if it persists, check https://replicate.statuspage.io"
+	}
+}
+
+// checkModels looks up every registered model on the provider serving it
+func (d *doctor) checkModels(ctx context.Context, replicate *client.ReplicateClient, cfg *config.Config) {
+	var hosted []models.ModelInfo
+	unserved := map[string][]string{} // Provider to the models it alone serves
+	for _, info := range models.All() {
+		switch info.Provider {
+		case "":
+			hosted = append(hosted, info)
+		case models.ProviderStability:
+			if cfg.StabilityAPIKey == "" {
+				unserved["STABILITY_API_KEY"] = append(unserved["STABILITY_API_KEY"], info.ID)
+			}
+		case models.ProviderOpenAI:
+			if cfg.OpenAIAPIKey == "" {
+				unserved["OPENAI_API_KEY"] = append(unserved["OPENAI_API_KEY"], info.ID)
+			}
+		case models.ProviderLocal:
+			if cfg.LocalBackend == "" {
+				unserved["LOCAL_BACKEND"] = append(unserved["LOCAL_BACKEND"], info.ID)
+			}
+		}
+	}
+
+	problems := make([]string, len(hosted))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < doctorModelWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				reqCtx, cancel := context.WithTimeout(ctx, doctorTimeout)
+				_, _, err := replicate.GetModel(reqCtx, hosted[i].ID)
+				cancel()
+				if err != nil {
+					problems[i] = err.Error()
+				}
+			}
+		}()
+	}
+	for i := range hosted {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var unreachable []string
+	for i, problem := range problems {
+		if problem != "" {
+			unreachable = append(unreachable, fmt.Sprintf("%s (%s)", hosted[i].ID, problem))
+		}
+	}
+	reachable := len(hosted) - len(unreachable)
+	if len(unreachable) > 0 {
+		d.warn("models", fmt.Sprintf("%d of %d Replicate models reachable; unreachable: %s", reachable, len(hosted), strings.Join(unreachable, ", ")),
+			"use another model for these; a removed version is fixed by upgrading this server")
+	} else {
+		d.ok("models", "all %d Replicate models reachable", len(hosted))
+	}
+
+	keys := make([]string, 0, len(unserved))
+	for key := range unserved {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		d.warn("models", fmt.Sprintf("%s unavailable without %s", strings.Join(unserved[key], ", "), key),
+			fmt.Sprintf("export %s to use them, or ignore this if you do not need them", key))
+	}
+}
+
+// checkTools checks the external programs optional features rely on
+func (d *doctor) checkTools(cfg *config.Config) {
+	magick := cfg.ImageMagickPath
+	if magick == "" {
+		magick = transcode.DefaultTool
+	}
+	if path, err := exec.LookPath(magick); err != nil {
+		d.warn("imagemagick", magick+" not found; WebP and AVIF conversion of outputs is unavailable",
+			"install ImageMagick 7 or set IMAGEMAGICK_PATH to its magick binary")
+	} else {
+		d.ok("imagemagick", "%s", path)
+	}
+
+	if cfg.C2PACertPath == "" {
+		return
+	}
+	c2pa := cfg.C2PATool
+	if c2pa == "" {
+		c2pa = provenance.DefaultTool
+	}
+	if path, err := exec.LookPath(c2pa); err != nil {
+		d.fail("c2patool", c2pa+" not found but C2PA_SIGN_CERT is set",
+			"install c2patool (https://github.com/contentauth/c2pa-rs) or set C2PA_TOOL to its path")
+	} else {
+		d.ok("c2patool", "%s", path)
+	}
+	for _, file := range []string{cfg.C2PACertPath, cfg.C2PAKeyPath} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			d.fail("c2pa", err.Error(), "check C2PA_SIGN_CERT and C2PA_PRIVATE_KEY point at readable PEM files")
+		}
+	}
+}
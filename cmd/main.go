@@ -5,14 +5,23 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gomcpgo/mcp/pkg/handler"
 	"github.com/gomcpgo/mcp/pkg/protocol"
 	"github.com/gomcpgo/mcp/pkg/server"
 	"github.com/gomcpgo/replicate_image_ai/pkg/config"
 	replhandler "github.com/gomcpgo/replicate_image_ai/pkg/handler"
+	"github.com/gomcpgo/replicate_image_ai/pkg/logging"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
 )
 
 const version = "2.0.0"
@@ -37,12 +46,14 @@ func main() {
 		refTags       string
 		resolution    string
 		prompt        string
+		copyOutput    bool
 	)
 
 	flag.StringVar(&generateModel, "g", "", "Generate an image using specified model")
 	flag.BoolVar(&listModels, "list", false, "List all available models")
 	flag.BoolVar(&versionFlag, "version", false, "Show version information")
 	flag.StringVar(&prompt, "p", "", "Custom prompt for generation")
+	flag.BoolVar(&copyOutput, "copy", false, "Copy the resulting image to the system clipboard")
 	
 	// Enhancement flags
 	flag.StringVar(&testEnhance, "enhance", "", "Test enhancement tool: remove-bg, upscale, face, restore")
@@ -71,24 +82,42 @@ func main() {
 		fmt.Printf("Replicate Image AI MCP Server v%s\n", version)
 		return
 	}
+	
+	// Self-test of the configuration, token, models, and storage
+	if flag.Arg(0) == "doctor" {
+		os.Exit(runDoctor())
+	}
+
+	// The model list comes from the registry and needs no configuration
+	if listModels {
+		listAvailableModels()
+		return
+	}
 
 	// Terminal mode operations
-	if listModels || generateModel != "" || testEnhance != "" || editModel != "" || imagen4Flag || gen4Flag {
-		// Get API key from environment
-		apiKey := os.Getenv("REPLICATE_API_TOKEN")
-		if apiKey == "" {
-			log.Fatal("REPLICATE_API_TOKEN environment variable is required")
+	if generateModel != "" || testEnhance != "" || editModel != "" || imagen4Flag || gen4Flag {
+		// Load configuration from environment
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
 		}
 		
-		// Get root folder from environment or use default
-		rootFolder := os.Getenv("REPLICATE_IMAGES_ROOT_FOLDER")
-		if rootFolder == "" {
+		// Use the desktop app folder unless a root folder is configured
+		if os.Getenv("REPLICATE_IMAGES_ROOT_FOLDER") == "" {
 			homeDir, _ := os.UserHomeDir()
-			rootFolder = fmt.Sprintf("%s/Library/Application Support/Savant/replicate_image_ai", homeDir)
+			cfg.ReplicateImagesRoot = fmt.Sprintf("%s/Library/Application Support/Savant/replicate_image_ai", homeDir)
+		}
+		cfg.DebugMode = true
+		cfg.Timeouts.InitialWait = 0 // The process exits with the result, so wait for it
+		if os.Getenv("LOG_LEVEL") == "" {
+			cfg.LogLevel = "debug"
+		}
+		if err := logging.Setup(cfg.LogLevel, cfg.PromptPrivacy != "", cfg.Secrets()...); err != nil {
+			log.Fatalf("Failed to configure logging: %v", err)
 		}
 		
 		// Create handler for terminal operations
-		h, err := replhandler.NewReplicateImageHandler(apiKey, rootFolder, true)
+		h, err := replhandler.NewReplicateImageHandler(cfg)
 		if err != nil {
 			log.Fatalf("Failed to create handler: %v", err)
 		}
@@ -96,54 +125,63 @@ func main() {
 		ctx := context.Background()
 		
 		// Handle terminal mode operations
-		if listModels {
-			listAvailableModels()
-			return
-		}
 		
-		if generateModel != "" {
-			runGeneration(ctx, h, generateModel, prompt)
-			return
+		var resp *protocol.CallToolResponse
+		switch {
+		case generateModel != "":
+			resp = runGeneration(ctx, h, generateModel, prompt)
+		case testEnhance != "":
+			resp = runEnhancement(ctx, h, testEnhance, inputImage, enhanceModel, outputFile)
+		case editModel != "":
+			resp = runEdit(ctx, h, editModel, inputImage, editPrompt, outputFile)
+		case imagen4Flag:
+			resp = runImagen4(ctx, h, prompt, aspectRatio, safetyFilter)
+		case gen4Flag:
+			resp = runGen4(ctx, h, prompt, refImages, refTags, aspectRatio, resolution)
 		}
 		
-		if testEnhance != "" {
-			runEnhancement(ctx, h, testEnhance, inputImage, enhanceModel, outputFile)
-			return
+		if copyOutput {
+			path, err := responseImagePath(resp)
+			if err != nil {
+				log.Fatalf("Nothing to copy: %v", err)
+			}
+			if err := copyImage(path); err != nil {
+				log.Fatalf("Failed to copy image to clipboard: %v", err)
+			}
+			fmt.Printf("Copied %s to the clipboard\n", path)
 		}
-		
-		if editModel != "" {
-			runEdit(ctx, h, editModel, inputImage, editPrompt, outputFile)
-			return
-		}
-		
-		if imagen4Flag {
-			runImagen4(ctx, h, prompt, aspectRatio, safetyFilter)
-			return
-		}
-		
-		if gen4Flag {
-			runGen4(ctx, h, prompt, refImages, refTags, aspectRatio, resolution)
-			return
-		}
-		
 		return
 	}
 
 	// MCP Server mode
-	fmt.Println("Starting Replicate Image AI MCP Server...")
-	
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 	
+	// Log to stderr only; stdout carries the MCP protocol
+	if err := logging.Setup(cfg.LogLevel, cfg.PromptPrivacy != "", cfg.Secrets()...); err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	slog.Info("starting Replicate Image AI MCP server", "version", version, "log_level", cfg.LogLevel)
+	
+	// Configure tracing from the standard OTEL_* environment variables
+	shutdownTracing, err := tracing.Setup(version)
+	if err != nil {
+		log.Fatalf("Failed to configure tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	
 	// Create handler
-	h, err := replhandler.NewReplicateImageHandler(cfg.ReplicateAPIToken, cfg.ReplicateImagesRoot, cfg.DebugMode)
+	h, err := replhandler.NewReplicateImageHandler(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create handler: %v", err)
 	}
 	
+	// Pick up the background operations a restart cut short
+	h.RestorePending()
+	
 	// Create handler registry
 	registry := handler.NewHandlerRegistry()
 	registry.RegisterToolHandler(h)
@@ -155,42 +193,82 @@ func main() {
 		Registry: registry,
 	})
 	
-	log.Printf("Server started (version %s)", version)
+	// Drain in-flight calls on SIGTERM or SIGINT instead of dropping them; a
+	// second signal exits at once
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		slog.Info("shutting down", "signal", sig.String(), "timeout", cfg.ShutdownTimeout)
+		drain(h, cfg.ShutdownTimeout)
+		shutdownTracing(context.Background())
+		os.Exit(0)
+	}()
+	
+	slog.Info("server started", "version", version)
 	if err := srv.Run(); err != nil {
+		drain(h, cfg.ShutdownTimeout)
+		shutdownTracing(context.Background())
 		log.Fatalf("Server error: %v", err)
 	}
+	
+	// The client closed the connection
+	drain(h, cfg.ShutdownTimeout)
+}
+
+// drainOnce keeps a signal and the end of the session from draining twice
+var drainOnce sync.Once
+
+// drain waits up to timeout for the handler's tool calls to finish
+func drain(h *replhandler.ReplicateImageHandler, timeout time.Duration) {
+	drainOnce.Do(func() { shutdown(h, timeout) })
 }
 
+// shutdown runs the handler's shutdown and logs its outcome
+func shutdown(h *replhandler.ReplicateImageHandler, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	interrupted, err := h.Shutdown(ctx)
+	if err != nil {
+		slog.Error("shutdown failed", "error", err)
+	}
+	if interrupted > 0 {
+		slog.Warn("operations were interrupted; see list_interrupted after restart", "count", interrupted)
+	}
+	slog.Info("shutdown complete")
+}
+
+// listAvailableModels prints the models of each operation from the registry,
+// with the aliases the flags and tools accept for them
 func listAvailableModels() {
-	fmt.Println("\n=== Available Models ===\n")
-	fmt.Println("Generation Models:")
-	fmt.Println("  flux-schnell    - Fast generation (default)")
-	fmt.Println("  flux-dev        - Development version")
-	fmt.Println("  flux-pro        - High quality (paid)")
-	fmt.Println("  imagen-4        - Google's photorealistic model")
-	fmt.Println("  gen4-image      - RunwayML Gen-4 with visual context")
-	fmt.Println("  sdxl            - Stable Diffusion XL")
-	fmt.Println("  sdxl-lightning  - Fast SDXL variant")
-	fmt.Println("  seedream        - High quality generation")
-	fmt.Println("  ideogram        - Text in images")
-	fmt.Println("  recraft         - Raster images")
-	fmt.Println("  recraft-svg     - SVG generation")
-	fmt.Println()
-	fmt.Println("Enhancement Models:")
-	fmt.Println("  remove-bg       - Background removal")
-	fmt.Println("  rembg           - Alternative background removal")
-	fmt.Println("  realesrgan      - Image upscaling")
-	fmt.Println("  gfpgan          - Face enhancement")
-	fmt.Println("  codeformer      - Face restoration")
-	fmt.Println("  bopbtl          - Old photo restoration")
-	fmt.Println()
-	fmt.Println("Edit Models (FLUX Kontext):")
-	fmt.Println("  pro             - Balanced speed/quality")
-	fmt.Println("  max             - Highest quality")
-	fmt.Println("  dev             - Advanced controls")
+	fmt.Println("\n=== Available Models ===")
+	for _, operation := range models.Operations {
+		fmt.Printf("\n%s:\n", operation)
+		byModel := models.Aliases(operation)
+		ids := make([]string, 0, len(byModel))
+		for id := range byModel {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			info := models.GetModelInfo(id)
+			line := fmt.Sprintf("  %-34s %s", strings.Join(byModel[id], ", "), info.Name)
+			if info.Description != "" {
+				line += " - " + info.Description
+			}
+			if id == models.DefaultModel(operation) {
+				line += " (default)"
+			}
+			if info.Deprecated {
+				line += " (deprecated)"
+			}
+			fmt.Println(line)
+		}
+	}
 }
 
-func runGeneration(ctx context.Context, h *replhandler.ReplicateImageHandler, model, prompt string) {
+func runGeneration(ctx context.Context, h *replhandler.ReplicateImageHandler, model, prompt string) *protocol.CallToolResponse {
 	if prompt == "" {
 		prompt = "A beautiful sunset over mountains with a lake in the foreground"
 	}
@@ -212,9 +290,10 @@ func runGeneration(ctx context.Context, h *replhandler.ReplicateImageHandler, mo
 	}
 	
 	printResponse(resp)
+	return resp
 }
 
-func runEnhancement(ctx context.Context, h *replhandler.ReplicateImageHandler, tool, inputPath, model, outputFile string) {
+func runEnhancement(ctx context.Context, h *replhandler.ReplicateImageHandler, tool, inputPath, model, outputFile string) *protocol.CallToolResponse {
 	if inputPath == "" {
 		log.Fatal("Input image path is required for enhancement")
 	}
@@ -267,9 +346,10 @@ func runEnhancement(ctx context.Context, h *replhandler.ReplicateImageHandler, t
 	}
 	
 	printResponse(resp)
+	return resp
 }
 
-func runEdit(ctx context.Context, h *replhandler.ReplicateImageHandler, model, inputPath, editPrompt, outputFile string) {
+func runEdit(ctx context.Context, h *replhandler.ReplicateImageHandler, model, inputPath, editPrompt, outputFile string) *protocol.CallToolResponse {
 	if inputPath == "" {
 		log.Fatal("Input image path is required for editing")
 	}
@@ -302,9 +382,10 @@ func runEdit(ctx context.Context, h *replhandler.ReplicateImageHandler, model, i
 	}
 	
 	printResponse(resp)
+	return resp
 }
 
-func runImagen4(ctx context.Context, h *replhandler.ReplicateImageHandler, prompt, aspectRatio, safetyFilter string) {
+func runImagen4(ctx context.Context, h *replhandler.ReplicateImageHandler, prompt, aspectRatio, safetyFilter string) *protocol.CallToolResponse {
 	if prompt == "" {
 		prompt = "A photorealistic portrait of a young woman with vibrant red hair"
 	}
@@ -329,9 +410,10 @@ func runImagen4(ctx context.Context, h *replhandler.ReplicateImageHandler, promp
 	}
 	
 	printResponse(resp)
+	return resp
 }
 
-func runGen4(ctx context.Context, h *replhandler.ReplicateImageHandler, prompt, refImages, refTags, aspectRatio, resolution string) {
+func runGen4(ctx context.Context, h *replhandler.ReplicateImageHandler, prompt, refImages, refTags, aspectRatio, resolution string) *protocol.CallToolResponse {
 	if refImages == "" || refTags == "" {
 		log.Fatal("Reference images and tags are required for Gen-4")
 	}
@@ -370,6 +452,7 @@ func runGen4(ctx context.Context, h *replhandler.ReplicateImageHandler, prompt,
 	}
 	
 	printResponse(resp)
+	return resp
 }
 
 func printResponse(resp *protocol.CallToolResponse) {
@@ -7,4 +7,6 @@ require (
 	gopkg.in/yaml.v3 v3.0.1
 )
 
+// The checkout must have Tool.OutputSchema and CallToolResponse.StructuredContent
+// (see pkg/handler/mcp_features.go)
 replace github.com/gomcpgo/mcp => ../mcp
@@ -0,0 +1,309 @@
+package brand
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults for optional kit settings
+const (
+	DefaultLogoPosition = "bottom-right"
+	DefaultLogoScale    = 0.15
+	DefaultLogoMargin   = 0.03
+	DefaultTolerance    = 60.0 // RGB distance; the full range is about 441
+	DefaultMinCoverage  = 0.25
+	dominantColorCount  = 5
+	paletteSampleEdge   = 256 // Images are sampled on at most this many pixels per edge
+)
+
+// Kit is a brand kit: the palette, logo, and content rules generate_branded
+// applies to every generation
+type Kit struct {
+	Name         string   `yaml:"name"`
+	Palette      []string `yaml:"palette"`       // Hex colors, e.g. "#FF5A1F"
+	Logo         string   `yaml:"logo"`          // Logo image, relative to the kit file
+	LogoPosition string   `yaml:"logo_position"` // top-left, top-right, bottom-left, bottom-right, or center
+	LogoScale    float64  `yaml:"logo_scale"`    // Logo width as a fraction of the image width
+	LogoMargin   float64  `yaml:"logo_margin"`   // Logo inset as a fraction of the image width
+	BannedTerms  []string `yaml:"banned_terms"`
+	Tolerance    float64  `yaml:"palette_tolerance"`    // Largest RGB distance counted as a palette match
+	MinCoverage  float64  `yaml:"min_palette_coverage"` // Share of pixels that must match the palette
+
+	colors []color.RGBA
+	terms  []string // Non-empty banned terms
+	banned []*regexp.Regexp
+	logo   image.Image
+}
+
+// Load reads a brand kit from a YAML file. An empty path returns nil: no
+// brand kit is configured.
+func Load(path string) (*Kit, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read brand kit: %w", err)
+	}
+
+	var kit Kit
+	if err := yaml.Unmarshal(data, &kit); err != nil {
+		return nil, fmt.Errorf("failed to parse brand kit: %w", err)
+	}
+	if kit.Name == "" {
+		kit.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if kit.LogoPosition == "" {
+		kit.LogoPosition = DefaultLogoPosition
+	}
+	if kit.LogoScale <= 0 {
+		kit.LogoScale = DefaultLogoScale
+	}
+	if kit.LogoMargin <= 0 {
+		kit.LogoMargin = DefaultLogoMargin
+	}
+	if kit.Tolerance <= 0 {
+		kit.Tolerance = DefaultTolerance
+	}
+	if kit.MinCoverage <= 0 {
+		kit.MinCoverage = DefaultMinCoverage
+	}
+	if kit.LogoScale > 1 || kit.LogoMargin > 0.5 || kit.MinCoverage > 1 {
+		return nil, fmt.Errorf("invalid brand kit: logo_scale, logo_margin, and min_palette_coverage are fractions")
+	}
+	if !storage.ValidOverlayPosition(kit.LogoPosition) {
+		return nil, fmt.Errorf("invalid brand kit logo_position %q", kit.LogoPosition)
+	}
+
+	for _, hex := range kit.Palette {
+		c, err := ParseHex(hex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid brand kit palette: %w", err)
+		}
+		kit.colors = append(kit.colors, c)
+	}
+	if kit.Logo != "" {
+		if !filepath.IsAbs(kit.Logo) {
+			kit.Logo = filepath.Join(filepath.Dir(path), kit.Logo)
+		}
+		if kit.logo, err = loadLogo(kit.Logo); err != nil {
+			return nil, err
+		}
+	}
+	for _, term := range kit.BannedTerms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		kit.terms = append(kit.terms, term)
+		kit.banned = append(kit.banned, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(term)+`\b`))
+	}
+	return &kit, nil
+}
+
+// NewPaletteKit returns a kit holding only a palette, with the default
+// tolerance and coverage, for checking images against colors given per call
+func NewPaletteKit(palette []string) (*Kit, error) {
+	kit := &Kit{Palette: palette, Tolerance: DefaultTolerance, MinCoverage: DefaultMinCoverage}
+	for _, hex := range palette {
+		c, err := ParseHex(hex)
+		if err != nil {
+			return nil, err
+		}
+		kit.colors = append(kit.colors, c)
+	}
+	return kit, nil
+}
+
+// Colors returns the kit's palette
+func (k *Kit) Colors() []color.RGBA {
+	return k.colors
+}
+
+// ParseHex parses a #RRGGBB or #RGB color
+func ParseHex(hex string) (color.RGBA, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("%q is not a hex color", hex)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("%q is not a hex color", hex)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// Hex formats a color as #RRGGBB
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// BannedTermsIn returns the banned terms that appear in a prompt
+func (k *Kit) BannedTermsIn(prompt string) []string {
+	var found []string
+	for i, re := range k.banned {
+		if re.MatchString(prompt) {
+			found = append(found, k.terms[i])
+		}
+	}
+	return found
+}
+
+// ApplyToPrompt adds the palette to a prompt
+func (k *Kit) ApplyToPrompt(prompt string) string {
+	if len(k.colors) == 0 {
+		return prompt
+	}
+	hexes := make([]string, len(k.colors))
+	for i, c := range k.colors {
+		hexes[i] = Hex(c)
+	}
+	return strings.TrimRight(strings.TrimSpace(prompt), ".") + ". Use a color palette of " + strings.Join(hexes, ", ") + "."
+}
+
+// NegativePrompt adds the banned terms to a negative prompt
+func (k *Kit) NegativePrompt(negative string) string {
+	if negative == "" {
+		return strings.Join(k.terms, ", ")
+	}
+	return strings.Join(append([]string{negative}, k.terms...), ", ")
+}
+
+// DominantColor is a prominent color of an image and its closest palette color
+type DominantColor struct {
+	Color    string  `json:"color"`
+	Share    float64 `json:"share"` // Share of the image's pixels
+	Nearest  string  `json:"nearest_palette_color"`
+	Distance float64 `json:"distance"`
+}
+
+// PaletteReport describes how closely an image keeps to the palette
+type PaletteReport struct {
+	Coverage       float64         `json:"coverage"` // Share of pixels within tolerance of a palette color
+	OnBrand        bool            `json:"on_brand"`
+	DominantColors []DominantColor `json:"dominant_colors"`
+}
+
+// CheckPalette measures palette proximity of the image at path
+func (k *Kit) CheckPalette(path string) (*PaletteReport, error) {
+	if len(k.colors) == 0 {
+		return nil, fmt.Errorf("the brand kit has no palette")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	img, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	step := max(1, max(bounds.Dx(), bounds.Dy())/paletteSampleEdge)
+
+	// Pixels are bucketed on 4 bits per channel to find the dominant colors
+	type bucket struct {
+		r, g, b, n int
+	}
+	buckets := map[int]*bucket{}
+	var total, matched int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				continue // Transparent areas are not part of the palette
+			}
+			total++
+			if _, d := k.nearest(c.R, c.G, c.B); d <= k.Tolerance {
+				matched++
+			}
+			key := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+			if buckets[key] == nil {
+				buckets[key] = &bucket{}
+			}
+			bk := buckets[key]
+			bk.r += int(c.R)
+			bk.g += int(c.G)
+			bk.b += int(c.B)
+			bk.n++
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("the image has no opaque pixels")
+	}
+
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		sorted = append(sorted, bk)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].n > sorted[j].n })
+
+	report := &PaletteReport{Coverage: float64(matched) / float64(total)}
+	report.OnBrand = report.Coverage >= k.MinCoverage
+	for i := 0; i < len(sorted) && i < dominantColorCount; i++ {
+		bk := sorted[i]
+		c := color.RGBA{R: uint8(bk.r / bk.n), G: uint8(bk.g / bk.n), B: uint8(bk.b / bk.n), A: 0xff}
+		nearest, d := k.nearest(c.R, c.G, c.B)
+		report.DominantColors = append(report.DominantColors, DominantColor{
+			Color:    Hex(c),
+			Share:    math.Round(float64(bk.n)/float64(total)*1000) / 1000,
+			Nearest:  Hex(nearest),
+			Distance: math.Round(d*10) / 10,
+		})
+	}
+	report.Coverage = math.Round(report.Coverage*1000) / 1000
+	return report, nil
+}
+
+// nearest returns the palette color closest to an RGB color and its distance
+func (k *Kit) nearest(r, g, b uint8) (color.RGBA, float64) {
+	best, bestDist := k.colors[0], math.MaxFloat64
+	for _, c := range k.colors {
+		dr, dg, db := float64(r)-float64(c.R), float64(g)-float64(c.G), float64(b)-float64(c.B)
+		if d := math.Sqrt(dr*dr + dg*dg + db*db); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best, bestDist
+}
+
+// HasPalette reports whether the kit defines a palette
+func (k *Kit) HasPalette() bool {
+	return len(k.colors) > 0
+}
+
+// LogoImage returns the kit's logo, or nil when it has none
+func (k *Kit) LogoImage() image.Image {
+	return k.logo
+}
+
+// loadLogo decodes a logo image
+func loadLogo(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open brand logo: %w", err)
+	}
+	defer file.Close()
+	logo, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode brand logo: %w", err)
+	}
+	return logo, nil
+}
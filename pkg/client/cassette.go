@@ -0,0 +1,214 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cassette modes
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// cassetteFile is the name of the recording inside a cassette directory
+const cassetteFile = "cassette.json"
+
+// Interaction is one recorded HTTP request/response pair. Request headers are
+// never recorded, so the API token does not end up on disk.
+type Interaction struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	BodySHA256  string `json:"body_sha256,omitempty"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body"` // base64 in JSON, so binary outputs round-trip
+}
+
+// cassette is an ordered list of interactions persisted as JSON
+type cassette struct {
+	mu           sync.Mutex
+	path         string
+	Interactions []*Interaction `json:"interactions"`
+	used         map[int]bool
+}
+
+// NewCassetteTransport returns an http.RoundTripper that records traffic to, or
+// replays traffic from, the cassette in dir. Recording passes requests through
+// next (http.DefaultTransport when nil). Replaying never touches the network,
+// so it works without an API token.
+func NewCassetteTransport(mode, dir string, next http.RoundTripper) (http.RoundTripper, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c := &cassette{path: filepath.Join(dir, cassetteFile), used: map[int]bool{}}
+
+	switch mode {
+	case CassetteRecord:
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+		// Append to an existing recording so sessions can be built up incrementally
+		if err := c.load(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return &recordingTransport{cassette: c, next: next}, nil
+	case CassetteReplay:
+		if err := c.load(); err != nil {
+			return nil, fmt.Errorf("failed to load cassette: %w", err)
+		}
+		return &replayTransport{cassette: c}, nil
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q (use record or replay)", mode)
+	}
+}
+
+func (c *cassette) load() error {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("invalid cassette %s: %w", c.path, err)
+	}
+	return nil
+}
+
+func (c *cassette) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// recordingTransport forwards requests and records each exchange
+type recordingTransport struct {
+	cassette *cassette
+	next     http.RoundTripper
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	bodyHash, err := hashRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.cassette.mu.Lock()
+	defer t.cassette.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, &Interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		BodySHA256:  bodyHash,
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        body,
+	})
+	if err := t.cassette.save(); err != nil {
+		return nil, fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return resp, nil
+}
+
+// replayTransport serves recorded responses in order. Repeated requests (such
+// as status polling) receive successive recordings; once those run out the last
+// one is repeated.
+type replayTransport struct {
+	cassette *cassette
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	bodyHash, err := hashRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.cassette.mu.Lock()
+	defer t.cassette.mu.Unlock()
+
+	url := req.URL.String()
+	match := t.find(func(i *Interaction) bool {
+		return i.Method == req.Method && i.URL == url && i.BodySHA256 == bodyHash
+	})
+	if match == nil {
+		// Request bodies can change between runs (e.g. a re-encoded input)
+		match = t.find(func(i *Interaction) bool {
+			return i.Method == req.Method && i.URL == url
+		})
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, url)
+	}
+
+	header := http.Header{}
+	if match.ContentType != "" {
+		header.Set("Content-Type", match.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", match.Status, http.StatusText(match.Status)),
+		StatusCode:    match.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(match.Body)),
+		ContentLength: int64(len(match.Body)),
+		Request:       req,
+	}, nil
+}
+
+// find returns the first unused matching interaction, or the last match if all
+// have been used
+func (t *replayTransport) find(matches func(*Interaction) bool) *Interaction {
+	last := -1
+	for idx, interaction := range t.cassette.Interactions {
+		if !matches(interaction) {
+			continue
+		}
+		if !t.cassette.used[idx] {
+			t.cassette.used[idx] = true
+			return interaction
+		}
+		last = idx
+	}
+	if last >= 0 {
+		return t.cassette.Interactions[last]
+	}
+	return nil
+}
+
+// hashRequestBody hashes and restores a request body
+func hashRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+)
+
+// Substitution is a model that predictions went to in place of a deprecated
+// one, or one whose pinned version was removed
+type Substitution struct {
+	Requested string `json:"requested"`
+	Used      string `json:"used"`
+	Reason    string `json:"reason"`
+}
+
+// Warning describes the substitution for the response of the call it affected
+func (s Substitution) Warning() string {
+	return fmt.Sprintf("%s %s; %s was used instead. Update the configuration or the model argument to stop this warning.", s.Requested, s.Reason, s.Used)
+}
+
+// versionLookup is implemented by providers that can find the latest version
+// of a model
+type versionLookup interface {
+	GetModel(ctx context.Context, modelID string) (*ModelDetails, *ModelVersion, error)
+}
+
+// Substitute returns the substitution predictions for a model go through,
+// reporting false when they go to the model itself. Deprecated models with
+// a registered replacement are always substituted; others only once their
+// version was found missing.
+func (r *Router) Substitute(modelID string) (Substitution, bool) {
+	r.mu.Lock()
+	sub, ok := r.substitutes[modelID]
+	r.mu.Unlock()
+	if ok {
+		return sub, true
+	}
+	if info := models.GetModelInfo(modelID); info.Deprecated && info.ReplacedBy != "" {
+		return Substitution{Requested: modelID, Used: info.ReplacedBy, Reason: "is deprecated"}, true
+	}
+	return Substitution{}, false
+}
+
+// replace finds the replacement of a model whose version the provider could
+// not find: the registered replacement, or else the latest version of a
+// pinned model. The substitution is remembered so later predictions skip the
+// failing request. It reports false when there is no replacement.
+func (r *Router) replace(ctx context.Context, p Provider, modelID string) (Substitution, bool) {
+	sub := Substitution{Requested: modelID, Reason: "no longer exists on " + p.Name()}
+	if replacement := models.GetModelInfo(modelID).ReplacedBy; replacement != "" {
+		sub.Used = replacement
+	} else if name, version, pinned := strings.Cut(modelID, ":"); pinned {
+		lookup, ok := p.(versionLookup)
+		if !ok {
+			return Substitution{}, false
+		}
+		model, _, err := lookup.GetModel(ctx, name)
+		if err != nil || model.LatestVersion == nil || model.LatestVersion.ID == version {
+			return Substitution{}, false
+		}
+		sub.Used = name + ":" + model.LatestVersion.ID
+		sub.Reason = "pins a version that no longer exists on " + p.Name()
+	} else {
+		return Substitution{}, false
+	}
+
+	slog.Warn("model replaced", "requested", sub.Requested, "used", sub.Used, "reason", sub.Reason)
+	r.mu.Lock()
+	r.substitutes[modelID] = sub
+	r.mu.Unlock()
+	return sub, true
+}
+
+// versionMissing reports whether err is a provider's answer to a model or
+// version it does not have
+func versionMissing(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == ErrCodeVersionNotFound && apiErr.StatusCode != 0
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrDryRun is returned instead of a prediction while a dry run is active
+var ErrDryRun = errors.New("dry run: no prediction was created")
+
+// PlannedPrediction is a prediction a dry run stopped short of creating
+type PlannedPrediction struct {
+	Model    string
+	Provider string // Empty when no configured provider can serve the model
+	Input    map[string]interface{}
+	Warning  string
+}
+
+// DryRun collects the predictions an operation would have created
+type DryRun struct {
+	mu          sync.Mutex
+	predictions []PlannedPrediction
+}
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context in which the Router records predictions in
+// the returned DryRun and fails them with ErrDryRun instead of creating them
+func WithDryRun(ctx context.Context) (context.Context, *DryRun) {
+	d := &DryRun{}
+	return context.WithValue(ctx, dryRunKey{}, d), d
+}
+
+// Predictions returns the recorded predictions in the order they were made
+func (d *DryRun) Predictions() []PlannedPrediction {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]PlannedPrediction(nil), d.predictions...)
+}
+
+// recordDryRun adds a prediction to the dry run in ctx, reporting whether
+// one is active
+func recordDryRun(ctx context.Context, planned PlannedPrediction) bool {
+	d, ok := ctx.Value(dryRunKey{}).(*DryRun)
+	if !ok {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.predictions = append(d.predictions, planned)
+	return true
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes derived from Replicate failure modes
+const (
+	ErrCodeContentBlocked  = "content_blocked"
+	ErrCodeNSFW            = "nsfw_content"
+	ErrCodeOutOfMemory     = "out_of_memory"
+	ErrCodeColdBootTimeout = "cold_boot_timeout"
+	ErrCodeVersionNotFound = "version_not_found"
+	ErrCodeInvalidInput    = "invalid_input"
+	ErrCodeBilling         = "billing_issue"
+	ErrCodeRateLimit       = "rate_limit"
+	ErrCodeAuth            = "authentication_error"
+	ErrCodeAPI             = "api_error"
+
+	ErrCodeProviderUnavailable = "provider_unavailable"
+)
+
+// APIError is returned when the Replicate API responds with an error status
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		// Raised locally, before any request was sent
+		return e.Message
+	}
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// newAPIError builds an APIError from an HTTP status and response body
+func newAPIError(statusCode int, body []byte) *APIError {
+	message := string(body)
+	var errorResp map[string]interface{}
+	if err := json.Unmarshal(body, &errorResp); err == nil {
+		if detail, ok := errorResp["detail"].(string); ok && detail != "" {
+			message = detail
+		} else if list, ok := errorResp["errors"].([]interface{}); ok && len(list) > 0 {
+			// Stability reports a list of error strings
+			parts := make([]string, len(list))
+			for i, item := range list {
+				parts[i] = fmt.Sprintf("%v", item)
+			}
+			message = strings.Join(parts, "; ")
+		} else if inner, ok := errorResp["error"].(map[string]interface{}); ok {
+			// OpenAI nests the message in an error object
+			if msg, ok := inner["message"].(string); ok && msg != "" {
+				message = msg
+			}
+		}
+	}
+
+	code := ErrCodeAPI
+	switch statusCode {
+	case http.StatusPaymentRequired:
+		code = ErrCodeBilling
+	case http.StatusUnauthorized, http.StatusForbidden:
+		code = ErrCodeAuth
+	case http.StatusNotFound:
+		code = ErrCodeVersionNotFound
+	case http.StatusUnprocessableEntity, http.StatusBadRequest:
+		code = ErrCodeInvalidInput
+	case http.StatusTooManyRequests:
+		code = ErrCodeRateLimit
+	}
+
+	// Some validation failures come back with other statuses, and missing
+	// versions with validation statuses
+	switch failureCode := classifyText(message); {
+	case code == ErrCodeAPI && failureCode != "":
+		code = failureCode
+	case code == ErrCodeInvalidInput && failureCode == ErrCodeVersionNotFound:
+		code = failureCode
+	}
+
+	return &APIError{
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    message,
+		Body:       string(body),
+	}
+}
+
+// failurePatterns maps substrings of Replicate error messages and logs to error codes
+var failurePatterns = []struct {
+	code     string
+	patterns []string
+}{
+	{ErrCodeContentBlocked, []string{"safety filter", "filtered out", "blocked by", "responsible ai", "content policy", "prohibited content"}},
+	{ErrCodeNSFW, []string{"nsfw", "safety checker", "unsafe content", "content flagged"}},
+	{ErrCodeOutOfMemory, []string{"cuda out of memory", "out of memory", "cuda error: out of memory", "oom-kill"}},
+	{ErrCodeColdBootTimeout, []string{"timed out waiting for model to boot", "model boot timed out", "setup timed out", "cold boot"}},
+	{ErrCodeVersionNotFound, []string{"version not found", "invalid version", "version does not exist", "model not found"}},
+	{ErrCodeInvalidInput, []string{"input validation", "invalid input", "validationerror", "unexpected keyword argument", "is not a valid"}},
+}
+
+// classifyText returns the error code matching a message, or "" if none match
+func classifyText(text string) string {
+	lower := strings.ToLower(text)
+	for _, fp := range failurePatterns {
+		for _, pattern := range fp.patterns {
+			if strings.Contains(lower, pattern) {
+				return fp.code
+			}
+		}
+	}
+	return ""
+}
+
+// ClassifyFailure maps a failed prediction's error value and logs to an error
+// code and a readable message. It returns an empty code for unrecognized failures.
+func ClassifyFailure(predictionError interface{}, logs string) (string, string) {
+	message := FailureMessage(predictionError)
+
+	if code := classifyText(message); code != "" {
+		return code, message
+	}
+	// The error field is often generic; the logs carry the real cause
+	if code := classifyText(logs); code != "" {
+		return code, message
+	}
+	return "", message
+}
+
+// IsContentBlocked reports whether a prediction's error or logs indicate that
+// its output was withheld by a safety filter
+func IsContentBlocked(predictionError interface{}, logs string) bool {
+	code, _ := ClassifyFailure(predictionError, logs)
+	return code == ErrCodeContentBlocked || code == ErrCodeNSFW
+}
+
+// FailureMessage extracts a readable message from a prediction's error field
+func FailureMessage(predictionError interface{}) string {
+	switch e := predictionError.(type) {
+	case nil:
+		return "prediction failed"
+	case string:
+		if e == "" {
+			return "prediction failed"
+		}
+		return e
+	case map[string]interface{}:
+		if msg, ok := e["message"]; ok {
+			return fmt.Sprintf("%v", msg)
+		}
+	}
+	return fmt.Sprintf("%v", predictionError)
+}
@@ -0,0 +1,319 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+const (
+	falQueueURL = "https://queue.fal.run"
+)
+
+// falModel maps a Replicate model to the fal.ai endpoint serving the same
+// weights, and translates Replicate-style input to the endpoint's schema
+type falModel struct {
+	endpoint string
+	input    func(map[string]interface{}) map[string]interface{}
+}
+
+// falModels lists the models fal.ai can serve in place of Replicate
+var falModels = map[string]falModel{
+	models.ModelFluxSchnell: {"fal-ai/flux/schnell", falGenerationInput},
+	models.ModelFluxDev:     {"fal-ai/flux/dev", falGenerationInput},
+	models.ModelFluxPro:     {"fal-ai/flux-pro/v1.1", falGenerationInput},
+	models.ModelSDXL:        {"fal-ai/fast-sdxl", falGenerationInput},
+	models.ModelRealESRGAN:  {"fal-ai/esrgan", falUpscaleInput},
+}
+
+// FalClient runs predictions on fal.ai's queue API
+type FalClient struct {
+	apiKey     string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	requests map[string]falRequest // Queue URLs by request ID
+}
+
+// falRequest holds the queue URLs returned when a request is submitted
+type falRequest struct {
+	StatusURL   string `json:"status_url"`
+	ResponseURL string `json:"response_url"`
+}
+
+// NewFalClient creates a new fal.ai client
+func NewFalClient(apiKey string) *FalClient {
+	return NewFalClientWithTransport(apiKey, nil)
+}
+
+// NewFalClientWithTransport creates a fal.ai client that sends requests through
+// a custom transport, such as a record/replay cassette
+func NewFalClientWithTransport(apiKey string, transport http.RoundTripper) *FalClient {
+	return &FalClient{
+		apiKey: apiKey,
+		httpClient: &http.Client{
+			Timeout:   60 * time.Second,
+			Transport: transport,
+		},
+		requests: map[string]falRequest{},
+	}
+}
+
+// Name returns the provider name
+func (c *FalClient) Name() string {
+	return ProviderFal
+}
+
+// Supports reports whether fal.ai serves a model
+func (c *FalClient) Supports(modelID string) bool {
+	_, ok := falModels[modelID]
+	return ok
+}
+
+// CreatePrediction submits a request to the fal.ai queue
+func (c *FalClient) CreatePrediction(ctx context.Context, modelID string, input map[string]interface{}) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "fal.create_prediction", "fal.model", modelID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	model, ok := falModels[modelID]
+	if !ok {
+		return nil, fmt.Errorf("model %s is not available on fal.ai", modelID)
+	}
+
+	url := fmt.Sprintf("%s/%s", falQueueURL, model.endpoint)
+	falInput := model.input(input)
+	slog.Debug("creating fal prediction", "model", modelID, "url", url, "input", falInput)
+
+	body := newJSONBody(falInput)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	respBody, status, err := c.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK && status != http.StatusCreated && status != http.StatusAccepted {
+		return nil, newAPIError(status, respBody)
+	}
+
+	var submitted struct {
+		falRequest
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(respBody, &submitted); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	c.mu.Lock()
+	c.requests[submitted.RequestID] = submitted.falRequest
+	c.mu.Unlock()
+
+	slog.Debug("fal prediction created", "request_id", submitted.RequestID, "model", modelID)
+	span.SetAttributes("fal.request_id", submitted.RequestID)
+	return &types.ReplicatePredictionResponse{
+		ID:     submitted.RequestID,
+		Status: types.StatusStarting,
+		Input:  input,
+	}, nil
+}
+
+// GetPrediction checks a queued request, fetching its result once completed
+func (c *FalClient) GetPrediction(ctx context.Context, requestID string) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "fal.get_prediction", "fal.request_id", requestID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	c.mu.Lock()
+	request, ok := c.requests[requestID]
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown fal.ai request %s (requests are tracked in memory only)", requestID)
+	}
+
+	respBody, status, err := c.get(ctx, request.StatusURL)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK && status != http.StatusAccepted {
+		return nil, newAPIError(status, respBody)
+	}
+
+	var queueStatus struct {
+		Status string `json:"status"`
+		Logs   []struct {
+			Message string `json:"message"`
+		} `json:"logs"`
+	}
+	if err := json.Unmarshal(respBody, &queueStatus); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	prediction := &types.ReplicatePredictionResponse{ID: requestID}
+	for _, entry := range queueStatus.Logs {
+		prediction.Logs += entry.Message + "\n"
+	}
+
+	switch queueStatus.Status {
+	case "IN_QUEUE":
+		prediction.Status = types.StatusStarting
+	case "IN_PROGRESS":
+		prediction.Status = types.StatusProcessing
+	case "COMPLETED":
+		// Completed requests stay tracked so expired output URLs can be re-fetched
+		if err := c.fetchResult(ctx, request.ResponseURL, prediction); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected fal.ai queue status %q", queueStatus.Status)
+	}
+
+	span.SetAttributes("fal.status", prediction.Status)
+	return prediction, nil
+}
+
+// fetchResult fills a prediction from a completed request's result
+func (c *FalClient) fetchResult(ctx context.Context, responseURL string, prediction *types.ReplicatePredictionResponse) error {
+	respBody, status, err := c.get(ctx, responseURL)
+	if err != nil {
+		return err
+	}
+
+	// Failed requests report their error through the result endpoint
+	if status != http.StatusOK {
+		apiErr := newAPIError(status, respBody)
+		prediction.Status = types.StatusFailed
+		prediction.Error = apiErr.Message
+		return nil
+	}
+
+	var result struct {
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+		Image *struct {
+			URL string `json:"url"`
+		} `json:"image"`
+		Timings struct {
+			Inference float64 `json:"inference"`
+		} `json:"timings"`
+		HasNSFWConcepts []bool `json:"has_nsfw_concepts"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+
+	// fal.ai replaces flagged images with black ones rather than failing
+	for _, nsfw := range result.HasNSFWConcepts {
+		if nsfw {
+			prediction.Status = types.StatusFailed
+			prediction.Error = "NSFW content detected by the safety checker"
+			return nil
+		}
+	}
+
+	var urls []interface{}
+	for _, img := range result.Images {
+		urls = append(urls, img.URL)
+	}
+	if result.Image != nil {
+		urls = append(urls, result.Image.URL)
+	}
+
+	prediction.Status = types.StatusSucceeded
+	prediction.Output = urls
+	if result.Timings.Inference > 0 {
+		prediction.Metrics = &types.PredictionMetrics{PredictTime: result.Timings.Inference}
+	}
+	return nil
+}
+
+// get sends an authenticated GET request
+func (c *FalClient) get(ctx context.Context, url string) ([]byte, int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	return c.do(httpReq)
+}
+
+// do sends an authenticated request and reads the response body
+func (c *FalClient) do(httpReq *http.Request) ([]byte, int, error) {
+	httpReq.Header.Set("Authorization", "Key "+c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+// falGenerationInput translates text-to-image input to fal.ai's schema
+func falGenerationInput(input map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for key, value := range input {
+		switch key {
+		case "width", "height":
+			// Combined into image_size below
+		case "num_outputs":
+			out["num_images"] = value
+		case "output_format":
+			// fal.ai encodes only JPEG and PNG; WebP is re-encoded after saving
+			switch value {
+			case "jpg":
+				value = "jpeg"
+			case "webp":
+				value = "png"
+			}
+			out["output_format"] = value
+		default:
+			out[key] = value
+		}
+	}
+
+	width, hasWidth := input["width"]
+	height, hasHeight := input["height"]
+	if hasWidth && hasHeight {
+		out["image_size"] = map[string]interface{}{"width": width, "height": height}
+	}
+	return out
+}
+
+// falUpscaleInput translates Real-ESRGAN input to fal.ai's schema
+func falUpscaleInput(input map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for key, value := range input {
+		switch key {
+		case "img", "image":
+			out["image_url"] = value
+		case "face_enhance":
+			out["face"] = value
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// Inputs sent through the files API. Smaller inputs stay inline as data
+// URLs, which saves a request; larger ones would balloon the prediction
+// request by a third.
+const (
+	UploadThreshold = 1024 * 1024
+	MaxUploadBytes  = 100 * 1024 * 1024 // Largest file the files API accepts
+	MaxInlineBytes  = 5 * 1024 * 1024   // Largest file sent inline when its upload fails
+)
+
+// uploadReuseMargin is how long before it expires an uploaded file stops
+// being reused, so a prediction never starts with a file about to vanish
+const uploadReuseMargin = time.Hour
+
+// UploadedFile is a file stored with Replicate's files API
+type UploadedFile struct {
+	ID        string `json:"id"`
+	Size      int64  `json:"size"`
+	ExpiresAt string `json:"expires_at"`
+	URLs      struct {
+		Get string `json:"get"`
+	} `json:"urls"`
+}
+
+// uploadCache remembers the files uploaded recently by content hash, so a
+// sweep sending the same image again does not upload it again
+type uploadCache struct {
+	mu    sync.Mutex
+	files map[string]cachedUpload
+}
+
+// cachedUpload is the URL of an uploaded file and when to stop using it
+type cachedUpload struct {
+	url   string
+	until time.Time
+}
+
+// SetFileUploads turns sending inputs through the files API on or off. When
+// on, inputs over UploadThreshold are uploaded and passed to predictions by
+// URL; smaller ones, and any whose upload fails while still small enough for
+// a data URL, are sent inline as before.
+func (c *ReplicateClient) SetFileUploads(enabled bool) {
+	if !enabled {
+		c.uploads = nil
+		return
+	}
+	c.uploads = &uploadCache{files: map[string]cachedUpload{}}
+}
+
+// UploadFile stores a file with the files API and returns it
+func (c *ReplicateClient) UploadFile(ctx context.Context, file *types.FileData) (_ *UploadedFile, err error) {
+	ctx, span := tracing.Start(ctx, "replicate.upload_file")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if file.Size > MaxUploadBytes {
+		return nil, fmt.Errorf("input of %dMB is over the %dMB the files API accepts", file.Size/(1024*1024), MaxUploadBytes/(1024*1024))
+	}
+	body, contentType := newMultipartBody(multipartForm{files: map[string]*types.FileData{"content": file}})
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", replicateAPIURL+"/files", body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
+	httpReq.Header.Set("Content-Type", contentType)
+
+	// Large uploads outlast the client timeout meant for API calls
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, respBody)
+	}
+
+	var uploaded UploadedFile
+	if err := json.Unmarshal(respBody, &uploaded); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if uploaded.URLs.Get == "" {
+		return nil, fmt.Errorf("the files API returned no URL for the upload")
+	}
+	slog.Debug("file uploaded", "file_id", uploaded.ID, "size", uploaded.Size, "expires_at", uploaded.ExpiresAt)
+	span.SetAttributes("replicate.file_id", uploaded.ID)
+	return &uploaded, nil
+}
+
+// uploadInputs returns the input with every file over UploadThreshold
+// replaced by the URL of its upload. The input itself is left unchanged, so
+// a retry or a dry run still sees the files.
+func (c *ReplicateClient) uploadInputs(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	if c.uploads == nil {
+		return input, nil
+	}
+	out, err := c.uploadValue(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return out.(map[string]interface{}), nil
+}
+
+// uploadValue replaces the large files within an input value by URLs
+func (c *ReplicateClient) uploadValue(ctx context.Context, v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case *types.FileData:
+		if val.Size <= UploadThreshold {
+			return val, nil
+		}
+		url, err := c.upload(ctx, val)
+		if err != nil {
+			// A file small enough for a data URL is still sent inline
+			if val.Size <= MaxInlineBytes {
+				slog.Warn("file upload failed; sending the input inline", "size", val.Size, "error", err)
+				return val, nil
+			}
+			return nil, err
+		}
+		return url, nil
+	case []*types.FileData:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = item
+		}
+		return c.uploadValue(ctx, items)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			replaced, err := c.uploadValue(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = replaced
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			replaced, err := c.uploadValue(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = replaced
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// upload returns the URL of a file, uploading it unless the same content
+// was uploaded recently
+func (c *ReplicateClient) upload(ctx context.Context, file *types.FileData) (string, error) {
+	key, err := fileHash(file)
+	if err != nil {
+		return "", err
+	}
+	c.uploads.mu.Lock()
+	cached, ok := c.uploads.files[key]
+	c.uploads.mu.Unlock()
+	if ok && time.Now().Before(cached.until) {
+		return cached.url, nil
+	}
+
+	uploaded, err := c.UploadFile(ctx, file)
+	if err != nil {
+		return "", err
+	}
+	until := time.Now().Add(uploadReuseMargin)
+	if expires, err := time.Parse(time.RFC3339, uploaded.ExpiresAt); err == nil {
+		until = expires.Add(-uploadReuseMargin)
+	}
+	c.uploads.mu.Lock()
+	for k, entry := range c.uploads.files {
+		if time.Now().After(entry.until) {
+			delete(c.uploads.files, k)
+		}
+	}
+	c.uploads.files[key] = cachedUpload{url: uploaded.URLs.Get, until: until}
+	c.uploads.mu.Unlock()
+	return uploaded.URLs.Get, nil
+}
+
+// fileHash returns a hash of a file's content
+func fileHash(file *types.FileData) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, src); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Priorities of predictions waiting for a slot. Interactive predictions are
+// started before any batch prediction that is still waiting.
+const (
+	PriorityInteractive = "interactive"
+	PriorityBatch       = "batch"
+)
+
+// slotLease frees a prediction's slot when its caller stops polling before
+// the prediction finishes, such as after a timeout. It outlasts the longest
+// wait a call can ask for.
+const slotLease = 20 * time.Minute
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose predictions wait for a slot with the
+// given priority
+func WithPriority(ctx context.Context, priority string) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFrom returns the priority set on ctx, or "" when none was set
+func PriorityFrom(ctx context.Context) string {
+	priority, _ := ctx.Value(priorityKey{}).(string)
+	return priority
+}
+
+// ValidPriority reports whether priority is a known priority
+func ValidPriority(priority string) bool {
+	return priority == PriorityInteractive || priority == PriorityBatch
+}
+
+// Limiter bounds the number of predictions in flight. A slot is taken when a
+// prediction is created and freed when polling sees it finish. Callers waiting
+// for a slot are served interactive first, then batch, each in arrival order.
+type Limiter struct {
+	mu          sync.Mutex
+	limit       int
+	active      int                    // Slots taken, including those being created
+	held        map[string]*time.Timer // Lease of each running prediction, by ID
+	interactive []chan struct{}
+	batch       []chan struct{}
+}
+
+// NewLimiter creates a limiter allowing limit predictions in flight
+func NewLimiter(limit int) *Limiter {
+	return &Limiter{limit: limit, held: make(map[string]*time.Timer)}
+}
+
+// acquire waits for a free slot, giving up when ctx is done
+func (l *Limiter) acquire(ctx context.Context, priority string) error {
+	l.mu.Lock()
+	if l.active < l.limit && len(l.interactive) == 0 && len(l.batch) == 0 {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	if priority == PriorityBatch {
+		l.batch = append(l.batch, ready)
+	} else {
+		priority = PriorityInteractive
+		l.interactive = append(l.interactive, ready)
+	}
+	slog.Debug("waiting for a prediction slot", "priority", priority,
+		"interactive_waiting", len(l.interactive), "batch_waiting", len(l.batch))
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if removeWaiter(&l.interactive, ready) || removeWaiter(&l.batch, ready) {
+			return fmt.Errorf("waiting for a prediction slot: %w", ctx.Err())
+		}
+		// The slot was handed over as ctx ended; pass it on
+		l.releaseLocked()
+		return fmt.Errorf("waiting for a prediction slot: %w", ctx.Err())
+	}
+}
+
+// hold keeps a slot for a created prediction until done is called with its ID
+// or the lease runs out
+func (l *Limiter) hold(predictionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held[predictionID] = time.AfterFunc(slotLease, func() {
+		slog.Debug("prediction slot lease expired", "prediction_id", predictionID)
+		l.done(predictionID)
+	})
+}
+
+// done frees the slot of a finished prediction. Predictions without a slot,
+// or whose slot was already freed, are ignored.
+func (l *Limiter) done(predictionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lease, ok := l.held[predictionID]
+	if !ok {
+		return
+	}
+	lease.Stop()
+	delete(l.held, predictionID)
+	l.releaseLocked()
+}
+
+// release frees a slot whose prediction was never created
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.releaseLocked()
+}
+
+// releaseLocked hands a freed slot to the next waiter, if any
+func (l *Limiter) releaseLocked() {
+	for _, queue := range []*[]chan struct{}{&l.interactive, &l.batch} {
+		if len(*queue) > 0 {
+			next := (*queue)[0]
+			*queue = (*queue)[1:]
+			close(next)
+			return
+		}
+	}
+	l.active--
+}
+
+// removeWaiter removes ready from queue, reporting whether it was there
+func removeWaiter(queue *[]chan struct{}, ready chan struct{}) bool {
+	for i, waiter := range *queue {
+		if waiter == ready {
+			*queue = append((*queue)[:i], (*queue)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
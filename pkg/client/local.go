@@ -0,0 +1,563 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// Local backends
+const (
+	LocalBackendA1111   = "a1111"
+	LocalBackendComfyUI = "comfyui"
+)
+
+// Default local server addresses
+const (
+	DefaultA1111URL   = "http://127.0.0.1:7860"
+	DefaultComfyUIURL = "http://127.0.0.1:8188"
+
+	// DefaultLocalUpscaler is the Automatic1111 upscaler used when none is configured
+	DefaultLocalUpscaler = "R-ESRGAN 4x+"
+)
+
+// localSizes maps aspect ratios to SDXL-friendly sizes of about one megapixel,
+// for models whose input gives an aspect ratio rather than a size
+var localSizes = map[string][2]int{
+	"1:1":  {1024, 1024},
+	"16:9": {1344, 768},
+	"9:16": {768, 1344},
+	"4:3":  {1152, 896},
+	"3:4":  {896, 1152},
+	"3:2":  {1216, 832},
+	"2:3":  {832, 1216},
+	"21:9": {1536, 640},
+}
+
+// localSupports reports whether a local server can stand in for a model: the
+// local models themselves, and Replicate-hosted models of the given categories.
+// The server runs whatever checkpoint it has loaded, whichever model is named.
+func localSupports(modelID string, categories ...string) bool {
+	info := models.GetModelInfo(modelID)
+	if info.Provider != "" && info.Provider != models.ProviderLocal {
+		return false
+	}
+	for _, category := range categories {
+		if info.Category == category {
+			return true
+		}
+	}
+	return false
+}
+
+// A1111Client runs generation, upscaling and inpainting on a local
+// Automatic1111 (or compatible, such as Forge) server through its /sdapi/v1
+// API. The API is synchronous, so CreatePrediction returns a completed
+// prediction with inline data URL outputs.
+type A1111Client struct {
+	baseURL    string
+	upscaler   string
+	httpClient *http.Client
+	results    *inlineResults
+}
+
+// NewA1111Client creates a client for an Automatic1111 server. An empty
+// baseURL or upscaler uses the defaults.
+func NewA1111Client(baseURL, upscaler string) *A1111Client {
+	return NewA1111ClientWithTransport(baseURL, upscaler, nil)
+}
+
+// NewA1111ClientWithTransport creates an Automatic1111 client that sends
+// requests through a custom transport, such as a record/replay cassette
+func NewA1111ClientWithTransport(baseURL, upscaler string, transport http.RoundTripper) *A1111Client {
+	if baseURL == "" {
+		baseURL = DefaultA1111URL
+	}
+	if upscaler == "" {
+		upscaler = DefaultLocalUpscaler
+	}
+	return &A1111Client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		upscaler: upscaler,
+		httpClient: &http.Client{
+			// Generation runs inside the request, and local GPUs can be slow
+			Timeout:   10 * time.Minute,
+			Transport: transport,
+		},
+		results: newInlineResults(),
+	}
+}
+
+// Name returns the provider name
+func (c *A1111Client) Name() string {
+	return ProviderLocal
+}
+
+// Supports reports whether the server can run a model's operation
+func (c *A1111Client) Supports(modelID string) bool {
+	if models.GetModelInfo(modelID).Category == models.CategoryEditing {
+		return models.RequiresMask(modelID) && localSupports(modelID, models.CategoryEditing)
+	}
+	return localSupports(modelID, models.CategoryGeneration, models.CategoryUpscaling)
+}
+
+// CreatePrediction runs a model's operation on the server and waits for its output
+func (c *A1111Client) CreatePrediction(ctx context.Context, modelID string, input map[string]interface{}) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "a1111.create_prediction", "a1111.model", modelID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if !c.Supports(modelID) {
+		return nil, fmt.Errorf("model %s cannot run on the local Automatic1111 server", modelID)
+	}
+
+	var endpoint string
+	var request map[string]interface{}
+	switch models.GetModelInfo(modelID).Category {
+	case models.CategoryUpscaling:
+		endpoint, request = "extra-single-image", c.upscaleRequest(input)
+	case models.CategoryEditing:
+		endpoint, request = "img2img", a1111InpaintRequest(input)
+	default:
+		endpoint, request = "txt2img", a1111GenerationRequest(input)
+	}
+
+	url := fmt.Sprintf("%s/sdapi/v1/%s", c.baseURL, endpoint)
+	slog.Debug("creating a1111 prediction", "model", modelID, "url", url, "request", request)
+
+	body := newJSONBody(request)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	startTime := time.Now()
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the local Automatic1111 server at %s (is it running with --api?): %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		Images []string `json:"images"` // txt2img and img2img
+		Image  string   `json:"image"`  // extra-single-image
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if result.Image != "" {
+		result.Images = append(result.Images, result.Image)
+	}
+
+	var outputs []interface{}
+	for _, image := range result.Images {
+		outputs = append(outputs, "data:image/png;base64,"+image)
+	}
+
+	prediction := &types.ReplicatePredictionResponse{
+		ID:      newInlineID(),
+		Status:  types.StatusSucceeded,
+		Input:   input,
+		Output:  outputs,
+		Metrics: &types.PredictionMetrics{PredictTime: time.Since(startTime).Seconds()},
+	}
+	c.results.store(prediction)
+
+	slog.Debug("a1111 prediction completed", "prediction_id", prediction.ID, "model", modelID, "outputs", len(outputs))
+	span.SetAttributes("a1111.prediction_id", prediction.ID)
+	return prediction, nil
+}
+
+// GetPrediction returns a completed prediction
+func (c *A1111Client) GetPrediction(ctx context.Context, predictionID string) (*types.ReplicatePredictionResponse, error) {
+	return c.results.take(predictionID)
+}
+
+// a1111GenerationRequest translates text-to-image input to the txt2img schema
+func a1111GenerationRequest(input map[string]interface{}) map[string]interface{} {
+	request := map[string]interface{}{
+		"prompt": input["prompt"],
+	}
+	copyLocalValues(request, input)
+	width, height := localSize(input)
+	request["width"] = width
+	request["height"] = height
+	return request
+}
+
+// a1111InpaintRequest translates inpainting input to the img2img schema
+func a1111InpaintRequest(input map[string]interface{}) map[string]interface{} {
+	request := map[string]interface{}{
+		"prompt":      input["prompt"],
+		"init_images": []interface{}{firstImage(input)},
+		"mask":        input["mask"],
+		// Regenerate only the masked area at full resolution
+		"inpaint_full_res": true,
+		"inpainting_fill":  1, // Start from the original pixels
+	}
+	copyLocalValues(request, input)
+	if strength, ok := input["strength"]; ok {
+		request["denoising_strength"] = strength
+	}
+	return request
+}
+
+// upscaleRequest translates upscaling input to the extra-single-image schema
+func (c *A1111Client) upscaleRequest(input map[string]interface{}) map[string]interface{} {
+	request := map[string]interface{}{
+		"image":      firstImage(input),
+		"upscaler_1": c.upscaler,
+	}
+	if scale, ok := input["scale"]; ok {
+		request["upscaling_resize"] = scale
+	}
+	return request
+}
+
+// copyLocalValues copies the sampling inputs shared by txt2img and img2img
+func copyLocalValues(request, input map[string]interface{}) {
+	renames := map[string]string{
+		"negative_prompt":     "negative_prompt",
+		"seed":                "seed",
+		"guidance_scale":      "cfg_scale",
+		"cfg":                 "cfg_scale",
+		"num_inference_steps": "steps",
+		"num_outputs":         "batch_size",
+	}
+	for from, to := range renames {
+		if value, ok := input[from]; ok {
+			request[to] = value
+		}
+	}
+}
+
+// firstImage returns the input image, which models name "image" or "img"
+func firstImage(input map[string]interface{}) interface{} {
+	if image, ok := input["image"]; ok {
+		return image
+	}
+	return input["img"]
+}
+
+// localSize returns the output size for generation input, converting an
+// aspect ratio to a size when the model takes one
+func localSize(input map[string]interface{}) (int, int) {
+	width, _ := input["width"].(int)
+	height, _ := input["height"].(int)
+	if width > 0 && height > 0 {
+		return width, height
+	}
+	if ratio, ok := input["aspect_ratio"].(string); ok {
+		if size, ok := localSizes[ratio]; ok {
+			return size[0], size[1]
+		}
+	}
+	return 1024, 1024
+}
+
+// ComfyUIClient runs text-to-image generation on a local ComfyUI server by
+// queueing a user-supplied workflow. The workflow is in ComfyUI's API format
+// ("Save (API Format)"), with "{{name}}" placeholders where request values go:
+// prompt, negative_prompt, seed, width, height, steps, cfg, and batch_size.
+type ComfyUIClient struct {
+	baseURL    string
+	workflow   map[string]interface{}
+	httpClient *http.Client
+}
+
+// LoadComfyUIWorkflow reads an API-format workflow template from a file
+func LoadComfyUIWorkflow(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ComfyUI workflow: %w", err)
+	}
+	var workflow map[string]interface{}
+	if err := json.Unmarshal(data, &workflow); err != nil {
+		return nil, fmt.Errorf("failed to parse ComfyUI workflow %s (export it with \"Save (API Format)\"): %w", path, err)
+	}
+	if !strings.Contains(string(data), "{{prompt}}") {
+		return nil, fmt.Errorf("ComfyUI workflow %s has no {{prompt}} placeholder", path)
+	}
+	return workflow, nil
+}
+
+// NewComfyUIClient creates a client for a ComfyUI server. An empty baseURL
+// uses the default.
+func NewComfyUIClient(baseURL string, workflow map[string]interface{}) *ComfyUIClient {
+	return NewComfyUIClientWithTransport(baseURL, workflow, nil)
+}
+
+// NewComfyUIClientWithTransport creates a ComfyUI client that sends requests
+// through a custom transport, such as a record/replay cassette
+func NewComfyUIClientWithTransport(baseURL string, workflow map[string]interface{}, transport http.RoundTripper) *ComfyUIClient {
+	if baseURL == "" {
+		baseURL = DefaultComfyUIURL
+	}
+	return &ComfyUIClient{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		workflow: workflow,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: transport,
+		},
+	}
+}
+
+// Name returns the provider name
+func (c *ComfyUIClient) Name() string {
+	return ProviderLocal
+}
+
+// Supports reports whether the workflow can stand in for a model. Only
+// text-to-image workflows are supported.
+func (c *ComfyUIClient) Supports(modelID string) bool {
+	return localSupports(modelID, models.CategoryGeneration)
+}
+
+// CreatePrediction queues the workflow with the input filled in
+func (c *ComfyUIClient) CreatePrediction(ctx context.Context, modelID string, input map[string]interface{}) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "comfyui.create_prediction", "comfyui.model", modelID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if !c.Supports(modelID) {
+		return nil, fmt.Errorf("model %s cannot run on the local ComfyUI server", modelID)
+	}
+
+	graph := fillWorkflow(c.workflow, comfyValues(input))
+	slog.Debug("creating comfyui prediction", "model", modelID, "url", c.baseURL)
+
+	body := newJSONBody(map[string]interface{}{"prompt": graph})
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/prompt", body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	respBody, status, err := c.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, newAPIError(status, respBody)
+	}
+
+	var queued struct {
+		PromptID   string                 `json:"prompt_id"`
+		NodeErrors map[string]interface{} `json:"node_errors"`
+	}
+	if err := json.Unmarshal(respBody, &queued); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(queued.NodeErrors) > 0 {
+		return nil, &APIError{StatusCode: status, Code: ErrCodeInvalidInput, Message: "ComfyUI rejected the workflow", Body: string(respBody)}
+	}
+
+	slog.Debug("comfyui prediction created", "prompt_id", queued.PromptID, "model", modelID)
+	span.SetAttributes("comfyui.prompt_id", queued.PromptID)
+	return &types.ReplicatePredictionResponse{
+		ID:     queued.PromptID,
+		Status: types.StatusStarting,
+		Input:  input,
+	}, nil
+}
+
+// GetPrediction checks a queued workflow through the server's history
+func (c *ComfyUIClient) GetPrediction(ctx context.Context, promptID string) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "comfyui.get_prediction", "comfyui.prompt_id", promptID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/history/"+url.PathEscape(promptID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	respBody, status, err := c.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, newAPIError(status, respBody)
+	}
+
+	var history map[string]struct {
+		Status struct {
+			StatusStr string          `json:"status_str"`
+			Completed bool            `json:"completed"`
+			Messages  [][]interface{} `json:"messages"`
+		} `json:"status"`
+		Outputs map[string]struct {
+			Images []struct {
+				Filename  string `json:"filename"`
+				Subfolder string `json:"subfolder"`
+				Type      string `json:"type"`
+			} `json:"images"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(respBody, &history); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	prediction := &types.ReplicatePredictionResponse{ID: promptID}
+	entry, ok := history[promptID]
+	switch {
+	case !ok:
+		// Workflows appear in the history only once they finish
+		prediction.Status = types.StatusProcessing
+	case entry.Status.StatusStr == "error":
+		prediction.Status = types.StatusFailed
+		prediction.Error = comfyError(entry.Status.Messages)
+	case !entry.Status.Completed:
+		prediction.Status = types.StatusProcessing
+	default:
+		nodes := make([]string, 0, len(entry.Outputs))
+		for node := range entry.Outputs {
+			nodes = append(nodes, node)
+		}
+		sort.Strings(nodes)
+
+		var outputs []interface{}
+		for _, node := range nodes {
+			for _, image := range entry.Outputs[node].Images {
+				// Preview nodes write temp images; only saved images are results
+				if image.Type != "output" {
+					continue
+				}
+				query := url.Values{"filename": {image.Filename}, "subfolder": {image.Subfolder}, "type": {image.Type}}
+				outputs = append(outputs, c.baseURL+"/view?"+query.Encode())
+			}
+		}
+		prediction.Status = types.StatusSucceeded
+		prediction.Output = outputs
+	}
+
+	span.SetAttributes("comfyui.status", prediction.Status)
+	return prediction, nil
+}
+
+// do sends a request and reads the response body
+func (c *ComfyUIClient) do(httpReq *http.Request) ([]byte, int, error) {
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to reach the local ComfyUI server at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+// comfyValues returns the placeholder values for a request. ComfyUI has no
+// random seed of its own, so one is picked when the input has none.
+func comfyValues(input map[string]interface{}) map[string]interface{} {
+	width, height := localSize(input)
+	values := map[string]interface{}{
+		"prompt":          input["prompt"],
+		"negative_prompt": "",
+		"seed":            randomSeed(),
+		"width":           width,
+		"height":          height,
+		"steps":           20,
+		"cfg":             7.0,
+		"batch_size":      1,
+	}
+	for from, to := range map[string]string{
+		"negative_prompt":     "negative_prompt",
+		"seed":                "seed",
+		"guidance_scale":      "cfg",
+		"cfg":                 "cfg",
+		"num_inference_steps": "steps",
+		"num_outputs":         "batch_size",
+	} {
+		if value, ok := input[from]; ok {
+			values[to] = value
+		}
+	}
+	return values
+}
+
+// fillWorkflow returns a copy of a workflow with its placeholders replaced. A
+// string that is exactly one placeholder takes the value's own type, so
+// numeric inputs stay numbers.
+func fillWorkflow(node interface{}, values map[string]interface{}) interface{} {
+	switch val := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			out[k] = fillWorkflow(v, values)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, v := range val {
+			out[i] = fillWorkflow(v, values)
+		}
+		return out
+	case string:
+		for name, value := range values {
+			placeholder := "{{" + name + "}}"
+			if val == placeholder {
+				return value
+			}
+			val = strings.ReplaceAll(val, placeholder, fmt.Sprintf("%v", value))
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+// comfyError extracts the exception message from a failed workflow's status
+// messages, which are [type, data] pairs
+func comfyError(messages [][]interface{}) string {
+	for _, message := range messages {
+		if len(message) != 2 || message[0] != "execution_error" {
+			continue
+		}
+		if data, ok := message[1].(map[string]interface{}); ok {
+			return fmt.Sprintf("%v: %v", data["node_type"], data["exception_message"])
+		}
+	}
+	return "workflow failed"
+}
+
+// randomSeed returns a random non-negative seed
+func randomSeed() int64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
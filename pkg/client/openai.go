@@ -0,0 +1,261 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+const (
+	openAIAPIURL = "https://api.openai.com/v1"
+)
+
+// openAIModels maps supported models to OpenAI model names
+var openAIModels = map[string]string{
+	models.ModelGPTImage1: "gpt-image-1",
+}
+
+// OpenAIClient runs image generation and edits on OpenAI's images API. Like
+// Stability's, the API is synchronous: CreatePrediction returns a completed
+// prediction with inline data URL outputs.
+type OpenAIClient struct {
+	apiKey     string
+	httpClient *http.Client
+	results    *inlineResults
+}
+
+// NewOpenAIClient creates a new OpenAI images client
+func NewOpenAIClient(apiKey string) *OpenAIClient {
+	return NewOpenAIClientWithTransport(apiKey, nil)
+}
+
+// NewOpenAIClientWithTransport creates an OpenAI images client that sends
+// requests through a custom transport, such as a record/replay cassette
+func NewOpenAIClientWithTransport(apiKey string, transport http.RoundTripper) *OpenAIClient {
+	return &OpenAIClient{
+		apiKey: apiKey,
+		httpClient: &http.Client{
+			// High quality images can take well over a minute
+			Timeout:   3 * time.Minute,
+			Transport: transport,
+		},
+		results: newInlineResults(),
+	}
+}
+
+// Name returns the provider name
+func (c *OpenAIClient) Name() string {
+	return ProviderOpenAI
+}
+
+// Supports reports whether OpenAI serves a model
+func (c *OpenAIClient) Supports(modelID string) bool {
+	_, ok := openAIModels[modelID]
+	return ok
+}
+
+// CreatePrediction generates or edits an image and waits for its output.
+// Input with an image is sent to the edits endpoint.
+func (c *OpenAIClient) CreatePrediction(ctx context.Context, modelID string, input map[string]interface{}) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "openai.create_prediction", "openai.model", modelID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	model, ok := openAIModels[modelID]
+	if !ok {
+		return nil, fmt.Errorf("model %s is not available on the OpenAI API", modelID)
+	}
+
+	var (
+		url         string
+		body        io.ReadCloser
+		contentType string
+	)
+	if _, isEdit := input["image"]; isEdit {
+		form, err := openAIEditForm(model, input)
+		if err != nil {
+			return nil, err
+		}
+		url = openAIAPIURL + "/images/edits"
+		slog.Debug("creating openai edit", "model", modelID, "fields", form.fields)
+		body, contentType = newMultipartBody(form)
+	} else {
+		request := openAIGenerationRequest(model, input)
+		url = openAIAPIURL + "/images/generations"
+		slog.Debug("creating openai generation", "model", modelID, "request", request)
+		body, contentType = newJSONBody(request), "application/json"
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set("Content-Type", contentType)
+
+	startTime := time.Now()
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	prediction := &types.ReplicatePredictionResponse{
+		ID:      newInlineID(),
+		Input:   input,
+		Metrics: &types.PredictionMetrics{PredictTime: time.Since(startTime).Seconds()},
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError(resp.StatusCode, respBody)
+		// Moderation rejects the request itself; report it like a filtered prediction
+		if !strings.Contains(apiErr.Body, "moderation_blocked") {
+			return nil, apiErr
+		}
+		prediction.Status = types.StatusFailed
+		prediction.Error = "request was blocked by OpenAI's safety system: " + apiErr.Message
+	} else {
+		var result struct {
+			Data []struct {
+				B64JSON string `json:"b64_json"`
+			} `json:"data"`
+			OutputFormat string `json:"output_format"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+
+		mimeType := "image/png"
+		if result.OutputFormat != "" {
+			mimeType = "image/" + result.OutputFormat
+		}
+		var outputs []interface{}
+		for _, item := range result.Data {
+			outputs = append(outputs, "data:"+mimeType+";base64,"+item.B64JSON)
+		}
+		prediction.Status = types.StatusSucceeded
+		prediction.Output = outputs
+	}
+
+	c.results.store(prediction)
+
+	slog.Debug("openai prediction completed", "prediction_id", prediction.ID, "model", modelID, "status", prediction.Status)
+	span.SetAttributes("openai.prediction_id", prediction.ID, "openai.status", prediction.Status)
+	return prediction, nil
+}
+
+// GetPrediction returns a completed prediction
+func (c *OpenAIClient) GetPrediction(ctx context.Context, predictionID string) (*types.ReplicatePredictionResponse, error) {
+	return c.results.take(predictionID)
+}
+
+// openAIGenerationRequest translates text-to-image input to the generations schema
+func openAIGenerationRequest(model string, input map[string]interface{}) map[string]interface{} {
+	request := map[string]interface{}{
+		"model":  model,
+		"prompt": input["prompt"],
+		"size":   openAISize(input),
+	}
+	if n, ok := input["num_outputs"]; ok {
+		request["n"] = n
+	}
+	if format, ok := input["output_format"]; ok {
+		request["output_format"] = imageOutputFormat(format)
+	}
+	for _, key := range []string{"quality", "background"} {
+		if value, ok := input[key]; ok {
+			request[key] = value
+		}
+	}
+	return request
+}
+
+// openAIEditForm translates image-to-image input to the edits schema
+func openAIEditForm(model string, input map[string]interface{}) (multipartForm, error) {
+	form := multipartForm{
+		fields: map[string]string{"model": model, "size": openAISize(input)},
+		files:  map[string]*types.FileData{},
+	}
+	copyFields(form.fields, input, "prompt", "quality")
+	if n, ok := input["num_outputs"]; ok {
+		form.fields["n"] = fmt.Sprintf("%v", n)
+	}
+
+	if image, ok := input["image"].(*types.FileData); ok {
+		form.files["image"] = image
+	}
+	if mask, ok := input["mask"].(*types.FileData); ok {
+		alphaMask, err := openAIMask(mask)
+		if err != nil {
+			return form, err
+		}
+		form.files["mask"] = alphaMask
+	}
+	return form, nil
+}
+
+// openAIMask converts a black-and-white mask (white marks the area to repaint)
+// to OpenAI's convention, where fully transparent pixels mark the area to repaint
+func openAIMask(mask *types.FileData) (*types.FileData, error) {
+	src, err := mask.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode mask: %w", err)
+	}
+
+	bounds := img.Bounds()
+	out := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gray := color.GrayModel.Convert(img.At(x, y)).(color.Gray)
+			out.SetNRGBA(x, y, color.NRGBA{A: 255 - gray.Y})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return nil, fmt.Errorf("failed to encode mask: %w", err)
+	}
+	return &types.FileData{MimeType: "image/png", Data: buf.Bytes(), Size: int64(buf.Len())}, nil
+}
+
+// openAISize picks the supported size closest to the requested aspect ratio
+func openAISize(input map[string]interface{}) string {
+	switch input["aspect_ratio"] {
+	case "16:9", "4:3", "3:2":
+		return "1536x1024"
+	case "9:16", "3:4", "2:3":
+		return "1024x1536"
+	case "1:1":
+		return "1024x1024"
+	default:
+		return "auto"
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// OutputRefresher returns a function that re-fetches a prediction and returns
+// its current output URLs, for retrying downloads after delivery URLs expire
+func OutputRefresher(ctx context.Context, p Predictor, predictionID string) func() ([]string, error) {
+	return func() ([]string, error) {
+		result, err := p.GetPrediction(ctx, predictionID)
+		if err != nil {
+			return nil, err
+		}
+		return OutputURLs(result.Output), nil
+	}
+}
+
+// OutputURLs returns every file URL in a prediction's output. Models return a
+// single URL, a list of URLs, or an object whose values are URLs (for example an
+// image plus a mask); all of these are flattened in a stable order.
+func OutputURLs(output interface{}) []string {
+	var urls []string
+	collectOutputURLs(output, &urls)
+	return urls
+}
+
+// collectOutputURLs appends the URLs found in an output value
+func collectOutputURLs(output interface{}, urls *[]string) {
+	switch v := output.(type) {
+	case string:
+		if isOutputURL(v) {
+			*urls = append(*urls, v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectOutputURLs(item, urls)
+		}
+	case map[string]interface{}:
+		// Common primary keys first, then the rest alphabetically
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			pi, pj := primaryKeyRank(keys[i]), primaryKeyRank(keys[j])
+			if pi != pj {
+				return pi < pj
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys {
+			collectOutputURLs(v[key], urls)
+		}
+	}
+}
+
+// primaryKeyRank orders the keys models typically use for their main output
+func primaryKeyRank(key string) int {
+	for i, k := range []string{"image", "output", "url", "file"} {
+		if key == k {
+			return i
+		}
+	}
+	return 4
+}
+
+// isOutputURL reports whether a string looks like a downloadable output
+func isOutputURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "data:")
+}
@@ -0,0 +1,409 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// Provider names
+const (
+	ProviderReplicate = "replicate"
+	ProviderFal       = "fal"
+	ProviderStability = models.ProviderStability
+	ProviderOpenAI    = models.ProviderOpenAI
+	ProviderLocal     = models.ProviderLocal
+)
+
+// Predictor creates and polls predictions. Every provider maps its results onto
+// Replicate's prediction shape so operations can treat them alike.
+type Predictor interface {
+	CreatePrediction(ctx context.Context, modelID string, input map[string]interface{}) (*types.ReplicatePredictionResponse, error)
+	GetPrediction(ctx context.Context, predictionID string) (*types.ReplicatePredictionResponse, error)
+}
+
+// Provider is a hosted inference service that can serve some of the registered
+// models. Model IDs and inputs are always given in Replicate's form; providers
+// translate them to their own endpoints.
+type Provider interface {
+	Predictor
+	Name() string
+	Supports(modelID string) bool
+}
+
+// Name returns the provider name
+func (c *ReplicateClient) Name() string {
+	return ProviderReplicate
+}
+
+// Supports reports whether Replicate can serve a model. Custom models are
+// assumed to be on Replicate; only models exclusive to another provider are not.
+func (c *ReplicateClient) Supports(modelID string) bool {
+	return models.GetModelInfo(modelID).Provider == ""
+}
+
+// Router sends each prediction to the provider selected for its model. Models
+// without a selection, or whose selected provider cannot serve them, go to the
+// fallback provider, or to another registered provider when the fallback cannot
+// serve them either. Predictions from other providers get IDs prefixed with the
+// provider name ("fal:<id>") so status checks find their way back.
+type Router struct {
+	fallback        Provider
+	providers       map[string]Provider
+	defaultProvider string            // Preferred provider for every model it supports
+	routes          map[string]string // Per-model provider selection
+	limiter         *Limiter          // Nil when predictions are not limited
+
+	mu          sync.Mutex
+	lastUsed    map[string]time.Time    // When each model last got a prediction
+	substitutes map[string]Substitution // Models found missing, to their replacements
+}
+
+// NewRouter creates a router that sends everything to fallback until other
+// providers are registered and selected
+func NewRouter(fallback Provider) *Router {
+	return &Router{
+		fallback:  fallback,
+		providers: map[string]Provider{fallback.Name(): fallback},
+		routes:    map[string]string{},
+		lastUsed:  map[string]time.Time{},
+
+		substitutes: map[string]Substitution{},
+	}
+}
+
+// Register adds a provider that models can be routed to
+func (r *Router) Register(p Provider) {
+	r.providers[p.Name()] = p
+}
+
+// HasProvider reports whether a provider with the given name is registered
+func (r *Router) HasProvider(name string) bool {
+	_, ok := r.providers[name]
+	return ok
+}
+
+// SetDefault selects a provider for every model it supports
+func (r *Router) SetDefault(name string) {
+	r.defaultProvider = name
+}
+
+// Route selects a provider for one model, overriding the default
+func (r *Router) Route(modelID, name string) {
+	r.routes[modelID] = name
+}
+
+// SetConcurrency limits the predictions in flight across all providers; zero
+// or less removes the limit
+func (r *Router) SetConcurrency(limit int) {
+	r.limiter = nil
+	if limit > 0 {
+		r.limiter = NewLimiter(limit)
+	}
+}
+
+// ProviderFor returns the provider that will serve a model, or nil if no
+// registered provider can
+func (r *Router) ProviderFor(modelID string) Provider {
+	name, ok := r.routes[modelID]
+	if !ok {
+		name = r.defaultProvider
+	}
+	if p, ok := r.providers[name]; ok && p.Supports(modelID) {
+		return p
+	}
+	if r.fallback.Supports(modelID) {
+		if name != "" && name != r.fallback.Name() {
+			slog.Debug("provider cannot serve model, using fallback", "provider", name, "model", modelID, "fallback", r.fallback.Name())
+		}
+		return r.fallback
+	}
+
+	// Models exclusive to one provider go there whatever the selection
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if r.providers[name].Supports(modelID) {
+			return r.providers[name]
+		}
+	}
+	return nil
+}
+
+// CreatePrediction starts a prediction on the provider selected for the model,
+// first waiting for a slot when predictions are limited. During a dry run it
+// records the prediction and returns ErrDryRun instead. Deprecated models, and
+// models the provider reports missing, are replaced by their successor when
+// one is known; the prediction log in ctx records the substitution. A
+// matching prediction resumed from an earlier run (see
+// WithResumedPredictions) is returned instead of starting a new one.
+func (r *Router) CreatePrediction(ctx context.Context, modelID string, input map[string]interface{}) (*types.ReplicatePredictionResponse, error) {
+	key := InputKey(modelID, input)
+	if prediction, logged := r.resume(ctx, key); prediction != nil {
+		recordCreated(ctx, logged)
+		return prediction, nil
+	}
+
+	sub, substituted := r.Substitute(modelID)
+	used := modelID
+	if substituted {
+		used = sub.Used
+	}
+	p, prediction, err := r.create(ctx, used, input, sub)
+	if err != nil && !substituted && versionMissing(err) {
+		if sub, substituted = r.replace(ctx, p, modelID); substituted {
+			used = sub.Used
+			p, prediction, err = r.create(ctx, used, input, sub)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prediction = r.tag(p, prediction)
+	logged := LoggedPrediction{ID: prediction.ID, Model: used, InputKey: key}
+	if substituted {
+		logged.Substitution = &sub
+	}
+	recordCreated(ctx, logged)
+	r.mu.Lock()
+	r.lastUsed[used] = time.Now()
+	r.mu.Unlock()
+	if r.limiter != nil {
+		if finished(prediction.Status) {
+			r.limiter.release()
+		} else {
+			r.limiter.hold(prediction.ID)
+		}
+	}
+	return prediction, nil
+}
+
+// create starts a prediction of a model on the provider serving it, returning
+// the provider. sub is the substitution that selected the model, if any, for
+// the warning of a dry run.
+func (r *Router) create(ctx context.Context, modelID string, input map[string]interface{}, sub Substitution) (Provider, *types.ReplicatePredictionResponse, error) {
+	var warning string
+	if sub.Used != "" {
+		warning = sub.Warning()
+	}
+	p := r.ProviderFor(modelID)
+	if p == nil {
+		info := models.GetModelInfo(modelID)
+		message := fmt.Sprintf("%s is only available through the %s provider, which is not configured", info.Name, info.Provider)
+		if recordDryRun(ctx, PlannedPrediction{Model: modelID, Input: input, Warning: strings.TrimSpace(warning + " " + message)}) {
+			return nil, nil, ErrDryRun
+		}
+		return nil, nil, &APIError{
+			Code:    ErrCodeProviderUnavailable,
+			Message: message,
+		}
+	}
+	if recordDryRun(ctx, PlannedPrediction{Model: modelID, Provider: p.Name(), Input: input, Warning: warning}) {
+		return p, nil, ErrDryRun
+	}
+	if r.limiter != nil {
+		if err := r.limiter.acquire(ctx, PriorityFrom(ctx)); err != nil {
+			return p, nil, err
+		}
+	}
+	prediction, err := p.CreatePrediction(ctx, modelID, input)
+	if err != nil {
+		if r.limiter != nil {
+			r.limiter.release()
+		}
+		return p, nil, err
+	}
+	return p, prediction, nil
+}
+
+// LastUsed returns when a prediction was last created for a model, or the
+// zero time if none has been since startup
+func (r *Router) LastUsed(modelID string) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastUsed[modelID]
+}
+
+// GetPrediction polls a prediction on the provider that created it
+func (r *Router) GetPrediction(ctx context.Context, predictionID string) (*types.ReplicatePredictionResponse, error) {
+	p, id := r.fallback, predictionID
+	if name, rest, ok := strings.Cut(predictionID, ":"); ok {
+		if provider, known := r.providers[name]; known {
+			p, id = provider, rest
+		}
+	}
+
+	prediction, err := p.GetPrediction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.limiter != nil && finished(prediction.Status) {
+		r.limiter.done(predictionID)
+	}
+	return r.tag(p, prediction), nil
+}
+
+// finished reports whether a prediction status is final
+func finished(status string) bool {
+	return status == types.StatusSucceeded || status == types.StatusFailed || status == types.StatusCanceled
+}
+
+// tag records which provider served a prediction, namespacing its ID
+func (r *Router) tag(p Provider, prediction *types.ReplicatePredictionResponse) *types.ReplicatePredictionResponse {
+	prediction.Provider = p.Name()
+	if p != r.fallback {
+		prediction.ID = p.Name() + ":" + prediction.ID
+	}
+	return prediction
+}
+
+// newJSONBody returns a request body that streams v as JSON, base64-encoding
+// inline files as it goes instead of marshalling the whole request into memory.
+// The HTTP transport closes the body if a request fails before consuming it,
+// which stops the encoder.
+func newJSONBody(v interface{}) io.ReadCloser {
+	body, w := io.Pipe()
+	go func() {
+		w.CloseWithError(types.EncodeJSON(w, v))
+	}()
+	return body
+}
+
+// multipartForm is a multipart/form-data request body
+type multipartForm struct {
+	fields map[string]string
+	files  map[string]*types.FileData
+}
+
+// newMultipartBody streams a form as multipart/form-data, returning the body
+// and its content type
+func newMultipartBody(form multipartForm) (io.ReadCloser, string) {
+	body, w := io.Pipe()
+	mw := multipart.NewWriter(w)
+
+	go func() {
+		w.CloseWithError(writeMultipart(mw, form))
+	}()
+	return body, mw.FormDataContentType()
+}
+
+// writeMultipart writes every field and file of a form in a stable order
+func writeMultipart(mw *multipart.Writer, form multipartForm) error {
+	keys := make([]string, 0, len(form.fields))
+	for key := range form.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := mw.WriteField(key, form.fields[key]); err != nil {
+			return err
+		}
+	}
+
+	keys = keys[:0]
+	for key := range form.files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		file := form.files[key]
+		// Upload with the real content type; some APIs reject application/octet-stream
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s%s"`, key, key, uploadExtension(file.MimeType)))
+		header.Set("Content-Type", file.MimeType)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		src, err := file.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// inlineResults holds predictions from synchronous APIs, which complete
+// within the create request, until they are polled. Results are released
+// once fetched; their inline outputs never expire, so they are not needed again.
+type inlineResults struct {
+	mu      sync.Mutex
+	results map[string]*types.ReplicatePredictionResponse
+}
+
+// newInlineID returns a random ID for a prediction completed within its request
+func newInlineID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newInlineResults() *inlineResults {
+	return &inlineResults{results: map[string]*types.ReplicatePredictionResponse{}}
+}
+
+// store keeps a completed prediction until it is polled
+func (r *inlineResults) store(prediction *types.ReplicatePredictionResponse) {
+	r.mu.Lock()
+	r.results[prediction.ID] = prediction
+	r.mu.Unlock()
+}
+
+// take returns and releases a completed prediction
+func (r *inlineResults) take(predictionID string) (*types.ReplicatePredictionResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prediction, ok := r.results[predictionID]
+	if !ok {
+		return nil, fmt.Errorf("unknown prediction %s (results are kept in memory only and returned once)", predictionID)
+	}
+	delete(r.results, predictionID)
+	return prediction, nil
+}
+
+// uploadExtension returns a filename extension for an upload's MIME type
+func uploadExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
+
+// imageOutputFormat maps an output_format input to the png, jpeg, or webp
+// names synchronous APIs use
+func imageOutputFormat(format interface{}) string {
+	switch format {
+	case "jpg", "jpeg":
+		return "jpeg"
+	case "webp":
+		return "webp"
+	default:
+		return "png"
+	}
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDeleteUnsupported is returned when the provider of a prediction offers
+// no way to delete it
+var ErrDeleteUnsupported = errors.New("provider does not support deleting predictions")
+
+// Deleter is implemented by providers that can delete a finished prediction
+// and the data they keep for it
+type Deleter interface {
+	DeletePrediction(ctx context.Context, predictionID string) error
+}
+
+// PredictionLog collects the predictions created in a context
+type PredictionLog struct {
+	mu          sync.Mutex
+	predictions []LoggedPrediction
+	parent      *PredictionLog // Log of the enclosing context, which records them too
+	onCreate    func()         // Called after each prediction is recorded
+}
+
+// LoggedPrediction is a prediction recorded in a PredictionLog
+type LoggedPrediction struct {
+	ID           string
+	Model        string
+	CreatedAt    time.Time
+	Substitution *Substitution // Set when Model replaced the model asked for
+	InputKey     string        // Hash of the model asked for and its input, see InputKey
+}
+
+type predictionLogKey struct{}
+
+// WithPredictionLog returns a context in which the Router records the ID of
+// every prediction it creates in the returned PredictionLog, as well as in
+// any log ctx already carries
+func WithPredictionLog(ctx context.Context) (context.Context, *PredictionLog) {
+	parent, _ := ctx.Value(predictionLogKey{}).(*PredictionLog)
+	l := &PredictionLog{parent: parent}
+	return context.WithValue(ctx, predictionLogKey{}, l), l
+}
+
+// IDs returns the recorded prediction IDs in the order they were created
+func (l *PredictionLog) IDs() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ids := make([]string, len(l.predictions))
+	for i, prediction := range l.predictions {
+		ids[i] = prediction.ID
+	}
+	return ids
+}
+
+// Predictions returns the recorded predictions in the order they were created
+func (l *PredictionLog) Predictions() []LoggedPrediction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LoggedPrediction(nil), l.predictions...)
+}
+
+// OnCreate sets a function called after each prediction the log records
+func (l *PredictionLog) OnCreate(fn func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onCreate = fn
+}
+
+// Last returns the most recently created prediction, reporting false when
+// none was created yet
+func (l *PredictionLog) Last() (LoggedPrediction, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.predictions) == 0 {
+		return LoggedPrediction{}, false
+	}
+	return l.predictions[len(l.predictions)-1], true
+}
+
+// Substitutions returns the model substitutions of the recorded predictions,
+// each once, in the order they were first made
+func (l *PredictionLog) Substitutions() []Substitution {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var subs []Substitution
+	seen := make(map[Substitution]bool)
+	for _, prediction := range l.predictions {
+		if sub := prediction.Substitution; sub != nil && !seen[*sub] {
+			seen[*sub] = true
+			subs = append(subs, *sub)
+		}
+	}
+	return subs
+}
+
+// recordCreated adds a prediction to the log in ctx and the logs enclosing
+// it, if there are any
+func recordCreated(ctx context.Context, prediction LoggedPrediction) {
+	prediction.CreatedAt = time.Now()
+	l, _ := ctx.Value(predictionLogKey{}).(*PredictionLog)
+	for ; l != nil; l = l.parent {
+		l.mu.Lock()
+		l.predictions = append(l.predictions, prediction)
+		onCreate := l.onCreate
+		l.mu.Unlock()
+		if onCreate != nil {
+			onCreate()
+		}
+	}
+}
+
+// DeletePrediction deletes a prediction on the provider that created it, or
+// returns ErrDeleteUnsupported when that provider cannot
+func (r *Router) DeletePrediction(ctx context.Context, predictionID string) error {
+	var p Provider = r.fallback
+	id := predictionID
+	if name, rest, ok := strings.Cut(predictionID, ":"); ok {
+		if provider, known := r.providers[name]; known {
+			p, id = provider, rest
+		}
+	}
+	deleter, ok := p.(Deleter)
+	if !ok {
+		return ErrDeleteUnsupported
+	}
+	return deleter.DeletePrediction(ctx, id)
+}
@@ -1,16 +1,17 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
 	"github.com/gomcpgo/replicate_image_ai/pkg/types"
 )
 
@@ -22,60 +23,69 @@ const (
 type ReplicateClient struct {
 	apiToken   string
 	httpClient *http.Client
+	uploads    *uploadCache // Nil unless inputs are sent through the files API
 }
 
 // NewReplicateClient creates a new Replicate API client
 func NewReplicateClient(apiToken string) *ReplicateClient {
+	return NewReplicateClientWithTransport(apiToken, nil)
+}
+
+// NewReplicateClientWithTransport creates a client that sends requests through
+// a custom transport, such as a record/replay cassette
+func NewReplicateClientWithTransport(apiToken string, transport http.RoundTripper) *ReplicateClient {
 	return &ReplicateClient{
 		apiToken: apiToken,
 		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
+			Timeout:   60 * time.Second,
+			Transport: transport,
 		},
 	}
 }
 
 // CreatePrediction creates a new prediction on Replicate
-func (c *ReplicateClient) CreatePrediction(ctx context.Context, modelVersion string, input map[string]interface{}) (*types.ReplicatePredictionResponse, error) {
+func (c *ReplicateClient) CreatePrediction(ctx context.Context, modelVersion string, input map[string]interface{}) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "replicate.create_prediction", "replicate.model", modelVersion)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	
 	// Use deployment endpoint for models without version hash
 	var url string
-	var body []byte
-	var err error
+	var reqBody map[string]interface{}
 	
 	// Check if modelVersion contains a version hash (has colon)
 	if strings.Contains(modelVersion, ":") {
 		// Use version endpoint for specific versions
-		req := types.ReplicatePredictionRequest{
-			Version: modelVersion,
-			Input:   input,
+		reqBody = map[string]interface{}{
+			"version": modelVersion,
+			"input":   input,
 		}
-		body, err = json.Marshal(req)
 		url = fmt.Sprintf("%s/predictions", replicateAPIURL)
 	} else {
 		// Use deployment endpoint for latest version
-		reqBody := map[string]interface{}{
+		reqBody = map[string]interface{}{
 			"input": input,
 		}
-		body, err = json.Marshal(reqBody)
 		url = fmt.Sprintf("%s/models/%s/predictions", replicateAPIURL, modelVersion)
 	}
 	
+	// Data URLs in the input are redacted by the log handler
+	slog.Debug("creating prediction", "model", modelVersion, "url", url, "input", input)
+
+	// Send large files by URL rather than inline
+	uploaded, err := c.uploadInputs(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-	
-	// Log the request details (with masked token for security)
-	log.Printf("DEBUG: Creating prediction")
-	log.Printf("  URL: %s", url)
-	log.Printf("  Model: %s", modelVersion)
-	log.Printf("  Request Body: %s", string(body))
-	if len(c.apiToken) > 10 {
-		log.Printf("  Auth Token: %.10s... (length: %d)", c.apiToken, len(c.apiToken))
-	} else {
-		log.Printf("  Auth Token: [TOO SHORT - %d chars]", len(c.apiToken))
+		return nil, err
 	}
+	reqBody["input"] = uploaded
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	// Stream the body so inline files are base64-encoded straight into the request
+	body := newJSONBody(reqBody)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
+		body.Close()
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
@@ -93,24 +103,12 @@ func (c *ReplicateClient) CreatePrediction(ctx context.Context, modelVersion str
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// Log the response
-	log.Printf("DEBUG: Response Status: %d", resp.StatusCode)
-	log.Printf("DEBUG: Response Body: %s", string(respBody))
-
-	// Handle specific error codes
-	if resp.StatusCode == http.StatusPaymentRequired {
-		// 402 - Payment Required
-		var errorResp map[string]interface{}
-		if err := json.Unmarshal(respBody, &errorResp); err == nil {
-			if detail, ok := errorResp["detail"].(string); ok {
-				return nil, fmt.Errorf("billing issue: %s", detail)
-			}
-		}
-		return nil, fmt.Errorf("billing issue (status 402): %s", string(respBody))
-	}
+	// The body echoes the input, prompt included, so only its status is logged
+	slog.Debug("prediction response", "model", modelVersion, "status", resp.StatusCode)
 
+	// Map error statuses (billing, missing version, invalid input, ...) to typed errors
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	var prediction types.ReplicatePredictionResponse
@@ -118,11 +116,19 @@ func (c *ReplicateClient) CreatePrediction(ctx context.Context, modelVersion str
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
+	slog.Debug("prediction created", "prediction_id", prediction.ID, "model", modelVersion, "status", prediction.Status)
+	span.SetAttributes("replicate.prediction_id", prediction.ID, "replicate.status", prediction.Status)
 	return &prediction, nil
 }
 
 // GetPrediction gets the status of a prediction
-func (c *ReplicateClient) GetPrediction(ctx context.Context, predictionID string) (*types.ReplicatePredictionResponse, error) {
+func (c *ReplicateClient) GetPrediction(ctx context.Context, predictionID string) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "replicate.get_prediction", "replicate.prediction_id", predictionID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/predictions/%s", replicateAPIURL, predictionID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -142,7 +148,7 @@ func (c *ReplicateClient) GetPrediction(ctx context.Context, predictionID string
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	var prediction types.ReplicatePredictionResponse
@@ -150,9 +156,119 @@ func (c *ReplicateClient) GetPrediction(ctx context.Context, predictionID string
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
+	span.SetAttributes("replicate.status", prediction.Status)
 	return &prediction, nil
 }
 
+// DeletePrediction deletes a finished prediction's input, output, and logs
+// from Replicate
+func (c *ReplicateClient) DeletePrediction(ctx context.Context, predictionID string) (err error) {
+	ctx, span := tracing.Start(ctx, "replicate.delete_prediction", "replicate.prediction_id", predictionID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", fmt.Sprintf("%s/predictions/%s", replicateAPIURL, predictionID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp.StatusCode, body)
+	}
+
+	slog.Debug("prediction deleted", "prediction_id", predictionID)
+	return nil
+}
+
+// Account is the Replicate account an API token belongs to
+type Account struct {
+	Type     string `json:"type"` // user or organization
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+// GetAccount returns the account the API token belongs to. It is the cheapest
+// authenticated request, so it doubles as a token check.
+func (c *ReplicateClient) GetAccount(ctx context.Context) (*Account, error) {
+	var account Account
+	if err := c.get(ctx, replicateAPIURL+"/account", &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ModelVersion is one published version of a model
+type ModelVersion struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ModelDetails describes a model published on Replicate
+type ModelDetails struct {
+	Owner         string        `json:"owner"`
+	Name          string        `json:"name"`
+	Visibility    string        `json:"visibility"`
+	RunCount      int           `json:"run_count"`
+	LatestVersion *ModelVersion `json:"latest_version"`
+}
+
+// GetModel looks up a model by ID. For IDs pinned to a version it also looks
+// up that version, which is nil for unversioned IDs. A missing model or
+// version is an APIError with ErrCodeVersionNotFound.
+func (c *ReplicateClient) GetModel(ctx context.Context, modelID string) (*ModelDetails, *ModelVersion, error) {
+	name, version, _ := strings.Cut(modelID, ":")
+	var model ModelDetails
+	if err := c.get(ctx, fmt.Sprintf("%s/models/%s", replicateAPIURL, name), &model); err != nil {
+		return nil, nil, err
+	}
+	if version == "" {
+		return &model, nil, nil
+	}
+	var pinned ModelVersion
+	if err := c.get(ctx, fmt.Sprintf("%s/models/%s/versions/%s", replicateAPIURL, name, version), &pinned); err != nil {
+		return &model, nil, err
+	}
+	return &model, &pinned, nil
+}
+
+// get sends an authenticated GET request and decodes the JSON response into v
+func (c *ReplicateClient) get(ctx context.Context, url string, v interface{}) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp.StatusCode, respBody)
+	}
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
 // WaitForCompletion waits for a prediction to complete or timeout
 func (c *ReplicateClient) WaitForCompletion(ctx context.Context, predictionID string, timeout time.Duration) (*types.ReplicatePredictionResponse, error) {
 	deadline := time.Now().Add(timeout)
@@ -179,17 +295,7 @@ func (c *ReplicateClient) WaitForCompletion(ctx context.Context, predictionID st
 			case types.StatusSucceeded:
 				return prediction, nil
 			case types.StatusFailed:
-				errMsg := "prediction failed"
-				if prediction.Error != nil {
-					if errStr, ok := prediction.Error.(string); ok {
-						errMsg = errStr
-					} else if errMap, ok := prediction.Error.(map[string]interface{}); ok {
-						if msg, exists := errMap["message"]; exists {
-							errMsg = fmt.Sprintf("%v", msg)
-						}
-					}
-				}
-				return prediction, fmt.Errorf(errMsg)
+				return prediction, errors.New(FailureMessage(prediction.Error))
 			case types.StatusCanceled:
 				return prediction, fmt.Errorf("prediction was canceled")
 			}
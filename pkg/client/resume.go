@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// resumeSet holds predictions of an earlier run of a tool call, which the
+// Router hands back instead of creating identical ones again
+type resumeSet struct {
+	mu          sync.Mutex
+	predictions []LoggedPrediction // Not yet resumed
+}
+
+type resumeKey struct{}
+
+// WithResumedPredictions returns a context in which the Router resumes the
+// given predictions of an earlier run: a prediction whose model and input
+// match one of them, by InputKey, continues it instead of starting a new
+// one. Predictions that failed, were canceled, or cannot be fetched any more
+// are started again.
+func WithResumedPredictions(ctx context.Context, predictions []LoggedPrediction) context.Context {
+	set := &resumeSet{}
+	for _, prediction := range predictions {
+		if prediction.InputKey != "" {
+			set.predictions = append(set.predictions, prediction)
+		}
+	}
+	return context.WithValue(ctx, resumeKey{}, set)
+}
+
+// InputKey returns a hash of a model and its exact input, with inline files
+// hashed by their contents, which tells whether two predictions are the same
+func InputKey(modelID string, input map[string]interface{}) string {
+	hasher := sha256.New()
+	if err := types.EncodeJSON(hasher, map[string]interface{}{"model": modelID, "input": input}); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// resume returns the prediction of the earlier run matching key, fetched
+// afresh, or nil when there is none to continue
+func (r *Router) resume(ctx context.Context, key string) (*types.ReplicatePredictionResponse, LoggedPrediction) {
+	set, _ := ctx.Value(resumeKey{}).(*resumeSet)
+	if set == nil || key == "" {
+		return nil, LoggedPrediction{}
+	}
+	set.mu.Lock()
+	var logged LoggedPrediction
+	for i, prediction := range set.predictions {
+		if prediction.InputKey == key {
+			logged = prediction
+			set.predictions = append(set.predictions[:i], set.predictions[i+1:]...)
+			break
+		}
+	}
+	set.mu.Unlock()
+	if logged.ID == "" {
+		return nil, LoggedPrediction{}
+	}
+
+	prediction, err := r.GetPrediction(ctx, logged.ID)
+	if err != nil {
+		slog.Warn("cannot resume prediction; starting a new one", "prediction_id", logged.ID, "error", err)
+		return nil, LoggedPrediction{}
+	}
+	if prediction.Status == types.StatusFailed || prediction.Status == types.StatusCanceled {
+		slog.Info("resumed prediction did not succeed; starting a new one", "prediction_id", logged.ID, "status", prediction.Status)
+		return nil, LoggedPrediction{}
+	}
+	slog.Info("resuming prediction", "prediction_id", logged.ID, "model", logged.Model, "status", prediction.Status)
+	return prediction, logged
+}
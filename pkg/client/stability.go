@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+const (
+	stabilityAPIURL = "https://api.stability.ai/v2beta"
+)
+
+// stabilityModel maps a model to a Stability API endpoint and translates
+// Replicate-style input into the endpoint's form fields
+type stabilityModel struct {
+	endpoint string
+	form     func(map[string]interface{}) multipartForm
+}
+
+// stabilityModels lists the models the Stability API serves
+var stabilityModels = map[string]stabilityModel{
+	models.ModelSD35Large:                    {"stable-image/generate/sd3", sd3Form("sd3.5-large")},
+	models.ModelSD35LargeTurbo:               {"stable-image/generate/sd3", sd3Form("sd3.5-large-turbo")},
+	models.ModelSD35Medium:                   {"stable-image/generate/sd3", sd3Form("sd3.5-medium")},
+	models.ModelStableImageUltra:             {"stable-image/generate/ultra", stabilityGenerationForm},
+	models.ModelStabilityUpscaleFast:         {"stable-image/upscale/fast", stabilityImageForm},
+	models.ModelStabilityUpscaleConservative: {"stable-image/upscale/conservative", stabilityConservativeForm},
+	models.ModelStabilityInpaint:             {"stable-image/edit/inpaint", stabilityImageForm},
+}
+
+// StabilityClient runs predictions on Stability AI's hosted API. The API is
+// synchronous, so CreatePrediction returns a completed prediction whose output
+// is an inline data URL; GetPrediction hands it back once.
+type StabilityClient struct {
+	apiKey     string
+	httpClient *http.Client
+	results    *inlineResults
+}
+
+// NewStabilityClient creates a new Stability API client
+func NewStabilityClient(apiKey string) *StabilityClient {
+	return NewStabilityClientWithTransport(apiKey, nil)
+}
+
+// NewStabilityClientWithTransport creates a Stability API client that sends
+// requests through a custom transport, such as a record/replay cassette
+func NewStabilityClientWithTransport(apiKey string, transport http.RoundTripper) *StabilityClient {
+	return &StabilityClient{
+		apiKey: apiKey,
+		httpClient: &http.Client{
+			// Generation runs inside the request, so allow longer than Replicate's create call
+			Timeout:   3 * time.Minute,
+			Transport: transport,
+		},
+		results: newInlineResults(),
+	}
+}
+
+// Name returns the provider name
+func (c *StabilityClient) Name() string {
+	return ProviderStability
+}
+
+// Supports reports whether the Stability API serves a model
+func (c *StabilityClient) Supports(modelID string) bool {
+	_, ok := stabilityModels[modelID]
+	return ok
+}
+
+// CreatePrediction runs a model on the Stability API and waits for its output
+func (c *StabilityClient) CreatePrediction(ctx context.Context, modelID string, input map[string]interface{}) (_ *types.ReplicatePredictionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "stability.create_prediction", "stability.model", modelID)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	model, ok := stabilityModels[modelID]
+	if !ok {
+		return nil, fmt.Errorf("model %s is not available on the Stability API", modelID)
+	}
+
+	form := model.form(input)
+	url := fmt.Sprintf("%s/%s", stabilityAPIURL, model.endpoint)
+	slog.Debug("creating stability prediction", "model", modelID, "url", url, "fields", form.fields)
+
+	body, contentType := newMultipartBody(form)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Accept", "application/json")
+
+	startTime := time.Now()
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		Image        string `json:"image"`
+		FinishReason string `json:"finish_reason"`
+		Seed         int64  `json:"seed"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	prediction := &types.ReplicatePredictionResponse{
+		ID:      newInlineID(),
+		Input:   input,
+		Metrics: &types.PredictionMetrics{PredictTime: time.Since(startTime).Seconds()},
+	}
+	switch result.FinishReason {
+	case "CONTENT_FILTERED":
+		prediction.Status = types.StatusFailed
+		prediction.Error = "output was filtered out by Stability's content moderation"
+	default:
+		prediction.Status = types.StatusSucceeded
+		prediction.Output = []interface{}{"data:" + stabilityMimeType(form.fields["output_format"]) + ";base64," + result.Image}
+	}
+
+	c.results.store(prediction)
+
+	slog.Debug("stability prediction completed", "prediction_id", prediction.ID, "model", modelID, "finish_reason", result.FinishReason, "seed", result.Seed)
+	span.SetAttributes("stability.prediction_id", prediction.ID, "stability.finish_reason", result.FinishReason)
+	return prediction, nil
+}
+
+// GetPrediction returns a completed prediction
+func (c *StabilityClient) GetPrediction(ctx context.Context, predictionID string) (*types.ReplicatePredictionResponse, error) {
+	return c.results.take(predictionID)
+}
+
+// sd3Form returns a form builder for one of the SD 3.5 models
+func sd3Form(model string) func(map[string]interface{}) multipartForm {
+	return func(input map[string]interface{}) multipartForm {
+		form := stabilityGenerationForm(input)
+		form.fields["model"] = model
+		if cfg, ok := input["cfg"]; ok {
+			form.fields["cfg_scale"] = fmt.Sprintf("%v", cfg)
+		}
+		return form
+	}
+}
+
+// stabilityGenerationForm translates text-to-image input
+func stabilityGenerationForm(input map[string]interface{}) multipartForm {
+	form := multipartForm{fields: map[string]string{}, files: map[string]*types.FileData{}}
+	copyFields(form.fields, input, "prompt", "negative_prompt", "seed")
+	form.fields["output_format"] = imageOutputFormat(input["output_format"])
+	if ratio, ok := input["aspect_ratio"].(string); ok {
+		form.fields["aspect_ratio"] = stabilityAspectRatio(ratio)
+	}
+	return form
+}
+
+// stabilityImageForm translates image-to-image input (upscaling and inpainting)
+func stabilityImageForm(input map[string]interface{}) multipartForm {
+	form := multipartForm{fields: map[string]string{}, files: map[string]*types.FileData{}}
+	copyFields(form.fields, input, "prompt", "negative_prompt", "seed")
+	form.fields["output_format"] = imageOutputFormat(input["output_format"])
+	for _, key := range []string{"image", "img"} {
+		if image, ok := input[key].(*types.FileData); ok {
+			form.files["image"] = image
+		}
+	}
+	if mask, ok := input["mask"].(*types.FileData); ok {
+		form.files["mask"] = mask
+	}
+	return form
+}
+
+// stabilityConservativeForm translates input for the conservative upscaler,
+// which requires a prompt describing the image
+func stabilityConservativeForm(input map[string]interface{}) multipartForm {
+	form := stabilityImageForm(input)
+	if form.fields["prompt"] == "" {
+		form.fields["prompt"] = "high quality, sharp, detailed photograph"
+	}
+	return form
+}
+
+// copyFields copies the named input values into form fields
+func copyFields(fields map[string]string, input map[string]interface{}, keys ...string) {
+	for _, key := range keys {
+		if value, ok := input[key]; ok {
+			fields[key] = fmt.Sprintf("%v", value)
+		}
+	}
+}
+
+// stabilityAspectRatio maps ratios the API lacks to the nearest supported one
+func stabilityAspectRatio(ratio string) string {
+	switch ratio {
+	case "4:3":
+		return "3:2"
+	case "3:4":
+		return "2:3"
+	default:
+		return ratio
+	}
+}
+
+// stabilityMimeType returns the MIME type of an output format
+func stabilityMimeType(format string) string {
+	return "image/" + format
+}
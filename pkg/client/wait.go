@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+type maxWaitKey struct{}
+
+type pollScheduleKey struct{}
+
+// WithMaxWait returns a context whose predictions are polled for up to wait
+// before giving up, instead of the operation's usual limit
+func WithMaxWait(ctx context.Context, wait time.Duration) context.Context {
+	return context.WithValue(ctx, maxWaitKey{}, wait)
+}
+
+// PollSchedule sets how often a prediction is polled. The interval starts at
+// Initial and grows with the time already waited, by Backoff times the elapsed
+// time, up to Max: short jobs are seen finishing quickly, and long ones are not
+// polled more often than they need.
+type PollSchedule struct {
+	Initial time.Duration
+	Max     time.Duration
+	Backoff float64 // Fraction of the elapsed time to wait before the next poll
+}
+
+// DefaultPollSchedule polls every half second at first, backing off to every
+// ten seconds for predictions running close to a minute or longer
+var DefaultPollSchedule = PollSchedule{
+	Initial: 500 * time.Millisecond,
+	Max:     10 * time.Second,
+	Backoff: 0.2,
+}
+
+// Interval returns how long to wait before the next poll after elapsed
+func (s PollSchedule) Interval(elapsed time.Duration) time.Duration {
+	interval := time.Duration(float64(elapsed) * s.Backoff)
+	if interval < s.Initial {
+		interval = s.Initial
+	}
+	if s.Max > 0 && interval > s.Max {
+		interval = s.Max
+	}
+	return interval
+}
+
+// WithPollSchedule returns a context whose predictions are polled on schedule
+func WithPollSchedule(ctx context.Context, schedule PollSchedule) context.Context {
+	return context.WithValue(ctx, pollScheduleKey{}, schedule)
+}
+
+// Poll paces the status checks of a prediction until a deadline. The first
+// check is immediate; each one after it waits the interval the context's poll
+// schedule gives for the time elapsed.
+type Poll struct {
+	ctx      context.Context
+	schedule PollSchedule
+	start    time.Time
+	deadline time.Time
+	polled   bool
+}
+
+// Timeouts replace the built-in polling defaults of a subsystem, for example
+// with short waits in tests. Zero fields keep the defaults, and the wait and
+// poll schedule set in a call's context still win.
+type Timeouts struct {
+	Wait time.Duration // Longest wait for each prediction, instead of each operation's own limit
+	Poll PollSchedule  // Schedule used instead of DefaultPollSchedule
+}
+
+// NewPoll starts polling for up to wait, or for the wait set with WithMaxWait
+func NewPoll(ctx context.Context, wait time.Duration) *Poll {
+	return Timeouts{}.NewPoll(ctx, wait)
+}
+
+// NewPoll starts polling for up to wait, or for t.Wait when set, unless ctx
+// carries its own wait or schedule
+func (t Timeouts) NewPoll(ctx context.Context, wait time.Duration) *Poll {
+	if t.Wait > 0 {
+		wait = t.Wait
+	}
+	if maxWait, ok := ctx.Value(maxWaitKey{}).(time.Duration); ok && maxWait > 0 {
+		wait = maxWait
+	}
+	schedule, ok := ctx.Value(pollScheduleKey{}).(PollSchedule)
+	if !ok {
+		schedule = t.Poll
+	}
+	if schedule.Initial <= 0 {
+		schedule = DefaultPollSchedule
+	}
+	start := time.Now()
+	return &Poll{ctx: ctx, schedule: schedule, start: start, deadline: start.Add(wait)}
+}
+
+// Next waits until the next check is due and reports whether to make it. It
+// reports false once the deadline has passed, making a final check at the
+// deadline. When ctx is done it returns at once, so the check reports why.
+func (p *Poll) Next() bool {
+	if !p.polled {
+		p.polled = true
+		return true
+	}
+	remaining := time.Until(p.deadline)
+	if remaining <= 0 {
+		return false
+	}
+	timer := time.NewTimer(min(p.schedule.Interval(time.Since(p.start)), remaining))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-p.ctx.Done():
+	}
+	return true
+}
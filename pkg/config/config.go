@@ -4,7 +4,12 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/logging"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
 )
 
 // Config holds the configuration for the Replicate Image AI MCP server
@@ -13,26 +18,119 @@ type Config struct {
 	ReplicateAPIToken     string
 	ReplicateImagesRoot   string
 	
+	// Inference providers
+	FalAPIKey             string
+	StabilityAPIKey       string
+	OpenAIAPIKey          string
+	LocalBackend          string // a1111 or comfyui; empty disables the local provider
+	LocalURL              string // Local server address; empty uses the backend's default
+	LocalUpscaler         string // Automatic1111 upscaler name
+	ComfyUIWorkflow       string // Path to an API-format ComfyUI workflow template
+	Provider              string            // Preferred provider for every model it supports
+	ProviderModels        map[string]string // Per-model provider selection, by model ID
+	
 	// Optional with defaults
 	MaxImageSizeMB        int
-	MaxBatchSize          int
+	FileUploads           bool // Send large Replicate inputs through the files API instead of inline
+	MaxInputEdgePx        int // Overrides every model's input edge limit; zero keeps each model's own
+	MaxDownloadSizeMB     int
+	MaxParallelDownloads  int
+	MaxConcurrentPredictions int // Predictions in flight across all providers; zero means no limit
+	OffPeakWindow         Window // When calls with off_peak priority run; zero disables off-peak queueing
+	MaxBatchSize          int // Most prompts one batch_generate call runs at once
+	AllowedInputDirs      []string // Directories path arguments must be inside, besides the storage root; empty allows any path
 	OperationTimeout      time.Duration
+	ShutdownTimeout       time.Duration // How long a shutdown waits for tool calls in flight
+	Timeouts              TimeoutConfig // How often each operation polls its predictions
 	DebugMode            bool
+	ResultCache           bool   // Serve identical generation requests from stored results
+	PromptTranslation     bool   // Translate non-English generation prompts to English
+	DeterministicIDs      bool   // Derive generation storage IDs from the request instead of at random
+	ResponseDetail        string // minimal, standard, or full tool responses
+	AutoOpenOutputs       bool   // Open saved outputs in the OS default viewer
+	DeleteRemotePredictions bool // Delete predictions from Replicate once their results are saved
+	StripInputMetadata    bool   // Remove EXIF, XMP, and other metadata from inputs before upload
+	FileServerAddr        string // Listen address for the shareable-URL file server; empty disables it
+	FileServerURL         string // Public base URL of the file server, when behind a proxy or tunnel
+	FileServerSecret      string // Key for file URL tokens; URLs survive restarts only when set
+	SlackWebhookURL       string        // Slack incoming webhook for completion notifications
+	DiscordWebhookURL     string        // Discord webhook for completion notifications
+	NotifyWebhookURL      string        // Generic endpoint receiving completion events as JSON
+	NotifyMinDuration     time.Duration // Calls finishing sooner are not announced
+	DAMCreator            string        // Default creator written by export_for_dam
+	DAMUsageTerms         string        // Default usage terms written by export_for_dam
+	C2PACertPath          string // PEM certificate chain for C2PA signing; empty disables it
+	C2PAKeyPath           string // PEM private key matching the C2PA certificate
+	C2PAAlgorithm         string // C2PA signing algorithm (default es256)
+	C2PATimestampURL      string // RFC 3161 timestamp authority for C2PA signatures
+	C2PATool              string // Path to c2patool
+	ImageMagickPath       string // ImageMagick binary used to re-encode outputs as WebP or AVIF
+	BrandKitPath          string // YAML brand kit used by generate_branded; empty disables it
+	NegativePromptsPath   string // YAML negative prompt presets merged over the built-in ones
+	PromptPolicyPath      string // YAML banned and required prompt terms; empty disables the check
+	FilenameTemplate      string // Names generated images without a filename hint; empty uses the default
+	OutputModeration      string // block, quarantine, or tag for NSFW outputs; empty disables screening
+	PromptPrivacy         string // hash or encrypt prompts in stored metadata; empty stores them as they are
+	PromptEncryptionKey   string // 32-byte key, base64 or hex, used when PromptPrivacy is encrypt
+	WarmModels            []string      // Model IDs kept booted by periodic warm-up predictions
+	WarmInterval          time.Duration // Time between keep-warm rounds
+	LogLevel              string // debug, info, warn, or error
+	CassetteMode          string // "record", "replay", or empty for live traffic
+	CassetteDir           string // Directory holding the record/replay cassette
+}
+
+// TimeoutConfig holds how long tool calls wait for their predictions before
+// finishing in the background, and the poll schedules of prediction polling:
+// a default, and overrides for individual operations, by tool name
+type TimeoutConfig struct {
+	InitialWait time.Duration // Zero waits for the result
+	Poll        client.PollSchedule
+	PollByTool  map[string]client.PollSchedule
+}
+
+// PollSchedule returns the poll schedule of a tool
+func (t TimeoutConfig) PollSchedule(tool string) client.PollSchedule {
+	if schedule, ok := t.PollByTool[tool]; ok {
+		return schedule
+	}
+	return t.Poll
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
 	cfg := &Config{
 		// Set defaults
-		MaxImageSizeMB:   5,
-		MaxBatchSize:     10,
-		OperationTimeout: 30 * time.Second,
-		DebugMode:        false,
+		MaxImageSizeMB:       5,
+		MaxInputEdgePx:       0,
+		MaxDownloadSizeMB:    200,
+		MaxParallelDownloads: 4,
+		MaxBatchSize:         10,
+		OperationTimeout:     30 * time.Second,
+		ShutdownTimeout:      25 * time.Second,
+		Timeouts:             TimeoutConfig{InitialWait: 30 * time.Second, Poll: client.DefaultPollSchedule, PollByTool: map[string]client.PollSchedule{}},
+		DebugMode:            false,
+		LogLevel:             "info",
+		Provider:             "replicate",
+		NotifyMinDuration:    30 * time.Second,
+		WarmInterval:         4 * time.Minute,
+		ProviderModels:       map[string]string{},
+	}
+
+	// Record/replay mode for offline development
+	cfg.CassetteMode = os.Getenv("REPLICATE_CASSETTE_MODE")
+	switch cfg.CassetteMode {
+	case "", "record", "replay":
+	default:
+		return nil, fmt.Errorf("invalid REPLICATE_CASSETTE_MODE: %q (use record or replay)", cfg.CassetteMode)
+	}
+	cfg.CassetteDir = os.Getenv("REPLICATE_CASSETTE_DIR")
+	if cfg.CassetteDir == "" {
+		cfg.CassetteDir = "./testdata/cassette"
 	}
 
 	// Required fields
 	cfg.ReplicateAPIToken = os.Getenv("REPLICATE_API_TOKEN")
-	if cfg.ReplicateAPIToken == "" {
+	if cfg.ReplicateAPIToken == "" && cfg.CassetteMode != "replay" {
 		return nil, fmt.Errorf("REPLICATE_API_TOKEN environment variable is required")
 	}
 
@@ -42,7 +140,62 @@ func LoadConfig() (*Config, error) {
 		cfg.ReplicateImagesRoot = "./replicate_images"
 	}
 
+	// Alternative providers for models they can serve
+	cfg.FalAPIKey = os.Getenv("FAL_KEY")
+	cfg.StabilityAPIKey = os.Getenv("STABILITY_API_KEY")
+	cfg.OpenAIAPIKey = os.Getenv("OPENAI_API_KEY")
+	cfg.LocalBackend = os.Getenv("LOCAL_BACKEND")
+	cfg.LocalURL = os.Getenv("LOCAL_URL")
+	cfg.LocalUpscaler = os.Getenv("LOCAL_UPSCALER")
+	cfg.ComfyUIWorkflow = os.Getenv("COMFYUI_WORKFLOW")
+	if provider := os.Getenv("IMAGE_PROVIDER"); provider != "" {
+		cfg.Provider = provider
+	}
+	if routes := os.Getenv("PROVIDER_MODELS"); routes != "" {
+		for _, route := range strings.Split(routes, ",") {
+			name, provider, ok := strings.Cut(strings.TrimSpace(route), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid PROVIDER_MODELS entry %q (use model=provider)", route)
+			}
+			modelID, known := models.ResolveAny(strings.TrimSpace(name))
+			if !known {
+				return nil, fmt.Errorf("invalid PROVIDER_MODELS entry %q: unknown model %q", route, name)
+			}
+			cfg.ProviderModels[modelID] = strings.TrimSpace(provider)
+		}
+	}
+
+	if warm := os.Getenv("WARM_MODELS"); warm != "" {
+		for _, name := range strings.Split(warm, ",") {
+			modelID, known := models.ResolveAny(strings.TrimSpace(name))
+			if !known {
+				return nil, fmt.Errorf("invalid WARM_MODELS entry: unknown model %q", name)
+			}
+			cfg.WarmModels = append(cfg.WarmModels, modelID)
+		}
+	}
+
+	if interval := os.Getenv("WARM_INTERVAL_SECONDS"); interval != "" {
+		val, err := strconv.Atoi(interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WARM_INTERVAL_SECONDS: %w", err)
+		}
+		cfg.WarmInterval = time.Duration(val) * time.Second
+	}
+
 	// Optional fields
+	if uploads := os.Getenv("REPLICATE_FILE_UPLOADS"); uploads != "" {
+		val, err := strconv.ParseBool(uploads)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REPLICATE_FILE_UPLOADS: %w", err)
+		}
+		cfg.FileUploads = val
+		// Uploaded inputs are not held to the size of a request body
+		if val {
+			cfg.MaxImageSizeMB = client.MaxUploadBytes / (1024 * 1024)
+		}
+	}
+
 	if maxSize := os.Getenv("MAX_IMAGE_SIZE_MB"); maxSize != "" {
 		val, err := strconv.Atoi(maxSize)
 		if err != nil {
@@ -51,6 +204,46 @@ func LoadConfig() (*Config, error) {
 		cfg.MaxImageSizeMB = val
 	}
 
+	if maxEdge := os.Getenv("MAX_INPUT_EDGE_PX"); maxEdge != "" {
+		val, err := strconv.Atoi(maxEdge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAX_INPUT_EDGE_PX: %w", err)
+		}
+		cfg.MaxInputEdgePx = val
+	}
+
+	if maxDownload := os.Getenv("MAX_DOWNLOAD_SIZE_MB"); maxDownload != "" {
+		val, err := strconv.Atoi(maxDownload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAX_DOWNLOAD_SIZE_MB: %w", err)
+		}
+		cfg.MaxDownloadSizeMB = val
+	}
+
+	if parallel := os.Getenv("MAX_PARALLEL_DOWNLOADS"); parallel != "" {
+		val, err := strconv.Atoi(parallel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAX_PARALLEL_DOWNLOADS: %w", err)
+		}
+		cfg.MaxParallelDownloads = val
+	}
+
+	if concurrent := os.Getenv("MAX_CONCURRENT_PREDICTIONS"); concurrent != "" {
+		val, err := strconv.Atoi(concurrent)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAX_CONCURRENT_PREDICTIONS: %w", err)
+		}
+		cfg.MaxConcurrentPredictions = val
+	}
+
+	if window := os.Getenv("OFF_PEAK_WINDOW"); window != "" {
+		val, err := ParseWindow(window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OFF_PEAK_WINDOW: %w", err)
+		}
+		cfg.OffPeakWindow = val
+	}
+
 	if maxBatch := os.Getenv("MAX_BATCH_SIZE"); maxBatch != "" {
 		val, err := strconv.Atoi(maxBatch)
 		if err != nil {
@@ -59,6 +252,14 @@ func LoadConfig() (*Config, error) {
 		cfg.MaxBatchSize = val
 	}
 
+	if dirs := os.Getenv("ALLOWED_INPUT_DIRS"); dirs != "" {
+		for _, dir := range strings.Split(dirs, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				cfg.AllowedInputDirs = append(cfg.AllowedInputDirs, dir)
+			}
+		}
+	}
+
 	if timeout := os.Getenv("OPERATION_TIMEOUT_SECONDS"); timeout != "" {
 		val, err := strconv.Atoi(timeout)
 		if err != nil {
@@ -67,6 +268,159 @@ func LoadConfig() (*Config, error) {
 		cfg.OperationTimeout = time.Duration(val) * time.Second
 	}
 
+	if timeout := os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"); timeout != "" {
+		val, err := strconv.Atoi(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SHUTDOWN_TIMEOUT_SECONDS: %w", err)
+		}
+		cfg.ShutdownTimeout = time.Duration(val) * time.Second
+	}
+
+	if wait := os.Getenv("INITIAL_WAIT_SECONDS"); wait != "" {
+		val, err := strconv.Atoi(wait)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INITIAL_WAIT_SECONDS: %w", err)
+		}
+		cfg.Timeouts.InitialWait = time.Duration(val) * time.Second
+	}
+
+	if backoff := os.Getenv("POLL_BACKOFF"); backoff != "" {
+		val, err := strconv.ParseFloat(backoff, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid POLL_BACKOFF: %w", err)
+		}
+		cfg.Timeouts.Poll.Backoff = val
+	}
+
+	if interval := os.Getenv("POLL_INTERVAL"); interval != "" {
+		schedule, err := parsePollSchedule(interval, cfg.Timeouts.Poll.Backoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid POLL_INTERVAL: %w", err)
+		}
+		cfg.Timeouts.Poll = schedule
+	}
+
+	if intervals := os.Getenv("POLL_INTERVALS"); intervals != "" {
+		for _, entry := range strings.Split(intervals, ",") {
+			tool, interval, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid POLL_INTERVALS entry %q (use tool=initial:max)", entry)
+			}
+			schedule, err := parsePollSchedule(interval, cfg.Timeouts.Poll.Backoff)
+			if err != nil {
+				return nil, fmt.Errorf("invalid POLL_INTERVALS entry %q: %w", entry, err)
+			}
+			cfg.Timeouts.PollByTool[strings.TrimSpace(tool)] = schedule
+		}
+	}
+
+	if cache := os.Getenv("RESULT_CACHE"); cache != "" {
+		val, err := strconv.ParseBool(cache)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RESULT_CACHE: %w", err)
+		}
+		cfg.ResultCache = val
+	}
+
+	if translation := os.Getenv("PROMPT_TRANSLATION"); translation != "" {
+		val, err := strconv.ParseBool(translation)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROMPT_TRANSLATION: %w", err)
+		}
+		cfg.PromptTranslation = val
+	}
+
+	if deterministic := os.Getenv("DETERMINISTIC_IDS"); deterministic != "" {
+		val, err := strconv.ParseBool(deterministic)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DETERMINISTIC_IDS: %w", err)
+		}
+		cfg.DeterministicIDs = val
+	}
+
+	cfg.ResponseDetail = os.Getenv("RESPONSE_DETAIL")
+	switch cfg.ResponseDetail {
+	case "":
+		cfg.ResponseDetail = "standard"
+	case "minimal", "standard", "full":
+	default:
+		return nil, fmt.Errorf("invalid RESPONSE_DETAIL: %q (use minimal, standard, or full)", cfg.ResponseDetail)
+	}
+
+	if autoOpen := os.Getenv("AUTO_OPEN_OUTPUTS"); autoOpen != "" {
+		val, err := strconv.ParseBool(autoOpen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTO_OPEN_OUTPUTS: %w", err)
+		}
+		cfg.AutoOpenOutputs = val
+	}
+
+	if deleteRemote := os.Getenv("DELETE_REMOTE_PREDICTIONS"); deleteRemote != "" {
+		val, err := strconv.ParseBool(deleteRemote)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELETE_REMOTE_PREDICTIONS: %w", err)
+		}
+		cfg.DeleteRemotePredictions = val
+	}
+
+	if strip := os.Getenv("STRIP_INPUT_METADATA"); strip != "" {
+		val, err := strconv.ParseBool(strip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid STRIP_INPUT_METADATA: %w", err)
+		}
+		cfg.StripInputMetadata = val
+	}
+
+	cfg.FileServerAddr = os.Getenv("FILE_SERVER_ADDR")
+	cfg.FileServerURL = os.Getenv("FILE_SERVER_URL")
+	cfg.FileServerSecret = os.Getenv("FILE_SERVER_SECRET")
+
+	cfg.SlackWebhookURL = os.Getenv("SLACK_WEBHOOK_URL")
+	cfg.DiscordWebhookURL = os.Getenv("DISCORD_WEBHOOK_URL")
+	cfg.NotifyWebhookURL = os.Getenv("NOTIFY_WEBHOOK_URL")
+	cfg.DAMCreator = os.Getenv("DAM_CREATOR")
+	cfg.DAMUsageTerms = os.Getenv("DAM_USAGE_TERMS")
+
+	cfg.C2PACertPath = os.Getenv("C2PA_SIGN_CERT")
+	cfg.C2PAKeyPath = os.Getenv("C2PA_PRIVATE_KEY")
+	cfg.C2PAAlgorithm = os.Getenv("C2PA_ALGORITHM")
+	cfg.C2PATimestampURL = os.Getenv("C2PA_TIMESTAMP_URL")
+	cfg.C2PATool = os.Getenv("C2PA_TOOL")
+
+	cfg.ImageMagickPath = os.Getenv("IMAGEMAGICK_PATH")
+
+	cfg.BrandKitPath = os.Getenv("BRAND_KIT")
+	cfg.NegativePromptsPath = os.Getenv("NEGATIVE_PROMPTS")
+	cfg.PromptPolicyPath = os.Getenv("PROMPT_POLICY")
+	cfg.FilenameTemplate = os.Getenv("FILENAME_TEMPLATE")
+
+	cfg.OutputModeration = os.Getenv("OUTPUT_MODERATION")
+	switch cfg.OutputModeration {
+	case "", "block", "quarantine", "tag":
+	default:
+		return nil, fmt.Errorf("invalid OUTPUT_MODERATION: %q (use block, quarantine, or tag)", cfg.OutputModeration)
+	}
+
+	cfg.PromptPrivacy = os.Getenv("PROMPT_PRIVACY")
+	cfg.PromptEncryptionKey = os.Getenv("PROMPT_ENCRYPTION_KEY")
+	switch cfg.PromptPrivacy {
+	case "", "hash":
+	case "encrypt":
+		if cfg.PromptEncryptionKey == "" {
+			return nil, fmt.Errorf("PROMPT_ENCRYPTION_KEY is required when PROMPT_PRIVACY is encrypt")
+		}
+	default:
+		return nil, fmt.Errorf("invalid PROMPT_PRIVACY: %q (use hash or encrypt)", cfg.PromptPrivacy)
+	}
+
+	if minSeconds := os.Getenv("NOTIFY_MIN_SECONDS"); minSeconds != "" {
+		val, err := strconv.Atoi(minSeconds)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NOTIFY_MIN_SECONDS: %w", err)
+		}
+		cfg.NotifyMinDuration = time.Duration(val) * time.Second
+	}
+
 	if debug := os.Getenv("DEBUG_MODE"); debug != "" {
 		val, err := strconv.ParseBool(debug)
 		if err != nil {
@@ -75,23 +429,107 @@ func LoadConfig() (*Config, error) {
 		cfg.DebugMode = val
 	}
 
+	// Debug mode implies debug logging unless a level is set explicitly
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		if _, err := logging.ParseLevel(level); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+		cfg.LogLevel = level
+	} else if cfg.DebugMode {
+		cfg.LogLevel = "debug"
+	}
+
 	return cfg, nil
 }
 
+// Secrets returns every credential configured, for logging to redact
+func (c *Config) Secrets() []string {
+	return []string{
+		c.ReplicateAPIToken,
+		c.FalAPIKey,
+		c.StabilityAPIKey,
+		c.OpenAIAPIKey,
+		c.FileServerSecret,
+		c.SlackWebhookURL, // Webhook URLs carry their token in the path
+		c.DiscordWebhookURL,
+		c.NotifyWebhookURL,
+		c.PromptEncryptionKey,
+	}
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.ReplicateAPIToken == "" {
+	if c.ReplicateAPIToken == "" && c.CassetteMode != "replay" {
 		return fmt.Errorf("Replicate API token is required")
 	}
+	for _, provider := range append([]string{c.Provider}, providerNames(c.ProviderModels)...) {
+		switch provider {
+		case "replicate":
+		case "fal":
+			if c.FalAPIKey == "" && c.CassetteMode != "replay" {
+				return fmt.Errorf("FAL_KEY is required to use the fal provider")
+			}
+		case "stability":
+			if c.StabilityAPIKey == "" && c.CassetteMode != "replay" {
+				return fmt.Errorf("STABILITY_API_KEY is required to use the stability provider")
+			}
+		case "openai":
+			if c.OpenAIAPIKey == "" && c.CassetteMode != "replay" {
+				return fmt.Errorf("OPENAI_API_KEY is required to use the openai provider")
+			}
+		case "local":
+			if c.LocalBackend == "" {
+				return fmt.Errorf("LOCAL_BACKEND is required to use the local provider")
+			}
+		default:
+			return fmt.Errorf("unknown provider %q (use replicate, fal, stability, openai, or local)", provider)
+		}
+	}
+	switch c.LocalBackend {
+	case "", "a1111":
+	case "comfyui":
+		if c.ComfyUIWorkflow == "" {
+			return fmt.Errorf("COMFYUI_WORKFLOW is required for the comfyui backend")
+		}
+	default:
+		return fmt.Errorf("invalid LOCAL_BACKEND %q (use a1111 or comfyui)", c.LocalBackend)
+	}
+	if (c.C2PACertPath == "") != (c.C2PAKeyPath == "") {
+		return fmt.Errorf("C2PA_SIGN_CERT and C2PA_PRIVATE_KEY must be set together")
+	}
 	if c.MaxImageSizeMB <= 0 {
 		return fmt.Errorf("max image size must be positive")
 	}
+	if c.MaxInputEdgePx < 0 {
+		return fmt.Errorf("max input edge cannot be negative")
+	}
+	if c.MaxDownloadSizeMB <= 0 {
+		return fmt.Errorf("max download size must be positive")
+	}
+	if c.MaxParallelDownloads <= 0 {
+		return fmt.Errorf("max parallel downloads must be positive")
+	}
+	if len(c.WarmModels) > 0 && c.WarmInterval < time.Minute {
+		return fmt.Errorf("warm interval must be at least 60 seconds")
+	}
+	if c.MaxConcurrentPredictions < 0 {
+		return fmt.Errorf("max concurrent predictions cannot be negative")
+	}
 	if c.MaxBatchSize <= 0 {
 		return fmt.Errorf("max batch size must be positive")
 	}
 	if c.OperationTimeout <= 0 {
 		return fmt.Errorf("operation timeout must be positive")
 	}
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("shutdown timeout cannot be negative")
+	}
+	if c.Timeouts.InitialWait < 0 {
+		return fmt.Errorf("initial wait cannot be negative")
+	}
+	if c.Timeouts.Poll.Backoff < 0 {
+		return fmt.Errorf("poll backoff cannot be negative")
+	}
 	
 	// Create images root folder if it doesn't exist
 	if err := os.MkdirAll(c.ReplicateImagesRoot, 0755); err != nil {
@@ -99,4 +537,33 @@ func (c *Config) Validate() error {
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// providerNames returns the providers selected in a per-model routing table
+func providerNames(routes map[string]string) []string {
+	names := make([]string, 0, len(routes))
+	for _, name := range routes {
+		names = append(names, name)
+	}
+	return names
+}
+
+// parsePollSchedule parses a poll schedule written as initial:max, such as
+// 500ms:10s, or as a single fixed interval, such as 2s
+func parsePollSchedule(value string, backoff float64) (client.PollSchedule, error) {
+	initialValue, maxValue, ranged := strings.Cut(strings.TrimSpace(value), ":")
+	initial, err := time.ParseDuration(initialValue)
+	if err != nil {
+		return client.PollSchedule{}, err
+	}
+	maxInterval := initial
+	if ranged {
+		if maxInterval, err = time.ParseDuration(maxValue); err != nil {
+			return client.PollSchedule{}, err
+		}
+	}
+	if initial <= 0 || maxInterval < initial {
+		return client.PollSchedule{}, fmt.Errorf("intervals must be positive, and the maximum at least the initial one")
+	}
+	return client.PollSchedule{Initial: initial, Max: maxInterval, Backoff: backoff}, nil
+}
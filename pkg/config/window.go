@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time window in the server's local time, such as
+// 22:00-06:00. A window ending before it starts spans midnight.
+type Window struct {
+	Start time.Duration // Since midnight
+	End   time.Duration
+}
+
+// ParseWindow parses a window written as HH:MM-HH:MM
+func ParseWindow(s string) (Window, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("%q is not a window (use HH:MM-HH:MM, such as 22:00-06:00)", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, err
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("window %q is empty", s)
+	}
+	return Window{Start: start, End: end}, nil
+}
+
+// parseClock parses a time of day written as HH:MM
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day (use HH:MM)", strings.TrimSpace(s))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// IsZero reports whether no window is set
+func (w Window) IsZero() bool {
+	return w.Start == 0 && w.End == 0
+}
+
+// Contains reports whether t falls inside the window
+func (w Window) Contains(t time.Time) bool {
+	offset := t.Sub(midnight(t, 0))
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// NextStart returns t when it falls inside the window, and otherwise when
+// the window next opens
+func (w Window) NextStart(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	if start := midnight(t, 0).Add(w.Start); start.After(t) {
+		return start
+	}
+	return midnight(t, 1).Add(w.Start)
+}
+
+// String returns the window as HH:MM-HH:MM
+func (w Window) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// midnight returns the start of the day days after t's, in t's location
+func midnight(t time.Time, days int) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, t.Location())
+}
@@ -3,35 +3,51 @@ package editing
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/moderation"
 	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 	"github.com/gomcpgo/replicate_image_ai/pkg/types"
 )
 
 // Editor handles image editing operations
 type Editor struct {
-	client  *client.ReplicateClient
-	storage *storage.Storage
-	debug   bool
+	client   client.Predictor
+	storage  *storage.Storage
+	screener *moderation.Screener // Nil unless output moderation is configured
+	timeouts client.Timeouts
+	debug    bool
 }
 
 // NewEditor creates a new Editor instance
-func NewEditor(client *client.ReplicateClient, storage *storage.Storage, debug bool) *Editor {
+func NewEditor(predictor client.Predictor, storage *storage.Storage, debug bool) *Editor {
+	return NewEditorWithTimeouts(predictor, storage, debug, client.Timeouts{})
+}
+
+// NewEditorWithTimeouts creates an Editor polling its predictions with
+// timeouts instead of the defaults
+func NewEditorWithTimeouts(predictor client.Predictor, storage *storage.Storage, debug bool, timeouts client.Timeouts) *Editor {
 	return &Editor{
-		client:  client,
-		storage: storage,
-		debug:   debug,
+		client:   predictor,
+		storage:  storage,
+		timeouts: timeouts,
+		debug:    debug,
 	}
 }
 
+// SetScreener screens every edited output with s before it is saved
+func (e *Editor) SetScreener(s *moderation.Screener) {
+	e.screener = s
+}
+
 // EditImage performs text-based image editing using FLUX Kontext
-func (e *Editor) EditImage(ctx context.Context, params EditParams) (*EditResult, error) {
+func (e *Editor) EditImage(ctx context.Context, params EditParams) (_ *EditResult, err error) {
 	startTime := time.Now()
 	
 	// Validate parameters
@@ -40,16 +56,24 @@ func (e *Editor) EditImage(ctx context.Context, params EditParams) (*EditResult,
 	}
 	
 	// Get model ID from alias if needed
-	modelID := GetModelFromAlias(params.Model)
+	modelID := models.Resolve(models.OpEditImage, params.Model)
+	if models.RequiresMask(modelID) && params.MaskPath == "" {
+		return nil, EditError{
+			Code:    "invalid_parameters",
+			Message: fmt.Sprintf("%s requires a mask_path marking the area to repaint", models.GetModelInfo(modelID).Name),
+		}
+	}
 	
-	// Generate unique ID for this operation
-	id, err := e.storage.GenerateID()
+	// Start the operation under a unique ID
+	op, err := e.storage.StartOperation(ctx, e.debug, "edit_image", modelID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate ID: %w", err)
+		return nil, err
 	}
+	defer func() { op.Finish(err) }()
+	id, bundle := op.ID, op.Bundle
 	
-	// Convert image to base64 data URL
-	dataURL, err := storage.ImageToBase64(params.ImagePath)
+	// Prepare the input image
+	inputImage, err := op.PrepareInput(params.ImagePath)
 	if err != nil {
 		return nil, EditError{
 			Code:    "file_error",
@@ -61,46 +85,64 @@ func (e *Editor) EditImage(ctx context.Context, params EditParams) (*EditResult,
 	}
 	
 	// Build input parameters for FLUX Kontext
-	input := e.buildEditInput(modelID, dataURL, params)
+	input := e.buildEditInput(modelID, inputImage.Data, params)
 	
-	if e.debug {
-		log.Printf("Editing image with model %s", modelID)
-		log.Printf("Edit prompt: %s", params.Prompt)
+	if params.MaskPath != "" {
+		mask, err := op.PrepareInput(params.MaskPath)
+		if err != nil {
+			return nil, EditError{
+				Code:    "file_error",
+				Message: fmt.Sprintf("failed to load mask: %v", err),
+				Details: map[string]interface{}{
+					"file_path": params.MaskPath,
+				},
+			}
+		}
+		input["mask"] = mask.Data
 	}
 	
+	slog.Debug("editing image", "storage_id", id, "model", modelID, "prompt", params.Prompt)
+	
+	bundle.SetInput(input)
+	bundle.Stage("prepare_input")
+	
 	// Create prediction
 	prediction, err := e.client.CreatePrediction(ctx, modelID, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prediction: %w", err)
 	}
+	bundle.Record(prediction)
+	bundle.Stage("create_prediction")
 	
 	// Poll for completion (editing can take time)
-	const maxAttempts = 60
-	const pollInterval = 2 * time.Second
+	poll := e.timeouts.NewPoll(ctx, 2*time.Minute)
 	
 	var result *types.ReplicatePredictionResponse
-	for i := 0; i < maxAttempts; i++ {
+	for poll.Next() {
 		result, err = e.client.GetPrediction(ctx, prediction.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get prediction status: %w", err)
 		}
+		bundle.Record(result)
 		
 		if result.Status == "succeeded" {
 			break
 		}
 		
 		if result.Status == "failed" || result.Status == "canceled" {
+			code, message := client.ClassifyFailure(result.Error, result.Logs)
+			if code == "" {
+				code = "editing_failed"
+			}
 			return nil, EditError{
-				Code:    "editing_failed",
-				Message: fmt.Sprintf("Editing %s: %v", result.Status, result.Error),
+				Code:    code,
+				Message: fmt.Sprintf("Editing %s: %s", result.Status, message),
 				Details: map[string]interface{}{
 					"prediction_id": prediction.ID,
 					"status":        result.Status,
 				},
 			}
 		}
-		
-		time.Sleep(pollInterval)
 	}
 	
 	if result == nil || result.Status != "succeeded" {
@@ -113,47 +155,75 @@ func (e *Editor) EditImage(ctx context.Context, params EditParams) (*EditResult,
 		}
 	}
 	
-	// Extract output URL
-	outputURL := ""
-	if output, ok := result.Output.([]interface{}); ok && len(output) > 0 {
-		if url, ok := output[0].(string); ok {
-			outputURL = url
-		}
-	} else if url, ok := result.Output.(string); ok {
-		outputURL = url
-	}
+	bundle.Stage("wait_for_prediction")
 	
-	if outputURL == "" {
+	// Extract output URL
+	outputURLs := client.OutputURLs(result.Output)
+	if len(outputURLs) == 0 {
 		return nil, EditError{
 			Code:    "no_output",
 			Message: "No output URL in result",
 		}
 	}
 	
-	// Download and save image
+	// Screen outputs before they are written to disk
 	filename := e.generateFilename(params.Filename, params.ImagePath, "edited")
-	outputPath, err := e.storage.SaveImage(id, outputURL, filename)
+	screening, err := e.screener.Screen(ctx, id, outputURLs, filename)
 	if err != nil {
+		return nil, EditError{
+			Code:    "moderation_failed",
+			Message: err.Error(),
+			Details: map[string]interface{}{
+				"prediction_id": prediction.ID,
+			},
+		}
+	}
+	if code, message := screening.HeldBack(); code != "" {
+		return nil, EditError{
+			Code:    code,
+			Message: message,
+			Details: map[string]interface{}{
+				"prediction_id": prediction.ID,
+				"moderation":    screening.Metadata(),
+			},
+		}
+	}
+	outputURLs = screening.Keep
+	bundle.Stage("moderate_output")
+	
+	// Download and save image
+	savedFiles, err := e.storage.SaveOutputsWithRefresh(ctx, id, outputURLs, filename, screening.Refresher(client.OutputRefresher(ctx, e.client, prediction.ID)))
+	partial := storage.PartialOutputs(err)
+	if err != nil && partial == nil {
 		return nil, fmt.Errorf("failed to save image: %w", err)
 	}
+	bundle.Stage("download_output")
+	saved := savedFiles[0]
+	outputPath := saved.Path
 	
 	// Calculate metrics
 	inputInfo, _ := os.Stat(params.ImagePath)
-	outputInfo, _ := os.Stat(outputPath)
 	
 	metrics := EditMetrics{
 		ProcessingTime: time.Since(startTime).Seconds(),
 		InputSize:      inputInfo.Size(),
-		OutputSize:     outputInfo.Size(),
+		OutputSize:     saved.Size,
 	}
 	
 	// Save metadata
 	opResult := &types.OperationResult{
-		Filename:       filename,
+		Filename:       filepath.Base(outputPath),
 		GenerationTime: time.Since(startTime).Seconds(),
 		PredictionID:   prediction.ID,
+		FileSize:       saved.Size,
+		SHA256:         saved.SHA256,
+		Files:          storage.Filenames(savedFiles),
 	}
 	
+	models.SetActualCost(opResult, result, modelID, len(savedFiles))
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+	
 	metadata := &types.ImageMetadata{
 		Version:   "1.0",
 		ID:        id,
@@ -169,25 +239,46 @@ func (e *Editor) EditImage(ctx context.Context, params EditParams) (*EditResult,
 		},
 		Result: opResult,
 	}
+	// A selected mask is selected again on regenerate rather than reused
+	if params.SelectionPrompt != "" {
+		metadata.Parameters["selection_prompt"] = params.SelectionPrompt
+		metadata.Parameters["selection_mask"] = params.MaskPath
+	} else if params.MaskPath != "" {
+		metadata.Parameters["mask_path"] = params.MaskPath
+	}
+	if params.Seed > 0 {
+		metadata.Parameters["seed"] = params.Seed
+	}
 	
-	if err := e.storage.SaveMetadata(id, metadata); err != nil && e.debug {
-		log.Printf("Failed to save metadata: %v", err)
+	if info := screening.Metadata(); info != nil {
+		metadata.Parameters["moderation"] = info
+	}
+	
+	if err := e.storage.SaveMetadata(id, metadata); err != nil {
+		slog.Warn("failed to save metadata", "storage_id", id, "error", err)
+	}
+	if err := e.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "storage_id", id, "error", err)
 	}
 	
 	// Build result
-	modelInfo := GetModelInfo(modelID)
+	modelInfo := models.GetModelInfo(modelID)
 	return &EditResult{
 		ID:           id,
 		Operation:    "edit_image",
 		InputPath:    params.ImagePath,
 		OutputPath:   outputPath,
-		OutputURL:    outputURL,
+		OutputURL:    saved.URL,
+		OutputPaths:  storage.Paths(savedFiles),
+		OutputURLs:   storage.URLs(savedFiles),
 		Model:        modelID,
 		ModelName:    modelInfo.Name,
 		EditPrompt:   params.Prompt,
 		Parameters:   input,
 		Metrics:      metrics,
 		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+		Notes:        append(append(inputImage.Notes, screening.Notes()...), partial.Notes()...),
 	}, nil
 }
 
@@ -224,10 +315,10 @@ func (e *Editor) validateEditParams(params *EditParams) error {
 }
 
 // buildEditInput builds input parameters for FLUX Kontext editing
-func (e *Editor) buildEditInput(modelID string, dataURL string, params EditParams) map[string]interface{} {
+func (e *Editor) buildEditInput(modelID string, image *types.FileData, params EditParams) map[string]interface{} {
 	// FLUX Kontext models have similar input structure
 	input := map[string]interface{}{
-		"image":          dataURL,
+		"image":          image,
 		"prompt":         params.Prompt,
 		"guidance_scale": params.GuidanceScale,
 		"num_outputs":    params.NumOutputs,
@@ -235,25 +326,40 @@ func (e *Editor) buildEditInput(modelID string, dataURL string, params EditParam
 	
 	// Add model-specific parameters
 	switch modelID {
-	case ModelFluxKontextPro:
+	case models.ModelFluxKontextPro:
 		// Pro model - balanced settings
 		if params.Strength > 0 {
 			input["strength"] = params.Strength
 		}
 		
-	case ModelFluxKontextMax:
+	case models.ModelFluxKontextMax:
 		// Max model - highest quality settings
 		if params.Strength > 0 {
 			input["strength"] = params.Strength
 		}
 		input["quality"] = "max"
 		
-	case ModelFluxKontextDev:
+	case models.ModelFluxKontextDev:
 		// Dev model - all controls exposed
 		if params.Strength > 0 {
 			input["strength"] = params.Strength
 		}
 		input["num_inference_steps"] = 50 // More steps for dev
+		
+	case models.ModelStabilityInpaint, models.ModelFluxFillPro:
+		// Inpainting repaints the masked area only; strength and guidance do not apply
+		delete(input, "guidance_scale")
+		delete(input, "num_outputs")
+		
+	case models.ModelGPTImage1:
+		// GPT Image follows the instruction directly; there is no guidance control
+		delete(input, "guidance_scale")
+		
+	case models.ModelLocalInpaint:
+		// Strength becomes Automatic1111's denoising strength
+		if params.Strength > 0 {
+			input["strength"] = params.Strength
+		}
 	}
 	
 	// Add seed if specified
@@ -6,7 +6,9 @@ import "time"
 type EditParams struct {
 	ImagePath    string
 	Prompt       string  // Edit instruction
-	Model        string  // pro, max, dev
+	Model        string  // pro, max, dev, inpaint, gpt-image-1
+	MaskPath     string  // Mask for inpainting models and GPT Image (white marks the area to repaint)
+	SelectionPrompt string // What the mask was selected from, when it was not drawn
 	Strength     float64 // Edit strength (0.0-1.0)
 	GuidanceScale float64 // Guidance scale for edit
 	NumOutputs   int     // Number of variations
@@ -21,12 +23,16 @@ type EditResult struct {
 	InputPath    string
 	OutputPath   string
 	OutputURL    string
+	OutputPaths  []string // Every saved file when the model returns several
+	OutputURLs   []string
 	Model        string
 	ModelName    string
 	EditPrompt   string
 	Parameters   map[string]interface{}
 	Metrics      EditMetrics
 	PredictionID string
+	Provider     string   // Provider that ran the prediction
+	Notes        []string // Notes about adjustments made to the input
 }
 
 // EditMetrics contains performance metrics for editing
@@ -34,6 +40,8 @@ type EditMetrics struct {
 	ProcessingTime float64 // in seconds
 	InputSize      int64   // in bytes
 	OutputSize     int64   // in bytes
+	PredictTime    float64 // Billed model time in seconds
+	Cost           float64 // Actual cost in USD
 }
 
 // EditError represents an error during editing
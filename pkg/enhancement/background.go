@@ -3,17 +3,20 @@ package enhancement
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
 	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 	"github.com/gomcpgo/replicate_image_ai/pkg/types"
 )
 
 // RemoveBackground removes the background from an image
-func (e *Enhancer) RemoveBackground(ctx context.Context, params RemoveBackgroundParams) (*EnhancementResult, error) {
+func (e *Enhancer) RemoveBackground(ctx context.Context, params RemoveBackgroundParams) (_ *EnhancementResult, err error) {
 	startTime := time.Now()
 	
 	// Validate parameters
@@ -25,73 +28,85 @@ func (e *Enhancer) RemoveBackground(ctx context.Context, params RemoveBackground
 	}
 	
 	// Get model ID from alias if needed
-	modelID := GetModelFromAlias("remove_background", params.Model)
+	modelID := models.Resolve(models.OpRemoveBackground, params.Model)
 	
-	// Generate unique ID for this operation
-	id, err := e.storage.GenerateID()
+	// Start the operation under a unique ID
+	op, err := e.storage.StartOperation(ctx, e.debug, "remove_background", modelID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate ID: %w", err)
+		return nil, err
 	}
+	defer func() { op.Finish(err) }()
+	id, bundle := op.ID, op.Bundle
 	
-	// Convert image to base64 data URL
-	dataURL, err := storage.ImageToBase64(params.ImagePath)
+	// Prepare the input image
+	inputImage, err := op.PrepareInput(params.ImagePath)
 	if err != nil {
-		return nil, EnhancementError{
-			Code:    "file_error",
-			Message: fmt.Sprintf("failed to load image: %v", err),
-			Details: map[string]interface{}{
-				"file_path": params.ImagePath,
-			},
-		}
+		return nil, inputError(params.ImagePath, err)
 	}
 	
 	// Build input parameters based on model
-	input := e.buildRemoveBackgroundInput(modelID, dataURL)
+	input := e.buildRemoveBackgroundInput(modelID, inputImage.Data)
 	
-	e.logDebug("Removing background with model %s", modelID)
+	slog.Debug("removing background", "storage_id", id, "model", modelID)
+	
+	bundle.SetInput(input)
+	bundle.Stage("prepare_input")
 	
 	// Create prediction
 	prediction, err := e.client.CreatePrediction(ctx, modelID, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prediction: %w", err)
 	}
+	bundle.Record(prediction)
+	bundle.Stage("create_prediction")
 	
 	// Poll for completion
-	result, err := e.pollForCompletion(ctx, prediction.ID, 30, 2*time.Second)
+	result, err := e.pollForCompletion(ctx, bundle, prediction.ID, time.Minute)
 	if err != nil {
 		return nil, err
 	}
+	bundle.Stage("wait_for_prediction")
 	
-	// Extract output URL
-	outputURL, err := e.extractOutputURL(result)
+	// Extract output URLs
+	outputURLs, err := e.extractOutputURLs(result)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Download and save image
 	filename := e.generateFilename(params.Filename, params.ImagePath, "no_bg")
-	outputPath, err := e.storage.SaveImage(id, outputURL, filename)
-	if err != nil {
+	savedFiles, err := e.storage.SaveOutputsWithRefresh(ctx, id, outputURLs, filename, client.OutputRefresher(ctx, e.client, prediction.ID))
+	partial := storage.PartialOutputs(err)
+	if err != nil && partial == nil {
 		return nil, fmt.Errorf("failed to save image: %w", err)
 	}
+	bundle.Stage("download_output")
+	saved := savedFiles[0]
+	outputPath := saved.Path
 	
 	// Calculate metrics
 	inputInfo, _ := os.Stat(params.ImagePath)
-	outputInfo, _ := os.Stat(outputPath)
 	
 	metrics := EnhancementMetrics{
 		ProcessingTime: time.Since(startTime).Seconds(),
 		InputSize:      inputInfo.Size(),
-		OutputSize:     outputInfo.Size(),
+		OutputSize:     saved.Size,
 	}
 	
 	// Save metadata
 	opResult := &types.OperationResult{
-		Filename:       filename,
+		Filename:       filepath.Base(outputPath),
 		GenerationTime: time.Since(startTime).Seconds(),
 		PredictionID:   prediction.ID,
+		FileSize:       saved.Size,
+		SHA256:         saved.SHA256,
+		Files:          storage.Filenames(savedFiles),
 	}
 	
+	models.SetActualCost(opResult, result, modelID, len(savedFiles))
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+	
 	metadata := &types.ImageMetadata{
 		Version:   "1.0",
 		ID:        id,
@@ -107,71 +122,83 @@ func (e *Enhancer) RemoveBackground(ctx context.Context, params RemoveBackground
 	}
 	
 	if err := e.storage.SaveMetadata(id, metadata); err != nil {
-		e.logDebug("Failed to save metadata: %v", err)
+		slog.Warn("failed to save metadata", "storage_id", id, "error", err)
+	}
+	if err := e.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "storage_id", id, "error", err)
 	}
 	
 	// Build result
-	modelInfo := GetModelInfo(modelID)
+	modelInfo := models.GetModelInfo(modelID)
 	return &EnhancementResult{
 		ID:           id,
 		Operation:    "remove_background",
 		InputPath:    params.ImagePath,
 		OutputPath:   outputPath,
-		OutputURL:    outputURL,
+		OutputURL:    saved.URL,
+		OutputPaths:  storage.Paths(savedFiles),
+		OutputURLs:   storage.URLs(savedFiles),
 		Model:        modelID,
 		ModelName:    modelInfo.Name,
 		Parameters:   input,
 		Metrics:      metrics,
 		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+		Notes:        append(inputImage.Notes, partial.Notes()...),
 	}, nil
 }
 
 // buildRemoveBackgroundInput builds input parameters for background removal
-func (e *Enhancer) buildRemoveBackgroundInput(modelID, dataURL string) map[string]interface{} {
+func (e *Enhancer) buildRemoveBackgroundInput(modelID string, image *types.FileData) map[string]interface{} {
 	switch modelID {
-	case ModelRemoveBG:
+	case models.ModelRemoveBG:
 		return map[string]interface{}{
-			"image": dataURL,
+			"image": image,
 		}
-	case ModelRembg:
+	case models.ModelRembg:
 		return map[string]interface{}{
-			"image": dataURL,
+			"image": image,
 		}
-	case ModelDISBGRemoval:
+	case models.ModelDISBGRemoval:
 		return map[string]interface{}{
-			"image": dataURL,
+			"image": image,
 		}
 	default:
 		return map[string]interface{}{
-			"image": dataURL,
+			"image": image,
 		}
 	}
 }
 
-// pollForCompletion polls the API until the prediction completes
-func (e *Enhancer) pollForCompletion(ctx context.Context, predictionID string, maxAttempts int, interval time.Duration) (*types.ReplicatePredictionResponse, error) {
-	for i := 0; i < maxAttempts; i++ {
+// pollForCompletion polls the API until the prediction completes, giving up
+// after wait unless the call set its own
+func (e *Enhancer) pollForCompletion(ctx context.Context, bundle *storage.DebugBundle, predictionID string, wait time.Duration) (*types.ReplicatePredictionResponse, error) {
+	poll := e.timeouts.NewPoll(ctx, wait)
+	for poll.Next() {
 		result, err := e.client.GetPrediction(ctx, predictionID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get prediction status: %w", err)
 		}
+		bundle.Record(result)
 		
 		if result.Status == "succeeded" {
 			return result, nil
 		}
 		
 		if result.Status == "failed" || result.Status == "canceled" {
+			code, message := client.ClassifyFailure(result.Error, result.Logs)
+			if code == "" {
+				code = "processing_failed"
+			}
 			return nil, EnhancementError{
-				Code:    "processing_failed",
-				Message: fmt.Sprintf("Processing %s: %v", result.Status, result.Error),
+				Code:    code,
+				Message: fmt.Sprintf("Processing %s: %s", result.Status, message),
 				Details: map[string]interface{}{
 					"prediction_id": predictionID,
 					"status":        result.Status,
 				},
 			}
 		}
-		
-		time.Sleep(interval)
 	}
 	
 	return nil, EnhancementError{
@@ -183,34 +210,16 @@ func (e *Enhancer) pollForCompletion(ctx context.Context, predictionID string, m
 	}
 }
 
-// extractOutputURL extracts the output URL from prediction result
-func (e *Enhancer) extractOutputURL(result *types.ReplicatePredictionResponse) (string, error) {
-	// Handle string output
-	if url, ok := result.Output.(string); ok && url != "" {
-		return url, nil
-	}
-	
-	// Handle array output
-	if outputs, ok := result.Output.([]interface{}); ok && len(outputs) > 0 {
-		if url, ok := outputs[0].(string); ok && url != "" {
-			return url, nil
-		}
-	}
-	
-	// Handle map output with specific keys
-	if outputMap, ok := result.Output.(map[string]interface{}); ok {
-		// Try common keys
-		for _, key := range []string{"image", "output", "url", "file"} {
-			if url, ok := outputMap[key].(string); ok && url != "" {
-				return url, nil
-			}
+// extractOutputURLs extracts every output URL from a prediction result
+func (e *Enhancer) extractOutputURLs(result *types.ReplicatePredictionResponse) ([]string, error) {
+	urls := client.OutputURLs(result.Output)
+	if len(urls) == 0 {
+		return nil, EnhancementError{
+			Code:    "no_output",
+			Message: "No output URL in result",
 		}
 	}
-	
-	return "", EnhancementError{
-		Code:    "no_output",
-		Message: "No output URL in result",
-	}
+	return urls, nil
 }
 
 // generateFilename generates a filename for the enhanced image
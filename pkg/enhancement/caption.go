@@ -0,0 +1,145 @@
+package enhancement
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// defaultCaptionPrompt asks prompted captioning models for a caption suited to
+// training data
+const defaultCaptionPrompt = "Describe this image in one sentence for use as a training caption. Mention the subject, setting, lighting, and style. Do not start with \"This image\"."
+
+// Caption describes an image in text. Captions produce no file, so nothing is
+// stored; the spend is still recorded in the ledger.
+func (e *Enhancer) Caption(ctx context.Context, params CaptionParams) (*CaptionResult, error) {
+	startTime := time.Now()
+
+	// Validate parameters
+	if params.ImagePath == "" {
+		return nil, EnhancementError{
+			Code:    "invalid_parameters",
+			Message: "image path is required",
+		}
+	}
+
+	// Get model ID from alias if needed
+	modelID := models.Resolve(models.OpCaption, params.Model)
+
+	// Prepare the input image
+	inputImage, err := e.storage.PrepareInput(params.ImagePath, models.InputEdge(modelID))
+	if err != nil {
+		return nil, inputError(params.ImagePath, err)
+	}
+
+	var input map[string]interface{}
+	switch modelID {
+	case models.ModelLLaVA13:
+		prompt := params.Prompt
+		if prompt == "" {
+			prompt = defaultCaptionPrompt
+		}
+		input = map[string]interface{}{
+			"image":       inputImage.Data,
+			"prompt":      prompt,
+			"max_tokens":  200,
+			"temperature": 0.2,
+		}
+	default:
+		input = map[string]interface{}{
+			"image": inputImage.Data,
+			"task":  "image_captioning",
+		}
+	}
+
+	slog.Debug("captioning image", "file_path", params.ImagePath, "model", modelID)
+
+	// Create prediction
+	prediction, err := e.client.CreatePrediction(ctx, modelID, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %w", err)
+	}
+
+	// Poll for completion
+	result, err := e.pollForCompletion(ctx, nil, prediction.ID, 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	caption := captionText(result.Output)
+	if caption == "" {
+		return nil, EnhancementError{
+			Code:    "no_output",
+			Message: "the model returned an empty caption",
+			Details: map[string]interface{}{
+				"prediction_id": prediction.ID,
+			},
+		}
+	}
+
+	// Calculate metrics
+	metrics := EnhancementMetrics{
+		ProcessingTime: time.Since(startTime).Seconds(),
+	}
+	if inputInfo, err := os.Stat(params.ImagePath); err == nil {
+		metrics.InputSize = inputInfo.Size()
+	}
+
+	opResult := &types.OperationResult{
+		GenerationTime: time.Since(startTime).Seconds(),
+		PredictionID:   prediction.ID,
+	}
+	models.SetActualCost(opResult, result, modelID, 1)
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+
+	metadata := &types.ImageMetadata{
+		Version:   "1.0",
+		Operation: "caption_image",
+		Timestamp: time.Now(),
+		Model:     modelID,
+		Result:    opResult,
+	}
+	if err := e.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "prediction_id", prediction.ID, "error", err)
+	}
+
+	// Build result
+	modelInfo := models.GetModelInfo(modelID)
+	return &CaptionResult{
+		Caption:      caption,
+		InputPath:    params.ImagePath,
+		Model:        modelID,
+		ModelName:    modelInfo.Name,
+		Metrics:      metrics,
+		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+	}, nil
+}
+
+// captionText extracts the caption from a captioning model's output: a
+// string, optionally prefixed with "Caption:", or a list of streamed tokens
+func captionText(output interface{}) string {
+	var text string
+	switch v := output.(type) {
+	case string:
+		text = v
+	case []interface{}:
+		var b strings.Builder
+		for _, token := range v {
+			if s, ok := token.(string); ok {
+				b.WriteString(s)
+			}
+		}
+		text = b.String()
+	}
+	text = strings.TrimSpace(text)
+	text = strings.TrimSpace(strings.TrimPrefix(text, "Caption:"))
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,160 @@
+package enhancement
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// EstimateDepth produces a grayscale depth map of an image, brighter where the scene is nearer
+func (e *Enhancer) EstimateDepth(ctx context.Context, params DepthParams) (_ *EnhancementResult, err error) {
+	startTime := time.Now()
+
+	// Validate parameters
+	if params.ImagePath == "" {
+		return nil, EnhancementError{
+			Code:    "invalid_parameters",
+			Message: "image path is required",
+		}
+	}
+
+	// Get model ID from alias if needed
+	modelID := models.Resolve(models.OpEstimateDepth, params.Model)
+
+	// Start the operation under a unique ID
+	op, err := e.storage.StartOperation(ctx, e.debug, "estimate_depth", modelID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { op.Finish(err) }()
+	id, bundle := op.ID, op.Bundle
+
+	// Prepare the input image
+	inputImage, err := op.PrepareInput(params.ImagePath)
+	if err != nil {
+		return nil, inputError(params.ImagePath, err)
+	}
+
+	input := map[string]interface{}{
+		"image":   inputImage.Data,
+		"encoder": "vitl",
+	}
+
+	slog.Debug("estimating depth", "storage_id", id, "model", modelID)
+
+	bundle.SetInput(input)
+	bundle.Stage("prepare_input")
+
+	// Create prediction
+	prediction, err := e.client.CreatePrediction(ctx, modelID, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %w", err)
+	}
+	bundle.Record(prediction)
+	bundle.Stage("create_prediction")
+
+	// Poll for completion
+	result, err := e.pollForCompletion(ctx, bundle, prediction.ID, 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Stage("wait_for_prediction")
+
+	// Extract output URLs
+	outputURLs, err := e.extractOutputURLs(result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download and save image
+	filename := e.generateFilename(params.Filename, params.ImagePath, "depth")
+	savedFiles, err := e.storage.SaveOutputsWithRefresh(ctx, id, outputURLs, filename, client.OutputRefresher(ctx, e.client, prediction.ID))
+	partial := storage.PartialOutputs(err)
+	if err != nil && partial == nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	bundle.Stage("download_output")
+
+	// Depth Anything also returns a colorized map for viewing; the grayscale
+	// one is the result
+	primary := 0
+	if output, ok := result.Output.(map[string]interface{}); ok {
+		for i, img := range savedFiles {
+			if outputURLs[img.Index] == output["grey_depth"] {
+				primary = i
+			}
+		}
+	}
+	saved := savedFiles[primary]
+	outputPath := saved.Path
+
+	// Calculate metrics
+	inputInfo, _ := os.Stat(params.ImagePath)
+
+	metrics := EnhancementMetrics{
+		ProcessingTime: time.Since(startTime).Seconds(),
+		InputSize:      inputInfo.Size(),
+		OutputSize:     saved.Size,
+	}
+
+	// Save metadata
+	opResult := &types.OperationResult{
+		Filename:       filepath.Base(outputPath),
+		GenerationTime: time.Since(startTime).Seconds(),
+		PredictionID:   prediction.ID,
+		FileSize:       saved.Size,
+		SHA256:         saved.SHA256,
+		Files:          storage.Filenames(savedFiles),
+	}
+
+	models.SetActualCost(opResult, result, modelID, len(savedFiles))
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+
+	metadata := &types.ImageMetadata{
+		Version:   "1.0",
+		ID:        id,
+		Operation: "estimate_depth",
+		Timestamp: time.Now(),
+		Model:     modelID,
+		Parameters: map[string]interface{}{
+			"input_path": params.ImagePath,
+			"model":      params.Model,
+		},
+		Result: opResult,
+	}
+
+	if err := e.storage.SaveMetadata(id, metadata); err != nil {
+		slog.Warn("failed to save metadata", "storage_id", id, "error", err)
+	}
+	if err := e.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "storage_id", id, "error", err)
+	}
+
+	// Build result
+	modelInfo := models.GetModelInfo(modelID)
+	return &EnhancementResult{
+		ID:           id,
+		Operation:    "estimate_depth",
+		InputPath:    params.ImagePath,
+		OutputPath:   outputPath,
+		OutputURL:    saved.URL,
+		OutputPaths:  storage.Paths(savedFiles),
+		OutputURLs:   storage.URLs(savedFiles),
+		Model:        modelID,
+		ModelName:    modelInfo.Name,
+		Parameters:   input,
+		Metrics:      metrics,
+		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+		Notes:        append(inputImage.Notes, partial.Notes()...),
+	}, nil
+}
@@ -0,0 +1,151 @@
+package enhancement
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// Detect finds the objects named by a text query and returns their bounding
+// boxes. Like captions, detections produce no file, so nothing is stored; the
+// spend is still recorded in the ledger.
+func (e *Enhancer) Detect(ctx context.Context, params DetectParams) (*DetectResult, error) {
+	startTime := time.Now()
+
+	// Validate parameters
+	if params.ImagePath == "" {
+		return nil, EnhancementError{
+			Code:    "invalid_parameters",
+			Message: "image path is required",
+		}
+	}
+	if params.Query == "" {
+		return nil, EnhancementError{
+			Code:    "invalid_parameters",
+			Message: "query is required",
+		}
+	}
+	if params.Threshold <= 0 {
+		params.Threshold = 0.3
+	}
+
+	// Get model ID from alias if needed
+	modelID := models.Resolve(models.OpDetect, params.Model)
+
+	// Prepare the input image
+	inputImage, err := e.storage.PrepareInput(params.ImagePath, models.InputEdge(modelID))
+	if err != nil {
+		return nil, inputError(params.ImagePath, err)
+	}
+
+	input := map[string]interface{}{
+		"image":              inputImage.Data,
+		"query":              params.Query,
+		"box_threshold":      params.Threshold,
+		"text_threshold":     0.25,
+		"show_visualisation": false,
+	}
+
+	slog.Debug("detecting objects", "file_path", params.ImagePath, "model", modelID, "query", params.Query)
+
+	// Create prediction
+	prediction, err := e.client.CreatePrediction(ctx, modelID, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %w", err)
+	}
+
+	// Poll for completion
+	result, err := e.pollForCompletion(ctx, nil, prediction.ID, 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// Boxes refer to the uploaded image, which may have been downscaled
+	scale := 1.0
+	if inputImage.Resized && inputImage.Width > 0 {
+		scale = float64(inputImage.OriginalWidth) / float64(inputImage.Width)
+	}
+	detections := parseDetections(result.Output, scale)
+
+	// Calculate metrics
+	metrics := EnhancementMetrics{
+		ProcessingTime: time.Since(startTime).Seconds(),
+	}
+	if inputInfo, err := os.Stat(params.ImagePath); err == nil {
+		metrics.InputSize = inputInfo.Size()
+	}
+
+	opResult := &types.OperationResult{
+		GenerationTime: time.Since(startTime).Seconds(),
+		PredictionID:   prediction.ID,
+	}
+	models.SetActualCost(opResult, result, modelID, 1)
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+
+	metadata := &types.ImageMetadata{
+		Version:   "1.0",
+		Operation: "detect_objects",
+		Timestamp: time.Now(),
+		Model:     modelID,
+		Result:    opResult,
+	}
+	if err := e.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "prediction_id", prediction.ID, "error", err)
+	}
+
+	// Build result
+	modelInfo := models.GetModelInfo(modelID)
+	return &DetectResult{
+		Detections:   detections,
+		InputPath:    params.ImagePath,
+		Model:        modelID,
+		ModelName:    modelInfo.Name,
+		Metrics:      metrics,
+		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+	}, nil
+}
+
+// parseDetections reads Grounding DINO's output, {"detections": [{"bbox":
+// [x1, y1, x2, y2], "label": ..., "confidence": ...}]}, scaling boxes by
+// scale and sorting them most confident first
+func parseDetections(output interface{}, scale float64) []Detection {
+	obj, _ := output.(map[string]interface{})
+	raw, _ := obj["detections"].([]interface{})
+
+	var detections []Detection
+	for _, item := range raw {
+		d, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		bbox, _ := d["bbox"].([]interface{})
+		if len(bbox) != 4 {
+			continue
+		}
+		var coords [4]int
+		for i, v := range bbox {
+			f, _ := v.(float64)
+			coords[i] = int(f*scale + 0.5)
+		}
+		box := image.Rect(coords[0], coords[1], coords[2], coords[3])
+		if box.Empty() {
+			continue
+		}
+		label, _ := d["label"].(string)
+		confidence, _ := d["confidence"].(float64)
+		detections = append(detections, Detection{Label: label, Confidence: confidence, Box: box})
+	}
+	sort.SliceStable(detections, func(i, j int) bool {
+		return detections[i].Confidence > detections[j].Confidence
+	})
+	return detections
+}
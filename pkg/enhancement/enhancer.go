@@ -1,31 +1,30 @@
 package enhancement
 
 import (
-	"log"
-
 	"github.com/gomcpgo/replicate_image_ai/pkg/client"
 	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 )
 
 // Enhancer handles image enhancement operations
 type Enhancer struct {
-	client  *client.ReplicateClient
-	storage *storage.Storage
-	debug   bool
+	client   client.Predictor
+	storage  *storage.Storage
+	timeouts client.Timeouts
+	debug    bool
 }
 
 // NewEnhancer creates a new Enhancer instance
-func NewEnhancer(client *client.ReplicateClient, storage *storage.Storage, debug bool) *Enhancer {
-	return &Enhancer{
-		client:  client,
-		storage: storage,
-		debug:   debug,
-	}
+func NewEnhancer(predictor client.Predictor, storage *storage.Storage, debug bool) *Enhancer {
+	return NewEnhancerWithTimeouts(predictor, storage, debug, client.Timeouts{})
 }
 
-// logDebug logs debug messages if debug mode is enabled
-func (e *Enhancer) logDebug(format string, args ...interface{}) {
-	if e.debug {
-		log.Printf(format, args...)
+// NewEnhancerWithTimeouts creates an Enhancer polling its predictions with
+// timeouts instead of the defaults
+func NewEnhancerWithTimeouts(predictor client.Predictor, storage *storage.Storage, debug bool, timeouts client.Timeouts) *Enhancer {
+	return &Enhancer{
+		client:   predictor,
+		storage:  storage,
+		timeouts: timeouts,
+		debug:    debug,
 	}
-}
\ No newline at end of file
+}
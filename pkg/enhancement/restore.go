@@ -3,15 +3,19 @@ package enhancement
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
 	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 	"github.com/gomcpgo/replicate_image_ai/pkg/types"
 )
 
 // RestorePhoto restores old or damaged photos
-func (e *Enhancer) RestorePhoto(ctx context.Context, params RestorePhotoParams) (*EnhancementResult, error) {
+func (e *Enhancer) RestorePhoto(ctx context.Context, params RestorePhotoParams) (_ *EnhancementResult, err error) {
 	startTime := time.Now()
 	
 	// Validate parameters
@@ -28,73 +32,85 @@ func (e *Enhancer) RestorePhoto(ctx context.Context, params RestorePhotoParams)
 	}
 	
 	// Get model ID from alias if needed
-	modelID := GetModelFromAlias("restore_photo", params.Model)
+	modelID := models.Resolve(models.OpRestorePhoto, params.Model)
 	
-	// Generate unique ID for this operation
-	id, err := e.storage.GenerateID()
+	// Start the operation under a unique ID
+	op, err := e.storage.StartOperation(ctx, e.debug, "restore_photo", modelID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate ID: %w", err)
+		return nil, err
 	}
+	defer func() { op.Finish(err) }()
+	id, bundle := op.ID, op.Bundle
 	
-	// Convert image to base64 data URL
-	dataURL, err := storage.ImageToBase64(params.ImagePath)
+	// Prepare the input image
+	inputImage, err := op.PrepareInput(params.ImagePath)
 	if err != nil {
-		return nil, EnhancementError{
-			Code:    "file_error",
-			Message: fmt.Sprintf("failed to load image: %v", err),
-			Details: map[string]interface{}{
-				"file_path": params.ImagePath,
-			},
-		}
+		return nil, inputError(params.ImagePath, err)
 	}
 	
 	// Build input parameters based on model
-	input := e.buildRestoreInput(modelID, dataURL, params)
+	input := e.buildRestoreInput(modelID, inputImage.Data, params)
+	
+	slog.Debug("restoring photo", "storage_id", id, "model", modelID)
 	
-	e.logDebug("Restoring photo with model %s", modelID)
+	bundle.SetInput(input)
+	bundle.Stage("prepare_input")
 	
 	// Create prediction
 	prediction, err := e.client.CreatePrediction(ctx, modelID, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prediction: %w", err)
 	}
+	bundle.Record(prediction)
+	bundle.Stage("create_prediction")
 	
 	// Poll for completion (restoration can take longer)
-	result, err := e.pollForCompletion(ctx, prediction.ID, 60, 2*time.Second)
+	result, err := e.pollForCompletion(ctx, bundle, prediction.ID, 2*time.Minute)
 	if err != nil {
 		return nil, err
 	}
+	bundle.Stage("wait_for_prediction")
 	
-	// Extract output URL
-	outputURL, err := e.extractOutputURL(result)
+	// Extract output URLs
+	outputURLs, err := e.extractOutputURLs(result)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Download and save image
 	filename := e.generateFilename(params.Filename, params.ImagePath, "restored")
-	outputPath, err := e.storage.SaveImage(id, outputURL, filename)
-	if err != nil {
+	savedFiles, err := e.storage.SaveOutputsWithRefresh(ctx, id, outputURLs, filename, client.OutputRefresher(ctx, e.client, prediction.ID))
+	partial := storage.PartialOutputs(err)
+	if err != nil && partial == nil {
 		return nil, fmt.Errorf("failed to save image: %w", err)
 	}
+	bundle.Stage("download_output")
+	saved := savedFiles[0]
+	outputPath := saved.Path
 	
 	// Calculate metrics
 	inputInfo, _ := os.Stat(params.ImagePath)
-	outputInfo, _ := os.Stat(outputPath)
 	
 	metrics := EnhancementMetrics{
 		ProcessingTime: time.Since(startTime).Seconds(),
 		InputSize:      inputInfo.Size(),
-		OutputSize:     outputInfo.Size(),
+		OutputSize:     saved.Size,
 	}
 	
 	// Save metadata
 	opResult := &types.OperationResult{
-		Filename:       filename,
+		Filename:       filepath.Base(outputPath),
 		GenerationTime: time.Since(startTime).Seconds(),
 		PredictionID:   prediction.ID,
+		FileSize:       saved.Size,
+		SHA256:         saved.SHA256,
+		Files:          storage.Filenames(savedFiles),
 	}
 	
+	models.SetActualCost(opResult, result, modelID, len(savedFiles))
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+	
 	metadata := &types.ImageMetadata{
 		Version:   "1.0",
 		ID:        id,
@@ -113,49 +129,56 @@ func (e *Enhancer) RestorePhoto(ctx context.Context, params RestorePhotoParams)
 	}
 	
 	if err := e.storage.SaveMetadata(id, metadata); err != nil {
-		e.logDebug("Failed to save metadata: %v", err)
+		slog.Warn("failed to save metadata", "storage_id", id, "error", err)
+	}
+	if err := e.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "storage_id", id, "error", err)
 	}
 	
 	// Build result
-	modelInfo := GetModelInfo(modelID)
+	modelInfo := models.GetModelInfo(modelID)
 	return &EnhancementResult{
 		ID:           id,
 		Operation:    "restore_photo",
 		InputPath:    params.ImagePath,
 		OutputPath:   outputPath,
-		OutputURL:    outputURL,
+		OutputURL:    saved.URL,
+		OutputPaths:  storage.Paths(savedFiles),
+		OutputURLs:   storage.URLs(savedFiles),
 		Model:        modelID,
 		ModelName:    modelInfo.Name,
 		Parameters:   input,
 		Metrics:      metrics,
 		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+		Notes:        append(inputImage.Notes, partial.Notes()...),
 	}, nil
 }
 
 // buildRestoreInput builds input parameters for photo restoration
-func (e *Enhancer) buildRestoreInput(modelID string, dataURL string, params RestorePhotoParams) map[string]interface{} {
+func (e *Enhancer) buildRestoreInput(modelID string, image *types.FileData, params RestorePhotoParams) map[string]interface{} {
 	switch modelID {
-	case ModelBOPBTL:
+	case models.ModelOldPhotoRestore:
 		input := map[string]interface{}{
-			"image":            dataURL,
+			"image":            image,
 			"HR":               true, // High resolution
 			"with_scratch":     params.ScratchRemoval,
 		}
 		return input
 		
-	case ModelGFPGAN:
+	case models.ModelGFPGAN:
 		// When used for restoration
 		input := map[string]interface{}{
-			"img":     dataURL,
+			"img":     image,
 			"version": "v1.4",
 			"scale":   2,
 		}
 		return input
 		
-	case ModelCodeFormer:
+	case models.ModelCodeFormer:
 		// When used for restoration
 		input := map[string]interface{}{
-			"image":               dataURL,
+			"image":               image,
 			"codeformer_fidelity": params.Fidelity,
 			"upscale":             2,
 			"background_enhance":  true,
@@ -167,7 +190,7 @@ func (e *Enhancer) buildRestoreInput(modelID string, dataURL string, params Rest
 		
 	default:
 		return map[string]interface{}{
-			"image": dataURL,
+			"image": image,
 		}
 	}
 }
\ No newline at end of file
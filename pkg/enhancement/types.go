@@ -1,6 +1,10 @@
 package enhancement
 
-import "time"
+import (
+	"fmt"
+	"image"
+	"time"
+)
 
 // RemoveBackgroundParams contains parameters for background removal
 type RemoveBackgroundParams struct {
@@ -41,18 +45,88 @@ type RestorePhotoParams struct {
 	Filename       string  // Optional output filename
 }
 
+// ColorizeParams contains parameters for colorizing black and white photos
+type ColorizeParams struct {
+	ImagePath string
+	Model     string // ddcolor
+	ModelSize string // large (default) or tiny
+	Filename  string // Optional output filename
+}
+
+// DepthParams contains parameters for depth estimation
+type DepthParams struct {
+	ImagePath string
+	Model     string // depth-anything
+	Filename  string // Optional output filename
+}
+
+// VectorizeParams contains parameters for raster to SVG conversion
+type VectorizeParams struct {
+	ImagePath string
+	Model     string // recraft
+	Filename  string // Optional output filename
+}
+
+// CaptionParams contains parameters for image captioning
+type CaptionParams struct {
+	ImagePath string
+	Model     string // blip, llava
+	Prompt    string // Instruction for models that take one (llava)
+}
+
+// CaptionResult contains the caption produced for an image
+type CaptionResult struct {
+	Caption      string
+	InputPath    string
+	Model        string
+	ModelName    string
+	Metrics      EnhancementMetrics
+	PredictionID string
+	Provider     string
+}
+
+// DetectParams contains parameters for object detection
+type DetectParams struct {
+	ImagePath string
+	Model     string  // grounding-dino
+	Query     string  // What to find, e.g. "face" or "dog . cat"
+	Threshold float64 // Minimum box confidence (default 0.3)
+}
+
+// Detection is one object found in an image
+type Detection struct {
+	Label      string
+	Confidence float64
+	Box        image.Rectangle // In the source image's pixel coordinates
+}
+
+// DetectResult contains the objects found in an image, most confident first
+type DetectResult struct {
+	Detections   []Detection
+	InputPath    string
+	Model        string
+	ModelName    string
+	Metrics      EnhancementMetrics
+	PredictionID string
+	Provider     string
+}
+
 // EnhancementResult contains the result of an enhancement operation
 type EnhancementResult struct {
 	ID           string
-	Operation    string // "remove_background", "upscale", "enhance_face", "restore_photo"
+	Operation    string // "remove_background", "upscale", "enhance_face", "restore_photo", "colorize_image", "estimate_depth", "vectorize_image"
 	InputPath    string
 	OutputPath   string
 	OutputURL    string
+	OutputPaths  []string // Every saved file when the model returns several
+	OutputURLs   []string
 	Model        string
 	ModelName    string
 	Parameters   map[string]interface{}
 	Metrics      EnhancementMetrics
 	PredictionID string
+	Provider     string   // Provider that ran the prediction
+	Notes        []string // Notes about adjustments made to the input
 }
 
 // EnhancementMetrics contains performance metrics
@@ -61,6 +135,8 @@ type EnhancementMetrics struct {
 	InputSize      int64   // in bytes
 	OutputSize     int64   // in bytes
 	ScaleFactor    int     // For upscaling
+	PredictTime    float64 // Billed model time in seconds
+	Cost           float64 // Actual cost in USD
 }
 
 // EnhancementError represents an error during enhancement
@@ -74,6 +150,17 @@ func (e EnhancementError) Error() string {
 	return e.Message
 }
 
+// inputError reports an input image that could not be loaded
+func inputError(path string, err error) error {
+	return EnhancementError{
+		Code:    "file_error",
+		Message: fmt.Sprintf("failed to load image: %v", err),
+		Details: map[string]interface{}{
+			"file_path": path,
+		},
+	}
+}
+
 // EnhancementMetadata contains metadata about an enhanced image
 type EnhancementMetadata struct {
 	Version    string                 `json:"version"`
@@ -83,4 +170,4 @@ type EnhancementMetadata struct {
 	Model      string                 `json:"model"`
 	Parameters map[string]interface{} `json:"parameters"`
 	Result     interface{}            `json:"result,omitempty"`
-}
\ No newline at end of file
+}
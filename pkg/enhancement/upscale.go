@@ -3,15 +3,19 @@ package enhancement
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
 	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 	"github.com/gomcpgo/replicate_image_ai/pkg/types"
 )
 
 // UpscaleImage upscales an image to higher resolution
-func (e *Enhancer) UpscaleImage(ctx context.Context, params UpscaleParams) (*EnhancementResult, error) {
+func (e *Enhancer) UpscaleImage(ctx context.Context, params UpscaleParams) (_ *EnhancementResult, err error) {
 	startTime := time.Now()
 	
 	// Validate parameters
@@ -27,74 +31,86 @@ func (e *Enhancer) UpscaleImage(ctx context.Context, params UpscaleParams) (*Enh
 	}
 	
 	// Get model ID from alias if needed
-	modelID := GetModelFromAlias("upscale", params.Model)
+	modelID := models.Resolve(models.OpUpscale, params.Model)
 	
-	// Generate unique ID for this operation
-	id, err := e.storage.GenerateID()
+	// Start the operation under a unique ID
+	op, err := e.storage.StartOperation(ctx, e.debug, "upscale_image", modelID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate ID: %w", err)
+		return nil, err
 	}
+	defer func() { op.Finish(err) }()
+	id, bundle := op.ID, op.Bundle
 	
-	// Convert image to base64 data URL
-	dataURL, err := storage.ImageToBase64(params.ImagePath)
+	// Prepare the input image
+	inputImage, err := op.PrepareInput(params.ImagePath)
 	if err != nil {
-		return nil, EnhancementError{
-			Code:    "file_error",
-			Message: fmt.Sprintf("failed to load image: %v", err),
-			Details: map[string]interface{}{
-				"file_path": params.ImagePath,
-			},
-		}
+		return nil, inputError(params.ImagePath, err)
 	}
 	
 	// Build input parameters based on model
-	input := e.buildUpscaleInput(modelID, dataURL, params)
+	input := e.buildUpscaleInput(modelID, inputImage.Data, params)
+	
+	slog.Debug("upscaling image", "storage_id", id, "model", modelID, "scale", params.Scale)
 	
-	e.logDebug("Upscaling image with model %s, scale %dx", modelID, params.Scale)
+	bundle.SetInput(input)
+	bundle.Stage("prepare_input")
 	
 	// Create prediction
 	prediction, err := e.client.CreatePrediction(ctx, modelID, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prediction: %w", err)
 	}
+	bundle.Record(prediction)
+	bundle.Stage("create_prediction")
 	
 	// Poll for completion (upscaling can take longer)
-	result, err := e.pollForCompletion(ctx, prediction.ID, 60, 2*time.Second)
+	result, err := e.pollForCompletion(ctx, bundle, prediction.ID, 2*time.Minute)
 	if err != nil {
 		return nil, err
 	}
+	bundle.Stage("wait_for_prediction")
 	
-	// Extract output URL
-	outputURL, err := e.extractOutputURL(result)
+	// Extract output URLs
+	outputURLs, err := e.extractOutputURLs(result)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Download and save image
 	filename := e.generateFilename(params.Filename, params.ImagePath, fmt.Sprintf("upscaled_%dx", params.Scale))
-	outputPath, err := e.storage.SaveImage(id, outputURL, filename)
-	if err != nil {
+	savedFiles, err := e.storage.SaveOutputsWithRefresh(ctx, id, outputURLs, filename, client.OutputRefresher(ctx, e.client, prediction.ID))
+	partial := storage.PartialOutputs(err)
+	if err != nil && partial == nil {
 		return nil, fmt.Errorf("failed to save image: %w", err)
 	}
+	bundle.Stage("download_output")
+	saved := savedFiles[0]
+	outputPath := saved.Path
 	
 	// Calculate metrics
 	inputInfo, _ := os.Stat(params.ImagePath)
-	outputInfo, _ := os.Stat(outputPath)
 	
 	metrics := EnhancementMetrics{
 		ProcessingTime: time.Since(startTime).Seconds(),
 		InputSize:      inputInfo.Size(),
-		OutputSize:     outputInfo.Size(),
+		OutputSize:     saved.Size,
 		ScaleFactor:    params.Scale,
 	}
 	
 	// Save metadata
 	opResult := &types.OperationResult{
-		Filename:       filename,
+		Filename:       filepath.Base(outputPath),
 		GenerationTime: time.Since(startTime).Seconds(),
 		PredictionID:   prediction.ID,
+		FileSize:       saved.Size,
+		SHA256:         saved.SHA256,
+		Files:          storage.Filenames(savedFiles),
 	}
 	
+	models.SetActualCost(opResult, result, modelID, len(savedFiles))
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+	
 	metadata := &types.ImageMetadata{
 		Version:   "1.0",
 		ID:        id,
@@ -111,31 +127,38 @@ func (e *Enhancer) UpscaleImage(ctx context.Context, params UpscaleParams) (*Enh
 	}
 	
 	if err := e.storage.SaveMetadata(id, metadata); err != nil {
-		e.logDebug("Failed to save metadata: %v", err)
+		slog.Warn("failed to save metadata", "storage_id", id, "error", err)
+	}
+	if err := e.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "storage_id", id, "error", err)
 	}
 	
 	// Build result
-	modelInfo := GetModelInfo(modelID)
+	modelInfo := models.GetModelInfo(modelID)
 	return &EnhancementResult{
 		ID:           id,
 		Operation:    "upscale_image",
 		InputPath:    params.ImagePath,
 		OutputPath:   outputPath,
-		OutputURL:    outputURL,
+		OutputURL:    saved.URL,
+		OutputPaths:  storage.Paths(savedFiles),
+		OutputURLs:   storage.URLs(savedFiles),
 		Model:        modelID,
 		ModelName:    modelInfo.Name,
 		Parameters:   input,
 		Metrics:      metrics,
 		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+		Notes:        append(inputImage.Notes, partial.Notes()...),
 	}, nil
 }
 
 // buildUpscaleInput builds input parameters for upscaling
-func (e *Enhancer) buildUpscaleInput(modelID string, dataURL string, params UpscaleParams) map[string]interface{} {
+func (e *Enhancer) buildUpscaleInput(modelID string, image *types.FileData, params UpscaleParams) map[string]interface{} {
 	switch modelID {
-	case ModelRealESRGAN:
+	case models.ModelRealESRGAN:
 		input := map[string]interface{}{
-			"img":   dataURL,
+			"img":   image,
 			"scale": params.Scale,
 		}
 		if params.FaceEnhance {
@@ -143,22 +166,35 @@ func (e *Enhancer) buildUpscaleInput(modelID string, dataURL string, params Upsc
 		}
 		return input
 		
-	case ModelESRGAN:
+	case models.ModelESRGAN:
 		return map[string]interface{}{
-			"image": dataURL,
+			"image": image,
 			"scale": params.Scale,
 		}
 		
-	case ModelSwinIR:
+	case models.ModelSwinIR:
 		return map[string]interface{}{
-			"image": dataURL,
+			"image": image,
 			"task_type": "Real-World Image Super-Resolution",
 			"scale": params.Scale,
 		}
 		
+	case models.ModelClarityUpscaler:
+		return map[string]interface{}{
+			"image":         image,
+			"scale_factor":  params.Scale,
+			"output_format": "png",
+		}
+		
+	case models.ModelSUPIR:
+		return map[string]interface{}{
+			"image":   image,
+			"upscale": params.Scale,
+		}
+		
 	default:
 		return map[string]interface{}{
-			"image": dataURL,
+			"image": image,
 			"scale": params.Scale,
 		}
 	}
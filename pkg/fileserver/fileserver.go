@@ -0,0 +1,155 @@
+package fileserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tokenLength is the number of hex characters of the HMAC kept in each URL
+const tokenLength = 32
+
+// Server serves files under the storage root over HTTP. Every file gets its
+// own unguessable URL, /<token>/<path>, where the token is an HMAC of the path;
+// sharing one URL exposes only that file, and the root cannot be listed.
+type Server struct {
+	root    string
+	baseURL string
+	secret  []byte
+	server  *http.Server
+}
+
+// New creates a file server for root. baseURL is the address clients reach it
+// at (it defaults to the listen address); an empty secret picks a random one,
+// so URLs stop working when the server restarts.
+func New(root, addr, baseURL, secret string) (*Server, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate file server secret: %w", err)
+		}
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage root: %w", err)
+	}
+
+	if baseURL == "" {
+		host := addr
+		if strings.HasPrefix(host, ":") {
+			host = "localhost" + host
+		}
+		baseURL = "http://" + host
+	}
+
+	s := &Server{
+		root:    absRoot,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  key,
+	}
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// Start listens on the configured address and serves in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start file server: %w", err)
+	}
+	slog.Info("file server started", "addr", listener.Addr().String(), "base_url", s.baseURL)
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("file server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Close stops the server
+func (s *Server) Close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
+
+// URL returns the shareable URL of a file, or "" if the server is disabled or
+// the file is outside the storage root
+func (s *Server) URL(filePath string) string {
+	if s == nil || filePath == "" {
+		return ""
+	}
+
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(s.root, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+
+	urlPath := filepath.ToSlash(rel)
+	segments := strings.Split(urlPath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return fmt.Sprintf("%s/%s/%s", s.baseURL, s.token(urlPath), strings.Join(segments, "/"))
+}
+
+// ServeHTTP serves a file whose URL token matches its path
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, urlPath, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || urlPath == "" || path.Clean("/"+urlPath) != "/"+urlPath {
+		http.NotFound(w, r)
+		return
+	}
+	if !hmac.Equal([]byte(token), []byte(s.token(urlPath))) {
+		http.NotFound(w, r)
+		return
+	}
+
+	filePath := filepath.Join(s.root, filepath.FromSlash(urlPath))
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	slog.Debug("serving file", "path", urlPath, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeFile(w, r, filePath)
+}
+
+// token returns the URL token for a slash-separated path under the root
+func (s *Server) token(urlPath string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(urlPath))
+	return hex.EncodeToString(mac.Sum(nil))[:tokenLength]
+}
@@ -0,0 +1,92 @@
+package generation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// lookupCached returns a previous result for an identical request, or nil when
+// there is none. The cache key is returned so the caller can store a fresh result.
+func (g *Generator) lookupCached(operation, modelID, prompt string, input map[string]interface{}, startTime time.Time) (*ImageResult, string) {
+	key, err := storage.CacheKey(operation, modelID, input)
+	if err != nil {
+		slog.Warn("failed to compute cache key", "operation", operation, "error", err)
+		return nil, ""
+	}
+
+	cached, ok := g.storage.LookupCache(key)
+	if !ok {
+		return nil, key
+	}
+
+	metadata := cached.Metadata
+	slog.Debug("serving cached result", "operation", operation, "storage_id", metadata.ID, "cache_key", key)
+
+	var fileSize int64
+	if info, err := os.Stat(cached.FilePaths[0]); err == nil {
+		fileSize = info.Size()
+	}
+
+	// Delivery URLs from the original prediction have expired; only local files are returned
+	modelInfo := models.GetModelInfo(modelID)
+	return &ImageResult{
+		ID:         metadata.ID,
+		FilePath:   cached.FilePaths[0],
+		FilePaths:  cached.FilePaths,
+		Model:      modelID,
+		ModelName:  modelInfo.Name,
+		Prompt:     prompt,
+		Parameters: input,
+		Metrics: GenerationMetrics{
+			GenerationTime: time.Since(startTime).Seconds(),
+			FileSize:       fileSize,
+		},
+		PredictionID: metadata.Result.PredictionID,
+		Provider:     metadata.Result.Provider,
+		Cached:       true,
+	}, key
+}
+
+// cacheOperation names an operation in cache keys. Outputs re-encoded after
+// saving depend on the requested encoding as well as the model input, so it
+// is part of the key when set.
+func cacheOperation(operation, format string, quality int) string {
+	if format != "" {
+		operation += "." + format
+	}
+	if quality > 0 {
+		operation += fmt.Sprintf("@%d", quality)
+	}
+	return operation
+}
+
+// newID returns the storage ID of a new operation: derived from the request
+// when deterministic IDs are asked for, so reruns of a request share a
+// directory, or random otherwise. Call release once the operation is done.
+func (g *Generator) newID(ctx context.Context, deterministic bool, operation, modelID string, input map[string]interface{}) (id string, release func(), err error) {
+	if !deterministic {
+		id, err = g.storage.OperationID(ctx)
+		return id, func() {}, err
+	}
+	key, err := storage.CacheKey(operation, modelID, input)
+	if err != nil {
+		return "", nil, err
+	}
+	return g.storage.DerivedID(key)
+}
+
+// storeCached records a completed result under its cache key
+func (g *Generator) storeCached(key, id string) {
+	if key == "" {
+		return
+	}
+	if err := g.storage.StoreCache(key, id); err != nil {
+		slog.Warn("failed to update result cache", "storage_id", id, "error", err)
+	}
+}
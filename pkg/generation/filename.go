@@ -0,0 +1,169 @@
+package generation
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultFilenameTemplate names generated images after their prompt and model
+const DefaultFilenameTemplate = "{prompt}_{model}"
+
+// PrivateFilenameTemplate names generated images without revealing their
+// prompt, for when prompts are kept private
+const PrivateFilenameTemplate = "{model}_{timestamp}"
+
+// maxPromptSlug is the longest the prompt part of a filename gets
+const maxPromptSlug = 50
+
+// filenamePlaceholder matches one {field} of a filename template
+var filenamePlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// filenameFields are the values a filename template can use
+type filenameFields struct {
+	Prompt string
+	Model  string // Full model ID; only its name is used
+	Seed   int    // Zero when no seed was requested
+	ID     string // Storage ID
+}
+
+// ValidateFilenameTemplate checks that a template only uses known fields:
+// {prompt}, {model}, {seed}, {timestamp}, {date}, and {id}
+func ValidateFilenameTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("filename template is empty")
+	}
+	if strings.ContainsAny(template, `/\`) {
+		return fmt.Errorf("filename template %q must not contain path separators", template)
+	}
+	for _, match := range filenamePlaceholder.FindAllStringSubmatch(template, -1) {
+		switch match[1] {
+		case "prompt", "model", "seed", "timestamp", "date", "id":
+		default:
+			return fmt.Errorf("filename template %q has unknown field {%s} (use prompt, model, seed, timestamp, date, or id)", template, match[1])
+		}
+	}
+	return nil
+}
+
+// SetFilenameTemplate sets the template naming generated images that have
+// no filename hint; empty restores DefaultFilenameTemplate
+func (g *Generator) SetFilenameTemplate(template string) error {
+	if template == "" {
+		template = DefaultFilenameTemplate
+	}
+	if err := ValidateFilenameTemplate(template); err != nil {
+		return err
+	}
+	g.filenameTemplate = template
+	return nil
+}
+
+// expandFilenameTemplate fills in a filename template. Fields without a
+// value, such as {seed} when no seed was requested, are dropped together
+// with the separators around them.
+func expandFilenameTemplate(template string, fields filenameFields, now time.Time) string {
+	modelName := strings.Split(filepath.Base(fields.Model), ":")[0]
+	name := filenamePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch strings.Trim(placeholder, "{}") {
+		case "prompt":
+			if slug := slugify(fields.Prompt, maxPromptSlug); slug != "" {
+				return slug
+			}
+			return "generated"
+		case "model":
+			return modelName
+		case "seed":
+			if fields.Seed == 0 {
+				return ""
+			}
+			return strconv.Itoa(fields.Seed)
+		case "timestamp":
+			return now.Format("20060102-150405")
+		case "date":
+			return now.Format("2006-01-02")
+		case "id":
+			return fields.ID
+		}
+		return ""
+	})
+	if name = tidySeparators(name); name == "" {
+		name = "generated"
+	}
+	return name
+}
+
+// slugify lowercases s, transliterates accented Latin, Greek, and Cyrillic
+// letters to ASCII, turns spaces into underscores, and drops everything else
+// a filename should not hold. With max > 0 it cuts the result at a word
+// boundary no longer than max.
+func slugify(s string, max int) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'):
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		default:
+			b.WriteString(transliterations[r])
+		}
+	}
+	slug := tidySeparators(b.String())
+	if max > 0 && len(slug) > max {
+		slug = slug[:max]
+		if cut := strings.LastIndexByte(slug, '_'); cut > max/2 {
+			slug = slug[:cut]
+		}
+		slug = tidySeparators(slug)
+	}
+	return slug
+}
+
+// repeatedSeparators matches runs of separators left by dropped characters
+var repeatedSeparators = regexp.MustCompile(`[_.-]*[_-][_.-]*`)
+
+// tidySeparators collapses separator runs to their first underscore or
+// hyphen and trims them from the ends
+func tidySeparators(s string) string {
+	s = repeatedSeparators.ReplaceAllStringFunc(s, func(run string) string {
+		return string(run[strings.IndexAny(run, "_-")])
+	})
+	return strings.Trim(s, "_.-")
+}
+
+// transliterations spell non-ASCII letters in ASCII
+var transliterations = map[rune]string{
+	// Latin
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ĉ': "c", 'ċ': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ĝ': "g", 'ġ': "g", 'ģ': "g", 'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i", 'ĵ': "j", 'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ł': "l", 'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ș': "s", 'ŝ': "s", 'ß': "ss",
+	'ť': "t", 'ţ': "t", 'ț': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+
+	// Greek
+	'α': "a", 'ά': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'έ': "e", 'ζ': "z", 'η': "i", 'ή': "i",
+	'θ': "th", 'ι': "i", 'ί': "i", 'ϊ': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'ό': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'ύ': "y", 'ϋ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o", 'ώ': "o",
+
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'ё': "e", 'є': "ye",
+	'ж': "zh", 'з': "z", 'и': "i", 'і': "i", 'ї': "yi", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ў': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya",
+
+	// Punctuation that separates words
+	'–': "-", '—': "-", '’': "", '‘': "", '“': "", '”': "", '«': "", '»': "", '·': "_",
+}
@@ -3,35 +3,54 @@ package generation
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/moderation"
 	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 	"github.com/gomcpgo/replicate_image_ai/pkg/types"
 )
 
 // Generator handles image generation operations
 type Generator struct {
-	client  *client.ReplicateClient
-	storage *storage.Storage
-	debug   bool
+	client    client.Predictor
+	storage   *storage.Storage
+	screener  *moderation.Screener // Nil unless output moderation is configured
+	negatives *NegativePresets     // Negative prompt presets and per-family defaults
+	timeouts  client.Timeouts
+	debug     bool
+	
+	filenameTemplate string // Names images without a filename hint; see SetFilenameTemplate
 }
 
 // NewGenerator creates a new Generator instance
-func NewGenerator(client *client.ReplicateClient, storage *storage.Storage, debug bool) *Generator {
+func NewGenerator(predictor client.Predictor, storage *storage.Storage, debug bool) *Generator {
+	return NewGeneratorWithTimeouts(predictor, storage, debug, client.Timeouts{})
+}
+
+// NewGeneratorWithTimeouts creates a Generator polling its predictions with
+// timeouts instead of the defaults
+func NewGeneratorWithTimeouts(predictor client.Predictor, storage *storage.Storage, debug bool, timeouts client.Timeouts) *Generator {
 	return &Generator{
-		client:  client,
-		storage: storage,
-		debug:   debug,
+		client:    predictor,
+		storage:   storage,
+		negatives: DefaultNegativePresets(),
+		timeouts:  timeouts,
+		debug:     debug,
 	}
 }
 
+// SetScreener screens every generated output with s before it is saved
+func (g *Generator) SetScreener(s *moderation.Screener) {
+	g.screener = s
+}
+
 // GenerateImage generates an image using the specified model and parameters
-func (g *Generator) GenerateImage(ctx context.Context, params GenerateParams) (*ImageResult, error) {
+func (g *Generator) GenerateImage(ctx context.Context, params GenerateParams) (_ *ImageResult, err error) {
 	startTime := time.Now()
 	
 	// Validate parameters
@@ -42,56 +61,102 @@ func (g *Generator) GenerateImage(ctx context.Context, params GenerateParams) (*
 		}
 	}
 	
+	// Translate a non-English prompt before anything else sees it
+	var translation *Translation
+	var notes []string
+	if params.TranslatePrompt {
+		var note string
+		if params.Prompt, translation, note = g.translatePrompt(ctx, params.Prompt); note != "" {
+			notes = append(notes, note)
+		}
+	}
+	
 	// Get model ID from alias if needed
-	modelID := GetModelFromAlias(params.Model)
+	modelID := models.Resolve(models.OpGenerate, params.Model)
+	if modelID, err = resolveImagen(modelID, params); err != nil {
+		return nil, err
+	}
+	if err := checkSeedream(modelID, params); err != nil {
+		return nil, err
+	}
 	
-	// Generate unique ID for this operation
-	id, err := g.storage.GenerateID()
+	if err := g.ApplyNegativePreset(&params); err != nil {
+		return nil, err
+	}
+	sized, err := applySizePreset(modelID, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate ID: %w", err)
+		return nil, err
 	}
 	
 	// Build input parameters based on model type
-	input := g.buildInputParams(params, modelID)
+	input := g.buildInputParams(sized, modelID)
 	
-	if g.debug {
-		log.Printf("Generating image with model %s", modelID)
-		log.Printf("Parameters: %+v", input)
+	// Serve an identical earlier request from the cache
+	var cacheKey string
+	operation := cacheOperation("generate_image", params.OutputFormat, params.OutputQuality)
+	if params.UseCache {
+		var cached *ImageResult
+		if cached, cacheKey = g.lookupCached(operation, modelID, params.Prompt, input, startTime); cached != nil {
+			cached.Parameters = responseParameters(input, params)
+			return cached, nil
+		}
+	}
+	
+	// Generate unique ID for this operation
+	id, release, err := g.newID(ctx, params.DeterministicID, operation, modelID, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ID: %w", err)
 	}
+	defer release()
+	op := g.storage.OpenOperation(id, g.debug, "generate_image", modelID)
+	defer func() { op.Finish(err) }()
+	bundle := op.Bundle
+	
+	slog.Debug("generating image", "storage_id", id, "model", modelID, "input", input)
+	
+	bundle.SetInput(input)
+	bundle.Stage("prepare_input")
 	
 	// Create prediction
 	prediction, err := g.client.CreatePrediction(ctx, modelID, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prediction: %w", err)
 	}
+	bundle.Record(prediction)
+	bundle.Stage("create_prediction")
 	
 	// Poll for completion
-	const maxAttempts = 60
-	const pollInterval = 2 * time.Second
+	poll := g.timeouts.NewPoll(ctx, 2*time.Minute)
 	
 	var result *types.ReplicatePredictionResponse
-	for i := 0; i < maxAttempts; i++ {
+	for poll.Next() {
 		result, err = g.client.GetPrediction(ctx, prediction.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get prediction status: %w", err)
 		}
+		bundle.Record(result)
 		
 		if result.Status == "succeeded" {
 			break
 		}
 		
 		if result.Status == "failed" || result.Status == "canceled" {
+			if blockedErr := contentBlockedError(result, modelID, input); blockedErr != nil {
+				return nil, blockedErr
+			}
+			code, message := client.ClassifyFailure(result.Error, result.Logs)
+			if code == "" {
+				code = "generation_failed"
+			}
 			return nil, GenerationError{
-				Code:    "generation_failed",
-				Message: fmt.Sprintf("Generation %s: %v", result.Status, result.Error),
+				Code:    code,
+				Message: fmt.Sprintf("Generation %s: %s", result.Status, message),
 				Details: map[string]interface{}{
 					"prediction_id": prediction.ID,
 					"status":        result.Status,
 				},
 			}
 		}
-		
-		time.Sleep(pollInterval)
 	}
 	
 	if result == nil || result.Status != "succeeded" {
@@ -104,73 +169,130 @@ func (g *Generator) GenerateImage(ctx context.Context, params GenerateParams) (*
 		}
 	}
 	
+	bundle.Stage("wait_for_prediction")
+	
 	// Process output
-	outputURL := ""
-	if output, ok := result.Output.([]interface{}); ok && len(output) > 0 {
-		if url, ok := output[0].(string); ok {
-			outputURL = url
+	outputURLs := client.OutputURLs(result.Output)
+	if len(outputURLs) == 0 {
+		// Safety filters usually surface as a successful prediction with no output
+		if blockedErr := contentBlockedError(result, modelID, input); blockedErr != nil {
+			return nil, blockedErr
 		}
-	} else if url, ok := result.Output.(string); ok {
-		outputURL = url
-	}
-	
-	if outputURL == "" {
 		return nil, GenerationError{
 			Code:    "no_output",
 			Message: "No output URL in result",
 		}
 	}
 	
-	// Download and save image
-	filename := g.generateFilename(params.Filename, params.Prompt, modelID)
-	imagePath, err := g.storage.SaveImage(id, outputURL, filename)
+	// A rerun with a derived ID replaces the earlier run's files
+	if params.DeterministicID {
+		if err := g.storage.ClearOperation(id); err != nil {
+			return nil, err
+		}
+	}
+	
+	// Screen outputs before they are written to disk
+	filename := g.generateFilename(params.Filename, filenameFields{Prompt: params.Prompt, Model: modelID, Seed: params.Seed, ID: id})
+	screening, err := g.screener.Screen(ctx, id, outputURLs, filename)
 	if err != nil {
+		return nil, GenerationError{
+			Code:    "moderation_failed",
+			Message: err.Error(),
+			Details: map[string]interface{}{
+				"prediction_id": prediction.ID,
+			},
+		}
+	}
+	if code, message := screening.HeldBack(); code != "" {
+		return nil, GenerationError{
+			Code:    code,
+			Message: message,
+			Details: map[string]interface{}{
+				"prediction_id": prediction.ID,
+				"moderation":    screening.Metadata(),
+			},
+		}
+	}
+	outputURLs = screening.Keep
+	bundle.Stage("moderate_output")
+	
+	// Download and save image
+	savedFiles, err := g.storage.SaveOutputsWithRefresh(ctx, id, outputURLs, filename, screening.Refresher(client.OutputRefresher(ctx, g.client, prediction.ID)))
+	partial := storage.PartialOutputs(err)
+	if err != nil && partial == nil {
 		return nil, fmt.Errorf("failed to save image: %w", err)
 	}
+	bundle.Stage("download_output")
+	notes = append(notes, partial.Notes()...)
+	// Seedream 4 decides how many images of a sequence to return
+	if returned := len(client.OutputURLs(result.Output)); modelID == models.ModelSeedream4 && returned < params.NumOutputs {
+		notes = append(notes, fmt.Sprintf("seedream-4 returned %d of the %d images requested; describe the sequence in the prompt, e.g. \"a series of 4 images\", to get more", returned, params.NumOutputs))
+	}
+	saved := savedFiles[0]
+	imagePath := saved.Path
 	
 	// Calculate metrics
-	fileInfo, _ := os.Stat(imagePath)
 	metrics := GenerationMetrics{
 		GenerationTime: time.Since(startTime).Seconds(),
-		FileSize:       fileInfo.Size(),
+		FileSize:       saved.Size,
 	}
 	
 	// Save metadata
 	opResult := &types.OperationResult{
-		Filename:       filename,
+		Filename:       filepath.Base(imagePath),
 		GenerationTime: time.Since(startTime).Seconds(),
 		PredictionID:   prediction.ID,
+		FileSize:       saved.Size,
+		SHA256:         saved.SHA256,
+		Files:          storage.Filenames(savedFiles),
+		SVG:            saved.SVG,
 	}
 	
+	models.SetActualCost(opResult, result, modelID, len(savedFiles))
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+	
 	metadata := &types.ImageMetadata{
 		Version:   "1.0",
 		ID:        id,
 		Operation: "generate_image",
 		Timestamp: time.Now(),
 		Model:     modelID,
-		Parameters: map[string]interface{}{
-			"prompt": params.Prompt,
-			"model":  params.Model,
-		},
-		Result: opResult,
+		Parameters: params.metadataParameters(),
+		Result:     opResult,
 	}
 	
-	if err := g.storage.SaveMetadata(id, metadata); err != nil && g.debug {
-		log.Printf("Failed to save metadata: %v", err)
+	if info := screening.Metadata(); info != nil {
+		metadata.Parameters["moderation"] = info
+	}
+	if translation != nil {
+		metadata.Parameters["translation"] = translation.metadata()
 	}
 	
+	if err := g.storage.SaveMetadata(id, metadata); err != nil {
+		slog.Warn("failed to save metadata", "storage_id", id, "error", err)
+	}
+	if err := g.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "storage_id", id, "error", err)
+	}
+	g.storeCached(cacheKey, id)
+	
 	// Build result
-	modelInfo := GetModelInfo(modelID)
+	modelInfo := models.GetModelInfo(modelID)
 	return &ImageResult{
 		ID:           id,
 		FilePath:     imagePath,
-		URL:          outputURL,
+		URL:          saved.URL,
+		FilePaths:    storage.Paths(savedFiles),
+		URLs:         storage.URLs(savedFiles),
 		Model:        modelID,
 		ModelName:    modelInfo.Name,
 		Prompt:       params.Prompt,
-		Parameters:   input,
+		Parameters:   responseParameters(input, params),
 		Metrics:      metrics,
 		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+		Notes:        append(notes, screening.Notes()...),
 	}, nil
 }
 
@@ -182,11 +304,11 @@ func (g *Generator) buildInputParams(params GenerateParams, modelID string) map[
 	
 	// Special handling for different models
 	switch modelID {
-	case ModelImagen4:
+	case models.ModelImagen4, models.ModelImagen4Fast, models.ModelImagen4Ultra:
 		// Imagen-4 uses aspect_ratio instead of width/height
 		aspectRatio := params.AspectRatio
 		if aspectRatio == "" {
-			aspectRatio = g.inferAspectRatio(params.Width, params.Height)
+			aspectRatio = nearestAspectRatio(modelID, params.Width, params.Height)
 		}
 		input["aspect_ratio"] = aspectRatio
 		
@@ -196,17 +318,21 @@ func (g *Generator) buildInputParams(params GenerateParams, modelID string) map[
 			input["safety_filter_level"] = "block_only_high"
 		}
 		
-		if params.OutputFormat != "" {
-			input["output_format"] = params.OutputFormat
+		if params.PersonGeneration != "" {
+			input["person_generation"] = params.PersonGeneration
+		}
+		
+		if format := modelFormat(params.OutputFormat, "jpg", "png"); format != "" {
+			input["output_format"] = format
 		} else {
 			input["output_format"] = "jpg"
 		}
 		
-	case ModelGen4Image:
+	case models.ModelGen4Image:
 		// Gen-4 uses aspect_ratio and resolution
 		aspectRatio := params.AspectRatio
 		if aspectRatio == "" {
-			aspectRatio = g.inferAspectRatio(params.Width, params.Height)
+			aspectRatio = nearestAspectRatio(modelID, params.Width, params.Height)
 		}
 		input["aspect_ratio"] = aspectRatio
 		
@@ -215,7 +341,54 @@ func (g *Generator) buildInputParams(params GenerateParams, modelID string) map[
 		} else {
 			input["resolution"] = "1080p"
 		}
+
+	case models.ModelSD35Large, models.ModelSD35LargeTurbo, models.ModelSD35Medium, models.ModelStableImageUltra:
+		// Stability models use aspect_ratio and cfg
+		aspectRatio := params.AspectRatio
+		if aspectRatio == "" {
+			aspectRatio = nearestAspectRatio(modelID, params.Width, params.Height)
+		}
+		input["aspect_ratio"] = aspectRatio
+
+		if params.GuidanceScale > 0 && modelID != models.ModelStableImageUltra {
+			input["cfg"] = params.GuidanceScale
+		}
+
+		if params.NegativePrompt != "" {
+			input["negative_prompt"] = params.NegativePrompt
+		}
+
+		if format := modelFormat(params.OutputFormat, "jpg", "png", "webp"); format != "" {
+			input["output_format"] = format
+		} else {
+			input["output_format"] = "png"
+		}
+
+	case models.ModelSeedream3, models.ModelSeedream4:
+		seedreamInput(input, params, modelID)
 		
+	case models.ModelGPTImage1:
+		// GPT Image picks a size from the aspect ratio
+		aspectRatio := params.AspectRatio
+		if aspectRatio == "" {
+			aspectRatio = nearestAspectRatio(modelID, params.Width, params.Height)
+		}
+		input["aspect_ratio"] = aspectRatio
+
+		if params.Quality != "" {
+			input["quality"] = params.Quality
+		}
+
+		if format := modelFormat(params.OutputFormat, "jpg", "png", "webp"); format != "" {
+			input["output_format"] = format
+		}
+
+		if params.NumOutputs > 0 {
+			input["num_outputs"] = params.NumOutputs
+		} else {
+			input["num_outputs"] = 1
+		}
+
 	default:
 		// Standard models use width/height
 		width := params.Width
@@ -244,6 +417,14 @@ func (g *Generator) buildInputParams(params GenerateParams, modelID string) map[
 		} else {
 			input["num_outputs"] = 1
 		}
+		
+		// FLUX models return WebP unless asked otherwise
+		if format := modelFormat(params.OutputFormat, "webp", "jpg", "png"); format != "" {
+			input["output_format"] = format
+		}
+		if params.OutputQuality > 0 {
+			input["output_quality"] = params.OutputQuality
+		}
 	}
 	
 	// Add seed if specified
@@ -254,29 +435,24 @@ func (g *Generator) buildInputParams(params GenerateParams, modelID string) map[
 	return input
 }
 
-// inferAspectRatio infers aspect ratio from width and height
-func (g *Generator) inferAspectRatio(width, height int) string {
-	if width <= 0 || height <= 0 {
-		return "1:1"
+// modelFormat returns the output format to ask a model for, given the format
+// requested and those the model can produce. A format the model cannot
+// produce is re-encoded after saving, from a lossless PNG.
+func modelFormat(requested string, supported ...string) string {
+	if requested == "" {
+		return ""
 	}
-	
-	ratio := float64(width) / float64(height)
-	
-	if ratio > 1.7 { // ~16:9
-		return "16:9"
-	} else if ratio < 0.6 { // ~9:16
-		return "9:16"
-	} else if ratio > 1.2 && ratio < 1.4 { // ~4:3
-		return "4:3"
-	} else if ratio > 0.7 && ratio < 0.8 { // ~3:4
-		return "3:4"
+	for _, format := range supported {
+		if format == requested {
+			return format
+		}
 	}
-	
-	return "1:1"
+	return "png"
 }
 
-// generateFilename generates a filename for the image
-func (g *Generator) generateFilename(userFilename, prompt, modelID string) string {
+// generateFilename generates a filename for the image from the filename
+// template unless the user gave one
+func (g *Generator) generateFilename(userFilename string, fields filenameFields) string {
 	if userFilename != "" {
 		// Ensure it has an extension
 		if !strings.Contains(userFilename, ".") {
@@ -285,29 +461,9 @@ func (g *Generator) generateFilename(userFilename, prompt, modelID string) strin
 		return userFilename
 	}
 	
-	// Generate from prompt
-	cleanPrompt := strings.ToLower(prompt)
-	cleanPrompt = strings.ReplaceAll(cleanPrompt, " ", "_")
-	
-	// Remove special characters
-	var result []rune
-	for _, r := range cleanPrompt {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
-			result = append(result, r)
-		}
-	}
-	
-	filename := string(result)
-	if len(filename) > 50 {
-		filename = filename[:50]
+	template := g.filenameTemplate
+	if template == "" {
+		template = DefaultFilenameTemplate
 	}
-	if filename == "" {
-		filename = "generated"
-	}
-	
-	// Add model suffix
-	modelName := filepath.Base(modelID)
-	modelName = strings.Split(modelName, ":")[0]
-	
-	return fmt.Sprintf("%s_%s.png", filename, modelName)
+	return expandFilenameTemplate(template, fields, time.Now()) + ".png"
 }
\ No newline at end of file
@@ -0,0 +1,64 @@
+package generation
+
+import (
+	"fmt"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+)
+
+// imagenVariants maps Imagen-4 tiers to their models
+var imagenVariants = map[string]string{
+	"fast":     models.ModelImagen4Fast,
+	"standard": models.ModelImagen4,
+	"ultra":    models.ModelImagen4Ultra,
+}
+
+// personGenerationPolicies are the people Imagen-4 may depict
+var personGenerationPolicies = map[string]bool{
+	"dont_allow":  true, // No people
+	"allow_adult": true, // Adults only
+	"allow_all":   true, // Adults and children
+}
+
+// isImagen4 reports whether modelID is an Imagen-4 tier
+func isImagen4(modelID string) bool {
+	for _, variant := range imagenVariants {
+		if modelID == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveImagen applies the Imagen-4 options of params to the resolved
+// model: imagen_variant swaps in the requested tier. Both Imagen-4 options
+// are rejected for other models, since silently running a different tier
+// or ignoring a person policy would be surprising.
+func resolveImagen(modelID string, params GenerateParams) (string, error) {
+	if params.ImagenVariant == "" && params.PersonGeneration == "" {
+		return modelID, nil
+	}
+	if !isImagen4(modelID) {
+		return "", GenerationError{
+			Code:    "invalid_parameters",
+			Message: fmt.Sprintf("imagen_variant and person_generation apply only to imagen-4, not %s", params.Model),
+		}
+	}
+	if params.PersonGeneration != "" && !personGenerationPolicies[params.PersonGeneration] {
+		return "", GenerationError{
+			Code:    "invalid_parameters",
+			Message: fmt.Sprintf("invalid person_generation %q (use dont_allow, allow_adult, or allow_all)", params.PersonGeneration),
+		}
+	}
+	if params.ImagenVariant == "" {
+		return modelID, nil
+	}
+	variant, ok := imagenVariants[params.ImagenVariant]
+	if !ok {
+		return "", GenerationError{
+			Code:    "invalid_parameters",
+			Message: fmt.Sprintf("invalid imagen_variant %q (use fast, standard, or ultra)", params.ImagenVariant),
+		}
+	}
+	return variant, nil
+}
@@ -0,0 +1,177 @@
+package generation
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// NoNegativePreset turns off the default negative prompt preset
+const NoNegativePreset = "none"
+
+// Model families with a default negative prompt preset. Only these models
+// take a negative prompt.
+const (
+	FamilySDXL  = "sdxl"  // SDXL and SDXL Lightning
+	FamilySD3   = "sd3"   // Stable Diffusion 3.5 and Stable Image Ultra
+	FamilyLocal = "local" // The checkpoint of a local server
+)
+
+// builtinNegativePresets are the presets every library starts from
+var builtinNegativePresets = map[string]string{
+	"photography": "blurry, out of focus, motion blur, overexposed, underexposed, noise, jpeg artifacts, lowres, watermark, text, signature, deformed, disfigured, bad anatomy, bad hands, extra fingers, extra limbs, cartoon, illustration, painting, 3d render",
+	"anime":       "photo, photorealistic, realistic, 3d render, lowres, blurry, jpeg artifacts, watermark, signature, text, bad anatomy, bad hands, extra fingers, missing fingers, extra limbs, deformed, worst quality, low quality",
+	"product":     "cluttered background, distracting objects, people, hands, text, watermark, logo, blurry, out of focus, harsh shadows, dust, scratches, fingerprints, deformed, warped, lowres, jpeg artifacts",
+}
+
+// builtinNegativeDefaults are the presets applied per model family
+var builtinNegativeDefaults = map[string]string{
+	FamilySDXL:  "photography",
+	FamilySD3:   "photography",
+	FamilyLocal: "photography",
+}
+
+// NegativePresets is a library of named negative prompts and the preset
+// applied by default to each model family
+type NegativePresets struct {
+	Presets  map[string]string `yaml:"presets"`  // Name to negative prompt
+	Defaults map[string]string `yaml:"defaults"` // Model family to preset name, or none
+}
+
+// DefaultNegativePresets returns the built-in library
+func DefaultNegativePresets() *NegativePresets {
+	lib := &NegativePresets{Presets: map[string]string{}, Defaults: map[string]string{}}
+	for name, negative := range builtinNegativePresets {
+		lib.Presets[name] = negative
+	}
+	for family, name := range builtinNegativeDefaults {
+		lib.Defaults[family] = name
+	}
+	return lib
+}
+
+// LoadNegativePresets reads a YAML library of negative prompt presets and
+// merges it over the built-in one: its presets add to or replace the
+// built-in presets, and its defaults replace those of the families it
+// names. An empty path returns the built-in library.
+func LoadNegativePresets(path string) (*NegativePresets, error) {
+	lib := DefaultNegativePresets()
+	if path == "" {
+		return lib, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read negative prompt presets: %w", err)
+	}
+	var file NegativePresets
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse negative prompt presets: %w", err)
+	}
+
+	for name, negative := range file.Presets {
+		name = strings.ToLower(strings.TrimSpace(name))
+		negative = strings.TrimSpace(negative)
+		if name == "" || name == NoNegativePreset || negative == "" {
+			return nil, fmt.Errorf("invalid negative prompt preset %q: presets need a name other than none and a negative prompt", name)
+		}
+		lib.Presets[name] = negative
+	}
+	for family, name := range file.Defaults {
+		family = strings.ToLower(strings.TrimSpace(family))
+		if _, ok := builtinNegativeDefaults[family]; !ok {
+			return nil, fmt.Errorf("invalid negative prompt defaults: unknown model family %q (use sdxl, sd3, or local)", family)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := lib.Presets[name]; !ok && name != NoNegativePreset {
+			return nil, fmt.Errorf("invalid negative prompt defaults: %s uses unknown preset %q", family, name)
+		}
+		lib.Defaults[family] = name
+	}
+	return lib, nil
+}
+
+// Names returns the preset names in order
+func (l *NegativePresets) Names() []string {
+	names := make([]string, 0, len(l.Presets))
+	for name := range l.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NegativeFamily returns the model family a generation model's default
+// negative prompt preset is chosen by, or "" for models that take no
+// negative prompt
+func NegativeFamily(modelID string) string {
+	switch modelID {
+	case models.ModelSDXL, models.ModelSDXLLightning:
+		return FamilySDXL
+	case models.ModelSD35Large, models.ModelSD35LargeTurbo, models.ModelSD35Medium, models.ModelStableImageUltra:
+		return FamilySD3
+	case models.ModelLocal:
+		return FamilyLocal
+	}
+	return ""
+}
+
+// SetNegativePresets replaces the library negative prompt presets come from
+func (g *Generator) SetNegativePresets(lib *NegativePresets) {
+	g.negatives = lib
+}
+
+// ApplyNegativePreset fills in the negative prompt of params from a preset
+// when none was given: the preset named by NegativePreset, or else the
+// default for the model's family. An explicit negative prompt wins over any
+// preset. GenerateImage applies presets itself; callers that add to the
+// negative prompt, such as generate_branded, apply them first.
+func (g *Generator) ApplyNegativePreset(params *GenerateParams) error {
+	if params.negativeApplied {
+		return nil
+	}
+	if params.NegativePrompt != "" {
+		params.NegativePreset = ""
+		return nil
+	}
+
+	name := strings.ToLower(strings.TrimSpace(params.NegativePreset))
+	if name == "" {
+		family := NegativeFamily(models.Resolve(models.OpGenerate, params.Model))
+		if name = g.negatives.Defaults[family]; name == "" || name == NoNegativePreset {
+			params.NegativePreset = ""
+			return nil
+		}
+	}
+	params.negativeApplied = true
+	params.NegativePreset = name
+	if name == NoNegativePreset {
+		return nil
+	}
+	negative, ok := g.negatives.Presets[name]
+	if !ok {
+		return GenerationError{
+			Code:    "invalid_parameters",
+			Message: fmt.Sprintf("unknown negative_preset %q (use %s, or none)", name, strings.Join(g.negatives.Names(), ", ")),
+		}
+	}
+	params.NegativePrompt = negative
+	return nil
+}
+
+// responseParameters returns the model input reported in responses, naming
+// the negative prompt preset applied
+func responseParameters(input map[string]interface{}, params GenerateParams) map[string]interface{} {
+	if params.NegativePreset == "" {
+		return input
+	}
+	parameters := make(map[string]interface{}, len(input)+1)
+	for k, v := range input {
+		parameters[k] = v
+	}
+	parameters["negative_preset"] = params.NegativePreset
+	return parameters
+}
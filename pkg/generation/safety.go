@@ -0,0 +1,72 @@
+package generation
+
+import (
+	"fmt"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// filteredModels lists models that silently return no output when their safety filter triggers
+var filteredModels = map[string]bool{
+	models.ModelImagen4:      true,
+	models.ModelImagen4Fast:  true,
+	models.ModelImagen4Ultra: true,
+	models.ModelFluxSchnell:  true,
+	models.ModelFluxDev:      true,
+	models.ModelFluxPro:      true,
+}
+
+// contentBlockedError returns a content_blocked error if a prediction's output
+// was withheld by a safety filter, or nil otherwise
+func contentBlockedError(result *types.ReplicatePredictionResponse, modelID string, input map[string]interface{}) error {
+	blocked := client.IsContentBlocked(result.Error, result.Logs)
+
+	// A successful prediction from a filtered model with no output was filtered
+	if !blocked && result.Status == "succeeded" && filteredModels[modelID] {
+		blocked = true
+	}
+	if !blocked {
+		return nil
+	}
+
+	details := map[string]interface{}{
+		"prediction_id": result.ID,
+		"model":         modelID,
+		"filter_level":  filterLevel(modelID, input),
+		"suggestions":   blockedSuggestions(modelID),
+	}
+
+	return GenerationError{
+		Code:    client.ErrCodeContentBlocked,
+		Message: fmt.Sprintf("%s blocked the output with its safety filter", models.GetModelInfo(modelID).Name),
+		Details: details,
+	}
+}
+
+// filterLevel reports the safety filter setting used for a prediction
+func filterLevel(modelID string, input map[string]interface{}) interface{} {
+	if isImagen4(modelID) {
+		if level, ok := input["safety_filter_level"]; ok {
+			return level
+		}
+	} else if tolerance, ok := input["safety_tolerance"]; ok {
+		return tolerance
+	}
+	return "default"
+}
+
+// blockedSuggestions returns ways to get past a safety filter block
+func blockedSuggestions(modelID string) []string {
+	suggestions := []string{
+		"Rephrase the prompt to remove violent, explicit, or otherwise sensitive wording",
+		"Avoid naming real people or trademarked characters",
+	}
+	if isImagen4(modelID) {
+		suggestions = append(suggestions, "Set safety_filter_level to block_only_high for the most permissive filtering")
+	} else {
+		suggestions = append(suggestions, "Try a different model, which may apply a different content filter")
+	}
+	return suggestions
+}
@@ -0,0 +1,76 @@
+package generation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+)
+
+// MaxSeedreamImages is the most images Seedream 4 returns for one prompt
+const MaxSeedreamImages = 15
+
+// seedreamSizes are the size tiers each Seedream model takes
+var seedreamSizes = map[string][]string{
+	models.ModelSeedream3: {"small", "regular", "big"},
+	models.ModelSeedream4: {"1K", "2K", "4K"},
+}
+
+// checkSeedream rejects a size tier the model does not take
+func checkSeedream(modelID string, params GenerateParams) error {
+	if params.Size == "" {
+		return nil
+	}
+	sizes, ok := seedreamSizes[modelID]
+	if !ok {
+		return GenerationError{
+			Code:    "invalid_parameters",
+			Message: fmt.Sprintf("size applies only to seedream models, not %s", params.Model),
+		}
+	}
+	for _, size := range sizes {
+		if params.Size == size {
+			return nil
+		}
+	}
+	return GenerationError{
+		Code:    "invalid_parameters",
+		Message: fmt.Sprintf("invalid size %q for %s (use %s)", params.Size, models.GetModelInfo(modelID).Name, strings.Join(sizes, ", ")),
+	}
+}
+
+// seedreamInput sets the Seedream inputs. Both models take an aspect ratio
+// and a size tier; explicit pixel dimensions go through a "custom" aspect
+// ratio on Seedream 3 and a "custom" size on Seedream 4. Several outputs
+// from Seedream 4 are a sequence of related images, such as a storyboard or
+// variations of one character.
+func seedreamInput(input map[string]interface{}, params GenerateParams, modelID string) {
+	switch {
+	case params.AspectRatio != "":
+		input["aspect_ratio"] = params.AspectRatio
+	case params.Width > 0 && params.Height > 0:
+		if modelID == models.ModelSeedream3 {
+			input["aspect_ratio"] = "custom"
+		} else {
+			input["size"] = "custom"
+		}
+		input["width"] = params.Width
+		input["height"] = params.Height
+	default:
+		input["aspect_ratio"] = "1:1"
+	}
+	if _, custom := input["size"]; !custom && params.Size != "" {
+		input["size"] = params.Size
+	}
+
+	if modelID == models.ModelSeedream3 {
+		if params.GuidanceScale > 0 {
+			input["guidance_scale"] = params.GuidanceScale
+		}
+		return
+	}
+	if params.NumOutputs > 1 {
+		input["sequential_image_generation"] = "auto"
+		input["max_images"] = min(params.NumOutputs, MaxSeedreamImages)
+	}
+}
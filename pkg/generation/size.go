@@ -0,0 +1,139 @@
+package generation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+)
+
+// SizePreset is a named image shape. Models that take pixel dimensions get
+// its width and height; models that take an aspect ratio get the supported
+// ratio closest to it.
+type SizePreset struct {
+	Ratio  string // Intended aspect ratio
+	Width  int    // Dimensions for models that take width and height
+	Height int
+}
+
+// SizePresets are the named shapes generation tools accept. Dimensions are
+// the SDXL training buckets, which FLUX and local checkpoints also handle well.
+var SizePresets = map[string]SizePreset{
+	"square":    {Ratio: "1:1", Width: 1024, Height: 1024},
+	"portrait":  {Ratio: "2:3", Width: 832, Height: 1216},
+	"landscape": {Ratio: "3:2", Width: 1216, Height: 832},
+	"banner":    {Ratio: "21:9", Width: 1536, Height: 640},
+	"story":     {Ratio: "9:16", Width: 768, Height: 1344},
+	"thumbnail": {Ratio: "16:9", Width: 1344, Height: 768},
+}
+
+// aspectRatios are the aspect ratios each model that takes one accepts.
+// Models not listed take width and height.
+var aspectRatios = map[string][]string{
+	models.ModelImagen4:          {"1:1", "9:16", "16:9", "3:4", "4:3"},
+	models.ModelImagen4Fast:      {"1:1", "9:16", "16:9", "3:4", "4:3"},
+	models.ModelImagen4Ultra:     {"1:1", "9:16", "16:9", "3:4", "4:3"},
+	models.ModelGen4Image:        {"1:1", "16:9", "9:16", "4:3", "3:4", "21:9"},
+	models.ModelSD35Large:        {"1:1", "16:9", "9:16", "21:9", "9:21", "3:2", "2:3", "5:4", "4:5"},
+	models.ModelSD35LargeTurbo:   {"1:1", "16:9", "9:16", "21:9", "9:21", "3:2", "2:3", "5:4", "4:5"},
+	models.ModelSD35Medium:       {"1:1", "16:9", "9:16", "21:9", "9:21", "3:2", "2:3", "5:4", "4:5"},
+	models.ModelStableImageUltra: {"1:1", "16:9", "9:16", "21:9", "9:21", "3:2", "2:3", "5:4", "4:5"},
+	models.ModelSeedream3:        {"1:1", "16:9", "9:16", "4:3", "3:4", "3:2", "2:3", "21:9"},
+	models.ModelSeedream4:        {"1:1", "16:9", "9:16", "4:3", "3:4", "3:2", "2:3", "21:9"},
+	models.ModelGPTImage1:        {"1:1", "3:2", "2:3"},
+}
+
+// SizePresetNames returns the preset names in order
+func SizePresetNames() []string {
+	names := make([]string, 0, len(SizePresets))
+	for name := range SizePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sizePreset looks up a preset by name
+func sizePreset(name string) (SizePreset, error) {
+	preset, ok := SizePresets[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return SizePreset{}, GenerationError{
+			Code:    "invalid_parameters",
+			Message: fmt.Sprintf("unknown size_preset %q (use %s)", name, strings.Join(SizePresetNames(), ", ")),
+		}
+	}
+	return preset, nil
+}
+
+// PresetAspectRatio returns the aspect ratio a size preset resolves to for a
+// model that takes an aspect ratio
+func PresetAspectRatio(modelID, name string) (string, error) {
+	preset, err := sizePreset(name)
+	if err != nil {
+		return "", err
+	}
+	return preset.aspectRatio(modelID), nil
+}
+
+// aspectRatio returns the preset's ratio if modelID accepts it, or else the
+// accepted ratio closest to it
+func (p SizePreset) aspectRatio(modelID string) string {
+	for _, ratio := range aspectRatios[modelID] {
+		if ratio == p.Ratio {
+			return ratio
+		}
+	}
+	return nearestAspectRatio(modelID, p.Width, p.Height)
+}
+
+// applySizePreset returns params with the dimensions of its size preset
+// filled in for modelID. The preset itself stays in params, so metadata
+// records the preset rather than the dimensions it resolved to.
+func applySizePreset(modelID string, params GenerateParams) (GenerateParams, error) {
+	if params.SizePreset == "" {
+		return params, nil
+	}
+	if params.AspectRatio != "" || params.Width > 0 || params.Height > 0 {
+		return params, GenerationError{
+			Code:    "invalid_parameters",
+			Message: "size_preset replaces width, height, and aspect_ratio; pass either the preset or dimensions",
+		}
+	}
+	preset, err := sizePreset(params.SizePreset)
+	if err != nil {
+		return params, err
+	}
+	if _, ok := aspectRatios[modelID]; ok {
+		params.AspectRatio = preset.aspectRatio(modelID)
+	} else {
+		params.Width, params.Height = preset.Width, preset.Height
+	}
+	return params, nil
+}
+
+// nearestAspectRatio returns the aspect ratio modelID accepts that is
+// closest to width:height, or 1:1 when no dimensions are given
+func nearestAspectRatio(modelID string, width, height int) string {
+	if width <= 0 || height <= 0 {
+		return "1:1"
+	}
+	ratios, ok := aspectRatios[modelID]
+	if !ok {
+		ratios = aspectRatios[models.ModelGen4Image]
+	}
+	// Compare on a log scale so 2:1 and 1:2 are equally far from 1:1
+	target := math.Log(float64(width) / float64(height))
+	best, bestDistance := "1:1", math.Inf(1)
+	for _, ratio := range ratios {
+		w, h, _ := strings.Cut(ratio, ":")
+		rw, _ := strconv.ParseFloat(w, 64)
+		rh, _ := strconv.ParseFloat(h, 64)
+		if distance := math.Abs(math.Log(rw/rh) - target); distance < bestDistance {
+			best, bestDistance = ratio, distance
+		}
+	}
+	return best
+}
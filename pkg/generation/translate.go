@@ -0,0 +1,186 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// translationSystemPrompt instructs the language model to translate a prompt.
+// Reference tags such as @hero1 must survive for Gen-4.
+const translationSystemPrompt = `You translate prompts for an image generation model into English. ` +
+	`Reply with a single JSON object and nothing else: {"language": "<English name of the prompt's language>", "english": "<the prompt in English>"}. ` +
+	`Translate faithfully without adding, removing, or embellishing details. ` +
+	`Keep words starting with @, proper names, brand names, and any text meant to appear in the image exactly as written. ` +
+	`If the prompt is already English, set language to "English" and return it unchanged.`
+
+// englishMarkers are common English words; an ASCII prompt containing one is
+// taken to be English without asking the language model. "of" is left out
+// because generate_branded appends "Use a color palette of ..." to prompts.
+var englishMarkers = map[string]bool{
+	"the": true, "with": true, "and": true, "is": true,
+	"are": true, "from": true, "this": true, "that": true, "wearing": true,
+	"photo": true, "image": true, "style": true, "background": true,
+}
+
+// Translation records a prompt translated to English before generation
+type Translation struct {
+	Original string  // The prompt as written
+	Language string  // Language the prompt was written in
+	Cost     float64 // Cost of the translation in USD
+}
+
+// metadata describes the translation for an operation's metadata
+func (t *Translation) metadata() map[string]interface{} {
+	return map[string]interface{}{
+		"original_prompt": t.Original,
+		"language":        t.Language,
+		"model":           models.ModelLlama3Instruct,
+		"cost":            t.Cost,
+	}
+}
+
+// likelyEnglish reports whether a prompt can be sent without translation:
+// it is written in ASCII and either contains a common English word or is too
+// short to tell
+func likelyEnglish(prompt string) bool {
+	for _, r := range prompt {
+		if r > unicode.MaxASCII && unicode.IsLetter(r) {
+			return false
+		}
+	}
+	words := strings.FieldsFunc(strings.ToLower(prompt), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) <= 3 {
+		return true
+	}
+	for _, word := range words {
+		if englishMarkers[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// translatePrompt returns prompt in English, with a record of the translation
+// and a note for the response when one was made. A failed translation does
+// not stop generation: the prompt is sent as written and the note says why.
+func (g *Generator) translatePrompt(ctx context.Context, prompt string) (string, *Translation, string) {
+	if likelyEnglish(prompt) {
+		return prompt, nil, ""
+	}
+
+	language, english, cost, err := g.translate(ctx, prompt)
+	if err != nil {
+		slog.Warn("prompt translation failed", "error", err)
+		return prompt, nil, "the prompt was sent untranslated: " + err.Error()
+	}
+	if strings.EqualFold(language, "English") || english == prompt {
+		return prompt, nil, ""
+	}
+	note := fmt.Sprintf("translated the prompt from %s to English: %q", language, english)
+	return english, &Translation{Original: prompt, Language: language, Cost: cost}, note
+}
+
+// translate asks the language model for the prompt's language and English
+// translation. The spend is recorded in the ledger.
+func (g *Generator) translate(ctx context.Context, prompt string) (string, string, float64, error) {
+	startTime := time.Now()
+	modelID := models.ModelLlama3Instruct
+
+	prediction, err := g.client.CreatePrediction(ctx, modelID, map[string]interface{}{
+		"prompt":        prompt,
+		"system_prompt": translationSystemPrompt,
+		"max_tokens":    512,
+		"temperature":   0.1,
+	})
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to create prediction: %w", err)
+	}
+
+	poll := g.timeouts.NewPoll(ctx, 30*time.Second)
+	var result *types.ReplicatePredictionResponse
+	for poll.Next() {
+		result, err = g.client.GetPrediction(ctx, prediction.ID)
+		if err != nil {
+			return "", "", 0, fmt.Errorf("failed to get prediction status: %w", err)
+		}
+		if result.Status == types.StatusSucceeded || result.Status == types.StatusFailed || result.Status == types.StatusCanceled {
+			break
+		}
+	}
+	if result.Status != types.StatusSucceeded {
+		_, message := client.ClassifyFailure(result.Error, result.Logs)
+		if result.Status != types.StatusFailed && result.Status != types.StatusCanceled {
+			message = "timed out"
+		}
+		return "", "", 0, fmt.Errorf("translation %s: %s", result.Status, message)
+	}
+
+	opResult := &types.OperationResult{
+		GenerationTime: time.Since(startTime).Seconds(),
+		PredictionID:   prediction.ID,
+	}
+	models.SetActualCost(opResult, result, modelID, 1)
+	metadata := &types.ImageMetadata{
+		Version:   "1.0",
+		Operation: "translate_prompt",
+		Timestamp: time.Now(),
+		Model:     modelID,
+		Result:    opResult,
+	}
+	if err := g.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "prediction_id", prediction.ID, "error", err)
+	}
+
+	language, english, err := parseTranslation(outputText(result.Output))
+	return language, english, opResult.CostEstimate, err
+}
+
+// outputText joins a language model's output, which Replicate returns as a
+// list of tokens
+func outputText(output interface{}) string {
+	switch v := output.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var b strings.Builder
+		for _, token := range v {
+			if s, ok := token.(string); ok {
+				b.WriteString(s)
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// parseTranslation reads the JSON object the language model was asked for,
+// ignoring any text around it
+func parseTranslation(text string) (string, string, error) {
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return "", "", fmt.Errorf("the language model did not return a translation")
+	}
+	var reply struct {
+		Language string `json:"language"`
+		English  string `json:"english"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &reply); err != nil {
+		return "", "", fmt.Errorf("the language model returned an unreadable translation: %w", err)
+	}
+	reply.English = strings.TrimSpace(reply.English)
+	if reply.English == "" {
+		return "", "", fmt.Errorf("the language model returned an empty translation")
+	}
+	return strings.TrimSpace(reply.Language), reply.English, nil
+}
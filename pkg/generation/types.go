@@ -10,13 +10,57 @@ type GenerateParams struct {
 	Height         int
 	AspectRatio    string  // For Imagen4 and Gen4
 	Resolution     string  // For Gen4
+	Size           string  // For Seedream: small, regular, or big (3); 1K, 2K, or 4K (4)
+	SizePreset     string  // Named shape resolved per model in place of width, height, or aspect ratio
 	Seed           int
 	GuidanceScale  float64
 	NegativePrompt string
 	NumOutputs     int
 	SafetyFilter   string  // For Imagen4
-	OutputFormat   string  // For Imagen4
+	ImagenVariant  string  // For Imagen4: fast, standard, or ultra tier
+	PersonGeneration string // For Imagen4: dont_allow, allow_adult, or allow_all
+	OutputFormat   string  // jpg, png, webp, or avif; formats the model cannot produce are re-encoded after saving
+	OutputQuality  int     // Quality of lossy output formats (1-100)
+	Quality        string  // For GPT Image: low, medium, high
 	Filename       string  // Optional filename hint
+	UseCache       bool    // Return a stored result for an identical request
+	TranslatePrompt bool   // Translate a non-English prompt to English first
+	DeterministicID bool   // Derive the storage ID from the request, so reruns share a directory
+	NegativePreset string  // Negative prompt preset used when NegativePrompt is empty; "" picks the model family's default, "none" turns it off
+
+	negativeApplied bool // The preset has been applied to NegativePrompt
+}
+
+// metadataParameters returns the request as stored in metadata: the prompt
+// and model plus every option that was set, enough to rerun the request
+func (p GenerateParams) metadataParameters() map[string]interface{} {
+	parameters := map[string]interface{}{
+		"prompt": p.Prompt,
+		"model":  p.Model,
+	}
+	set := func(key string, value interface{}, isSet bool) {
+		if isSet {
+			parameters[key] = value
+		}
+	}
+	set("width", p.Width, p.Width != 0)
+	set("height", p.Height, p.Height != 0)
+	set("aspect_ratio", p.AspectRatio, p.AspectRatio != "")
+	set("resolution", p.Resolution, p.Resolution != "")
+	set("size", p.Size, p.Size != "")
+	set("size_preset", p.SizePreset, p.SizePreset != "")
+	set("seed", p.Seed, p.Seed != 0)
+	set("guidance_scale", p.GuidanceScale, p.GuidanceScale != 0)
+	set("negative_prompt", p.NegativePrompt, p.NegativePrompt != "")
+	set("negative_preset", p.NegativePreset, p.NegativePreset != "")
+	set("num_outputs", p.NumOutputs, p.NumOutputs != 0)
+	set("safety_filter_level", p.SafetyFilter, p.SafetyFilter != "")
+	set("imagen_variant", p.ImagenVariant, p.ImagenVariant != "")
+	set("person_generation", p.PersonGeneration, p.PersonGeneration != "")
+	set("output_format", p.OutputFormat, p.OutputFormat != "")
+	set("output_quality", p.OutputQuality, p.OutputQuality != 0)
+	set("quality", p.Quality, p.Quality != "")
+	return parameters
 }
 
 // Gen4Params contains parameters specific to Gen-4 with visual context
@@ -27,7 +71,12 @@ type Gen4Params struct {
 	AspectRatio     string
 	Resolution      string
 	Seed            int
+	OutputFormat    string // Requested output format; Gen-4 outputs are re-encoded after saving
+	OutputQuality   int    // Requested quality of lossy output formats
 	Filename        string // Optional filename hint
+	UseCache        bool   // Return a stored result for an identical request
+	TranslatePrompt bool   // Translate a non-English prompt to English first
+	DeterministicID bool   // Derive the storage ID from the request, so reruns share a directory
 }
 
 // ImageResult contains the result of an image generation
@@ -35,18 +84,25 @@ type ImageResult struct {
 	ID          string
 	FilePath    string
 	URL         string
+	FilePaths   []string // Every saved file when the model returns several
+	URLs        []string
 	Model       string
 	ModelName   string
 	Prompt      string
 	Parameters  map[string]interface{}
 	Metrics     GenerationMetrics
 	PredictionID string
+	Provider     string   // Provider that ran the prediction
+	Notes       []string // Notes about adjustments made to reference inputs
+	Cached      bool     // Served from the result cache without a new prediction
 }
 
 // GenerationMetrics contains performance metrics
 type GenerationMetrics struct {
 	GenerationTime float64 // in seconds
 	FileSize       int64   // in bytes
+	PredictTime    float64 // Billed model time in seconds
+	Cost           float64 // Actual cost in USD
 	Width          int
 	Height         int
 }
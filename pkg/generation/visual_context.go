@@ -3,16 +3,20 @@ package generation
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
 	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 	"github.com/gomcpgo/replicate_image_ai/pkg/types"
 )
 
 // GenerateWithVisualContext generates images using RunwayML Gen-4 with reference images
-func (g *Generator) GenerateWithVisualContext(ctx context.Context, params Gen4Params) (*ImageResult, error) {
+func (g *Generator) GenerateWithVisualContext(ctx context.Context, params Gen4Params) (_ *ImageResult, err error) {
 	startTime := time.Now()
 	
 	// Validate parameters
@@ -20,21 +24,19 @@ func (g *Generator) GenerateWithVisualContext(ctx context.Context, params Gen4Pa
 		return nil, err
 	}
 	
-	// Generate unique ID for this operation
-	id, err := g.storage.GenerateID()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate ID: %w", err)
-	}
-	
 	// Convert local file paths to data URLs
-	imageURLs, err := g.convertImagesToDataURLs(params.ReferenceImages)
+	imageURLs, notes, err := g.convertImagesToDataURLs(params.ReferenceImages)
 	if err != nil {
 		return nil, err
 	}
 	
-	if g.debug {
-		log.Printf("Generating with visual context: %d reference images", len(imageURLs))
-		log.Printf("Reference tags: %v", params.ReferenceTags)
+	// Translate a non-English prompt; reference tags are kept as written
+	var translation *Translation
+	if params.TranslatePrompt {
+		var note string
+		if params.Prompt, translation, note = g.translatePrompt(ctx, params.Prompt); note != "" {
+			notes = append(notes, note)
+		}
 	}
 	
 	// Build input parameters for Gen-4
@@ -50,39 +52,73 @@ func (g *Generator) GenerateWithVisualContext(ctx context.Context, params Gen4Pa
 		input["seed"] = params.Seed
 	}
 	
+	// Serve an identical earlier request from the cache; the key covers the
+	// reference image contents, not just their paths
+	var cacheKey string
+	operation := cacheOperation("generate_with_visual_context", params.OutputFormat, params.OutputQuality)
+	if params.UseCache {
+		var cached *ImageResult
+		if cached, cacheKey = g.lookupCached(operation, models.ModelGen4Image, params.Prompt, input, startTime); cached != nil {
+			cached.Parameters = gen4ResponseParams(params)
+			return cached, nil
+		}
+	}
+	
+	// Generate unique ID for this operation
+	id, release, err := g.newID(ctx, params.DeterministicID, operation, models.ModelGen4Image, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ID: %w", err)
+	}
+	defer release()
+	op := g.storage.OpenOperation(id, g.debug, "generate_with_visual_context", models.ModelGen4Image)
+	defer func() { op.Finish(err) }()
+	bundle := op.Bundle
+	
+	slog.Debug("generating with visual context", "storage_id", id, "reference_images", len(imageURLs), "reference_tags", params.ReferenceTags)
+	
+	bundle.SetInput(input)
+	bundle.Stage("prepare_input")
+	
 	// Create prediction with Gen-4 model
-	prediction, err := g.client.CreatePrediction(ctx, ModelGen4Image, input)
+	prediction, err := g.client.CreatePrediction(ctx, models.ModelGen4Image, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prediction: %w", err)
 	}
+	bundle.Record(prediction)
+	bundle.Stage("create_prediction")
 	
 	// Poll for completion
-	const maxAttempts = 60
-	const pollInterval = 2 * time.Second
+	poll := g.timeouts.NewPoll(ctx, 2*time.Minute)
 	
 	var result *types.ReplicatePredictionResponse
-	for i := 0; i < maxAttempts; i++ {
+	for poll.Next() {
 		result, err = g.client.GetPrediction(ctx, prediction.ID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get prediction status: %w", err)
 		}
+		bundle.Record(result)
 		
 		if result.Status == "succeeded" {
 			break
 		}
 		
 		if result.Status == "failed" || result.Status == "canceled" {
+			if blockedErr := contentBlockedError(result, models.ModelGen4Image, input); blockedErr != nil {
+				return nil, blockedErr
+			}
+			code, message := client.ClassifyFailure(result.Error, result.Logs)
+			if code == "" {
+				code = "generation_failed"
+			}
 			return nil, GenerationError{
-				Code:    "generation_failed",
-				Message: fmt.Sprintf("Generation %s: %v", result.Status, result.Error),
+				Code:    code,
+				Message: fmt.Sprintf("Generation %s: %s", result.Status, message),
 				Details: map[string]interface{}{
 					"prediction_id": prediction.ID,
 					"status":        result.Status,
 				},
 			}
 		}
-		
-		time.Sleep(pollInterval)
 	}
 	
 	if result == nil || result.Status != "succeeded" {
@@ -95,50 +131,90 @@ func (g *Generator) GenerateWithVisualContext(ctx context.Context, params Gen4Pa
 		}
 	}
 	
+	bundle.Stage("wait_for_prediction")
+	
 	// Process output
-	outputURL := ""
-	if output, ok := result.Output.([]interface{}); ok && len(output) > 0 {
-		if url, ok := output[0].(string); ok {
-			outputURL = url
+	outputURLs := client.OutputURLs(result.Output)
+	if len(outputURLs) == 0 {
+		// Safety filters usually surface as a successful prediction with no output
+		if blockedErr := contentBlockedError(result, models.ModelGen4Image, input); blockedErr != nil {
+			return nil, blockedErr
 		}
-	} else if url, ok := result.Output.(string); ok {
-		outputURL = url
-	}
-	
-	if outputURL == "" {
 		return nil, GenerationError{
 			Code:    "no_output",
 			Message: "No output URL in result",
 		}
 	}
 	
-	// Download and save image
-	filename := g.generateFilename(params.Filename, params.Prompt, ModelGen4Image)
-	imagePath, err := g.storage.SaveImage(id, outputURL, filename)
+	// A rerun with a derived ID replaces the earlier run's files
+	if params.DeterministicID {
+		if err := g.storage.ClearOperation(id); err != nil {
+			return nil, err
+		}
+	}
+	
+	// Screen outputs before they are written to disk
+	filename := g.generateFilename(params.Filename, filenameFields{Prompt: params.Prompt, Model: models.ModelGen4Image, Seed: params.Seed, ID: id})
+	screening, err := g.screener.Screen(ctx, id, outputURLs, filename)
 	if err != nil {
+		return nil, GenerationError{
+			Code:    "moderation_failed",
+			Message: err.Error(),
+			Details: map[string]interface{}{
+				"prediction_id": prediction.ID,
+			},
+		}
+	}
+	if code, message := screening.HeldBack(); code != "" {
+		return nil, GenerationError{
+			Code:    code,
+			Message: message,
+			Details: map[string]interface{}{
+				"prediction_id": prediction.ID,
+				"moderation":    screening.Metadata(),
+			},
+		}
+	}
+	outputURLs = screening.Keep
+	bundle.Stage("moderate_output")
+	
+	// Download and save image
+	savedFiles, err := g.storage.SaveOutputsWithRefresh(ctx, id, outputURLs, filename, screening.Refresher(client.OutputRefresher(ctx, g.client, prediction.ID)))
+	partial := storage.PartialOutputs(err)
+	if err != nil && partial == nil {
 		return nil, fmt.Errorf("failed to save image: %w", err)
 	}
+	bundle.Stage("download_output")
+	notes = append(notes, partial.Notes()...)
+	saved := savedFiles[0]
+	imagePath := saved.Path
 	
 	// Calculate metrics
-	fileInfo, _ := os.Stat(imagePath)
 	metrics := GenerationMetrics{
 		GenerationTime: time.Since(startTime).Seconds(),
-		FileSize:       fileInfo.Size(),
+		FileSize:       saved.Size,
 	}
 	
 	// Save metadata
 	opResult := &types.OperationResult{
-		Filename:       filename,
+		Filename:       filepath.Base(imagePath),
 		GenerationTime: time.Since(startTime).Seconds(),
 		PredictionID:   prediction.ID,
+		FileSize:       saved.Size,
+		SHA256:         saved.SHA256,
+		Files:          storage.Filenames(savedFiles),
 	}
 	
+	models.SetActualCost(opResult, result, models.ModelGen4Image, len(savedFiles))
+	metrics.PredictTime = opResult.PredictTime
+	metrics.Cost = opResult.CostEstimate
+	
 	metadata := &types.ImageMetadata{
 		Version:   "1.0",
 		ID:        id,
 		Operation: "generate_with_visual_context",
 		Timestamp: time.Now(),
-		Model:     ModelGen4Image,
+		Model:     models.ModelGen4Image,
 		Parameters: map[string]interface{}{
 			"prompt":           params.Prompt,
 			"reference_images": params.ReferenceImages, // Store original paths
@@ -148,32 +224,56 @@ func (g *Generator) GenerateWithVisualContext(ctx context.Context, params Gen4Pa
 		},
 		Result: opResult,
 	}
+	if params.Seed != 0 {
+		metadata.Parameters["seed"] = params.Seed
+	}
+	
+	if info := screening.Metadata(); info != nil {
+		metadata.Parameters["moderation"] = info
+	}
+	if translation != nil {
+		metadata.Parameters["translation"] = translation.metadata()
+	}
 	
-	if err := g.storage.SaveMetadata(id, metadata); err != nil && g.debug {
-		log.Printf("Failed to save metadata: %v", err)
+	if err := g.storage.SaveMetadata(id, metadata); err != nil {
+		slog.Warn("failed to save metadata", "storage_id", id, "error", err)
 	}
+	if err := g.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "storage_id", id, "error", err)
+	}
+	g.storeCached(cacheKey, id)
 	
 	// Build result
-	modelInfo := GetModelInfo(ModelGen4Image)
+	modelInfo := models.GetModelInfo(models.ModelGen4Image)
 	return &ImageResult{
-		ID:        id,
-		FilePath:  imagePath,
-		URL:       outputURL,
-		Model:     ModelGen4Image,
-		ModelName: modelInfo.Name,
-		Prompt:    params.Prompt,
-		Parameters: map[string]interface{}{
-			"prompt":           params.Prompt,
-			"reference_images": len(params.ReferenceImages),
-			"reference_tags":   params.ReferenceTags,
-			"aspect_ratio":     params.AspectRatio,
-			"resolution":       params.Resolution,
-		},
+		ID:           id,
+		FilePath:     imagePath,
+		URL:          saved.URL,
+		FilePaths:    storage.Paths(savedFiles),
+		URLs:         storage.URLs(savedFiles),
+		Model:        models.ModelGen4Image,
+		ModelName:    modelInfo.Name,
+		Prompt:       params.Prompt,
+		Parameters:   gen4ResponseParams(params),
 		Metrics:      metrics,
 		PredictionID: prediction.ID,
+		Provider:     result.Provider,
+		Notes:        append(notes, screening.Notes()...),
 	}, nil
 }
 
+// gen4ResponseParams returns the parameters reported back to the caller,
+// summarizing reference images rather than echoing their data
+func gen4ResponseParams(params Gen4Params) map[string]interface{} {
+	return map[string]interface{}{
+		"prompt":           params.Prompt,
+		"reference_images": len(params.ReferenceImages),
+		"reference_tags":   params.ReferenceTags,
+		"aspect_ratio":     params.AspectRatio,
+		"resolution":       params.Resolution,
+	}
+}
+
 // validateGen4Params validates the parameters for Gen-4 generation
 func (g *Generator) validateGen4Params(params Gen4Params) error {
 	if params.Prompt == "" {
@@ -228,35 +328,55 @@ func (g *Generator) validateGen4Params(params Gen4Params) error {
 	return nil
 }
 
-// convertImagesToDataURLs converts local file paths to data URLs
-func (g *Generator) convertImagesToDataURLs(imagePaths []string) ([]string, error) {
-	imageURLs := make([]string, 0, len(imagePaths))
-	
+// convertImagesToDataURLs converts local file paths to data URLs, returning any
+// notes about inputs that had to be adjusted. References are prepared
+// concurrently since each may need decoding and re-encoding, as many at once
+// as the download pool allows, so a large set does not decode every full-size
+// image into memory together.
+func (g *Generator) convertImagesToDataURLs(imagePaths []string) ([]*types.FileData, []string, error) {
+	// Check that every file exists before doing any work
 	for _, imagePath := range imagePaths {
-		// Check if file exists
 		if _, err := os.Stat(imagePath); os.IsNotExist(err) {
-			return nil, GenerationError{
+			return nil, nil, GenerationError{
 				Code:    "file_not_found",
 				Message: fmt.Sprintf("reference image not found: %s", imagePath),
 			}
 		}
-		
-		// Convert to data URL
-		dataURL, err := storage.ImageToBase64(imagePath)
-		if err != nil {
-			return nil, GenerationError{
+	}
+	
+	prepared := make([]*storage.InputImage, len(imagePaths))
+	errs := make([]error, len(imagePaths))
+	
+	slots := make(chan struct{}, g.storage.MaxParallelDownloads())
+	var wg sync.WaitGroup
+	for i, imagePath := range imagePaths {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, imagePath string) {
+			defer func() { <-slots }()
+			defer wg.Done()
+			// Convert to data URL, downscaling references larger than Gen-4 takes
+			prepared[i], errs[i] = g.storage.PrepareInput(imagePath, models.InputEdge(models.ModelGen4Image))
+		}(i, imagePath)
+	}
+	wg.Wait()
+	
+	// Collect results in the original order so they line up with the tags
+	imageURLs := make([]*types.FileData, 0, len(imagePaths))
+	var notes []string
+	for i, imagePath := range imagePaths {
+		if errs[i] != nil {
+			return nil, nil, GenerationError{
 				Code:    "file_error",
-				Message: fmt.Sprintf("failed to read reference image: %v", err),
+				Message: fmt.Sprintf("failed to read reference image: %v", errs[i]),
 			}
 		}
 		
-		if g.debug {
-			log.Printf("Converted reference image: %s -> data URL (length: %d)", 
-				imagePath, len(dataURL))
-		}
+		slog.Debug("converted reference image", "path", imagePath, "data_url_length", prepared[i].Data.EncodedLen())
 		
-		imageURLs = append(imageURLs, dataURL)
+		imageURLs = append(imageURLs, prepared[i].Data)
+		notes = append(notes, prepared[i].Notes...)
 	}
 	
-	return imageURLs, nil
-}
\ No newline at end of file
+	return imageURLs, notes, nil
+}
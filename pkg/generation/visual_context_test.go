@@ -0,0 +1,125 @@
+package generation
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// writeReferences writes count copies of a photo-sized JPEG, over Gen-4's
+// input edge limit, and returns their paths
+func writeReferences(b *testing.B, count int) []string {
+	b.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 3000, 2000))
+	for y := 0; y < 2000; y++ {
+		for x := 0; x < 3000; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * y), G: uint8(x + y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	dir := b.TempDir()
+	first := filepath.Join(dir, "ref_1.jpg")
+	f, err := os.Create(first)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 90}); err != nil {
+		b.Fatal(err)
+	}
+	f.Close()
+	data, err := os.ReadFile(first)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	paths := []string{first}
+	for i := 2; i <= count; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("ref_%d.jpg", i))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// samplePeakHeap samples the heap every millisecond until the returned
+// function is called, which returns the largest size seen in bytes
+func samplePeakHeap() func() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	done := make(chan struct{})
+	result := make(chan uint64)
+	go func() {
+		var peak uint64
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			metrics.Read(sample)
+			if heap := sample[0].Value.Uint64(); heap > peak {
+				peak = heap
+			}
+			select {
+			case <-done:
+				result <- peak
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		return <-result
+	}
+}
+
+// BenchmarkConvertImagesToDataURLs prepares large reference sets from
+// scratch, each image decoded, downscaled, and re-encoded, and reports the
+// peak heap size along the way
+func BenchmarkConvertImagesToDataURLs(b *testing.B) {
+	for _, count := range []int{3, 10, 30} {
+		b.Run(fmt.Sprintf("refs=%d", count), func(b *testing.B) {
+			paths := writeReferences(b, count)
+			root := b.TempDir()
+			stop := samplePeakHeap()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// A fresh storage has no prepared inputs to reuse
+				b.StopTimer()
+				g := NewGenerator(nil, storage.NewStorage(root), false)
+				b.StartTimer()
+				if _, _, err := g.convertImagesToDataURLs(paths); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(stop())/(1<<20), "peak-heap-MB")
+		})
+	}
+}
+
+// BenchmarkConvertImagesToDataURLsCached prepares the same reference sets
+// again, as a sweep does, reusing the inputs prepared the first time
+func BenchmarkConvertImagesToDataURLsCached(b *testing.B) {
+	for _, count := range []int{3, 10, 30} {
+		b.Run(fmt.Sprintf("refs=%d", count), func(b *testing.B) {
+			paths := writeReferences(b, count)
+			g := NewGenerator(nil, storage.NewStorage(b.TempDir()), false)
+			if _, _, err := g.convertImagesToDataURLs(paths); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := g.convertImagesToDataURLs(paths); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/generation"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleCreateABTest handles the create_ab_test tool: it generates two
+// candidates and presents them in random order, labeled only A and B
+func (h *ReplicateImageHandler) handleCreateABTest(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	prompt, ok := args["prompt"].(string)
+	if !ok || prompt == "" {
+		return h.errorResponse("create_ab_test", "invalid_parameters", "prompt parameter is required", nil)
+	}
+
+	first := generation.GenerateParams{Prompt: prompt, Model: "flux-schnell", OutputFormat: "png", UseCache: h.cache}
+	if aspectRatio, ok := args["aspect_ratio"].(string); ok {
+		first.AspectRatio = aspectRatio
+	}
+	if width, ok := args["width"].(float64); ok {
+		first.Width = int(width)
+	}
+	if height, ok := args["height"].(float64); ok {
+		first.Height = int(height)
+	}
+	if sizePreset, ok := args["size_preset"].(string); ok {
+		first.SizePreset = sizePreset
+	}
+	if seed, ok := args["seed"].(float64); ok {
+		first.Seed = int(seed)
+	}
+
+	second := first
+	if model, ok := args["model_a"].(string); ok && model != "" {
+		first.Model = model
+	}
+	second.Model = first.Model
+	if model, ok := args["model_b"].(string); ok && model != "" {
+		second.Model = model
+	}
+	if promptB, ok := args["prompt_b"].(string); ok && promptB != "" {
+		second.Prompt = promptB
+	}
+	if first.Model == second.Model && first.Prompt == second.Prompt {
+		return h.errorResponse("create_ab_test", "invalid_parameters", "candidates must differ: set model_b or prompt_b", nil)
+	}
+
+	// Blind the comparison: which request becomes A is random
+	var coin [1]byte
+	if _, err := rand.Read(coin[:]); err != nil {
+		return h.errorResponse("create_ab_test", "internal_error", err.Error(), nil)
+	}
+	if coin[0]&1 == 1 {
+		first, second = second, first
+	}
+
+	// Generate both candidates concurrently
+	requests := [2]generation.GenerateParams{first, second}
+	var results [2]*generation.ImageResult
+	var errs [2]error
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = h.generator.GenerateImage(ctx, requests[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return h.toolErrorResponse("create_ab_test", "generation_error", err)
+		}
+	}
+
+	testID, err := storage.NewABTestID()
+	if err != nil {
+		return h.errorResponse("create_ab_test", "internal_error", err.Error(), nil)
+	}
+	test := &storage.ABTest{ID: testID, Created: time.Now()}
+	var notes []string
+	for i, label := range []string{storage.ABWinnerA, storage.ABWinnerB} {
+		result := results[i]
+		if !result.Cached {
+			notes = append(notes, h.addContentCredentials(ctx, result.ID)...)
+		}
+		test.Candidates = append(test.Candidates, storage.ABCandidate{
+			Label:     label,
+			StorageID: result.ID,
+			Model:     result.Model,
+			Prompt:    result.Prompt,
+			FilePath:  result.FilePath,
+		})
+	}
+
+	// The candidates remain usable when they cannot be composed (e.g. a
+	// model that only returns WebP)
+	compositePath := h.storage.ABCompositePath(testID)
+	if err := storage.ComposeAB(test.Candidates[0].FilePath, test.Candidates[1].FilePath, compositePath); err != nil {
+		slog.Warn("failed to compose A/B candidates", "test_id", testID, "error", err)
+		notes = append(notes, "could not compose the candidates side by side: "+err.Error())
+	} else {
+		test.CompositePath = compositePath
+	}
+
+	if err := h.storage.SaveABTest(test); err != nil {
+		return h.errorResponse("create_ab_test", "storage_error", err.Error(), nil)
+	}
+
+	// Models and prompts stay hidden until a choice is recorded
+	data := map[string]interface{}{
+		"test_id":        testID,
+		"composite_path": test.CompositePath,
+		"candidates": map[string]string{
+			storage.ABWinnerA: test.Candidates[0].FilePath,
+			storage.ABWinnerB: test.Candidates[1].FilePath,
+		},
+	}
+	if shareURL := h.files.URL(test.CompositePath); shareURL != "" {
+		data["share_url"] = shareURL
+	}
+	if len(notes) > 0 {
+		data["notes"] = notes
+	}
+	message := fmt.Sprintf("Created A/B test %s. Compare A and B, then call record_ab_choice with the winner.", testID)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("create_ab_test", message, data))
+}
+
+// handleRecordABChoice handles the record_ab_choice tool
+func (h *ReplicateImageHandler) handleRecordABChoice(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	testID, ok := args["test_id"].(string)
+	if !ok || testID == "" {
+		return h.errorResponse("record_ab_choice", "invalid_parameters", "test_id is required", nil)
+	}
+	winner, _ := args["winner"].(string)
+	if winner != storage.ABWinnerA && winner != storage.ABWinnerB && winner != storage.ABWinnerTie {
+		return h.errorResponse("record_ab_choice", "invalid_parameters", "winner must be one of: A, B, tie", nil)
+	}
+
+	test, err := h.storage.LoadABTest(testID)
+	if err != nil {
+		return h.errorResponse("record_ab_choice", "not_found", err.Error(), nil)
+	}
+	if test.Winner != "" {
+		return h.errorResponse("record_ab_choice", "invalid_parameters", fmt.Sprintf("A/B test %s was already decided (%s)", testID, test.Winner), nil)
+	}
+
+	now := time.Now()
+	test.Winner = winner
+	test.DecidedAt = &now
+	if err := h.storage.SaveABTest(test); err != nil {
+		return h.errorResponse("record_ab_choice", "storage_error", err.Error(), nil)
+	}
+
+	report, err := h.storage.ABPreferenceReport()
+	if err != nil {
+		return h.errorResponse("record_ab_choice", "storage_error", err.Error(), nil)
+	}
+
+	message := fmt.Sprintf("Recorded %s for A/B test %s", winner, testID)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("record_ab_choice", message, map[string]interface{}{
+		"test_id":    testID,
+		"winner":     winner,
+		"candidates": test.Candidates,
+		"report":     report,
+	}))
+}
+
+// handleABReport handles the ab_report tool
+func (h *ReplicateImageHandler) handleABReport(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	report, err := h.storage.ABPreferenceReport()
+	if err != nil {
+		return h.errorResponse("ab_report", "storage_error", err.Error(), nil)
+	}
+
+	message := fmt.Sprintf("%d A/B tests, %d decided", report.Tests, report.Decided)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("ab_report", message, map[string]interface{}{
+		"report": report,
+	}))
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// animationConcurrency bounds the frames of an animation processed at once
+const animationConcurrency = 4
+
+// frameProcessor enhances one frame of an animation, storing the result as
+// its own operation
+type frameProcessor func(ctx context.Context, framePath string) (*enhancement.EnhancementResult, error)
+
+// processAnimation enhances every frame of an animated input with process
+// and reassembles the results with the original timing. The frames sent to
+// the model are kept in a frames directory of the animation's operation;
+// notes are added to the response.
+func (h *ReplicateImageHandler) processAnimation(ctx context.Context, operation, filePath, filename, suffix string, anim *storage.Animation, notes []string, process frameProcessor) (*protocol.CallToolResponse, error) {
+	id, err := h.storage.OperationID(ctx)
+	if err != nil {
+		return h.errorResponse(operation, "storage_error", err.Error(), nil)
+	}
+	defer h.storage.CleanupIfEmpty(id)
+	dir := h.storage.OperationDir(id)
+	framesDir := filepath.Join(dir, "frames")
+
+	framePaths, err := writeFrames(framesDir, anim)
+	if err != nil {
+		os.RemoveAll(framesDir)
+		return h.errorResponse(operation, "storage_error", err.Error(), nil)
+	}
+
+	// Stop the remaining frames once one fails
+	frameCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]*enhancement.EnhancementResult, len(framePaths))
+	errs := make([]error, len(framePaths))
+	slots := make(chan struct{}, animationConcurrency)
+	var wg sync.WaitGroup
+	for i, path := range framePaths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if err := frameCtx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			results[i], errs[i] = process(frameCtx, path)
+			// A dry run plans every frame
+			if errs[i] != nil && !errors.Is(errs[i], client.ErrDryRun) {
+				cancel()
+			}
+		}(i, path)
+	}
+	wg.Wait()
+
+	totalCost, predictTime := 0.0, 0.0
+	frameIDs := make([]string, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			totalCost += result.Metrics.Cost
+			predictTime += result.Metrics.PredictTime
+			frameIDs = append(frameIDs, result.ID)
+		}
+	}
+	if i, err := firstFrameError(errs); err != nil {
+		os.RemoveAll(framesDir)
+		resp, respErr := h.toolErrorResponse(operation, "processing_error", fmt.Errorf("frame %d of %d: %w", i+1, len(framePaths), err))
+		return withResponseFields(resp, respErr, map[string]interface{}{"frame_ids": frameIDs, "total_cost": totalCost})
+	}
+
+	// Reassemble the processed frames, which must all come back one size
+	out := &storage.Animation{Format: anim.Format, Delays: anim.Delays, LoopCount: anim.LoopCount}
+	for i, result := range results {
+		frame, err := storage.LoadFrame(result.OutputPath)
+		if err != nil {
+			os.RemoveAll(framesDir)
+			return h.errorResponse(operation, "processing_error", fmt.Sprintf("frame %d: %s", i+1, err), nil)
+		}
+		if i > 0 && frame.Bounds().Size() != out.Frames[0].Bounds().Size() {
+			os.RemoveAll(framesDir)
+			return h.errorResponse(operation, "processing_error",
+				fmt.Sprintf("frame %d came back %v, unlike frame 1 at %v", i+1, frame.Bounds().Size(), out.Frames[0].Bounds().Size()), nil)
+		}
+		out.Frames = append(out.Frames, frame)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + "_" + suffix
+	if filename != "" {
+		base = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	outputPath := filepath.Join(dir, base+out.Ext())
+	if err := out.Save(outputPath); err != nil {
+		os.RemoveAll(framesDir)
+		return h.errorResponse(operation, "storage_error", err.Error(), nil)
+	}
+
+	// Record the options the frames were processed with, against the
+	// animation rather than a frame
+	parameters := make(map[string]interface{}, len(results[0].Parameters))
+	for k, v := range results[0].Parameters {
+		parameters[k] = v
+	}
+	parameters["input_path"] = filePath
+	if filename != "" {
+		parameters["filename"] = filename
+	} else {
+		delete(parameters, "filename")
+	}
+	if _, err := h.storage.FinalizeFiles(id, operation, parameters, ""); err != nil {
+		return h.errorResponse(operation, "storage_error", err.Error(), nil)
+	}
+	notes = append(notes, h.addContentCredentials(ctx, id)...)
+
+	result := map[string]interface{}{
+		"id":           id,
+		"file_path":    outputPath,
+		"format":       anim.Format,
+		"frames":       len(out.Frames),
+		"frame_ids":    frameIDs,
+		"total_cost":   totalCost,
+		"predict_time": predictTime,
+		"paths":        map[string]string{"directory": dir, "frames": framesDir},
+	}
+	if url := h.files.URL(outputPath); url != "" {
+		result["share_url"] = url
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Processed %d frames of the animation into %s", len(out.Frames), outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse(operation, message, result))
+}
+
+// writeFrames writes each frame of anim as a PNG into dir
+func writeFrames(dir string, anim *storage.Animation) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create frames directory: %w", err)
+	}
+	paths := make([]string, len(anim.Frames))
+	for i, frame := range anim.Frames {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("frame_%03d.png", i+1))
+		file, err := os.Create(paths[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to write frame %d: %w", i+1, err)
+		}
+		err = png.Encode(file, frame)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write frame %d: %w", i+1, err)
+		}
+	}
+	return paths, nil
+}
+
+// firstFrameError returns the first frame error that is not a cancellation
+// caused by another frame failing
+func firstFrameError(errs []error) (int, error) {
+	first := -1
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return i, err
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	if first >= 0 {
+		return first, errs[first]
+	}
+	return -1, nil
+}
+
+// animationNotes lists the options an animated input ignores
+func animationNotes(args map[string]interface{}, options ...string) []string {
+	var notes []string
+	for _, option := range options {
+		if v, ok := args[option]; ok && v != false && v != "" {
+			notes = append(notes, fmt.Sprintf("%s is not applied to animations", option))
+		}
+	}
+	return notes
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// maxContinueWait is the longest continue_operation waits for an operation
+const maxContinueWait = 30 * time.Second
+
+// pendingExpiry is how long the result of a background operation is kept
+// for continue_operation after it finishes
+const pendingExpiry = time.Hour
+
+// pendingOp is a tool call that outlived its initial wait and finishes in
+// the background
+type pendingOp struct {
+	tool        string
+	args        map[string]interface{}
+	started     time.Time
+	predictions *client.PredictionLog
+	resumed     []client.LoggedPrediction // Predictions of the run before a restart
+	offPeak     bool // Queued for the off-peak window, and queued again after a restart
+	outputs     *storage.OutputLog // Files saved so far
+	done        chan struct{} // Closed once resp and err are set
+	resp        *protocol.CallToolResponse
+	err         error
+	finished    time.Time
+
+	mu        sync.Mutex // Orders saving the operation with collecting it, and guards the logs
+	collected bool
+}
+
+// start runs the operation in the background
+func (op *pendingOp) start(run func() (*protocol.CallToolResponse, error)) {
+	go func() {
+		op.resp, op.err = run()
+		op.finished = time.Now()
+		close(op.done)
+	}()
+}
+
+// logs returns the logs of the predictions the operation created and the
+// files it saved, which a queued operation replaces when it starts
+func (op *pendingOp) logs() (*client.PredictionLog, *storage.OutputLog) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.predictions, op.outputs
+}
+
+// pendingRegistry holds the background operations until continue_operation
+// collects their results. The zero value is ready to use.
+type pendingRegistry struct {
+	mu  sync.Mutex
+	ops map[string]*pendingOp
+}
+
+// add registers an operation under the first prediction it created, or under
+// a random ID when it has not created one yet, and returns that ID. Random
+// IDs cannot collide with those of operations restored after a restart.
+// Results nobody collected within pendingExpiry are dropped.
+func (r *pendingRegistry) add(op *pendingOp) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ops == nil {
+		r.ops = make(map[string]*pendingOp)
+	}
+	for id, old := range r.ops {
+		select {
+		case <-old.done:
+			if time.Since(old.finished) > pendingExpiry {
+				delete(r.ops, id)
+			}
+		default:
+		}
+	}
+
+	var token [6]byte
+	id := fmt.Sprintf("pending-%d", time.Now().UnixNano())
+	if _, err := rand.Read(token[:]); err == nil {
+		id = "pending-" + hex.EncodeToString(token[:])
+	}
+	if ids := op.predictions.IDs(); len(ids) > 0 {
+		id = ids[0]
+	}
+	r.ops[id] = op
+	return id
+}
+
+// put registers an operation under a known ID, such as one restored after
+// a restart
+func (r *pendingRegistry) put(id string, op *pendingOp) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ops == nil {
+		r.ops = make(map[string]*pendingOp)
+	}
+	r.ops[id] = op
+}
+
+// get returns the operation registered under id, or nil
+func (r *pendingRegistry) get(id string) *pendingOp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ops[id]
+}
+
+// activePaths returns the files saved so far by the operations still
+// running
+func (r *pendingRegistry) activePaths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var paths []string
+	for _, op := range r.ops {
+		select {
+		case <-op.done:
+			continue
+		default:
+		}
+		if _, outputs := op.logs(); outputs != nil {
+			paths = append(paths, outputs.Paths()...)
+		}
+	}
+	return paths
+}
+
+// remove forgets a collected operation
+func (r *pendingRegistry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.ops, id)
+}
+
+// initialWait returns how long a call waits for its result before returning
+// a processing response, or zero when it waits for the result. Generation,
+// enhancement, and editing tools, the ones accepting dry_run, return early
+// unless the call is a dry run or set max_wait_seconds to wait longer.
+func (h *ReplicateImageHandler) initialWait(req *protocol.CallToolRequest) time.Duration {
+	if h.timeouts.InitialWait <= 0 || !dryRunTools[req.Name] {
+		return 0
+	}
+	if dryRun, _ := req.Arguments["dry_run"].(bool); dryRun {
+		return 0
+	}
+	if _, ok := req.Arguments["max_wait_seconds"]; ok {
+		return 0
+	}
+	return h.timeouts.InitialWait
+}
+
+// runAsync runs a tool call in the background, returning its result if it
+// finishes within wait and a processing response naming it otherwise.
+// created and saved log the predictions run creates and the files it saves.
+func (h *ReplicateImageHandler) runAsync(req *protocol.CallToolRequest, wait time.Duration, created *client.PredictionLog, saved *storage.OutputLog, run func() (*protocol.CallToolResponse, error)) (*protocol.CallToolResponse, error) {
+	op := &pendingOp{tool: req.Name, args: req.Arguments, started: time.Now(), predictions: created, outputs: saved, done: make(chan struct{})}
+	op.start(run)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-op.done:
+		return op.resp, op.err
+	case <-timer.C:
+	}
+	id := h.pending.add(op)
+	h.trackPending(id, op)
+	return h.processingResponse(op, id)
+}
+
+// processingResponse reports an operation still running in the background,
+// with an estimate of the time its current prediction has left and the files
+// it saved so far, which are usable before it finishes
+func (h *ReplicateImageHandler) processingResponse(op *pendingOp, id string) (*protocol.CallToolResponse, error) {
+	if position := h.offPeak.position(id); position > 0 {
+		resp, err := h.successResponse(responses.BuildProcessingResponse(op.tool, id, "", 0))
+		return withResponseFields(resp, err, h.offPeak.status(id, position))
+	}
+	predictions, outputs := op.logs()
+	resp, err := h.successResponse(responses.BuildProcessingResponse(op.tool, id, "", h.estimateRemaining(predictions)))
+	if paths := outputs.Paths(); len(paths) > 0 {
+		resp, err = withResponseFields(resp, err, map[string]interface{}{"completed_files": paths})
+	}
+	return resp, err
+}
+
+// estimateRemaining estimates the seconds left for the latest prediction in
+// created from its model's recent durations in the ledger, or returns 0 when
+// there is no estimate
+func (h *ReplicateImageHandler) estimateRemaining(created *client.PredictionLog) int {
+	prediction, ok := created.Last()
+	if !ok {
+		return 0
+	}
+	entries, err := h.storage.ReadLedger()
+	if err != nil {
+		return 0
+	}
+	latency := storage.ModelLatency(entries, prediction.Model, time.Now().Add(-probeWindow), probeSamples)
+	if latency == nil {
+		return 0
+	}
+	return latency.EstimateRemaining(time.Since(prediction.CreatedAt))
+}
+
+// handleContinueOperation handles the continue_operation tool
+func (h *ReplicateImageHandler) handleContinueOperation(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["prediction_id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("continue_operation", "invalid_parameters", "prediction_id parameter is required", nil)
+	}
+	op := h.pending.get(id)
+	if op == nil {
+		return h.errorResponse("continue_operation", "not_found",
+			fmt.Sprintf("no operation in progress with prediction_id %s; its result may already have been returned, or was dropped an hour after it finished", id), nil)
+	}
+
+	// A call waiting for the off-peak window will not finish within the wait
+	if h.offPeak.position(id) > 0 {
+		return h.processingResponse(op, id)
+	}
+
+	wait := maxContinueWait
+	if seconds, ok := args["wait_time"].(float64); ok {
+		wait = min(max(time.Duration(seconds*float64(time.Second)), 0), maxContinueWait)
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-op.done:
+		h.collectPending(id, op)
+		return op.resp, op.err
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return h.processingResponse(op, id)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleAutoCrop handles the auto_crop tool: it trims the transparent border
+// of a cutout down to the subject
+func (h *ReplicateImageHandler) handleAutoCrop(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("auto_crop", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+	opts, err := autoCropOptions(args["padding"], args["aspect_ratio"])
+	if err != nil {
+		return h.errorResponse("auto_crop", "invalid_parameters", err.Error(), nil)
+	}
+	filename, _ := args["filename"].(string)
+
+	info, err := h.saveAutoCrop(ctx, filePath, opts, filename)
+	if err != nil {
+		return h.errorResponse("auto_crop", "processing_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	message := fmt.Sprintf("Cropped to the subject: %s", info["file_path"])
+	return h.successResponse(responses.BuildSimpleSuccessResponse("auto_crop", message, info))
+}
+
+// autoCropOptions reads the padding and aspect ratio arguments of a crop
+func autoCropOptions(padding, aspectRatio interface{}) (storage.AutoCropOptions, error) {
+	var opts storage.AutoCropOptions
+	if value, ok := padding.(float64); ok {
+		opts.Padding = int(value)
+	}
+	if opts.Padding < 0 {
+		return opts, fmt.Errorf("padding must not be negative")
+	}
+	if ratio, ok := aspectRatio.(string); ok && ratio != "" {
+		if _, _, err := storage.ParseAspectRatio(ratio); err != nil {
+			return opts, err
+		}
+		opts.AspectRatio = ratio
+	}
+	return opts, nil
+}
+
+// saveAutoCrop crops the image at path to its visible content and stores the
+// crop as an auto_crop operation, returning its details for a response
+func (h *ReplicateImageHandler) saveAutoCrop(ctx context.Context, path string, opts storage.AutoCropOptions, filename string) (map[string]interface{}, error) {
+	data, bounds, err := storage.AutoCrop(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if filename == "" {
+		filename = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + "_cropped"
+	}
+	filename = strings.TrimSuffix(filepath.Base(filename), ".png") + ".png"
+	parameters := map[string]interface{}{
+		"input_path": path,
+		"padding":    opts.Padding,
+	}
+	if opts.AspectRatio != "" {
+		parameters["aspect_ratio"] = opts.AspectRatio
+	}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "auto_crop", parameters, filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]interface{}{
+		"id":             id,
+		"file_path":      outputPath,
+		"subject_bounds": cropInfo(bounds),
+	}
+	if shareURL := h.files.URL(outputPath); shareURL != "" {
+		info["share_url"] = shareURL
+	}
+	if notes := h.addContentCredentials(ctx, id); len(notes) > 0 {
+		info["notes"] = notes
+	}
+	return info, nil
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+)
+
+// batchItem is the generate_image call made for one prompt of a batch
+type batchItem struct {
+	prompt string
+	op     *pendingOp // Nil when the call could not start
+}
+
+// handleBatchGenerate handles the batch_generate tool: it runs generate_image
+// for every prompt, up to batchSize at a time, with the other arguments
+// shared. Calls still running after the initial wait finish in the
+// background, each under its own prediction_id for continue_operation.
+func (h *ReplicateImageHandler) handleBatchGenerate(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	raw, _ := args["prompts"].([]interface{})
+	if len(raw) == 0 {
+		return h.errorResponse("batch_generate", "invalid_parameters", "prompts must list at least one prompt", nil)
+	}
+	prompts := make([]string, len(raw))
+	for i, v := range raw {
+		prompt, _ := v.(string)
+		if strings.TrimSpace(prompt) == "" {
+			return h.errorResponse("batch_generate", "invalid_parameters", fmt.Sprintf("prompt %d is empty", i+1), nil)
+		}
+		prompts[i] = prompt
+	}
+	base := ""
+	if filename, ok := args["filename"].(string); ok && filename != "" {
+		base = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+
+	// 1. Start every prompt as its own call, which outlives this one when it
+	// runs past the initial wait. Calls past batchSize wait for a slot.
+	items := make([]batchItem, len(prompts))
+	slots := make(chan struct{}, h.batchSize)
+	var wait time.Duration
+	for i, prompt := range prompts {
+		itemArgs := make(map[string]interface{}, len(args))
+		for k, v := range args {
+			if k != "prompts" {
+				itemArgs[k] = v
+			}
+		}
+		itemArgs["prompt"] = prompt
+		if base != "" {
+			itemArgs["filename"] = fmt.Sprintf("%s_%d", base, i+1)
+		}
+		req := &protocol.CallToolRequest{Name: "generate_image", Arguments: itemArgs}
+		wait = h.initialWait(req)
+
+		items[i].prompt = prompt
+		run, predictions, outputs, ok := h.startCall(context.WithoutCancel(ctx), req)
+		if !ok {
+			continue
+		}
+		op := &pendingOp{tool: req.Name, args: itemArgs, started: time.Now(), predictions: predictions, outputs: outputs, done: make(chan struct{})}
+		op.start(func() (*protocol.CallToolResponse, error) {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			return run()
+		})
+		items[i].op = op
+	}
+
+	// 2. Wait for them together; zero waits for every result
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+waiting:
+	for _, item := range items {
+		if item.op == nil {
+			continue
+		}
+		select {
+		case <-item.op.done:
+		case <-timeout:
+			break waiting
+		case <-ctx.Done():
+			break waiting
+		}
+	}
+
+	// 3. Report the finished calls and hand the others to continue_operation
+	var results []map[string]interface{}
+	var predictionIDs []string
+	succeeded, failed := 0, 0
+	totalCost := 0.0
+	for i, item := range items {
+		info := map[string]interface{}{"index": i + 1, "prompt": item.prompt}
+		results = append(results, info)
+		if item.op == nil {
+			info["status"] = "failed"
+			info["error"] = "the server is shutting down"
+			failed++
+			continue
+		}
+		select {
+		case <-item.op.done:
+		default:
+			id := h.pending.add(item.op)
+			h.trackPending(id, item.op)
+			info["status"] = "processing"
+			info["prediction_id"] = id
+			predictionIDs = append(predictionIDs, id)
+			continue
+		}
+
+		result := nestedResult(item.op.resp, item.op.err)
+		if !result.Success {
+			info["status"] = "failed"
+			info["error"] = result.Error
+			failed++
+			continue
+		}
+		output := result.Output
+		info["status"] = "succeeded"
+		info["id"] = output["id"]
+		info["paths"] = output["paths"]
+		cost := result.Cost
+		if cached, _ := output["cached"].(bool); cached {
+			cost = 0
+			info["cached"] = true
+		}
+		info["cost"] = cost
+		totalCost += cost
+		succeeded++
+	}
+	if succeeded == 0 && len(predictionIDs) == 0 {
+		return h.errorResponse("batch_generate", "generation_error", fmt.Sprintf("all %d prompts failed", len(items)), map[string]interface{}{"results": results})
+	}
+
+	response := map[string]interface{}{
+		"results":    results,
+		"succeeded":  succeeded,
+		"failed":     failed,
+		"processing": len(predictionIDs),
+		"total_cost": totalCost,
+	}
+	message := fmt.Sprintf("Generated %d of %d prompts", succeeded, len(items))
+	if failed > 0 {
+		message += fmt.Sprintf("; %d failed", failed)
+	}
+	if len(predictionIDs) > 0 {
+		response["prediction_ids"] = predictionIDs
+		message += fmt.Sprintf("; %d still processing. Use continue_operation with each of prediction_ids to collect them.", len(predictionIDs))
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("batch_generate", message, response))
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleBlurBackground handles the blur_background tool: it segments the
+// subject, optionally estimates depth, and blurs the background locally
+func (h *ReplicateImageHandler) handleBlurBackground(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("blur_background", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+
+	opts := storage.BokehOptions{Strength: 0.5, Feather: 0.3}
+	if strength, ok := args["strength"].(float64); ok {
+		opts.Strength = strength
+	}
+	if feather, ok := args["feather"].(float64); ok {
+		opts.Feather = feather
+	}
+	if opts.Strength <= 0 || opts.Strength > 1 || opts.Feather < 0 || opts.Feather > 1 {
+		return h.errorResponse("blur_background", "invalid_parameters", "strength must be between 0 (exclusive) and 1, and feather between 0 and 1", nil)
+	}
+	useDepth := true
+	if depth, ok := args["depth_aware"].(bool); ok {
+		useDepth = depth
+	}
+	segmentationModel := "remove-bg"
+	if model, ok := args["segmentation_model"].(string); ok && model != "" {
+		segmentationModel = model
+	}
+
+	var notes []string
+	steps := map[string]interface{}{}
+
+	// 1. Segment the subject
+	cutout, err := h.enhancer.RemoveBackground(ctx, enhancement.RemoveBackgroundParams{
+		ImagePath: filePath,
+		Model:     segmentationModel,
+	})
+	if err != nil {
+		return h.toolErrorResponse("blur_background", "processing_error", err)
+	}
+	notes = append(notes, cutout.Notes...)
+	steps["cutout_id"] = cutout.ID
+	steps["cutout_path"] = cutout.OutputPath
+
+	// 2. Estimate depth; without it the background is blurred evenly
+	depthPath := ""
+	if useDepth {
+		depth, err := h.enhancer.EstimateDepth(ctx, enhancement.DepthParams{ImagePath: filePath})
+		if err != nil {
+			notes = append(notes, "depth estimation failed, blurring the background evenly: "+err.Error())
+		} else {
+			depthPath = depth.OutputPath
+			steps["depth_id"] = depth.ID
+			steps["depth_path"] = depth.OutputPath
+		}
+	}
+
+	// 3. Blur locally
+	data, err := storage.BlurBackground(filePath, cutout.OutputPath, depthPath, opts)
+	if err != nil {
+		return h.errorResponse("blur_background", "processing_error", err.Error(), steps)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	filename := base + "_bokeh.png"
+	if name, ok := args["filename"].(string); ok && name != "" {
+		filename = strings.TrimSuffix(filepath.Base(name), ".png") + ".png"
+	}
+	parameters := map[string]interface{}{
+		"input_path":         filePath,
+		"strength":           opts.Strength,
+		"feather":            opts.Feather,
+		"depth_aware":        depthPath != "",
+		"segmentation_model": segmentationModel,
+	}
+	for key, value := range steps {
+		parameters[key] = value
+	}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "blur_background", parameters, filename, data)
+	if err != nil {
+		return h.errorResponse("blur_background", "storage_error", err.Error(), steps)
+	}
+	notes = append(notes, h.addContentCredentials(ctx, id)...)
+
+	paths := map[string]string{
+		"input_path": filePath,
+		"file_path":  outputPath,
+	}
+	if shareURL := h.files.URL(outputPath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
+	result := map[string]interface{}{
+		"id":    id,
+		"paths": paths,
+		"steps": steps,
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	mode := "evenly"
+	if depthPath != "" {
+		mode = "by depth"
+	}
+	message := fmt.Sprintf("Blurred the background %s: %s", mode, outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("blur_background", message, result))
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleGenerateBranded handles the generate_branded tool: generate_image
+// with the configured brand kit applied
+func (h *ReplicateImageHandler) handleGenerateBranded(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	if h.brand == nil {
+		return h.errorResponse("generate_branded", "not_configured", "no brand kit is configured (set BRAND_KIT to a brand kit file)", nil)
+	}
+
+	prompt, ok := args["prompt"].(string)
+	if !ok || prompt == "" {
+		return h.errorResponse("generate_branded", "invalid_parameters", "prompt parameter is required", nil)
+	}
+	if banned := h.brand.BannedTermsIn(prompt); len(banned) > 0 {
+		return h.errorResponse("generate_branded", "brand_violation",
+			fmt.Sprintf("prompt contains terms the %s brand kit bans: %s", h.brand.Name, strings.Join(banned, ", ")),
+			map[string]interface{}{"banned_terms": banned})
+	}
+
+	params := h.generateParams(h.brand.ApplyToPrompt(prompt), args)
+	// The banned terms add to the preset negative prompt rather than replace it
+	if err := h.generator.ApplyNegativePreset(&params); err != nil {
+		return h.toolErrorResponse("generate_branded", "generation_error", err)
+	}
+	params.NegativePrompt = h.brand.NegativePrompt(params.NegativePrompt)
+	addLogo := h.brand.LogoImage() != nil
+	if noLogo, ok := args["skip_logo"].(bool); ok && noLogo {
+		addLogo = false
+	}
+
+	// The palette check and logo need an output Go can decode
+	if params.OutputFormat == "" || params.OutputFormat == "webp" {
+		params.OutputFormat = "png"
+	}
+
+	result, err := h.generator.GenerateImage(ctx, params)
+	if err != nil {
+		return h.toolErrorResponse("generate_branded", "generation_error", err)
+	}
+
+	files := result.FilePaths
+	if len(files) == 0 {
+		files = []string{result.FilePath}
+	}
+
+	// Measure the palette of the generation itself, before the logo
+	brandInfo := map[string]interface{}{"kit": h.brand.Name}
+	if h.brand.HasPalette() {
+		var reports []interface{}
+		for _, path := range files {
+			report, err := h.brand.CheckPalette(path)
+			if err != nil {
+				result.Notes = append(result.Notes, "palette check skipped: "+err.Error())
+				continue
+			}
+			reports = append(reports, map[string]interface{}{
+				"file_path": path,
+				"palette":   report,
+			})
+			if !report.OnBrand {
+				result.Notes = append(result.Notes, fmt.Sprintf("%s is off-palette: %.0f%% of pixels match the brand palette", path, report.Coverage*100))
+			}
+		}
+		brandInfo["palette_checks"] = reports
+	}
+
+	// Cached results already carry the logo and credentials
+	if !result.Cached {
+		if addLogo {
+			_, err := h.storage.RewriteOutputs(result.ID, func(src, dst string) error {
+				return storage.OverlayImage(src, dst, h.brand.LogoImage(), h.brand.LogoPosition, h.brand.LogoScale, h.brand.LogoMargin)
+			})
+			if err != nil {
+				slog.Warn("failed to add brand logo", "storage_id", result.ID, "error", err)
+				result.Notes = append(result.Notes, "brand logo was not added: "+err.Error())
+				addLogo = false
+			}
+		}
+		result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+		brandInfo["logo"] = addLogo
+	}
+
+	resp, err := h.successResponse(h.buildGenerationResponse("generate_branded", result))
+	return withResponseFields(resp, err, map[string]interface{}{"brand": brandInfo})
+}
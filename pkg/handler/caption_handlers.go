@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+const (
+	// maxCaptionImages caps the images captioned in one call
+	maxCaptionImages = 500
+	// captionWorkers is the number of captioning predictions run at once
+	captionWorkers = 4
+)
+
+// captionJob is one image's captioning outcome
+type captionJob struct {
+	imagePath string
+	caption   string
+	result    *enhancement.CaptionResult
+	skipped   bool
+	err       error
+}
+
+// handleCaptionFolder handles the caption_folder tool: it captions every
+// image in a directory and writes each caption to a .txt sidecar next to the
+// image, the layout LoRA trainers expect
+func (h *ReplicateImageHandler) handleCaptionFolder(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	dir, ok := args["directory"].(string)
+	if !ok || dir == "" {
+		return h.errorResponse("caption_folder", "invalid_parameters", "directory parameter is required", nil)
+	}
+	model := "blip"
+	if m, ok := args["model"].(string); ok && m != "" {
+		model = m
+	}
+	triggerWord, _ := args["trigger_word"].(string)
+	triggerWord = strings.TrimSpace(triggerWord)
+	prompt, _ := args["prompt"].(string)
+	overwrite, _ := args["overwrite"].(bool)
+	recursive, _ := args["recursive"].(bool)
+
+	images, err := storage.ListImages(dir, recursive)
+	if err != nil {
+		return h.errorResponse("caption_folder", "file_error", err.Error(), map[string]interface{}{"directory": dir})
+	}
+	if len(images) == 0 {
+		return h.errorResponse("caption_folder", "invalid_parameters", "no images found in "+dir, nil)
+	}
+	if len(images) > maxCaptionImages {
+		return h.errorResponse("caption_folder", "invalid_parameters",
+			fmt.Sprintf("found %d images; at most %d can be captioned in one call", len(images), maxCaptionImages), nil)
+	}
+
+	// Caption the images a few at a time
+	jobs := make([]captionJob, len(images))
+	queue := make(chan *captionJob)
+	var wg sync.WaitGroup
+	for i := 0; i < captionWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				h.captionImage(ctx, job, model, prompt, triggerWord, overwrite)
+			}
+		}()
+	}
+	for i, imagePath := range images {
+		jobs[i].imagePath = imagePath
+		queue <- &jobs[i]
+	}
+	close(queue)
+	wg.Wait()
+
+	var results []map[string]interface{}
+	var firstErr error
+	captioned, skipped, failed := 0, 0, 0
+	totalCost := 0.0
+	for _, job := range jobs {
+		info := map[string]interface{}{
+			"file_path":    job.imagePath,
+			"caption_path": storage.CaptionPath(job.imagePath),
+		}
+		switch {
+		case job.err != nil:
+			failed++
+			if firstErr == nil {
+				firstErr = job.err
+			}
+			info["error"] = job.err.Error()
+		case job.skipped:
+			skipped++
+			info["skipped"] = "caption already exists"
+		default:
+			captioned++
+			totalCost += job.result.Metrics.Cost
+			info["caption"] = job.caption
+		}
+		results = append(results, info)
+	}
+	if captioned == 0 && failed > 0 {
+		return h.toolErrorResponse("caption_folder", "processing_error", firstErr)
+	}
+
+	result := map[string]interface{}{
+		"directory":  dir,
+		"model":      model,
+		"captioned":  captioned,
+		"skipped":    skipped,
+		"failed":     failed,
+		"total_cost": totalCost,
+		"results":    results,
+	}
+	if triggerWord != "" {
+		result["trigger_word"] = triggerWord
+	}
+	message := fmt.Sprintf("Captioned %d of %d images in %s", captioned, len(images), dir)
+	if skipped > 0 {
+		message += fmt.Sprintf(" (%d already captioned)", skipped)
+	}
+	if failed > 0 {
+		message += fmt.Sprintf(", %d failed", failed)
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("caption_folder", message, result))
+}
+
+// captionImage captions one image and writes its sidecar, skipping images
+// that already have one unless overwrite is set
+func (h *ReplicateImageHandler) captionImage(ctx context.Context, job *captionJob, model, prompt, triggerWord string, overwrite bool) {
+	sidecar := storage.CaptionPath(job.imagePath)
+	if !overwrite {
+		if _, err := os.Stat(sidecar); err == nil {
+			job.skipped = true
+			return
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		job.err = err
+		return
+	}
+
+	job.result, job.err = h.enhancer.Caption(ctx, enhancement.CaptionParams{
+		ImagePath: job.imagePath,
+		Model:     model,
+		Prompt:    prompt,
+	})
+	if job.err != nil {
+		return
+	}
+	job.caption = job.result.Caption
+	if triggerWord != "" {
+		job.caption = triggerWord + ", " + job.caption
+	}
+	if err := os.WriteFile(sidecar, []byte(job.caption+"\n"), 0644); err != nil {
+		job.err = fmt.Errorf("failed to write caption: %w", err)
+	}
+}
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/workflow"
+)
+
+// maxChainWait caps how long run_chain and chain_status block for a result
+const maxChainWait = 300 * time.Second
+
+// chainRetention is how long a finished chain's status stays available
+const chainRetention = time.Hour
+
+// chainRegistry holds the chains started by run_chain. The zero value is
+// ready to use.
+type chainRegistry struct {
+	mu   sync.Mutex
+	runs map[string]*workflow.Run
+}
+
+// add registers a run, forgetting runs that finished over chainRetention ago
+func (c *chainRegistry) add(id string, run *workflow.Run) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.runs == nil {
+		c.runs = make(map[string]*workflow.Run)
+	}
+	for other, r := range c.runs {
+		if finished := r.Finished(); !finished.IsZero() && time.Since(finished) > chainRetention {
+			delete(c.runs, other)
+		}
+	}
+	c.runs[id] = run
+}
+
+// get returns the run with the given ID, or nil
+func (c *chainRegistry) get(id string) *workflow.Run {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.runs[id]
+}
+
+// wait blocks until every registered run has finished or timeout elapses
+func (c *chainRegistry) wait(timeout time.Duration) {
+	c.mu.Lock()
+	runs := make([]*workflow.Run, 0, len(c.runs))
+	for _, run := range c.runs {
+		runs = append(runs, run)
+	}
+	c.mu.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for _, run := range runs {
+		if !run.Wait(time.Until(deadline)) {
+			return
+		}
+	}
+}
+
+// chainableTool reports whether a tool may be a node of a chain. Chains may
+// not start or poll other chains.
+func (h *ReplicateImageHandler) chainableTool(name string) bool {
+	if name == "run_chain" || name == "chain_status" {
+		return false
+	}
+	tools, _ := h.ListTools(context.Background())
+	for _, tool := range tools.Tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// takesSeed reports whether a tool has a seed argument
+func (h *ReplicateImageHandler) takesSeed(name string) bool {
+	return h.takesArgument(name, "seed")
+}
+
+// takesArgument reports whether a tool's schema defines an argument
+func (h *ReplicateImageHandler) takesArgument(name, arg string) bool {
+	tools, _ := h.ListTools(context.Background())
+	for _, tool := range tools.Tools {
+		if tool.Name != name {
+			continue
+		}
+		var schema struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		}
+		if err := json.Unmarshal(tool.InputSchema, &schema); err != nil {
+			return false
+		}
+		_, ok := schema.Properties[arg]
+		return ok
+	}
+	return false
+}
+
+// handleRunChain handles the run_chain tool: it validates a workflow of tool
+// calls and runs it in the background, returning the chain's status
+func (h *ReplicateImageHandler) handleRunChain(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	raw, ok := args["workflow"]
+	if !ok {
+		return h.errorResponse("run_chain", "invalid_parameters", "workflow parameter is required", nil)
+	}
+	w, err := workflow.Parse(raw)
+	if err != nil {
+		return h.errorResponse("run_chain", "invalid_parameters", err.Error(), nil)
+	}
+	if err := w.Validate(h.chainableTool); err != nil {
+		return h.errorResponse("run_chain", "invalid_parameters", err.Error(), nil)
+	}
+	if seed, ok := args["lock_seed"].(float64); ok {
+		root := int64(seed)
+		w.LockSeed = &root
+	}
+	w.LockSeeds(h.takesSeed)
+
+	var token [8]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return h.errorResponse("run_chain", "processing_error", err.Error(), nil)
+	}
+	id := "chain_" + hex.EncodeToString(token[:])
+	run := workflow.Start(ctx, id, w, h.runChainNode)
+	h.chains.add(id, run)
+
+	return h.chainStatusResponse("run_chain", run, chainWait(args))
+}
+
+// handleChainStatus handles the chain_status tool
+func (h *ReplicateImageHandler) handleChainStatus(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["chain_id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("chain_status", "invalid_parameters", "chain_id parameter is required", nil)
+	}
+	run := h.chains.get(id)
+	if run == nil {
+		return h.errorResponse("chain_status", "not_found", "no chain with ID "+id, nil)
+	}
+	return h.chainStatusResponse("chain_status", run, chainWait(args))
+}
+
+// chainWait reads the wait_seconds argument
+func chainWait(args map[string]interface{}) time.Duration {
+	seconds, _ := args["wait_seconds"].(float64)
+	wait := time.Duration(seconds * float64(time.Second))
+	return min(max(wait, 0), maxChainWait)
+}
+
+// chainStatusResponse waits up to wait for a run to finish and reports its
+// combined status
+func (h *ReplicateImageHandler) chainStatusResponse(operation string, run *workflow.Run, wait time.Duration) (*protocol.CallToolResponse, error) {
+	if wait > 0 {
+		run.Wait(wait)
+	}
+	status := run.Status()
+
+	counts := make(map[string]int)
+	for _, node := range status.Nodes {
+		counts[node.State]++
+	}
+	var message string
+	switch status.State {
+	case workflow.RunRunning:
+		message = fmt.Sprintf("Chain %s is running: %d of %d nodes finished. Call chain_status with chain_id='%s' to check on it.",
+			status.ID, len(status.Nodes)-counts[workflow.StatePending]-counts[workflow.StateRunning], len(status.Nodes), status.ID)
+	case workflow.RunFailed:
+		message = fmt.Sprintf("Chain %s failed: %d succeeded, %d failed, %d skipped",
+			status.ID, counts[workflow.StateSucceeded], counts[workflow.StateFailed], counts[workflow.StateSkipped])
+	default:
+		message = fmt.Sprintf("Chain %s finished: %d succeeded, %d failed, %d skipped",
+			status.ID, counts[workflow.StateSucceeded], counts[workflow.StateFailed], counts[workflow.StateSkipped])
+	}
+
+	result := map[string]interface{}{
+		"chain_id":   status.ID,
+		"status":     status.State,
+		"started_at": status.StartedAt,
+		"duration":   status.Duration,
+		"total_cost": status.TotalCost,
+		"nodes":      status.Nodes,
+	}
+	if status.LockSeed != nil {
+		result["lock_seed"] = *status.LockSeed
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse(operation, message, result))
+}
+
+// runChainNode runs one node of a chain through the tool dispatcher and reads
+// the outcome from its JSON response
+func (h *ReplicateImageHandler) runChainNode(ctx context.Context, tool string, args map[string]interface{}) workflow.Result {
+	ctx, finish, ok := h.drain.begin(ctx, tool, args)
+	if !ok {
+		h.drain.refuse()
+		h.recordInterrupted(tool, args, time.Time{}, nil, "chain node not started before shutdown")
+		return workflow.Result{Error: "the server is shutting down"}
+	}
+	defer finish()
+
+	return nestedResult(h.callTool(ctx, &protocol.CallToolRequest{Name: tool, Arguments: args}))
+}
+
+// nestedResult reads the outcome and cost of a tool call made from within
+// another tool out of its JSON response
+func nestedResult(resp *protocol.CallToolResponse, err error) workflow.Result {
+	if err != nil {
+		return workflow.Result{Error: err.Error()}
+	}
+	if resp == nil || len(resp.Content) == 0 {
+		return workflow.Result{Error: "the tool returned no content"}
+	}
+
+	var output map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &output); err != nil {
+		return workflow.Result{Error: "the tool returned a response that is not JSON"}
+	}
+	result := workflow.Result{Output: output}
+	result.Success, _ = output["success"].(bool)
+	if cost, ok := output["cost_estimate"].(float64); ok {
+		result.Cost = cost
+	} else if cost, ok := output["total_cost"].(float64); ok {
+		result.Cost = cost
+	}
+	if !result.Success {
+		if e, ok := output["error"].(map[string]interface{}); ok {
+			result.Error, _ = e["message"].(string)
+		}
+		if result.Error == "" {
+			result.Error = "the tool did not succeed"
+		}
+	}
+	return result
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// defaultComparedUpscalers are compared when the call names none
+var defaultComparedUpscalers = []string{"realesrgan", "clarity", "supir"}
+
+// upscalerRun is one upscaler's result on the comparison crop
+type upscalerRun struct {
+	model  string
+	result *enhancement.EnhancementResult
+	err    error
+}
+
+// handleCompareUpscalers handles the compare_upscalers tool: it upscales the
+// same crop with several models and composes the results side by side, next
+// to the original crop, with each model's time and cost
+func (h *ReplicateImageHandler) handleCompareUpscalers(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("compare_upscalers", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+
+	var upscalers []string
+	if modelsRaw, ok := args["models"].([]interface{}); ok {
+		for _, m := range modelsRaw {
+			if model, ok := m.(string); ok && model != "" {
+				upscalers = append(upscalers, model)
+			}
+		}
+	}
+	if len(upscalers) == 0 {
+		upscalers = defaultComparedUpscalers
+	}
+	// The original crop takes the first tile
+	if len(upscalers) > storage.MaxComparisonTiles-1 {
+		return h.errorResponse("compare_upscalers", "invalid_parameters",
+			fmt.Sprintf("at most %d models can be compared at once", storage.MaxComparisonTiles-1), nil)
+	}
+	for _, model := range upscalers {
+		if !models.HasAlias(models.OpUpscale, model) {
+			return h.errorResponse("compare_upscalers", "invalid_parameters", "unknown upscaler: "+model, nil)
+		}
+	}
+
+	scale := 2
+	if value, ok := args["scale"].(float64); ok {
+		scale = int(value)
+	}
+	if scale != 2 && scale != 4 {
+		return h.errorResponse("compare_upscalers", "invalid_parameters", "scale must be 2 or 4", nil)
+	}
+
+	var region image.Rectangle
+	if crop, ok := args["crop"].(map[string]interface{}); ok {
+		x, _ := crop["x"].(float64)
+		y, _ := crop["y"].(float64)
+		width, _ := crop["width"].(float64)
+		height, _ := crop["height"].(float64)
+		if width <= 0 || height <= 0 {
+			return h.errorResponse("compare_upscalers", "invalid_parameters", "crop width and height must be positive", nil)
+		}
+		region = image.Rect(int(x), int(y), int(x+width), int(y+height))
+	}
+
+	// 1. Cut the crop out and store it, so every upscaler reads the same file
+	data, region, err := storage.CropImage(filePath, region)
+	if err != nil {
+		return h.errorResponse("compare_upscalers", "file_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	cropParams := map[string]interface{}{
+		"input_path": filePath,
+		"crop":       cropInfo(region),
+	}
+	cropID, cropPath, err := h.storage.SaveLocalResult(ctx, "crop_image", cropParams, "crop.png", data)
+	if err != nil {
+		return h.errorResponse("compare_upscalers", "storage_error", err.Error(), nil)
+	}
+
+	// 2. Upscale it with every model at once
+	runs := make([]upscalerRun, len(upscalers))
+	var wg sync.WaitGroup
+	for i, model := range upscalers {
+		wg.Add(1)
+		go func(run *upscalerRun, model string) {
+			defer wg.Done()
+			run.model = model
+			run.result, run.err = h.enhancer.UpscaleImage(ctx, enhancement.UpscaleParams{
+				ImagePath: cropPath,
+				Model:     model,
+				Scale:     scale,
+				Filename:  "crop_" + strings.ReplaceAll(model, "-", "_") + ".png",
+			})
+		}(&runs[i], model)
+	}
+	wg.Wait()
+
+	// 3. Compose the crop and the results that succeeded
+	tiles := []string{cropPath}
+	labels := map[string]string{"1": "original"}
+	var infos []map[string]interface{}
+	var notes []string
+	succeeded := 0
+	for _, run := range runs {
+		info := map[string]interface{}{"model": run.model}
+		if run.err != nil {
+			info["error"] = run.err.Error()
+			infos = append(infos, info)
+			continue
+		}
+		succeeded++
+		r := run.result
+		label := strconv.Itoa(len(tiles) + 1)
+		tiles = append(tiles, r.OutputPath)
+		labels[label] = run.model
+		info["label"] = label
+		info["id"] = r.ID
+		info["model_name"] = r.ModelName
+		info["file_path"] = r.OutputPath
+		info["processing_time"] = r.Metrics.ProcessingTime
+		info["predict_time"] = r.Metrics.PredictTime
+		info["cost"] = r.Metrics.Cost
+		infos = append(infos, info)
+		notes = append(notes, h.addContentCredentials(ctx, r.ID)...)
+	}
+	if succeeded == 0 {
+		return h.toolErrorResponse("compare_upscalers", "processing_error", runs[0].err)
+	}
+
+	composite, err := storage.ComposeComparison(tiles)
+	if err != nil {
+		return h.errorResponse("compare_upscalers", "processing_error", err.Error(), map[string]interface{}{"results": infos})
+	}
+	parameters := map[string]interface{}{
+		"input_path": filePath,
+		"crop_id":    cropID,
+		"crop":       cropInfo(region),
+		"models":     upscalers,
+		"scale":      scale,
+	}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "compare_upscalers", parameters, "upscaler_comparison.png", composite)
+	if err != nil {
+		return h.errorResponse("compare_upscalers", "storage_error", err.Error(), map[string]interface{}{"results": infos})
+	}
+
+	paths := map[string]string{
+		"input_path": filePath,
+		"crop_path":  cropPath,
+		"file_path":  outputPath,
+	}
+	if shareURL := h.files.URL(outputPath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
+	result := map[string]interface{}{
+		"id":      id,
+		"paths":   paths,
+		"crop":    cropInfo(region),
+		"labels":  labels,
+		"results": infos,
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Compared %d of %d upscalers at %dx on a %dx%d crop: %s",
+		succeeded, len(upscalers), scale, region.Dx(), region.Dy(), outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("compare_upscalers", message, result))
+}
+
+// cropInfo describes a crop region in a response or metadata
+func cropInfo(region image.Rectangle) map[string]interface{} {
+	return map[string]interface{}{
+		"x":      region.Min.X,
+		"y":      region.Min.Y,
+		"width":  region.Dx(),
+		"height": region.Dy(),
+	}
+}
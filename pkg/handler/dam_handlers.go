@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// maxTitleLength bounds titles derived from prompts
+const maxTitleLength = 80
+
+// damDefaults are the configured fields used when a call does not set them
+type damDefaults struct {
+	creator    string
+	usageTerms string
+}
+
+// handleExportForDAM handles the export_for_dam tool
+func (h *ReplicateImageHandler) handleExportForDAM(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("export_for_dam", "invalid_parameters", "id is required", nil)
+	}
+
+	mode := "sidecar"
+	if m, ok := args["mode"].(string); ok && m != "" {
+		mode = m
+	}
+	if mode != "sidecar" && mode != "embedded" && mode != "both" {
+		return h.errorResponse("export_for_dam", "invalid_parameters", "mode must be one of: sidecar, embedded, both", nil)
+	}
+
+	metadata, err := h.storage.LoadMetadata(id)
+	if err != nil {
+		return h.errorResponse("export_for_dam", "not_found", fmt.Sprintf("no stored operation with id %s", id), nil)
+	}
+
+	fields := h.damFields(metadata, args)
+	export, err := h.storage.ExportForDAM(id, storage.BuildXMP(fields), mode != "embedded", mode != "sidecar")
+	if err != nil {
+		if errors.Is(err, storage.ErrEmbedUnsupported) {
+			return h.errorResponse("export_for_dam", "invalid_parameters", err.Error()+"; use mode sidecar", nil)
+		}
+		return h.errorResponse("export_for_dam", "storage_error", err.Error(), nil)
+	}
+
+	message := fmt.Sprintf("Wrote IPTC/XMP metadata for %d images", len(export.Images))
+	response := responses.BuildSimpleSuccessResponse("export_for_dam", message, map[string]interface{}{
+		"id":       id,
+		"mode":     mode,
+		"images":   export.Images,
+		"sidecars": export.Sidecars,
+		"embedded": export.Embedded,
+		"fields": map[string]interface{}{
+			"title":               fields.Title,
+			"description":         fields.Description,
+			"creator":             fields.Creator,
+			"usage_terms":         fields.UsageTerms,
+			"keywords":            fields.Keywords,
+			"digital_source_type": fields.DigitalSourceType,
+		},
+	})
+	return h.successResponse(response)
+}
+
+// damFields derives the IPTC fields of an operation from its metadata and the
+// configured defaults, letting call arguments override each one
+func (h *ReplicateImageHandler) damFields(metadata *types.ImageMetadata, args map[string]interface{}) storage.DAMFields {
+	prompt, _ := metadata.Parameters["prompt"].(string)
+	if storage.RedactedPrompt(prompt) {
+		prompt = "" // Hashed by PROMPT_PRIVACY; the hash says nothing to a reader
+	}
+
+	fields := storage.DAMFields{
+		Title:             promptTitle(prompt),
+		Description:       prompt,
+		Creator:           h.dam.creator,
+		UsageTerms:        h.dam.usageTerms,
+		DigitalSourceType: digitalSourceType(metadata.Operation),
+		CreatorTool:       "replicate_image_ai (" + metadata.Model + ")",
+		Created:           metadata.Timestamp,
+		Rating:            metadata.Rating,
+	}
+	if fields.Title == "" {
+		fields.Title = fmt.Sprintf("%s %s", metadata.Operation, metadata.ID)
+	}
+
+	if v, ok := args["title"].(string); ok && v != "" {
+		fields.Title = v
+	}
+	if v, ok := args["description"].(string); ok && v != "" {
+		fields.Description = v
+	}
+	if v, ok := args["creator"].(string); ok && v != "" {
+		fields.Creator = v
+	}
+	if v, ok := args["usage_terms"].(string); ok && v != "" {
+		fields.UsageTerms = v
+	}
+	if keywordsRaw, ok := args["keywords"].([]interface{}); ok {
+		for _, k := range keywordsRaw {
+			if keyword, ok := k.(string); ok && keyword != "" {
+				fields.Keywords = append(fields.Keywords, keyword)
+			}
+		}
+	}
+	return fields
+}
+
+// promptTitle shortens a prompt to a title, cutting at a word boundary
+func promptTitle(prompt string) string {
+	runes := []rune(strings.Join(strings.Fields(prompt), " "))
+	if len(runes) <= maxTitleLength {
+		return string(runes)
+	}
+	head := string(runes[:maxTitleLength])
+	if cut := strings.LastIndex(head, " "); cut > 0 {
+		head = head[:cut]
+	}
+	return strings.TrimRight(head, ",.;:") + "…"
+}
+
+// digitalSourceType classifies an operation for the IPTC DigitalSourceType
+// field: new images, edits of existing ones, and enhancements
+func digitalSourceType(operation string) string {
+	switch operation {
+	case "generate_image", "generate_with_visual_context":
+		return storage.SourceTrainedAlgorithmic
+	case "edit_image", "product_scene":
+		return storage.SourceCompositeWithAlgorithm
+	default:
+		return storage.SourceAlgorithmicallyEnhanced
+	}
+}
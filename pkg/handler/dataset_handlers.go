@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// duplicateDistance is the largest difference hash distance at which two
+// dataset images count as the same shot
+const duplicateDistance = 5
+
+// datasetItem tracks one source image through dataset preparation
+type datasetItem struct {
+	source    string
+	detection *enhancement.Detection
+	image     *storage.TrainingImage
+	cost      float64
+	excluded  string // Why the image was left out, if it was
+	file      string // Name of the image in the dataset
+	caption   captionJob
+}
+
+// handlePrepareDataset handles the prepare_dataset tool: it crops each image
+// around a face or subject, resizes it to the training resolution, drops
+// near-duplicates, captions what is left, and zips the result
+func (h *ReplicateImageHandler) handlePrepareDataset(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	dir, ok := args["directory"].(string)
+	if !ok || dir == "" {
+		return h.errorResponse("prepare_dataset", "invalid_parameters", "directory parameter is required", nil)
+	}
+	cropMode := storage.CropCenter
+	if mode, ok := args["crop"].(string); ok && mode != "" {
+		cropMode = mode
+	}
+	query := ""
+	switch cropMode {
+	case storage.CropFace:
+		query = "face"
+	case storage.CropSubject:
+		query = "person"
+		if subject, ok := args["subject"].(string); ok && subject != "" {
+			query = subject
+		}
+	case storage.CropCenter, storage.CropNone:
+	default:
+		return h.errorResponse("prepare_dataset", "invalid_parameters", "crop must be face, subject, center, or none", nil)
+	}
+	resolution := 1024
+	if value, ok := args["resolution"].(float64); ok {
+		resolution = int(value)
+	}
+	if resolution < 256 || resolution > 2048 {
+		return h.errorResponse("prepare_dataset", "invalid_parameters", "resolution must be between 256 and 2048", nil)
+	}
+	dedupe := true
+	if value, ok := args["dedupe"].(bool); ok {
+		dedupe = value
+	}
+	caption := true
+	if value, ok := args["caption"].(bool); ok {
+		caption = value
+	}
+	captionModel := "blip"
+	if m, ok := args["caption_model"].(string); ok && m != "" {
+		captionModel = m
+	}
+	triggerWord, _ := args["trigger_word"].(string)
+	triggerWord = strings.TrimSpace(triggerWord)
+	archive := true
+	if value, ok := args["zip"].(bool); ok {
+		archive = value
+	}
+	recursive, _ := args["recursive"].(bool)
+
+	sources, err := storage.ListImages(dir, recursive)
+	if err != nil {
+		return h.errorResponse("prepare_dataset", "file_error", err.Error(), map[string]interface{}{"directory": dir})
+	}
+	if len(sources) == 0 {
+		return h.errorResponse("prepare_dataset", "invalid_parameters", "no images found in "+dir, nil)
+	}
+	if len(sources) > maxCaptionImages {
+		return h.errorResponse("prepare_dataset", "invalid_parameters",
+			fmt.Sprintf("found %d images; at most %d can be prepared in one call", len(sources), maxCaptionImages), nil)
+	}
+
+	// 1. Detect, crop, and resize every image, a few at a time
+	items := make([]datasetItem, len(sources))
+	for i, source := range sources {
+		items[i].source = source
+	}
+	forEachDatasetItem(items, func(item *datasetItem) {
+		h.cropDatasetItem(ctx, item, cropMode, query, resolution)
+	})
+
+	// 2. Drop near-duplicates, keeping the largest crop of each shot
+	duplicates := 0
+	if dedupe {
+		var kept []*datasetItem
+		for i := range items {
+			item := &items[i]
+			if item.excluded != "" {
+				continue
+			}
+			match := -1
+			for j, other := range kept {
+				if storage.HashDistance(item.image.Hash, other.image.Hash) <= duplicateDistance {
+					match = j
+					break
+				}
+			}
+			if match < 0 {
+				kept = append(kept, item)
+				continue
+			}
+			duplicates++
+			if item.image.Crop.Dx() > kept[match].image.Crop.Dx() {
+				kept[match].excluded = "duplicate of " + item.source
+				kept[match] = item
+			} else {
+				item.excluded = "duplicate of " + kept[match].source
+			}
+		}
+	}
+
+	// 3. Write the dataset
+	id, err := h.storage.OperationID(ctx)
+	if err != nil {
+		return h.errorResponse("prepare_dataset", "storage_error", err.Error(), nil)
+	}
+	defer h.storage.CleanupIfEmpty(id)
+	datasetDir := h.storage.OperationDir(id)
+	count := 0
+	for i := range items {
+		item := &items[i]
+		if item.excluded != "" {
+			continue
+		}
+		count++
+		base := strings.TrimSuffix(filepath.Base(item.source), filepath.Ext(item.source))
+		item.file = fmt.Sprintf("%04d_%s.png", count, base)
+		if err := os.WriteFile(filepath.Join(datasetDir, item.file), item.image.PNG, 0644); err != nil {
+			return h.errorResponse("prepare_dataset", "storage_error", err.Error(), nil)
+		}
+		item.image.PNG = nil
+	}
+	if count == 0 {
+		return h.errorResponse("prepare_dataset", "processing_error", "no usable images were left after cropping and deduplication",
+			map[string]interface{}{"details": datasetItemInfos(items)})
+	}
+
+	// 4. Caption the kept images
+	if caption {
+		forEachDatasetItem(items, func(item *datasetItem) {
+			if item.excluded != "" {
+				return
+			}
+			item.caption.imagePath = filepath.Join(datasetDir, item.file)
+			h.captionImage(ctx, &item.caption, captionModel, "", triggerWord, true)
+		})
+	}
+
+	parameters := map[string]interface{}{
+		"directory":  dir,
+		"crop":       cropMode,
+		"resolution": resolution,
+		"dedupe":     dedupe,
+		"caption":    caption,
+		"source":     len(sources),
+		"images":     count,
+	}
+	if query != "" {
+		parameters["query"] = query
+	}
+	if caption {
+		parameters["caption_model"] = captionModel
+		if triggerWord != "" {
+			parameters["trigger_word"] = triggerWord
+		}
+	}
+	zipPath, err := h.storage.FinalizeDataset(id, parameters, archive)
+	if err != nil {
+		return h.errorResponse("prepare_dataset", "storage_error", err.Error(), nil)
+	}
+
+	totalCost := 0.0
+	captioned, excluded := 0, 0
+	for _, item := range items {
+		totalCost += item.cost
+		if item.caption.result != nil {
+			totalCost += item.caption.result.Metrics.Cost
+		}
+		if item.caption.err == nil && item.caption.caption != "" {
+			captioned++
+		}
+		if item.excluded != "" && !strings.HasPrefix(item.excluded, "duplicate of ") {
+			excluded++
+		}
+	}
+
+	paths := map[string]string{
+		"directory": datasetDir,
+	}
+	if zipPath != "" {
+		paths["zip_path"] = zipPath
+		if shareURL := h.files.URL(zipPath); shareURL != "" {
+			paths["share_url"] = shareURL
+		}
+	}
+	result := map[string]interface{}{
+		"id":         id,
+		"paths":      paths,
+		"source":     len(sources),
+		"images":     count,
+		"duplicates": duplicates,
+		"excluded":   excluded,
+		"captioned":  captioned,
+		"resolution": resolution,
+		"total_cost": totalCost,
+		"details":    datasetItemInfos(items),
+	}
+	message := fmt.Sprintf("Prepared %d of %d images at %dpx in %s", count, len(sources), resolution, datasetDir)
+	if duplicates > 0 {
+		message += fmt.Sprintf(" (%d duplicates dropped)", duplicates)
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("prepare_dataset", message, result))
+}
+
+// forEachDatasetItem runs fn on every item, captionWorkers at a time
+func forEachDatasetItem(items []datasetItem, fn func(*datasetItem)) {
+	queue := make(chan *datasetItem)
+	var wg sync.WaitGroup
+	for i := 0; i < captionWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				fn(item)
+			}
+		}()
+	}
+	for i := range items {
+		queue <- &items[i]
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// cropDatasetItem finds the face or subject in one image, when the crop mode
+// needs one, and crops and resizes the image around it
+func (h *ReplicateImageHandler) cropDatasetItem(ctx context.Context, item *datasetItem, cropMode, query string, resolution int) {
+	var box image.Rectangle
+	if query != "" {
+		if err := ctx.Err(); err != nil {
+			item.excluded = err.Error()
+			return
+		}
+		detected, err := h.enhancer.Detect(ctx, enhancement.DetectParams{
+			ImagePath: item.source,
+			Query:     query,
+		})
+		if err != nil {
+			item.excluded = "detection failed: " + err.Error()
+			return
+		}
+		item.cost = detected.Metrics.Cost
+		if len(detected.Detections) == 0 {
+			item.excluded = "no " + query + " found"
+			return
+		}
+		item.detection = &detected.Detections[0]
+		box = item.detection.Box
+	}
+
+	trainingImage, err := storage.PrepareTrainingImage(item.source, cropMode, box, resolution)
+	if err != nil {
+		item.excluded = err.Error()
+		return
+	}
+	item.image = trainingImage
+}
+
+// datasetItemInfos describes what happened to each source image
+func datasetItemInfos(items []datasetItem) []map[string]interface{} {
+	infos := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		info := map[string]interface{}{"source": item.source}
+		if item.excluded != "" {
+			info["excluded"] = item.excluded
+			infos = append(infos, info)
+			continue
+		}
+		info["file"] = item.file
+		info["crop"] = cropInfo(item.image.Crop)
+		if item.detection != nil {
+			info["confidence"] = item.detection.Confidence
+		}
+		if item.image.Enlarged {
+			info["enlarged"] = true
+		}
+		if item.caption.err != nil {
+			info["caption_error"] = item.caption.err.Error()
+		} else if item.caption.caption != "" {
+			info["caption"] = item.caption.caption
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// Response detail levels
+const (
+	detailMinimal  = "minimal"  // Only the result's ID and paths
+	detailStandard = "standard" // The usual response
+	detailFull     = "full"     // The usual response plus the raw prediction and its logs
+)
+
+// minimalFields are the response fields kept at the minimal detail level
+var minimalFields = map[string]bool{
+	"success":   true,
+	"operation": true,
+	"id":        true,
+	"paths":     true,
+	"file_path": true,
+	"share_url": true,
+	"files":     true,
+}
+
+// responseDetailSchema is the response_detail property added to the schema of
+// every tool
+const responseDetailSchema = `{
+	"type": "string",
+	"description": "How much the response reports: minimal returns only the result's ID and paths to save context, standard the usual response, and full adds the raw prediction with its logs. Defaults to RESPONSE_DETAIL, or standard.",
+	"enum": ["minimal", "standard", "full"]
+}`
+
+// responseDetail returns the detail level the call asked for, or def
+func responseDetail(req *protocol.CallToolRequest, def string) (string, error) {
+	detail, ok := req.Arguments["response_detail"].(string)
+	if !ok || detail == "" {
+		return def, nil
+	}
+	switch detail {
+	case detailMinimal, detailStandard, detailFull:
+		return detail, nil
+	}
+	return "", fmt.Errorf("response_detail must be %s, %s, or %s", detailMinimal, detailStandard, detailFull)
+}
+
+// withResponseDetail trims or extends a JSON tool response to the call's
+// detail level. Minimal applies only to successful results with an ID, so
+// listings and errors stay whole; full adds the prediction the response names,
+// fetched again from its provider.
+func (h *ReplicateImageHandler) withResponseDetail(ctx context.Context, req *protocol.CallToolRequest, resp *protocol.CallToolResponse) *protocol.CallToolResponse {
+	detail, err := responseDetail(req, h.detail)
+	if err != nil || detail == detailStandard || resp == nil || len(resp.Content) == 0 {
+		return resp
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &response); err != nil {
+		return resp
+	}
+
+	switch detail {
+	case detailMinimal:
+		if success, _ := response["success"].(bool); !success || response["id"] == nil {
+			return resp
+		}
+		for key := range response {
+			if !minimalFields[key] {
+				delete(response, key)
+			}
+		}
+	case detailFull:
+		predictionID := responsePredictionID(response)
+		if predictionID == "" {
+			return resp
+		}
+		prediction, err := h.router.GetPrediction(ctx, predictionID)
+		if err != nil {
+			response["prediction_error"] = err.Error()
+			break
+		}
+		raw, err := json.Marshal(prediction)
+		if err != nil {
+			return resp
+		}
+		var generic map[string]interface{}
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return resp
+		}
+		response["prediction"] = storage.SummarizeInput(generic)
+	}
+
+	content, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return resp
+	}
+	resp.Content[0].Text = string(content)
+	return resp
+}
+
+// responsePredictionID returns the prediction a response reports, whether it
+// succeeded, is still processing, or failed
+func responsePredictionID(response map[string]interface{}) string {
+	if id, ok := response["prediction_id"].(string); ok {
+		return id
+	}
+	if e, ok := response["error"].(map[string]interface{}); ok {
+		if details, ok := e["details"].(map[string]interface{}); ok {
+			id, _ := details["prediction_id"].(string)
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// dryRunTools are the tools that accept dry_run. Each creates its first
+// prediction before writing anything, so stopping there leaves no trace.
+// Tools that write files before or without predictions are left out.
+var dryRunTools = map[string]bool{
+	"generate_image":               true,
+	"generate_branded":             true,
+	"generate_with_visual_context": true,
+	"professional_headshot":        true,
+	"product_scene":                true,
+	"regenerate":                   true,
+	"generate_preview":             true,
+	"finalize":                     true,
+	"create_ab_test":               true,
+	"remove_background":            true,
+	"blur_background":              true,
+	"upscale_image":                true,
+	"enhance_face":                 true,
+	"restore_photo":                true,
+	"revive_photo":                 true,
+	"vectorize_image":              true,
+	"caption_folder":               true,
+	"edit_image":                   true,
+}
+
+// dryRunAliasOps maps tools whose model argument is an alias to the
+// operation that resolves it
+var dryRunAliasOps = map[string]string{
+	"generate_image":    models.OpGenerate,
+	"generate_branded":  models.OpGenerate,
+	"remove_background": models.OpRemoveBackground,
+	"upscale_image":     models.OpUpscale,
+	"enhance_face":      models.OpEnhanceFace,
+	"restore_photo":     models.OpRestorePhoto,
+	"vectorize_image":   models.OpVectorize,
+	"caption_folder":    models.OpCaption,
+	"edit_image":        models.OpEditImage,
+}
+
+// handleDryRun runs a tool up to its first predictions without creating them,
+// and reports the resolved models, final inputs, and estimated cost instead
+func (h *ReplicateImageHandler) handleDryRun(ctx context.Context, req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
+	if !dryRunTools[req.Name] {
+		return h.errorResponse(req.Name, "invalid_parameters", fmt.Sprintf("%s does not support dry_run", req.Name), nil)
+	}
+
+	args := make(map[string]interface{}, len(req.Arguments))
+	for k, v := range req.Arguments {
+		if k != "dry_run" {
+			args[k] = v
+		}
+	}
+	ctx, plan := client.WithDryRun(ctx)
+	resp, err := h.callTool(ctx, &protocol.CallToolRequest{Name: req.Name, Arguments: args})
+
+	warnings := dryRunWarnings(req.Name, args)
+	planned := plan.Predictions()
+	if len(planned) == 0 {
+		// The tool stopped before any prediction: a validation error, or a
+		// result served from the cache. Either way its response says why.
+		fields := map[string]interface{}{"dry_run": true}
+		if len(warnings) > 0 {
+			fields["warnings"] = warnings
+		}
+		return withResponseFields(resp, err, fields)
+	}
+
+	predictions := make([]map[string]interface{}, len(planned))
+	totalCost := 0.0
+	for i, p := range planned {
+		cost, basis := dryRunCost(req.Name, p)
+		totalCost += cost
+		info := models.GetModelInfo(p.Model)
+		prediction := map[string]interface{}{
+			"model":          p.Model,
+			"model_name":     info.Name,
+			"input":          storage.SummarizeInput(p.Input),
+			"estimated_cost": cost,
+			"cost_basis":     basis,
+		}
+		if p.Provider != "" {
+			prediction["provider"] = p.Provider
+		}
+		if p.Warning != "" {
+			warnings = append(warnings, p.Warning)
+		}
+		if basis == models.CostBasisUnpriced {
+			warnings = append(warnings, fmt.Sprintf("%s is not in the pricing table; its cost is a flat estimate", p.Model))
+		}
+		predictions[i] = prediction
+	}
+
+	result := map[string]interface{}{
+		"dry_run":        true,
+		"predictions":    predictions,
+		"estimated_cost": totalCost,
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+	if notes := dryRunNotes(resp); len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Dry run: %s would create %d prediction(s) costing about $%.4f; nothing was run. Steps that depend on these predictions' outputs are not shown.",
+		req.Name, len(predictions), totalCost)
+	return h.successResponse(responses.BuildSimpleSuccessResponse(req.Name, message, result))
+}
+
+// dryRunSchema is the dry_run property added to the schema of every tool in
+// dryRunTools
+const dryRunSchema = `{
+	"type": "boolean",
+	"description": "Resolve the model and final input parameters and estimate the cost without creating a prediction",
+	"default": false
+}`
+
+// dryRunCost estimates the cost of a planned prediction. Models billed per
+// output are priced from the requested number of outputs; models billed by
+// time fall back to the operation's flat estimate.
+func dryRunCost(tool string, p client.PlannedPrediction) (float64, string) {
+	outputs := 1
+	for _, key := range []string{"num_outputs", "num_images", "n"} {
+		if n, ok := p.Input[key].(int); ok && n > 0 {
+			outputs = n
+		}
+	}
+	cost, basis := models.ActualCost(p.Provider, p.Model, 0, outputs)
+	if basis == models.CostBasisPerOutput || basis == models.CostBasisLocal {
+		return cost, basis
+	}
+	if basis == models.CostBasisUnpriced {
+		return responses.EstimateCost(tool), basis
+	}
+	return responses.EstimateCost(tool), "estimate"
+}
+
+// dryRunWarnings flags arguments that the tool would silently replace
+func dryRunWarnings(tool string, args map[string]interface{}) []string {
+	var warnings []string
+	op, ok := dryRunAliasOps[tool]
+	if !ok {
+		return nil
+	}
+	if alias, ok := args["model"].(string); ok && alias != "" && !models.HasAlias(op, alias) && !models.IsKnown(alias) {
+		warnings = append(warnings, fmt.Sprintf("unknown model %q; the default model %s is used instead", alias, models.DefaultModel(op)))
+	}
+	return warnings
+}
+
+// dryRunNotes returns the notes of a dry-run tool response, such as input
+// adjustments, leaving out the dry-run error itself
+func dryRunNotes(resp *protocol.CallToolResponse) []string {
+	if resp == nil || len(resp.Content) == 0 {
+		return nil
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &response); err != nil {
+		return nil
+	}
+	raw, _ := response["notes"].([]interface{})
+	var notes []string
+	for _, note := range raw {
+		if s, ok := note.(string); ok && !strings.Contains(s, client.ErrDryRun.Error()) {
+			notes = append(notes, s)
+		}
+	}
+	return notes
+}
@@ -2,10 +2,15 @@ package handler
 
 import (
 	"context"
+	"fmt"
+	"image"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
 	"github.com/gomcpgo/replicate_image_ai/pkg/editing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
 	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 )
 
 // handleEditImage handles the edit_image tool
@@ -13,7 +18,7 @@ func (h *ReplicateImageHandler) handleEditImage(ctx context.Context, args map[st
 	// Extract and validate parameters
 	filePath, ok := args["file_path"].(string)
 	if !ok || filePath == "" {
-		return h.errorResponse("edit_image", "invalid_parameters", "file_path parameter is required", nil)
+		return h.errorResponse("edit_image", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
 	}
 	
 	prompt, ok := args["prompt"].(string)
@@ -54,18 +59,83 @@ func (h *ReplicateImageHandler) handleEditImage(ctx context.Context, args map[st
 		params.Filename = filename
 	}
 	
+	if maskPath, ok := args["mask_path"].(string); ok {
+		params.MaskPath = maskPath
+	}
+	
+	// Build the mask from a description of the area instead of a drawing
+	var selection map[string]interface{}
+	if selectionPrompt, ok := args["selection_prompt"].(string); ok && selectionPrompt != "" {
+		if params.MaskPath != "" {
+			return h.errorResponse("edit_image", "invalid_parameters", "pass either mask_path or selection_prompt, not both", nil)
+		}
+		if _, ok := args["model"].(string); !ok {
+			params.Model = "fill"
+		}
+		if modelID := models.Resolve(models.OpEditImage, params.Model); !models.RequiresMask(modelID) && modelID != models.ModelGPTImage1 {
+			return h.errorResponse("edit_image", "invalid_parameters",
+				fmt.Sprintf("selection_prompt needs a mask-based model (fill, inpaint, local, or gpt-image-1), not %s", params.Model), nil)
+		}
+		maskPath, info, err := h.selectMask(ctx, filePath, selectionPrompt)
+		if err != nil {
+			return h.toolErrorResponse("edit_image", "processing_error", err)
+		}
+		params.MaskPath, params.SelectionPrompt, selection = maskPath, selectionPrompt, info
+	}
+	
 	// Call core function
 	result, err := h.editor.EditImage(ctx, params)
 	if err != nil {
-		if editErr, ok := err.(editing.EditError); ok {
-			return h.errorResponse("edit_image", editErr.Code, editErr.Message, editErr.Details)
-		}
-		return h.errorResponse("edit_image", "editing_error", err.Error(), nil)
+		return h.toolErrorResponse("edit_image", "editing_error", err)
 	}
 	
+	result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+	
 	// Build success response
 	response := h.buildEditResponse(result)
-	return h.successResponse(response)
+	if selection == nil {
+		return h.successResponse(response)
+	}
+	resp, err := h.successResponse(response)
+	return withResponseFields(resp, err, map[string]interface{}{"selection": selection})
+}
+
+// selectMask builds a mask selecting what a text prompt describes in the
+// image at path: the objects it names are detected, and their boxes marked.
+// The mask is saved under inputs/ and returned with a description of the
+// selection for the response.
+func (h *ReplicateImageHandler) selectMask(ctx context.Context, path, prompt string) (string, map[string]interface{}, error) {
+	detected, err := h.enhancer.Detect(ctx, enhancement.DetectParams{ImagePath: path, Query: prompt})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(detected.Detections) == 0 {
+		return "", nil, fmt.Errorf("nothing matching %q was found in the image; describe the area differently or pass a mask_path", prompt)
+	}
+	boxes := make([]image.Rectangle, len(detected.Detections))
+	regions := make([]map[string]interface{}, len(detected.Detections))
+	for i, d := range detected.Detections {
+		boxes[i] = d.Box
+		regions[i] = map[string]interface{}{
+			"label":      d.Label,
+			"confidence": d.Confidence,
+			"box":        cropInfo(d.Box),
+		}
+	}
+	mask, err := storage.SelectionMask(path, boxes)
+	if err != nil {
+		return "", nil, err
+	}
+	maskPath, err := h.storage.SaveInlineInput(mask)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save mask: %w", err)
+	}
+	return maskPath, map[string]interface{}{
+		"prompt":    prompt,
+		"mask_path": maskPath,
+		"regions":   regions,
+		"cost":      detected.Metrics.Cost,
+	}, nil
 }
 
 // buildEditResponse builds a structured response for edit results
@@ -75,11 +145,17 @@ func (h *ReplicateImageHandler) buildEditResponse(result *editing.EditResult) st
 		"file_path":  result.OutputPath,
 		"url":        result.OutputURL,
 	}
+	if shareURL := h.files.URL(result.OutputPath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
 	
 	modelInfo := map[string]string{
 		"id":   result.Model,
 		"name": result.ModelName,
 	}
+	if result.Provider != "" {
+		modelInfo["provider"] = result.Provider
+	}
 	
 	parameters := map[string]interface{}{
 		"prompt": result.EditPrompt,
@@ -95,7 +171,9 @@ func (h *ReplicateImageHandler) buildEditResponse(result *editing.EditResult) st
 		"processing_time": result.Metrics.ProcessingTime,
 		"input_size":      result.Metrics.InputSize,
 		"output_size":     result.Metrics.OutputSize,
+		"predict_time":    result.Metrics.PredictTime,
+		"cost":            result.Metrics.Cost,
 	}
 	
-	return responses.BuildSuccessResponse(result.Operation, result.ID, paths, modelInfo, parameters, metrics, result.PredictionID)
+	return responses.BuildSuccessResponse(result.Operation, result.ID, paths, modelInfo, parameters, metrics, result.PredictionID, h.buildResultExtra(result.Notes, result.OutputPaths, result.OutputURLs))
 }
\ No newline at end of file
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
 	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
 	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 )
 
 // handleRemoveBackground handles the remove_background tool
@@ -13,7 +15,7 @@ func (h *ReplicateImageHandler) handleRemoveBackground(ctx context.Context, args
 	// Extract and validate parameters
 	filePath, ok := args["file_path"].(string)
 	if !ok || filePath == "" {
-		return h.errorResponse("remove_background", "invalid_parameters", "file_path parameter is required", nil)
+		return h.errorResponse("remove_background", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
 	}
 	
 	// Build parameters
@@ -32,18 +34,55 @@ func (h *ReplicateImageHandler) handleRemoveBackground(ctx context.Context, args
 		params.Filename = filename
 	}
 	
+	autoCrop, _ := args["auto_crop"].(bool)
+	cropOpts, err := autoCropOptions(args["crop_padding"], args["crop_aspect_ratio"])
+	if err != nil {
+		return h.errorResponse("remove_background", "invalid_parameters", err.Error(), nil)
+	}
+	
+	enc, err := outputEncodingArgs(args)
+	if err != nil {
+		return h.errorResponse("remove_background", "invalid_parameters", err.Error(), nil)
+	}
+	
+	// Animated inputs are processed frame by frame
+	anim, err := storage.LoadAnimation(filePath)
+	if err != nil {
+		return h.errorResponse("remove_background", "invalid_parameters", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	if anim != nil {
+		notes := animationNotes(args, "output_format", "output_quality", "auto_crop")
+		return h.processAnimation(ctx, "remove_background", filePath, params.Filename, "no_bg", anim, notes, func(ctx context.Context, framePath string) (*enhancement.EnhancementResult, error) {
+			frame := params
+			frame.ImagePath, frame.Filename = framePath, ""
+			return h.enhancer.RemoveBackground(ctx, frame)
+		})
+	}
+	
 	// Call core function
 	result, err := h.enhancer.RemoveBackground(ctx, params)
 	if err != nil {
-		if enhErr, ok := err.(enhancement.EnhancementError); ok {
-			return h.errorResponse("remove_background", enhErr.Code, enhErr.Message, enhErr.Details)
-		}
-		return h.errorResponse("remove_background", "processing_error", err.Error(), nil)
+		return h.toolErrorResponse("remove_background", "processing_error", err)
 	}
 	
+	result.Notes = append(result.Notes, h.encodeOutputs(ctx, result.ID, enc, &result.OutputPath, result.OutputPaths, &result.Metrics.OutputSize)...)
+	result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+	
 	// Build success response
 	response := h.buildEnhancementResponse(result)
-	return h.successResponse(response)
+	if !autoCrop {
+		return h.successResponse(response)
+	}
+	
+	// Trim the cutout to the subject; the uncropped cutout is kept either way
+	fields := map[string]interface{}{}
+	if cropped, err := h.saveAutoCrop(ctx, result.OutputPath, cropOpts, ""); err != nil {
+		fields["auto_crop_error"] = err.Error()
+	} else {
+		fields["cropped"] = cropped
+	}
+	resp, err := h.successResponse(response)
+	return withResponseFields(resp, err, fields)
 }
 
 // handleUpscaleImage handles the upscale_image tool
@@ -51,7 +90,7 @@ func (h *ReplicateImageHandler) handleUpscaleImage(ctx context.Context, args map
 	// Extract and validate parameters
 	filePath, ok := args["file_path"].(string)
 	if !ok || filePath == "" {
-		return h.errorResponse("upscale_image", "invalid_parameters", "file_path parameter is required", nil)
+		return h.errorResponse("upscale_image", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
 	}
 	
 	// Build parameters
@@ -80,15 +119,34 @@ func (h *ReplicateImageHandler) handleUpscaleImage(ctx context.Context, args map
 		params.Filename = filename
 	}
 	
+	enc, err := outputEncodingArgs(args)
+	if err != nil {
+		return h.errorResponse("upscale_image", "invalid_parameters", err.Error(), nil)
+	}
+	
+	// Animated inputs are processed frame by frame
+	anim, err := storage.LoadAnimation(filePath)
+	if err != nil {
+		return h.errorResponse("upscale_image", "invalid_parameters", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	if anim != nil {
+		notes := animationNotes(args, "output_format", "output_quality")
+		return h.processAnimation(ctx, "upscale_image", filePath, params.Filename, fmt.Sprintf("upscaled_%dx", params.Scale), anim, notes, func(ctx context.Context, framePath string) (*enhancement.EnhancementResult, error) {
+			frame := params
+			frame.ImagePath, frame.Filename = framePath, ""
+			return h.enhancer.UpscaleImage(ctx, frame)
+		})
+	}
+	
 	// Call core function
 	result, err := h.enhancer.UpscaleImage(ctx, params)
 	if err != nil {
-		if enhErr, ok := err.(enhancement.EnhancementError); ok {
-			return h.errorResponse("upscale_image", enhErr.Code, enhErr.Message, enhErr.Details)
-		}
-		return h.errorResponse("upscale_image", "processing_error", err.Error(), nil)
+		return h.toolErrorResponse("upscale_image", "processing_error", err)
 	}
 	
+	result.Notes = append(result.Notes, h.encodeOutputs(ctx, result.ID, enc, &result.OutputPath, result.OutputPaths, &result.Metrics.OutputSize)...)
+	result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+	
 	// Build success response
 	response := h.buildEnhancementResponse(result)
 	return h.successResponse(response)
@@ -99,7 +157,7 @@ func (h *ReplicateImageHandler) handleEnhanceFace(ctx context.Context, args map[
 	// Extract and validate parameters
 	filePath, ok := args["file_path"].(string)
 	if !ok || filePath == "" {
-		return h.errorResponse("enhance_face", "invalid_parameters", "file_path parameter is required", nil)
+		return h.errorResponse("enhance_face", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
 	}
 	
 	// Build parameters
@@ -132,15 +190,20 @@ func (h *ReplicateImageHandler) handleEnhanceFace(ctx context.Context, args map[
 		params.Filename = filename
 	}
 	
+	enc, err := outputEncodingArgs(args)
+	if err != nil {
+		return h.errorResponse("enhance_face", "invalid_parameters", err.Error(), nil)
+	}
+	
 	// Call core function
 	result, err := h.enhancer.EnhanceFace(ctx, params)
 	if err != nil {
-		if enhErr, ok := err.(enhancement.EnhancementError); ok {
-			return h.errorResponse("enhance_face", enhErr.Code, enhErr.Message, enhErr.Details)
-		}
-		return h.errorResponse("enhance_face", "processing_error", err.Error(), nil)
+		return h.toolErrorResponse("enhance_face", "processing_error", err)
 	}
 	
+	result.Notes = append(result.Notes, h.encodeOutputs(ctx, result.ID, enc, &result.OutputPath, result.OutputPaths, &result.Metrics.OutputSize)...)
+	result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+	
 	// Build success response
 	response := h.buildEnhancementResponse(result)
 	return h.successResponse(response)
@@ -151,7 +214,7 @@ func (h *ReplicateImageHandler) handleRestorePhoto(ctx context.Context, args map
 	// Extract and validate parameters
 	filePath, ok := args["file_path"].(string)
 	if !ok || filePath == "" {
-		return h.errorResponse("restore_photo", "invalid_parameters", "file_path parameter is required", nil)
+		return h.errorResponse("restore_photo", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
 	}
 	
 	// Build parameters
@@ -186,15 +249,20 @@ func (h *ReplicateImageHandler) handleRestorePhoto(ctx context.Context, args map
 		params.Filename = filename
 	}
 	
+	enc, err := outputEncodingArgs(args)
+	if err != nil {
+		return h.errorResponse("restore_photo", "invalid_parameters", err.Error(), nil)
+	}
+	
 	// Call core function
 	result, err := h.enhancer.RestorePhoto(ctx, params)
 	if err != nil {
-		if enhErr, ok := err.(enhancement.EnhancementError); ok {
-			return h.errorResponse("restore_photo", enhErr.Code, enhErr.Message, enhErr.Details)
-		}
-		return h.errorResponse("restore_photo", "processing_error", err.Error(), nil)
+		return h.toolErrorResponse("restore_photo", "processing_error", err)
 	}
 	
+	result.Notes = append(result.Notes, h.encodeOutputs(ctx, result.ID, enc, &result.OutputPath, result.OutputPaths, &result.Metrics.OutputSize)...)
+	result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+	
 	// Build success response
 	response := h.buildEnhancementResponse(result)
 	return h.successResponse(response)
@@ -207,21 +275,29 @@ func (h *ReplicateImageHandler) buildEnhancementResponse(result *enhancement.Enh
 		"file_path":  result.OutputPath,
 		"url":        result.OutputURL,
 	}
+	if shareURL := h.files.URL(result.OutputPath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
 	
 	modelInfo := map[string]string{
 		"id":   result.Model,
 		"name": result.ModelName,
 	}
+	if result.Provider != "" {
+		modelInfo["provider"] = result.Provider
+	}
 	
 	metrics := map[string]interface{}{
 		"processing_time": result.Metrics.ProcessingTime,
 		"input_size":      result.Metrics.InputSize,
 		"output_size":     result.Metrics.OutputSize,
+		"predict_time":    result.Metrics.PredictTime,
+		"cost":            result.Metrics.Cost,
 	}
 	
 	if result.Metrics.ScaleFactor > 0 {
 		metrics["scale_factor"] = result.Metrics.ScaleFactor
 	}
 	
-	return responses.BuildSuccessResponse(result.Operation, result.ID, paths, modelInfo, result.Parameters, metrics, result.PredictionID)
+	return responses.BuildSuccessResponse(result.Operation, result.ID, paths, modelInfo, result.Parameters, metrics, result.PredictionID, h.buildResultExtra(result.Notes, result.OutputPaths, result.OutputURLs))
 }
\ No newline at end of file
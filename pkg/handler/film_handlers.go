@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleApplyFilmLook handles the apply_film_look tool: it adds analog film
+// effects locally, without a prediction
+func (h *ReplicateImageHandler) handleApplyFilmLook(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("apply_film_look", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+
+	opts := storage.FilmOptions{GrainSize: 1, LUTStrength: 1}
+	parameters := map[string]interface{}{"input_path": filePath}
+	strengths := []struct {
+		name  string
+		value *float64
+	}{
+		{"grain", &opts.Grain},
+		{"vignette", &opts.Vignette},
+		{"halation", &opts.Halation},
+		{"lut_strength", &opts.LUTStrength},
+	}
+	for _, s := range strengths {
+		if value, ok := args[s.name].(float64); ok {
+			if value < 0 || value > 1 {
+				return h.errorResponse("apply_film_look", "invalid_parameters", fmt.Sprintf("%s must be between 0 and 1", s.name), nil)
+			}
+			*s.value = value
+		}
+	}
+	if size, ok := args["grain_size"].(float64); ok {
+		if size < 0.5 || size > 4 {
+			return h.errorResponse("apply_film_look", "invalid_parameters", "grain_size must be between 0.5 and 4", nil)
+		}
+		opts.GrainSize = size
+	}
+	if seed, ok := args["seed"].(float64); ok {
+		opts.Seed = int64(seed)
+	}
+	if lutPath, ok := args["lut_path"].(string); ok && lutPath != "" {
+		if !strings.EqualFold(filepath.Ext(lutPath), ".cube") {
+			return h.errorResponse("apply_film_look", "invalid_parameters", "lut_path must be a .cube file", nil)
+		}
+		lut, err := storage.LoadCubeLUT(lutPath)
+		if err != nil {
+			return h.errorResponse("apply_film_look", "file_error", err.Error(), map[string]interface{}{"lut_path": lutPath})
+		}
+		opts.LUT = lut
+		parameters["lut_path"] = lutPath
+		parameters["lut_strength"] = opts.LUTStrength
+	}
+	if opts.Grain == 0 && opts.Vignette == 0 && opts.Halation == 0 && (opts.LUT == nil || opts.LUTStrength == 0) {
+		return h.errorResponse("apply_film_look", "invalid_parameters", "set at least one of grain, vignette, halation, or lut_path", nil)
+	}
+	if opts.Grain > 0 {
+		parameters["grain"] = opts.Grain
+		parameters["grain_size"] = opts.GrainSize
+		parameters["seed"] = opts.Seed
+	}
+	if opts.Vignette > 0 {
+		parameters["vignette"] = opts.Vignette
+	}
+	if opts.Halation > 0 {
+		parameters["halation"] = opts.Halation
+	}
+
+	data, err := storage.ApplyFilmLook(filePath, opts)
+	if err != nil {
+		return h.errorResponse("apply_film_look", "processing_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+
+	filename := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + "_film"
+	if name, ok := args["filename"].(string); ok && name != "" {
+		filename = name
+	}
+	filename = strings.TrimSuffix(filepath.Base(filename), ".png") + ".png"
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "apply_film_look", parameters, filename, data)
+	if err != nil {
+		return h.errorResponse("apply_film_look", "storage_error", err.Error(), nil)
+	}
+
+	result := map[string]interface{}{
+		"id":        id,
+		"file_path": outputPath,
+	}
+	if opts.LUT != nil && opts.LUT.Title != "" {
+		result["lut_title"] = opts.LUT.Title
+	}
+	if url := h.files.URL(outputPath); url != "" {
+		result["share_url"] = url
+	}
+	if notes := h.addContentCredentials(ctx, id); len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Applied the film look: %s", outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("apply_film_look", message, result))
+}
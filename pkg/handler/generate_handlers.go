@@ -2,10 +2,18 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/editing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
 	"github.com/gomcpgo/replicate_image_ai/pkg/generation"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
 	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
 )
 
 // handleGenerateImage handles the generate_image tool
@@ -16,7 +24,30 @@ func (h *ReplicateImageHandler) handleGenerateImage(ctx context.Context, args ma
 		return h.errorResponse("generate_image", "invalid_parameters", "prompt parameter is required", nil)
 	}
 	
-	// Build generation parameters
+	enc, err := outputEncodingArgs(args)
+	if err != nil {
+		return h.errorResponse("generate_image", "invalid_parameters", err.Error(), nil)
+	}
+	
+	// Call core generation function
+	result, err := h.generator.GenerateImage(ctx, h.generateParams(prompt, args))
+	if err != nil {
+		return h.toolErrorResponse("generate_image", "generation_error", err)
+	}
+	
+	// Cached results were re-encoded and signed when first created
+	if !result.Cached {
+		result.Notes = append(result.Notes, h.encodeOutputs(ctx, result.ID, enc, &result.FilePath, result.FilePaths, &result.Metrics.FileSize)...)
+		result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+	}
+	
+	// Build success response
+	response := h.buildGenerationResponse("generate_image", result)
+	return h.successResponse(response)
+}
+
+// generateParams builds generation parameters from generate_image arguments
+func (h *ReplicateImageHandler) generateParams(prompt string, args map[string]interface{}) generation.GenerateParams {
 	params := generation.GenerateParams{
 		Prompt: prompt,
 	}
@@ -44,6 +75,14 @@ func (h *ReplicateImageHandler) handleGenerateImage(ctx context.Context, args ma
 		params.Resolution = resolution
 	}
 	
+	if size, ok := args["size"].(string); ok {
+		params.Size = size
+	}
+	
+	if sizePreset, ok := args["size_preset"].(string); ok {
+		params.SizePreset = sizePreset
+	}
+	
 	if seed, ok := args["seed"].(float64); ok {
 		params.Seed = int(seed)
 	}
@@ -56,6 +95,10 @@ func (h *ReplicateImageHandler) handleGenerateImage(ctx context.Context, args ma
 		params.NegativePrompt = negativePrompt
 	}
 	
+	if negativePreset, ok := args["negative_preset"].(string); ok {
+		params.NegativePreset = negativePreset
+	}
+	
 	if numOutputs, ok := args["num_outputs"].(float64); ok {
 		params.NumOutputs = int(numOutputs)
 	}
@@ -64,26 +107,46 @@ func (h *ReplicateImageHandler) handleGenerateImage(ctx context.Context, args ma
 		params.SafetyFilter = safetyFilter
 	}
 	
+	if variant, ok := args["imagen_variant"].(string); ok {
+		params.ImagenVariant = variant
+	}
+	
+	if personGeneration, ok := args["person_generation"].(string); ok {
+		params.PersonGeneration = personGeneration
+	}
+	
 	if outputFormat, ok := args["output_format"].(string); ok {
 		params.OutputFormat = outputFormat
 	}
 	
+	if outputQuality, ok := args["output_quality"].(float64); ok {
+		params.OutputQuality = int(outputQuality)
+	}
+	
+	if quality, ok := args["quality"].(string); ok {
+		params.Quality = quality
+	}
+	
 	if filename, ok := args["filename"].(string); ok {
 		params.Filename = filename
 	}
 	
-	// Call core generation function
-	result, err := h.generator.GenerateImage(ctx, params)
-	if err != nil {
-		if genErr, ok := err.(generation.GenerationError); ok {
-			return h.errorResponse("generate_image", genErr.Code, genErr.Message, genErr.Details)
-		}
-		return h.errorResponse("generate_image", "generation_error", err.Error(), nil)
+	params.UseCache = h.cache
+	if useCache, ok := args["use_cache"].(bool); ok {
+		params.UseCache = useCache
 	}
 	
-	// Build success response
-	response := h.buildGenerationResponse("generate_image", result)
-	return h.successResponse(response)
+	params.TranslatePrompt = h.translate
+	if translate, ok := args["translate_prompt"].(bool); ok {
+		params.TranslatePrompt = translate
+	}
+	
+	params.DeterministicID = h.stableIDs
+	if deterministic, ok := args["deterministic_id"].(bool); ok {
+		params.DeterministicID = deterministic
+	}
+	
+	return params
 }
 
 // handleGenerateWithVisualContext handles the generate_with_visual_context tool
@@ -104,11 +167,6 @@ func (h *ReplicateImageHandler) handleGenerateWithVisualContext(ctx context.Cont
 		}
 	}
 	
-	if len(referenceImages) == 0 {
-		return h.errorResponse("generate_with_visual_context", "invalid_parameters", 
-			"reference_images parameter is required (1-3 images)", nil)
-	}
-	
 	// Extract reference tags
 	var referenceTags []string
 	if refTagsRaw, ok := args["reference_tags"].([]interface{}); ok {
@@ -124,11 +182,29 @@ func (h *ReplicateImageHandler) handleGenerateWithVisualContext(ctx context.Cont
 			"reference_tags must match the number of reference_images", nil)
 	}
 	
+	// Add the images of named reference sets
+	referenceImages, referenceTags, err := h.expandReferenceSets(prompt, args, referenceImages, referenceTags)
+	if err != nil {
+		return h.errorResponse("generate_with_visual_context", "invalid_parameters", err.Error(), nil)
+	}
+	
+	if len(referenceImages) == 0 {
+		return h.errorResponse("generate_with_visual_context", "invalid_parameters", 
+			"reference_images or a reference set is required (1-3 images)", nil)
+	}
+	
+	enc, err := outputEncodingArgs(args)
+	if err != nil {
+		return h.errorResponse("generate_with_visual_context", "invalid_parameters", err.Error(), nil)
+	}
+	
 	// Build Gen4 parameters
 	params := generation.Gen4Params{
 		Prompt:          prompt,
 		ReferenceImages: referenceImages,
 		ReferenceTags:   referenceTags,
+		OutputFormat:    enc.format,
+		OutputQuality:   enc.quality,
 	}
 	
 	// Extract optional parameters
@@ -138,6 +214,17 @@ func (h *ReplicateImageHandler) handleGenerateWithVisualContext(ctx context.Cont
 		params.AspectRatio = "16:9" // Default
 	}
 	
+	if sizePreset, ok := args["size_preset"].(string); ok && sizePreset != "" {
+		if _, ok := args["aspect_ratio"].(string); ok {
+			return h.errorResponse("generate_with_visual_context", "invalid_parameters", "size_preset replaces aspect_ratio; pass either one", nil)
+		}
+		aspectRatio, err := generation.PresetAspectRatio(models.ModelGen4Image, sizePreset)
+		if err != nil {
+			return h.toolErrorResponse("generate_with_visual_context", "invalid_parameters", err)
+		}
+		params.AspectRatio = aspectRatio
+	}
+	
 	if resolution, ok := args["resolution"].(string); ok {
 		params.Resolution = resolution
 	} else {
@@ -152,13 +239,31 @@ func (h *ReplicateImageHandler) handleGenerateWithVisualContext(ctx context.Cont
 		params.Filename = filename
 	}
 	
+	params.UseCache = h.cache
+	if useCache, ok := args["use_cache"].(bool); ok {
+		params.UseCache = useCache
+	}
+	
+	params.TranslatePrompt = h.translate
+	if translate, ok := args["translate_prompt"].(bool); ok {
+		params.TranslatePrompt = translate
+	}
+	
+	params.DeterministicID = h.stableIDs
+	if deterministic, ok := args["deterministic_id"].(bool); ok {
+		params.DeterministicID = deterministic
+	}
+	
 	// Call core generation function
 	result, err := h.generator.GenerateWithVisualContext(ctx, params)
 	if err != nil {
-		if genErr, ok := err.(generation.GenerationError); ok {
-			return h.errorResponse("generate_with_visual_context", genErr.Code, genErr.Message, genErr.Details)
-		}
-		return h.errorResponse("generate_with_visual_context", "generation_error", err.Error(), nil)
+		return h.toolErrorResponse("generate_with_visual_context", "generation_error", err)
+	}
+	
+	// Cached results were re-encoded and signed when first created
+	if !result.Cached {
+		result.Notes = append(result.Notes, h.encodeOutputs(ctx, result.ID, enc, &result.FilePath, result.FilePaths, &result.Metrics.FileSize)...)
+		result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
 	}
 	
 	// Build success response
@@ -172,18 +277,61 @@ func (h *ReplicateImageHandler) buildGenerationResponse(operation string, result
 		"file_path": result.FilePath,
 		"url":       result.URL,
 	}
+	if shareURL := h.files.URL(result.FilePath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
 	
 	modelInfo := map[string]string{
 		"id":   result.Model,
 		"name": result.ModelName,
 	}
+	if result.Provider != "" {
+		modelInfo["provider"] = result.Provider
+	}
 	
 	metrics := map[string]interface{}{
 		"generation_time": result.Metrics.GenerationTime,
 		"file_size":       result.Metrics.FileSize,
+		"predict_time":    result.Metrics.PredictTime,
+		"cost":            result.Metrics.Cost,
 	}
 	
-	return responses.BuildSuccessResponse(operation, result.ID, paths, modelInfo, result.Parameters, metrics, result.PredictionID)
+	extra := h.buildResultExtra(result.Notes, result.FilePaths, result.URLs)
+	if result.Cached {
+		if extra == nil {
+			extra = map[string]interface{}{}
+		}
+		extra["cached"] = true
+		extra["cost_estimate"] = 0.0
+	}
+	
+	return responses.BuildSuccessResponse(operation, result.ID, paths, modelInfo, result.Parameters, metrics, result.PredictionID, extra)
+}
+
+// buildResultExtra returns the extra response fields for result notes and
+// multi-file outputs, if any
+func (h *ReplicateImageHandler) buildResultExtra(notes []string, filePaths []string, urls []string) map[string]interface{} {
+	extra := map[string]interface{}{}
+	if len(notes) > 0 {
+		extra["notes"] = notes
+	}
+	if len(filePaths) > 1 {
+		files := make([]map[string]string, len(filePaths))
+		for i, path := range filePaths {
+			files[i] = map[string]string{"file_path": path}
+			if i < len(urls) && !strings.HasPrefix(urls[i], "data:") {
+				files[i]["url"] = urls[i]
+			}
+			if shareURL := h.files.URL(path); shareURL != "" {
+				files[i]["share_url"] = shareURL
+			}
+		}
+		extra["files"] = files
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	return extra
 }
 
 // errorResponse builds an error response
@@ -200,6 +348,34 @@ func (h *ReplicateImageHandler) errorResponse(operation, code, message string, d
 	}, nil
 }
 
+// toolErrorResponse converts an error returned by a core package into an error
+// response, preserving its error code when one is available
+func (h *ReplicateImageHandler) toolErrorResponse(operation, fallbackCode string, err error) (*protocol.CallToolResponse, error) {
+	var genErr generation.GenerationError
+	var enhErr enhancement.EnhancementError
+	var editErr editing.EditError
+	var apiErr *client.APIError
+	
+	switch {
+	case errors.Is(err, storage.ErrOutputExpired):
+		return h.errorResponse(operation, "output_expired", err.Error(), nil)
+	case errors.Is(err, storage.ErrIncompleteImage):
+		return h.errorResponse(operation, "incomplete_download", err.Error(), nil)
+	case errors.As(err, &genErr):
+		return h.errorResponse(operation, genErr.Code, genErr.Message, genErr.Details)
+	case errors.As(err, &enhErr):
+		return h.errorResponse(operation, enhErr.Code, enhErr.Message, enhErr.Details)
+	case errors.As(err, &editErr):
+		return h.errorResponse(operation, editErr.Code, editErr.Message, editErr.Details)
+	case errors.As(err, &apiErr):
+		return h.errorResponse(operation, apiErr.Code, apiErr.Message, map[string]interface{}{
+			"status_code": apiErr.StatusCode,
+		})
+	default:
+		return h.errorResponse(operation, fallbackCode, err.Error(), nil)
+	}
+}
+
 // successResponse builds a success response
 func (h *ReplicateImageHandler) successResponse(content string) (*protocol.CallToolResponse, error) {
 	return &protocol.CallToolResponse{
@@ -210,4 +386,44 @@ func (h *ReplicateImageHandler) successResponse(content string) (*protocol.CallT
 			},
 		},
 	}, nil
-}
\ No newline at end of file
+}
+
+// withResponseFields adds top-level fields to a JSON tool response, leaving
+// responses that are not JSON untouched
+func withResponseFields(resp *protocol.CallToolResponse, err error, fields map[string]interface{}) (*protocol.CallToolResponse, error) {
+	if err != nil || resp == nil || len(resp.Content) == 0 {
+		return resp, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &response); err != nil {
+		return resp, nil
+	}
+	for key, value := range fields {
+		response[key] = value
+	}
+	content, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return resp, nil
+	}
+	resp.Content[0].Text = string(content)
+	return resp, nil
+}
+
+// withStructuredContent sets the structured content of a tool response from
+// its JSON text, which is kept for clients that only read text content
+func withStructuredContent(resp *protocol.CallToolResponse) *protocol.CallToolResponse {
+	if resp == nil {
+		return resp
+	}
+	for _, content := range resp.Content {
+		if content.Type != "text" {
+			continue
+		}
+		if structured, ok := responses.StructuredContent(content.Text); ok {
+			resp.StructuredContent = structured
+		}
+		break
+	}
+	return resp
+}
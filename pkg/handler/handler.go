@@ -3,13 +3,27 @@ package handler
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/brand"
 	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/config"
 	"github.com/gomcpgo/replicate_image_ai/pkg/editing"
 	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/fileserver"
 	"github.com/gomcpgo/replicate_image_ai/pkg/generation"
+	"github.com/gomcpgo/replicate_image_ai/pkg/moderation"
+	"github.com/gomcpgo/replicate_image_ai/pkg/notify"
+	"github.com/gomcpgo/replicate_image_ai/pkg/policy"
+	"github.com/gomcpgo/replicate_image_ai/pkg/provenance"
 	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/transcode"
+	"github.com/gomcpgo/replicate_image_ai/pkg/warmup"
 )
 
 // ReplicateImageHandler handles MCP requests for image operations
@@ -18,54 +32,463 @@ type ReplicateImageHandler struct {
 	enhancer  *enhancement.Enhancer
 	editor    *editing.Editor
 	storage   *storage.Storage
+	files     *fileserver.Server // Nil unless the file server is enabled
+	notifier  *notify.Notifier   // Nil unless a notification target is configured
+	signer    *provenance.Signer // Nil unless C2PA signing is configured
+	encoder   *transcode.Encoder // Re-encodes outputs into formats models cannot produce
+	brand     *brand.Kit         // Nil unless a brand kit is configured
 	debug     bool
+	cache     bool   // Default for the per-call use_cache argument
+	translate bool   // Default for the per-call translate_prompt argument
+	stableIDs bool   // Default for the per-call deterministic_id argument
+	detail    string // Default for the per-call response_detail argument
+	open      bool   // Default for the per-call open_output argument
+	purge     bool   // Default for the per-call delete_remote argument
+	private   bool   // Prompts are kept out of stored metadata, logs, and notifications
+	dam       damDefaults
+	chains    chainRegistry        // Workflows started by run_chain
+	drain     drainState           // Tool calls in flight, awaited by Shutdown
+	halt      context.CancelFunc   // Stops background work such as keep-warm rounds
+	timeouts  config.TimeoutConfig // Poll schedules and initial wait of each tool's predictions
+	pending   pendingRegistry      // Calls that outlived their initial wait, for continue_operation
+	batchSize int                  // Most prompts batch_generate runs at once
+	offPeak   *offPeakQueue        // Nil unless an off-peak window is configured
+	warmer    *warmup.Warmer
+	router    *client.Router
+	replicate *client.ReplicateClient // Looks up models for probe_model
+	inputDirs []string                // Canonical directories path arguments must be inside; nil allows any path
+	policy    *policy.Policy          // Nil unless a prompt policy is configured
 }
 
 // NewReplicateImageHandler creates a new handler instance
-func NewReplicateImageHandler(apiKey string, rootFolder string, debug bool) (*ReplicateImageHandler, error) {
+func NewReplicateImageHandler(cfg *config.Config) (*ReplicateImageHandler, error) {
+	// Record or replay all HTTP traffic (API calls and downloads) when configured
+	var transport http.RoundTripper
+	var downloadClient *http.Client
+	if cfg.CassetteMode != "" {
+		var err error
+		transport, err = client.NewCassetteTransport(cfg.CassetteMode, cfg.CassetteDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		downloadClient = &http.Client{Transport: transport}
+	}
+	
+	// Hash or encrypt prompts before they are stored
+	prompts, err := storage.NewPromptSealer(cfg.PromptPrivacy, cfg.PromptEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROMPT_PRIVACY: %w", err)
+	}
+	
 	// Initialize storage
-	store := storage.NewStorage(rootFolder)
+	store := storage.NewStorageWithOptions(cfg.ReplicateImagesRoot, storage.Options{
+		MaxInputBytes:        int64(cfg.MaxImageSizeMB) * 1024 * 1024,
+		MaxInputEdge:         cfg.MaxInputEdgePx,
+		MaxDownloadBytes:     int64(cfg.MaxDownloadSizeMB) * 1024 * 1024,
+		MaxParallelDownloads: cfg.MaxParallelDownloads,
+		HTTPClient:           downloadClient,
+		Prompts:              prompts,
+		StripInputMetadata:   cfg.StripInputMetadata,
+	})
 	
 	// Initialize Replicate client
-	replicateClient := client.NewReplicateClient(apiKey)
+	replicateClient := client.NewReplicateClientWithTransport(cfg.ReplicateAPIToken, transport)
+	replicateClient.SetFileUploads(cfg.FileUploads)
+	
+	// Route models to alternative providers where configured; Replicate serves the rest
+	router := client.NewRouter(replicateClient)
+	if cfg.FalAPIKey != "" || cfg.CassetteMode == "replay" {
+		router.Register(client.NewFalClientWithTransport(cfg.FalAPIKey, transport))
+	}
+	if cfg.StabilityAPIKey != "" || cfg.CassetteMode == "replay" {
+		router.Register(client.NewStabilityClientWithTransport(cfg.StabilityAPIKey, transport))
+	}
+	if cfg.OpenAIAPIKey != "" || cfg.CassetteMode == "replay" {
+		router.Register(client.NewOpenAIClientWithTransport(cfg.OpenAIAPIKey, transport))
+	}
+	switch cfg.LocalBackend {
+	case client.LocalBackendA1111:
+		router.Register(client.NewA1111ClientWithTransport(cfg.LocalURL, cfg.LocalUpscaler, transport))
+	case client.LocalBackendComfyUI:
+		workflow, err := client.LoadComfyUIWorkflow(cfg.ComfyUIWorkflow)
+		if err != nil {
+			return nil, err
+		}
+		router.Register(client.NewComfyUIClientWithTransport(cfg.LocalURL, workflow, transport))
+	}
+	router.SetDefault(cfg.Provider)
+	router.SetConcurrency(cfg.MaxConcurrentPredictions)
+	for modelID, provider := range cfg.ProviderModels {
+		router.Route(modelID, provider)
+	}
+	
+	// Serve outputs at shareable URLs when configured
+	var files *fileserver.Server
+	if cfg.FileServerAddr != "" {
+		var err error
+		files, err = fileserver.New(cfg.ReplicateImagesRoot, cfg.FileServerAddr, cfg.FileServerURL, cfg.FileServerSecret)
+		if err != nil {
+			return nil, err
+		}
+		if err := files.Start(); err != nil {
+			return nil, err
+		}
+	}
 	
-	// Initialize core components
-	gen := generation.NewGenerator(replicateClient, store, debug)
-	enh := enhancement.NewEnhancer(replicateClient, store, debug)
-	edit := editing.NewEditor(replicateClient, store, debug)
+	// Sign outputs with C2PA content credentials when a certificate is configured
+	signer, err := provenance.NewSigner(provenance.Options{
+		ToolPath:       cfg.C2PATool,
+		CertPath:       cfg.C2PACertPath,
+		KeyPath:        cfg.C2PAKeyPath,
+		Algorithm:      cfg.C2PAAlgorithm,
+		TimestampURL:   cfg.C2PATimestampURL,
+		ClaimGenerator: "replicate_image_ai",
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	// Load the brand kit applied by generate_branded
+	kit, err := brand.Load(cfg.BrandKitPath)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Load the content policy prompts are checked against
+	promptPolicy, err := policy.Load(cfg.PromptPolicyPath)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Load the negative prompt presets applied to generations
+	negatives, err := generation.LoadNegativePresets(cfg.NegativePromptsPath)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Confine path arguments to the allowed input directories
+	inputDirs, err := newInputDirs(cfg.AllowedInputDirs, cfg.ReplicateImagesRoot)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Initialize core components, polling on the configured default schedule
+	timeouts := client.Timeouts{Poll: cfg.Timeouts.Poll}
+	
+	// Screen generated and edited outputs for NSFW content when configured
+	screener, err := moderation.NewWithTimeouts(router, store, cfg.OutputModeration, timeouts)
+	if err != nil {
+		return nil, err
+	}
+	
+	gen := generation.NewGeneratorWithTimeouts(router, store, cfg.DebugMode, timeouts)
+	enh := enhancement.NewEnhancerWithTimeouts(router, store, cfg.DebugMode, timeouts)
+	edit := editing.NewEditorWithTimeouts(router, store, cfg.DebugMode, timeouts)
+	gen.SetScreener(screener)
+	gen.SetNegativePresets(negatives)
+	// Private prompts must not show up in filenames either
+	filenameTemplate := cfg.FilenameTemplate
+	if prompts != nil {
+		if strings.Contains(filenameTemplate, "{prompt}") {
+			return nil, fmt.Errorf("invalid FILENAME_TEMPLATE: {prompt} cannot be used when PROMPT_PRIVACY is set")
+		}
+		if filenameTemplate == "" {
+			filenameTemplate = generation.PrivateFilenameTemplate
+		}
+	}
+	if err := gen.SetFilenameTemplate(filenameTemplate); err != nil {
+		return nil, fmt.Errorf("invalid FILENAME_TEMPLATE: %w", err)
+	}
+	edit.SetScreener(screener)
+	
+	// Keep configured community models booted until shutdown
+	background, halt := context.WithCancel(context.Background())
+	warmer := warmup.NewWithTimeouts(router, store, timeouts)
+	warmer.KeepWarm(client.WithPollSchedule(background, cfg.Timeouts.PollSchedule("warm_model")), cfg.WarmModels, cfg.WarmInterval)
+	
+	if interrupted, err := store.InterruptedOperations(); err == nil && len(interrupted) > 0 {
+		slog.Warn("a previous shutdown interrupted operations; see list_interrupted", "count", len(interrupted))
+	}
 	
 	return &ReplicateImageHandler{
 		generator: gen,
 		enhancer:  enh,
 		editor:    edit,
 		storage:   store,
-		debug:     debug,
+		files:     files,
+		signer:    signer,
+		encoder:   transcode.New(cfg.ImageMagickPath),
+		brand:     kit,
+		notifier: notify.New(notify.Options{
+			SlackWebhookURL:   cfg.SlackWebhookURL,
+			DiscordWebhookURL: cfg.DiscordWebhookURL,
+			WebhookURL:        cfg.NotifyWebhookURL,
+			MinDuration:       cfg.NotifyMinDuration,
+		}),
+		debug:     cfg.DebugMode,
+		cache:     cfg.ResultCache,
+		translate: cfg.PromptTranslation,
+		stableIDs: cfg.DeterministicIDs,
+		detail:    cfg.ResponseDetail,
+		open:      cfg.AutoOpenOutputs,
+		purge:     cfg.DeleteRemotePredictions,
+		private:   prompts != nil,
+		halt:      halt,
+		timeouts:  cfg.Timeouts,
+		batchSize: cfg.MaxBatchSize,
+		offPeak:   newOffPeakQueue(cfg.OffPeakWindow),
+		warmer:    warmer,
+		router:    router,
+		replicate: replicateClient,
+		inputDirs: inputDirs,
+		policy:    promptPolicy,
+		dam: damDefaults{
+			creator:    cfg.DAMCreator,
+			usageTerms: cfg.DAMUsageTerms,
+		},
 	}, nil
 }
 
 // CallTool handles execution of image tools
 func (h *ReplicateImageHandler) CallTool(ctx context.Context, req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
+	if offPeakCall(req) {
+		if h.drain.stopping() {
+			resp, err := h.shuttingDown(req)
+			return withStructuredContent(resp), err
+		}
+		resp, err := h.queueOffPeak(req)
+		return withStructuredContent(resp), err
+	}
+	wait := h.initialWait(req)
+	if wait > 0 {
+		// The call may outlive the request, finishing in the background
+		ctx = context.WithoutCancel(ctx)
+	}
+	run, predictions, outputs, ok := h.startCall(ctx, req)
+	if !ok {
+		resp, err := h.shuttingDown(req)
+		return withStructuredContent(resp), err
+	}
+	if wait == 0 {
+		return run()
+	}
+	resp, err := h.runAsync(req, wait, predictions, outputs, run)
+	return withStructuredContent(resp), err
+}
+
+// startCall registers a tool call with the shutdown drain and returns the
+// function running it, with the logs of the predictions it creates and the
+// files it saves. It reports false once a shutdown has started.
+func (h *ReplicateImageHandler) startCall(ctx context.Context, req *protocol.CallToolRequest) (func() (*protocol.CallToolResponse, error), *client.PredictionLog, *storage.OutputLog, bool) {
+	ctx, span := tracing.Start(ctx, "tools/call "+req.Name, "mcp.tool", req.Name)
+	
+	ctx, finish, ok := h.drain.begin(ctx, req.Name, req.Arguments)
+	if !ok {
+		span.End()
+		return nil, nil, nil, false
+	}
+	ctx, predictions := client.WithPredictionLog(ctx)
+	ctx, outputs := storage.WithOutputLog(ctx)
+	
+	run := func() (*protocol.CallToolResponse, error) {
+		defer span.End()
+		defer finish()
+		
+		start := time.Now()
+		ctx, created := h.trackPredictions(ctx, req)
+		resp, err := h.callTool(ctx, req)
+		resp = h.deleteRemotePredictions(ctx, created, resp, err)
+		resp = withSubstitutions(resp, err, predictions)
+		span.RecordError(err)
+		h.notifyCompletion(req, resp, err, time.Since(start))
+		h.openOutput(req, resp, err)
+		resp = h.recordPrompt(req, resp, err)
+		resp = h.withResponseDetail(ctx, req, resp)
+		return withStructuredContent(resp), err
+	}
+	return run, predictions, outputs, true
+}
+
+// callTool dispatches a tool call to its handler
+func (h *ReplicateImageHandler) callTool(ctx context.Context, req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
+	if _, err := responseDetail(req, h.detail); err != nil {
+		return h.errorResponse(req.Name, "invalid_parameters", err.Error(), nil)
+	}
+	if violation := h.checkPromptPolicy(req); violation != nil {
+		return h.policyViolation(req, violation)
+	}
+	// Tools with a dry_run of their own, such as repair_storage, handle it
+	if dryRun, _ := req.Arguments["dry_run"].(bool); dryRun && (dryRunTools[req.Name] || !h.takesArgument(req.Name, "dry_run")) {
+		return h.handleDryRun(ctx, req)
+	}
+	ctx, err := withPriority(ctx, req)
+	if err != nil {
+		return h.errorResponse(req.Name, "invalid_parameters", err.Error(), nil)
+	}
+	ctx, err = withMaxWait(ctx, req)
+	if err != nil {
+		return h.errorResponse(req.Name, "invalid_parameters", err.Error(), nil)
+	}
+	ctx = client.WithPollSchedule(ctx, h.timeouts.PollSchedule(req.Name))
+	if err := h.checkInputPaths(req.Arguments); err != nil {
+		return h.errorResponse(req.Name, "permission_denied", err.Error(), nil)
+	}
+	args, inlinePath, err := h.saveInlineImage(req.Arguments)
+	if err != nil {
+		return h.errorResponse(req.Name, "invalid_parameters", err.Error(), nil)
+	}
+	ctx, args, downloaded, err := h.downloadRemoteInputs(ctx, args)
+	defer h.storage.ReleaseID(ctx)
+	if err != nil {
+		return h.errorResponse(req.Name, "file_error", err.Error(), nil)
+	}
+	args, pagePath, page, err := h.extractPDFPage(req.Name, args)
+	if err != nil {
+		return h.errorResponse(req.Name, "file_error", err.Error(), nil)
+	}
+	if inlinePath == "" && len(downloaded) == 0 && pagePath == "" {
+		return h.dispatch(ctx, req)
+	}
+	fields := make(map[string]interface{})
+	if inlinePath != "" {
+		fields["input_path"] = inlinePath
+	}
+	if len(downloaded) > 0 {
+		fields["downloaded_inputs"] = downloaded
+	}
+	if pagePath != "" {
+		fields["input_path"] = pagePath
+		fields["pdf_page"] = page
+	}
+	resp, err := h.dispatch(ctx, &protocol.CallToolRequest{Name: req.Name, Arguments: args})
+	return withResponseFields(resp, err, fields)
+}
+
+// dispatch calls the handler of a tool
+func (h *ReplicateImageHandler) dispatch(ctx context.Context, req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
 	switch req.Name {
 	// Generation tools
 	case "generate_image":
 		return h.handleGenerateImage(ctx, req.Arguments)
+	case "generate_wide_image":
+		return h.handleGenerateWideImage(ctx, req.Arguments)
 	case "generate_with_visual_context":
 		return h.handleGenerateWithVisualContext(ctx, req.Arguments)
+	case "generate_branded":
+		return h.handleGenerateBranded(ctx, req.Arguments)
+	case "batch_generate":
+		return h.handleBatchGenerate(ctx, req.Arguments)
+	case "professional_headshot":
+		return h.handleProfessionalHeadshot(ctx, req.Arguments)
+	case "product_scene":
+		return h.handleProductScene(ctx, req.Arguments)
+	case "regenerate":
+		return h.handleRegenerate(ctx, req.Arguments)
+	case "register_reference_set":
+		return h.handleRegisterReferenceSet(ctx, req.Arguments)
+	case "list_reference_sets":
+		return h.handleListReferenceSets(ctx, req.Arguments)
+	case "delete_reference_set":
+		return h.handleDeleteReferenceSet(ctx, req.Arguments)
+		
+	// Comparison tools
+	case "create_ab_test":
+		return h.handleCreateABTest(ctx, req.Arguments)
+	case "record_ab_choice":
+		return h.handleRecordABChoice(ctx, req.Arguments)
+	case "ab_report":
+		return h.handleABReport(ctx, req.Arguments)
+	case "parameter_sweep":
+		return h.handleParameterSweep(ctx, req.Arguments)
+	case "generate_preview":
+		return h.handleGeneratePreview(ctx, req.Arguments)
+	case "finalize":
+		return h.handleFinalize(ctx, req.Arguments)
 		
 	// Enhancement tools
 	case "remove_background":
 		return h.handleRemoveBackground(ctx, req.Arguments)
+	case "blur_background":
+		return h.handleBlurBackground(ctx, req.Arguments)
+	case "auto_crop":
+		return h.handleAutoCrop(ctx, req.Arguments)
+	case "apply_film_look":
+		return h.handleApplyFilmLook(ctx, req.Arguments)
+	case "strip_metadata":
+		return h.handleStripMetadata(ctx, req.Arguments)
+	case "stitch_panorama":
+		return h.handleStitchPanorama(ctx, req.Arguments)
+	case "recolor_image":
+		return h.handleRecolorImage(ctx, req.Arguments)
 	case "upscale_image":
 		return h.handleUpscaleImage(ctx, req.Arguments)
+	case "compare_upscalers":
+		return h.handleCompareUpscalers(ctx, req.Arguments)
+	case "upscale_region":
+		return h.handleUpscaleRegion(ctx, req.Arguments)
+	case "compare_images":
+		return h.handleCompareImages(ctx, req.Arguments)
 	case "enhance_face":
 		return h.handleEnhanceFace(ctx, req.Arguments)
 	case "restore_photo":
 		return h.handleRestorePhoto(ctx, req.Arguments)
+	case "revive_photo":
+		return h.handleRevivePhoto(ctx, req.Arguments)
+	case "vectorize_image":
+		return h.handleVectorizeImage(ctx, req.Arguments)
+	case "caption_folder":
+		return h.handleCaptionFolder(ctx, req.Arguments)
+	case "prepare_dataset":
+		return h.handlePrepareDataset(ctx, req.Arguments)
+	case "export_social_sizes":
+		return h.handleExportSocialSizes(ctx, req.Arguments)
+	case "generate_icon_set":
+		return h.handleGenerateIconSet(ctx, req.Arguments)
 		
 	// Editing tools
 	case "edit_image":
 		return h.handleEditImage(ctx, req.Arguments)
 		
+	// Model tools
+	case "warm_model":
+		return h.handleWarmModel(ctx, req.Arguments)
+	case "probe_model":
+		return h.handleProbeModel(ctx, req.Arguments)
+		
+	// Storage tools
+	case "repair_storage":
+		return h.handleRepairStorage(ctx, req.Arguments)
+	case "usage_summary":
+		return h.handleUsageSummary(ctx, req.Arguments)
+	case "export_for_dam":
+		return h.handleExportForDAM(ctx, req.Arguments)
+	case "export_metadata":
+		return h.handleExportMetadata(ctx, req.Arguments)
+	case "list_images":
+		return h.handleListImages(ctx, req.Arguments)
+	case "get_image":
+		return h.handleGetImage(ctx, req.Arguments)
+	case "delete_image":
+		return h.handleDeleteImage(ctx, req.Arguments)
+	case "rate_image":
+		return h.handleRateImage(ctx, req.Arguments)
+	case "annotate_image":
+		return h.handleAnnotateImage(ctx, req.Arguments)
+	case "list_prompt_history":
+		return h.handleListPromptHistory(ctx, req.Arguments)
+	case "favorite_prompt":
+		return h.handleFavoritePrompt(ctx, req.Arguments)
+		
+	// Workflow tools
+	case "run_chain":
+		return h.handleRunChain(ctx, req.Arguments)
+	case "chain_status":
+		return h.handleChainStatus(ctx, req.Arguments)
+	case "list_interrupted":
+		return h.handleListInterrupted(ctx, req.Arguments)
+	case "continue_operation":
+		return h.handleContinueOperation(ctx, req.Arguments)
+		
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", req.Name)
 	}
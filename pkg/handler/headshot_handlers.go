@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/generation"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// headshotAttire describes the clothing of each attire preset
+var headshotAttire = map[string]string{
+	"business_formal": "a tailored dark suit with a crisp shirt",
+	"business_casual": "a smart blazer over an open-collar shirt",
+	"smart_casual":    "a neat knit sweater",
+	"medical":         "a white doctor's coat",
+	"creative":        "a stylish modern outfit in muted tones",
+}
+
+// headshotBackgrounds describes the setting of each background preset
+var headshotBackgrounds = map[string]string{
+	"studio_gray":   "a seamless neutral gray studio backdrop",
+	"studio_white":  "a clean white studio backdrop",
+	"office":        "a softly blurred modern office",
+	"outdoor":       "softly blurred greenery outdoors in natural light",
+	"gradient_blue": "a subtle dark blue gradient backdrop",
+}
+
+// Headshot candidate limits
+const (
+	defaultHeadshotCount = 4
+	maxHeadshotCount     = 4
+	headshotTag          = "person"
+)
+
+// headshotCandidate is one generated headshot and its face-enhanced version
+type headshotCandidate struct {
+	result   *generation.ImageResult
+	enhanced *enhancement.EnhancementResult
+	seed     int
+	notes    []string
+	err      error
+}
+
+// handleProfessionalHeadshot handles the professional_headshot tool: Gen-4
+// generates portraits that keep the person's identity from their photos, and
+// each candidate's face is then enhanced
+func (h *ReplicateImageHandler) handleProfessionalHeadshot(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	var photos []string
+	if photosRaw, ok := args["photos"].([]interface{}); ok {
+		for _, photo := range photosRaw {
+			if photoStr, ok := photo.(string); ok && photoStr != "" {
+				photos = append(photos, photoStr)
+			}
+		}
+	}
+	tags := make([]string, len(photos))
+	for i := range photos {
+		tags[i] = headshotTag
+		if i > 0 {
+			tags[i] = fmt.Sprintf("%s%d", headshotTag, i+1)
+		}
+	}
+
+	// A registered character can stand in for the photos
+	if character, ok := args["character"].(string); ok && character != "" {
+		set, err := h.storage.LoadReferenceSet(character)
+		if err != nil {
+			return h.errorResponse("professional_headshot", "invalid_parameters", err.Error(), nil)
+		}
+		photos = append(photos, h.storage.ReferenceImagePaths(set)...)
+		tags = append(tags, set.Tags()...)
+	}
+	if len(photos) == 0 || len(photos) > storage.MaxReferenceImages {
+		return h.errorResponse("professional_headshot", "invalid_parameters",
+			fmt.Sprintf("photos or character is required (1-%d photos in total)", storage.MaxReferenceImages), nil)
+	}
+
+	attire := "business_formal"
+	if preset, ok := args["attire"].(string); ok && preset != "" {
+		attire = preset
+	}
+	background := "studio_gray"
+	if preset, ok := args["background"].(string); ok && preset != "" {
+		background = preset
+	}
+	if headshotAttire[attire] == "" {
+		return h.errorResponse("professional_headshot", "invalid_parameters", "unknown attire preset: "+attire, nil)
+	}
+	if headshotBackgrounds[background] == "" {
+		return h.errorResponse("professional_headshot", "invalid_parameters", "unknown background preset: "+background, nil)
+	}
+
+	count := defaultHeadshotCount
+	if n, ok := args["count"].(float64); ok {
+		count = int(n)
+	}
+	if count < 1 || count > maxHeadshotCount {
+		return h.errorResponse("professional_headshot", "invalid_parameters",
+			fmt.Sprintf("count must be between 1 and %d", maxHeadshotCount), nil)
+	}
+
+	prompt := headshotPrompt(tags, headshotAttire[attire], headshotBackgrounds[background])
+	if extra, ok := args["instructions"].(string); ok && extra != "" {
+		prompt += " " + extra
+	}
+
+	// Candidates differ by seed; a random base keeps unseeded calls from
+	// hitting the result cache with identical requests
+	baseSeed := rand.Intn(1 << 30)
+	if seed, ok := args["seed"].(float64); ok && seed > 0 {
+		baseSeed = int(seed)
+	}
+	aspectRatio := "3:4"
+	if ratio, ok := args["aspect_ratio"].(string); ok && ratio != "" {
+		aspectRatio = ratio
+	}
+	faceModel := "codeformer"
+	if model, ok := args["face_model"].(string); ok && model != "" {
+		faceModel = model
+	}
+	enhanceFace := true
+	if enhance, ok := args["enhance_face"].(bool); ok {
+		enhanceFace = enhance
+	}
+
+	candidates := make([]headshotCandidate, count)
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(c *headshotCandidate, seed int) {
+			defer wg.Done()
+			c.seed = seed
+			c.result, c.err = h.generator.GenerateWithVisualContext(ctx, generation.Gen4Params{
+				Prompt:          prompt,
+				ReferenceImages: photos,
+				ReferenceTags:   tags,
+				AspectRatio:     aspectRatio,
+				Resolution:      "1080p",
+				Seed:            seed,
+				UseCache:        h.cache,
+			})
+			if c.err != nil {
+				return
+			}
+			if !c.result.Cached {
+				c.notes = append(c.notes, h.addContentCredentials(ctx, c.result.ID)...)
+			}
+			if !enhanceFace {
+				return
+			}
+
+			// A failed enhancement keeps the generated portrait
+			enhanced, err := h.enhancer.EnhanceFace(ctx, enhancement.EnhanceFaceParams{
+				ImagePath: c.result.FilePath,
+				Model:     faceModel,
+				Fidelity:  0.7, // Favor the person's own features over idealized ones
+			})
+			if err != nil {
+				c.notes = append(c.notes, "face enhancement failed: "+err.Error())
+				return
+			}
+			c.enhanced = enhanced
+			c.notes = append(c.notes, h.addContentCredentials(ctx, enhanced.ID)...)
+		}(&candidates[i], baseSeed+i)
+	}
+	wg.Wait()
+
+	var infos []map[string]interface{}
+	var firstErr error
+	for i, c := range candidates {
+		if c.err != nil {
+			if firstErr == nil {
+				firstErr = c.err
+			}
+			infos = append(infos, map[string]interface{}{
+				"index": i + 1,
+				"seed":  c.seed,
+				"error": c.err.Error(),
+			})
+			continue
+		}
+
+		info := map[string]interface{}{
+			"index":          i + 1,
+			"seed":           c.seed,
+			"file_path":      c.result.FilePath,
+			"generation_id":  c.result.ID,
+			"generated_path": c.result.FilePath,
+		}
+		if c.enhanced != nil {
+			info["file_path"] = c.enhanced.OutputPath
+			info["enhanced_id"] = c.enhanced.ID
+		}
+		if shareURL := h.files.URL(info["file_path"].(string)); shareURL != "" {
+			info["share_url"] = shareURL
+		}
+		if notes := append(c.result.Notes, c.notes...); len(notes) > 0 {
+			info["notes"] = notes
+		}
+		infos = append(infos, info)
+	}
+
+	succeeded := count
+	for _, c := range candidates {
+		if c.err != nil {
+			succeeded--
+		}
+	}
+	if succeeded == 0 {
+		return h.toolErrorResponse("professional_headshot", "generation_error", firstErr)
+	}
+
+	message := fmt.Sprintf("Generated %d of %d headshot candidates (%s, %s)", succeeded, count, attire, background)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("professional_headshot", message, map[string]interface{}{
+		"prompt":     prompt,
+		"attire":     attire,
+		"background": background,
+		"candidates": infos,
+	}))
+}
+
+// headshotPrompt builds the Gen-4 prompt for a headshot of the person in the
+// tagged reference photos
+func headshotPrompt(tags []string, attire, background string) string {
+	mentions := make([]string, len(tags))
+	for i, tag := range tags {
+		mentions[i] = "@" + tag
+	}
+	subject := mentions[0]
+	if len(mentions) > 1 {
+		subject = "the person shown in " + strings.Join(mentions[:len(mentions)-1], ", ") + " and " + mentions[len(mentions)-1]
+	}
+	return fmt.Sprintf("Professional corporate headshot of %s wearing %s, in front of %s. "+
+		"Head and shoulders framing, facing the camera with a confident, friendly expression, "+
+		"soft flattering studio lighting, sharp focus on the eyes, natural skin texture, shot on an 85mm lens.",
+		subject, attire, background)
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/brand"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/generation"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// iconDirections are added to the prompt when generate_icon_set generates
+// its source, so the design stays legible at favicon sizes
+const iconDirections = "Centered app icon, simple bold shapes, high contrast, no text, legible at small sizes"
+
+// Bounds of the icon sizes generate_icon_set accepts
+const (
+	minIconSize = 16
+	maxIconSize = 1024
+)
+
+// handleGenerateIconSet handles the generate_icon_set tool: it renders a
+// square image, given or generated, at the standard icon sizes, with a
+// favicon.ico bundle and a maskable icon
+func (h *ReplicateImageHandler) handleGenerateIconSet(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, _ := args["file_path"].(string)
+	prompt, _ := args["prompt"].(string)
+	if (filePath == "") == (strings.TrimSpace(prompt) == "") {
+		return h.errorResponse("generate_icon_set", "invalid_parameters", "exactly one of file_path, image_base64, or prompt is required", nil)
+	}
+	sizes := storage.DefaultIconSizes
+	if raw, ok := args["sizes"].([]interface{}); ok && len(raw) > 0 {
+		seen := make(map[int]bool)
+		sizes = nil
+		for _, item := range raw {
+			size, ok := item.(float64)
+			if !ok || size != float64(int(size)) || size < minIconSize || size > maxIconSize {
+				return h.errorResponse("generate_icon_set", "invalid_parameters",
+					fmt.Sprintf("sizes must be whole numbers from %d to %d", minIconSize, maxIconSize), nil)
+			}
+			if !seen[int(size)] {
+				seen[int(size)] = true
+				sizes = append(sizes, int(size))
+			}
+		}
+		sort.Ints(sizes)
+	}
+	maskable := true
+	if m, ok := args["maskable"].(bool); ok {
+		maskable = m
+	}
+	upscale, _ := args["upscale"].(bool)
+	background := color.NRGBA{255, 255, 255, 255}
+	if hex, ok := args["background"].(string); ok && hex != "" {
+		c, err := brand.ParseHex(hex)
+		if err != nil {
+			return h.errorResponse("generate_icon_set", "invalid_parameters", err.Error(), nil)
+		}
+		background = color.NRGBA{c.R, c.G, c.B, 255}
+	}
+	base := "icon"
+	if name, ok := args["filename"].(string); ok && name != "" {
+		base = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+
+	totalCost := 0.0
+	var notes []string
+	steps := make(map[string]interface{})
+
+	// 1. Generate the source when there is none
+	if filePath == "" {
+		params := generation.GenerateParams{
+			Prompt:       strings.TrimRight(strings.TrimSpace(prompt), ".") + ". " + iconDirections,
+			Model:        "flux-schnell",
+			OutputFormat: "png",
+			AspectRatio:  "1:1",
+			UseCache:     h.cache,
+		}
+		if model, ok := args["model"].(string); ok && model != "" {
+			params.Model = model
+		}
+		if seed, ok := args["seed"].(float64); ok {
+			params.Seed = int(seed)
+		}
+		generated, err := h.generator.GenerateImage(ctx, params)
+		if err != nil {
+			return h.toolErrorResponse("generate_icon_set", "generation_error", err)
+		}
+		filePath = generated.FilePath
+		totalCost += generated.Metrics.Cost
+		steps["source_id"] = generated.ID
+		steps["source_path"] = generated.FilePath
+		notes = append(notes, generated.Notes...)
+		if !generated.Cached {
+			notes = append(notes, h.addContentCredentials(ctx, generated.ID)...)
+		}
+	}
+
+	source, err := storage.LoadIconSource(filePath)
+	if err != nil {
+		return h.errorResponse("generate_icon_set", "file_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	if source.Cropped {
+		notes = append(notes, fmt.Sprintf("the image is not square, so its center %dx%d was used", source.Size(), source.Size()))
+	}
+
+	// 2. Upscale the source for the sizes larger than it
+	largest := sizes[len(sizes)-1]
+	if maskable {
+		largest = max(largest, storage.MaskableIconSize)
+	}
+	large := source
+	if upscale && largest > source.Size() {
+		scale := 2
+		if largest > 2*source.Size() {
+			scale = 4
+		}
+		model := "realesrgan"
+		if m, ok := args["upscale_model"].(string); ok && m != "" {
+			model = m
+		}
+		upscaled, err := h.enhancer.UpscaleImage(ctx, enhancement.UpscaleParams{
+			ImagePath: filePath,
+			Scale:     scale,
+			Model:     model,
+			Filename:  fmt.Sprintf("%s_upscaled_%dx.png", base, scale),
+		})
+		if err != nil {
+			resp, respErr := h.toolErrorResponse("generate_icon_set", "processing_error", err)
+			return withResponseFields(resp, respErr, steps)
+		}
+		totalCost += upscaled.Metrics.Cost
+		steps["upscale_id"] = upscaled.ID
+		steps["upscale_path"] = upscaled.OutputPath
+		notes = append(notes, h.addContentCredentials(ctx, upscaled.ID)...)
+		if large, err = storage.LoadIconSource(upscaled.OutputPath); err != nil {
+			return h.errorResponse("generate_icon_set", "processing_error", err.Error(), steps)
+		}
+	}
+	from := func(size int) *storage.IconSource {
+		if size > source.Size() {
+			return large
+		}
+		return source
+	}
+
+	// 3. Render every icon into one operation
+	id, err := h.storage.OperationID(ctx)
+	if err != nil {
+		return h.errorResponse("generate_icon_set", "storage_error", err.Error(), nil)
+	}
+	defer h.storage.CleanupIfEmpty(id)
+	dir := h.storage.OperationDir(id)
+	write := func(name string, data []byte) (string, error) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return path, nil
+	}
+	describe := func(path string, size int) map[string]interface{} {
+		info := map[string]interface{}{"size": size, "file_path": path}
+		if url := h.files.URL(path); url != "" {
+			info["share_url"] = url
+		}
+		if src := from(size); size > src.Size() {
+			info["enlarged"] = true
+		} else if src != source {
+			info["upscaled"] = true
+		}
+		return info
+	}
+
+	icons := make([]map[string]interface{}, 0, len(sizes))
+	for _, size := range sizes {
+		var flatten *color.NRGBA
+		if size == storage.AppleTouchIconSize {
+			flatten = &background
+		}
+		data, err := from(size).Icon(size, flatten)
+		if err != nil {
+			return h.errorResponse("generate_icon_set", "processing_error", err.Error(), nil)
+		}
+		path, err := write(fmt.Sprintf("%s_%d.png", base, size), data)
+		if err != nil {
+			return h.errorResponse("generate_icon_set", "storage_error", err.Error(), nil)
+		}
+		icons = append(icons, describe(path, size))
+	}
+
+	entries := make([][]byte, len(storage.ICOSizes))
+	for i, size := range storage.ICOSizes {
+		if entries[i], err = source.Icon(size, nil); err != nil {
+			return h.errorResponse("generate_icon_set", "processing_error", err.Error(), nil)
+		}
+	}
+	ico, err := storage.EncodeICO(entries)
+	if err != nil {
+		return h.errorResponse("generate_icon_set", "processing_error", err.Error(), nil)
+	}
+	icoPath, err := write("favicon.ico", ico)
+	if err != nil {
+		return h.errorResponse("generate_icon_set", "storage_error", err.Error(), nil)
+	}
+
+	result := map[string]interface{}{
+		"id":      id,
+		"paths":   map[string]string{"directory": dir},
+		"icons":   icons,
+		"favicon": map[string]interface{}{"file_path": icoPath, "sizes": storage.ICOSizes},
+	}
+	if maskable {
+		data, err := from(storage.MaskableIconSize).MaskableIcon(storage.MaskableIconSize, background)
+		if err != nil {
+			return h.errorResponse("generate_icon_set", "processing_error", err.Error(), nil)
+		}
+		path, err := write(fmt.Sprintf("%s_maskable_%d.png", base, storage.MaskableIconSize), data)
+		if err != nil {
+			return h.errorResponse("generate_icon_set", "storage_error", err.Error(), nil)
+		}
+		result["maskable"] = describe(path, storage.MaskableIconSize)
+	}
+
+	parameters := map[string]interface{}{
+		"sizes":      sizes,
+		"maskable":   maskable,
+		"upscale":    upscale,
+		"background": brand.Hex(color.RGBA{background.R, background.G, background.B, 255}),
+	}
+	if prompt != "" {
+		parameters["prompt"] = prompt
+	} else {
+		parameters["file_path"] = filePath
+	}
+	for key, value := range steps {
+		parameters[key] = value
+	}
+	// The icons are not signed: C2PA does not cover ICO files, and a manifest
+	// would outweigh a 16px PNG. The source and upscale carry the credentials.
+	if _, err := h.storage.FinalizeFiles(id, "generate_icon_set", parameters, ""); err != nil {
+		return h.errorResponse("generate_icon_set", "storage_error", err.Error(), nil)
+	}
+
+	for key, value := range steps {
+		result[key] = value
+	}
+	result["total_cost"] = totalCost
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Generated %d icons, favicon.ico", len(icons))
+	if maskable {
+		message += ", and a maskable icon"
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("generate_icon_set", message+" in "+dir, result))
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// imageArguments are the arguments holding input images, as a path or a
+// list of paths. Any of them may be an http(s) URL instead.
+var imageArguments = []string{"file_path", "mask_path", "scene_path", "compare_path", "reference_images", "images", "photos"}
+
+// downloadRemoteInputs returns a copy of args with every image URL replaced by
+// the local path it was downloaded to, along with the path of each URL.
+// The images are downloaded into the directory of an operation ID reserved
+// in the returned context for the call, which must release it when done.
+// args and ctx are returned as they are when args holds no URLs.
+func (h *ReplicateImageHandler) downloadRemoteInputs(ctx context.Context, args map[string]interface{}) (context.Context, map[string]interface{}, map[string]string, error) {
+	var resolved map[string]interface{}
+	var id string
+	downloaded := make(map[string]string)
+	download := func(value string) (string, error) {
+		if id == "" {
+			var err error
+			if ctx, id, err = h.storage.ReserveID(ctx); err != nil {
+				return "", err
+			}
+		}
+		path, err := h.storage.DownloadInput(id, value)
+		if err != nil {
+			return "", err
+		}
+		slog.Debug("downloaded input image", "url", value, "path", path)
+		downloaded[value] = path
+		return path, nil
+	}
+
+	for _, key := range imageArguments {
+		switch value := args[key].(type) {
+		case string:
+			if !storage.IsRemote(value) {
+				continue
+			}
+			path, err := download(value)
+			if err != nil {
+				return ctx, nil, nil, fmt.Errorf("failed to download %s from %s: %w", key, value, err)
+			}
+			if resolved == nil {
+				resolved = copyArguments(args)
+			}
+			resolved[key] = path
+		case []interface{}:
+			var list []interface{}
+			for i, item := range value {
+				url, ok := item.(string)
+				if !ok || !storage.IsRemote(url) {
+					continue
+				}
+				path, err := download(url)
+				if err != nil {
+					return ctx, nil, nil, fmt.Errorf("failed to download %s from %s: %w", key, url, err)
+				}
+				if list == nil {
+					list = append([]interface{}(nil), value...)
+				}
+				list[i] = path
+			}
+			if list == nil {
+				continue
+			}
+			if resolved == nil {
+				resolved = copyArguments(args)
+			}
+			resolved[key] = list
+		}
+	}
+	if resolved == nil {
+		return ctx, args, nil, nil
+	}
+	return ctx, resolved, downloaded, nil
+}
+
+// copyArguments returns a shallow copy of a tool call's arguments
+func copyArguments(args map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		copied[k] = v
+	}
+	return copied
+}
+
+// inlineImageSchema is the image_base64 property added to the schema of every
+// tool that takes a file_path
+const inlineImageSchema = `{
+	"type": "string",
+	"description": "The input image as base64 or a data URL, instead of file_path. It is saved under inputs/ in storage first."
+}`
+
+// saveInlineImage returns a copy of args with image_base64 replaced by a
+// file_path to the saved image, along with that path. args is returned as is
+// when it has no image_base64.
+func (h *ReplicateImageHandler) saveInlineImage(args map[string]interface{}) (map[string]interface{}, string, error) {
+	encoded, _ := args["image_base64"].(string)
+	if encoded == "" {
+		return args, "", nil
+	}
+	if filePath, _ := args["file_path"].(string); filePath != "" {
+		return nil, "", fmt.Errorf("pass either file_path or image_base64, not both")
+	}
+
+	// Accept a data URL as well as bare base64, wrapped or not
+	if strings.HasPrefix(encoded, "data:") {
+		if i := strings.Index(encoded, ","); i != -1 {
+			encoded = encoded[i+1:]
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return nil, "", fmt.Errorf("image_base64 is not valid base64: %w", err)
+	}
+	path, err := h.storage.SaveInlineInput(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image_base64: %w", err)
+	}
+
+	resolved := copyArguments(args)
+	delete(resolved, "image_base64")
+	resolved["file_path"] = path
+	return resolved, path, nil
+}
+
+// addInlineImage adds image_base64 to the schema of every tool that takes a
+// file_path, making file_path optional since either one will do
+func addInlineImage(tools []protocol.Tool) {
+	for i := range tools {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(tools[i].InputSchema, &parsed); err != nil {
+			continue
+		}
+		properties, ok := parsed["properties"].(map[string]interface{})
+		if !ok || properties["file_path"] == nil {
+			continue
+		}
+		properties["image_base64"] = json.RawMessage(inlineImageSchema)
+		if required, ok := parsed["required"].([]interface{}); ok {
+			var kept []interface{}
+			for _, name := range required {
+				if name != "file_path" {
+					kept = append(kept, name)
+				}
+			}
+			if len(kept) == 0 {
+				delete(parsed, "required")
+			} else {
+				parsed["required"] = kept
+			}
+		}
+		if raw, err := json.Marshal(parsed); err == nil {
+			tools[i].InputSchema = raw
+		}
+	}
+}
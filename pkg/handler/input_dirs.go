@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// pathArguments are the arguments naming local files or directories to read
+// or write, which are checked against the allowed input directories: the
+// image arguments and those naming a LUT, a folder or an output file. A tool
+// taking a new path argument must add it here.
+var pathArguments = append([]string{"lut_path", "directory", "output_path"}, imageArguments...)
+
+// newInputDirs resolves the allowed input directories to canonical paths,
+// adding the storage root, which holds downloaded and inline inputs and
+// earlier outputs. It returns nil, allowing any path, when none are given.
+func newInputDirs(dirs []string, storageRoot string) ([]string, error) {
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+	roots := make([]string, 0, len(dirs)+1)
+	for _, dir := range append(dirs, storageRoot) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALLOWED_INPUT_DIRS entry %q: %w", dir, err)
+		}
+		root, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALLOWED_INPUT_DIRS entry %q: %w", dir, err)
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+// checkInputPaths checks that every path argument is inside an allowed input
+// directory once made absolute and its symlinks resolved, so neither ".."
+// segments nor links lead out of them. Image URLs are skipped; they are
+// downloaded into storage.
+func (h *ReplicateImageHandler) checkInputPaths(args map[string]interface{}) error {
+	if h.inputDirs == nil {
+		return nil
+	}
+	for _, key := range pathArguments {
+		var paths []string
+		switch value := args[key].(type) {
+		case string:
+			paths = []string{value}
+		case []interface{}:
+			for _, item := range value {
+				if path, ok := item.(string); ok {
+					paths = append(paths, path)
+				}
+			}
+		}
+		for _, path := range paths {
+			if path == "" || storage.IsRemote(path) {
+				continue
+			}
+			if !h.inputAllowed(path) {
+				return fmt.Errorf("%s %s is outside the allowed input directories (ALLOWED_INPUT_DIRS)", key, path)
+			}
+		}
+	}
+	return nil
+}
+
+// inputAllowed reports whether a path resolves to a location inside one of
+// the allowed input directories
+func (h *ReplicateImageHandler) inputAllowed(path string) bool {
+	resolved, err := canonicalPath(path)
+	if err != nil {
+		return false
+	}
+	for _, root := range h.inputDirs {
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalPath returns the absolute form of path with its symlinks resolved.
+// Of a path that does not exist yet, such as an output to write, the nearest
+// existing directory is resolved.
+func canonicalPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	existing, rest := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		parent := filepath.Dir(existing)
+		if !os.IsNotExist(err) || parent == existing {
+			return "", err
+		}
+		existing, rest = parent, filepath.Join(filepath.Base(existing), rest)
+	}
+}
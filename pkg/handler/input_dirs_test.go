@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// localPathDescription matches the description of an argument taking local
+// paths whose name does not say so, such as images or photos
+var localPathDescription = regexp.MustCompile(`(?i)\blocal (\w+ )?(paths?|photos?|images?)\b`)
+
+// TestPathArgumentsCoverSchemas checks that every argument of the tool
+// schemas that names a local path is declared in pathArguments, so a new one
+// cannot skip the allowed input directories
+func TestPathArgumentsCoverSchemas(t *testing.T) {
+	declared := make(map[string]bool)
+	for _, key := range pathArguments {
+		declared[key] = true
+	}
+	h := &ReplicateImageHandler{}
+	tools, err := h.ListTools(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range tools.Tools {
+		var schema struct {
+			Properties map[string]struct {
+				Description string `json:"description"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(tool.InputSchema, &schema); err != nil {
+			t.Fatalf("%s: %v", tool.Name, err)
+		}
+		for name, property := range schema.Properties {
+			names := strings.HasSuffix(name, "_path") || name == "directory" || localPathDescription.MatchString(property.Description)
+			if names && !declared[name] {
+				t.Errorf("%s argument %s names a local path but is not in pathArguments", tool.Name, name)
+			}
+		}
+	}
+}
+
+// TestCheckInputPaths checks paths leaving the allowed directory through ".."
+// segments or symlinks, and output paths that do not exist yet
+func TestCheckInputPaths(t *testing.T) {
+	base := t.TempDir()
+	allowed := filepath.Join(base, "allowed")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{allowed, outside, filepath.Join(allowed, "sub"), filepath.Join(base, "storage")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(allowed, "photo.png"), filepath.Join(outside, "secret.png")} {
+		if err := os.WriteFile(file, []byte("png"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(allowed, "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.png"), filepath.Join(allowed, "secret.png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(allowed, "photo.png"), filepath.Join(outside, "inside.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	inputDirs, err := newInputDirs([]string{allowed}, filepath.Join(base, "storage"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &ReplicateImageHandler{inputDirs: inputDirs}
+
+	tests := []struct {
+		name  string
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{"file inside", "file_path", filepath.Join(allowed, "photo.png"), true},
+		{"dot dot staying inside", "file_path", filepath.Join(allowed, "sub", "..", "photo.png"), true},
+		{"dot dot leaving", "file_path", allowed + "/../outside/secret.png", false},
+		{"file outside", "mask_path", filepath.Join(outside, "secret.png"), false},
+		{"directory symlink escaping", "file_path", filepath.Join(allowed, "escape", "secret.png"), false},
+		{"file symlink escaping", "file_path", filepath.Join(allowed, "secret.png"), false},
+		{"symlink from outside into allowed", "file_path", filepath.Join(outside, "inside.png"), true},
+		{"directory argument through symlink", "directory", filepath.Join(allowed, "escape"), false},
+		{"new output inside", "output_path", filepath.Join(allowed, "new", "dir", "out.png"), true},
+		{"new output outside", "output_path", filepath.Join(outside, "new", "out.png"), false},
+		{"new output through symlink", "output_path", filepath.Join(allowed, "escape", "new", "out.png"), false},
+		{"new output dot dot leaving", "output_path", allowed + "/new/../../outside/out.png", false},
+		{"list with one outside", "images", []interface{}{filepath.Join(allowed, "photo.png"), filepath.Join(outside, "secret.png")}, false},
+		{"URL", "file_path", "https://example.com/photo.png", true},
+		{"other argument", "prompt", "../../etc/passwd", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := h.checkInputPaths(map[string]interface{}{tt.key: tt.value})
+			if (err == nil) != tt.ok {
+				t.Errorf("checkInputPaths(%s: %v) = %v, want ok %v", tt.key, tt.value, err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package handler
+
+import "github.com/gomcpgo/mcp/pkg/protocol"
+
+// The MCP features the handler relies on beyond those of the first gomcpgo/mcp
+// releases. go.mod replaces the module with a local checkout, so its required
+// version does not say which one is built; a checkout too old for these fails
+// the build here rather than wherever the fields are first used.
+var (
+	_ = protocol.Tool{OutputSchema: nil}                  // Output schemas of tools
+	_ = protocol.CallToolResponse{StructuredContent: nil} // Structured content of results
+)
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleCompareImages handles the compare_images tool: it measures how much
+// a second image differs from a first, such as a restoration from its
+// original, and optionally stores a heatmap of the differences
+func (h *ReplicateImageHandler) handleCompareImages(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("compare_images", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+	comparePath, ok := args["compare_path"].(string)
+	if !ok || comparePath == "" {
+		return h.errorResponse("compare_images", "invalid_parameters", "compare_path parameter is required", nil)
+	}
+	heatmap := true
+	if value, ok := args["heatmap"].(bool); ok {
+		heatmap = value
+	}
+
+	comparison, err := storage.CompareImages(filePath, comparePath, heatmap)
+	if err != nil {
+		return h.errorResponse("compare_images", "processing_error", err.Error(),
+			map[string]interface{}{"file_path": filePath, "compare_path": comparePath})
+	}
+
+	metrics := map[string]interface{}{
+		"ssim":                comparison.SSIM,
+		"mean_absolute_error": comparison.MeanAbsoluteError,
+		"changed_share":       comparison.ChangedShare,
+		"sharpness": map[string]interface{}{
+			"file_path":    comparison.Sharpness[0],
+			"compare_path": comparison.Sharpness[1],
+		},
+	}
+	// JSON has no infinity: identical images have no PSNR
+	identical := math.IsInf(comparison.PSNR, 1)
+	if identical {
+		metrics["psnr"] = nil
+	} else {
+		metrics["psnr"] = comparison.PSNR
+	}
+	result := map[string]interface{}{
+		"metrics":     metrics,
+		"identical":   identical,
+		"compared_at": map[string]int{"width": comparison.Width, "height": comparison.Height},
+	}
+	if comparison.Resized {
+		result["resized"] = true
+	}
+	if comparison.Sharpness[0] > 0 {
+		result["sharpness_change"] = comparison.Sharpness[1]/comparison.Sharpness[0] - 1
+	}
+
+	if comparison.Heatmap != nil {
+		filename := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + "_diff"
+		if name, ok := args["filename"].(string); ok && name != "" {
+			filename = name
+		}
+		filename = strings.TrimSuffix(filepath.Base(filename), ".png") + ".png"
+		parameters := map[string]interface{}{
+			"input_path":   filePath,
+			"compare_path": comparePath,
+			"ssim":         comparison.SSIM,
+		}
+		if !identical {
+			parameters["psnr"] = comparison.PSNR
+		}
+		id, outputPath, err := h.storage.SaveLocalResult(ctx, "compare_images", parameters, filename, comparison.Heatmap)
+		if err != nil {
+			return h.errorResponse("compare_images", "storage_error", err.Error(), nil)
+		}
+		result["id"] = id
+		result["heatmap_path"] = outputPath
+		if url := h.files.URL(outputPath); url != "" {
+			result["share_url"] = url
+		}
+	}
+
+	message := fmt.Sprintf("SSIM %.4f, PSNR %.2f dB", comparison.SSIM, comparison.PSNR)
+	if identical {
+		message = "The images are identical"
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("compare_images", message, result))
+}
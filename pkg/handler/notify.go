@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/notify"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// thumbnailEdge is the longest side, in pixels, of notification previews
+const thumbnailEdge = 256
+
+// quietTools never trigger notifications; they produce no images
+var quietTools = map[string]bool{
+	"repair_storage":         true,
+	"usage_summary":          true,
+	"export_for_dam":         true,
+	"export_metadata":        true,
+	"record_ab_choice":       true,
+	"ab_report":              true,
+	"register_reference_set": true,
+	"list_reference_sets":    true,
+	"delete_reference_set":   true,
+	"list_prompt_history":    true,
+	"favorite_prompt":        true,
+	"list_images":            true,
+	"get_image":              true,
+	"delete_image":           true,
+	"rate_image":             true,
+	"annotate_image":         true,
+	"list_interrupted":       true,
+	"continue_operation":     true,
+}
+
+// toolResult is the part of a tool response a notification reports
+type toolResult struct {
+	Success bool   `json:"success"`
+	Status  string `json:"status"`
+	ID      string `json:"id"`
+	Paths   struct {
+		FilePath string `json:"file_path"`
+		ShareURL string `json:"share_url"`
+	} `json:"paths"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// notifyCompletion announces a finished tool call when it ran long enough
+// for the user to have moved on
+func (h *ReplicateImageHandler) notifyCompletion(req *protocol.CallToolRequest, resp *protocol.CallToolResponse, callErr error, elapsed time.Duration) {
+	if !h.notifier.ShouldNotify(elapsed) || quietTools[req.Name] {
+		return
+	}
+
+	event := notify.Event{
+		Operation: req.Name,
+		Duration:  elapsed.Seconds(),
+	}
+	if prompt, ok := req.Arguments["prompt"].(string); ok && !h.private {
+		event.Prompt = prompt
+	}
+
+	if callErr != nil {
+		event.Error = callErr.Error()
+	} else if resp != nil && len(resp.Content) > 0 {
+		var result toolResult
+		if err := json.Unmarshal([]byte(resp.Content[0].Text), &result); err != nil {
+			slog.Debug("not notifying for unparseable tool response", "tool", req.Name, "error", err)
+			return
+		}
+		if result.Status == "processing" {
+			return // Announced when the operation actually finishes
+		}
+		event.Success = result.Success
+		event.StorageID = result.ID
+		event.FilePath = result.Paths.FilePath
+		event.ShareURL = result.Paths.ShareURL
+		event.Error = result.Error.Message
+	}
+
+	if event.Success && event.FilePath != "" {
+		thumbnail, err := storage.Thumbnail(event.FilePath, thumbnailEdge)
+		if err != nil {
+			slog.Debug("failed to create notification thumbnail", "path", event.FilePath, "error", err)
+		}
+		event.Thumbnail = thumbnail
+	}
+
+	h.notifier.Notify(event)
+}
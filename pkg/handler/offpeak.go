@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/config"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// priorityOffPeak queues a call until the off-peak window instead of running
+// it. Once running, its predictions wait for a slot with batch priority.
+const priorityOffPeak = "off_peak"
+
+// offPeakQueue holds the calls queued for the off-peak window in arrival
+// order. They run one at a time, each starting only while the window is
+// open; a call still running when the window closes is left to finish.
+type offPeakQueue struct {
+	window config.Window
+	mu     sync.Mutex
+	queue  []queuedCall
+	wake   chan struct{} // Signalled when a call is queued
+	stop   chan struct{} // Closed by a shutdown
+	once   sync.Once     // Starts the loop running the queue
+	halted sync.Once
+}
+
+// queuedCall is a background operation waiting for the off-peak window
+type queuedCall struct {
+	id string
+	op *pendingOp
+}
+
+func newOffPeakQueue(window config.Window) *offPeakQueue {
+	if window.IsZero() {
+		return nil
+	}
+	return &offPeakQueue{window: window, wake: make(chan struct{}, 1), stop: make(chan struct{})}
+}
+
+// add queues an operation and makes sure the loop running the queue is
+// started
+func (q *offPeakQueue) add(h *ReplicateImageHandler, id string, op *pendingOp) {
+	q.mu.Lock()
+	q.queue = append(q.queue, queuedCall{id: id, op: op})
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	q.once.Do(func() { go h.runOffPeak() })
+}
+
+// position returns the 1-based place of an operation in the queue, or 0
+// when it is not waiting
+func (q *offPeakQueue) position(id string) int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.IndexFunc(q.queue, func(c queuedCall) bool { return c.id == id }) + 1
+}
+
+// next waits for a queued call and for the window to open, then removes the
+// call from the queue and returns it. It reports false once a shutdown has
+// started.
+func (q *offPeakQueue) next() (queuedCall, bool) {
+	for {
+		q.mu.Lock()
+		empty := len(q.queue) == 0
+		q.mu.Unlock()
+		if empty {
+			select {
+			case <-q.wake:
+				continue
+			case <-q.stop:
+				return queuedCall{}, false
+			}
+		}
+
+		if now := time.Now(); !q.window.Contains(now) {
+			timer := time.NewTimer(q.window.NextStart(now).Sub(now))
+			select {
+			case <-timer.C:
+				continue
+			case <-q.stop:
+				timer.Stop()
+				return queuedCall{}, false
+			}
+		}
+
+		q.mu.Lock()
+		call := q.queue[0]
+		q.queue = q.queue[1:]
+		q.mu.Unlock()
+		return call, true
+	}
+}
+
+// halt stops starting queued calls. Those not started stay saved and are
+// queued again on the next start.
+func (q *offPeakQueue) halt() {
+	if q == nil {
+		return
+	}
+	q.halted.Do(func() { close(q.stop) })
+}
+
+// status describes where a queued operation stands, for its processing
+// response
+func (q *offPeakQueue) status(id string, position int) map[string]interface{} {
+	fields := map[string]interface{}{
+		"queued":         true,
+		"queue_position": position,
+		"window":         q.window.String(),
+		"message": fmt.Sprintf("Queued for the off-peak window (%s), %d in line. Use continue_operation with prediction_id='%s' to check status.",
+			q.window, position, id),
+	}
+	if now := time.Now(); !q.window.Contains(now) {
+		fields["starts_at"] = q.window.NextStart(now).Format(time.RFC3339)
+	}
+	return fields
+}
+
+// runOffPeak runs the queued calls one after another as the window allows,
+// until a shutdown
+func (h *ReplicateImageHandler) runOffPeak() {
+	for {
+		call, ok := h.offPeak.next()
+		if !ok {
+			return
+		}
+		slog.Info("starting off-peak operation", "prediction_id", call.id, "tool", call.op.tool)
+		if !h.startPending(call.id, call.op) {
+			return
+		}
+		select {
+		case <-call.op.done:
+		case <-h.offPeak.stop:
+			return
+		}
+	}
+}
+
+// offPeakCall reports whether a call asks to wait for the off-peak window.
+// Dry runs spend nothing, so they run right away.
+func offPeakCall(req *protocol.CallToolRequest) bool {
+	priority, _ := req.Arguments["priority"].(string)
+	dryRun, _ := req.Arguments["dry_run"].(bool)
+	return priority == priorityOffPeak && !dryRun && predictionTool(req.Name)
+}
+
+// queueOffPeak saves a call for the off-peak window and returns a processing
+// response naming it, which continue_operation collects once it has run
+func (h *ReplicateImageHandler) queueOffPeak(req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
+	if h.offPeak == nil {
+		return h.errorResponse(req.Name, "invalid_parameters", "off_peak priority needs OFF_PEAK_WINDOW to be set", nil)
+	}
+	if err := h.checkInputPaths(req.Arguments); err != nil {
+		return h.errorResponse(req.Name, "permission_denied", err.Error(), nil)
+	}
+	// The inline image is saved now, so the saved call refers to its file
+	args, _, err := h.saveInlineImage(req.Arguments)
+	if err != nil {
+		return h.errorResponse(req.Name, "invalid_parameters", err.Error(), nil)
+	}
+
+	op := &pendingOp{tool: req.Name, args: args, started: time.Now(), predictions: &client.PredictionLog{}, done: make(chan struct{}), offPeak: true}
+	_, op.outputs = storage.WithOutputLog(context.Background())
+	id := h.pending.add(op)
+	h.persistPending(id, op)
+	h.offPeak.add(h, id, op)
+	return h.processingResponse(op, id)
+}
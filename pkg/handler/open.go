@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+)
+
+// noOpenTools save no image to open, besides the quiet tools
+var noOpenTools = map[string]bool{
+	"caption_folder":  true,
+	"prepare_dataset": true,
+	"chain_status":    true,
+	"warm_model":      true,
+	"probe_model":     true,
+}
+
+// openExtensions are the image formats open_output opens. Any other file is
+// left alone, since the viewer picked for it could run it.
+var openExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".webp": true,
+	".gif":  true,
+	".bmp":  true,
+	".svg":  true,
+}
+
+// openOutputSchema is the open_output property added to the schema of every
+// tool that saves an image
+const openOutputSchema = `{
+	"type": "boolean",
+	"description": "Open the saved image in the default viewer when the server runs on a desktop. Defaults to AUTO_OPEN_OUTPUTS."
+}`
+
+// openTool reports whether a tool saves an image open_output can open
+func openTool(name string) bool {
+	return !quietTools[name] && !noOpenTools[name]
+}
+
+// openOutput opens the file a successful tool call saved in the OS default
+// viewer when asked to, per call or by default. Failures are only logged.
+func (h *ReplicateImageHandler) openOutput(req *protocol.CallToolRequest, resp *protocol.CallToolResponse, callErr error) {
+	open := h.open
+	if o, ok := req.Arguments["open_output"].(bool); ok {
+		open = o
+	}
+	if !open || !openTool(req.Name) || callErr != nil || resp == nil || len(resp.Content) == 0 {
+		return
+	}
+
+	var result toolResult
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &result); err != nil {
+		return
+	}
+	if !result.Success || result.Status == "processing" || result.Paths.FilePath == "" {
+		return
+	}
+	if ext := strings.ToLower(filepath.Ext(result.Paths.FilePath)); !openExtensions[ext] {
+		slog.Warn("not opening output without an image extension", "tool", req.Name, "path", result.Paths.FilePath)
+		return
+	}
+	if err := openFile(result.Paths.FilePath); err != nil {
+		slog.Warn("failed to open output", "tool", req.Name, "path", result.Paths.FilePath, "error", err)
+	}
+}
+
+// openFile starts the OS default viewer on path without waiting for it
+func openFile(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		// Headless servers have no viewer to open
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return fmt.Errorf("no desktop session (DISPLAY and WAYLAND_DISPLAY are unset)")
+		}
+		cmd = exec.Command("xdg-open", path)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait() // Reap the viewer launcher once it exits
+	return nil
+}
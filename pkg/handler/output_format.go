@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/transcode"
+)
+
+// encodingTools take output_format and output_quality, besides generate_image,
+// which declares its own
+var encodingTools = map[string]bool{
+	"generate_with_visual_context": true,
+	"remove_background":            true,
+	"upscale_image":                true,
+	"enhance_face":                 true,
+	"restore_photo":                true,
+}
+
+// outputFormatSchema is the output_format property added to encodingTools
+const outputFormatSchema = `{
+	"type": "string",
+	"description": "Output format. The model's output is re-encoded when it differs; jpg has no transparency, so cutouts are flattened onto white.",
+	"enum": ["png", "jpg", "webp", "avif"]
+}`
+
+// outputQualitySchema is the output_quality property added to encodingTools
+const outputQualitySchema = `{
+	"type": "integer",
+	"description": "Quality of jpg, webp, and avif output (1-100). Defaults to the encoder's default.",
+	"minimum": 1,
+	"maximum": 100
+}`
+
+// outputEncoding is the output format and quality a call asked for
+type outputEncoding struct {
+	format  string
+	quality int
+}
+
+// outputEncodingArgs reads and validates output_format and output_quality
+func outputEncodingArgs(args map[string]interface{}) (outputEncoding, error) {
+	var enc outputEncoding
+	if format, ok := args["output_format"].(string); ok && format != "" {
+		if format == "jpeg" {
+			format = transcode.FormatJPG
+		}
+		if !transcode.Valid(format) {
+			return enc, fmt.Errorf("output_format must be png, jpg, webp, or avif")
+		}
+		enc.format = format
+	}
+	if quality, ok := args["output_quality"].(float64); ok {
+		if quality < 1 || quality > 100 || quality != float64(int(quality)) {
+			return enc, fmt.Errorf("output_quality must be a whole number from 1 to 100")
+		}
+		enc.quality = int(quality)
+	}
+	return enc, nil
+}
+
+// encodeOutputs re-encodes the outputs of an operation that are not already
+// in the requested format, updating path, paths, and size to the re-encoded
+// files. It returns notes for the response; if re-encoding fails, the outputs
+// not yet re-encoded are kept as the model made them.
+func (h *ReplicateImageHandler) encodeOutputs(ctx context.Context, id string, enc outputEncoding, path *string, paths []string, size *int64) []string {
+	if enc.format == "" {
+		return nil
+	}
+
+	parameters := map[string]interface{}{"output_format": enc.format}
+	if enc.quality > 0 {
+		parameters["output_quality"] = enc.quality
+	}
+	converted, err := h.storage.ConvertOutputs(id, "."+enc.format, parameters, func(src, dst string) error {
+		return h.encoder.Encode(ctx, src, dst, enc.format, enc.quality)
+	})
+
+	if newPath, ok := converted[*path]; ok {
+		*path = newPath
+		if info, err := os.Stat(newPath); err == nil {
+			*size = info.Size()
+		}
+	}
+	for i, p := range paths {
+		if newPath, ok := converted[p]; ok {
+			paths[i] = newPath
+		}
+	}
+
+	if err != nil {
+		slog.Warn("failed to re-encode outputs", "storage_id", id, "format", enc.format, "error", err)
+		return []string{fmt.Sprintf("output was not re-encoded as %s: %s", enc.format, err)}
+	}
+	if len(converted) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("re-encoded the model's output as %s", enc.format)}
+}
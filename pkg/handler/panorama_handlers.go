@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleStitchPanorama handles the stitch_panorama tool: it joins
+// overlapping tiles into one wide or tall image locally, without a
+// prediction
+func (h *ReplicateImageHandler) handleStitchPanorama(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	var paths []string
+	if imagesRaw, ok := args["images"].([]interface{}); ok {
+		for _, img := range imagesRaw {
+			if path, ok := img.(string); ok && path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	if len(paths) < 2 || len(paths) > storage.MaxPanoramaTiles {
+		return h.errorResponse("stitch_panorama", "invalid_parameters",
+			fmt.Sprintf("images parameter is required (2-%d images, in order)", storage.MaxPanoramaTiles), nil)
+	}
+
+	opts := storage.PanoramaOptions{Detect: true}
+	direction := "horizontal"
+	if value, ok := args["direction"].(string); ok && value != "" {
+		direction = value
+	}
+	switch direction {
+	case "horizontal":
+	case "vertical":
+		opts.Vertical = true
+	default:
+		return h.errorResponse("stitch_panorama", "invalid_parameters", "direction must be horizontal or vertical", nil)
+	}
+	parameters := map[string]interface{}{"input_paths": paths, "direction": direction}
+	if overlap, ok := args["overlap"].(float64); ok {
+		if overlap < 0 || overlap > storage.MaxPanoramaLap {
+			return h.errorResponse("stitch_panorama", "invalid_parameters",
+				fmt.Sprintf("overlap must be between 0 and %g", storage.MaxPanoramaLap), nil)
+		}
+		opts.Overlap = overlap
+		opts.Detect = false
+		parameters["overlap"] = overlap
+	}
+
+	pano, err := storage.StitchPanorama(paths, opts)
+	if err != nil {
+		return h.errorResponse("stitch_panorama", "processing_error", err.Error(), map[string]interface{}{"images": paths})
+	}
+
+	filename := strings.TrimSuffix(filepath.Base(paths[0]), filepath.Ext(paths[0])) + "_panorama"
+	if name, ok := args["filename"].(string); ok && name != "" {
+		filename = name
+	}
+	filename = strings.TrimSuffix(filepath.Base(filename), ".png") + ".png"
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "stitch_panorama", parameters, filename, pano.Data)
+	if err != nil {
+		return h.errorResponse("stitch_panorama", "storage_error", err.Error(), nil)
+	}
+
+	result := map[string]interface{}{
+		"id":        id,
+		"file_path": outputPath,
+		"width":     pano.Width,
+		"height":    pano.Height,
+		"seams":     pano.Seams,
+	}
+	if url := h.files.URL(outputPath); url != "" {
+		result["share_url"] = url
+	}
+	var notes []string
+	for i, seam := range pano.Seams {
+		if !seam.Matched {
+			notes = append(notes, fmt.Sprintf("images %d and %d do not match across their overlap of %d pixels; check their order, or set overlap if they were made with a known one", i+1, i+2, seam.Overlap))
+		}
+	}
+	notes = append(notes, h.addContentCredentials(ctx, id)...)
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Stitched %d images into a %dx%d panorama: %s", len(paths), pano.Width, pano.Height, outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("stitch_panorama", message, result))
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/pdf"
+)
+
+// pdfTools take a PDF as file_path, with pdf_page choosing the page
+var pdfTools = map[string]bool{
+	"remove_background": true,
+	"blur_background":   true,
+	"auto_crop":         true,
+	"upscale_image":     true,
+	"compare_upscalers": true,
+	"upscale_region":    true,
+	"enhance_face":      true,
+	"restore_photo":     true,
+	"revive_photo":      true,
+	"vectorize_image":   true,
+}
+
+// pdfPageSchema is the pdf_page property added to pdfTools
+const pdfPageSchema = `{
+	"type": "integer",
+	"description": "Page to use when file_path is a PDF, counted from 1 (default: 1). The page's scanned image is extracted; pages of text or vector drawings cannot be used.",
+	"minimum": 1
+}`
+
+// extractPDFPage returns a copy of args with a PDF file_path replaced by the
+// image of its pdf_page, saved under inputs/, along with that path and the
+// page. args is returned as is when file_path is not a PDF.
+func (h *ReplicateImageHandler) extractPDFPage(name string, args map[string]interface{}) (map[string]interface{}, string, int, error) {
+	filePath, _ := args["file_path"].(string)
+	if !pdfTools[name] || filePath == "" || !isPDFFile(filePath) {
+		return args, "", 0, nil
+	}
+	page := 1
+	if p, ok := args["pdf_page"].(float64); ok {
+		if p < 1 || p != float64(int(p)) {
+			return nil, "", 0, fmt.Errorf("pdf_page must be a whole number from 1")
+		}
+		page = int(p)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	image, err := pdf.ExtractPage(data, page)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to extract page %d of %s: %w", page, filePath, err)
+	}
+	path, err := h.storage.SaveInlineInput(image)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to save page %d of %s: %w", page, filePath, err)
+	}
+	slog.Debug("extracted PDF page", "pdf", filePath, "page", page, "path", path)
+
+	resolved := copyArguments(args)
+	delete(resolved, "pdf_page")
+	resolved["file_path"] = path
+	return resolved, path, page, nil
+}
+
+// isPDFFile reports whether the file at path is a PDF, by its content
+func isPDFFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(file, head); err != nil {
+		return false
+	}
+	return pdf.IsPDF(head)
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// Models a preview runs on and a preview is finalized on, unless the call
+// names others
+const (
+	defaultPreviewModel = "flux-schnell"
+	defaultFinalModel   = "flux-pro"
+)
+
+// previewTools are the tools generate_preview can stand in for
+var previewTools = map[string]bool{
+	"generate_image":   true,
+	"generate_branded": true,
+}
+
+// handleGeneratePreview handles the generate_preview tool: it runs a
+// generation on a cheap model with the seed fixed and stores a small
+// watermarked copy of it, along with the call finalize makes once the
+// preview is approved
+func (h *ReplicateImageHandler) handleGeneratePreview(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	tool := "generate_image"
+	if t, ok := args["tool"].(string); ok && t != "" {
+		tool = t
+	}
+	if !previewTools[tool] {
+		return h.errorResponse("generate_preview", "invalid_parameters", "tool must be generate_image or generate_branded", nil)
+	}
+	callArgs, _ := args["arguments"].(map[string]interface{})
+	if prompt, _ := callArgs["prompt"].(string); prompt == "" {
+		return h.errorResponse("generate_preview", "invalid_parameters", "arguments.prompt is required", nil)
+	}
+	previewModel := defaultPreviewModel
+	if m, ok := args["preview_model"].(string); ok && m != "" {
+		previewModel = m
+	}
+
+	// The final call is the one given, on the final model with the seed
+	// fixed, for a single image: the one approved
+	final := make(map[string]interface{}, len(callArgs)+2)
+	for k, v := range callArgs {
+		final[k] = v
+	}
+	delete(final, "dry_run")
+	delete(final, "num_outputs")
+	if m, _ := final["model"].(string); m == "" {
+		final["model"] = defaultFinalModel
+	}
+	seed := rand.Intn(1<<30) + 1
+	if s, ok := final["seed"].(float64); ok && s > 0 {
+		seed = int(s)
+	}
+	final["seed"] = float64(seed)
+
+	// 1. Generate the preview's source on the preview model
+	runArgs := make(map[string]interface{}, len(final)+2)
+	for k, v := range final {
+		runArgs[k] = v
+	}
+	runArgs["model"] = previewModel
+	runArgs["num_outputs"] = float64(1)
+	// The source is decoded for the preview, which WebP outputs cannot be
+	runArgs["output_format"] = "png"
+	delete(runArgs, "output_quality")
+	result := nestedResult(h.callTool(ctx, &protocol.CallToolRequest{Name: tool, Arguments: runArgs}))
+	if !result.Success {
+		return h.errorResponse("generate_preview", "generation_error", result.Error, nil)
+	}
+	output := result.Output
+	sourcePath := ""
+	if paths, ok := output["paths"].(map[string]interface{}); ok {
+		sourcePath, _ = paths["file_path"].(string)
+	}
+	cost := result.Cost
+	if cached, _ := output["cached"].(bool); cached {
+		cost = 0
+	}
+
+	// 2. Scale it down and watermark it
+	preview, err := storage.WatermarkPreview(sourcePath)
+	if err != nil {
+		return h.errorResponse("generate_preview", "processing_error", err.Error(), map[string]interface{}{"source_id": output["id"]})
+	}
+	base := "preview"
+	if filename, ok := final["filename"].(string); ok && filename != "" {
+		base = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + "_preview"
+	}
+	parameters := map[string]interface{}{
+		"tool":          tool,
+		"arguments":     final,
+		"preview_model": previewModel,
+		"source_id":     output["id"],
+	}
+	id, previewPath, err := h.storage.SaveLocalResult(ctx, "generate_preview", parameters, base+".png", preview)
+	if err != nil {
+		return h.errorResponse("generate_preview", "storage_error", err.Error(), map[string]interface{}{"source_id": output["id"]})
+	}
+
+	paths := map[string]string{"file_path": previewPath}
+	if shareURL := h.files.URL(previewPath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
+	response := map[string]interface{}{
+		"id":            id,
+		"paths":         paths,
+		"tool":          tool,
+		"seed":          seed,
+		"preview_model": previewModel,
+		"final_model":   final["model"],
+		"source_id":     output["id"],
+		"cost":          cost,
+	}
+	if notes, ok := output["notes"].([]interface{}); ok && len(notes) > 0 {
+		response["notes"] = notes
+	}
+	message := fmt.Sprintf("Preview saved to %s. Once it is approved, call finalize with id='%s' to render it on %s.", previewPath, id, final["model"])
+	return h.successResponse(responses.BuildSimpleSuccessResponse("generate_preview", message, response))
+}
+
+// handleFinalize handles the finalize tool: it runs the call stored with a
+// preview, on the final model with the preview's seed
+func (h *ReplicateImageHandler) handleFinalize(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("finalize", "invalid_parameters", "id is required", nil)
+	}
+
+	metadata, err := h.storage.LoadMetadata(id)
+	if err != nil {
+		return h.errorResponse("finalize", "not_found", fmt.Sprintf("no stored operation with id %s", id), nil)
+	}
+	if metadata.Operation != "generate_preview" {
+		return h.errorResponse("finalize", "invalid_parameters", fmt.Sprintf("%s is a %s operation; finalize takes the id returned by generate_preview", id, metadata.Operation), nil)
+	}
+	tool, _ := metadata.Parameters["tool"].(string)
+	stored, _ := metadata.Parameters["arguments"].(map[string]interface{})
+	if !previewTools[tool] || stored == nil {
+		return h.errorResponse("finalize", "invalid_parameters", fmt.Sprintf("preview %s does not record the call to finalize", id), nil)
+	}
+
+	toolArgs := toolArguments(stored)
+	overrides, _ := args["overrides"].(map[string]interface{})
+	for k, v := range overrides {
+		toolArgs[k] = v
+	}
+	if key := redactedArgument(toolArgs); key != "" {
+		return h.errorResponse("finalize", "invalid_parameters",
+			fmt.Sprintf("the stored %s was hashed by PROMPT_PRIVACY; pass it in overrides", key), nil)
+	}
+
+	resp, err := h.callTool(ctx, &protocol.CallToolRequest{Name: tool, Arguments: toolArgs})
+
+	// Link the final result to the preview it was approved from
+	fields := map[string]interface{}{"finalized_from": id}
+	if len(overrides) > 0 {
+		fields["overrides"] = overrides
+	}
+	return withResponseFields(resp, err, fields)
+}
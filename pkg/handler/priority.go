@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+)
+
+// batchTools run many predictions in one call and wait behind interactive
+// calls unless given a priority
+var batchTools = map[string]bool{
+	"batch_generate":  true,
+	"caption_folder":  true,
+	"prepare_dataset": true,
+}
+
+// predictionTools are the tools that create predictions, besides those in
+// dryRunTools. They accept priority and max_wait_seconds.
+var predictionTools = map[string]bool{
+	"auto_crop":           true,
+	"batch_generate":      true,
+	"compare_upscalers":   true,
+	"prepare_dataset":     true,
+	"export_social_sizes": true,
+	"generate_icon_set":   true,
+	"generate_wide_image": true,
+	"parameter_sweep":     true,
+	"recolor_image":       true,
+	"run_chain":           true,
+	"upscale_region":      true,
+	"warm_model":          true,
+}
+
+// prioritySchema is the priority property added to the schema of every tool
+// that creates predictions
+const prioritySchema = `{
+	"type": "string",
+	"description": "Queue priority when MAX_CONCURRENT_PREDICTIONS is reached: interactive calls start before waiting batch calls. off_peak saves the call to run within the server's OFF_PEAK_WINDOW, one call at a time, at batch priority; it returns a prediction_id for continue_operation right away. Defaults to batch for caption_folder and prepare_dataset, and to interactive otherwise.",
+	"enum": ["interactive", "batch", "off_peak"]
+}`
+
+// withPriority returns ctx carrying the priority the call's predictions wait
+// with. An explicit priority argument wins, off_peak counting as batch;
+// otherwise a call inside another (regenerate, run_chain nodes) keeps its
+// caller's priority, and batch tools default to batch.
+func withPriority(ctx context.Context, req *protocol.CallToolRequest) (context.Context, error) {
+	if priority, ok := req.Arguments["priority"].(string); ok && priority != "" {
+		// An off-peak call runs once its window opens, as a batch call
+		if priority == priorityOffPeak {
+			return client.WithPriority(ctx, client.PriorityBatch), nil
+		}
+		if !client.ValidPriority(priority) {
+			return ctx, fmt.Errorf("priority must be %s, %s, or %s", client.PriorityInteractive, client.PriorityBatch, priorityOffPeak)
+		}
+		return client.WithPriority(ctx, priority), nil
+	}
+	if client.PriorityFrom(ctx) == "" && batchTools[req.Name] {
+		return client.WithPriority(ctx, client.PriorityBatch), nil
+	}
+	return ctx, nil
+}
+
+// predictionTool reports whether a tool creates predictions
+func predictionTool(name string) bool {
+	return dryRunTools[name] || predictionTools[name]
+}
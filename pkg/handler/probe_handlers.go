@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// Probe settings
+const (
+	maxProbeModels = 10
+	probeTimeout   = 15 * time.Second
+	probeWindow    = 7 * 24 * time.Hour // Ledger history used for latency
+	probeSamples   = 50
+	warmWindow     = 5 * time.Minute // Community models shut down after a few idle minutes
+)
+
+// Model states reported by probe_model
+const (
+	stateAlwaysWarm  = "always_warm"
+	stateWarm        = "warm"
+	stateLikelyCold  = "likely_cold"
+	stateUnavailable = "unavailable"
+)
+
+// handleProbeModel handles the probe_model tool: for each model it checks
+// that the model (and pinned version) exists, whether it is likely cold, and
+// how long recent calls with it took according to the spend ledger
+func (h *ReplicateImageHandler) handleProbeModel(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	var names []string
+	if name, ok := args["model"].(string); ok && name != "" {
+		names = append(names, name)
+	}
+	if raw, ok := args["models"].([]interface{}); ok {
+		for _, item := range raw {
+			if name, ok := item.(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return h.errorResponse("probe_model", "invalid_parameters", "model or models parameter is required", nil)
+	}
+	if len(names) > maxProbeModels {
+		return h.errorResponse("probe_model", "invalid_parameters", fmt.Sprintf("at most %d models can be probed at once", maxProbeModels), nil)
+	}
+	modelIDs := make([]string, len(names))
+	for i, name := range names {
+		modelID, known := models.ResolveAny(name)
+		if !known {
+			if !strings.Contains(name, "/") {
+				return h.errorResponse("probe_model", "invalid_parameters", fmt.Sprintf("unknown model %q", name), nil)
+			}
+			modelID = name // A custom Replicate model
+		}
+		modelIDs[i] = modelID
+	}
+
+	entries, err := h.storage.ReadLedger()
+	if err != nil {
+		return h.errorResponse("probe_model", "storage_error", err.Error(), nil)
+	}
+
+	probes := make([]map[string]interface{}, len(modelIDs))
+	var wg sync.WaitGroup
+	for i, modelID := range modelIDs {
+		wg.Add(1)
+		go func(i int, modelID string) {
+			defer wg.Done()
+			probes[i] = h.probeModel(ctx, modelID, entries)
+		}(i, modelID)
+	}
+	wg.Wait()
+
+	message := fmt.Sprintf("Probed %d model(s)", len(probes))
+	return h.successResponse(responses.BuildSimpleSuccessResponse("probe_model", message, map[string]interface{}{
+		"models": probes,
+	}))
+}
+
+// probeModel describes one model's availability, warmth, and recent latency
+func (h *ReplicateImageHandler) probeModel(ctx context.Context, modelID string, entries []storage.LedgerEntry) map[string]interface{} {
+	probe := map[string]interface{}{
+		"model":      modelID,
+		"model_name": models.GetModelInfo(modelID).Name,
+	}
+	var notes []string
+
+	latency := storage.ModelLatency(entries, modelID, time.Now().Add(-probeWindow), probeSamples)
+	if latency != nil {
+		probe["latency"] = latency
+	}
+
+	if sub, ok := h.router.Substitute(modelID); ok {
+		probe["replaced_by"] = sub.Used
+		notes = append(notes, sub.Warning())
+	}
+
+	provider := h.router.ProviderFor(modelID)
+	if provider == nil {
+		info := models.GetModelInfo(modelID)
+		probe["state"] = stateUnavailable
+		notes = append(notes, fmt.Sprintf("only available through the %s provider, which is not configured", info.Provider))
+		probe["notes"] = notes
+		return probe
+	}
+	probe["provider"] = provider.Name()
+
+	// Check the model on Replicate; other providers serve a fixed model list
+	if provider.Name() == client.ProviderReplicate {
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		model, version, err := h.replicate.GetModel(probeCtx, modelID)
+		cancel()
+		var apiErr *client.APIError
+		switch {
+		case err == nil:
+			probe["exists"] = true
+			if model.LatestVersion != nil {
+				probe["latest_version"] = model.LatestVersion.ID
+				if version != nil && version.ID != model.LatestVersion.ID {
+					notes = append(notes, fmt.Sprintf("a newer version was published on %s", model.LatestVersion.CreatedAt))
+				}
+			}
+			probe["run_count"] = model.RunCount
+		case errors.As(err, &apiErr) && apiErr.Code == client.ErrCodeVersionNotFound:
+			probe["exists"] = false
+			probe["state"] = stateUnavailable
+			notes = append(notes, "the model or its pinned version no longer exists on Replicate")
+			probe["notes"] = notes
+			return probe
+		default:
+			notes = append(notes, "could not check the model on Replicate: "+err.Error())
+		}
+	}
+
+	// Replicate does not report whether a model is booted; infer it from the
+	// last prediction this server made with it
+	lastUsed := h.router.LastUsed(modelID)
+	if latency != nil && latency.LastUsed.After(lastUsed) {
+		lastUsed = latency.LastUsed
+	}
+	switch reason := h.warmer.Skip(modelID); {
+	case reason != "":
+		probe["state"] = stateAlwaysWarm
+		notes = append(notes, reason)
+	case time.Since(lastUsed) < warmWindow:
+		probe["state"] = stateWarm
+	default:
+		probe["state"] = stateLikelyCold
+		notes = append(notes, "the next call may wait 30-90 seconds for the model to boot; warm_model can boot it first")
+	}
+	if !lastUsed.IsZero() {
+		probe["last_used"] = lastUsed
+	}
+	if len(notes) > 0 {
+		probe["notes"] = notes
+	}
+	return probe
+}
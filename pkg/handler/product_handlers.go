@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/generation"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// sceneDirections keep generated scenes free for the product to be placed in
+const sceneDirections = "Product photography backdrop with open space in the foreground for a product, " +
+	"no products, no people, no text, professional commercial lighting."
+
+// handleProductScene handles the product_scene tool: it cuts the product out
+// of its photo, generates (or loads) a scene, and composites the product into
+// the scene with a drop shadow and matched colors
+func (h *ReplicateImageHandler) handleProductScene(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	productPath, ok := args["file_path"].(string)
+	if !ok || productPath == "" {
+		return h.errorResponse("product_scene", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+	prompt, _ := args["prompt"].(string)
+	scenePath, _ := args["scene_path"].(string)
+	if (prompt == "") == (scenePath == "") {
+		return h.errorResponse("product_scene", "invalid_parameters", "exactly one of prompt or scene_path is required", nil)
+	}
+
+	opts := storage.SceneOptions{Placement: storage.PlaceBottom, Scale: 0.6, Shadow: 0.45, ColorMatch: 0.3}
+	if placement, ok := args["placement"].(string); ok && placement != "" {
+		opts.Placement = placement
+	}
+	switch opts.Placement {
+	case storage.PlaceBottom, storage.PlaceCenter, storage.PlaceLeft, storage.PlaceRight:
+	default:
+		return h.errorResponse("product_scene", "invalid_parameters", "placement must be one of: bottom, center, left, right", nil)
+	}
+	if scale, ok := args["scale"].(float64); ok {
+		opts.Scale = scale
+	}
+	if shadow, ok := args["shadow"].(float64); ok {
+		opts.Shadow = shadow
+	}
+	if colorMatch, ok := args["color_match"].(float64); ok {
+		opts.ColorMatch = colorMatch
+	}
+	if opts.Scale <= 0 || opts.Scale > 1 || opts.Shadow < 0 || opts.Shadow > 1 || opts.ColorMatch < 0 || opts.ColorMatch > 1 {
+		return h.errorResponse("product_scene", "invalid_parameters", "scale, shadow, and color_match must be between 0 and 1", nil)
+	}
+
+	var notes []string
+	steps := map[string]interface{}{}
+
+	// 1. Cut the product out, unless it already has a transparent background
+	cutoutPath := productPath
+	removeBackground := true
+	if remove, ok := args["remove_background"].(bool); ok {
+		removeBackground = remove
+	}
+	if removeBackground {
+		cutout, err := h.enhancer.RemoveBackground(ctx, enhancement.RemoveBackgroundParams{
+			ImagePath: productPath,
+			Model:     "remove-bg",
+		})
+		if err != nil {
+			return h.toolErrorResponse("product_scene", "processing_error", err)
+		}
+		cutoutPath = cutout.OutputPath
+		notes = append(notes, h.addContentCredentials(ctx, cutout.ID)...)
+		steps["cutout_id"] = cutout.ID
+		steps["cutout_path"] = cutout.OutputPath
+	}
+
+	// 2. Generate the scene, as PNG so it can be composited here
+	if scenePath == "" {
+		params := generation.GenerateParams{
+			Prompt:       strings.TrimRight(strings.TrimSpace(prompt), ".") + ". " + sceneDirections,
+			Model:        "flux-schnell",
+			OutputFormat: "png",
+			AspectRatio:  "1:1",
+			UseCache:     h.cache,
+		}
+		if model, ok := args["model"].(string); ok && model != "" {
+			params.Model = model
+		}
+		if aspectRatio, ok := args["aspect_ratio"].(string); ok && aspectRatio != "" {
+			params.AspectRatio = aspectRatio
+		}
+		if seed, ok := args["seed"].(float64); ok {
+			params.Seed = int(seed)
+		}
+		scene, err := h.generator.GenerateImage(ctx, params)
+		if err != nil {
+			return h.toolErrorResponse("product_scene", "generation_error", err)
+		}
+		scenePath = scene.FilePath
+		steps["scene_id"] = scene.ID
+		steps["scene_path"] = scene.FilePath
+		notes = append(notes, scene.Notes...)
+		if !scene.Cached {
+			notes = append(notes, h.addContentCredentials(ctx, scene.ID)...)
+		}
+	}
+
+	// 3. Composite locally
+	data, err := storage.ComposeProductScene(cutoutPath, scenePath, opts)
+	if err != nil {
+		return h.errorResponse("product_scene", "processing_error", err.Error(), steps)
+	}
+
+	filename := "product_scene.png"
+	if name, ok := args["filename"].(string); ok && name != "" {
+		filename = strings.TrimSuffix(filepath.Base(name), ".png") + ".png"
+	}
+	parameters := map[string]interface{}{
+		"product_path": productPath,
+		"placement":    opts.Placement,
+		"scale":        opts.Scale,
+		"shadow":       opts.Shadow,
+		"color_match":  opts.ColorMatch,
+	}
+	if prompt != "" {
+		parameters["prompt"] = prompt
+	}
+	for key, value := range steps {
+		parameters[key] = value
+	}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "product_scene", parameters, filename, data)
+	if err != nil {
+		return h.errorResponse("product_scene", "storage_error", err.Error(), steps)
+	}
+	notes = append(notes, h.addContentCredentials(ctx, id)...)
+
+	paths := map[string]string{"file_path": outputPath}
+	if shareURL := h.files.URL(outputPath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
+	result := map[string]interface{}{
+		"id":    id,
+		"paths": paths,
+		"steps": steps,
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Placed the product in the scene: %s", outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("product_scene", message, result))
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// defaultPromptHistoryLimit is how many entries list_prompt_history returns
+// unless asked for more
+const defaultPromptHistoryLimit = 20
+
+// promptResult is the part of a tool response the prompt history records
+type promptResult struct {
+	Success   bool        `json:"success"`
+	Status    string      `json:"status"`
+	ID        string      `json:"id"`
+	StorageID string      `json:"storage_id"` // Set while processing
+	Model     interface{} `json:"model"`
+	Error     struct {
+		Type string `json:"type"`
+	} `json:"error"`
+}
+
+// recordPrompt adds a tool call with a prompt to the prompt history and
+// returns its response with the entry ID, which favorite_prompt takes
+func (h *ReplicateImageHandler) recordPrompt(req *protocol.CallToolRequest, resp *protocol.CallToolResponse, callErr error) *protocol.CallToolResponse {
+	prompt, _ := req.Arguments["prompt"].(string)
+	if dryRun, _ := req.Arguments["dry_run"].(bool); prompt == "" || dryRun {
+		return resp
+	}
+
+	entry := storage.PromptEntry{Tool: req.Name, Prompt: prompt, Outcome: storage.PromptFailed}
+	entry.NegativePrompt, _ = req.Arguments["negative_prompt"].(string)
+	if model, ok := req.Arguments["model"].(string); ok && model != "" {
+		entry.Model = model
+		if modelID, ok := models.ResolveAny(model); ok {
+			entry.Model = modelID
+		}
+	}
+	if callErr == nil && resp != nil && len(resp.Content) > 0 {
+		var result promptResult
+		if err := json.Unmarshal([]byte(resp.Content[0].Text), &result); err == nil {
+			switch {
+			case result.Status == "processing":
+				entry.Outcome = storage.PromptProcessing
+			case result.Success:
+				entry.Outcome = storage.PromptSucceeded
+			}
+			entry.StorageID = result.ID
+			if entry.StorageID == "" {
+				entry.StorageID = result.StorageID
+			}
+			entry.ErrorType = result.Error.Type
+			switch model := result.Model.(type) {
+			case map[string]interface{}:
+				if id, ok := model["id"].(string); ok && id != "" {
+					entry.Model = id
+				}
+			case string:
+				entry.Model = model
+			}
+		}
+	}
+
+	id, err := h.storage.RecordPrompt(entry)
+	if err != nil {
+		slog.Warn("failed to record prompt history", "tool", req.Name, "error", err)
+		return resp
+	}
+	resp, _ = withResponseFields(resp, callErr, map[string]interface{}{"prompt_id": id})
+	return resp
+}
+
+// handleListPromptHistory handles the list_prompt_history tool
+func (h *ReplicateImageHandler) handleListPromptHistory(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filter := storage.PromptFilter{}
+	filter.Contains, _ = args["query"].(string)
+	filter.Tool, _ = args["tool"].(string)
+	filter.Outcome, _ = args["outcome"].(string)
+	filter.FavoritesOnly, _ = args["favorites_only"].(bool)
+	if model, ok := args["model"].(string); ok && model != "" {
+		filter.Model = model
+		if modelID, ok := models.ResolveAny(model); ok {
+			filter.Model = modelID
+		}
+	}
+	if rating, ok := args["min_rating"].(float64); ok {
+		if rating < 1 || rating > 5 {
+			return h.errorResponse("list_prompt_history", "invalid_parameters", "min_rating must be between 1 and 5", nil)
+		}
+		filter.MinRating = int(rating)
+	}
+	if since, ok := args["since"].(string); ok && since != "" {
+		from, err := time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return h.errorResponse("list_prompt_history", "invalid_parameters", "since must be a date in YYYY-MM-DD format", nil)
+		}
+		filter.From = from
+	}
+	if until, ok := args["until"].(string); ok && until != "" {
+		day, err := time.ParseInLocation("2006-01-02", until, time.Local)
+		if err != nil {
+			return h.errorResponse("list_prompt_history", "invalid_parameters", "until must be a date in YYYY-MM-DD format", nil)
+		}
+		filter.To = day.AddDate(0, 0, 1) // Include the whole day
+	}
+	limit := defaultPromptHistoryLimit
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	entries, err := h.storage.PromptHistory(filter)
+	if err != nil {
+		return h.errorResponse("list_prompt_history", "storage_error", err.Error(), nil)
+	}
+	total := len(entries)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	message := fmt.Sprintf("Found %d prompts", total)
+	if total > len(entries) {
+		message = fmt.Sprintf("Found %d prompts, showing the newest %d", total, len(entries))
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("list_prompt_history", message, map[string]interface{}{
+		"prompts": entries,
+		"total":   total,
+	}))
+}
+
+// handleFavoritePrompt handles the favorite_prompt tool
+func (h *ReplicateImageHandler) handleFavoritePrompt(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	ref, ok := args["id"].(string)
+	if !ok || ref == "" {
+		return h.errorResponse("favorite_prompt", "invalid_parameters", "id is required", nil)
+	}
+	favorite := true
+	if f, ok := args["favorite"].(bool); ok {
+		favorite = f
+	}
+	rating, hasRating := args["rating"].(float64)
+	if hasRating && (rating < 0 || rating > 5) {
+		return h.errorResponse("favorite_prompt", "invalid_parameters", "rating must be between 1 and 5, or 0 to clear it", nil)
+	}
+	name, hasName := args["name"].(string)
+
+	entry, err := h.storage.MarkPrompt(ref, func(mark *storage.PromptMark) {
+		mark.Favorite = favorite
+		if hasRating {
+			mark.Rating = int(rating)
+		}
+		if hasName {
+			mark.Name = name
+		}
+	})
+	if err != nil {
+		return h.errorResponse("favorite_prompt", "not_found", err.Error(), nil)
+	}
+
+	message := fmt.Sprintf("Saved prompt %s as a favorite", entry.ID)
+	if !favorite {
+		message = fmt.Sprintf("Updated prompt %s", entry.ID)
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("favorite_prompt", message, map[string]interface{}{
+		"prompt": entry,
+	}))
+}
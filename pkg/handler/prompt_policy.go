@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/policy"
+)
+
+// promptArguments are the arguments holding prompt text sent to models. Full
+// prompts must contain the policy's required terms; fragments added to a
+// built prompt are only checked for banned ones.
+var promptArguments = []struct {
+	name string
+	full bool
+}{
+	{"prompt", true},
+	{"prompt_b", true},
+	{"instructions", false},
+	{"selection_prompt", false},
+}
+
+// checkPromptPolicy checks the prompts of a call to a tool that creates
+// predictions against the content policy, returning the first violation or
+// nil when they comply or no policy is configured
+func (h *ReplicateImageHandler) checkPromptPolicy(req *protocol.CallToolRequest) *policy.Violation {
+	if h.policy == nil || !predictionTool(req.Name) {
+		return nil
+	}
+	for _, arg := range promptArguments {
+		prompt, ok := req.Arguments[arg.name].(string)
+		if !ok || prompt == "" {
+			continue
+		}
+		if violation := h.policy.Check(arg.name, prompt, arg.full); violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
+
+// policyViolation is the response to a call whose prompt breaks the content
+// policy
+func (h *ReplicateImageHandler) policyViolation(req *protocol.CallToolRequest, violation *policy.Violation) (*protocol.CallToolResponse, error) {
+	details := map[string]interface{}{"argument": violation.Argument}
+	if len(violation.Banned) > 0 {
+		details["banned_terms"] = violation.Banned
+	}
+	if len(violation.Missing) > 0 {
+		details["missing_terms"] = violation.Missing
+	}
+	return h.errorResponse(req.Name, "policy_violation", violation.Error(), details)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/provenance"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// addContentCredentials signs every output of an operation with a C2PA
+// manifest when signing is configured, returning a note for the response.
+// A signing failure keeps the unsigned outputs and is reported in the note.
+func (h *ReplicateImageHandler) addContentCredentials(ctx context.Context, id string) []string {
+	if !h.signer.Enabled() {
+		return nil
+	}
+
+	metadata, err := h.storage.LoadMetadata(id)
+	if err != nil {
+		slog.Warn("failed to load metadata for C2PA signing", "storage_id", id, "error", err)
+		return []string{"content credentials were not added: " + err.Error()}
+	}
+
+	// Credentials travel with the image, so private prompts stay out of them
+	prompt, _ := metadata.Parameters["prompt"].(string)
+	if h.private {
+		prompt = ""
+	}
+	sourceType := digitalSourceType(metadata.Operation)
+	claim := provenance.Claim{
+		Operation:         metadata.Operation,
+		Model:             metadata.Model,
+		Prompt:            prompt,
+		DigitalSourceType: sourceType,
+		Edited:            sourceType != storage.SourceTrainedAlgorithmic,
+		Created:           metadata.Timestamp,
+	}
+
+	_, err = h.storage.RewriteOutputs(id, func(src, dst string) error {
+		return h.signer.Sign(ctx, src, dst, claim)
+	})
+	if err != nil {
+		slog.Warn("failed to add C2PA content credentials", "storage_id", id, "error", err)
+		return []string{"content credentials were not added: " + err.Error()}
+	}
+	return []string{"added C2PA content credentials"}
+}
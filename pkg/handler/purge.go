@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+)
+
+// deleteRemoteSchema is the delete_remote property added to the schema of
+// every tool that creates predictions
+const deleteRemoteSchema = `{
+	"type": "boolean",
+	"description": "Delete the call's predictions, with their prompts, inputs, and outputs, from Replicate once the results are saved locally. Defaults to DELETE_REMOTE_PREDICTIONS."
+}`
+
+// trackPredictions returns ctx recording the predictions the call creates
+// when they are to be deleted afterwards, and a nil log otherwise
+func (h *ReplicateImageHandler) trackPredictions(ctx context.Context, req *protocol.CallToolRequest) (context.Context, *client.PredictionLog) {
+	deleteRemote := h.purge
+	if d, ok := req.Arguments["delete_remote"].(bool); ok {
+		deleteRemote = d
+	}
+	if !deleteRemote || !predictionTool(req.Name) {
+		return ctx, nil
+	}
+	return client.WithPredictionLog(ctx)
+}
+
+// deleteRemotePredictions deletes the predictions a successful call created
+// from their provider and lists them in the response as remote_deleted.
+// Predictions of failed calls and of calls still processing are kept, so
+// their outputs can still be fetched; providers that keep nothing to delete
+// are skipped.
+func (h *ReplicateImageHandler) deleteRemotePredictions(ctx context.Context, created *client.PredictionLog, resp *protocol.CallToolResponse, callErr error) *protocol.CallToolResponse {
+	if created == nil || callErr != nil || resp == nil || len(resp.Content) == 0 {
+		return resp
+	}
+	var result toolResult
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &result); err != nil || !result.Success || result.Status == "processing" {
+		return resp
+	}
+
+	deleted := []string{}
+	failed := map[string]string{}
+	for _, id := range created.IDs() {
+		err := h.router.DeletePrediction(ctx, id)
+		switch {
+		case err == nil:
+			deleted = append(deleted, id)
+		case errors.Is(err, client.ErrDeleteUnsupported):
+		default:
+			slog.Warn("failed to delete remote prediction", "prediction_id", id, "error", err)
+			failed[id] = err.Error()
+		}
+	}
+
+	fields := map[string]interface{}{}
+	if len(deleted) > 0 {
+		fields["remote_deleted"] = deleted
+	}
+	if len(failed) > 0 {
+		fields["remote_delete_errors"] = failed
+	}
+	if len(fields) == 0 {
+		return resp
+	}
+	resp, _ = withResponseFields(resp, callErr, fields)
+	return resp
+}
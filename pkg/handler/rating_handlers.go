@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// handleRateImage handles the rate_image tool
+func (h *ReplicateImageHandler) handleRateImage(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("rate_image", "invalid_parameters", "id is required", nil)
+	}
+	rating, ok := args["rating"].(float64)
+	if !ok || rating < 0 || rating > 5 || rating != float64(int(rating)) {
+		return h.errorResponse("rate_image", "invalid_parameters", "rating must be a whole number of stars from 1 to 5, or 0 to clear it", nil)
+	}
+
+	metadata, err := h.storage.UpdateMetadata(id, func(metadata *types.ImageMetadata) {
+		metadata.Rating = int(rating)
+	})
+	if err != nil {
+		return h.errorResponse("rate_image", "not_found", fmt.Sprintf("no stored operation %s: %v", id, err), nil)
+	}
+
+	message := fmt.Sprintf("Rated %s %d stars", id, metadata.Rating)
+	if metadata.Rating == 0 {
+		message = fmt.Sprintf("Cleared the rating of %s", id)
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("rate_image", message, map[string]interface{}{
+		"id":     id,
+		"rating": metadata.Rating,
+	}))
+}
+
+// handleAnnotateImage handles the annotate_image tool
+func (h *ReplicateImageHandler) handleAnnotateImage(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("annotate_image", "invalid_parameters", "id is required", nil)
+	}
+	note, ok := args["note"].(string)
+	if !ok {
+		return h.errorResponse("annotate_image", "invalid_parameters", "note is required; pass an empty note to clear it", nil)
+	}
+	note = strings.TrimSpace(note)
+	appendNote, _ := args["append"].(bool)
+
+	metadata, err := h.storage.UpdateMetadata(id, func(metadata *types.ImageMetadata) {
+		if appendNote && metadata.Note != "" && note != "" {
+			metadata.Note += "\n" + note
+		} else if !appendNote || note != "" {
+			metadata.Note = note
+		}
+	})
+	if err != nil {
+		return h.errorResponse("annotate_image", "not_found", fmt.Sprintf("no stored operation %s: %v", id, err), nil)
+	}
+
+	message := fmt.Sprintf("Saved the note of %s", id)
+	if metadata.Note == "" {
+		message = fmt.Sprintf("Cleared the note of %s", id)
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("annotate_image", message, map[string]interface{}{
+		"id":   id,
+		"note": metadata.Note,
+	}))
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/brand"
+	"github.com/gomcpgo/replicate_image_ai/pkg/editing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// Ways recolor_image remaps colors
+const (
+	recolorLocal = "local" // Shift colors toward the palette locally
+	recolorEdit  = "edit"  // Ask an edit model to repaint in the palette
+)
+
+// recolorDirections is the edit instruction of the edit method; the palette
+// is filled in
+const recolorDirections = "Recolor this image using only this color palette: %s. Keep the composition, subjects, shapes, line work, and lighting exactly the same; change only the colors."
+
+// handleRecolorImage handles the recolor_image tool: it remaps an image's
+// colors toward a palette, locally or with an edit model, and reports how
+// much of the result is on the palette
+func (h *ReplicateImageHandler) handleRecolorImage(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("recolor_image", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+
+	// The palette defaults to the brand kit's
+	var palette []string
+	if raw, ok := args["palette"].([]interface{}); ok {
+		for _, item := range raw {
+			if hex, ok := item.(string); ok && hex != "" {
+				palette = append(palette, hex)
+			}
+		}
+	}
+	if len(palette) == 0 && h.brand != nil {
+		palette = h.brand.Palette
+	}
+	if len(palette) == 0 {
+		return h.errorResponse("recolor_image", "invalid_parameters", "palette is required when no brand kit with a palette is configured", nil)
+	}
+	kit, err := brand.NewPaletteKit(palette)
+	if err != nil {
+		return h.errorResponse("recolor_image", "invalid_parameters", err.Error(), nil)
+	}
+	hexes := make([]string, len(kit.Colors()))
+	for i, c := range kit.Colors() {
+		hexes[i] = brand.Hex(c)
+	}
+
+	method := recolorLocal
+	if m, ok := args["method"].(string); ok && m != "" {
+		method = m
+	}
+	if method != recolorLocal && method != recolorEdit {
+		return h.errorResponse("recolor_image", "invalid_parameters", "method must be local or edit", nil)
+	}
+	filename, _ := args["filename"].(string)
+
+	recolor := map[string]interface{}{"method": method, "palette": hexes}
+	if before, err := kit.CheckPalette(filePath); err == nil {
+		recolor["palette_before"] = before
+	}
+
+	if method == recolorEdit {
+		prompt := fmt.Sprintf(recolorDirections, strings.Join(hexes, ", "))
+		if extra, ok := args["prompt"].(string); ok && strings.TrimSpace(extra) != "" {
+			prompt += " " + strings.TrimSpace(extra)
+		}
+		params := editing.EditParams{
+			ImagePath: filePath,
+			Prompt:    prompt,
+			Model:     "pro",
+			Filename:  filename,
+		}
+		if model, ok := args["model"].(string); ok && model != "" {
+			params.Model = model
+		}
+		if seed, ok := args["seed"].(float64); ok {
+			params.Seed = int(seed)
+		}
+		result, err := h.editor.EditImage(ctx, params)
+		if err != nil {
+			return h.toolErrorResponse("recolor_image", "editing_error", err)
+		}
+		result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+		if after, err := kit.CheckPalette(result.OutputPath); err == nil {
+			recolor["palette_after"] = after
+		}
+		resp, err := h.successResponse(h.buildEditResponse(result))
+		return withResponseFields(resp, err, map[string]interface{}{"recolor": recolor})
+	}
+
+	strength := 1.0
+	if value, ok := args["strength"].(float64); ok {
+		if value <= 0 || value > 1 {
+			return h.errorResponse("recolor_image", "invalid_parameters", "strength must be between 0 (exclusive) and 1", nil)
+		}
+		strength = value
+	}
+	exact, _ := args["exact"].(bool)
+
+	data, mappings, err := storage.RecolorToPalette(filePath, kit.Colors(), strength, exact)
+	if err != nil {
+		return h.errorResponse("recolor_image", "processing_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	if filename == "" {
+		filename = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + "_recolored"
+	}
+	filename = strings.TrimSuffix(filepath.Base(filename), ".png") + ".png"
+	parameters := map[string]interface{}{
+		"input_path": filePath,
+		"palette":    hexes,
+		"method":     method,
+		"strength":   strength,
+		"exact":      exact,
+	}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "recolor_image", parameters, filename, data)
+	if err != nil {
+		return h.errorResponse("recolor_image", "storage_error", err.Error(), nil)
+	}
+
+	recolor["mappings"] = mappings
+	if after, err := kit.CheckPalette(outputPath); err == nil {
+		recolor["palette_after"] = after
+	}
+	result := map[string]interface{}{
+		"id":        id,
+		"file_path": outputPath,
+		"recolor":   recolor,
+	}
+	if url := h.files.URL(outputPath); url != "" {
+		result["share_url"] = url
+	}
+	if notes := h.addContentCredentials(ctx, id); len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Recolored to the %d-color palette: %s", len(hexes), outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("recolor_image", message, result))
+}
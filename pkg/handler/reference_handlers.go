@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// promptTagPattern finds @tag mentions in a prompt
+var promptTagPattern = regexp.MustCompile(`@([A-Za-z][A-Za-z0-9]*)`)
+
+// handleRegisterReferenceSet handles the register_reference_set tool
+func (h *ReplicateImageHandler) handleRegisterReferenceSet(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	name, _ := args["name"].(string)
+	if err := storage.ValidateReferenceName(name); err != nil {
+		return h.errorResponse("register_reference_set", "invalid_parameters", err.Error(), nil)
+	}
+
+	var images []string
+	if imagesRaw, ok := args["images"].([]interface{}); ok {
+		for _, img := range imagesRaw {
+			if imgStr, ok := img.(string); ok {
+				images = append(images, imgStr)
+			}
+		}
+	}
+	if len(images) == 0 || len(images) > storage.MaxReferenceImages {
+		return h.errorResponse("register_reference_set", "invalid_parameters",
+			fmt.Sprintf("images parameter is required (1-%d images)", storage.MaxReferenceImages), nil)
+	}
+
+	set := &storage.ReferenceSet{Name: name, Kind: storage.ReferenceCharacter}
+	if kind, ok := args["kind"].(string); ok && kind != "" {
+		set.Kind = kind
+	}
+	if set.Kind != storage.ReferenceCharacter && set.Kind != storage.ReferenceStyle {
+		return h.errorResponse("register_reference_set", "invalid_parameters", "kind must be one of: character, style", nil)
+	}
+	if description, ok := args["description"].(string); ok {
+		set.Description = description
+	}
+	replace, _ := args["replace"].(bool)
+
+	if err := h.storage.SaveReferenceSet(set, images, replace); err != nil {
+		return h.errorResponse("register_reference_set", "storage_error", err.Error(), nil)
+	}
+
+	message := fmt.Sprintf("Registered %s %s; use @%s in generate_with_visual_context prompts", set.Kind, name, name)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("register_reference_set", message, map[string]interface{}{
+		"reference_set": h.referenceSetInfo(set),
+	}))
+}
+
+// handleListReferenceSets handles the list_reference_sets tool
+func (h *ReplicateImageHandler) handleListReferenceSets(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	sets, err := h.storage.ListReferenceSets()
+	if err != nil {
+		return h.errorResponse("list_reference_sets", "storage_error", err.Error(), nil)
+	}
+
+	infos := make([]map[string]interface{}, len(sets))
+	for i, set := range sets {
+		infos[i] = h.referenceSetInfo(set)
+	}
+	message := fmt.Sprintf("%d reference sets", len(sets))
+	return h.successResponse(responses.BuildSimpleSuccessResponse("list_reference_sets", message, map[string]interface{}{
+		"reference_sets": infos,
+	}))
+}
+
+// handleDeleteReferenceSet handles the delete_reference_set tool
+func (h *ReplicateImageHandler) handleDeleteReferenceSet(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	name, ok := args["name"].(string)
+	if !ok || name == "" {
+		return h.errorResponse("delete_reference_set", "invalid_parameters", "name is required", nil)
+	}
+	if err := h.storage.DeleteReferenceSet(name); err != nil {
+		return h.errorResponse("delete_reference_set", "not_found", err.Error(), nil)
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("delete_reference_set", fmt.Sprintf("Deleted reference set %s", name), nil))
+}
+
+// referenceSetInfo describes a reference set in a response
+func (h *ReplicateImageHandler) referenceSetInfo(set *storage.ReferenceSet) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        set.Name,
+		"kind":        set.Kind,
+		"description": set.Description,
+		"images":      h.storage.ReferenceImagePaths(set),
+		"tags":        set.Tags(),
+		"created":     set.Created,
+	}
+}
+
+// expandReferenceSets appends the images and tags of the reference sets a
+// generate_with_visual_context call names, through the character or
+// reference_sets arguments or as @name in the prompt. Mentions of tags the
+// call already supplies, or of names that are not sets, are left alone.
+func (h *ReplicateImageHandler) expandReferenceSets(prompt string, args map[string]interface{}, images, tags []string) ([]string, []string, error) {
+	var names []string
+	if character, ok := args["character"].(string); ok && character != "" {
+		names = append(names, character)
+	}
+	if setsRaw, ok := args["reference_sets"].([]interface{}); ok {
+		for _, set := range setsRaw {
+			if name, ok := set.(string); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	explicit := len(names)
+	for _, match := range promptTagPattern.FindAllStringSubmatch(prompt, -1) {
+		names = append(names, match[1])
+	}
+
+	supplied := map[string]bool{}
+	for _, tag := range tags {
+		supplied[tag] = true
+	}
+
+	for i, name := range names {
+		if supplied[name] {
+			continue
+		}
+		set, err := h.storage.LoadReferenceSet(name)
+		if err != nil {
+			if i < explicit {
+				return nil, nil, err
+			}
+			continue // An ordinary @tag, or a typo the model will ignore
+		}
+
+		images = append(images, h.storage.ReferenceImagePaths(set)...)
+		for _, tag := range set.Tags() {
+			tags = append(tags, tag)
+			supplied[tag] = true
+		}
+	}
+
+	if len(images) > storage.MaxReferenceImages {
+		return nil, nil, fmt.Errorf("at most %d reference images can be used at once; these references need %d", storage.MaxReferenceImages, len(images))
+	}
+	return images, tags, nil
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// regenerableOperations are the stored operations regenerate can rerun
+var regenerableOperations = map[string]bool{
+	"generate_image":               true,
+	"generate_with_visual_context": true,
+	"edit_image":                   true,
+	"remove_background":            true,
+	"upscale_image":                true,
+	"enhance_face":                 true,
+	"restore_photo":                true,
+	"vectorize_image":              true,
+}
+
+// handleRegenerate handles the regenerate tool: it rebuilds the arguments of a
+// stored operation from its metadata, applies overrides, and runs it again
+func (h *ReplicateImageHandler) handleRegenerate(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("regenerate", "invalid_parameters", "id is required", nil)
+	}
+
+	metadata, err := h.storage.LoadMetadata(id)
+	if err != nil {
+		return h.errorResponse("regenerate", "not_found", fmt.Sprintf("no stored operation with id %s", id), nil)
+	}
+	if !regenerableOperations[metadata.Operation] {
+		return h.errorResponse("regenerate", "invalid_parameters", fmt.Sprintf("operation %s cannot be regenerated", metadata.Operation), nil)
+	}
+
+	toolArgs := toolArguments(metadata.Parameters)
+	overrides, _ := args["overrides"].(map[string]interface{})
+	for k, v := range overrides {
+		toolArgs[k] = v
+	}
+
+	// Hashed prompts cannot be sent again; the caller has to supply them
+	if key := redactedArgument(toolArgs); key != "" {
+		return h.errorResponse("regenerate", "invalid_parameters",
+			fmt.Sprintf("the stored %s was hashed by PROMPT_PRIVACY; pass it in overrides", key), nil)
+	}
+
+	// A rerun asks for a new result, not the stored one
+	if _, ok := toolArgs["use_cache"]; !ok {
+		toolArgs["use_cache"] = false
+	}
+
+	resp, err := h.callTool(ctx, &protocol.CallToolRequest{Name: metadata.Operation, Arguments: toolArgs})
+
+	// Link the new result to the one it reruns
+	fields := map[string]interface{}{"regenerated_from": id}
+	if len(overrides) > 0 {
+		fields["overrides"] = overrides
+	}
+	return withResponseFields(resp, err, fields)
+}
+
+// toolArguments converts stored metadata parameters back into tool call
+// arguments. Metadata records input files as input_path and YAML decodes
+// numbers as integers, while tools take file_path and JSON numbers; unset
+// options (empty strings, zero numbers) are dropped so tool defaults apply.
+func toolArguments(parameters map[string]interface{}) map[string]interface{} {
+	args := make(map[string]interface{}, len(parameters))
+	for k, v := range parameters {
+		// Moderation and translation records describe how the request was
+		// handled; the stored prompt is already the one that was sent
+		if k == "moderation" || k == "translation" {
+			continue
+		}
+		// A mask selected from selection_prompt is selected again
+		if k == "selection_mask" {
+			continue
+		}
+		// A preset's negative prompt is taken from the library again
+		if _, preset := parameters["negative_preset"]; preset && k == "negative_prompt" {
+			continue
+		}
+		if k == "input_path" {
+			k = "file_path"
+		}
+		switch value := v.(type) {
+		case string:
+			if value == "" {
+				continue
+			}
+		case int:
+			if value == 0 {
+				continue
+			}
+			v = float64(value)
+		case float64:
+			if value == 0 {
+				continue
+			}
+		}
+		args[k] = v
+	}
+	return args
+}
+
+// redactedArgument returns the first prompt argument that PROMPT_PRIVACY
+// stored hashed, which cannot be sent again, or "" when there is none
+func redactedArgument(args map[string]interface{}) string {
+	for _, key := range []string{"prompt", "negative_prompt", "selection_prompt"} {
+		if value, _ := args[key].(string); storage.RedactedPrompt(value) {
+			return key
+		}
+	}
+	return ""
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// Ways upscale_region returns the upscaled region
+const (
+	pasteNone     = "none"     // Only the upscaled crop
+	pasteOriginal = "original" // Pasted into the image at its own size
+	pasteEnlarged = "enlarged" // Pasted into the image enlarged locally to the same scale
+)
+
+// regionMarginShare is the context kept around the region, as a share of its
+// shorter side; the pasted crop fades in across it
+const regionMarginShare = 0.1
+
+// handleUpscaleRegion handles the upscale_region tool: it upscales one region
+// of an image instead of the whole image, optionally pasting the result back
+func (h *ReplicateImageHandler) handleUpscaleRegion(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("upscale_region", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+	var region image.Rectangle
+	if box, ok := args["region"].(map[string]interface{}); ok {
+		x, _ := box["x"].(float64)
+		y, _ := box["y"].(float64)
+		width, _ := box["width"].(float64)
+		height, _ := box["height"].(float64)
+		if width <= 0 || height <= 0 {
+			return h.errorResponse("upscale_region", "invalid_parameters", "region width and height must be positive", nil)
+		}
+		region = image.Rect(int(x), int(y), int(x+width), int(y+height))
+	}
+	subject, _ := args["subject"].(string)
+	if region.Empty() == (subject == "") {
+		return h.errorResponse("upscale_region", "invalid_parameters", "pass either region or subject", nil)
+	}
+
+	scale := 2
+	if value, ok := args["scale"].(float64); ok {
+		scale = int(value)
+	}
+	if scale != 2 && scale != 4 {
+		return h.errorResponse("upscale_region", "invalid_parameters", "scale must be 2 or 4", nil)
+	}
+	model := "realesrgan"
+	if m, ok := args["model"].(string); ok && m != "" {
+		model = m
+	}
+	if !models.HasAlias(models.OpUpscale, model) {
+		return h.errorResponse("upscale_region", "invalid_parameters", "unknown upscaler: "+model, nil)
+	}
+	faceEnhance, _ := args["face_enhance"].(bool)
+	paste := pasteNone
+	if p, ok := args["paste_back"].(string); ok && p != "" {
+		paste = p
+	}
+	if paste != pasteNone && paste != pasteOriginal && paste != pasteEnlarged {
+		return h.errorResponse("upscale_region", "invalid_parameters", "paste_back must be none, original, or enlarged", nil)
+	}
+
+	width, height, err := storage.ImageSize(filePath)
+	if err != nil {
+		return h.errorResponse("upscale_region", "file_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	if paste == pasteEnlarged && width*height*scale*scale > storage.MaxPasteBackPixels {
+		return h.errorResponse("upscale_region", "invalid_parameters",
+			fmt.Sprintf("a %dx%d image enlarged %dx is over %d megapixels; use paste_back original or none", width, height, scale, storage.MaxPasteBackPixels/1_000_000), nil)
+	}
+
+	totalCost := 0.0
+	steps := make(map[string]interface{})
+
+	// 1. Find the region when a subject is named
+	if subject != "" {
+		detected, err := h.enhancer.Detect(ctx, enhancement.DetectParams{ImagePath: filePath, Query: subject})
+		if err != nil {
+			return h.toolErrorResponse("upscale_region", "processing_error", err)
+		}
+		totalCost += detected.Metrics.Cost
+		if len(detected.Detections) == 0 {
+			return h.errorResponse("upscale_region", "not_found", fmt.Sprintf("no %s was found in the image", subject),
+				map[string]interface{}{"total_cost": totalCost})
+		}
+		region = detected.Detections[0].Box
+		steps["detection"] = map[string]interface{}{
+			"label":      detected.Detections[0].Label,
+			"confidence": detected.Detections[0].Confidence,
+		}
+	}
+	region = region.Intersect(image.Rect(0, 0, width, height))
+	if region.Empty() {
+		return h.errorResponse("upscale_region", "invalid_parameters", fmt.Sprintf("region lies outside the %dx%d image", width, height), nil)
+	}
+
+	// 2. Cut the region out with some context and store it
+	margin := max(8, int(float64(min(region.Dx(), region.Dy()))*regionMarginShare))
+	crop := storage.PadRegion(region, margin, width, height)
+	data, crop, err := storage.CropImage(filePath, crop)
+	if err != nil {
+		return h.errorResponse("upscale_region", "file_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	cropParams := map[string]interface{}{
+		"input_path": filePath,
+		"crop":       cropInfo(crop),
+	}
+	cropID, cropPath, err := h.storage.SaveLocalResult(ctx, "crop_image", cropParams, base+"_region.png", data)
+	if err != nil {
+		return h.errorResponse("upscale_region", "storage_error", err.Error(), nil)
+	}
+	steps["crop_id"] = cropID
+	steps["crop_path"] = cropPath
+
+	// 3. Upscale the crop
+	upscaled, err := h.enhancer.UpscaleImage(ctx, enhancement.UpscaleParams{
+		ImagePath:   cropPath,
+		Scale:       scale,
+		Model:       model,
+		FaceEnhance: faceEnhance,
+		Filename:    fmt.Sprintf("%s_region_upscaled_%dx.png", base, scale),
+	})
+	if err != nil {
+		resp, respErr := h.toolErrorResponse("upscale_region", "processing_error", err)
+		return withResponseFields(resp, respErr, steps)
+	}
+	totalCost += upscaled.Metrics.Cost
+	notes := append(upscaled.Notes, h.addContentCredentials(ctx, upscaled.ID)...)
+
+	result := map[string]interface{}{
+		"region":       cropInfo(region),
+		"crop":         cropInfo(crop),
+		"upscale_id":   upscaled.ID,
+		"upscale_path": upscaled.OutputPath,
+		"model":        model,
+		"scale":        scale,
+		"total_cost":   totalCost,
+		"predict_time": upscaled.Metrics.PredictTime,
+	}
+	for key, value := range steps {
+		result[key] = value
+	}
+	message := fmt.Sprintf("Upscaled a %dx%d region %dx: %s", crop.Dx(), crop.Dy(), scale, upscaled.OutputPath)
+
+	// 4. Paste it back
+	if paste != pasteNone {
+		pasteScale := 1
+		if paste == pasteEnlarged {
+			pasteScale = scale
+		}
+		data, err := storage.PasteRegion(filePath, upscaled.OutputPath, crop, region, pasteScale)
+		if err != nil {
+			return h.errorResponse("upscale_region", "processing_error", err.Error(), result)
+		}
+		filename := base + "_region_enhanced"
+		if name, ok := args["filename"].(string); ok && name != "" {
+			filename = name
+		}
+		filename = strings.TrimSuffix(filepath.Base(filename), ".png") + ".png"
+		parameters := map[string]interface{}{
+			"input_path":   filePath,
+			"region":       cropInfo(region),
+			"crop":         cropInfo(crop),
+			"model":        model,
+			"scale":        scale,
+			"paste_back":   paste,
+			"upscale_id":   upscaled.ID,
+			"face_enhance": faceEnhance,
+		}
+		if subject != "" {
+			parameters["subject"] = subject
+		}
+		id, outputPath, err := h.storage.SaveLocalResult(ctx, "upscale_region", parameters, filename, data)
+		if err != nil {
+			return h.errorResponse("upscale_region", "storage_error", err.Error(), result)
+		}
+		notes = append(notes, h.addContentCredentials(ctx, id)...)
+		result["id"] = id
+		result["file_path"] = outputPath
+		if url := h.files.URL(outputPath); url != "" {
+			result["share_url"] = url
+		}
+		message = fmt.Sprintf("Upscaled a %dx%d region %dx and pasted it back: %s", crop.Dx(), crop.Dy(), scale, outputPath)
+	} else {
+		result["id"] = upscaled.ID
+		result["file_path"] = upscaled.OutputPath
+		if url := h.files.URL(upscaled.OutputPath); url != "" {
+			result["share_url"] = url
+		}
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("upscale_region", message, result))
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// trackPending saves a background operation to disk, and saves it again as
+// it creates predictions and once it finishes, so continue_operation can
+// still reach it after a restart
+func (h *ReplicateImageHandler) trackPending(id string, op *pendingOp) {
+	// The inline image is saved under a hash of its content, so a resumed
+	// call reads the same file instead of keeping the image in the record
+	if args, _, err := h.saveInlineImage(op.args); err == nil {
+		op.args = args
+	}
+	h.persistPending(id, op)
+	op.predictions.OnCreate(func() { h.persistPending(id, op) })
+	go func() {
+		<-op.done
+		h.persistPending(id, op)
+	}()
+}
+
+// persistPending writes the current state of a background operation, unless
+// its result was collected. A call cancelled by a shutdown is left as it was
+// last saved, so the next start resumes it rather than returning the error.
+func (h *ReplicateImageHandler) persistPending(id string, op *pendingOp) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if op.collected {
+		return
+	}
+
+	record := storage.PendingOperation{
+		ID:        id,
+		Tool:      op.tool,
+		Arguments: op.args,
+		StartedAt: op.started,
+		OffPeak:   op.offPeak,
+	}
+	seen := map[string]bool{}
+	for _, prediction := range op.predictions.Predictions() {
+		seen[prediction.ID] = true
+		record.Predictions = append(record.Predictions, storage.PendingPrediction{ID: prediction.ID, Model: prediction.Model, InputKey: prediction.InputKey})
+	}
+	for _, prediction := range op.resumed {
+		if !seen[prediction.ID] {
+			record.Predictions = append(record.Predictions, storage.PendingPrediction{ID: prediction.ID, Model: prediction.Model, InputKey: prediction.InputKey})
+		}
+	}
+
+	select {
+	case <-op.done:
+		result := pendingResult(op.resp)
+		if h.drain.wasAborted() && (op.err != nil || result["success"] != true) {
+			return
+		}
+		finished := op.finished
+		record.FinishedAt = &finished
+		record.Result = result
+		if op.err != nil {
+			record.Error = op.err.Error()
+		}
+	default:
+	}
+	if err := h.storage.SavePending(record); err != nil {
+		slog.Warn("failed to save background operation", "prediction_id", id, "tool", op.tool, "error", err)
+	}
+}
+
+// collectPending forgets a background operation whose result was returned
+func (h *ReplicateImageHandler) collectPending(id string, op *pendingOp) {
+	op.mu.Lock()
+	op.collected = true
+	op.mu.Unlock()
+	h.pending.remove(id)
+	if err := h.storage.RemovePending(id); err != nil {
+		slog.Warn("failed to remove background operation", "prediction_id", id, "error", err)
+	}
+}
+
+// pendingResult decodes a JSON tool response for saving, or returns nil
+func pendingResult(resp *protocol.CallToolResponse) map[string]interface{} {
+	if resp == nil || len(resp.Content) == 0 {
+		return nil
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Content[0].Text), &result); err != nil {
+		return nil
+	}
+	return result
+}
+
+// RestorePending registers the background operations saved before the
+// server last stopped. Finished operations are returned by
+// continue_operation as before; unfinished ones are run again in the
+// background, continuing the predictions they had created instead of
+// starting new ones, and off-peak ones are queued for the window again.
+// Results older than pendingExpiry are dropped.
+func (h *ReplicateImageHandler) RestorePending() {
+	records, err := h.storage.PendingOperations()
+	if err != nil {
+		slog.Warn("failed to read background operations", "error", err)
+		return
+	}
+	resumed := 0
+	for _, record := range records {
+		if record.FinishedAt != nil {
+			if time.Since(*record.FinishedAt) > pendingExpiry {
+				if err := h.storage.RemovePending(record.ID); err != nil {
+					slog.Warn("failed to remove background operation", "prediction_id", record.ID, "error", err)
+				}
+				continue
+			}
+			h.restoreFinished(record)
+			continue
+		}
+		if h.resumePending(record) {
+			resumed++
+		}
+	}
+	if resumed > 0 {
+		slog.Info("resumed background operations from the previous run", "count", resumed)
+	}
+}
+
+// restoreFinished registers a saved operation that finished before the
+// restart, so continue_operation returns its result
+func (h *ReplicateImageHandler) restoreFinished(record storage.PendingOperation) {
+	var resp *protocol.CallToolResponse
+	var err error
+	switch {
+	case record.Error != "":
+		err = fmt.Errorf("%s", record.Error)
+	case record.Result != nil:
+		content, marshalErr := json.MarshalIndent(record.Result, "", "  ")
+		if marshalErr != nil {
+			resp, err = h.errorResponse(record.Tool, "processing_error", marshalErr.Error(), nil)
+			break
+		}
+		resp, err = h.successResponse(string(content))
+	default:
+		resp, err = h.errorResponse(record.Tool, "processing_error", "the operation finished without a result", nil)
+	}
+	op := h.restoredOp(record)
+	op.resp, op.err = withStructuredContent(resp), err
+	op.finished = *record.FinishedAt
+	close(op.done)
+	h.pending.put(record.ID, op)
+}
+
+// resumePending runs a saved operation that had not finished again in the
+// background under its original ID, reporting whether it was started. Calls
+// whose prompts were stored hashed cannot be sent again and are reported as
+// failed to continue_operation instead.
+func (h *ReplicateImageHandler) resumePending(record storage.PendingOperation) bool {
+	if key := redactedArgument(record.Arguments); key != "" {
+		op := h.restoredOp(record)
+		op.resp, op.err = h.errorResponse(record.Tool, "interrupted",
+			fmt.Sprintf("the server restarted before the operation finished, and it cannot be resumed because PROMPT_PRIVACY stored its %s hashed; outputs of its predictions (%d) may still be fetched from the provider for an hour", key, len(record.Predictions)),
+			map[string]interface{}{"prediction_ids": pendingPredictionIDs(record)})
+		op.resp = withStructuredContent(op.resp)
+		op.finished = time.Now()
+		close(op.done)
+		h.pending.put(record.ID, op)
+		h.persistPending(record.ID, op) // Dropped with other results an hour from now
+		return false
+	}
+
+	op := h.restoredOp(record)
+	if op.offPeak && h.offPeak != nil {
+		h.pending.put(record.ID, op)
+		h.offPeak.add(h, record.ID, op)
+		slog.Info("queued background operation for the off-peak window again", "prediction_id", record.ID, "tool", record.Tool)
+		return true
+	}
+	h.pending.put(record.ID, op)
+	if !h.startPending(record.ID, op) {
+		h.pending.remove(record.ID)
+		return false
+	}
+	slog.Info("resuming background operation", "prediction_id", record.ID, "tool", record.Tool, "predictions", len(record.Predictions))
+	return true
+}
+
+// startPending runs a saved or queued operation in the background under its
+// ID, continuing the predictions of its run before a restart, if any. It
+// reports false once a shutdown has started.
+func (h *ReplicateImageHandler) startPending(id string, op *pendingOp) bool {
+	ctx := context.Background()
+	if len(op.resumed) > 0 {
+		ctx = client.WithResumedPredictions(ctx, op.resumed)
+	}
+	run, predictions, outputs, ok := h.startCall(ctx, &protocol.CallToolRequest{Name: op.tool, Arguments: op.args})
+	if !ok {
+		return false
+	}
+	op.mu.Lock()
+	op.predictions, op.outputs = predictions, outputs
+	op.mu.Unlock()
+	op.start(run)
+	h.trackPending(id, op)
+	return true
+}
+
+// restoredOp returns a background operation for a saved record, with empty
+// logs and the record's predictions as the ones to resume
+func (h *ReplicateImageHandler) restoredOp(record storage.PendingOperation) *pendingOp {
+	op := &pendingOp{
+		tool:        record.Tool,
+		args:        record.Arguments,
+		started:     record.StartedAt,
+		offPeak:     record.OffPeak,
+		predictions: &client.PredictionLog{},
+		done:        make(chan struct{}),
+	}
+	_, op.outputs = storage.WithOutputLog(context.Background())
+	for _, prediction := range record.Predictions {
+		op.resumed = append(op.resumed, client.LoggedPrediction{ID: prediction.ID, Model: prediction.Model, InputKey: prediction.InputKey})
+	}
+	return op
+}
+
+// pendingPredictionIDs returns the IDs of a saved operation's predictions
+func pendingPredictionIDs(record storage.PendingOperation) []string {
+	ids := make([]string, len(record.Predictions))
+	for i, prediction := range record.Predictions {
+		ids[i] = prediction.ID
+	}
+	return ids
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+)
+
+// reviveStep is one enhancement in the revive_photo pipeline
+type reviveStep struct {
+	name string
+	run  func(inputPath, filename string) (*enhancement.EnhancementResult, error)
+}
+
+// handleRevivePhoto handles the revive_photo tool: it removes scratches,
+// restores faces, colorizes, and upscales an old photo in that order. Damage
+// is repaired first so the later models don't treat it as detail, and
+// upscaling comes last so every other step works on the smaller image.
+func (h *ReplicateImageHandler) handleRevivePhoto(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("revive_photo", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+
+	enabled := func(name string) bool {
+		if value, ok := args[name].(bool); ok {
+			return value
+		}
+		return true
+	}
+
+	faceModel := "codeformer"
+	if model, ok := args["face_model"].(string); ok && model != "" {
+		faceModel = model
+	}
+	fidelity := 0.7 // Old faces are easily over-idealized; keep the person's features
+	if value, ok := args["fidelity"].(float64); ok {
+		fidelity = value
+	}
+	scale := 2
+	if value, ok := args["scale"].(float64); ok {
+		scale = int(value)
+	}
+	if scale != 2 && scale != 4 {
+		return h.errorResponse("revive_photo", "invalid_parameters", "scale must be 2 or 4", nil)
+	}
+
+	var steps []reviveStep
+	if enabled("scratch_removal") {
+		steps = append(steps, reviveStep{"scratch_removal", func(inputPath, filename string) (*enhancement.EnhancementResult, error) {
+			return h.enhancer.RestorePhoto(ctx, enhancement.RestorePhotoParams{
+				ImagePath:      inputPath,
+				Model:          "bopbtl",
+				ScratchRemoval: true,
+				Filename:       filename,
+			})
+		}})
+	}
+	if enabled("face_restore") {
+		steps = append(steps, reviveStep{"face_restore", func(inputPath, filename string) (*enhancement.EnhancementResult, error) {
+			return h.enhancer.EnhanceFace(ctx, enhancement.EnhanceFaceParams{
+				ImagePath: inputPath,
+				Model:     faceModel,
+				Fidelity:  fidelity,
+				Filename:  filename,
+			})
+		}})
+	}
+	if enabled("colorize") {
+		steps = append(steps, reviveStep{"colorize", func(inputPath, filename string) (*enhancement.EnhancementResult, error) {
+			return h.enhancer.Colorize(ctx, enhancement.ColorizeParams{
+				ImagePath: inputPath,
+				Model:     "ddcolor",
+				Filename:  filename,
+			})
+		}})
+	}
+	if enabled("upscale") {
+		steps = append(steps, reviveStep{"upscale", func(inputPath, filename string) (*enhancement.EnhancementResult, error) {
+			return h.enhancer.UpscaleImage(ctx, enhancement.UpscaleParams{
+				ImagePath: inputPath,
+				Model:     "realesrgan",
+				Scale:     scale,
+				Filename:  filename,
+			})
+		}})
+	}
+	if len(steps) == 0 {
+		return h.errorResponse("revive_photo", "invalid_parameters", "at least one step must be enabled", nil)
+	}
+
+	// Only the final output takes the custom filename
+	filename, _ := args["filename"].(string)
+
+	inputPath := filePath
+	var infos []map[string]interface{}
+	var notes []string
+	var cost float64
+	for i, step := range steps {
+		stepFilename := ""
+		if i == len(steps)-1 {
+			stepFilename = filename
+		}
+		result, err := step.run(inputPath, stepFilename)
+		if err != nil {
+			// Earlier steps stay stored; report them so they aren't lost
+			return h.errorResponse("revive_photo", "processing_error",
+				fmt.Sprintf("%s step failed: %v", step.name, err),
+				map[string]interface{}{"failed_step": step.name, "completed_steps": infos})
+		}
+		notes = append(notes, result.Notes...)
+		notes = append(notes, h.addContentCredentials(ctx, result.ID)...)
+		cost += result.Metrics.Cost
+
+		info := map[string]interface{}{
+			"step":      step.name,
+			"id":        result.ID,
+			"model":     result.ModelName,
+			"file_path": result.OutputPath,
+		}
+		if shareURL := h.files.URL(result.OutputPath); shareURL != "" {
+			info["share_url"] = shareURL
+		}
+		infos = append(infos, info)
+		inputPath = result.OutputPath
+	}
+
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.name
+	}
+	paths := map[string]string{
+		"input_path": filePath,
+		"file_path":  inputPath,
+	}
+	if shareURL := h.files.URL(inputPath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
+	result := map[string]interface{}{
+		"paths":         paths,
+		"steps":         infos,
+		"cost_estimate": cost,
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Revived the photo (%s): %s", strings.Join(names, ", "), inputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("revive_photo", message, result))
+}
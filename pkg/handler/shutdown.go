@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// abortGrace is how long a shutdown waits for cancelled calls to return
+const abortGrace = 5 * time.Second
+
+// drainState tracks the tool calls in flight so a shutdown can wait for
+// them. The zero value is ready to use.
+type drainState struct {
+	mu       sync.Mutex
+	draining bool // Set once a shutdown starts; no calls begin after it
+	aborted  bool // Set once a shutdown cancels the calls in flight
+	next     int
+	calls    map[int]*activeCall
+	active   sync.WaitGroup
+	refused  int // Chain nodes not started because of the shutdown
+}
+
+// activeCall is a tool call in flight
+type activeCall struct {
+	tool    string
+	args    map[string]interface{}
+	started time.Time
+	created *client.PredictionLog
+	cancel  context.CancelFunc
+}
+
+// begin registers a tool call, returning its context and the function to
+// call when it returns. It reports false once a shutdown has started.
+func (d *drainState) begin(ctx context.Context, tool string, args map[string]interface{}) (context.Context, func(), bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return ctx, nil, false
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, created := client.WithPredictionLog(ctx)
+	if d.calls == nil {
+		d.calls = make(map[int]*activeCall)
+	}
+	d.next++
+	id := d.next
+	d.calls[id] = &activeCall{tool: tool, args: args, started: time.Now(), created: created, cancel: cancel}
+	d.active.Add(1)
+
+	return ctx, func() {
+		d.mu.Lock()
+		delete(d.calls, id)
+		d.mu.Unlock()
+		cancel()
+		d.active.Done()
+	}, true
+}
+
+// stop refuses every call that has not begun yet
+func (d *drainState) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.draining = true
+}
+
+// stopping reports whether a shutdown has started
+func (d *drainState) stopping() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// wasAborted reports whether a shutdown cancelled the calls in flight
+func (d *drainState) wasAborted() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.aborted
+}
+
+// refuse counts a chain node that was not started because of the shutdown
+func (d *drainState) refuse() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refused++
+}
+
+// wait blocks until every call in flight has returned or ctx is done,
+// reporting whether they all returned
+func (d *drainState) wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		d.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// abort cancels the calls still in flight and returns them
+func (d *drainState) abort() []*activeCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.aborted = true
+	calls := make([]*activeCall, 0, len(d.calls))
+	for _, call := range d.calls {
+		call.cancel()
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// shuttingDown is the response to a tool call made after a shutdown started
+func (h *ReplicateImageHandler) shuttingDown(req *protocol.CallToolRequest) (*protocol.CallToolResponse, error) {
+	return h.errorResponse(req.Name, "shutting_down", "the server is shutting down and accepts no new tool calls", nil)
+}
+
+// Shutdown stops accepting tool calls and waits until the ones in flight,
+// chain nodes included, have finished their predictions and downloads. Calls
+// still running when ctx is done are cancelled and recorded, with the
+// predictions they created, in the interrupted operations log, as are chain
+// nodes that were never started. Notifications being sent are delivered
+// before it returns. It reports how many operations were interrupted.
+func (h *ReplicateImageHandler) Shutdown(ctx context.Context) (int, error) {
+	h.drain.stop()
+	h.halt()
+	h.offPeak.halt()
+
+	interrupted := 0
+	if !h.drain.wait(ctx) {
+		for _, call := range h.drain.abort() {
+			h.recordInterrupted(call.tool, call.args, call.started, call.created.IDs(), "cancelled by shutdown")
+			interrupted++
+		}
+		graceCtx, cancel := context.WithTimeout(context.Background(), abortGrace)
+		h.drain.wait(graceCtx)
+		cancel()
+	}
+
+	// Chains refuse their remaining nodes once their running ones return
+	h.chains.wait(abortGrace)
+	h.drain.mu.Lock()
+	interrupted += h.drain.refused
+	h.drain.mu.Unlock()
+
+	h.notifier.Flush()
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), abortGrace)
+	defer cancel()
+	if err := h.files.Close(closeCtx); err != nil {
+		return interrupted, fmt.Errorf("failed to stop file server: %w", err)
+	}
+	return interrupted, nil
+}
+
+// recordInterrupted logs an operation a shutdown cut short
+func (h *ReplicateImageHandler) recordInterrupted(tool string, args map[string]interface{}, started time.Time, predictionIDs []string, reason string) {
+	op := storage.InterruptedOperation{
+		Tool:          tool,
+		Arguments:     args,
+		PredictionIDs: predictionIDs,
+		Reason:        reason,
+	}
+	if !started.IsZero() {
+		op.StartedAt = &started
+	}
+	if err := h.storage.RecordInterrupted(op); err != nil {
+		slog.Error("failed to record interrupted operation", "tool", tool, "prediction_ids", predictionIDs, "error", err)
+		return
+	}
+	slog.Warn("operation interrupted by shutdown", "tool", tool, "prediction_ids", predictionIDs, "reason", reason)
+}
+
+// handleListInterrupted handles the list_interrupted tool
+func (h *ReplicateImageHandler) handleListInterrupted(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	ops, err := h.storage.InterruptedOperations()
+	if err != nil {
+		return h.errorResponse("list_interrupted", "processing_error", err.Error(), nil)
+	}
+
+	message := fmt.Sprintf("%d operations were interrupted by a shutdown", len(ops))
+	if clearLog, _ := args["clear"].(bool); clearLog {
+		if err := h.storage.ClearInterrupted(); err != nil {
+			return h.errorResponse("list_interrupted", "processing_error", err.Error(), nil)
+		}
+		message += "; the log was cleared"
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("list_interrupted", message, map[string]interface{}{
+		"count":      len(ops),
+		"operations": ops,
+	}))
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/generation"
+)
+
+// sizePresetTools are the generation tools that accept size_preset
+var sizePresetTools = map[string]bool{
+	"generate_image":               true,
+	"generate_branded":             true,
+	"generate_with_visual_context": true,
+	"create_ab_test":               true,
+}
+
+// sizePresetSchema is the size_preset property added to the schema of every
+// tool in sizePresetTools
+var sizePresetSchema = func() string {
+	names, _ := json.Marshal(generation.SizePresetNames())
+	return fmt.Sprintf(`{
+	"type": "string",
+	"description": "Named shape used instead of width, height, and aspect_ratio, resolved for the chosen model: square (1:1), portrait (2:3), landscape (3:2), banner (21:9), story (9:16), or thumbnail (16:9). Models that take an aspect ratio get the closest one they support",
+	"enum": %s
+}`, names)
+}()
@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/editing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// Ways a social variant is produced
+const (
+	socialAuto     = "auto"     // Crop when little is lost, outpaint otherwise
+	socialCrop     = "crop"     // Always crop
+	socialOutpaint = "outpaint" // Always outpaint, unless the ratio already matches
+)
+
+// minCropCoverage is the smallest share of the image auto mode will crop down
+// to before outpainting instead
+const minCropCoverage = 0.6
+
+// defaultOutpaintPrompt describes the border to paint when no prompt is given
+const defaultOutpaintPrompt = "Extend the scene naturally beyond the edges of the frame, matching the lighting, perspective, colors, and style"
+
+// socialVariant tracks one target format through export
+type socialVariant struct {
+	format   storage.SocialFormat
+	method   string // crop or outpaint
+	image    *storage.SocialImage
+	outpaint *outpaintJob
+	file     string
+	note     string
+	err      error
+}
+
+// outpaintJob is one outpaint, shared by every target with the same aspect
+// ratio
+type outpaintJob struct {
+	format storage.SocialFormat
+	result *editing.EditResult
+	err    error
+}
+
+// handleExportSocialSizes handles the export_social_sizes tool: it produces
+// one variant of an image per social platform size, cropping or outpainting
+// each to the platform's aspect ratio
+func (h *ReplicateImageHandler) handleExportSocialSizes(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("export_social_sizes", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+	targets := storage.DefaultSocialFormats
+	if raw, ok := args["targets"].([]interface{}); ok && len(raw) > 0 {
+		targets = nil
+		for _, item := range raw {
+			if name, ok := item.(string); ok && name != "" {
+				targets = append(targets, name)
+			}
+		}
+	}
+	var formats []storage.SocialFormat
+	seen := make(map[string]bool)
+	for _, name := range targets {
+		format, ok := storage.LookupSocialFormat(name)
+		if !ok {
+			return h.errorResponse("export_social_sizes", "invalid_parameters", fmt.Sprintf("unknown target %q", name),
+				map[string]interface{}{"targets": socialFormatNames()})
+		}
+		if !seen[name] {
+			seen[name] = true
+			formats = append(formats, format)
+		}
+	}
+	mode := socialAuto
+	if m, ok := args["mode"].(string); ok && m != "" {
+		mode = m
+	}
+	if mode != socialAuto && mode != socialCrop && mode != socialOutpaint {
+		return h.errorResponse("export_social_sizes", "invalid_parameters", "mode must be auto, crop, or outpaint", nil)
+	}
+	subject, _ := args["subject"].(string)
+	prompt := defaultOutpaintPrompt
+	if p, ok := args["prompt"].(string); ok && p != "" {
+		prompt = p
+	}
+	model := "fill"
+	if m, ok := args["model"].(string); ok && m != "" {
+		model = m
+	}
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	if name, ok := args["filename"].(string); ok && name != "" {
+		base = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+
+	width, height, err := storage.ImageSize(filePath)
+	if err != nil {
+		return h.errorResponse("export_social_sizes", "file_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+
+	// Center crops on the subject when one is named
+	totalCost := 0.0
+	var focus image.Rectangle
+	var notes []string
+	if subject != "" {
+		detected, err := h.enhancer.Detect(ctx, enhancement.DetectParams{ImagePath: filePath, Query: subject})
+		if err != nil {
+			return h.toolErrorResponse("export_social_sizes", "processing_error", err)
+		}
+		totalCost += detected.Metrics.Cost
+		if len(detected.Detections) > 0 {
+			focus = detected.Detections[0].Box
+		} else {
+			notes = append(notes, fmt.Sprintf("no %s was found; crops follow the image's detail instead", subject))
+		}
+	}
+
+	// Decide how to produce each variant; targets sharing an aspect ratio
+	// share one outpaint
+	variants := make([]socialVariant, len(formats))
+	outpaints := make(map[int]*outpaintJob)
+	for i, format := range formats {
+		variants[i].format = format
+		variants[i].method = socialCrop
+		coverage := storage.CropCoverage(width, height, format.Aspect())
+		if coverage > 0.99 || mode == socialCrop || (mode == socialAuto && coverage >= minCropCoverage) {
+			continue
+		}
+		variants[i].method = socialOutpaint
+		key := int(math.Round(format.Aspect() * 1000))
+		if outpaints[key] == nil {
+			outpaints[key] = &outpaintJob{format: format}
+		}
+		variants[i].outpaint = outpaints[key]
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range outpaints {
+		wg.Add(1)
+		go func(job *outpaintJob) {
+			defer wg.Done()
+			job.result, job.err = h.outpaint(ctx, filePath, job.format, prompt, model)
+		}(job)
+	}
+	wg.Wait()
+
+	// Render every variant into one operation
+	id, err := h.storage.OperationID(ctx)
+	if err != nil {
+		return h.errorResponse("export_social_sizes", "storage_error", err.Error(), nil)
+	}
+	defer h.storage.CleanupIfEmpty(id)
+	dir := h.storage.OperationDir(id)
+	count := 0
+	for i := range variants {
+		variant := &variants[i]
+		source, crop := filePath, focus
+		if job := variant.outpaint; job != nil {
+			switch {
+			case job.err == nil:
+				source, crop = job.result.OutputPath, image.Rectangle{}
+			case mode == socialAuto:
+				variant.method = socialCrop
+				variant.note = "outpainting failed, so the image was cropped instead: " + job.err.Error()
+			default:
+				variant.err = job.err
+				continue
+			}
+		}
+		variant.image, variant.err = storage.RenderSocialFormat(source, variant.format, crop)
+		if variant.err != nil {
+			continue
+		}
+		variant.file = fmt.Sprintf("%s_%s.png", base, variant.format.Name)
+		if err := os.WriteFile(filepath.Join(dir, variant.file), variant.image.PNG, 0644); err != nil {
+			return h.errorResponse("export_social_sizes", "storage_error", err.Error(), nil)
+		}
+		variant.image.PNG = nil
+		count++
+	}
+	for _, job := range outpaints {
+		if job.result != nil {
+			totalCost += job.result.Metrics.Cost
+		}
+	}
+	if count == 0 {
+		return h.errorResponse("export_social_sizes", "processing_error", "no variant could be produced",
+			map[string]interface{}{"details": socialVariantInfos(variants, dir, nil)})
+	}
+
+	parameters := map[string]interface{}{
+		"file_path": filePath,
+		"targets":   targets,
+		"mode":      mode,
+	}
+	if subject != "" {
+		parameters["subject"] = subject
+	}
+	if len(outpaints) > 0 {
+		parameters["prompt"] = prompt
+		parameters["model"] = model
+	}
+	if _, err := h.storage.FinalizeFiles(id, "export_social_sizes", parameters, ""); err != nil {
+		return h.errorResponse("export_social_sizes", "storage_error", err.Error(), nil)
+	}
+	notes = append(notes, h.addContentCredentials(ctx, id)...)
+
+	result := map[string]interface{}{
+		"id":         id,
+		"paths":      map[string]string{"directory": dir},
+		"variants":   socialVariantInfos(variants, dir, h.files.URL),
+		"total_cost": totalCost,
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Exported %d of %d social sizes to %s", count, len(variants), dir)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("export_social_sizes", message, result))
+}
+
+// outpaint extends the image at path to the aspect ratio of format with a
+// mask-based edit model. The edit is stored as its own edit_image operation.
+func (h *ReplicateImageHandler) outpaint(ctx context.Context, path string, format storage.SocialFormat, prompt, model string) (*editing.EditResult, error) {
+	canvas, mask, err := storage.OutpaintCanvas(path, format)
+	if err != nil {
+		return nil, err
+	}
+	canvasPath, err := writeTempPNG("outpaint-canvas-*.png", canvas)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(canvasPath)
+	maskPath, err := writeTempPNG("outpaint-mask-*.png", mask)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(maskPath)
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return h.editor.EditImage(ctx, editing.EditParams{
+		ImagePath: canvasPath,
+		MaskPath:  maskPath,
+		Prompt:    prompt,
+		Model:     model,
+		Filename:  fmt.Sprintf("%s_outpaint_%dx%d.png", base, format.Width, format.Height),
+	})
+}
+
+// writeTempPNG writes data to a new temporary file and returns its path
+func writeTempPNG(pattern string, data []byte) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// socialFormatNames lists the names of the supported targets
+func socialFormatNames() []string {
+	names := make([]string, len(storage.SocialFormats))
+	for i, format := range storage.SocialFormats {
+		names[i] = format.Name
+	}
+	return names
+}
+
+// socialVariantInfos describes each variant for a response. shareURL may be
+// nil when no share links are wanted.
+func socialVariantInfos(variants []socialVariant, dir string, shareURL func(string) string) []map[string]interface{} {
+	infos := make([]map[string]interface{}, 0, len(variants))
+	for _, variant := range variants {
+		info := map[string]interface{}{
+			"target": variant.format.Name,
+			"label":  variant.format.Label,
+			"width":  variant.format.Width,
+			"height": variant.format.Height,
+			"method": variant.method,
+		}
+		if variant.err != nil {
+			info["error"] = variant.err.Error()
+			infos = append(infos, info)
+			continue
+		}
+		path := filepath.Join(dir, variant.file)
+		info["file_path"] = path
+		if shareURL != nil {
+			if url := shareURL(path); url != "" {
+				info["share_url"] = url
+			}
+		}
+		if job := variant.outpaint; job != nil && job.err == nil {
+			info["outpaint_id"] = job.result.ID
+		} else {
+			info["crop"] = cropInfo(variant.image.Crop)
+		}
+		if variant.image.Enlarged {
+			info["enlarged"] = true
+		}
+		if variant.note != "" {
+			info["note"] = variant.note
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleRepairStorage handles the repair_storage tool
+func (h *ReplicateImageHandler) handleRepairStorage(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	dryRun := false
+	if d, ok := args["dry_run"].(bool); ok {
+		dryRun = d
+	}
+
+	// Operations running in the background may still write to their
+	// directories
+	inUse := make(map[string]bool)
+	for _, path := range h.pending.activePaths() {
+		if id := h.storage.StorageID(path); id != "" {
+			inUse[id] = true
+		}
+	}
+
+	report, err := h.storage.RepairStorage(dryRun, inUse)
+	if err != nil {
+		return h.errorResponse("repair_storage", "storage_error", err.Error(), nil)
+	}
+
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+	message := fmt.Sprintf("%s %d orphaned directories, %d unused inputs, and %d temp files (scanned %d)",
+		verb, len(report.RemovedIDs), len(report.RemovedInputs), len(report.RemovedTempFiles), report.Scanned)
+
+	response := responses.BuildSimpleSuccessResponse("repair_storage", message, map[string]interface{}{
+		"removed_ids":        report.RemovedIDs,
+		"removed_temp_files": report.RemovedTempFiles,
+		"missing_metadata":   report.MissingMetadata,
+		"skipped_ids":        report.SkippedIDs,
+		"removed_inputs":     report.RemovedInputs,
+		"scanned":            report.Scanned,
+		"dry_run":            report.DryRun,
+	})
+	return h.successResponse(response)
+}
+
+// handleUsageSummary handles the usage_summary tool
+func (h *ReplicateImageHandler) handleUsageSummary(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	period := "week"
+	if p, ok := args["period"].(string); ok && p != "" {
+		period = p
+	}
+
+	from, to, err := usagePeriod(period, time.Now())
+	if err != nil {
+		return h.errorResponse("usage_summary", "invalid_parameters", err.Error(), nil)
+	}
+
+	// Explicit dates override the period
+	if since, ok := args["since"].(string); ok && since != "" {
+		from, err = time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return h.errorResponse("usage_summary", "invalid_parameters", "since must be a date in YYYY-MM-DD format", nil)
+		}
+	}
+	if until, ok := args["until"].(string); ok && until != "" {
+		day, err := time.ParseInLocation("2006-01-02", until, time.Local)
+		if err != nil {
+			return h.errorResponse("usage_summary", "invalid_parameters", "until must be a date in YYYY-MM-DD format", nil)
+		}
+		to = day.AddDate(0, 0, 1) // Include the whole day
+	}
+
+	summary, err := h.storage.UsageSummary(from, to)
+	if err != nil {
+		return h.errorResponse("usage_summary", "storage_error", err.Error(), nil)
+	}
+
+	message := fmt.Sprintf("%d operations producing %d outputs, costing $%.4f", summary.Total.Operations, summary.Total.Outputs, summary.Total.Cost)
+	response := responses.BuildSimpleSuccessResponse("usage_summary", message, map[string]interface{}{
+		"period":   period,
+		"from":     summary.From.Format(time.RFC3339),
+		"to":       summary.To.Format(time.RFC3339),
+		"total":    summary.Total,
+		"by_model": summary.ByModel,
+		"by_day":   summary.ByDay,
+		"days":     summary.Days,
+	})
+	return h.successResponse(response)
+}
+
+// handleExportMetadata handles the export_metadata tool
+func (h *ReplicateImageHandler) handleExportMetadata(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	format := "csv"
+	if f, ok := args["format"].(string); ok && f != "" {
+		format = f
+	}
+	if format != "csv" && format != "json" {
+		return h.errorResponse("export_metadata", "invalid_parameters", "format must be one of: csv, json", nil)
+	}
+
+	filter := storage.MetadataFilter{}
+	if operation, ok := args["operation"].(string); ok {
+		filter.Operation = operation
+	}
+	if model, ok := args["model"].(string); ok && model != "" {
+		filter.Model = model
+		if modelID, ok := models.ResolveAny(model); ok {
+			filter.Model = modelID
+		}
+	}
+	if contains, ok := args["prompt_contains"].(string); ok {
+		filter.PromptContains = contains
+	}
+	if rating, ok := args["min_rating"].(float64); ok {
+		if rating < 1 || rating > 5 {
+			return h.errorResponse("export_metadata", "invalid_parameters", "min_rating must be between 1 and 5", nil)
+		}
+		filter.MinRating = int(rating)
+	}
+	if since, ok := args["since"].(string); ok && since != "" {
+		from, err := time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return h.errorResponse("export_metadata", "invalid_parameters", "since must be a date in YYYY-MM-DD format", nil)
+		}
+		filter.From = from
+	}
+	if until, ok := args["until"].(string); ok && until != "" {
+		day, err := time.ParseInLocation("2006-01-02", until, time.Local)
+		if err != nil {
+			return h.errorResponse("export_metadata", "invalid_parameters", "until must be a date in YYYY-MM-DD format", nil)
+		}
+		filter.To = day.AddDate(0, 0, 1) // Include the whole day
+	}
+
+	records, err := h.storage.QueryMetadata(filter)
+	if err != nil {
+		return h.errorResponse("export_metadata", "storage_error", err.Error(), nil)
+	}
+
+	outputPath := h.storage.DefaultExportPath(format, time.Now())
+	if p, ok := args["output_path"].(string); ok && p != "" {
+		outputPath = p
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return h.errorResponse("export_metadata", "storage_error", fmt.Sprintf("failed to create export directory: %v", err), nil)
+	}
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return h.errorResponse("export_metadata", "storage_error", fmt.Sprintf("failed to create export file: %v", err), nil)
+	}
+	if format == "json" {
+		err = storage.WriteMetadataJSON(file, records)
+	} else {
+		err = storage.WriteMetadataCSV(file, records)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return h.errorResponse("export_metadata", "storage_error", fmt.Sprintf("failed to write export: %v", err), nil)
+	}
+
+	message := fmt.Sprintf("Exported metadata for %d operations to %s", len(records), outputPath)
+	response := responses.BuildSimpleSuccessResponse("export_metadata", message, map[string]interface{}{
+		"file_path":  outputPath,
+		"format":     format,
+		"operations": len(records),
+	})
+	return h.successResponse(response)
+}
+
+// usagePeriod converts a named period into a time range ending now
+func usagePeriod(period string, now time.Time) (time.Time, time.Time, error) {
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := now.Add(time.Second)
+
+	switch period {
+	case "today":
+		return startOfToday, to, nil
+	case "week":
+		return startOfToday.AddDate(0, 0, -6), to, nil
+	case "month":
+		return startOfToday.AddDate(0, 0, -29), to, nil
+	case "all":
+		return time.Time{}, to, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("period must be one of: today, week, month, all")
+	}
+}
+
+// defaultListImagesLimit is how many images list_images returns unless asked
+// for more
+const defaultListImagesLimit = 50
+
+// handleListImages handles the list_images tool
+func (h *ReplicateImageHandler) handleListImages(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filter := storage.MetadataFilter{}
+	if operation, ok := args["operation"].(string); ok {
+		filter.Operation = operation
+	}
+	if model, ok := args["model"].(string); ok && model != "" {
+		filter.Model = model
+		if modelID, ok := models.ResolveAny(model); ok {
+			filter.Model = modelID
+		}
+	}
+	if contains, ok := args["prompt_contains"].(string); ok {
+		filter.PromptContains = contains
+	}
+	if rating, ok := args["min_rating"].(float64); ok {
+		if rating < 1 || rating > 5 {
+			return h.errorResponse("list_images", "invalid_parameters", "min_rating must be between 1 and 5", nil)
+		}
+		filter.MinRating = int(rating)
+	}
+	if since, ok := args["since"].(string); ok && since != "" {
+		from, err := time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return h.errorResponse("list_images", "invalid_parameters", "since must be a date in YYYY-MM-DD format", nil)
+		}
+		filter.From = from
+	}
+	if until, ok := args["until"].(string); ok && until != "" {
+		day, err := time.ParseInLocation("2006-01-02", until, time.Local)
+		if err != nil {
+			return h.errorResponse("list_images", "invalid_parameters", "until must be a date in YYYY-MM-DD format", nil)
+		}
+		filter.To = day.AddDate(0, 0, 1) // Include the whole day
+	}
+	limit := defaultListImagesLimit
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	images, err := h.storage.ListImages(filter)
+	if err != nil {
+		return h.errorResponse("list_images", "storage_error", err.Error(), nil)
+	}
+	total := len(images)
+	if len(images) > limit {
+		images = images[:limit]
+	}
+
+	message := fmt.Sprintf("Found %d images", total)
+	if total > len(images) {
+		message = fmt.Sprintf("Found %d images, showing the newest %d", total, len(images))
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("list_images", message, map[string]interface{}{
+		"images": images,
+		"total":  total,
+	}))
+}
+
+// handleGetImage handles the get_image tool
+func (h *ReplicateImageHandler) handleGetImage(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("get_image", "invalid_parameters", "id is required", nil)
+	}
+	metadata, paths, err := h.storage.GetImage(id)
+	if err != nil {
+		return h.errorResponse("get_image", "not_found", fmt.Sprintf("no stored operation %s: %v", id, err), nil)
+	}
+
+	result := map[string]interface{}{
+		"id":         id,
+		"file_paths": paths,
+		"metadata":   storage.MetadataRecord(metadata),
+	}
+	if len(paths) > 0 {
+		result["file_path"] = paths[0]
+		if url := h.files.URL(paths[0]); url != "" {
+			result["share_url"] = url
+		}
+	}
+	message := fmt.Sprintf("%s: %s with %s, %d file(s)", id, metadata.Operation, metadata.Model, len(paths))
+	return h.successResponse(responses.BuildSimpleSuccessResponse("get_image", message, result))
+}
+
+// handleDeleteImage handles the delete_image tool: it removes a stored
+// operation and all its files
+func (h *ReplicateImageHandler) handleDeleteImage(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	id, ok := args["id"].(string)
+	if !ok || id == "" {
+		return h.errorResponse("delete_image", "invalid_parameters", "id is required", nil)
+	}
+	metadata, err := h.storage.DeleteImage(id)
+	if err != nil {
+		return h.errorResponse("delete_image", "not_found", fmt.Sprintf("no stored operation %s: %v", id, err), nil)
+	}
+
+	message := fmt.Sprintf("Deleted %s (%s) and its files", id, metadata.Operation)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("delete_image", message, map[string]interface{}{
+		"id":        id,
+		"operation": metadata.Operation,
+	}))
+}
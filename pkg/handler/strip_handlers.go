@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// handleStripMetadata handles the strip_metadata tool: it saves a copy of an
+// image without its EXIF, GPS, XMP, and other metadata, for sharing. The copy
+// gets no content credentials, which would add metadata back.
+func (h *ReplicateImageHandler) handleStripMetadata(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("strip_metadata", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+
+	stripped, err := storage.StripMetadataFile(filePath)
+	if errors.Is(err, storage.ErrStripUnsupported) {
+		return h.errorResponse("strip_metadata", "invalid_format", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	if err != nil {
+		return h.errorResponse("strip_metadata", "processing_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+
+	filename := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + "_clean"
+	if name, ok := args["filename"].(string); ok && name != "" {
+		filename = name
+	}
+	filename = strings.TrimSuffix(filepath.Base(filename), stripped.Ext) + stripped.Ext
+	parameters := map[string]interface{}{"input_path": filePath}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "strip_metadata", parameters, filename, stripped.Data)
+	if err != nil {
+		return h.errorResponse("strip_metadata", "storage_error", err.Error(), nil)
+	}
+
+	result := map[string]interface{}{
+		"id":        id,
+		"file_path": outputPath,
+		"removed":   stripped.Removed,
+	}
+	if stripped.Rotated {
+		result["notes"] = []string{"the EXIF orientation was applied to the pixels, re-encoding the JPEG, so the image still displays upright"}
+	}
+	if url := h.files.URL(outputPath); url != "" {
+		result["share_url"] = url
+	}
+	message := fmt.Sprintf("The image had no metadata to remove; saved a copy: %s", outputPath)
+	if len(stripped.Removed) > 0 {
+		message = fmt.Sprintf("Removed %s metadata; saved the clean copy: %s", strings.Join(stripped.Removed, ", "), outputPath)
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("strip_metadata", message, result))
+}
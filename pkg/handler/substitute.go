@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+)
+
+// withSubstitutions lists the models a call's predictions went to in place of
+// deprecated or missing ones in its response, as model_substitutions, with a
+// warning for each
+func withSubstitutions(resp *protocol.CallToolResponse, callErr error, created *client.PredictionLog) *protocol.CallToolResponse {
+	subs := created.Substitutions()
+	if len(subs) == 0 {
+		return resp
+	}
+	warnings := make([]string, len(subs))
+	for i, sub := range subs {
+		warnings[i] = sub.Warning()
+	}
+	resp, _ = withResponseFields(resp, callErr, map[string]interface{}{
+		"model_substitutions": subs,
+		"warnings":            warnings,
+	})
+	return resp
+}
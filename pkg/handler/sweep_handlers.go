@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+	"github.com/gomcpgo/replicate_image_ai/pkg/workflow"
+)
+
+// sweepSteps is the number of values a from/to range is split into by default
+const sweepSteps = 5
+
+// sweepParameter is a numeric parameter parameter_sweep can vary
+type sweepParameter struct {
+	tools    map[string]bool // Tools taking the parameter
+	from, to float64         // Range swept when the call gives none
+	max      float64         // Largest accepted value, 0 for none
+	inputs   []string        // Model input keys the parameter is sent as
+}
+
+// sweepParameters are the parameters parameter_sweep can vary. Values must be
+// positive, since 0 selects the tool's default.
+var sweepParameters = map[string]sweepParameter{
+	"guidance_scale": {
+		tools:  map[string]bool{"generate_image": true, "edit_image": true},
+		from:   1,
+		to:     13,
+		inputs: []string{"guidance_scale", "cfg"}, // Stability models call it cfg
+	},
+	"strength": {
+		tools:  map[string]bool{"edit_image": true},
+		from:   0.2,
+		to:     1,
+		max:    1,
+		inputs: []string{"strength"},
+	},
+}
+
+// sweepRun is the call made for one value of a sweep
+type sweepRun struct {
+	value  float64
+	label  string
+	result workflow.Result
+}
+
+// handleParameterSweep handles the parameter_sweep tool: it runs
+// generate_image or edit_image once per value of a single parameter, with
+// everything else including the seed fixed, and composes the results into a
+// strip labeled with the values
+func (h *ReplicateImageHandler) handleParameterSweep(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	tool := "generate_image"
+	if t, ok := args["tool"].(string); ok && t != "" {
+		tool = t
+	}
+	if tool != "generate_image" && tool != "edit_image" {
+		return h.errorResponse("parameter_sweep", "invalid_parameters", "tool must be generate_image or edit_image", nil)
+	}
+	name, _ := args["parameter"].(string)
+	if name == "" {
+		name = "guidance_scale"
+		if tool == "edit_image" {
+			name = "strength"
+		}
+	}
+	param, ok := sweepParameters[name]
+	if !ok {
+		return h.errorResponse("parameter_sweep", "invalid_parameters", "parameter must be guidance_scale or strength", nil)
+	}
+	if !param.tools[tool] {
+		return h.errorResponse("parameter_sweep", "invalid_parameters", fmt.Sprintf("%s does not take %s", tool, name), nil)
+	}
+
+	callArgs, _ := args["arguments"].(map[string]interface{})
+	if prompt, _ := callArgs["prompt"].(string); prompt == "" {
+		return h.errorResponse("parameter_sweep", "invalid_parameters", "arguments.prompt is required", nil)
+	}
+	if tool == "edit_image" {
+		filePath, _ := callArgs["file_path"].(string)
+		inline, _ := callArgs["image_base64"].(string)
+		if filePath == "" && inline == "" {
+			return h.errorResponse("parameter_sweep", "invalid_parameters", "arguments.file_path or arguments.image_base64 is required for edit_image", nil)
+		}
+	}
+
+	values, err := sweepValues(args, param)
+	if err != nil {
+		return h.errorResponse("parameter_sweep", "invalid_parameters", err.Error(), nil)
+	}
+
+	// The seed is fixed across the sweep, so the parameter is the only
+	// difference between the results
+	seed := rand.Intn(1<<30) + 1
+	if s, ok := callArgs["seed"].(float64); ok && s > 0 {
+		seed = int(s)
+	}
+	base := "sweep_" + name
+	if filename, ok := args["filename"].(string); ok && filename != "" {
+		base = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+
+	// 1. Run every value at once
+	runs := make([]sweepRun, len(values))
+	var wg sync.WaitGroup
+	for i, value := range values {
+		run := &runs[i]
+		run.value = value
+		run.label = strconv.FormatFloat(value, 'f', -1, 64)
+		runArgs := make(map[string]interface{}, len(callArgs)+5)
+		for k, v := range callArgs {
+			runArgs[k] = v
+		}
+		delete(runArgs, "dry_run")
+		runArgs[name] = value
+		runArgs["seed"] = float64(seed)
+		runArgs["num_outputs"] = float64(1)
+		// Results are decoded for the strip, which WebP outputs cannot be
+		runArgs["output_format"] = "png"
+		delete(runArgs, "output_quality")
+		runArgs["filename"] = fmt.Sprintf("%s_%d", base, i+1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run.result = nestedResult(h.callTool(ctx, &protocol.CallToolRequest{Name: tool, Arguments: runArgs}))
+		}()
+	}
+	wg.Wait()
+
+	// 2. Compose the results that succeeded
+	var tiles, labels []string
+	var infos []map[string]interface{}
+	var notes []string
+	ignored := true
+	totalCost := 0.0
+	for _, run := range runs {
+		info := map[string]interface{}{name: run.value}
+		if !run.result.Success {
+			info["error"] = run.result.Error
+			infos = append(infos, info)
+			continue
+		}
+		output := run.result.Output
+		filePath := ""
+		if paths, ok := output["paths"].(map[string]interface{}); ok {
+			filePath, _ = paths["file_path"].(string)
+		}
+		info["id"] = output["id"]
+		info["file_path"] = filePath
+		cost := run.result.Cost
+		if cached, _ := output["cached"].(bool); cached {
+			cost = 0
+			info["cached"] = true
+		}
+		info["cost"] = cost
+		infos = append(infos, info)
+		totalCost += cost
+		tiles = append(tiles, filePath)
+		labels = append(labels, run.label)
+
+		inputs, _ := output["parameters"].(map[string]interface{})
+		for _, key := range param.inputs {
+			if _, ok := inputs[key]; ok {
+				ignored = false
+			}
+		}
+		// Every value usually yields the same notes; keep one of each
+		if outputNotes, ok := output["notes"].([]interface{}); ok {
+			for _, note := range outputNotes {
+				if s, ok := note.(string); ok && !slices.Contains(notes, s) {
+					notes = append(notes, s)
+				}
+			}
+		}
+	}
+	if len(tiles) == 0 {
+		return h.errorResponse("parameter_sweep", "processing_error", runs[0].result.Error, map[string]interface{}{"results": infos})
+	}
+	if ignored {
+		notes = append(notes, fmt.Sprintf("The model does not take %s, so the results differ only by chance or not at all; try a model that does", name))
+	}
+	if len(tiles) < len(runs) {
+		notes = append(notes, fmt.Sprintf("%d of %d values failed and are missing from the strip", len(runs)-len(tiles), len(runs)))
+	}
+
+	strip, err := storage.ComposeSweep(tiles, labels)
+	if err != nil {
+		return h.errorResponse("parameter_sweep", "processing_error", err.Error(), map[string]interface{}{"results": infos})
+	}
+	// An inline image is stored with each result, not in the sweep's metadata
+	stored := make(map[string]interface{}, len(callArgs))
+	for k, v := range callArgs {
+		if k != "image_base64" {
+			stored[k] = v
+		}
+	}
+	parameters := map[string]interface{}{
+		"tool":      tool,
+		"parameter": name,
+		"values":    values,
+		"seed":      seed,
+		"arguments": stored,
+	}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "parameter_sweep", parameters, base+".png", strip)
+	if err != nil {
+		return h.errorResponse("parameter_sweep", "storage_error", err.Error(), map[string]interface{}{"results": infos})
+	}
+
+	paths := map[string]string{"file_path": outputPath}
+	if shareURL := h.files.URL(outputPath); shareURL != "" {
+		paths["share_url"] = shareURL
+	}
+	result := map[string]interface{}{
+		"id":         id,
+		"paths":      paths,
+		"tool":       tool,
+		"parameter":  name,
+		"seed":       seed,
+		"results":    infos,
+		"total_cost": totalCost,
+	}
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Swept %s over %s with %s: %s", name, strings.Join(labels, ", "), tool, outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("parameter_sweep", message, result))
+}
+
+// sweepValues returns the values a sweep runs: the values given, or the
+// from-to range split into steps, rounded to three decimals
+func sweepValues(args map[string]interface{}, param sweepParameter) ([]float64, error) {
+	var values []float64
+	if raw, ok := args["values"].([]interface{}); ok && len(raw) > 0 {
+		for _, v := range raw {
+			value, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("values must be numbers")
+			}
+			values = append(values, value)
+		}
+	} else {
+		from, to := param.from, param.to
+		if v, ok := args["from"].(float64); ok {
+			from = v
+		}
+		if v, ok := args["to"].(float64); ok {
+			to = v
+		}
+		steps := sweepSteps
+		if v, ok := args["steps"].(float64); ok {
+			steps = int(v)
+		}
+		if steps < 2 || steps > storage.MaxSweepTiles {
+			return nil, fmt.Errorf("steps must be between 2 and %d", storage.MaxSweepTiles)
+		}
+		for i := 0; i < steps; i++ {
+			value := from + (to-from)*float64(i)/float64(steps-1)
+			values = append(values, math.Round(value*1000)/1000)
+		}
+	}
+
+	if len(values) < 2 || len(values) > storage.MaxSweepTiles {
+		return nil, fmt.Errorf("a sweep takes 2-%d values", storage.MaxSweepTiles)
+	}
+	seen := map[float64]bool{}
+	for _, value := range values {
+		if value <= 0 || (param.max > 0 && value > param.max) {
+			if param.max > 0 {
+				return nil, fmt.Errorf("values must be above 0 and at most %g, got %g", param.max, value)
+			}
+			return nil, fmt.Errorf("values must be above 0, got %g", value)
+		}
+		if seen[value] {
+			return nil, fmt.Errorf("value %g appears twice", value)
+		}
+		seen[value] = true
+	}
+	return values, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
 )
 
 // ListTools provides a list of all available tools
@@ -22,7 +23,7 @@ func (h *ReplicateImageHandler) ListTools(ctx context.Context) (*protocol.ListTo
 					},
 					"model": {
 						"type": "string",
-						"description": "Model to use: flux-schnell (fast), flux-pro (professional), flux-dev (experimental), sdxl (detailed), sdxl-lightning (ultra-fast), ideogram (text rendering), recraft (design), seedream (artistic), imagen-4 (photorealistic), gen4-image (visual context)",
+						"description": "Model to use: flux-schnell (fast), flux-pro (professional), flux-dev (experimental), sdxl (detailed), sdxl-lightning (ultra-fast), ideogram (text rendering), recraft (design), seedream / seedream-3 (artistic), seedream-4 (up to 4K, image sequences), imagen-4 / imagen-4-fast / imagen-4-ultra (photorealistic), gen4-image (visual context), sd3.5 / sd3.5-large-turbo / sd3.5-medium (Stable Diffusion 3.5), ultra (Stable Image Ultra, Stability AI only), gpt-image-1 (instruction following, OpenAI only), local (local ComfyUI or Automatic1111 server, free)",
 						"default": "flux-schnell"
 					},
 					"width": {
@@ -37,7 +38,7 @@ func (h *ReplicateImageHandler) ListTools(ctx context.Context) (*protocol.ListTo
 					},
 					"aspect_ratio": {
 						"type": "string",
-						"description": "Aspect ratio for Imagen-4 and Gen-4 models: 1:1, 16:9, 9:16, 4:3, 3:4",
+						"description": "Aspect ratio for Imagen-4, Gen-4 and Stability models: 1:1, 16:9, 9:16, 4:3, 3:4",
 						"enum": ["1:1", "16:9", "9:16", "4:3", "3:4"]
 					},
 					"resolution": {
@@ -46,6 +47,11 @@ func (h *ReplicateImageHandler) ListTools(ctx context.Context) (*protocol.ListTo
 						"enum": ["720p", "1080p"],
 						"default": "1080p"
 					},
+					"size": {
+						"type": "string",
+						"description": "Size tier for Seedream: small, regular, or big for seedream-3; 1K, 2K, or 4K for seedream-4. Width and height instead request exact dimensions",
+						"enum": ["small", "regular", "big", "1K", "2K", "4K"]
+					},
 					"guidance_scale": {
 						"type": "number",
 						"description": "How closely to follow the prompt (1-20). Higher values = more literal interpretation.",
@@ -55,13 +61,17 @@ func (h *ReplicateImageHandler) ListTools(ctx context.Context) (*protocol.ListTo
 						"type": "string",
 						"description": "What to avoid in the image (SDXL and similar models only)"
 					},
+					"negative_preset": {
+						"type": "string",
+						"description": "Negative prompt preset used when negative_prompt is not given: photography, anime, product, a configured preset, or none. Defaults to the preset configured for the model family (photography for SDXL, SD 3.5, and local models)"
+					},
 					"seed": {
 						"type": "integer",
 						"description": "Random seed for reproducible results"
 					},
 					"num_outputs": {
 						"type": "integer",
-						"description": "Number of images to generate (1-4)",
+						"description": "Number of images to generate (1-4). seedream-4 returns them as a sequence of related images and may return fewer",
 						"default": 1,
 						"minimum": 1,
 						"maximum": 4
@@ -72,256 +82,2042 @@ func (h *ReplicateImageHandler) ListTools(ctx context.Context) (*protocol.ListTo
 						"enum": ["block_low_and_above", "block_medium_and_above", "block_only_high"],
 						"default": "block_only_high"
 					},
+					"imagen_variant": {
+						"type": "string",
+						"description": "Imagen-4 tier: fast (about half the cost, for drafts), standard, or ultra (about 1.5x the cost, best quality and prompt adherence). Overrides the tier of the imagen-4 model alias",
+						"enum": ["fast", "standard", "ultra"]
+					},
+					"person_generation": {
+						"type": "string",
+						"description": "Which people Imagen-4 may depict: dont_allow, allow_adult, or allow_all. Defaults to the model's policy",
+						"enum": ["dont_allow", "allow_adult", "allow_all"]
+					},
 					"output_format": {
 						"type": "string",
-						"description": "Output format for Imagen-4: jpg, png",
-						"enum": ["jpg", "png"],
+						"description": "Output format: jpg, png, webp, avif. Imagen-4 defaults to jpg and Stability models to png; FLUX models return webp unless set. Formats the model cannot produce (always avif) are generated as png and re-encoded.",
+						"enum": ["jpg", "png", "webp", "avif"],
 						"default": "jpg"
 					},
+					"output_quality": {
+						"type": "integer",
+						"description": "Quality of jpg, webp, and avif output (1-100). FLUX models apply it themselves; re-encoded outputs use it too.",
+						"minimum": 1,
+						"maximum": 100
+					},
+					"quality": {
+						"type": "string",
+						"description": "Rendering quality for GPT Image: low, medium, high. Higher quality costs more and takes longer.",
+						"enum": ["low", "medium", "high"]
+					},
 					"filename": {
 						"type": "string",
 						"description": "Custom filename for the generated image"
+					},
+					"use_cache": {
+						"type": "boolean",
+						"description": "Return the stored result of an identical earlier request instead of running a new prediction (defaults to the server's RESULT_CACHE setting)"
+					},
+					"translate_prompt": {
+						"type": "boolean",
+						"description": "Translate a non-English prompt to English before generating; set false to send the prompt as written (defaults to the server's PROMPT_TRANSLATION setting)"
+					},
+					"deterministic_id": {
+						"type": "boolean",
+						"description": "Derive the result's ID from the request (model, prompt, seed, and options), so running the same request again replaces the earlier result in the same folder; useful for regression baselines (defaults to the server's DETERMINISTIC_IDS setting)"
 					}
 				},
 				"required": ["prompt"]
 			}`),
 		},
 		{
-			Name:        "generate_with_visual_context",
-			Description: `Generate images using RunwayML Gen-4 with visual reference images for maintaining consistent visual elements across generated images. This tool excels at preserving character identity, object appearance, and style consistency. Use @tags in your prompt to reference specific images (e.g., "@person in a coffee shop" where "person" is the tag for a reference image of a specific person).`,
+			Name:        "batch_generate",
+			Description: "Generate an image for each of several prompts at once, with the model, size, and other options shared. Each prompt is stored under its own ID; prompts still running after the initial wait are returned with a prediction_id each for continue_operation.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
-					"prompt": {
+					"prompts": {
+						"type": "array",
+						"items": {"type": "string"},
+						"description": "Prompts to generate, one call each; up to MAX_BATCH_SIZE (default 10) run at once"
+					},
+					"model": {
 						"type": "string",
-						"description": "Generation prompt with @tag references. Use @tag to reference specific images (e.g., '@character in @location with @object')"
+						"description": "Model for every prompt, as for generate_image",
+						"default": "flux-schnell"
 					},
-					"reference_images": {
-						"type": "array",
-						"items": {
-							"type": "string"
-						},
-						"description": "Array of 1-3 local image file paths to use as visual references",
-						"minItems": 1,
-						"maxItems": 3
+					"width": {
+						"type": "integer",
+						"description": "Image width in pixels (most models)"
 					},
-					"reference_tags": {
-						"type": "array",
-						"items": {
-							"type": "string"
-						},
-						"description": "Tags for each reference image (3-15 alphanumeric characters). These tags are used with @ in the prompt to reference specific images."
+					"height": {
+						"type": "integer",
+						"description": "Image height in pixels (most models)"
 					},
 					"aspect_ratio": {
 						"type": "string",
-						"description": "Output aspect ratio",
-						"enum": ["1:1", "16:9", "9:16", "4:3", "3:4"],
-						"default": "16:9"
+						"description": "Aspect ratio for Imagen-4, Gen-4 and Stability models",
+						"enum": ["1:1", "16:9", "9:16", "4:3", "3:4"]
 					},
-					"resolution": {
+					"negative_prompt": {
 						"type": "string",
-						"description": "Output resolution",
-						"enum": ["720p", "1080p"],
-						"default": "1080p"
+						"description": "What to avoid in every image (SDXL and similar models only)"
 					},
 					"seed": {
 						"type": "integer",
-						"description": "Random seed for reproducible results"
+						"description": "Random seed used for every prompt"
+					},
+					"num_outputs": {
+						"type": "integer",
+						"description": "Number of images per prompt (1-4)",
+						"default": 1,
+						"minimum": 1,
+						"maximum": 4
+					},
+					"output_format": {
+						"type": "string",
+						"description": "Output format: jpg, png, webp, avif",
+						"enum": ["jpg", "png", "webp", "avif"]
 					},
 					"filename": {
 						"type": "string",
-						"description": "Custom filename for the generated image"
+						"description": "Base filename; each prompt's image gets a _<N> suffix in prompt order"
 					}
 				},
-				"required": ["prompt", "reference_images", "reference_tags"]
+				"required": ["prompts"]
 			}`),
 		},
 		{
-			Name:        "edit_image",
-			Description: `Edit images using text instructions with FLUX Kontext models. Transform existing images through natural language commands like "Make it a winter scene", "Change the car to red", or "Convert to cartoon style". Three model variants available: pro (balanced speed/quality), max (highest quality), and dev (experimental features).`,
+			Name:        "generate_wide_image",
+			Description: "Generate an image wider or taller than any model supports, such as a 4096x1024 banner: a square base tile is generated, extended tile by tile with a mask-based fill model, and the tiles are stitched with their seams blended. Costs one generation plus one edit per added tile.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
-					"file_path": {
-						"type": "string",
-						"description": "Path to the image file to edit"
-					},
 					"prompt": {
 						"type": "string",
-						"description": "Text instruction describing how to edit the image (e.g., 'Make it a sunset scene', 'Change hair color to blonde', 'Add snow')"
+						"description": "Description of the whole scene; it guides every tile"
+					},
+					"width": {
+						"type": "integer",
+						"description": "Width of the final image in pixels",
+						"minimum": 256
+					},
+					"height": {
+						"type": "integer",
+						"description": "Height of the final image in pixels",
+						"minimum": 256
 					},
 					"model": {
 						"type": "string",
-						"description": "FLUX Kontext model variant: pro (balanced), max (highest quality), dev (experimental)",
-						"enum": ["pro", "max", "dev"],
-						"default": "pro"
-					},
-					"strength": {
-						"type": "number",
-						"description": "Edit strength (0.0-1.0). Higher values make more dramatic changes.",
-						"minimum": 0,
-						"maximum": 1,
-						"default": 0.8
+						"description": "Model generating the base tile, as for generate_image",
+						"default": "flux-schnell"
 					},
-					"guidance_scale": {
-						"type": "number",
-						"description": "How closely to follow the edit prompt (1-20)",
-						"default": 7.5
+					"edit_model": {
+						"type": "string",
+						"description": "Mask-based model extending the image tile by tile: fill (FLUX Fill Pro), inpaint (Stability AI), gpt-image-1 (OpenAI), or local",
+						"enum": ["fill", "inpaint", "gpt-image-1", "local"],
+						"default": "fill"
 					},
 					"seed": {
 						"type": "integer",
-						"description": "Random seed for reproducible results"
+						"description": "Random seed for the base tile and each extension"
+					},
+					"negative_prompt": {
+						"type": "string",
+						"description": "What to avoid in the base tile"
 					},
 					"filename": {
 						"type": "string",
-						"description": "Custom filename for the edited image"
+						"description": "Base name for the image (saved as PNG); tiles get a _tile<N> suffix (default: wide)"
 					}
 				},
-				"required": ["file_path", "prompt"]
+				"required": ["prompt", "width", "height"]
 			}`),
 		},
 		{
-			Name:        "remove_background",
-			Description: "Remove or replace the background of an image using AI models. Produces a transparent PNG or can replace with a new background.",
+			Name:        "generate_branded",
+			Description: `Generate on-brand images with the server's brand kit (BRAND_KIT): the brand palette is added to the prompt, prompts with banned terms are rejected, each output's palette is checked against the brand colors, and the brand logo is composited onto the result. Takes the same options as generate_image.`,
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
-					"file_path": {
+					"prompt": {
 						"type": "string",
-						"description": "Path to the image file"
+						"description": "Text description of the image to generate; the brand palette is appended automatically"
 					},
 					"model": {
 						"type": "string",
-						"description": "Model to use: remove-bg (fast), rembg (robust), dis (detailed)",
-						"enum": ["remove-bg", "rembg", "dis"],
-						"default": "remove-bg"
+						"description": "Model to use, as for generate_image",
+						"default": "flux-schnell"
+					},
+					"width": {
+						"type": "integer",
+						"description": "Image width in pixels (most models)"
+					},
+					"height": {
+						"type": "integer",
+						"description": "Image height in pixels (most models)"
+					},
+					"aspect_ratio": {
+						"type": "string",
+						"description": "Aspect ratio for Imagen-4, Gen-4 and Stability models",
+						"enum": ["1:1", "16:9", "9:16", "4:3", "3:4"]
+					},
+					"negative_prompt": {
+						"type": "string",
+						"description": "What to avoid in the image; the brand's banned terms are added automatically"
+					},
+					"negative_preset": {
+						"type": "string",
+						"description": "Negative prompt preset used when negative_prompt is not given: photography, anime, product, a configured preset, or none. Defaults to the preset configured for the model family (photography for SDXL, SD 3.5, and local models)"
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Random seed for reproducible results"
+					},
+					"num_outputs": {
+						"type": "integer",
+						"description": "Number of images to generate (1-4)",
+						"default": 1,
+						"minimum": 1,
+						"maximum": 4
+					},
+					"output_format": {
+						"type": "string",
+						"description": "Output format: jpg, png",
+						"enum": ["jpg", "png"],
+						"default": "png"
+					},
+					"skip_logo": {
+						"type": "boolean",
+						"description": "Leave the brand logo off the output",
+						"default": false
 					},
 					"filename": {
 						"type": "string",
-						"description": "Custom filename for the output image"
+						"description": "Custom filename for the generated image"
+					},
+					"use_cache": {
+						"type": "boolean",
+						"description": "Return the stored result of an identical earlier request instead of running a new prediction (defaults to the server's RESULT_CACHE setting)"
+					},
+					"translate_prompt": {
+						"type": "boolean",
+						"description": "Translate a non-English prompt to English before generating; set false to send the prompt as written (defaults to the server's PROMPT_TRANSLATION setting)"
+					},
+					"deterministic_id": {
+						"type": "boolean",
+						"description": "Derive the result's ID from the request (model, prompt, seed, and options), so running the same request again replaces the earlier result in the same folder; useful for regression baselines (defaults to the server's DETERMINISTIC_IDS setting)"
 					}
 				},
-				"required": ["file_path"]
+				"required": ["prompt"]
 			}`),
 		},
 		{
-			Name:        "upscale_image",
-			Description: "Upscale images to higher resolution using AI super-resolution models. Can enhance details and optionally improve faces.",
+			Name:        "generate_with_visual_context",
+			Description: `Generate images using RunwayML Gen-4 with visual reference images for maintaining consistent visual elements across generated images. This tool excels at preserving character identity, object appearance, and style consistency. Use @tags in your prompt to reference specific images (e.g., "@person in a coffee shop" where "person" is the tag for a reference image of a specific person). Characters and styles registered with register_reference_set can be used by name, without file paths.`,
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
-					"file_path": {
+					"prompt": {
 						"type": "string",
-						"description": "Path to the image file to upscale"
+						"description": "Generation prompt with @tag references. Use @tag to reference specific images (e.g., '@character in @location with @object')"
 					},
-					"scale": {
-						"type": "integer",
-						"description": "Upscale factor (2, 4, or 8)",
-						"enum": [2, 4, 8],
-						"default": 4
+					"reference_images": {
+						"type": "array",
+						"items": {
+							"type": "string"
+						},
+						"description": "Array of 1-3 local image paths or http(s) URLs to use as visual references (optional when using reference sets)",
+						"maxItems": 3
 					},
-					"model": {
+					"reference_tags": {
+						"type": "array",
+						"items": {
+							"type": "string"
+						},
+						"description": "Tags for each reference image (3-15 alphanumeric characters). These tags are used with @ in the prompt to reference specific images."
+					},
+					"character": {
 						"type": "string",
-						"description": "Model to use: realesrgan (general), esrgan (detailed), swinir (flexible)",
-						"enum": ["realesrgan", "esrgan", "swinir"],
-						"default": "realesrgan"
+						"description": "Name of a registered reference set to include, referenced as @name in the prompt"
 					},
-					"face_enhance": {
-						"type": "boolean",
-						"description": "Enhance faces during upscaling (RealESRGAN only)",
-						"default": false
+					"reference_sets": {
+						"type": "array",
+						"items": {
+							"type": "string"
+						},
+						"description": "Names of registered reference sets to include. Sets mentioned as @name in the prompt are included automatically."
+					},
+					"aspect_ratio": {
+						"type": "string",
+						"description": "Output aspect ratio",
+						"enum": ["1:1", "16:9", "9:16", "4:3", "3:4"],
+						"default": "16:9"
+					},
+					"resolution": {
+						"type": "string",
+						"description": "Output resolution",
+						"enum": ["720p", "1080p"],
+						"default": "1080p"
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Random seed for reproducible results"
 					},
 					"filename": {
 						"type": "string",
-						"description": "Custom filename for the upscaled image"
+						"description": "Custom filename for the generated image"
+					},
+					"use_cache": {
+						"type": "boolean",
+						"description": "Return the stored result of an identical earlier request instead of running a new prediction (defaults to the server's RESULT_CACHE setting)"
+					},
+					"translate_prompt": {
+						"type": "boolean",
+						"description": "Translate a non-English prompt to English before generating; set false to send the prompt as written (defaults to the server's PROMPT_TRANSLATION setting)"
+					},
+					"deterministic_id": {
+						"type": "boolean",
+						"description": "Derive the result's ID from the request (model, prompt, seed, and options), so running the same request again replaces the earlier result in the same folder; useful for regression baselines (defaults to the server's DETERMINISTIC_IDS setting)"
 					}
 				},
-				"required": ["file_path"]
+				"required": ["prompt"]
 			}`),
 		},
 		{
-			Name:        "enhance_face",
-			Description: "Enhance and restore faces in images using specialized AI models. Improves facial details, removes artifacts, and can restore old or damaged portraits.",
+			Name:        "professional_headshot",
+			Description: `Turn casual photos of a person into professional headshots. Gen-4 generates portraits that preserve the person's identity, wearing the chosen attire in front of the chosen background, and each candidate's face is then enhanced. Returns a set of candidates to choose from.`,
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
-					"file_path": {
+					"photos": {
+						"type": "array",
+						"items": {
+							"type": "string"
+						},
+						"description": "1-3 local photos or http(s) URLs of the person; clear, well-lit views of the face work best",
+						"maxItems": 3
+					},
+					"character": {
 						"type": "string",
-						"description": "Path to the image file containing faces"
+						"description": "Name of a registered reference set to use instead of (or with) photos"
 					},
-					"model": {
+					"attire": {
 						"type": "string",
-						"description": "Model to use: gfpgan (balanced), codeformer (versatile), restoreformer (natural)",
-						"enum": ["gfpgan", "codeformer", "restoreformer"],
-						"default": "gfpgan"
+						"description": "Clothing preset",
+						"enum": ["business_formal", "business_casual", "smart_casual", "medical", "creative"],
+						"default": "business_formal"
 					},
-					"fidelity": {
-						"type": "number",
-						"description": "Balance between quality and faithfulness to original (0.0-1.0). Higher = more faithful.",
-						"minimum": 0,
-						"maximum": 1,
-						"default": 0.5
+					"background": {
+						"type": "string",
+						"description": "Background preset",
+						"enum": ["studio_gray", "studio_white", "office", "outdoor", "gradient_blue"],
+						"default": "studio_gray"
 					},
-					"only_center": {
-						"type": "boolean",
-						"description": "Only enhance the center face in the image",
-						"default": false
+					"count": {
+						"type": "integer",
+						"description": "Number of candidates to generate (1-4)",
+						"default": 4,
+						"minimum": 1,
+						"maximum": 4
 					},
-					"background_enhance": {
+					"instructions": {
+						"type": "string",
+						"description": "Extra directions appended to the prompt, e.g. 'wearing glasses, slight smile'"
+					},
+					"aspect_ratio": {
+						"type": "string",
+						"description": "Output aspect ratio",
+						"enum": ["1:1", "4:3", "3:4"],
+						"default": "3:4"
+					},
+					"enhance_face": {
 						"type": "boolean",
-						"description": "Also enhance the background (CodeFormer only)",
-						"default": false
+						"description": "Run face enhancement on each candidate",
+						"default": true
 					},
-					"filename": {
+					"face_model": {
 						"type": "string",
-						"description": "Custom filename for the enhanced image"
+						"description": "Face enhancement model: gfpgan, codeformer, restoreformer",
+						"enum": ["gfpgan", "codeformer", "restoreformer"],
+						"default": "codeformer"
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Seed of the first candidate; the others use the following seeds"
 					}
-				},
-				"required": ["file_path"]
+				}
 			}`),
 		},
 		{
-			Name:        "restore_photo",
-			Description: "Restore old, damaged, or low-quality photos using AI. Can remove scratches, enhance details, fix fading, and optionally colorize black and white images.",
+			Name:        "product_scene",
+			Description: `Place a product photo into a new scene for e-commerce shots in one call: the product's background is removed, a scene is generated from the prompt (or loaded from scene_path), and the product is composited into it with a soft drop shadow and its colors matched to the scene.`,
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
 					"file_path": {
 						"type": "string",
-						"description": "Path to the photo to restore"
+						"description": "Local path or http(s) URL of the product photo"
 					},
-					"model": {
+					"prompt": {
 						"type": "string",
-						"description": "Model to use: bopbtl (old photos), gfpgan (faces), codeformer (general)",
-						"enum": ["bopbtl", "gfpgan", "codeformer"],
+						"description": "Description of the scene, e.g. 'a marble kitchen counter in morning light'. Required unless scene_path is given."
+					},
+					"scene_path": {
+						"type": "string",
+						"description": "Local path or http(s) URL of a PNG or JPEG scene to use instead of generating one"
+					},
+					"model": {
+						"type": "string",
+						"description": "Model that generates the scene",
+						"default": "flux-schnell"
+					},
+					"aspect_ratio": {
+						"type": "string",
+						"description": "Aspect ratio of the generated scene",
+						"enum": ["1:1", "16:9", "9:16", "4:3", "3:4"],
+						"default": "1:1"
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Random seed for the scene"
+					},
+					"placement": {
+						"type": "string",
+						"description": "Where the product goes: bottom (standing on the floor, centered), center, left, or right",
+						"enum": ["bottom", "center", "left", "right"],
+						"default": "bottom"
+					},
+					"scale": {
+						"type": "number",
+						"description": "Product height as a fraction of the scene height (0-1)",
+						"default": 0.6
+					},
+					"shadow": {
+						"type": "number",
+						"description": "Drop shadow opacity (0-1, 0 for none)",
+						"default": 0.45
+					},
+					"color_match": {
+						"type": "number",
+						"description": "How far to shift the product's color balance toward the scene's (0-1)",
+						"default": 0.3
+					},
+					"remove_background": {
+						"type": "boolean",
+						"description": "Cut the product out first; set false when the photo already has a transparent background",
+						"default": true
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the composite"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "register_reference_set",
+			Description: "Register a named character or style: 1-3 reference images stored with the server under a canonical tag, so generate_with_visual_context prompts can use @name without supplying file paths again.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"name": {
+						"type": "string",
+						"description": "Set name and canonical tag (3-14 letters or digits, starting with a letter). Extra images are tagged name2 and name3."
+					},
+					"images": {
+						"type": "array",
+						"items": {
+							"type": "string"
+						},
+						"description": "1-3 local image paths or http(s) URLs; the images are copied into storage",
+						"minItems": 1,
+						"maxItems": 3
+					},
+					"kind": {
+						"type": "string",
+						"description": "What the set captures",
+						"enum": ["character", "style"],
+						"default": "character"
+					},
+					"description": {
+						"type": "string",
+						"description": "Notes about the character or style"
+					},
+					"replace": {
+						"type": "boolean",
+						"description": "Replace an existing set with the same name",
+						"default": false
+					}
+				},
+				"required": ["name", "images"]
+			}`),
+		},
+		{
+			Name:        "list_reference_sets",
+			Description: "List registered character and style reference sets with their images and tags.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {}
+			}`),
+		},
+		{
+			Name:        "delete_reference_set",
+			Description: "Delete a registered reference set and its stored images.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"name": {
+						"type": "string",
+						"description": "Name of the set to delete"
+					}
+				},
+				"required": ["name"]
+			}`),
+		},
+		{
+			Name:        "regenerate",
+			Description: "Rerun a stored operation with its saved parameters, optionally overriding some of them (a new seed, a different model, a higher resolution). Works for generations, edits, and enhancements; the result is stored as a new operation.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string",
+						"description": "Storage ID of the operation to rerun"
+					},
+					"overrides": {
+						"type": "object",
+						"description": "Arguments of the original tool to change, e.g. {\"seed\": 42}, {\"model\": \"flux-pro\"}, or {\"width\": 1536, \"height\": 1536}"
+					}
+				},
+				"required": ["id"]
+			}`),
+		},
+		{
+			Name:        "create_ab_test",
+			Description: "Start a blind A/B comparison: generate two candidates from different models or prompts and compose them side by side, labeled only A and B in random order. Show the composite to the user, then record their pick with record_ab_choice.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"prompt": {
+						"type": "string",
+						"description": "Prompt for both candidates (candidate two uses prompt_b when set)"
+					},
+					"prompt_b": {
+						"type": "string",
+						"description": "Alternative prompt for the second candidate"
+					},
+					"model_a": {
+						"type": "string",
+						"description": "Model for the first candidate (same values as generate_image)",
+						"default": "flux-schnell"
+					},
+					"model_b": {
+						"type": "string",
+						"description": "Model for the second candidate (default: model_a)"
+					},
+					"aspect_ratio": {
+						"type": "string",
+						"description": "Aspect ratio for both candidates"
+					},
+					"width": {
+						"type": "integer",
+						"description": "Width for both candidates"
+					},
+					"height": {
+						"type": "integer",
+						"description": "Height for both candidates"
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Seed for both candidates"
+					}
+				},
+				"required": ["prompt"]
+			}`),
+		},
+		{
+			Name:        "record_ab_choice",
+			Description: "Record the winner of an A/B test. Reveals which model and prompt each label was and returns the updated preference report per model.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"test_id": {
+						"type": "string",
+						"description": "ID returned by create_ab_test"
+					},
+					"winner": {
+						"type": "string",
+						"description": "The preferred candidate",
+						"enum": ["A", "B", "tie"]
+					}
+				},
+				"required": ["test_id", "winner"]
+			}`),
+		},
+		{
+			Name:        "ab_report",
+			Description: "Report A/B test outcomes per model: tests, wins, losses, ties, and win rate, ranked best first.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {}
+			}`),
+		},
+		{
+			Name:        "parameter_sweep",
+			Description: "Run generate_image or edit_image once for each value of guidance_scale or strength, with the prompt, image, model, and seed fixed, and compose the results into a strip labeled with the values. Shows what a parameter does for a given prompt and model; costs one generation or edit per value.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"tool": {
+						"type": "string",
+						"description": "Tool to run for each value",
+						"enum": ["generate_image", "edit_image"],
+						"default": "generate_image"
+					},
+					"parameter": {
+						"type": "string",
+						"description": "Parameter to vary; strength applies to edit_image only. Defaults to guidance_scale for generate_image and strength for edit_image",
+						"enum": ["guidance_scale", "strength"]
+					},
+					"arguments": {
+						"type": "object",
+						"description": "Arguments of the tool call, as for generate_image or edit_image, such as prompt, model, and file_path. A seed is picked when none is given, and each result is saved as PNG"
+					},
+					"values": {
+						"type": "array",
+						"items": {"type": "number"},
+						"description": "Values to run (2-8), above 0; strength at most 1. Overrides from, to, and steps"
+					},
+					"from": {
+						"type": "number",
+						"description": "First value of an evenly spaced range (default: 1 for guidance_scale, 0.2 for strength)"
+					},
+					"to": {
+						"type": "number",
+						"description": "Last value of the range (default: 13 for guidance_scale, 1 for strength)"
+					},
+					"steps": {
+						"type": "integer",
+						"description": "Number of values in the range (2-8)",
+						"default": 5
+					},
+					"filename": {
+						"type": "string",
+						"description": "Base name for the strip (saved as PNG); results get a _<N> suffix (default: sweep_<parameter>)"
+					}
+				},
+				"required": ["arguments"]
+			}`),
+		},
+		{
+			Name:        "generate_preview",
+			Description: "Generate a cheap, low-resolution watermarked preview of an image for approval, on flux-schnell with the seed fixed. The call is stored with the preview; once it is approved, finalize runs it at full quality on the final model with the same seed and parameters.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"tool": {
+						"type": "string",
+						"description": "Tool the final image is made with",
+						"enum": ["generate_image", "generate_branded"],
+						"default": "generate_image"
+					},
+					"arguments": {
+						"type": "object",
+						"description": "Arguments of the final call, as for generate_image or generate_branded, such as prompt, size, and output_format. model is the final model (default: flux-pro). A seed is picked when none is given"
+					},
+					"preview_model": {
+						"type": "string",
+						"description": "Model the preview is generated with, as for generate_image",
+						"default": "flux-schnell"
+					}
+				},
+				"required": ["arguments"]
+			}`),
+		},
+		{
+			Name:        "finalize",
+			Description: "Render an approved preview at full quality: runs the call stored by generate_preview on its final model with the preview's seed and parameters, and stores the result as a new operation.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string",
+						"description": "ID returned by generate_preview"
+					},
+					"overrides": {
+						"type": "object",
+						"description": "Arguments of the final call to change, e.g. {\"model\": \"imagen-4-ultra\"} or {\"output_format\": \"jpg\"}"
+					}
+				},
+				"required": ["id"]
+			}`),
+		},
+		{
+			Name:        "edit_image",
+			Description: `Edit images using text instructions with FLUX Kontext models. Transform existing images through natural language commands like "Make it a winter scene", "Change the car to red", or "Convert to cartoon style". Three model variants available: pro (balanced speed/quality), max (highest quality), and dev (experimental features). For targeted edits, use fill (FLUX Fill Pro) or inpaint (Stability AI) with a mask_path marking the area to repaint, or a selection_prompt describing it (e.g. "the sky"). gpt-image-1 (OpenAI) follows complex instructions and accepts an optional mask_path.`,
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image file to edit"
+					},
+					"prompt": {
+						"type": "string",
+						"description": "Text instruction describing how to edit the image (e.g., 'Make it a sunset scene', 'Change hair color to blonde', 'Add snow')"
+					},
+					"model": {
+						"type": "string",
+						"description": "Model variant: pro (balanced), max (highest quality), dev (experimental), fill (FLUX Fill Pro, requires mask_path), inpaint (Stability AI, requires mask_path), gpt-image-1 (OpenAI), local (local Automatic1111 inpainting, requires mask_path)",
+						"enum": ["pro", "max", "dev", "fill", "inpaint", "gpt-image-1", "local"],
+						"default": "pro"
+					},
+					"strength": {
+						"type": "number",
+						"description": "Edit strength (0.0-1.0). Higher values make more dramatic changes.",
+						"minimum": 0,
+						"maximum": 1,
+						"default": 0.8
+					},
+					"guidance_scale": {
+						"type": "number",
+						"description": "How closely to follow the edit prompt (1-20)",
+						"default": 7.5
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Random seed for reproducible results"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the edited image"
+					},
+					"mask_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of a mask image for fill, inpaint, and local (required) or gpt-image-1 (optional): white areas are repainted, black areas kept"
+					},
+					"selection_prompt": {
+						"type": "string",
+						"description": "Describe the area to repaint instead of passing a mask_path, e.g. \"the sky\" or \"the car\". The objects it names are detected and their boxes become the mask. Defaults the model to fill."
+					}
+				},
+				"required": ["file_path", "prompt"]
+			}`),
+		},
+		{
+			Name:        "remove_background",
+			Description: "Remove or replace the background of an image using AI models. Produces a transparent PNG or can replace with a new background. Animated GIFs and APNGs are processed frame by frame.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image file"
+					},
+					"model": {
+						"type": "string",
+						"description": "Model to use: remove-bg (fast), rembg (robust), dis (detailed)",
+						"enum": ["remove-bg", "rembg", "dis"],
+						"default": "remove-bg"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the output image"
+					},
+					"auto_crop": {
+						"type": "boolean",
+						"description": "Also save a copy trimmed to the subject, for thumbnails and marketplace listings",
+						"default": false
+					},
+					"crop_padding": {
+						"type": "integer",
+						"description": "Transparent margin around the subject in the trimmed copy, in pixels",
+						"minimum": 0,
+						"default": 0
+					},
+					"crop_aspect_ratio": {
+						"type": "string",
+						"description": "Pad the trimmed copy with transparency to this aspect ratio: square or W:H (e.g. 4:5)"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "blur_background",
+			Description: "Blur the background of a photo behind its subject, like a portrait-mode bokeh. The subject is segmented, a depth map makes farther areas blurrier, and the blur is applied locally. Lighter than replacing the background.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the photo"
+					},
+					"strength": {
+						"type": "number",
+						"description": "Blur strength of the farthest background (0-1)",
+						"minimum": 0,
+						"maximum": 1,
+						"default": 0.5
+					},
+					"feather": {
+						"type": "number",
+						"description": "Softness of the subject's edge (0-1)",
+						"minimum": 0,
+						"maximum": 1,
+						"default": 0.3
+					},
+					"depth_aware": {
+						"type": "boolean",
+						"description": "Estimate depth so farther areas are blurred more. When false, the whole background is blurred evenly",
+						"default": true
+					},
+					"segmentation_model": {
+						"type": "string",
+						"description": "Background removal model used to find the subject",
+						"enum": ["remove-bg", "rembg", "dis"],
+						"default": "remove-bg"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the result (saved as PNG)"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "auto_crop",
+			Description: "Trim the transparent border of a cutout (e.g. from remove_background) down to the subject, with optional padding and padding out to a square or other aspect ratio. Saves the tight crop as a new image.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of an image with a transparent background"
+					},
+					"padding": {
+						"type": "integer",
+						"description": "Transparent margin around the subject, in pixels",
+						"minimum": 0,
+						"default": 0
+					},
+					"aspect_ratio": {
+						"type": "string",
+						"description": "Pad the crop with transparency to this aspect ratio, keeping the subject centered: square or W:H (e.g. 4:5)"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the crop (saved as PNG)"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "strip_metadata",
+			Description: "Save a copy of an image without its EXIF (camera, timestamps, GPS location), XMP, IPTC, comments, and PNG text chunks such as embedded generation parameters, before sharing or uploading it elsewhere. Pixels and color profile are kept; JPEG, PNG, and WebP are supported. Runs locally with no model call or cost.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image to clean"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the clean copy (default: the input name with _clean; the format is kept)"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "stitch_panorama",
+			Description: "Stitch a series of overlapping tiles, generated (e.g. by outpainting) or photographed, into one wide or tall image, for formats beyond any single model's maximum resolution. Overlaps are detected and cross-faded so no seam shows. Runs locally with no model call or cost.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"images": {
+						"type": "array",
+						"items": {"type": "string"},
+						"description": "Paths or http(s) URLs of the tiles in order, left to right or top to bottom (2-12). Each must overlap the next and line up with it across the seam.",
+						"minItems": 2,
+						"maxItems": 12
+					},
+					"direction": {
+						"type": "string",
+						"description": "horizontal joins the tiles left to right, vertical top to bottom",
+						"enum": ["horizontal", "vertical"],
+						"default": "horizontal"
+					},
+					"overlap": {
+						"type": "number",
+						"description": "Fraction of the narrower tile each pair shares, 0-0.5, when it is known (e.g. 0.25 for tiles outpainted with a quarter overlap). Detected when omitted.",
+						"minimum": 0,
+						"maximum": 0.5
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the panorama (saved as PNG)"
+					}
+				},
+				"required": ["images"]
+			}`),
+		},
+		{
+			Name:        "apply_film_look",
+			Description: "Give an image an analog film look locally, with no model call or cost: grain, a vignette, halation around highlights, and color grading with a .cube LUT. Saves the result as a new PNG.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image to stylize"
+					},
+					"grain": {
+						"type": "number",
+						"description": "Strength of the film grain, 0-1 (0.3 is subtle, 1 is heavy)",
+						"minimum": 0,
+						"maximum": 1
+					},
+					"grain_size": {
+						"type": "number",
+						"description": "Size of the grain, 0.5-4; 1 is fine 35mm grain. Scaled with the image so it looks alike at any resolution.",
+						"minimum": 0.5,
+						"maximum": 4,
+						"default": 1
+					},
+					"vignette": {
+						"type": "number",
+						"description": "Darkening of the corners, 0-1",
+						"minimum": 0,
+						"maximum": 1
+					},
+					"halation": {
+						"type": "number",
+						"description": "Red-orange glow bleeding around highlights, 0-1",
+						"minimum": 0,
+						"maximum": 1
+					},
+					"lut_path": {
+						"type": "string",
+						"description": "Local path of a 1D or 3D .cube LUT to grade the colors with, applied before the other effects"
+					},
+					"lut_strength": {
+						"type": "number",
+						"description": "Mix of the LUT-graded colors over the original, 0-1",
+						"minimum": 0,
+						"maximum": 1,
+						"default": 1
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Seed of the grain pattern; the same seed gives the same grain (default: 0)"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the result (saved as PNG)"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "recolor_image",
+			Description: "Remap an image's colors toward a palette of hex colors, e.g. to keep generated illustrations on brand. The local method shifts colors toward the palette at no cost while keeping shading; the edit method asks an edit model to repaint in the palette. Reports how much of the image matches the palette before and after.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image to recolor"
+					},
+					"palette": {
+						"type": "array",
+						"description": "Target colors as hex, e.g. [\"#FF5A1F\", \"#0B1F3A\"] (default: the brand kit's palette)",
+						"items": {"type": "string"}
+					},
+					"method": {
+						"type": "string",
+						"description": "local shifts colors toward the palette without a model call; edit repaints with an edit model",
+						"enum": ["local", "edit"],
+						"default": "local"
+					},
+					"strength": {
+						"type": "number",
+						"description": "How far the local method moves colors toward the palette, 0-1",
+						"minimum": 0,
+						"maximum": 1,
+						"default": 1
+					},
+					"exact": {
+						"type": "boolean",
+						"description": "With the local method, snap every pixel to a palette color, for flat illustrations",
+						"default": false
+					},
+					"prompt": {
+						"type": "string",
+						"description": "Extra instructions for the edit method, e.g. 'use the orange for the sky'"
+					},
+					"model": {
+						"type": "string",
+						"description": "Edit model for the edit method: pro (default), max, dev, or gpt-image-1",
+						"enum": ["pro", "max", "dev", "gpt-image-1"]
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Seed for the edit method"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the result"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "upscale_image",
+			Description: "Upscale images to higher resolution using AI super-resolution models. Can enhance details and optionally improve faces. Animated GIFs and APNGs are processed frame by frame.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image file to upscale"
+					},
+					"scale": {
+						"type": "integer",
+						"description": "Upscale factor (2, 4, or 8)",
+						"enum": [2, 4, 8],
+						"default": 4
+					},
+					"model": {
+						"type": "string",
+						"description": "Model to use: realesrgan (general), esrgan (detailed), swinir (flexible), clarity (adds fine detail), supir (degraded photos), stability-fast (4x, Stability AI only), stability-conservative (up to 4K, Stability AI only), local (local Automatic1111 server)",
+						"enum": ["realesrgan", "esrgan", "swinir", "clarity", "supir", "stability-fast", "stability-conservative", "local"],
+						"default": "realesrgan"
+					},
+					"face_enhance": {
+						"type": "boolean",
+						"description": "Enhance faces during upscaling (RealESRGAN only)",
+						"default": false
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the upscaled image"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "compare_upscalers",
+			Description: "Upscale the same crop of an image with several upscalers and compose the results side by side, next to the original crop, to help pick the right upscaler for the content. Reports the time and cost of each model.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image to compare upscalers on"
+					},
+					"models": {
+						"type": "array",
+						"items": {
+							"type": "string",
+							"enum": ["realesrgan", "esrgan", "swinir", "clarity", "supir", "stability-fast", "stability-conservative", "local"]
+						},
+						"description": "Upscalers to compare (1-4)",
+						"default": ["realesrgan", "clarity", "supir"]
+					},
+					"scale": {
+						"type": "integer",
+						"description": "Upscale factor",
+						"enum": [2, 4],
+						"default": 2
+					},
+					"crop": {
+						"type": "object",
+						"description": "Region of the image to compare, in pixels. Defaults to a 256x256 square at the center",
+						"properties": {
+							"x": {"type": "integer"},
+							"y": {"type": "integer"},
+							"width": {"type": "integer"},
+							"height": {"type": "integer"}
+						},
+						"required": ["width", "height"]
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "upscale_region",
+			Description: "Upscale just one region of an image, such as a face or a label, instead of the whole image, and optionally paste the result back with a soft edge. Much cheaper than upscaling a large image when only part of it matters.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image"
+					},
+					"region": {
+						"type": "object",
+						"description": "Region to upscale, in pixels of the upright image",
+						"properties": {
+							"x": {"type": "integer"},
+							"y": {"type": "integer"},
+							"width": {"type": "integer"},
+							"height": {"type": "integer"}
+						},
+						"required": ["width", "height"]
+					},
+					"subject": {
+						"type": "string",
+						"description": "Instead of region, what to find and upscale, e.g. 'face' or 'product label'. The most confident detection is used"
+					},
+					"scale": {
+						"type": "integer",
+						"description": "Upscale factor",
+						"enum": [2, 4],
+						"default": 2
+					},
+					"model": {
+						"type": "string",
+						"enum": ["realesrgan", "esrgan", "swinir", "clarity", "supir", "stability-fast", "stability-conservative", "local"],
+						"description": "Upscaling model",
+						"default": "realesrgan"
+					},
+					"face_enhance": {
+						"type": "boolean",
+						"description": "Also restore faces (realesrgan only)",
+						"default": false
+					},
+					"paste_back": {
+						"type": "string",
+						"enum": ["none", "original", "enlarged"],
+						"description": "none returns only the upscaled region; original pastes it back into the image at its own size; enlarged pastes it into a copy of the image enlarged by scale",
+						"default": "none"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the pasted-back image"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "compare_images",
+			Description: "Measure how two images differ, e.g. an original and its restoration or upscale: SSIM, PSNR, mean error, the share of pixels changed, and the sharpness of each. Runs locally at no cost and saves a heatmap of the differences.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the first image, usually the original"
+					},
+					"compare_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image to compare with it, usually the enhanced result"
+					},
+					"heatmap": {
+						"type": "boolean",
+						"description": "Save a heatmap of the differences over a dimmed copy of the first image",
+						"default": true
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the heatmap (saved as PNG)"
+					}
+				},
+				"required": ["file_path", "compare_path"]
+			}`),
+		},
+		{
+			Name:        "enhance_face",
+			Description: "Enhance and restore faces in images using specialized AI models. Improves facial details, removes artifacts, and can restore old or damaged portraits.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image file containing faces"
+					},
+					"model": {
+						"type": "string",
+						"description": "Model to use: gfpgan (balanced), codeformer (versatile), restoreformer (natural)",
+						"enum": ["gfpgan", "codeformer", "restoreformer"],
+						"default": "gfpgan"
+					},
+					"fidelity": {
+						"type": "number",
+						"description": "Balance between quality and faithfulness to original (0.0-1.0). Higher = more faithful.",
+						"minimum": 0,
+						"maximum": 1,
+						"default": 0.5
+					},
+					"only_center": {
+						"type": "boolean",
+						"description": "Only enhance the center face in the image",
+						"default": false
+					},
+					"background_enhance": {
+						"type": "boolean",
+						"description": "Also enhance the background (CodeFormer only)",
+						"default": false
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the enhanced image"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "restore_photo",
+			Description: "Restore old, damaged, or low-quality photos using AI. Can remove scratches, enhance details, fix fading, and optionally colorize black and white images.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the photo to restore"
+					},
+					"model": {
+						"type": "string",
+						"description": "Model to use: bopbtl (old photos), gfpgan (faces), codeformer (general)",
+						"enum": ["bopbtl", "gfpgan", "codeformer"],
 						"default": "bopbtl"
 					},
-					"face_enhance": {
+					"face_enhance": {
+						"type": "boolean",
+						"description": "Enhance faces during restoration",
+						"default": true
+					},
+					"scratch_removal": {
+						"type": "boolean",
+						"description": "Remove scratches and damage (BOPBTL only)",
+						"default": true
+					},
+					"colorize": {
+						"type": "boolean",
+						"description": "Colorize black and white photos",
+						"default": false
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the restored image"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "revive_photo",
+			Description: "Bring an old family photo back to life in one call: removes scratches and damage, restores faces, colorizes, and upscales, in that order. Each step is saved as its own result, so intermediate versions are kept.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the old photo"
+					},
+					"scratch_removal": {
+						"type": "boolean",
+						"description": "Remove scratches and damage with BOPBTL",
+						"default": true
+					},
+					"face_restore": {
+						"type": "boolean",
+						"description": "Restore faces",
+						"default": true
+					},
+					"colorize": {
+						"type": "boolean",
+						"description": "Colorize the photo with DDColor. Turn off for photos that are already in color",
+						"default": true
+					},
+					"upscale": {
+						"type": "boolean",
+						"description": "Upscale the result with Real-ESRGAN",
+						"default": true
+					},
+					"face_model": {
+						"type": "string",
+						"description": "Face restoration model",
+						"enum": ["codeformer", "gfpgan", "restoreformer"],
+						"default": "codeformer"
+					},
+					"fidelity": {
+						"type": "number",
+						"description": "Face fidelity (0.0-1.0). Higher keeps the person's own features",
+						"minimum": 0,
+						"maximum": 1,
+						"default": 0.7
+					},
+					"scale": {
+						"type": "integer",
+						"description": "Upscale factor",
+						"enum": [2, 4],
+						"default": 2
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the final image"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "vectorize_image",
+			Description: "Convert a raster image such as a logo or icon to SVG. Uses Recraft's vectorization model, or a free embedded tracer that reduces the image to a set number of colors and traces each color's shapes. The SVG is validated before it is saved.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image to vectorize (PNG, JPEG, or GIF for the tracer)"
+					},
+					"model": {
+						"type": "string",
+						"description": "recraft (Recraft Vectorize, best for complex artwork) or trace (embedded tracer, free, best for flat logos and icons)",
+						"enum": ["recraft", "trace"],
+						"default": "recraft"
+					},
+					"colors": {
+						"type": "integer",
+						"description": "Number of fill colors (trace only)",
+						"minimum": 2,
+						"maximum": 32,
+						"default": 8
+					},
+					"smoothing": {
+						"type": "number",
+						"description": "Outline smoothing (trace only): 0 keeps pixel-exact edges, 1 gives the smoothest curves",
+						"minimum": 0,
+						"maximum": 1,
+						"default": 0.5
+					},
+					"filename": {
+						"type": "string",
+						"description": "Custom filename for the SVG"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "caption_folder",
+			Description: "Caption every image in a directory and write each caption to a .txt file next to its image, with an optional trigger word in front. The result is a captioned dataset in the image-plus-text layout LoRA trainers expect. Images that already have a caption are skipped unless overwrite is set.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"directory": {
+						"type": "string",
+						"description": "Directory containing the images to caption"
+					},
+					"model": {
+						"type": "string",
+						"description": "blip (short, literal captions, cheapest) or llava (detailed captions guided by prompt)",
+						"enum": ["blip", "llava"],
+						"default": "blip"
+					},
+					"trigger_word": {
+						"type": "string",
+						"description": "Word put at the start of every caption, e.g. the token a LoRA is trained on"
+					},
+					"prompt": {
+						"type": "string",
+						"description": "Instruction for llava describing the caption to write"
+					},
+					"overwrite": {
+						"type": "boolean",
+						"description": "Replace existing caption files",
+						"default": false
+					},
+					"recursive": {
+						"type": "boolean",
+						"description": "Also caption images in subdirectories",
+						"default": false
+					}
+				},
+				"required": ["directory"]
+			}`),
+		},
+		{
+			Name:        "prepare_dataset",
+			Description: "Turn a folder of raw photos into a training dataset: crop each one around a face or subject, resize it to the training resolution, drop near-duplicate shots, caption what is left, and zip the result. The dataset is saved as a new operation; the source folder is not modified.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"directory": {
+						"type": "string",
+						"description": "Directory containing the raw images"
+					},
+					"crop": {
+						"type": "string",
+						"description": "face (head and shoulders around the most confident face), subject (around the object named by subject), center (largest centered square, free), or none (whole image, long edge at the resolution)",
+						"enum": ["face", "subject", "center", "none"],
+						"default": "center"
+					},
+					"subject": {
+						"type": "string",
+						"description": "What to crop around when crop is subject, e.g. dog or red sneaker",
+						"default": "person"
+					},
+					"resolution": {
+						"type": "integer",
+						"description": "Training resolution in pixels",
+						"minimum": 256,
+						"maximum": 2048,
+						"default": 1024
+					},
+					"dedupe": {
+						"type": "boolean",
+						"description": "Drop near-duplicate shots, keeping the largest",
+						"default": true
+					},
+					"caption": {
+						"type": "boolean",
+						"description": "Write a .txt caption next to each image",
+						"default": true
+					},
+					"caption_model": {
+						"type": "string",
+						"description": "Captioning model: blip or llava",
+						"enum": ["blip", "llava"],
+						"default": "blip"
+					},
+					"trigger_word": {
+						"type": "string",
+						"description": "Word put at the start of every caption"
+					},
+					"zip": {
+						"type": "boolean",
+						"description": "Also zip the images and captions into dataset.zip",
+						"default": true
+					},
+					"recursive": {
+						"type": "boolean",
+						"description": "Also include images in subdirectories",
+						"default": false
+					}
+				},
+				"required": ["directory"]
+			}`),
+		},
+		{
+			Name:        "export_social_sizes",
+			Description: "Export an image at the sizes social platforms expect (1:1, 4:5, 9:16, 16:9, and cover banners) in one call. Each target is smart-cropped, or outpainted with a generative fill model when cropping would cut away too much. Files are named by platform.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image to export"
+					},
+					"targets": {
+						"type": "array",
+						"description": "Sizes to export (default: instagram_square, instagram_portrait, instagram_story, youtube_thumbnail, facebook_cover, x_header, linkedin_cover)",
+						"items": {
+							"type": "string",
+							"enum": ["instagram_square", "instagram_portrait", "instagram_story", "tiktok", "pinterest_pin", "facebook_post", "linkedin_post", "x_post", "youtube_thumbnail", "facebook_cover", "x_header", "linkedin_cover", "youtube_banner"]
+						}
+					},
+					"mode": {
+						"type": "string",
+						"description": "auto crops when at least 60% of the image survives and outpaints otherwise; crop and outpaint force one method",
+						"enum": ["auto", "crop", "outpaint"],
+						"default": "auto"
+					},
+					"subject": {
+						"type": "string",
+						"description": "What crops should be centered on, e.g. 'person' or 'product'. By default crops follow the most detailed region."
+					},
+					"prompt": {
+						"type": "string",
+						"description": "Description of what to paint in outpainted borders (default: extend the scene naturally)"
+					},
+					"model": {
+						"type": "string",
+						"description": "Mask-based model used to outpaint: fill (FLUX Fill Pro), inpaint (Stability AI), gpt-image-1 (OpenAI), or local",
+						"enum": ["fill", "inpaint", "gpt-image-1", "local"],
+						"default": "fill"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Base name for the files, which get a _<target>.png suffix (default: the input's name)"
+					}
+				},
+				"required": ["file_path"]
+			}`),
+		},
+		{
+			Name:        "generate_icon_set",
+			Description: "Generate a favicon and app icon set from a square image, or from a prompt: PNGs at the standard sizes (16-1024px), a favicon.ico bundle, and a maskable PWA icon. Icons are resized locally; set upscale to enlarge a small source with an AI upscaler first.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"file_path": {
+						"type": "string",
+						"description": "Path or http(s) URL of the image to make icons from. Images that are not square are cropped to their center."
+					},
+					"prompt": {
+						"type": "string",
+						"description": "Description of an icon to generate instead of passing an image"
+					},
+					"model": {
+						"type": "string",
+						"description": "Model used to generate from a prompt (default: flux-schnell)"
+					},
+					"seed": {
+						"type": "integer",
+						"description": "Seed for generating from a prompt"
+					},
+					"sizes": {
+						"type": "array",
+						"description": "Icon sizes in pixels (default: 16, 32, 48, 64, 128, 180, 192, 256, 512, 1024)",
+						"items": {"type": "integer", "minimum": 16, "maximum": 1024}
+					},
+					"maskable": {
+						"type": "boolean",
+						"description": "Also write a 512px maskable icon, with the design inside the safe zone that Android and PWA masks keep",
+						"default": true
+					},
+					"background": {
+						"type": "string",
+						"description": "Hex color behind transparent designs in the maskable icon and the 180px Apple touch icon (default: #FFFFFF)"
+					},
+					"upscale": {
 						"type": "boolean",
-						"description": "Enhance faces during restoration",
-						"default": true
+						"description": "Upscale the source with an AI model when it is smaller than the largest icon, instead of enlarging it locally",
+						"default": false
 					},
-					"scratch_removal": {
+					"upscale_model": {
+						"type": "string",
+						"description": "Upscaling model used with upscale (default: realesrgan)",
+						"enum": ["realesrgan", "esrgan", "swinir", "clarity", "supir", "stability-fast", "stability-conservative", "local"]
+					},
+					"filename": {
+						"type": "string",
+						"description": "Base name for the PNGs, which get a _<size>.png suffix (default: icon)"
+					}
+				}
+			}`),
+		},
+		{
+			Name:        "run_chain",
+			Description: "Run a small workflow of tool calls server-side in one call. Nodes are tool calls; edges order them, wire one node's output into the next node's arguments, and branch on success or failure. Independent nodes run concurrently. Returns a chain_id and the combined status of every node; poll chain_status until the chain finishes.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"workflow": {
+						"type": "object",
+						"description": "The workflow to run (at most 20 nodes, no cycles)",
+						"properties": {
+							"nodes": {
+								"type": "array",
+								"description": "Tool calls to make",
+								"items": {
+									"type": "object",
+									"properties": {
+										"id": {"type": "string", "description": "Unique node name used by edges"},
+										"tool": {"type": "string", "description": "Name of any tool except run_chain and chain_status"},
+										"args": {"type": "object", "description": "Arguments for the tool"}
+									},
+									"required": ["id", "tool"]
+								}
+							},
+							"edges": {
+								"type": "array",
+								"description": "Dependencies between nodes. A node runs once all its incoming edges are followed; if any is not, the node is skipped.",
+								"items": {
+									"type": "object",
+									"properties": {
+										"from": {"type": "string", "description": "Source node id"},
+										"to": {"type": "string", "description": "Target node id"},
+										"output": {"type": "string", "description": "Dotted path into the source's response to pass on", "default": "paths.file_path"},
+										"input": {"type": "string", "description": "Argument of the target that receives the output, e.g. file_path. Omit to only order the nodes."},
+										"on": {"type": "string", "enum": ["success", "failure", "always"], "description": "When the edge is followed", "default": "success"}
+									},
+									"required": ["from", "to"]
+								}
+							}
+						},
+						"required": ["nodes"]
+					},
+					"lock_seed": {
+						"type": "integer",
+						"description": "Root seed that makes the whole chain reproducible: every node whose tool takes a seed and that sets none gets one derived from this seed and its node id, so running the chain again with the same lock_seed reproduces every step"
+					},
+					"wait_seconds": {
+						"type": "number",
+						"description": "Wait up to this many seconds (max 300) for the chain to finish before returning its status",
+						"default": 0
+					}
+				},
+				"required": ["workflow"]
+			}`),
+		},
+		{
+			Name:        "chain_status",
+			Description: "Report the combined status of a chain started by run_chain: the state, result, error, duration, and cost of every node, and the chain's total cost.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"chain_id": {
+						"type": "string",
+						"description": "ID returned by run_chain"
+					},
+					"wait_seconds": {
+						"type": "number",
+						"description": "Wait up to this many seconds (max 300) for the chain to finish before returning its status",
+						"default": 0
+					}
+				},
+				"required": ["chain_id"]
+			}`),
+		},
+		{
+			Name:        "continue_operation",
+			Description: "Wait for a generation, enhancement, or editing operation that returned status processing, and return its result once it finishes.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"prediction_id": {
+						"type": "string",
+						"description": "prediction_id of the processing response"
+					},
+					"wait_time": {
+						"type": "number",
+						"description": "Seconds to wait for the operation (max 30)",
+						"default": 30
+					}
+				},
+				"required": ["prediction_id"]
+			}`),
+		},
+		{
+			Name:        "list_interrupted",
+			Description: "List the tool calls a server shutdown cancelled or never started, with their arguments and the IDs of the predictions they created, so they can be rerun or their outputs recovered.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"clear": {
 						"type": "boolean",
-						"description": "Remove scratches and damage (BOPBTL only)",
+						"description": "Empty the log after listing it",
+						"default": false
+					}
+				}
+			}`),
+		},
+		{
+			Name:        "warm_model",
+			Description: "Boot a community model (SDXL, upscalers, face restoration, background removal, captioning, ...) with a minimal prediction so the next real call does not wait 30-90 seconds for a cold start. Official models and other providers are always warm and are skipped at no cost. A warm-up costs a fraction of a cent; models stay warm for a few minutes after their last prediction.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"model": {
+						"type": "string",
+						"description": "Model alias from any tool (e.g. sdxl, real-esrgan, gfpgan, blip) or a full model ID"
+					},
+					"input": {
+						"type": "object",
+						"description": "Prediction input to send instead of the built-in minimal one; required for custom models"
+					},
+					"wait": {
+						"type": "boolean",
+						"description": "Wait until the model has booted and report how long it took. With false, return as soon as the warm-up starts.",
 						"default": true
+					}
+				},
+				"required": ["model"]
+			}`),
+		},
+		{
+			Name:        "probe_model",
+			Description: "Check models before committing to a slow or large call: whether each model (and its pinned version) still exists on Replicate, whether it is likely warm or cold, and how long recent calls with it took on this server (median, p90, cold starts from the spend ledger). Costs nothing.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"model": {
+						"type": "string",
+						"description": "Model alias from any tool or a full model ID"
 					},
-					"colorize": {
+					"models": {
+						"type": "array",
+						"items": {"type": "string"},
+						"description": "Several models to compare (at most 10)"
+					}
+				}
+			}`),
+		},
+		{
+			Name:        "repair_storage",
+			Description: "Remove orphaned storage directories left behind by failed or interrupted operations, along with stale partial downloads. Directories containing images are never removed.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"dry_run": {
 						"type": "boolean",
-						"description": "Colorize black and white photos",
+						"description": "Report what would be removed without deleting anything",
 						"default": false
+					}
+				}
+			}`),
+		},
+		{
+			Name:        "usage_summary",
+			Description: "Summarize image operations for a period: counts, outputs, generation time, bytes stored, and spend, grouped by model and by day.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"period": {
+						"type": "string",
+						"description": "Period to summarize (last 7 days for week, last 30 days for month)",
+						"enum": ["today", "week", "month", "all"],
+						"default": "week"
 					},
-					"filename": {
+					"since": {
 						"type": "string",
-						"description": "Custom filename for the restored image"
+						"description": "Start date (YYYY-MM-DD); overrides the period start"
+					},
+					"until": {
+						"type": "string",
+						"description": "End date (YYYY-MM-DD), inclusive; overrides the period end"
+					}
+				}
+			}`),
+		},
+		{
+			Name:        "export_for_dam",
+			Description: "Write IPTC/XMP metadata (title, description from the prompt, creator, usage terms, keywords, AI source type) for a stored operation's outputs, as .xmp sidecars or embedded in PNG/JPEG files, so digital asset management systems can ingest them without manual tagging.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string",
+						"description": "Storage ID of the operation to export"
+					},
+					"mode": {
+						"type": "string",
+						"description": "Write .xmp sidecar files, embed the metadata in the images (PNG and JPEG only), or both",
+						"enum": ["sidecar", "embedded", "both"],
+						"default": "sidecar"
+					},
+					"title": {
+						"type": "string",
+						"description": "Asset title (default: the start of the prompt)"
+					},
+					"description": {
+						"type": "string",
+						"description": "Asset description (default: the prompt)"
+					},
+					"creator": {
+						"type": "string",
+						"description": "Creator name (default: DAM_CREATOR)"
+					},
+					"usage_terms": {
+						"type": "string",
+						"description": "Rights usage terms (default: DAM_USAGE_TERMS)"
+					},
+					"keywords": {
+						"type": "array",
+						"items": {"type": "string"},
+						"description": "Keywords for the asset"
 					}
 				},
-				"required": ["file_path"]
+				"required": ["id"]
+			}`),
+		},
+		{
+			Name:        "export_metadata",
+			Description: "Export the metadata of stored operations (prompt, model, provider, dimensions, timing, cost, parameters) to a CSV or JSON file for analysis in spreadsheets or BI tools. Filters narrow the export to a subset.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"format": {
+						"type": "string",
+						"description": "Export file format",
+						"enum": ["csv", "json"],
+						"default": "csv"
+					},
+					"output_path": {
+						"type": "string",
+						"description": "File to write (default: a timestamped file in the exports directory of the storage root)"
+					},
+					"operation": {
+						"type": "string",
+						"description": "Only export this operation (e.g. generate_image)"
+					},
+					"model": {
+						"type": "string",
+						"description": "Only export operations run with this model ID or alias"
+					},
+					"prompt_contains": {
+						"type": "string",
+						"description": "Only export operations whose prompt contains this text (case-insensitive)"
+					},
+					"min_rating": {
+						"type": "integer",
+						"description": "Only export operations rated at least this many stars (1-5)",
+						"minimum": 1,
+						"maximum": 5
+					},
+					"since": {
+						"type": "string",
+						"description": "Start date (YYYY-MM-DD)"
+					},
+					"until": {
+						"type": "string",
+						"description": "End date (YYYY-MM-DD), inclusive"
+					}
+				}
+			}`),
+		},
+		{
+			Name:        "list_images",
+			Description: "List stored images newest first with their ID, operation, model, file path, rating, note, and parameters. Filters narrow the list, for example to the best-rated images.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"operation": {
+						"type": "string",
+						"description": "Only images from this operation (e.g. generate_image)"
+					},
+					"model": {
+						"type": "string",
+						"description": "Only images made with this model ID or alias"
+					},
+					"prompt_contains": {
+						"type": "string",
+						"description": "Only images whose prompt contains this text (case-insensitive)"
+					},
+					"min_rating": {
+						"type": "integer",
+						"description": "Only images rated at least this many stars (1-5)",
+						"minimum": 1,
+						"maximum": 5
+					},
+					"since": {
+						"type": "string",
+						"description": "Start date (YYYY-MM-DD)"
+					},
+					"until": {
+						"type": "string",
+						"description": "End date (YYYY-MM-DD), inclusive"
+					},
+					"limit": {
+						"type": "integer",
+						"description": "Most images to return",
+						"default": 50,
+						"minimum": 1
+					}
+				}
+			}`),
+		},
+		{
+			Name:        "get_image",
+			Description: "Get a stored image by its ID: the paths of its files and its metadata, including the operation, model, prompt and parameters, size, cost, rating, and note.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string",
+						"description": "Storage ID of the image, as returned by the tool that made it or by list_images"
+					}
+				},
+				"required": ["id"]
+			}`),
+		},
+		{
+			Name:        "delete_image",
+			Description: "Permanently delete a stored image with all the files of its operation (outputs, metadata, and sidecars). This cannot be undone.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string",
+						"description": "Storage ID of the image to delete"
+					}
+				},
+				"required": ["id"]
+			}`),
+		},
+		{
+			Name:        "rate_image",
+			Description: "Rate a stored image from 1 to 5 stars. The rating is saved in its metadata, shown by list_images, filters list_images and export_metadata, and is written as xmp:Rating by export_for_dam.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string",
+						"description": "Storage ID of the image"
+					},
+					"rating": {
+						"type": "integer",
+						"description": "Stars from 1 to 5; 0 clears the rating",
+						"minimum": 0,
+						"maximum": 5
+					}
+				},
+				"required": ["id", "rating"]
+			}`),
+		},
+		{
+			Name:        "annotate_image",
+			Description: "Attach a free-text note to a stored image, such as why it was picked or what to fix. The note is saved in its metadata and shown by list_images.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string",
+						"description": "Storage ID of the image"
+					},
+					"note": {
+						"type": "string",
+						"description": "The note; an empty note clears it"
+					},
+					"append": {
+						"type": "boolean",
+						"description": "Add the note on a new line after the existing one instead of replacing it",
+						"default": false
+					}
+				},
+				"required": ["id", "note"]
+			}`),
+		},
+		{
+			Name:        "list_prompt_history",
+			Description: "List the prompts used in earlier tool calls, newest first, with the tool, model, result storage ID, outcome, and any favorite, rating, or name. Recall a prompt that worked well without digging through metadata files.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"query": {
+						"type": "string",
+						"description": "Only prompts or favorite names containing this text (case-insensitive)"
+					},
+					"tool": {
+						"type": "string",
+						"description": "Only prompts used with this tool (e.g. generate_image)"
+					},
+					"model": {
+						"type": "string",
+						"description": "Only prompts run with this model ID or alias"
+					},
+					"outcome": {
+						"type": "string",
+						"description": "Only prompts with this outcome",
+						"enum": ["succeeded", "failed", "processing"]
+					},
+					"favorites_only": {
+						"type": "boolean",
+						"description": "Only favorite prompts",
+						"default": false
+					},
+					"min_rating": {
+						"type": "integer",
+						"description": "Only prompts rated at least this (1-5)",
+						"minimum": 1,
+						"maximum": 5
+					},
+					"since": {
+						"type": "string",
+						"description": "Start date (YYYY-MM-DD)"
+					},
+					"until": {
+						"type": "string",
+						"description": "End date (YYYY-MM-DD), inclusive"
+					},
+					"limit": {
+						"type": "integer",
+						"description": "Most prompts to return",
+						"default": 20,
+						"minimum": 1
+					}
+				}
+			}`),
+		},
+		{
+			Name:        "favorite_prompt",
+			Description: "Mark a prompt from the history as a favorite, rate how well it worked, or name it for later recall. Favorites and ratings are shown by list_prompt_history.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"id": {
+						"type": "string",
+						"description": "prompt_id returned with the tool response, or the storage ID of the result"
+					},
+					"favorite": {
+						"type": "boolean",
+						"description": "Whether the prompt is a favorite; pass false to unfavorite it or to only rate it",
+						"default": true
+					},
+					"rating": {
+						"type": "integer",
+						"description": "How well the prompt worked, from 1 to 5; 0 clears the rating",
+						"minimum": 0,
+						"maximum": 5
+					},
+					"name": {
+						"type": "string",
+						"description": "Label to recall the prompt by; list_prompt_history searches it"
+					}
+				},
+				"required": ["id"]
 			}`),
 		},
 	}
+	addProperty(tools, "dry_run", dryRunSchema, func(name string) bool { return dryRunTools[name] })
+	addProperty(tools, "priority", prioritySchema, predictionTool)
+	addProperty(tools, "max_wait_seconds", maxWaitSchema, predictionTool)
+	addProperty(tools, "output_format", outputFormatSchema, func(name string) bool { return encodingTools[name] })
+	addProperty(tools, "output_quality", outputQualitySchema, func(name string) bool { return encodingTools[name] })
+	addProperty(tools, "pdf_page", pdfPageSchema, func(name string) bool { return pdfTools[name] })
+	addProperty(tools, "size_preset", sizePresetSchema, func(name string) bool { return sizePresetTools[name] })
+	addProperty(tools, "response_detail", responseDetailSchema, func(string) bool { return true })
+	addProperty(tools, "open_output", openOutputSchema, openTool)
+	addProperty(tools, "delete_remote", deleteRemoteSchema, predictionTool)
+	addInlineImage(tools)
+	addOutputSchemas(tools)
 	
 	return &protocol.ListToolsResponse{
 		Tools: tools,
 	}, nil
-}
\ No newline at end of file
+}
+
+// addProperty adds a property shared by several tools to the schema of each
+// tool accepted by include
+func addProperty(tools []protocol.Tool, name, schema string, include func(string) bool) {
+	for i := range tools {
+		if !include(tools[i].Name) {
+			continue
+		}
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(tools[i].InputSchema, &parsed); err != nil {
+			continue
+		}
+		properties, ok := parsed["properties"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		properties[name] = json.RawMessage(schema)
+		if raw, err := json.Marshal(parsed); err == nil {
+			tools[i].InputSchema = raw
+		}
+	}
+}
+
+// addOutputSchemas publishes the response schema on every tool, so clients
+// can read the structured content of results instead of parsing the text
+func addOutputSchemas(tools []protocol.Tool) {
+	schema := responses.OutputSchema()
+	for i := range tools {
+		tools[i].OutputSchema = schema
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/enhancement"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+	"github.com/gomcpgo/replicate_image_ai/pkg/vectorize"
+)
+
+// traceModel selects the embedded tracer instead of a vectorization model
+const traceModel = "trace"
+
+// handleVectorizeImage handles the vectorize_image tool
+func (h *ReplicateImageHandler) handleVectorizeImage(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	filePath, ok := args["file_path"].(string)
+	if !ok || filePath == "" {
+		return h.errorResponse("vectorize_image", "invalid_parameters", "file_path or image_base64 parameter is required", nil)
+	}
+	model := "recraft"
+	if m, ok := args["model"].(string); ok && m != "" {
+		model = m
+	}
+	filename, _ := args["filename"].(string)
+
+	if model != traceModel {
+		result, err := h.enhancer.Vectorize(ctx, enhancement.VectorizeParams{
+			ImagePath: filePath,
+			Model:     model,
+			Filename:  filename,
+		})
+		if err != nil {
+			return h.toolErrorResponse("vectorize_image", "processing_error", err)
+		}
+		result.Notes = append(result.Notes, h.addContentCredentials(ctx, result.ID)...)
+		return h.successResponse(h.buildEnhancementResponse(result))
+	}
+
+	// Trace locally
+	opts := vectorize.Options{Colors: vectorize.DefaultColors, Smoothing: 0.5}
+	if colors, ok := args["colors"].(float64); ok {
+		opts.Colors = int(colors)
+	}
+	if smoothing, ok := args["smoothing"].(float64); ok {
+		opts.Smoothing = smoothing
+	}
+	if opts.Colors < vectorize.MinColors || opts.Colors > vectorize.MaxColors {
+		return h.errorResponse("vectorize_image", "invalid_parameters",
+			fmt.Sprintf("colors must be between %d and %d", vectorize.MinColors, vectorize.MaxColors), nil)
+	}
+	if opts.Smoothing < 0 || opts.Smoothing > 1 {
+		return h.errorResponse("vectorize_image", "invalid_parameters", "smoothing must be between 0 and 1", nil)
+	}
+
+	data, err := vectorize.TraceFile(filePath, opts)
+	if err != nil {
+		return h.errorResponse("vectorize_image", "processing_error", err.Error(), map[string]interface{}{"file_path": filePath})
+	}
+	paths, err := storage.ValidateSVG(data)
+	if err != nil {
+		return h.errorResponse("vectorize_image", "processing_error", err.Error(), nil)
+	}
+
+	if filename == "" {
+		filename = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + "_vector"
+	}
+	filename = strings.TrimSuffix(filepath.Base(filename), ".svg") + ".svg"
+	parameters := map[string]interface{}{
+		"input_path": filePath,
+		"model":      traceModel,
+		"colors":     opts.Colors,
+		"smoothing":  opts.Smoothing,
+	}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "vectorize_image", parameters, filename, data)
+	if err != nil {
+		return h.errorResponse("vectorize_image", "storage_error", err.Error(), nil)
+	}
+
+	// The saved SVG is cleaned, so its size differs from the traced one
+	fileSize := int64(len(data))
+	if info, err := os.Stat(outputPath); err == nil {
+		fileSize = info.Size()
+	}
+	result := map[string]interface{}{
+		"id": id,
+		"paths": map[string]string{
+			"input_path": filePath,
+			"file_path":  outputPath,
+		},
+		"path_count":    paths,
+		"file_size":     fileSize,
+		"cost_estimate": 0.0,
+	}
+	if shareURL := h.files.URL(outputPath); shareURL != "" {
+		result["paths"].(map[string]string)["share_url"] = shareURL
+	}
+	message := fmt.Sprintf("Traced the image to SVG with %d colors: %s", opts.Colors, outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("vectorize_image", message, result))
+}
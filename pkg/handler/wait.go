@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+)
+
+// maxWaitLimit is the longest a call may ask to wait for its predictions
+const maxWaitLimit = 900
+
+// maxWaitSchema is the max_wait_seconds property added to the schema of every
+// tool that creates predictions
+var maxWaitSchema = fmt.Sprintf(`{
+	"type": "integer",
+	"description": "Seconds to wait for each prediction before timing out, overriding the usual limit (about 2 minutes for most models). Raise it for slow models such as imagen-4 or gen4-image when the client allows long calls. At most %d.",
+	"minimum": 1,
+	"maximum": %d
+}`, maxWaitLimit, maxWaitLimit)
+
+// withMaxWait returns ctx carrying the call's max_wait_seconds, if it set one.
+// Calls made inside another keep their caller's wait unless they set their own.
+func withMaxWait(ctx context.Context, req *protocol.CallToolRequest) (context.Context, error) {
+	seconds, ok := req.Arguments["max_wait_seconds"].(float64)
+	if !ok {
+		return ctx, nil
+	}
+	if seconds < 1 || seconds > maxWaitLimit {
+		return ctx, fmt.Errorf("max_wait_seconds must be between 1 and %d", maxWaitLimit)
+	}
+	return client.WithMaxWait(ctx, time.Duration(seconds*float64(time.Second))), nil
+}
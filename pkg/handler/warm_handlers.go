@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/warmup"
+)
+
+// handleWarmModel handles the warm_model tool: it boots a community model
+// with a minimal prediction so the next real call skips the cold start
+func (h *ReplicateImageHandler) handleWarmModel(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	name, ok := args["model"].(string)
+	if !ok || name == "" {
+		return h.errorResponse("warm_model", "invalid_parameters", "model parameter is required", nil)
+	}
+	modelID, known := models.ResolveAny(name)
+	if !known {
+		if !strings.Contains(name, "/") {
+			return h.errorResponse("warm_model", "invalid_parameters", fmt.Sprintf("unknown model %q", name), nil)
+		}
+		modelID = name // A custom Replicate model
+	}
+	input, _ := args["input"].(map[string]interface{})
+	wait := true
+	if w, ok := args["wait"].(bool); ok {
+		wait = w
+	}
+
+	if _, ok := warmup.Input(modelID); !ok && input == nil && h.warmer.Skip(modelID) == "" {
+		return h.errorResponse("warm_model", "invalid_parameters",
+			fmt.Sprintf("no warm-up input is known for %s; pass input with the model's required parameters", modelID), nil)
+	}
+
+	result, err := h.warmer.Warm(ctx, modelID, input, wait)
+	if err != nil {
+		return h.toolErrorResponse("warm_model", "processing_error", err)
+	}
+
+	data := map[string]interface{}{
+		"model":      result.Model,
+		"model_name": models.GetModelInfo(result.Model).Name,
+		"status":     result.Status,
+	}
+	var message string
+	switch result.Status {
+	case warmup.StatusSkipped:
+		data["reason"] = result.Reason
+		message = fmt.Sprintf("%s needs no warm-up: %s", result.Model, result.Reason)
+	case warmup.StatusStarted:
+		data["prediction_id"] = result.PredictionID
+		message = fmt.Sprintf("Started warming %s; it is usually ready within 30 to 90 seconds", result.Model)
+	default:
+		data["prediction_id"] = result.PredictionID
+		data["cold_start"] = result.ColdStart
+		data["startup_seconds"] = result.StartupTime
+		data["predict_time"] = result.PredictTime
+		data["total_cost"] = result.Cost
+		if result.Error != "" {
+			data["notes"] = []string{"the warm-up prediction failed after the model started, so the model is still warm: " + result.Error}
+		}
+		message = fmt.Sprintf("%s is warm", result.Model)
+		if result.ColdStart {
+			message = fmt.Sprintf("%s booted in %.0fs and is now warm", result.Model, result.StartupTime)
+		}
+	}
+	return h.successResponse(responses.BuildSimpleSuccessResponse("warm_model", message, data))
+}
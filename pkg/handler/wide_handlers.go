@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/mcp/pkg/protocol"
+	"github.com/gomcpgo/replicate_image_ai/pkg/editing"
+	"github.com/gomcpgo/replicate_image_ai/pkg/responses"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+)
+
+// Tiling of wide images
+const (
+	wideTileSize = 1024 // Side of the square tiles generated and outpainted
+	wideTileKeep = 3    // Each outpainted tile keeps 1/wideTileKeep of the one before as context
+	wideMinSide  = 256
+)
+
+// wideTile is a generated or outpainted tile of a wide image
+type wideTile struct {
+	ID       string  `json:"id"`
+	FilePath string  `json:"file_path"`
+	Cost     float64 `json:"cost"`
+}
+
+// handleGenerateWideImage handles the generate_wide_image tool: it generates
+// an image beyond the size and aspect ratio any model supports by generating
+// a square base tile, outpainting tile after tile from its right or bottom
+// edge, and stitching the tiles with their seams blended
+func (h *ReplicateImageHandler) handleGenerateWideImage(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	prompt, ok := args["prompt"].(string)
+	if !ok || prompt == "" {
+		return h.errorResponse("generate_wide_image", "invalid_parameters", "prompt parameter is required", nil)
+	}
+	width, _ := args["width"].(float64)
+	height, _ := args["height"].(float64)
+	if width < wideMinSide || height < wideMinSide {
+		return h.errorResponse("generate_wide_image", "invalid_parameters",
+			fmt.Sprintf("width and height are required (at least %d pixels)", wideMinSide), nil)
+	}
+	if width*height > storage.MaxPanoramaPixels {
+		return h.errorResponse("generate_wide_image", "invalid_parameters",
+			fmt.Sprintf("%.0fx%.0f is more than %d megapixels", width, height, storage.MaxPanoramaPixels/1_000_000), nil)
+	}
+
+	// Square tiles overlapping by a third cover the aspect ratio
+	vertical := height > width
+	ratio := max(width, height) / min(width, height)
+	keep := wideTileSize / wideTileKeep
+	step := float64(wideTileSize-keep) / wideTileSize
+	count := 1 + int(math.Ceil((ratio-1)/step-1e-9))
+	if count < 2 {
+		return h.errorResponse("generate_wide_image", "invalid_parameters",
+			"a square image needs no tiling; use generate_image", nil)
+	}
+	if count > storage.MaxPanoramaTiles {
+		return h.errorResponse("generate_wide_image", "invalid_parameters",
+			fmt.Sprintf("a %.1f:1 image would take %d tiles; at most %d are stitched", ratio, count, storage.MaxPanoramaTiles), nil)
+	}
+
+	editModel := "fill"
+	if m, ok := args["edit_model"].(string); ok && m != "" {
+		editModel = m
+	}
+	base := "wide"
+	if name, ok := args["filename"].(string); ok && name != "" {
+		base = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+
+	params := h.generateParams(prompt, args)
+	params.Width, params.Height = wideTileSize, wideTileSize
+	params.AspectRatio, params.SizePreset = "", ""
+	params.NumOutputs = 1
+	// Tiles are decoded for stitching, which WebP outputs cannot be
+	params.OutputFormat, params.OutputQuality = "png", 0
+	params.Filename = base + "_tile1"
+	generated, err := h.generator.GenerateImage(ctx, params)
+	if err != nil {
+		return h.toolErrorResponse("generate_wide_image", "generation_error", err)
+	}
+	tiles := []wideTile{{ID: generated.ID, FilePath: generated.FilePath, Cost: generated.Metrics.Cost}}
+	if generated.Cached {
+		tiles[0].Cost = 0
+	}
+
+	for i := 2; i <= count; i++ {
+		tile, err := h.outpaintTile(ctx, tiles[len(tiles)-1].FilePath, keep, vertical, editing.EditParams{
+			Prompt:   prompt,
+			Model:    editModel,
+			Seed:     params.Seed,
+			Filename: fmt.Sprintf("%s_tile%d.png", base, i),
+		})
+		if err != nil {
+			resp, respErr := h.toolErrorResponse("generate_wide_image", "editing_error", fmt.Errorf("tile %d of %d: %w", i, count, err))
+			return withResponseFields(resp, respErr, map[string]interface{}{"completed_tiles": tiles})
+		}
+		tiles = append(tiles, tile)
+	}
+
+	paths := make([]string, len(tiles))
+	totalCost := 0.0
+	for i, tile := range tiles {
+		paths[i] = tile.FilePath
+		totalCost += tile.Cost
+	}
+	pano, err := storage.StitchPanorama(paths, storage.PanoramaOptions{
+		Vertical: vertical,
+		Overlap:  float64(keep) / wideTileSize,
+		Width:    int(width),
+		Height:   int(height),
+	})
+	if err != nil {
+		resp, respErr := h.errorResponse("generate_wide_image", "processing_error", err.Error(), nil)
+		return withResponseFields(resp, respErr, map[string]interface{}{"completed_tiles": tiles})
+	}
+
+	parameters := map[string]interface{}{
+		"prompt":     prompt,
+		"model":      params.Model,
+		"edit_model": editModel,
+		"width":      int(width),
+		"height":     int(height),
+		"tiles":      len(tiles),
+	}
+	if params.Seed != 0 {
+		parameters["seed"] = params.Seed
+	}
+	id, outputPath, err := h.storage.SaveLocalResult(ctx, "generate_wide_image", parameters, base+".png", pano.Data)
+	if err != nil {
+		return h.errorResponse("generate_wide_image", "storage_error", err.Error(), nil)
+	}
+
+	result := map[string]interface{}{
+		"id":         id,
+		"file_path":  outputPath,
+		"width":      pano.Width,
+		"height":     pano.Height,
+		"tiles":      tiles,
+		"seams":      pano.Seams,
+		"total_cost": totalCost,
+	}
+	if url := h.files.URL(outputPath); url != "" {
+		result["share_url"] = url
+	}
+	var notes []string
+	if pano.Enlarged {
+		notes = append(notes, fmt.Sprintf("the tiles are %d pixels across, so the image was enlarged to %.0fx%.0f; upscale_image the tiles first for more detail", wideTileSize, width, height))
+	}
+	for i, seam := range pano.Seams {
+		if !seam.Matched {
+			notes = append(notes, fmt.Sprintf("tile %d was not continued cleanly from tile %d; regenerate or try another edit_model if the seam shows", i+2, i+1))
+		}
+	}
+	notes = append(notes, h.addContentCredentials(ctx, id)...)
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+	message := fmt.Sprintf("Generated a %dx%d image from %d tiles: %s", pano.Width, pano.Height, len(tiles), outputPath)
+	return h.successResponse(responses.BuildSimpleSuccessResponse("generate_wide_image", message, result))
+}
+
+// outpaintTile continues the strip of tiles ending with the tile at path by
+// one tile, keeping keep pixels of it as context, with a mask-based edit
+// model. The tile is stored as its own edit_image operation.
+func (h *ReplicateImageHandler) outpaintTile(ctx context.Context, path string, keep int, vertical bool, params editing.EditParams) (wideTile, error) {
+	canvas, mask, err := storage.OutpaintStrip(path, wideTileSize, wideTileSize, keep, vertical)
+	if err != nil {
+		return wideTile{}, err
+	}
+	canvasPath, err := writeTempPNG("wide-canvas-*.png", canvas)
+	if err != nil {
+		return wideTile{}, err
+	}
+	defer os.Remove(canvasPath)
+	maskPath, err := writeTempPNG("wide-mask-*.png", mask)
+	if err != nil {
+		return wideTile{}, err
+	}
+	defer os.Remove(maskPath)
+
+	params.ImagePath, params.MaskPath = canvasPath, maskPath
+	result, err := h.editor.EditImage(ctx, params)
+	if err != nil {
+		return wideTile{}, err
+	}
+	return wideTile{ID: result.ID, FilePath: result.OutputPath, Cost: result.Metrics.Cost}, nil
+}
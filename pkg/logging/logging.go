@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// dataURLPattern matches base64 data URLs so their payloads are never logged
+var dataURLPattern = regexp.MustCompile(`data:([\w.+/-]*);base64,[A-Za-z0-9+/=]+`)
+
+// promptKeys are the attribute and input keys whose values are prompts
+var promptKeys = map[string]bool{
+	"prompt":           true,
+	"negative_prompt":  true,
+	"original_prompt":  true,
+	"selection_prompt": true,
+	"prompt_b":         true,
+}
+
+// Setup installs the default structured logger. Logs go to stderr so they never
+// interfere with the MCP protocol on stdout. Every occurrence of the given
+// secrets is redacted from log output, as are data URL payloads, and prompts
+// too when redactPrompts is set.
+func Setup(level string, redactPrompts bool, secrets ...string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(NewHandler(os.Stderr, lvl, redactPrompts, secrets...)))
+	return nil
+}
+
+// NewHandler returns a text handler that redacts secrets and data URLs, and
+// prompts when redactPrompts is set
+func NewHandler(w io.Writer, level slog.Level, redactPrompts bool, secrets ...string) slog.Handler {
+	r := newRedactor(secrets)
+	return slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if redactPrompts {
+				if promptKeys[a.Key] {
+					return slog.String(a.Key, "[REDACTED]")
+				}
+				if a.Value.Kind() == slog.KindAny {
+					a.Value = slog.AnyValue(redactPromptValues(a.Value.Any()))
+				}
+			}
+			switch a.Value.Kind() {
+			case slog.KindString:
+				a.Value = slog.StringValue(r.redact(a.Value.String()))
+			case slog.KindAny:
+				// Maps, slices and errors may carry request bodies or tokens
+				a.Value = slog.StringValue(r.redact(fmt.Sprintf("%+v", a.Value.Any())))
+			}
+			return a
+		},
+	})
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a slog level
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q (use debug, info, warn, or error)", level)
+	}
+}
+
+// redactPromptValues copies maps and slices, replacing the values of prompt
+// keys, such as those of a prediction input
+func redactPromptValues(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			if promptKeys[k] {
+				out[k] = "[REDACTED]"
+			} else {
+				out[k] = redactPromptValues(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = redactPromptValues(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// redactor removes secrets and binary payloads from log text
+type redactor struct {
+	secrets []string
+}
+
+func newRedactor(secrets []string) *redactor {
+	r := &redactor{}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	return r
+}
+
+func (r *redactor) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	if !strings.Contains(s, "base64,") {
+		return s
+	}
+	return dataURLPattern.ReplaceAllStringFunc(s, func(match string) string {
+		idx := strings.Index(match, ",")
+		return fmt.Sprintf("%s<%d bytes>", match[:idx+1], len(match)-idx-1)
+	})
+}
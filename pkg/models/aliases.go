@@ -0,0 +1,215 @@
+package models
+
+import "sort"
+
+// Operations that resolve model aliases
+const (
+	OpGenerate         = "generate_image"
+	OpRemoveBackground = "remove_background"
+	OpUpscale          = "upscale_image"
+	OpEnhanceFace      = "enhance_face"
+	OpRestorePhoto     = "restore_photo"
+	OpColorize         = "colorize_image"
+	OpEstimateDepth    = "estimate_depth"
+	OpVectorize        = "vectorize_image"
+	OpCaption          = "caption_image"
+	OpDetect           = "detect_objects"
+	OpEditImage        = "edit_image"
+)
+
+// Operations lists every operation that resolves model aliases
+var Operations = []string{OpGenerate, OpRemoveBackground, OpUpscale, OpEnhanceFace, OpRestorePhoto, OpColorize, OpEstimateDepth, OpVectorize, OpCaption, OpDetect, OpEditImage}
+
+// aliasTable maps user-facing aliases to model IDs for one operation
+type aliasTable struct {
+	defaultModel string
+	aliases      map[string]string
+}
+
+// aliases holds the alias tables for every operation
+var aliases = map[string]aliasTable{
+	OpGenerate: {
+		defaultModel: ModelFluxSchnell,
+		aliases: map[string]string{
+			"flux-schnell":       ModelFluxSchnell,
+			"flux":               ModelFluxSchnell,
+			"schnell":            ModelFluxSchnell,
+			"flux-pro":           ModelFluxPro,
+			"pro":                ModelFluxPro,
+			"flux-dev":           ModelFluxDev,
+			"dev":                ModelFluxDev,
+			"imagen-4":           ModelImagen4,
+			"imagen":             ModelImagen4,
+			"imagen-4-fast":      ModelImagen4Fast,
+			"imagen-fast":        ModelImagen4Fast,
+			"imagen-4-ultra":     ModelImagen4Ultra,
+			"imagen-ultra":       ModelImagen4Ultra,
+			"gen4-image":         ModelGen4Image,
+			"gen4":               ModelGen4Image,
+			"runway":             ModelGen4Image,
+			"sdxl":               ModelSDXL,
+			"sdxl-lightning":     ModelSDXLLightning,
+			"lightning":          ModelSDXLLightning,
+			"ideogram":           ModelIdeogramTurbo,
+			"ideogram-turbo":     ModelIdeogramTurbo,
+			"recraft":            ModelRecraft,
+			"recraft-svg":        ModelRecraftSVG,
+			"seedream":           ModelSeedream3,
+			"seedream-3":         ModelSeedream3,
+			"seedream-4":         ModelSeedream4,
+			"sd3.5":              ModelSD35Large,
+			"sd3.5-large":        ModelSD35Large,
+			"sd3.5-large-turbo":  ModelSD35LargeTurbo,
+			"sd3.5-medium":       ModelSD35Medium,
+			"ultra":              ModelStableImageUltra,
+			"stable-image-ultra": ModelStableImageUltra,
+			"gpt-image-1":        ModelGPTImage1,
+			"gpt-image":          ModelGPTImage1,
+			"local":              ModelLocal,
+		},
+	},
+	OpRemoveBackground: {
+		defaultModel: ModelRemoveBG,
+		aliases: map[string]string{
+			"remove-bg": ModelRemoveBG,
+			"removebg":  ModelRemoveBG,
+			"rembg":     ModelRembg,
+			"dis":       ModelDISBGRemoval,
+		},
+	},
+	OpUpscale: {
+		defaultModel: ModelRealESRGAN,
+		aliases: map[string]string{
+			"realesrgan":             ModelRealESRGAN,
+			"real-esrgan":            ModelRealESRGAN,
+			"esrgan":                 ModelESRGAN,
+			"swinir":                 ModelSwinIR,
+			"clarity":                ModelClarityUpscaler,
+			"supir":                  ModelSUPIR,
+			"stability-fast":         ModelStabilityUpscaleFast,
+			"stability-conservative": ModelStabilityUpscaleConservative,
+			"local":                  ModelLocalUpscale,
+		},
+	},
+	OpEnhanceFace: {
+		defaultModel: ModelGFPGAN,
+		aliases: map[string]string{
+			"gfpgan":        ModelGFPGAN,
+			"codeformer":    ModelCodeFormer,
+			"restoreformer": ModelRestoreFormer,
+		},
+	},
+	OpRestorePhoto: {
+		defaultModel: ModelOldPhotoRestore,
+		aliases: map[string]string{
+			"bopbtl":     ModelOldPhotoRestore,
+			"gfpgan":     ModelGFPGAN,
+			"codeformer": ModelCodeFormer,
+		},
+	},
+	OpColorize: {
+		defaultModel: ModelDDColor,
+		aliases: map[string]string{
+			"ddcolor": ModelDDColor,
+		},
+	},
+	OpEstimateDepth: {
+		defaultModel: ModelDepthAnythingV2,
+		aliases: map[string]string{
+			"depth-anything": ModelDepthAnythingV2,
+		},
+	},
+	OpVectorize: {
+		defaultModel: ModelRecraftVectorize,
+		aliases: map[string]string{
+			"recraft":           ModelRecraftVectorize,
+			"recraft-vectorize": ModelRecraftVectorize,
+		},
+	},
+	OpCaption: {
+		defaultModel: ModelBLIP,
+		aliases: map[string]string{
+			"blip":  ModelBLIP,
+			"llava": ModelLLaVA13,
+		},
+	},
+	OpDetect: {
+		defaultModel: ModelGroundingDINO,
+		aliases: map[string]string{
+			"grounding-dino": ModelGroundingDINO,
+		},
+	},
+	OpEditImage: {
+		defaultModel: ModelFluxKontextPro,
+		aliases: map[string]string{
+			"pro":               ModelFluxKontextPro,
+			"kontext-pro":       ModelFluxKontextPro,
+			"flux-kontext-pro":  ModelFluxKontextPro,
+			"max":               ModelFluxKontextMax,
+			"kontext-max":       ModelFluxKontextMax,
+			"flux-kontext-max":  ModelFluxKontextMax,
+			"dev":               ModelFluxKontextDev,
+			"kontext-dev":       ModelFluxKontextDev,
+			"flux-kontext-dev":  ModelFluxKontextDev,
+			"inpaint":           ModelStabilityInpaint,
+			"stability-inpaint": ModelStabilityInpaint,
+			"fill":              ModelFluxFillPro,
+			"flux-fill-pro":     ModelFluxFillPro,
+			"gpt-image":         ModelGPTImage1,
+			"gpt-image-1":       ModelGPTImage1,
+			"local":             ModelLocalInpaint,
+		},
+	},
+}
+
+// Resolve returns the model ID for an alias within an operation, falling back
+// to the operation's default model for unknown aliases
+func Resolve(operation, alias string) string {
+	table, ok := aliases[operation]
+	if !ok {
+		return ""
+	}
+	if modelID, ok := table.aliases[alias]; ok {
+		return modelID
+	}
+	return table.defaultModel
+}
+
+// HasAlias reports whether an alias is defined for an operation
+func HasAlias(operation, alias string) bool {
+	_, ok := aliases[operation].aliases[alias]
+	return ok
+}
+
+// DefaultModel returns the default model ID for an operation
+func DefaultModel(operation string) string {
+	return aliases[operation].defaultModel
+}
+
+// Aliases returns the sorted aliases of each model an operation resolves,
+// keyed by model ID
+func Aliases(operation string) map[string][]string {
+	byModel := make(map[string][]string)
+	for alias, modelID := range aliases[operation].aliases {
+		byModel[modelID] = append(byModel[modelID], alias)
+	}
+	for _, list := range byModel {
+		sort.Strings(list)
+	}
+	return byModel
+}
+
+// ResolveAny returns the model ID for a full model ID or an alias from any
+// operation, checking operations in the order of Operations. It reports
+// false when the name is not recognized.
+func ResolveAny(name string) (string, bool) {
+	if IsKnown(name) {
+		return name, true
+	}
+	for _, operation := range Operations {
+		if modelID, ok := aliases[operation].aliases[name]; ok {
+			return modelID, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,553 @@
+package models
+
+import "sort"
+
+// Model IDs for Replicate models. This is the single source of truth for model
+// identifiers; every package resolves models through this registry.
+const (
+	// ============== GENERATION MODELS ==============
+
+	ModelFluxSchnell   = "black-forest-labs/flux-schnell" // Fast generation (default)
+	ModelFluxDev       = "black-forest-labs/flux-dev"     // Development version
+	ModelFluxPro       = "black-forest-labs/flux-1.1-pro" // High quality (paid)
+	ModelImagen4       = "google/imagen-4"                // Google's photorealistic image generation
+	ModelImagen4Fast   = "google/imagen-4-fast"           // Cheaper, faster Imagen-4 tier
+	ModelImagen4Ultra  = "google/imagen-4-ultra"          // Highest quality Imagen-4 tier
+	ModelGen4Image     = "runwayml/gen4-image"            // RunwayML Gen-4 with reference image support
+	ModelSDXL          = "stability-ai/sdxl:7762fd07cf82c948538e41f63f77d685e02b063e37e496e96eefd46c929f9bdc"
+	ModelSDXLLightning = "bytedance/sdxl-lightning-4step:6f7a773af6fc3e8de9d5a3c00be77c17308914bf67772726aff83496ba1e3bbe"
+	ModelSeedream3     = "bytedance/seedream-3"          // High quality
+	ModelSeedream4     = "bytedance/seedream-4"          // Up to 4K, with multi-image sequences
+	ModelIdeogramTurbo = "ideogram-ai/ideogram-v3-turbo" // Text in images
+	ModelRecraft       = "recraft-ai/recraft-v3"         // Raster images
+	ModelRecraftSVG    = "recraft-ai/recraft-v3-svg"     // SVG generation
+
+	ModelSD35Large        = "stability-ai/stable-diffusion-3.5-large"       // Stable Diffusion 3.5, highest quality
+	ModelSD35LargeTurbo   = "stability-ai/stable-diffusion-3.5-large-turbo" // Distilled SD 3.5 Large, few steps
+	ModelSD35Medium       = "stability-ai/stable-diffusion-3.5-medium"      // Smaller SD 3.5
+	ModelStableImageUltra = "stability-ai/stable-image-ultra"               // Stability API only
+
+	ModelGPTImage1 = "openai/gpt-image-1" // OpenAI API only; also used for edits
+
+	// ============== BACKGROUND REMOVAL ==============
+
+	ModelRemoveBG     = "lucataco/remove-bg:95fcc2a26d3899cd6c2691c900465aaeff466285a65c14638cc5f36f34befaf1"
+	ModelRembg        = "cjwbw/rembg:fb8af171cfa1616ddcf1242c093f9c46bcada5ad4cf6f2fbe8b81b330ec5c003"
+	ModelDISBGRemoval = "lucataco/dis-background-removal:5b67a0da2b417b71066754fa8550d668a12edccfe3f32de062f7e20ac0b5d55e"
+
+	// ============== UPSCALING ==============
+
+	ModelRealESRGAN      = "nightmareai/real-esrgan:f121d640bd286e1fdc67f9799164c1d5be36ff74576ee11c803ae5b665dd46aa"
+	ModelESRGAN          = "mv-lab/esrgan:7c2e97f640b7e199d5bb86d17dc4d1d6e317c0c45e1f6ac1c827e87b3c5b7c96"
+	ModelSwinIR          = "jingyunliang/swinir:660d922d33153019e8c263a3bba265de882e7f4f70396546b6c9c8f9d47a021a"
+	ModelClarityUpscaler = "philz1337x/clarity-upscaler:dfad41707589d68ecdccd1dfa600d55a208f9310748e44bfe35b4a6291453d5e"
+	ModelSUPIR           = "cjwbw/supir-v0q:ede69f6a5ae7d09f769d683347325b08d2f83a93d136ed89747941205e0a71da"
+
+	// Stability API only
+	ModelStabilityUpscaleFast         = "stability-ai/upscale-fast"
+	ModelStabilityUpscaleConservative = "stability-ai/upscale-conservative"
+
+	// ============== FACE ENHANCEMENT ==============
+
+	ModelGFPGAN        = "tencentarc/gfpgan:297a243ce8643961d52f745f9b6c8c1bd96850a51c92be5f43628a0d3e08321a"
+	ModelCodeFormer    = "sczhou/codeformer:cc4956dd26fa5a7185d5660cc9100fab1b8070a1d1654a8bb5eb6d443b020bb2"
+	ModelRestoreFormer = "jingyunliang/restoreformer:65b8e87b48cbdc7e5e91703c8e18b5d2e4f20dcbc49f3c45cdba5e4c481e973c"
+
+	// ============== PHOTO RESTORATION ==============
+
+	ModelOldPhotoRestore = "microsoft/bringing-old-photos-back-to-life:c75db81db6cbd809d93cc3b7e7a088a351a3349c9fa02b6d393e35e0d51ba799"
+
+	// ============== COLORIZATION ==============
+
+	ModelDDColor = "piddnad/ddcolor:ca494ba129e44e45f661d6ece83c4c98a9a7c774309beca01429b58fce8aa695"
+
+	// ============== DEPTH ESTIMATION ==============
+
+	ModelDepthAnythingV2 = "chenxwh/depth-anything-v2:b239ea33cff32bb7abb5db39ffe9a09c14cbc2894331d1ef66fe096eed88ebd4"
+
+	// ============== VECTORIZATION ==============
+
+	ModelRecraftVectorize = "recraft-ai/recraft-vectorize" // Raster to SVG
+
+	// ============== CAPTIONING ==============
+
+	ModelBLIP    = "salesforce/blip:2e1dddc8621f72155f24cf2e0adbde548458d3cab9f00c0139eea840d0ac4746"
+	ModelLLaVA13 = "yorickvp/llava-13b:80537f9eead1a5bfa72d5ac6ea6414379be41d4d4f6679fd776e9535d1eb58bb"
+
+	// ============== OBJECT DETECTION ==============
+
+	ModelGroundingDINO = "adirik/grounding-dino:efd10a8ddc57ea28773327e881ce95e20cc1d734c589f7dd01d2036921ed78aa" // Open-vocabulary boxes from a text query
+
+	// ============== MODERATION ==============
+
+	ModelNSFWDetection = "falcons-ai/nsfw_image_detection:97116600cabd3037e5f22ca08ffcc33b92cfacebf7ccd3609e9c1d29e43d3a8d" // Labels images normal or nsfw
+
+	// ============== LANGUAGE ==============
+
+	ModelLlama3Instruct = "meta/meta-llama-3-8b-instruct" // Translates prompts to English
+
+	// ============== IMAGE EDITING ==============
+
+	ModelInpainting       = "stability-ai/stable-diffusion-inpainting:95b7223104132402a9ae91cc677285bc5eb997834bd2349fa486f53910fd68b3"
+	ModelStabilityInpaint = "stability-ai/inpaint"            // Stability API only
+	ModelFluxFillPro      = "black-forest-labs/flux-fill-pro" // Mask-based inpainting and outpainting
+
+	// FLUX Kontext text-based editing (no masks)
+	ModelFluxKontextPro = "black-forest-labs/flux-kontext-pro" // Balanced speed/quality (recommended default)
+	ModelFluxKontextMax = "black-forest-labs/flux-kontext-max" // Highest quality, premium tier
+	ModelFluxKontextDev = "black-forest-labs/flux-kontext-dev" // Advanced controls, more parameters
+
+	// ============== LOCAL BACKEND ==============
+
+	// Served by a local ComfyUI or Automatic1111 server with whatever checkpoint it has loaded
+	ModelLocal        = "local/txt2img"
+	ModelLocalUpscale = "local/upscale"
+	ModelLocalInpaint = "local/inpaint"
+)
+
+// Model categories
+const (
+	CategoryGeneration        = "generation"
+	CategoryBackgroundRemoval = "background-removal"
+	CategoryUpscaling         = "upscaling"
+	CategoryFaceEnhancement   = "face-enhancement"
+	CategoryPhotoRestoration  = "photo-restoration"
+	CategoryColorization      = "colorization"
+	CategoryDepthEstimation   = "depth-estimation"
+	CategoryVectorization     = "vectorization"
+	CategoryCaptioning        = "captioning"
+	CategoryDetection         = "object-detection"
+	CategoryModeration        = "moderation"
+	CategoryLanguage          = "language"
+	CategoryEditing           = "text-edit"
+	CategoryUnknown           = "unknown"
+)
+
+// Providers that exclusively serve some models
+const (
+	ProviderStability = "stability"
+	ProviderOpenAI    = "openai"
+	ProviderLocal     = "local"
+)
+
+// ModelInfo contains information about a model
+type ModelInfo struct {
+	ID          string
+	Name        string
+	Description string
+	Category    string
+	Features    []string
+	Provider    string // Only provider serving the model; empty when Replicate serves it
+	Deprecated  bool   // Predictions go to ReplacedBy instead, with a warning
+	ReplacedBy  string // Successor used when the model is deprecated or no longer exists
+	InputEdge   int    // Longest input image edge the model takes; larger inputs are downscaled. Zero takes any size.
+}
+
+// DefaultInputEdge is the input edge limit of models that take images but
+// gain nothing from sending them larger, such as editing, background
+// removal, and captioning models. Upscalers and restoration models take
+// their inputs at full size.
+const DefaultInputEdge = 2048
+
+// registry holds information about every known model
+var registry = map[string]ModelInfo{
+	// Generation models
+	ModelFluxSchnell: {
+		Name:        "FLUX Schnell",
+		Description: "Fast, high-quality image generation",
+		Category:    CategoryGeneration,
+		Features:    []string{"fast", "high-quality", "versatile"},
+	},
+	ModelFluxPro: {
+		Name:        "FLUX Pro",
+		Description: "Professional-grade image generation with advanced controls",
+		Category:    CategoryGeneration,
+		Features:    []string{"professional", "advanced-controls", "high-resolution"},
+	},
+	ModelFluxDev: {
+		Name:        "FLUX Dev",
+		Description: "Development version with experimental features",
+		Category:    CategoryGeneration,
+		Features:    []string{"experimental", "cutting-edge"},
+	},
+	ModelImagen4: {
+		Name:        "Google Imagen-4",
+		Description: "Photorealistic image generation with aspect ratio control",
+		Category:    CategoryGeneration,
+		Features:    []string{"photorealistic", "aspect-ratio", "safety-filter"},
+	},
+	ModelImagen4Fast: {
+		Name:        "Google Imagen-4 Fast",
+		Description: "Faster, cheaper Imagen-4 tier for drafts and volume",
+		Category:    CategoryGeneration,
+		Features:    []string{"photorealistic", "fast", "aspect-ratio", "safety-filter"},
+	},
+	ModelImagen4Ultra: {
+		Name:        "Google Imagen-4 Ultra",
+		Description: "Imagen-4's highest quality tier with the closest prompt adherence",
+		Category:    CategoryGeneration,
+		Features:    []string{"photorealistic", "premium", "aspect-ratio", "safety-filter"},
+	},
+	ModelGen4Image: {
+		Name:        "RunwayML Gen-4",
+		Description: "Advanced generation with visual context and reference images",
+		Category:    CategoryGeneration,
+		Features:    []string{"reference-images", "visual-context", "style-transfer"},
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelSDXL: {
+		Name:        "Stable Diffusion XL",
+		Description: "High-resolution image generation with fine control",
+		Category:    CategoryGeneration,
+		Features:    []string{"high-resolution", "fine-control", "negative-prompt"},
+	},
+	ModelSDXLLightning: {
+		Name:        "SDXL Lightning",
+		Description: "Ultra-fast 4-step SDXL generation",
+		Category:    CategoryGeneration,
+		Features:    []string{"ultra-fast", "4-step", "efficient"},
+	},
+	ModelIdeogramTurbo: {
+		Name:        "Ideogram Turbo",
+		Description: "Fast generation with excellent text rendering",
+		Category:    CategoryGeneration,
+		Features:    []string{"text-rendering", "fast", "creative"},
+	},
+	ModelRecraft: {
+		Name:        "Recraft V3",
+		Description: "Design-focused generation for professional graphics",
+		Category:    CategoryGeneration,
+		Features:    []string{"design", "professional", "graphics"},
+	},
+	ModelRecraftSVG: {
+		Name:        "Recraft V3 SVG",
+		Description: "Vector graphics generation in SVG format",
+		Category:    CategoryGeneration,
+		Features:    []string{"vector", "svg", "scalable"},
+	},
+	ModelSeedream3: {
+		Name:        "Seedream 3",
+		Description: "Artistic and creative image generation",
+		Category:    CategoryGeneration,
+		Features:    []string{"artistic", "creative", "stylized"},
+	},
+	ModelSeedream4: {
+		Name:        "Seedream 4",
+		Description: "ByteDance's generation up to 4K, returning sequences of related images on request",
+		Category:    CategoryGeneration,
+		Features:    []string{"high-resolution", "multi-image", "creative"},
+	},
+	ModelSD35Large: {
+		Name:        "Stable Diffusion 3.5 Large",
+		Description: "Stability's highest quality open model with strong prompt adherence",
+		Category:    CategoryGeneration,
+		Features:    []string{"high-quality", "prompt-adherence", "negative-prompt"},
+	},
+	ModelSD35LargeTurbo: {
+		Name:        "Stable Diffusion 3.5 Large Turbo",
+		Description: "Distilled SD 3.5 Large that generates in a few steps",
+		Category:    CategoryGeneration,
+		Features:    []string{"fast", "few-step", "negative-prompt"},
+	},
+	ModelSD35Medium: {
+		Name:        "Stable Diffusion 3.5 Medium",
+		Description: "Smaller, cheaper SD 3.5 model",
+		Category:    CategoryGeneration,
+		Features:    []string{"efficient", "negative-prompt"},
+	},
+	ModelStableImageUltra: {
+		Name:        "Stable Image Ultra",
+		Description: "Stability's flagship photorealistic generation (Stability API)",
+		Category:    CategoryGeneration,
+		Features:    []string{"photorealistic", "premium", "negative-prompt"},
+		Provider:    ProviderStability,
+	},
+	ModelGPTImage1: {
+		Name:        "GPT Image 1",
+		Description: "OpenAI's natively multimodal generation with strong instruction following and text rendering (OpenAI API)",
+		Category:    CategoryGeneration,
+		Features:    []string{"instruction-following", "text-rendering", "text-based", "transparent-background"},
+		Provider:    ProviderOpenAI,
+		InputEdge:   DefaultInputEdge,
+	},
+
+	// Background removal models
+	ModelRemoveBG: {
+		Name:        "Remove BG",
+		Description: "Fast and accurate background removal",
+		Category:    CategoryBackgroundRemoval,
+		Features:    []string{"fast", "accurate", "preserves-edges"},
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelRembg: {
+		Name:        "Rembg",
+		Description: "Robust background removal with U2-Net",
+		Category:    CategoryBackgroundRemoval,
+		Features:    []string{"robust", "u2-net", "high-quality"},
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelDISBGRemoval: {
+		Name:        "DIS Background Removal",
+		Description: "Advanced background removal with DIS model",
+		Category:    CategoryBackgroundRemoval,
+		Features:    []string{"advanced", "dis-model", "detailed"},
+		InputEdge:   DefaultInputEdge,
+	},
+
+	// Upscaling models
+	ModelRealESRGAN: {
+		Name:        "Real-ESRGAN",
+		Description: "High-quality image upscaling with face enhancement",
+		Category:    CategoryUpscaling,
+		Features:    []string{"high-quality", "face-enhancement", "4x-upscale"},
+	},
+	ModelESRGAN: {
+		Name:        "ESRGAN",
+		Description: "Enhanced Super-Resolution GAN for image upscaling",
+		Category:    CategoryUpscaling,
+		Features:    []string{"super-resolution", "gan", "detailed"},
+	},
+	ModelSwinIR: {
+		Name:        "SwinIR",
+		Description: "Transformer-based image restoration and upscaling",
+		Category:    CategoryUpscaling,
+		Features:    []string{"transformer", "restoration", "flexible-scale"},
+	},
+	ModelClarityUpscaler: {
+		Name:        "Clarity Upscaler",
+		Description: "Diffusion-based upscaling that adds fine detail",
+		Category:    CategoryUpscaling,
+		Features:    []string{"diffusion", "detail-enhancement", "creative"},
+	},
+	ModelSUPIR: {
+		Name:        "SUPIR",
+		Description: "Diffusion-based restoration and upscaling for degraded photos",
+		Category:    CategoryUpscaling,
+		Features:    []string{"diffusion", "restoration", "photo-realistic"},
+	},
+	ModelStabilityUpscaleFast: {
+		Name:        "Stability Fast Upscaler",
+		Description: "Fast 4x upscaling (Stability API)",
+		Category:    CategoryUpscaling,
+		Features:    []string{"fast", "4x-upscale"},
+		Provider:    ProviderStability,
+	},
+	ModelStabilityUpscaleConservative: {
+		Name:        "Stability Conservative Upscaler",
+		Description: "Upscaling to 4 megapixels with minimal changes to the image (Stability API)",
+		Category:    CategoryUpscaling,
+		Features:    []string{"faithful", "4-megapixel", "detail-enhancement"},
+		Provider:    ProviderStability,
+	},
+
+	// Face enhancement models
+	ModelGFPGAN: {
+		Name:        "GFPGAN",
+		Description: "Face restoration with generative facial prior",
+		Category:    CategoryFaceEnhancement,
+		Features:    []string{"face-restoration", "generative", "high-fidelity"},
+	},
+	ModelCodeFormer: {
+		Name:        "CodeFormer",
+		Description: "Robust face restoration via discrete code modeling",
+		Category:    CategoryFaceEnhancement,
+		Features:    []string{"robust", "code-modeling", "versatile"},
+	},
+	ModelRestoreFormer: {
+		Name:        "RestoreFormer",
+		Description: "High-quality blind face restoration",
+		Category:    CategoryFaceEnhancement,
+		Features:    []string{"blind-restoration", "high-quality", "natural"},
+	},
+
+	// Photo restoration models
+	ModelOldPhotoRestore: {
+		Name:        "Old Photo Restoration",
+		Description: "Bringing old photos back to life",
+		Category:    CategoryPhotoRestoration,
+		Features:    []string{"old-photos", "restoration", "scratch-removal"},
+	},
+
+	// Colorization models
+	ModelDDColor: {
+		Name:        "DDColor",
+		Description: "Photo-realistic colorization of black and white photos",
+		Category:    CategoryColorization,
+		Features:    []string{"colorization", "old-photos", "photo-realistic"},
+	},
+
+	// Depth estimation models
+	ModelDepthAnythingV2: {
+		Name:        "Depth Anything V2",
+		Description: "Monocular depth estimation from a single image",
+		Category:    CategoryDepthEstimation,
+		Features:    []string{"depth-map", "monocular"},
+		InputEdge:   DefaultInputEdge,
+	},
+
+	// Vectorization models
+	ModelRecraftVectorize: {
+		Name:        "Recraft Vectorize",
+		Description: "Converts raster logos, icons, and illustrations to SVG",
+		Category:    CategoryVectorization,
+		Features:    []string{"vector", "svg", "scalable"},
+		InputEdge:   DefaultInputEdge,
+	},
+
+	// Captioning models
+	ModelBLIP: {
+		Name:        "BLIP",
+		Description: "Short, literal image captions",
+		Category:    CategoryCaptioning,
+		Features:    []string{"captioning", "fast"},
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelLLaVA13: {
+		Name:        "LLaVA 13B",
+		Description: "Detailed captions guided by an instruction prompt",
+		Category:    CategoryCaptioning,
+		Features:    []string{"captioning", "detailed", "prompted"},
+		InputEdge:   DefaultInputEdge,
+	},
+
+	// Object detection models
+	ModelGroundingDINO: {
+		Name:        "Grounding DINO",
+		Description: "Finds objects named in a text query and returns their bounding boxes",
+		Category:    CategoryDetection,
+		Features:    []string{"detection", "open-vocabulary", "bounding-boxes"},
+		InputEdge:   DefaultInputEdge,
+	},
+
+	// Moderation models
+	ModelNSFWDetection: {
+		Name:        "NSFW Image Detection",
+		Description: "Classifies images as safe or not safe for work",
+		Category:    CategoryModeration,
+		Features:    []string{"moderation", "nsfw", "classification"},
+	},
+
+	// Language models
+	ModelLlama3Instruct: {
+		Name:        "Llama 3 8B Instruct",
+		Description: "Small instruction-tuned language model, used to translate prompts",
+		Category:    CategoryLanguage,
+		Features:    []string{"text", "translation"},
+	},
+
+	// Editing models
+	ModelInpainting: {
+		Name:        "SD Inpainting",
+		Description: "Mask-based inpainting with Stable Diffusion",
+		Category:    CategoryEditing,
+		Features:    []string{"inpainting", "mask-based"},
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelStabilityInpaint: {
+		Name:        "Stability Inpaint",
+		Description: "Mask-based inpainting (Stability API)",
+		Category:    CategoryEditing,
+		Features:    []string{"inpainting", "mask-based"},
+		Provider:    ProviderStability,
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelFluxFillPro: {
+		Name:        "FLUX Fill Pro",
+		Description: "Mask-based inpainting and outpainting with FLUX",
+		Category:    CategoryEditing,
+		Features:    []string{"inpainting", "outpainting", "mask-based"},
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelFluxKontextPro: {
+		Name:        "FLUX Kontext Pro",
+		Description: "Professional text-based image editing with balanced speed and quality",
+		Category:    CategoryEditing,
+		Features:    []string{"balanced", "professional", "text-based", "fast"},
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelFluxKontextMax: {
+		Name:        "FLUX Kontext Max",
+		Description: "Maximum quality text-based image editing, premium tier",
+		Category:    CategoryEditing,
+		Features:    []string{"highest-quality", "premium", "text-based", "detailed"},
+		InputEdge:   DefaultInputEdge,
+	},
+	ModelFluxKontextDev: {
+		Name:        "FLUX Kontext Dev",
+		Description: "Development version with advanced controls for text-based editing",
+		Category:    CategoryEditing,
+		Features:    []string{"advanced-controls", "experimental", "text-based", "flexible"},
+		InputEdge:   DefaultInputEdge,
+	},
+
+	// Local backend
+	ModelLocal: {
+		Name:        "Local",
+		Description: "The checkpoint loaded in a local ComfyUI or Automatic1111 server",
+		Category:    CategoryGeneration,
+		Features:    []string{"local", "free", "negative-prompt"},
+		Provider:    ProviderLocal,
+	},
+	ModelLocalUpscale: {
+		Name:        "Local Upscaler",
+		Description: "An upscaler on a local Automatic1111 server",
+		Category:    CategoryUpscaling,
+		Features:    []string{"local", "free"},
+		Provider:    ProviderLocal,
+	},
+	ModelLocalInpaint: {
+		Name:        "Local Inpaint",
+		Description: "Mask-based inpainting on a local Automatic1111 server",
+		Category:    CategoryEditing,
+		Features:    []string{"inpainting", "mask-based", "local", "free"},
+		Provider:    ProviderLocal,
+		InputEdge:   DefaultInputEdge,
+	},
+}
+
+// GetModelInfo returns information about a model
+func GetModelInfo(modelID string) ModelInfo {
+	if info, ok := registry[modelID]; ok {
+		info.ID = modelID
+		return info
+	}
+
+	// Return basic info for unknown models
+	return ModelInfo{
+		ID:       modelID,
+		Name:     "Unknown Model",
+		Category: CategoryUnknown,
+	}
+}
+
+// IsKnown reports whether a model ID is in the registry
+func IsKnown(modelID string) bool {
+	_, ok := registry[modelID]
+	return ok
+}
+
+// All returns information about every registered model, sorted by ID
+func All() []ModelInfo {
+	all := make([]ModelInfo, 0, len(registry))
+	for id := range registry {
+		all = append(all, GetModelInfo(id))
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all
+}
+
+// InputEdge returns the longest input image edge a model takes, or zero
+// when it takes any size
+func InputEdge(modelID string) int {
+	return GetModelInfo(modelID).InputEdge
+}
+
+// RequiresMask reports whether a model edits only the region given by a mask
+func RequiresMask(modelID string) bool {
+	for _, feature := range GetModelInfo(modelID).Features {
+		if feature == "mask-based" {
+			return true
+		}
+	}
+	return false
+}
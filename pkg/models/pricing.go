@@ -0,0 +1,160 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// Cost bases reported alongside computed costs
+const (
+	CostBasisPerOutput = "per_output" // Official models billed per generated image
+	CostBasisPerSecond = "per_second" // Community models billed by hardware time
+	CostBasisUnpriced  = "unpriced"   // Model is not in the pricing table
+	CostBasisLocal     = "local"      // Run on the user's own hardware
+)
+
+// Hardware tiers and their price in USD per second of predict time
+const (
+	HardwareCPU      = "cpu"
+	HardwareT4       = "gpu-t4"
+	HardwareA40Large = "gpu-a40-large"
+	HardwareA100     = "gpu-a100-large"
+	HardwareL40S     = "gpu-l40s"
+)
+
+var hardwarePricePerSecond = map[string]float64{
+	HardwareCPU:      0.000100,
+	HardwareT4:       0.000225,
+	HardwareA40Large: 0.000725,
+	HardwareA100:     0.001400,
+	HardwareL40S:     0.000975,
+}
+
+// Pricing describes how a model is billed. Exactly one of PerOutput or
+// Hardware is set.
+type Pricing struct {
+	PerOutput float64 // USD per output image
+	Hardware  string  // Hardware tier for time-billed models
+}
+
+// pricingTable is a snapshot of Replicate's published prices; update it when
+// prices change
+var pricingTable = map[string]Pricing{
+	// Official models (per output image)
+	ModelFluxSchnell:    {PerOutput: 0.003},
+	ModelFluxDev:        {PerOutput: 0.025},
+	ModelFluxPro:        {PerOutput: 0.04},
+	ModelImagen4:        {PerOutput: 0.04},
+	ModelImagen4Fast:    {PerOutput: 0.02},
+	ModelImagen4Ultra:   {PerOutput: 0.06},
+	ModelGen4Image:      {PerOutput: 0.05},
+	ModelSeedream3:      {PerOutput: 0.03},
+	ModelSeedream4:      {PerOutput: 0.03},
+	ModelIdeogramTurbo:  {PerOutput: 0.03},
+	ModelRecraft:        {PerOutput: 0.04},
+	ModelRecraftSVG:     {PerOutput: 0.08},
+	ModelFluxKontextPro: {PerOutput: 0.04},
+	ModelFluxKontextMax: {PerOutput: 0.08},
+	ModelFluxKontextDev: {PerOutput: 0.025},
+	ModelFluxFillPro:    {PerOutput: 0.05},
+	ModelSD35Large:      {PerOutput: 0.065},
+	ModelSD35LargeTurbo: {PerOutput: 0.04},
+	ModelSD35Medium:     {PerOutput: 0.035},
+
+	ModelRecraftVectorize: {PerOutput: 0.01},
+
+	// Billed per token; a prompt translation is a few hundred tokens
+	ModelLlama3Instruct: {PerOutput: 0.0001},
+
+	// Community models (per second of hardware time)
+	ModelSDXL:            {Hardware: HardwareA40Large},
+	ModelSDXLLightning:   {Hardware: HardwareA40Large},
+	ModelRemoveBG:        {Hardware: HardwareT4},
+	ModelRembg:           {Hardware: HardwareT4},
+	ModelDISBGRemoval:    {Hardware: HardwareT4},
+	ModelRealESRGAN:      {Hardware: HardwareT4},
+	ModelESRGAN:          {Hardware: HardwareT4},
+	ModelSwinIR:          {Hardware: HardwareT4},
+	ModelClarityUpscaler: {Hardware: HardwareA100},
+	ModelSUPIR:           {Hardware: HardwareA100},
+	ModelGFPGAN:          {Hardware: HardwareT4},
+	ModelCodeFormer:      {Hardware: HardwareT4},
+	ModelRestoreFormer:   {Hardware: HardwareT4},
+	ModelOldPhotoRestore: {Hardware: HardwareT4},
+	ModelDDColor:         {Hardware: HardwareA40Large},
+	ModelDepthAnythingV2: {Hardware: HardwareL40S},
+	ModelBLIP:            {Hardware: HardwareT4},
+	ModelLLaVA13:         {Hardware: HardwareA40Large},
+	ModelGroundingDINO:   {Hardware: HardwareT4},
+	ModelNSFWDetection:   {Hardware: HardwareT4},
+	ModelInpainting:      {Hardware: HardwareA40Large},
+}
+
+// providerPricingTable holds prices for models served by providers other than
+// Replicate, keyed by provider name. fal.ai bills FLUX per megapixel; prices
+// here assume the default ~1 megapixel output. Models missing from a provider's
+// table are reported as unpriced.
+var providerPricingTable = map[string]map[string]Pricing{
+	"fal": {
+		ModelFluxSchnell: {PerOutput: 0.003},
+		ModelFluxDev:     {PerOutput: 0.025},
+		ModelFluxPro:     {PerOutput: 0.04},
+	},
+	// Stability bills credits at $0.01 each
+	ProviderStability: {
+		ModelSD35Large:                    {PerOutput: 0.065},
+		ModelSD35LargeTurbo:               {PerOutput: 0.04},
+		ModelSD35Medium:                   {PerOutput: 0.035},
+		ModelStableImageUltra:             {PerOutput: 0.08},
+		ModelStabilityUpscaleFast:         {PerOutput: 0.02},
+		ModelStabilityUpscaleConservative: {PerOutput: 0.40},
+		ModelStabilityInpaint:             {PerOutput: 0.03},
+	},
+	// OpenAI bills image tokens; this is a medium-quality 1024x1024 image
+	ProviderOpenAI: {
+		ModelGPTImage1: {PerOutput: 0.042},
+	},
+}
+
+// GetPricing returns the pricing for a model and whether it is known
+func GetPricing(modelID string) (Pricing, bool) {
+	p, ok := pricingTable[modelID]
+	return p, ok
+}
+
+// ActualCost computes the cost in USD of a completed prediction from its
+// predict time and number of outputs, returning the cost basis used. An empty
+// provider, or "replicate", uses Replicate's prices.
+func ActualCost(provider, modelID string, predictTime float64, outputs int) (float64, string) {
+	if provider == ProviderLocal {
+		return 0, CostBasisLocal
+	}
+
+	table := pricingTable
+	if provider != "" && provider != "replicate" {
+		table = providerPricingTable[provider]
+	}
+
+	p, ok := table[modelID]
+	if !ok {
+		return 0, CostBasisUnpriced
+	}
+
+	if p.PerOutput > 0 {
+		if outputs < 1 {
+			outputs = 1
+		}
+		return p.PerOutput * float64(outputs), CostBasisPerOutput
+	}
+
+	return hardwarePricePerSecond[p.Hardware] * predictTime, fmt.Sprintf("%s:%s", CostBasisPerSecond, p.Hardware)
+}
+
+// SetActualCost records a completed prediction's predict time, provider and
+// actual cost, for outputs images of modelID, on an operation's result
+func SetActualCost(result *types.OperationResult, prediction *types.ReplicatePredictionResponse, modelID string, outputs int) {
+	result.PredictTime = prediction.PredictTime()
+	result.Provider = prediction.Provider
+	result.CostEstimate, result.CostBasis = ActualCost(prediction.Provider, modelID, result.PredictTime, outputs)
+}
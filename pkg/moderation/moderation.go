@@ -0,0 +1,253 @@
+// Package moderation screens generated images with an NSFW classifier before
+// they are written to storage.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// Actions taken on flagged outputs
+const (
+	ActionBlock      = "block"      // Discard flagged outputs
+	ActionQuarantine = "quarantine" // Save flagged outputs to the quarantine folder only
+	ActionTag        = "tag"        // Save flagged outputs normally and mark them in metadata
+)
+
+// Error codes returned when every output of an operation is held back
+const (
+	ErrCodeBlocked     = client.ErrCodeNSFW
+	ErrCodeQuarantined = "content_quarantined"
+)
+
+// Screener classifies outputs and applies the configured action to flagged
+// ones. A nil Screener passes every output through unscreened.
+type Screener struct {
+	client   client.Predictor
+	storage  *storage.Storage
+	action   string
+	timeouts client.Timeouts
+}
+
+// New returns a Screener applying action to flagged outputs, or nil when
+// action is empty
+func New(predictor client.Predictor, store *storage.Storage, action string) (*Screener, error) {
+	return NewWithTimeouts(predictor, store, action, client.Timeouts{})
+}
+
+// NewWithTimeouts returns a Screener like New, polling its classifier
+// predictions with timeouts instead of the defaults
+func NewWithTimeouts(predictor client.Predictor, store *storage.Storage, action string, timeouts client.Timeouts) (*Screener, error) {
+	switch action {
+	case "":
+		return nil, nil
+	case ActionBlock, ActionQuarantine, ActionTag:
+	default:
+		return nil, fmt.Errorf("unknown moderation action %q (use block, quarantine, or tag)", action)
+	}
+	return &Screener{client: predictor, storage: store, action: action, timeouts: timeouts}, nil
+}
+
+// Verdict is the classification of one output
+type Verdict struct {
+	Index   int    // Position among the operation's outputs
+	Label   string // Classifier label, e.g. normal or nsfw
+	Flagged bool
+	Err     error
+}
+
+// Outcome is the result of screening an operation's outputs
+type Outcome struct {
+	Action      string
+	Keep        []string  // Output URLs to save normally
+	kept        []int     // Positions of Keep among the screened outputs
+	Flagged     []Verdict // Outputs the classifier flagged
+	Quarantined []string  // Paths of outputs saved to the quarantine folder
+	Cost        float64   // Classifier cost in USD
+}
+
+// Screen classifies every output URL of operation id and applies the action
+// to flagged ones. With the quarantine action, flagged outputs are downloaded
+// into the quarantine folder under filename. Unless the action is tag, an
+// output the classifier fails on is treated as an error rather than saved
+// unscreened.
+func (s *Screener) Screen(ctx context.Context, id string, urls []string, filename string) (*Outcome, error) {
+	if s == nil {
+		return &Outcome{Keep: urls}, nil
+	}
+
+	verdicts := make([]Verdict, len(urls))
+	costs := make([]float64, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			verdicts[i] = Verdict{Index: i}
+			verdicts[i].Label, costs[i], verdicts[i].Err = s.classify(ctx, url)
+			verdicts[i].Flagged = verdicts[i].Label != "" && verdicts[i].Label != "normal"
+		}(i, url)
+	}
+	wg.Wait()
+
+	outcome := &Outcome{Action: s.action}
+	var held []string
+	for i, verdict := range verdicts {
+		outcome.Cost += costs[i]
+		if verdict.Err != nil && s.action != ActionTag {
+			return nil, fmt.Errorf("output moderation failed for output %d: %w", i+1, verdict.Err)
+		}
+		if verdict.Flagged {
+			outcome.Flagged = append(outcome.Flagged, verdict)
+			if s.action != ActionTag {
+				held = append(held, urls[i])
+				continue
+			}
+		}
+		outcome.Keep = append(outcome.Keep, urls[i])
+		outcome.kept = append(outcome.kept, i)
+	}
+
+	if len(held) > 0 && s.action == ActionQuarantine {
+		saved, err := s.storage.SaveQuarantined(id, held, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quarantine flagged output: %w", err)
+		}
+		outcome.Quarantined = storage.Paths(saved)
+	}
+	if len(outcome.Flagged) > 0 {
+		slog.Warn("moderation flagged outputs", "storage_id", id, "flagged", len(outcome.Flagged), "outputs", len(urls), "action", s.action)
+	}
+	return outcome, nil
+}
+
+// Refresher narrows a function that re-fetches an operation's output URLs
+// down to the outputs that were kept
+func (o *Outcome) Refresher(refresh func() ([]string, error)) func() ([]string, error) {
+	if o == nil || o.kept == nil || refresh == nil {
+		return refresh
+	}
+	return func() ([]string, error) {
+		urls, err := refresh()
+		if err != nil {
+			return nil, err
+		}
+		var kept []string
+		for _, i := range o.kept {
+			if i < len(urls) {
+				kept = append(kept, urls[i])
+			}
+		}
+		return kept, nil
+	}
+}
+
+// Metadata describes the screening for an operation's metadata, or returns
+// nil when no screening took place
+func (o *Outcome) Metadata() map[string]interface{} {
+	if o == nil || o.Action == "" {
+		return nil
+	}
+	flagged := make([]int, len(o.Flagged))
+	for i, verdict := range o.Flagged {
+		flagged[i] = verdict.Index + 1
+	}
+	info := map[string]interface{}{
+		"action":  o.Action,
+		"flagged": flagged,
+	}
+	if len(o.Quarantined) > 0 {
+		info["quarantined"] = o.Quarantined
+	}
+	return info
+}
+
+// Notes describes flagged outputs for a tool response
+func (o *Outcome) Notes() []string {
+	if o == nil || len(o.Flagged) == 0 {
+		return nil
+	}
+	switch o.Action {
+	case ActionTag:
+		return []string{fmt.Sprintf("moderation flagged %d output(s) as NSFW; they were saved and tagged in metadata", len(o.Flagged))}
+	case ActionQuarantine:
+		return []string{fmt.Sprintf("moderation flagged %d output(s) as NSFW; they were moved to quarantine: %s", len(o.Flagged), strings.Join(o.Quarantined, ", "))}
+	default:
+		return []string{fmt.Sprintf("moderation flagged %d output(s) as NSFW; they were discarded", len(o.Flagged))}
+	}
+}
+
+// HeldBack reports the error code and message to return when every output
+// was held back, or "" when at least one output is kept
+func (o *Outcome) HeldBack() (string, string) {
+	if o == nil || len(o.Keep) > 0 {
+		return "", ""
+	}
+	if o.Action == ActionQuarantine {
+		return ErrCodeQuarantined, "Output moderation flagged the image as NSFW and moved it to quarantine"
+	}
+	return ErrCodeBlocked, "Output moderation flagged the image as NSFW and discarded it"
+}
+
+// classify runs the NSFW classifier on one output, returning its label and
+// cost. The spend is recorded in the ledger.
+func (s *Screener) classify(ctx context.Context, url string) (string, float64, error) {
+	startTime := time.Now()
+	modelID := models.ModelNSFWDetection
+
+	prediction, err := s.client.CreatePrediction(ctx, modelID, map[string]interface{}{"image": url})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create prediction: %w", err)
+	}
+
+	poll := s.timeouts.NewPoll(ctx, 30*time.Second)
+	var result *types.ReplicatePredictionResponse
+	for poll.Next() {
+		result, err = s.client.GetPrediction(ctx, prediction.ID)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to get prediction status: %w", err)
+		}
+		if result.Status == types.StatusSucceeded || result.Status == types.StatusFailed || result.Status == types.StatusCanceled {
+			break
+		}
+	}
+	if result.Status != types.StatusSucceeded {
+		_, message := client.ClassifyFailure(result.Error, result.Logs)
+		if result.Status != types.StatusFailed && result.Status != types.StatusCanceled {
+			message = "timed out"
+		}
+		return "", 0, fmt.Errorf("classifier %s: %s", result.Status, message)
+	}
+
+	opResult := &types.OperationResult{
+		GenerationTime: time.Since(startTime).Seconds(),
+		PredictionID:   prediction.ID,
+	}
+	models.SetActualCost(opResult, result, modelID, 1)
+	metadata := &types.ImageMetadata{
+		Version:   "1.0",
+		Operation: "moderate_image",
+		Timestamp: time.Now(),
+		Model:     modelID,
+		Result:    opResult,
+	}
+	if err := s.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "prediction_id", prediction.ID, "error", err)
+	}
+
+	label, _ := result.Output.(string)
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" {
+		return "", opResult.CostEstimate, fmt.Errorf("classifier returned no label")
+	}
+	return label, opResult.CostEstimate, nil
+}
@@ -0,0 +1,241 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+)
+
+// sendTimeout bounds each webhook delivery
+const sendTimeout = 15 * time.Second
+
+// Event describes a finished operation
+type Event struct {
+	Operation string  `json:"operation"`
+	Success   bool    `json:"success"`
+	StorageID string  `json:"storage_id,omitempty"`
+	Prompt    string  `json:"prompt,omitempty"`
+	FilePath  string  `json:"file_path,omitempty"`
+	ShareURL  string  `json:"share_url,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Duration  float64 `json:"duration_seconds"`
+	Thumbnail []byte  `json:"-"` // JPEG preview of the output, if one could be made
+}
+
+// Options configures where notifications are sent. Any combination of
+// targets may be set.
+type Options struct {
+	SlackWebhookURL   string        // Slack incoming webhook
+	DiscordWebhookURL string        // Discord channel webhook
+	WebhookURL        string        // Generic endpoint receiving the event as JSON
+	MinDuration       time.Duration // Operations finishing sooner are not announced
+	HTTPClient        *http.Client  // Client used for deliveries (default client when nil)
+}
+
+// Notifier posts completion notifications to the configured webhooks. A nil
+// Notifier sends nothing, so callers need not check whether notifications
+// are enabled.
+type Notifier struct {
+	options Options
+	sending sync.WaitGroup // Deliveries in progress
+}
+
+// New creates a notifier, or returns nil when no target is configured
+func New(opts Options) *Notifier {
+	if opts.SlackWebhookURL == "" && opts.DiscordWebhookURL == "" && opts.WebhookURL == "" {
+		return nil
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: sendTimeout}
+	}
+	return &Notifier{options: opts}
+}
+
+// ShouldNotify reports whether an operation that ran for d is announced
+func (n *Notifier) ShouldNotify(d time.Duration) bool {
+	return n != nil && d >= n.options.MinDuration
+}
+
+// Notify sends an event to every target in the background. Delivery failures
+// are logged, never returned: a notification must not fail the operation.
+func (n *Notifier) Notify(event Event) {
+	if n == nil {
+		return
+	}
+
+	targets := []struct {
+		name string
+		url  string
+		send func(context.Context, string, Event) error
+	}{
+		{"slack", n.options.SlackWebhookURL, n.sendSlack},
+		{"discord", n.options.DiscordWebhookURL, n.sendDiscord},
+		{"webhook", n.options.WebhookURL, n.sendWebhook},
+	}
+	for _, target := range targets {
+		if target.url == "" {
+			continue
+		}
+		target := target
+		n.sending.Add(1)
+		go func() {
+			defer n.sending.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := target.send(ctx, target.url, event); err != nil {
+				slog.Warn("failed to send notification", "target", target.name, "operation", event.Operation, "error", err)
+				return
+			}
+			slog.Debug("notification sent", "target", target.name, "operation", event.Operation, "storage_id", event.StorageID)
+		}()
+	}
+}
+
+// Flush waits for the notifications being sent to be delivered or to time out
+func (n *Notifier) Flush() {
+	if n == nil {
+		return
+	}
+	n.sending.Wait()
+}
+
+// summary returns a one-line description of an event
+func summary(event Event) string {
+	if event.Success {
+		return fmt.Sprintf("%s finished in %.0fs", event.Operation, event.Duration)
+	}
+	return fmt.Sprintf("%s failed after %.0fs: %s", event.Operation, event.Duration, event.Error)
+}
+
+// details returns the prompt and output location lines of an event
+func details(event Event) string {
+	var b bytes.Buffer
+	if event.Prompt != "" {
+		fmt.Fprintf(&b, "Prompt: %s\n", event.Prompt)
+	}
+	if event.FilePath != "" {
+		fmt.Fprintf(&b, "Saved to: %s\n", event.FilePath)
+	}
+	if event.ShareURL != "" {
+		fmt.Fprintf(&b, "View: %s\n", event.ShareURL)
+	}
+	return b.String()
+}
+
+// sendSlack posts an event to a Slack incoming webhook. Slack fetches images
+// itself, so the preview is shown only when the output has a share URL.
+func (n *Notifier) sendSlack(ctx context.Context, url string, event Event) error {
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": "*" + summary(event) + "*\n" + details(event)},
+		},
+	}
+	if event.Success && event.ShareURL != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type":      "image",
+			"image_url": event.ShareURL,
+			"alt_text":  event.Operation,
+		})
+	}
+	return n.postJSON(ctx, url, map[string]interface{}{
+		"text":   summary(event),
+		"blocks": blocks,
+	})
+}
+
+// sendDiscord posts an event to a Discord webhook, attaching the thumbnail
+func (n *Notifier) sendDiscord(ctx context.Context, url string, event Event) error {
+	embed := map[string]interface{}{
+		"title":       summary(event),
+		"description": details(event),
+		"color":       0x2ecc71,
+	}
+	if !event.Success {
+		embed["color"] = 0xe74c3c
+	}
+
+	if len(event.Thumbnail) == 0 {
+		return n.postJSON(ctx, url, map[string]interface{}{"embeds": []interface{}{embed}})
+	}
+
+	embed["image"] = map[string]interface{}{"url": "attachment://thumbnail.jpg"}
+	payload, err := json.Marshal(map[string]interface{}{"embeds": []interface{}{embed}})
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("payload_json", string(payload)); err != nil {
+		return err
+	}
+	part, err := mw.CreateFormFile("files[0]", "thumbnail.jpg")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(event.Thumbnail); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return n.post(ctx, url, mw.FormDataContentType(), &body)
+}
+
+// sendWebhook posts the event as JSON, with the thumbnail as a data URL
+func (n *Notifier) sendWebhook(ctx context.Context, url string, event Event) error {
+	payload := struct {
+		Event
+		Thumbnail string `json:"thumbnail,omitempty"`
+	}{Event: event}
+	if len(event.Thumbnail) > 0 {
+		payload.Thumbnail = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(event.Thumbnail)
+	}
+	return n.postJSON(ctx, url, payload)
+}
+
+// postJSON posts a JSON body
+func (n *Notifier) postJSON(ctx context.Context, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	return n.post(ctx, url, "application/json", bytes.NewReader(body))
+}
+
+// post sends a notification and checks the response status
+func (n *Notifier) post(ctx context.Context, url, contentType string, body io.Reader) error {
+	// Errors leave out the URL, whose path holds the webhook's token
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := n.options.HTTPClient.Do(req)
+	if err != nil {
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send request to %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+// Package pdf extracts the scanned image of a PDF page, so pages of scanned
+// documents can be used as image inputs without an external renderer
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// ErrNoImage is returned for pages with no embedded image, such as pages of
+// text or vector drawings, which would need rendering
+var ErrNoImage = errors.New("page has no embedded image; only scanned pages can be extracted")
+
+// maxPixels bounds the size of a decoded page image
+const maxPixels = 100_000_000
+
+// IsPDF reports whether data starts like a PDF file
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-"))
+}
+
+// ExtractPage returns the image of page (counted from 1) of the PDF in data,
+// encoded as JPEG when it is stored that way and as PNG otherwise. A page
+// holding several images yields its largest, which on a scanned page is the
+// scan. The page's rotation is applied.
+func ExtractPage(data []byte, page int) ([]byte, error) {
+	if !IsPDF(data) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+	d := newDocument(data)
+	trailer := d.trailer()
+	if trailer == nil {
+		return nil, errSyntax
+	}
+	if trailer["Encrypt"] != nil {
+		return nil, fmt.Errorf("encrypted PDFs are not supported")
+	}
+	pages := d.pages(d.dict(trailer["Root"])["Pages"])
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("PDF has no pages")
+	}
+	if page < 1 || page > len(pages) {
+		return nil, fmt.Errorf("page %d is out of range; the PDF has %d pages", page, len(pages))
+	}
+
+	p := pages[page-1]
+	s := d.largestImage(p.resources, 0, make(map[*stream]bool))
+	if s == nil {
+		return nil, ErrNoImage
+	}
+	out, err := d.encodeImage(s, p.rotate)
+	if err != nil {
+		return nil, fmt.Errorf("page %d: %w", page, err)
+	}
+	return out, nil
+}
+
+// page is a page with the attributes it inherits from the page tree
+type page struct {
+	resources dict
+	rotate    int
+}
+
+// pages lists the pages of the page tree rooted at node in order
+func (d *document) pages(node object) []page {
+	var pages []page
+	visited := make(map[object]bool)
+	var walk func(node object, inherited page, depth int)
+	walk = func(node object, inherited page, depth int) {
+		if r, ok := node.(ref); ok {
+			if visited[r] {
+				return
+			}
+			visited[r] = true
+		}
+		n := d.dict(node)
+		if n == nil || depth > maxNesting {
+			return
+		}
+		if resources := d.dict(n["Resources"]); resources != nil {
+			inherited.resources = resources
+		}
+		if rotate, ok := d.int(n["Rotate"]); ok {
+			inherited.rotate = ((rotate % 360) + 360) % 360
+		}
+		kids, ok := d.resolve(n["Kids"]).(array)
+		if !ok || d.name(n["Type"]) == "Page" {
+			pages = append(pages, inherited)
+			return
+		}
+		for _, kid := range kids {
+			walk(kid, inherited, depth+1)
+		}
+	}
+	walk(node, page{}, 0)
+	return pages
+}
+
+// largestImage finds the largest image among the XObjects of resources,
+// looking inside form XObjects
+func (d *document) largestImage(resources dict, depth int, visited map[*stream]bool) *stream {
+	var best *stream
+	bestArea := 0
+	for _, obj := range d.dict(resources["XObject"]) {
+		s, ok := d.resolve(obj).(*stream)
+		if !ok || visited[s] || depth > 3 {
+			continue
+		}
+		visited[s] = true
+		candidate := s
+		if d.name(s.dict["Subtype"]) == "Form" {
+			if candidate = d.largestImage(d.dict(s.dict["Resources"]), depth+1, visited); candidate == nil {
+				continue
+			}
+		} else if d.name(s.dict["Subtype"]) != "Image" {
+			continue
+		}
+		width, _ := d.int(candidate.dict["Width"])
+		height, _ := d.int(candidate.dict["Height"])
+		if area := width * height; area > bestArea {
+			best, bestArea = candidate, area
+		}
+	}
+	return best
+}
+
+// encodeImage encodes an image XObject as JPEG or PNG, rotated clockwise
+func (d *document) encodeImage(s *stream, rotate int) ([]byte, error) {
+	data, filter, err := d.decode(s, true)
+	if err != nil {
+		return nil, err
+	}
+	var img image.Image
+	switch filter {
+	case "":
+		if img, err = d.rawImage(s, data); err != nil {
+			return nil, err
+		}
+	case "DCTDecode", "DCT":
+		if rotate == 0 {
+			return data, nil
+		}
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to decode JPEG image: %w", err)
+		}
+	case "JPXDecode":
+		return nil, fmt.Errorf("the page image is JPEG 2000, which cannot be extracted")
+	default:
+		return nil, fmt.Errorf("the page image is %s-encoded, which cannot be extracted", filter)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rotateImage(img, rotate)); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// rawImage builds an image from unencoded samples
+func (d *document) rawImage(s *stream, data []byte) (image.Image, error) {
+	width, _ := d.int(s.dict["Width"])
+	height, _ := d.int(s.dict["Height"])
+	if width <= 0 || height <= 0 || width*height > maxPixels {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	mask, _ := d.resolve(s.dict["ImageMask"]).(bool)
+	bpc := 1
+	if !mask {
+		if bpc, _ = d.int(s.dict["BitsPerComponent"]); bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 && bpc != 16 {
+			return nil, fmt.Errorf("unsupported bits per component: %d", bpc)
+		}
+	}
+	components, palette := 1, color.Palette(nil)
+	if !mask {
+		var err error
+		if components, palette, err = d.colorSpace(s.dict["ColorSpace"], 0); err != nil {
+			return nil, err
+		}
+	}
+	if palette != nil {
+		components = 1
+	}
+
+	stride := (width*components*bpc + 7) / 8
+	if len(data) < stride*height {
+		return nil, fmt.Errorf("image data is truncated")
+	}
+
+	// A Decode array running from high to low inverts the component
+	invert := make([]bool, components)
+	decode := d.array(s.dict["Decode"])
+	for c := range invert {
+		if c*2+1 < len(decode) {
+			lo, _ := d.number(decode[c*2])
+			hi, _ := d.number(decode[c*2+1])
+			invert[c] = lo > hi
+		}
+	}
+
+	maxValue := uint32(1)<<uint(bpc) - 1
+	sample := func(row []byte, i int) uint32 {
+		switch bpc {
+		case 8:
+			return uint32(row[i])
+		case 16:
+			return uint32(row[2*i])<<8 | uint32(row[2*i+1])
+		}
+		bit := i * bpc
+		return uint32(row[bit/8]>>(8-uint(bpc)-uint(bit%8))) & maxValue
+	}
+	level := func(v uint32, c int) uint8 {
+		l := uint8(v * 255 / maxValue)
+		if invert[c] {
+			l = 255 - l
+		}
+		return l
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+	if components == 1 && palette == nil {
+		img := image.NewGray(bounds)
+		for y := 0; y < height; y++ {
+			row := data[y*stride:]
+			for x := 0; x < width; x++ {
+				// Mask samples of 0 are painted, so black, unless inverted
+				img.Pix[y*img.Stride+x] = level(sample(row, x), 0)
+			}
+		}
+		return img, nil
+	}
+
+	img := image.NewNRGBA(bounds)
+	for y := 0; y < height; y++ {
+		row := data[y*stride:]
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{A: 255}
+			switch {
+			case palette != nil:
+				if i := int(sample(row, x)); i < len(palette) {
+					c = color.NRGBAModel.Convert(palette[i]).(color.NRGBA)
+				}
+			case components == 3:
+				c = color.NRGBA{level(sample(row, x*3), 0), level(sample(row, x*3+1), 1), level(sample(row, x*3+2), 2), 255}
+			default:
+				r, g, b := color.CMYKToRGB(level(sample(row, x*4), 0), level(sample(row, x*4+1), 1), level(sample(row, x*4+2), 2), level(sample(row, x*4+3), 3))
+				c = color.NRGBA{r, g, b, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// colorSpace returns the number of components of a color space, or the
+// palette of an indexed one
+func (d *document) colorSpace(obj object, depth int) (int, color.Palette, error) {
+	if depth > 2 {
+		return 0, nil, fmt.Errorf("unsupported color space")
+	}
+	obj = d.resolve(obj)
+	family := d.name(obj)
+	a, isArray := obj.(array)
+	if isArray && len(a) > 0 {
+		family = d.name(a[0])
+	}
+	switch family {
+	case "DeviceGray", "G", "CalGray":
+		return 1, nil, nil
+	case "DeviceRGB", "RGB", "CalRGB":
+		return 3, nil, nil
+	case "DeviceCMYK", "CMYK":
+		return 4, nil, nil
+	case "ICCBased":
+		if len(a) > 1 {
+			if n, ok := d.int(d.dict(a[1])["N"]); ok && (n == 1 || n == 3 || n == 4) {
+				return n, nil, nil
+			}
+		}
+	case "Indexed", "I":
+		if len(a) < 4 {
+			break
+		}
+		base, _, err := d.colorSpace(a[1], depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+		hival, _ := d.int(a[2])
+		var lookup []byte
+		switch v := d.resolve(a[3]).(type) {
+		case str:
+			lookup = []byte(v)
+		case *stream:
+			if lookup, _, err = d.decode(v, false); err != nil {
+				return 0, nil, err
+			}
+		}
+		palette := make(color.Palette, 0, hival+1)
+		for i := 0; i <= hival && (i+1)*base <= len(lookup); i++ {
+			entry := lookup[i*base : (i+1)*base]
+			switch base {
+			case 1:
+				palette = append(palette, color.Gray{entry[0]})
+			case 3:
+				palette = append(palette, color.RGBA{entry[0], entry[1], entry[2], 255})
+			case 4:
+				palette = append(palette, color.CMYK{entry[0], entry[1], entry[2], entry[3]})
+			}
+		}
+		return 1, palette, nil
+	}
+	return 0, nil, fmt.Errorf("unsupported color space %s", family)
+}
+
+// rotateImage rotates img clockwise by a multiple of 90 degrees
+func rotateImage(img image.Image, degrees int) image.Image {
+	if degrees%90 != 0 || degrees%360 == 0 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var out *image.NRGBA
+	if degrees == 180 {
+		out = image.NewNRGBA(image.Rect(0, 0, w, h))
+	} else {
+		out = image.NewNRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch degrees {
+			case 90:
+				out.Set(h-1-y, x, c)
+			case 180:
+				out.Set(w-1-x, h-1-y, c)
+			case 270:
+				out.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,661 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// PDF objects
+type (
+	name    string
+	str     string
+	keyword string
+	dict    map[string]object
+	array   []object
+	object  interface{}
+)
+
+// ref is an indirect reference to object num
+type ref struct {
+	num, gen int
+}
+
+// stream is a stream object with its raw, still encoded data
+type stream struct {
+	dict dict
+	data []byte
+}
+
+// maxNesting bounds nested arrays and dictionaries, and chains of references
+const maxNesting = 32
+
+var errSyntax = errors.New("malformed PDF")
+
+// objectHeader finds the start of an indirect object on its own line
+var objectHeader = regexp.MustCompile(`[\r\n]\s*(\d+)\s+(\d+)\s+obj\b`)
+
+// document indexes the objects of a PDF. Objects are found by scanning for
+// their headers rather than through the cross-reference table, so files with
+// a broken or compressed table still open.
+type document struct {
+	data       []byte
+	offsets    map[int]int    // Offset of each uncompressed object's number
+	compressed map[int][2]int // Object stream and index of each compressed object
+	cache      map[int]object
+	loading    map[int]bool
+	streams    map[*stream]objectStream // Decoded object streams
+}
+
+// objectStream is a decoded object stream: the number and offset of each
+// object, and the data the offsets are relative to
+type objectStream struct {
+	header [][2]int
+	data   []byte
+}
+
+// newDocument indexes the objects in data
+func newDocument(data []byte) *document {
+	d := &document{
+		data:    data,
+		offsets: make(map[int]int),
+		cache:   make(map[int]object),
+		loading: make(map[int]bool),
+		streams: make(map[*stream]objectStream),
+	}
+	// Later definitions replace earlier ones, as incremental updates do
+	for _, m := range objectHeader.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err == nil {
+			d.offsets[num] = m[2]
+		}
+	}
+	return d
+}
+
+// object returns object num, or nil if the document has no such object
+func (d *document) object(num int) object {
+	if obj, ok := d.cache[num]; ok {
+		return obj
+	}
+	if d.loading[num] {
+		return nil
+	}
+	d.loading[num] = true
+	defer delete(d.loading, num)
+
+	var obj object
+	if offset, ok := d.offsets[num]; ok {
+		obj, _ = d.parseIndirect(offset)
+	} else {
+		d.indexCompressed()
+		if entry, ok := d.compressed[num]; ok {
+			obj, _ = d.parseCompressed(entry[0], entry[1])
+		}
+	}
+	d.cache[num] = obj
+	return obj
+}
+
+// resolve follows obj to the object it references
+func (d *document) resolve(obj object) object {
+	for i := 0; i < maxNesting; i++ {
+		r, ok := obj.(ref)
+		if !ok {
+			return obj
+		}
+		obj = d.object(r.num)
+	}
+	return nil
+}
+
+// dict resolves obj to a dictionary, or the dictionary of a stream
+func (d *document) dict(obj object) dict {
+	switch v := d.resolve(obj).(type) {
+	case dict:
+		return v
+	case *stream:
+		return v.dict
+	}
+	return nil
+}
+
+// array resolves obj to an array; a single object is an array of one
+func (d *document) array(obj object) array {
+	switch v := d.resolve(obj).(type) {
+	case array:
+		return v
+	case nil:
+		return nil
+	default:
+		return array{v}
+	}
+}
+
+// int resolves obj to an integer
+func (d *document) int(obj object) (int, bool) {
+	switch v := d.resolve(obj).(type) {
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// number resolves obj to a number
+func (d *document) number(obj object) (float64, bool) {
+	switch v := d.resolve(obj).(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// name resolves obj to a name
+func (d *document) name(obj object) name {
+	n, _ := d.resolve(obj).(name)
+	return n
+}
+
+// parseIndirect parses the indirect object whose header starts at offset
+func (d *document) parseIndirect(offset int) (object, error) {
+	p := &parser{data: d.data, pos: offset}
+	for _, want := range []string{"", "", "obj"} {
+		token := p.token()
+		if token == "" || (want != "" && token != want) {
+			return nil, errSyntax
+		}
+	}
+	obj, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	dictionary, ok := obj.(dict)
+	if !ok {
+		return obj, nil
+	}
+	save := p.pos
+	if p.token() != "stream" {
+		p.pos = save
+		return obj, nil
+	}
+
+	// Stream data starts after the end of the stream keyword's line
+	if p.pos < len(d.data) && d.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(d.data) && d.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+	if length, ok := d.int(dictionary["Length"]); ok && length >= 0 && start+length <= len(d.data) {
+		rest := bytes.TrimLeft(d.data[start+length:], " \t\r\n\f\x00")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return &stream{dict: dictionary, data: d.data[start : start+length]}, nil
+		}
+	}
+	// A wrong or missing length: the data runs up to endstream
+	end := bytes.Index(d.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil, errSyntax
+	}
+	data := bytes.TrimSuffix(d.data[start:start+end], []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return &stream{dict: dictionary, data: data}, nil
+}
+
+// indexCompressed finds the objects stored in object streams, once
+func (d *document) indexCompressed() {
+	if d.compressed != nil {
+		return
+	}
+	d.compressed = make(map[int][2]int)
+	for num := range d.offsets {
+		s, ok := d.object(num).(*stream)
+		if !ok || d.name(s.dict["Type"]) != "ObjStm" {
+			continue
+		}
+		decoded, err := d.objectStream(s)
+		if err != nil {
+			continue
+		}
+		for i, entry := range decoded.header {
+			if _, ok := d.compressed[entry[0]]; !ok {
+				d.compressed[entry[0]] = [2]int{num, i}
+			}
+		}
+	}
+}
+
+// objectStream decodes an object stream, once
+func (d *document) objectStream(s *stream) (objectStream, error) {
+	if decoded, ok := d.streams[s]; ok {
+		return decoded, nil
+	}
+	data, _, err := d.decode(s, false)
+	if err != nil {
+		return objectStream{}, err
+	}
+	n, _ := d.int(s.dict["N"])
+	first, _ := d.int(s.dict["First"])
+	if first < 0 || first > len(data) || n < 0 {
+		return objectStream{}, errSyntax
+	}
+	p := &parser{data: data[:first]}
+	decoded := objectStream{data: data[first:]}
+	for i := 0; i < n; i++ {
+		num, err1 := strconv.Atoi(p.token())
+		offset, err2 := strconv.Atoi(p.token())
+		if err1 != nil || err2 != nil {
+			return objectStream{}, errSyntax
+		}
+		decoded.header = append(decoded.header, [2]int{num, offset})
+	}
+	d.streams[s] = decoded
+	return decoded, nil
+}
+
+// parseCompressed parses object index of the object stream num
+func (d *document) parseCompressed(num, index int) (object, error) {
+	s, ok := d.object(num).(*stream)
+	if !ok {
+		return nil, errSyntax
+	}
+	decoded, err := d.objectStream(s)
+	if err != nil || index >= len(decoded.header) || decoded.header[index][1] > len(decoded.data) {
+		return nil, errSyntax
+	}
+	p := &parser{data: decoded.data, pos: decoded.header[index][1]}
+	return p.parse(0)
+}
+
+// trailer returns the trailer dictionary, or the dictionary of the last
+// cross-reference stream
+func (d *document) trailer() dict {
+	if i := bytes.LastIndex(d.data, []byte("trailer")); i >= 0 {
+		p := &parser{data: d.data, pos: i + len("trailer")}
+		if obj, err := p.parse(0); err == nil {
+			if trailer, ok := obj.(dict); ok && trailer["Root"] != nil {
+				return trailer
+			}
+		}
+	}
+	var trailer dict
+	last := -1
+	for num, offset := range d.offsets {
+		if s, ok := d.object(num).(*stream); ok && d.name(s.dict["Type"]) == "XRef" && offset > last {
+			trailer, last = s.dict, offset
+		}
+	}
+	return trailer
+}
+
+// decode applies the filters of a stream. Image filters the package cannot
+// decode end the chain: the data is returned still encoded, with the filter,
+// when keepImage is set, and an error otherwise.
+func (d *document) decode(s *stream, keepImage bool) ([]byte, name, error) {
+	data := s.data
+	filters := d.array(s.dict["Filter"])
+	parms := d.array(s.dict["DecodeParms"])
+	for i, f := range filters {
+		filter := d.name(f)
+		var parm dict
+		if i < len(parms) {
+			parm = d.dict(parms[i])
+		}
+		var err error
+		switch filter {
+		case "FlateDecode", "Fl":
+			if data, err = inflate(data); err == nil {
+				data, err = d.unpredict(data, parm)
+			}
+		case "ASCIIHexDecode", "AHx":
+			data, err = decodeHex(data)
+		case "ASCII85Decode", "A85":
+			data, err = decode85(data)
+		case "DCTDecode", "DCT", "JPXDecode", "CCITTFaxDecode", "CCF", "JBIG2Decode":
+			if !keepImage || i != len(filters)-1 {
+				return nil, filter, fmt.Errorf("unsupported filter %s", filter)
+			}
+			return data, filter, nil
+		default:
+			return nil, filter, fmt.Errorf("unsupported filter %s", filter)
+		}
+		if err != nil {
+			return nil, filter, fmt.Errorf("failed to decode %s data: %w", filter, err)
+		}
+	}
+	return data, "", nil
+}
+
+// inflate decompresses zlib data, keeping what was read from a truncated
+// stream
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(r)
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unpredict reverses the PNG predictors of Flate data
+func (d *document) unpredict(data []byte, parm dict) ([]byte, error) {
+	predictor, _ := d.int(parm["Predictor"])
+	if predictor < 10 {
+		if predictor == 2 {
+			return nil, fmt.Errorf("TIFF predictor is not supported")
+		}
+		return data, nil
+	}
+	colors, bpc, columns := 1, 8, 1
+	if v, ok := d.int(parm["Colors"]); ok {
+		colors = v
+	}
+	if v, ok := d.int(parm["BitsPerComponent"]); ok {
+		bpc = v
+	}
+	if v, ok := d.int(parm["Columns"]); ok {
+		columns = v
+	}
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (colors*bpc*columns + 7) / 8
+	if rowLen <= 0 || bpp <= 0 {
+		return nil, errSyntax
+	}
+
+	out := make([]byte, 0, len(data)/(rowLen+1)*rowLen)
+	prev := make([]byte, rowLen)
+	for len(data) >= rowLen+1 {
+		kind, row := data[0], append([]byte(nil), data[1:rowLen+1]...)
+		data = data[rowLen+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+// paeth is the PNG Paeth predictor
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// decodeHex decodes ASCIIHex data up to its > terminator
+func decodeHex(data []byte) ([]byte, error) {
+	if i := bytes.IndexByte(data, '>'); i >= 0 {
+		data = data[:i]
+	}
+	digits := bytes.Map(func(r rune) rune {
+		if isSpace(byte(r)) {
+			return -1
+		}
+		return r
+	}, data)
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	_, err := hex.Decode(out, digits)
+	return out, err
+}
+
+// decode85 decodes ASCII85 data up to its ~> terminator
+func decode85(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	out := make([]byte, 4*len(data)/5+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+// parser reads PDF objects from data
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// skipSpace skips whitespace and comments
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// token reads a run of regular characters
+func (p *parser) token() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && !isSpace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// parse reads one object, nested depth levels deep
+func (p *parser) parse(depth int) (object, error) {
+	if depth > maxNesting {
+		return nil, errSyntax
+	}
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, errSyntax
+	}
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return p.parseName(), nil
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		d := make(dict)
+		for {
+			p.skipSpace()
+			if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+				p.pos += 2
+				return d, nil
+			}
+			key, err := p.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(name)
+			if !ok {
+				return nil, errSyntax
+			}
+			value, err := p.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			d[string(k)] = value
+		}
+	case c == '<':
+		end := bytes.IndexByte(p.data[p.pos:], '>')
+		if end < 0 {
+			return nil, errSyntax
+		}
+		decoded, err := decodeHex(p.data[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+		return str(decoded), err
+	case c == '(':
+		return p.parseString()
+	case c == '[':
+		p.pos++
+		var a array
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return nil, errSyntax
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return a, nil
+			}
+			item, err := p.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, item)
+		}
+	}
+
+	token := p.token()
+	switch token {
+	case "":
+		return nil, errSyntax
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.ParseInt(token, 10, 64); err == nil {
+		// An integer may start a "num gen R" reference
+		save := p.pos
+		if gen, err := strconv.Atoi(p.token()); err == nil && p.token() == "R" {
+			return ref{num: int(n), gen: gen}, nil
+		}
+		p.pos = save
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f, nil
+	}
+	return keyword(token), nil
+}
+
+// parseName reads a name after its slash, decoding #xx escapes
+func (p *parser) parseName() name {
+	var out []byte
+	for p.pos < len(p.data) && !isSpace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) {
+			if v, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				p.pos += 3
+				continue
+			}
+		}
+		out = append(out, c)
+		p.pos++
+	}
+	return name(out)
+}
+
+// parseString reads a literal string with balanced parentheses
+func (p *parser) parseString() (object, error) {
+	p.pos++
+	var out []byte
+	nesting := 0
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			if nesting == 0 {
+				return str(out), nil
+			}
+			nesting--
+		case '\\':
+			if p.pos >= len(p.data) {
+				return nil, errSyntax
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// Line continuation
+				if c == '\r' && p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return nil, errSyntax
+}
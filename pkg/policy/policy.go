@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is an organization's content policy for prompts: terms no prompt
+// may contain and terms every prompt must contain
+type Policy struct {
+	Banned   []string `yaml:"banned_terms"`
+	Required []string `yaml:"required_terms"`
+
+	banned   []term
+	required []term
+}
+
+// term is a policy term with the pattern matching it as a whole word,
+// ignoring case
+type term struct {
+	text string
+	re   *regexp.Regexp
+}
+
+// Violation describes how a prompt breaks the policy
+type Violation struct {
+	Argument string   // Tool argument holding the prompt
+	Banned   []string // Banned terms the prompt contains
+	Missing  []string // Required terms the prompt lacks
+}
+
+func (v *Violation) Error() string {
+	var problems []string
+	if len(v.Banned) > 0 {
+		problems = append(problems, "contains banned terms: "+strings.Join(v.Banned, ", "))
+	}
+	if len(v.Missing) > 0 {
+		problems = append(problems, "lacks required terms: "+strings.Join(v.Missing, ", "))
+	}
+	return fmt.Sprintf("%s breaks the content policy; it %s", v.Argument, strings.Join(problems, " and "))
+}
+
+// Load reads a content policy from a YAML file. An empty path returns nil:
+// no policy is configured.
+func Load(path string) (*Policy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt policy: %w", err)
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt policy: %w", err)
+	}
+	p.banned = compileTerms(p.Banned)
+	p.required = compileTerms(p.Required)
+	if len(p.banned) == 0 && len(p.required) == 0 {
+		return nil, fmt.Errorf("invalid prompt policy: it lists neither banned_terms nor required_terms")
+	}
+	return &p, nil
+}
+
+// compileTerms returns the non-empty terms with their patterns
+func compileTerms(texts []string) []term {
+	var terms []term
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		terms = append(terms, term{text: text, re: regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(text) + `\b`)})
+	}
+	return terms
+}
+
+// Check checks the prompt held by a tool argument, returning nil when it
+// complies. Required terms are only checked when required is set, since
+// fragments added to a prompt need not repeat them.
+func (p *Policy) Check(argument, prompt string, required bool) *Violation {
+	v := &Violation{Argument: argument}
+	for _, t := range p.banned {
+		if t.re.MatchString(prompt) {
+			v.Banned = append(v.Banned, t.text)
+		}
+	}
+	if required {
+		for _, t := range p.required {
+			if !t.re.MatchString(prompt) {
+				v.Missing = append(v.Missing, t.text)
+			}
+		}
+	}
+	if len(v.Banned) == 0 && len(v.Missing) == 0 {
+		return nil
+	}
+	return v
+}
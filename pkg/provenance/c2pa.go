@@ -0,0 +1,185 @@
+package provenance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultTool is the c2patool binary used when no path is configured
+const DefaultTool = "c2patool"
+
+// DefaultAlgorithm is the signing algorithm used when none is configured
+const DefaultAlgorithm = "es256"
+
+// signTimeout bounds a single c2patool run, including any timestamp request
+const signTimeout = 60 * time.Second
+
+// assertionLabel names the assertion recording how an output was made
+const assertionLabel = "com.gomcpgo.replicate_image_ai.generation"
+
+// Options configures C2PA signing
+type Options struct {
+	ToolPath       string // c2patool binary (default: c2patool on PATH)
+	CertPath       string // PEM certificate chain used to sign manifests
+	KeyPath        string // PEM private key matching the certificate
+	Algorithm      string // Signing algorithm, e.g. es256 or ps256
+	TimestampURL   string // RFC 3161 timestamp authority, optional
+	ClaimGenerator string // Software named as the claim generator
+}
+
+// Signer adds C2PA content credentials to images by running c2patool, the
+// reference implementation of the C2PA specification. A nil Signer signs
+// nothing.
+type Signer struct {
+	options Options
+}
+
+// Claim describes how an image was made
+type Claim struct {
+	Operation         string
+	Model             string
+	Prompt            string // Recorded only as a SHA-256 hash
+	DigitalSourceType string // IPTC digital source type URI
+	Edited            bool   // The output modifies an existing image
+	Created           time.Time
+}
+
+// NewSigner creates a signer, or returns nil when no certificate is
+// configured. It fails when the tool, certificate, or key cannot be found.
+func NewSigner(opts Options) (*Signer, error) {
+	if opts.CertPath == "" {
+		return nil, nil
+	}
+	if opts.KeyPath == "" {
+		return nil, fmt.Errorf("C2PA signing requires a private key with the certificate")
+	}
+	if opts.ToolPath == "" {
+		opts.ToolPath = DefaultTool
+	}
+	if opts.Algorithm == "" {
+		opts.Algorithm = DefaultAlgorithm
+	}
+
+	toolPath, err := exec.LookPath(opts.ToolPath)
+	if err != nil {
+		return nil, fmt.Errorf("C2PA signing requires c2patool: %w", err)
+	}
+	opts.ToolPath = toolPath
+
+	// c2patool resolves manifest paths relative to the manifest file, which
+	// lives in a temp directory
+	for _, path := range []*string{&opts.CertPath, &opts.KeyPath} {
+		abs, err := filepath.Abs(*path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid C2PA credential path: %w", err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("C2PA credential not found: %w", err)
+		}
+		*path = abs
+	}
+
+	return &Signer{options: opts}, nil
+}
+
+// Enabled reports whether outputs are signed
+func (s *Signer) Enabled() bool {
+	return s != nil
+}
+
+// Sign writes a signed copy of the image at src to dst, whose extension must
+// match the image format
+func (s *Signer) Sign(ctx context.Context, src, dst string, claim Claim) error {
+	if s == nil {
+		return nil
+	}
+
+	manifest, err := json.Marshal(s.manifest(claim))
+	if err != nil {
+		return fmt.Errorf("failed to build C2PA manifest: %w", err)
+	}
+	manifestFile, err := os.CreateTemp("", "c2pa-manifest-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write C2PA manifest: %w", err)
+	}
+	defer os.Remove(manifestFile.Name())
+	if _, err := manifestFile.Write(manifest); err != nil {
+		manifestFile.Close()
+		return fmt.Errorf("failed to write C2PA manifest: %w", err)
+	}
+	if err := manifestFile.Close(); err != nil {
+		return fmt.Errorf("failed to write C2PA manifest: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, signTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.options.ToolPath, src, "--manifest", manifestFile.Name(), "--output", dst, "--force")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("c2patool failed: %s", msg)
+		}
+		return fmt.Errorf("c2patool failed: %w", err)
+	}
+	return nil
+}
+
+// manifest builds the c2patool manifest definition for a claim
+func (s *Signer) manifest(claim Claim) map[string]interface{} {
+	action := "c2pa.created"
+	if claim.Edited {
+		action = "c2pa.edited"
+	}
+
+	generation := map[string]interface{}{
+		"operation": claim.Operation,
+		"model":     claim.Model,
+	}
+	if claim.Prompt != "" {
+		sum := sha256.Sum256([]byte(claim.Prompt))
+		generation["prompt_sha256"] = hex.EncodeToString(sum[:])
+	}
+	if !claim.Created.IsZero() {
+		generation["created"] = claim.Created.UTC().Format(time.RFC3339)
+	}
+
+	manifest := map[string]interface{}{
+		"alg":             s.options.Algorithm,
+		"private_key":     s.options.KeyPath,
+		"sign_cert":       s.options.CertPath,
+		"claim_generator": s.options.ClaimGenerator,
+		"assertions": []interface{}{
+			map[string]interface{}{
+				"label": "c2pa.actions",
+				"data": map[string]interface{}{
+					"actions": []interface{}{
+						map[string]interface{}{
+							"action":            action,
+							"digitalSourceType": claim.DigitalSourceType,
+							"softwareAgent":     claim.Model,
+						},
+					},
+				},
+			},
+			map[string]interface{}{
+				"label": assertionLabel,
+				"data":  generation,
+			},
+		},
+	}
+	if s.options.TimestampURL != "" {
+		manifest["ta_url"] = s.options.TimestampURL
+	}
+	return manifest
+}
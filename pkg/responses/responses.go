@@ -5,10 +5,21 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
 )
 
 // BuildSuccessResponse creates a standardized success response
-func BuildSuccessResponse(operation string, id string, paths map[string]string, modelInfo map[string]string, params map[string]interface{}, metrics map[string]interface{}, predictionID string) string {
+func BuildSuccessResponse(operation string, id string, paths map[string]string, modelInfo map[string]string, params map[string]interface{}, metrics map[string]interface{}, predictionID string, extra map[string]interface{}) string {
+	// Inline outputs (Stability returns images in the response body) have no
+	// delivery URL worth reporting; the saved file is the result
+	for k, v := range paths {
+		if strings.HasPrefix(v, "data:") {
+			delete(paths, k)
+		}
+	}
+	
 	response := map[string]interface{}{
 		"success":    true,
 		"operation":  operation,
@@ -23,8 +34,20 @@ func BuildSuccessResponse(operation string, id string, paths map[string]string,
 		response["prediction_id"] = predictionID
 	}
 	
-	// Add cost estimate based on operation
-	response["cost_estimate"] = EstimateCost(operation)
+	// Report the actual cost when it was computed, otherwise a flat estimate.
+	// Local backends cost nothing.
+	if cost, ok := metrics["cost"].(float64); ok && cost > 0 {
+		response["cost_estimate"] = cost
+	} else if modelInfo["provider"] == models.ProviderLocal {
+		response["cost_estimate"] = 0.0
+	} else {
+		response["cost_estimate"] = EstimateCost(operation)
+	}
+	
+	// Merge additional fields if provided
+	for k, v := range extra {
+		response[k] = v
+	}
 	
 	jsonBytes, _ := json.MarshalIndent(response, "", "  ")
 	return string(jsonBytes)
@@ -54,10 +77,12 @@ func BuildProcessingResponse(operation string, predictionID string, storageID st
 		"operation":     operation,
 		"status":        "processing",
 		"prediction_id": predictionID,
-		"storage_id":    storageID,
 		"message":       fmt.Sprintf("Operation still in progress. Use continue_operation with prediction_id='%s' to check status.", predictionID),
 	}
 	
+	if storageID != "" {
+		response["storage_id"] = storageID
+	}
 	if estimatedRemaining > 0 {
 		response["estimated_remaining"] = estimatedRemaining
 	}
@@ -112,7 +137,8 @@ func GetFileSize(filePath string) int64 {
 	return info.Size()
 }
 
-// EstimateCost estimates the cost of an operation in USD
+// EstimateCost returns a flat per-operation cost guess in USD, used only when
+// the actual cost could not be computed from prediction metrics
 func EstimateCost(operation string) float64 {
 	costs := map[string]float64{
 		"generate_image":     0.003,
@@ -121,6 +147,14 @@ func EstimateCost(operation string) float64 {
 		"remove_background":  0.004,
 		"edit_image":         0.006,
 		"restore_photo":      0.005,
+		"colorize_image":     0.004,
+		"estimate_depth":     0.002,
+		"vectorize_image":    0.01,
+		"caption_image":      0.001,
+		"detect_objects":     0.001,
+		"moderate_image":     0.0005,
+		"translate_prompt":   0.0001,
+		"warm_model":         0.002,
 		"batch_process":      0.020,
 	}
 	
@@ -133,15 +167,29 @@ func EstimateCost(operation string) float64 {
 // GetSuggestion provides helpful suggestions for different error types
 func GetSuggestion(errorType string) string {
 	suggestions := map[string]string{
-		"file_not_found":     "Please check the file path and ensure the file exists",
-		"file_too_large":     "Please compress or resize the image to under 5MB",
-		"invalid_format":     "Please provide an image in JPEG, PNG, or WebP format",
-		"model_unavailable":  "Try using a different model or wait and retry",
-		"rate_limit":         "Wait a few seconds before retrying",
-		"invalid_parameters": "Check the parameter values and ensure they meet the requirements",
-		"timeout":            "The operation is taking longer than expected. Use continue_operation to check status",
-		"api_error":          "Check your API key and network connection",
-		"permission_denied":  "Ensure you have the necessary permissions for this operation",
+		"file_not_found":       "Please check the file path and ensure the file exists",
+		"file_too_large":       "Please compress or resize the image to under 5MB",
+		"invalid_format":       "Please provide an image in JPEG, PNG, or WebP format",
+		"model_unavailable":    "Try using a different model or wait and retry",
+		"rate_limit":           "Wait a few seconds before retrying",
+		"invalid_parameters":   "Check the parameter values and ensure they meet the requirements",
+		"timeout":              "The operation is taking longer than expected. Retry with a larger max_wait_seconds for slow models",
+		"api_error":            "Check your API key and network connection",
+		"permission_denied":    "Ensure you have the necessary permissions for this operation",
+		"content_blocked":      "The output was blocked by the model's safety filter. Rephrase the prompt or adjust safety_filter_level",
+		"nsfw_content":         "The model flagged the content as unsafe. Rephrase the prompt or use a different input image",
+		"content_quarantined":  "Output moderation flagged the image. It was moved to the quarantine folder for review; rephrase the prompt to avoid sensitive content",
+		"out_of_memory":        "The model ran out of GPU memory. Try a smaller image, a lower scale factor, or fewer outputs",
+		"cold_boot_timeout":    "The model took too long to start. Retry in a minute, warm it first with warm_model, or choose a more frequently used model",
+		"version_not_found":    "The model version no longer exists on Replicate. Try a different model alias",
+		"invalid_input":        "The model rejected one of the inputs. Check the parameters supported by the selected model",
+		"billing_issue":        "Check your Replicate billing settings and account credit",
+		"authentication_error": "Check that REPLICATE_API_TOKEN (or the selected provider's API key) is set to a valid API token",
+		"output_expired":       "The output files have expired on Replicate and can no longer be downloaded. Run the operation again",
+		"incomplete_download":  "The output kept arriving truncated or corrupt and was not saved. Check the network connection and run the operation again",
+		"provider_unavailable": "Set the provider's API key (FAL_KEY, STABILITY_API_KEY, OPENAI_API_KEY), set LOCAL_BACKEND for the local provider, or choose a model that Replicate serves",
+		"shutting_down":        "The server is restarting. Retry the call once it is back",
+		"policy_violation":     "The prompt breaks the server's content policy. Remove the banned terms or add the missing ones listed in details",
 	}
 	
 	if suggestion, ok := suggestions[errorType]; ok {
@@ -152,39 +200,18 @@ func GetSuggestion(errorType string) string {
 
 // ExtractModelName extracts a friendly model name from the model ID
 func ExtractModelName(modelID string) string {
-	modelNames := map[string]string{
-		"black-forest-labs/flux-schnell": "Flux Schnell",
-		"black-forest-labs/flux-dev":     "Flux Dev",
-		"black-forest-labs/flux-pro":     "Flux Pro",
-		"stability-ai/sdxl":               "SDXL",
-		"bytedance/sdxl-lightning-4step": "SDXL Lightning",
-		"bytedance/seedream-3":            "Seedream 3",
-		"ideogram-ai/ideogram-v3-turbo":  "Ideogram Turbo",
-		"recraft-ai/recraft-v3":           "Recraft",
-		"recraft-ai/recraft-v3-svg":       "Recraft SVG",
-		"tencentarc/gfpgan":               "GFPGAN",
-		"sczhou/codeformer":               "CodeFormer",
-		"nightmareai/real-esrgan":         "RealESRGAN",
-		"philz1337x/clarity-upscaler":    "Clarity Upscaler",
-		"lucataco/remove-bg":              "RemoveBG",
-		"cjwbw/rembg":                     "Rembg",
-		"lucataco/dis-background-removal": "DIS Background Removal",
-		"stability-ai/stable-diffusion-inpainting": "SD Inpainting",
-		"microsoft/bringing-old-photos-back-to-life": "Old Photo Restoration",
-	}
-	
-	// Remove version hash if present
-	if idx := filepath.Base(modelID); idx != "" {
-		modelID = idx
+	if models.IsKnown(modelID) {
+		return models.GetModelInfo(modelID).Name
 	}
 	
-	// Check for known model names
-	for prefix, name := range modelNames {
-		if len(modelID) >= len(prefix) && modelID[:len(prefix)] == prefix {
-			return name
+	// Match IDs given with or without a version hash
+	baseID := strings.Split(modelID, ":")[0]
+	for _, info := range models.All() {
+		if strings.Split(info.ID, ":")[0] == baseID {
+			return info.Name
 		}
 	}
 	
 	// Return the base name if not found
-	return filepath.Base(modelID)
+	return filepath.Base(baseID)
 }
\ No newline at end of file
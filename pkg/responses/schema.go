@@ -0,0 +1,96 @@
+package responses
+
+import "encoding/json"
+
+// SuccessSchema is the JSON Schema of the responses built by
+// BuildSuccessResponse and BuildSimpleSuccessResponse. Tools add their own
+// fields, so only the common ones are described.
+const SuccessSchema = `{
+	"type": "object",
+	"properties": {
+		"success": {"const": true},
+		"operation": {"type": "string", "description": "Tool that produced the response"},
+		"message": {"type": "string", "description": "Human-readable summary"},
+		"id": {"type": "string", "description": "Storage ID of the result"},
+		"paths": {"type": "object", "description": "Result locations, such as the saved file and the delivery URL", "additionalProperties": {"type": "string"}},
+		"model": {"description": "Model that produced the result: its id, name, and provider, or an alias"},
+		"parameters": {"type": "object", "description": "Inputs the model ran with"},
+		"metrics": {"type": "object", "description": "Prediction timings and cost"},
+		"prediction_id": {"type": "string"},
+		"cost_estimate": {"type": "number", "description": "Cost in USD"},
+		"file_path": {"type": "string", "description": "Saved result file"},
+		"share_url": {"type": "string", "description": "Public URL of the result file"},
+		"notes": {"type": "array", "items": {"type": "string"}},
+		"warnings": {"type": "array", "items": {"type": "string"}},
+		"model_substitutions": {"type": "array", "description": "Models used in place of deprecated or removed ones", "items": {"type": "object", "properties": {"requested": {"type": "string"}, "used": {"type": "string"}, "reason": {"type": "string"}}}}
+	},
+	"required": ["success", "operation"]
+}`
+
+// ProcessingSchema is the JSON Schema of the responses built by
+// BuildProcessingResponse
+const ProcessingSchema = `{
+	"type": "object",
+	"properties": {
+		"success": {"const": false},
+		"operation": {"type": "string"},
+		"status": {"const": "processing"},
+		"prediction_id": {"type": "string", "description": "Pass to continue_operation to check on the prediction"},
+		"storage_id": {"type": "string"},
+		"message": {"type": "string"},
+		"estimated_remaining": {"type": "integer", "description": "Estimated seconds until the prediction finishes"},
+		"completed_files": {"type": "array", "items": {"type": "string"}, "description": "Files the operation has saved so far"},
+		"queued": {"type": "boolean", "description": "The call is waiting for the off-peak window and has not started"},
+		"queue_position": {"type": "integer", "description": "Place among the calls waiting for the off-peak window, 1 running next"},
+		"window": {"type": "string", "description": "Off-peak window the call waits for, as HH:MM-HH:MM in server time"},
+		"starts_at": {"type": "string", "description": "When the off-peak window next opens, while it is closed"}
+	},
+	"required": ["success", "status", "prediction_id"]
+}`
+
+// ErrorSchema is the JSON Schema of the responses built by
+// BuildErrorResponse
+const ErrorSchema = `{
+	"type": "object",
+	"properties": {
+		"success": {"const": false},
+		"operation": {"type": "string"},
+		"error": {
+			"type": "object",
+			"properties": {
+				"type": {"type": "string", "description": "Error code, such as invalid_parameters or rate_limit"},
+				"message": {"type": "string"},
+				"details": {"type": ["object", "null"]},
+				"suggestion": {"type": "string"}
+			},
+			"required": ["type", "message"]
+		}
+	},
+	"required": ["success", "operation", "error"]
+}`
+
+// OutputSchema returns the output schema every tool publishes: a response is
+// a success, an operation still processing, or an error
+func OutputSchema() json.RawMessage {
+	schema := map[string]interface{}{
+		"type": "object",
+		"oneOf": []json.RawMessage{
+			json.RawMessage(SuccessSchema),
+			json.RawMessage(ProcessingSchema),
+			json.RawMessage(ErrorSchema),
+		},
+	}
+	raw, _ := json.Marshal(schema)
+	return raw
+}
+
+// StructuredContent parses a JSON response into the object sent as a tool
+// result's structured content. It returns false for responses that are not a
+// JSON object.
+func StructuredContent(content string) (map[string]interface{}, bool) {
+	var structured map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &structured); err != nil || structured == nil {
+		return nil, false
+	}
+	return structured, true
+}
@@ -0,0 +1,507 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// abTestsDir holds A/B test records and composites under the storage root.
+// Its name is not a storage ID, so repair_storage and listings leave it alone.
+const abTestsDir = "ab_tests"
+
+// abTestsFile is the index of every A/B test
+const abTestsFile = "tests.json"
+
+// abTestsMu serializes A/B test index updates within the process
+var abTestsMu sync.Mutex
+
+// Winners recorded for an A/B test
+const (
+	ABWinnerA   = "A"
+	ABWinnerB   = "B"
+	ABWinnerTie = "tie"
+)
+
+// ABCandidate is one side of an A/B test
+type ABCandidate struct {
+	Label     string `json:"label"`
+	StorageID string `json:"storage_id"`
+	Model     string `json:"model"`
+	Prompt    string `json:"prompt"`
+	FilePath  string `json:"file_path"`
+}
+
+// ABTest records a blind comparison of two generations and its outcome
+type ABTest struct {
+	ID            string        `json:"id"`
+	Created       time.Time     `json:"created"`
+	Candidates    []ABCandidate `json:"candidates"` // A, then B
+	CompositePath string        `json:"composite_path"`
+	Winner        string        `json:"winner,omitempty"`
+	DecidedAt     *time.Time    `json:"decided_at,omitempty"`
+}
+
+// ModelPreference totals the decided A/B tests a model took part in
+type ModelPreference struct {
+	Tests   int     `json:"tests"`
+	Wins    int     `json:"wins"`
+	Losses  int     `json:"losses"`
+	Ties    int     `json:"ties"`
+	WinRate float64 `json:"win_rate"` // Wins over decided tests, ties counting half
+}
+
+// ABReport summarizes A/B test outcomes per model
+type ABReport struct {
+	Tests    int                         `json:"tests"`
+	Decided  int                         `json:"decided"`
+	Pending  int                         `json:"pending"`
+	ByModel  map[string]*ModelPreference `json:"by_model"`
+	Rankings []string                    `json:"rankings"` // Models by win rate, best first
+}
+
+// NewABTestID returns a random A/B test ID
+func NewABTestID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "ab-" + hex.EncodeToString(b), nil
+}
+
+// ABCompositePath returns where the composite image of an A/B test is saved
+func (s *Storage) ABCompositePath(id string) string {
+	return filepath.Join(s.rootPath, abTestsDir, id+".png")
+}
+
+// SaveABTest creates or updates an A/B test record
+func (s *Storage) SaveABTest(test *ABTest) error {
+	abTestsMu.Lock()
+	defer abTestsMu.Unlock()
+
+	tests, err := s.readABTests()
+	if err != nil {
+		return err
+	}
+	tests[test.ID] = test
+
+	// Seal prompts in copies so callers keep the plain records
+	stored := make(map[string]*ABTest, len(tests))
+	for id, t := range tests {
+		sealed := *t
+		sealed.Candidates = make([]ABCandidate, len(t.Candidates))
+		for i, candidate := range t.Candidates {
+			candidate.Prompt = s.options.Prompts.Seal(candidate.Prompt)
+			sealed.Candidates[i] = candidate
+		}
+		stored[id] = &sealed
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal A/B tests: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(s.rootPath, abTestsDir), 0755); err != nil {
+		return fmt.Errorf("failed to create A/B test directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.rootPath, abTestsDir, abTestsFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to save A/B tests: %w", err)
+	}
+	return nil
+}
+
+// LoadABTest returns an A/B test record
+func (s *Storage) LoadABTest(id string) (*ABTest, error) {
+	abTestsMu.Lock()
+	defer abTestsMu.Unlock()
+
+	tests, err := s.readABTests()
+	if err != nil {
+		return nil, err
+	}
+	test, ok := tests[id]
+	if !ok {
+		return nil, fmt.Errorf("no A/B test with id %s", id)
+	}
+	return test, nil
+}
+
+// readABTests reads the A/B test index; callers must hold abTestsMu
+func (s *Storage) readABTests() (map[string]*ABTest, error) {
+	data, err := os.ReadFile(filepath.Join(s.rootPath, abTestsDir, abTestsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]*ABTest{}, nil
+		}
+		return nil, fmt.Errorf("failed to read A/B tests: %w", err)
+	}
+
+	tests := map[string]*ABTest{}
+	if err := json.Unmarshal(data, &tests); err != nil {
+		return nil, fmt.Errorf("failed to parse A/B tests: %w", err)
+	}
+	for _, test := range tests {
+		for i := range test.Candidates {
+			test.Candidates[i].Prompt = s.options.Prompts.Open(test.Candidates[i].Prompt)
+		}
+	}
+	return tests, nil
+}
+
+// ABPreferenceReport totals every decided A/B test by model. A test between
+// two prompts on the same model counts toward that model once, as a tie.
+func (s *Storage) ABPreferenceReport() (*ABReport, error) {
+	abTestsMu.Lock()
+	tests, err := s.readABTests()
+	abTestsMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ABReport{ByModel: map[string]*ModelPreference{}, Rankings: []string{}}
+	for _, test := range tests {
+		report.Tests++
+		if test.Winner == "" || len(test.Candidates) != 2 {
+			report.Pending++
+			continue
+		}
+		report.Decided++
+
+		a, b := test.Candidates[0], test.Candidates[1]
+		if a.Model == b.Model {
+			preference := report.preference(a.Model)
+			preference.Tests++
+			preference.Ties++
+			continue
+		}
+		for _, candidate := range test.Candidates {
+			preference := report.preference(candidate.Model)
+			preference.Tests++
+			switch test.Winner {
+			case ABWinnerTie:
+				preference.Ties++
+			case candidate.Label:
+				preference.Wins++
+			default:
+				preference.Losses++
+			}
+		}
+	}
+
+	for model, preference := range report.ByModel {
+		preference.WinRate = (float64(preference.Wins) + float64(preference.Ties)/2) / float64(preference.Tests)
+		report.Rankings = append(report.Rankings, model)
+	}
+	sort.Slice(report.Rankings, func(i, j int) bool {
+		pi, pj := report.ByModel[report.Rankings[i]], report.ByModel[report.Rankings[j]]
+		if pi.WinRate != pj.WinRate {
+			return pi.WinRate > pj.WinRate
+		}
+		return report.Rankings[i] < report.Rankings[j]
+	})
+	return report, nil
+}
+
+// preference returns the totals for a model, creating them if needed
+func (r *ABReport) preference(model string) *ModelPreference {
+	if r.ByModel[model] == nil {
+		r.ByModel[model] = &ModelPreference{}
+	}
+	return r.ByModel[model]
+}
+
+// Composite layout, in pixels
+const (
+	compositeMaxHeight = 1024
+	compositeGap       = 16
+	compositeBand      = 64 // Label band above the images
+	glyphScale         = 6
+)
+
+// glyphs are 7-row bitmaps of the candidate, comparison tile, and sweep
+// value labels, and of the preview watermark
+var glyphs = map[string][]string{
+	"A": {
+		".###.",
+		"#...#",
+		"#...#",
+		"#####",
+		"#...#",
+		"#...#",
+		"#...#",
+	},
+	"B": {
+		"####.",
+		"#...#",
+		"#...#",
+		"####.",
+		"#...#",
+		"#...#",
+		"####.",
+	},
+	"E": {
+		"#####",
+		"#....",
+		"#....",
+		"####.",
+		"#....",
+		"#....",
+		"#####",
+	},
+	"I": {
+		"###",
+		".#.",
+		".#.",
+		".#.",
+		".#.",
+		".#.",
+		"###",
+	},
+	"P": {
+		"####.",
+		"#...#",
+		"#...#",
+		"####.",
+		"#....",
+		"#....",
+		"#....",
+	},
+	"R": {
+		"####.",
+		"#...#",
+		"#...#",
+		"####.",
+		"#.#..",
+		"#..#.",
+		"#...#",
+	},
+	"V": {
+		"#...#",
+		"#...#",
+		"#...#",
+		"#...#",
+		"#...#",
+		".#.#.",
+		"..#..",
+	},
+	"W": {
+		"#...#",
+		"#...#",
+		"#...#",
+		"#.#.#",
+		"#.#.#",
+		"##.##",
+		"#...#",
+	},
+	"0": {
+		".###.",
+		"#...#",
+		"#..##",
+		"#.#.#",
+		"##..#",
+		"#...#",
+		".###.",
+	},
+	"1": {
+		"..#..",
+		".##..",
+		"..#..",
+		"..#..",
+		"..#..",
+		"..#..",
+		".###.",
+	},
+	"2": {
+		".###.",
+		"#...#",
+		"....#",
+		"...#.",
+		"..#..",
+		".#...",
+		"#####",
+	},
+	"3": {
+		"####.",
+		"....#",
+		"....#",
+		".###.",
+		"....#",
+		"....#",
+		"####.",
+	},
+	"4": {
+		"...#.",
+		"..##.",
+		".#.#.",
+		"#..#.",
+		"#####",
+		"...#.",
+		"...#.",
+	},
+	"5": {
+		"#####",
+		"#....",
+		"####.",
+		"....#",
+		"....#",
+		"#...#",
+		".###.",
+	},
+	"6": {
+		"..##.",
+		".#...",
+		"#....",
+		"####.",
+		"#...#",
+		"#...#",
+		".###.",
+	},
+	"7": {
+		"#####",
+		"....#",
+		"...#.",
+		"..#..",
+		".#...",
+		".#...",
+		".#...",
+	},
+	"8": {
+		".###.",
+		"#...#",
+		"#...#",
+		".###.",
+		"#...#",
+		"#...#",
+		".###.",
+	},
+	"9": {
+		".###.",
+		"#...#",
+		"#...#",
+		".####",
+		"....#",
+		"...#.",
+		".##..",
+	},
+	".": {
+		"..",
+		"..",
+		"..",
+		"..",
+		"..",
+		"##",
+		"##",
+	},
+	"-": {
+		"....",
+		"....",
+		"....",
+		"####",
+		"....",
+		"....",
+		"....",
+	},
+}
+
+// ComposeAB saves the two candidate images side by side, scaled to a common
+// height and labeled A and B, as a PNG at dst
+func ComposeAB(pathA, pathB, dst string) error {
+	var images [2]image.Image
+	for i, path := range []string{pathA, pathB} {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open candidate: %w", err)
+		}
+		img, _, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode candidate: %w", err)
+		}
+		images[i] = img
+	}
+
+	height := compositeMaxHeight
+	for _, img := range images {
+		if h := img.Bounds().Dy(); h < height {
+			height = h
+		}
+	}
+
+	var widths [2]int
+	for i, img := range images {
+		b := img.Bounds()
+		widths[i] = max(1, b.Dx()*height/b.Dy())
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, widths[0]+compositeGap+widths[1], compositeBand+height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	x := 0
+	for i, label := range []string{ABWinnerA, ABWinnerB} {
+		scaled := resizeImage(images[i], widths[i], height)
+		draw.Draw(canvas, image.Rect(x, compositeBand, x+widths[i], compositeBand+height), scaled, image.Point{}, draw.Over)
+		drawGlyph(canvas, label, x+widths[i]/2, compositeBand/2)
+		x += widths[i] + compositeGap
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create composite: %w", err)
+	}
+	if err := png.Encode(file, canvas); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to encode composite: %w", err)
+	}
+	return file.Close()
+}
+
+// drawGlyph draws a label centered on (cx, cy)
+func drawGlyph(dst draw.Image, label string, cx, cy int) {
+	rows := glyphs[label]
+	x0 := cx - len(rows[0])*glyphScale/2
+	y0 := cy - len(rows)*glyphScale/2
+	for row, line := range rows {
+		for col, c := range line {
+			if c != '#' {
+				continue
+			}
+			cell := image.Rect(x0+col*glyphScale, y0+row*glyphScale, x0+(col+1)*glyphScale, y0+(row+1)*glyphScale)
+			draw.Draw(dst, cell, image.NewUniform(color.Black), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// drawLabel draws a label of several glyphs, one cell apart, centered on
+// (cx, cy). Characters without a glyph are skipped.
+func drawLabel(dst draw.Image, label string, cx, cy int) {
+	var chars []string
+	width := 0
+	for _, c := range label {
+		if rows, ok := glyphs[string(c)]; ok {
+			if len(chars) > 0 {
+				width++
+			}
+			chars = append(chars, string(c))
+			width += len(rows[0])
+		}
+	}
+	x := cx - width*glyphScale/2
+	for _, c := range chars {
+		cols := len(glyphs[c][0])
+		drawGlyph(dst, c, x+cols*glyphScale/2, cy)
+		x += (cols + 1) * glyphScale
+	}
+}
@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"time"
+)
+
+// Animation formats
+const (
+	AnimationGIF  = "gif"
+	AnimationAPNG = "apng"
+)
+
+// MaxAnimationFrames bounds the frames of an animation processed frame by
+// frame, since each frame costs a prediction
+const MaxAnimationFrames = 100
+
+// Animation is a decoded animated GIF or APNG. Every frame is a full canvas
+// with earlier frames already composited in, so frames can be processed on
+// their own and written back without disposal or blending.
+type Animation struct {
+	Format    string
+	Frames    []*image.NRGBA
+	Delays    []time.Duration
+	LoopCount int // 0 loops forever
+}
+
+// Ext returns the file extension for the animation's format
+func (a *Animation) Ext() string {
+	if a.Format == AnimationGIF {
+		return ".gif"
+	}
+	return ".png"
+}
+
+// LoadAnimation decodes the animated GIF or APNG at path. It returns nil
+// without an error for images that are not animated.
+func LoadAnimation(path string) (*Animation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	var anim *Animation
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		anim, err = decodeGIFAnimation(data)
+	case bytes.HasPrefix(data, []byte(pngSignature)):
+		anim, err = decodeAPNG(data)
+	}
+	if err != nil || anim == nil || len(anim.Frames) < 2 {
+		return nil, err
+	}
+	if len(anim.Frames) > MaxAnimationFrames {
+		return nil, fmt.Errorf("animation has %d frames; at most %d can be processed", len(anim.Frames), MaxAnimationFrames)
+	}
+	return anim, nil
+}
+
+// LoadFrame decodes a processed frame
+func LoadFrame(path string) (*image.NRGBA, error) {
+	img, err := decodeOriented(path)
+	if err != nil {
+		return nil, err
+	}
+	return toNRGBA(img), nil
+}
+
+// Save writes the animation to path in its format
+func (a *Animation) Save(path string) error {
+	var data []byte
+	var err error
+	if a.Format == AnimationGIF {
+		data, err = a.encodeGIF()
+	} else {
+		data, err = a.encodeAPNG()
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write animation: %w", err)
+	}
+	return nil
+}
+
+// decodeGIFAnimation composites the frames of a GIF onto its canvas
+func decodeGIFAnimation(data []byte) (*Animation, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GIF: %w", err)
+	}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	anim := &Animation{Format: AnimationGIF, LoopCount: g.LoopCount}
+	if g.LoopCount < 0 {
+		anim.LoopCount = 1 // Played once
+	}
+
+	canvas := image.NewNRGBA(bounds)
+	for i, frame := range g.Image {
+		var previous *image.NRGBA
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalPrevious {
+			previous = cloneNRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		anim.Frames = append(anim.Frames, cloneNRGBA(canvas))
+		anim.Delays = append(anim.Delays, time.Duration(g.Delay[i])*10*time.Millisecond)
+
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+			case gif.DisposalPrevious:
+				canvas = previous
+			}
+		}
+	}
+	return anim, nil
+}
+
+// encodeGIF writes full-canvas frames, quantized to a web palette with one
+// transparent entry
+func (a *Animation) encodeGIF() ([]byte, error) {
+	colors := make(color.Palette, 0, 256)
+	colors = append(colors, palette.Plan9[:255]...)
+	colors = append(colors, color.Transparent)
+	transparent := uint8(len(colors) - 1)
+
+	g := &gif.GIF{LoopCount: a.LoopCount}
+	if a.LoopCount == 1 {
+		g.LoopCount = -1
+	}
+	for i, frame := range a.Frames {
+		paletted := image.NewPaletted(frame.Bounds(), colors)
+		draw.FloydSteinberg.Draw(paletted, frame.Bounds(), frame, frame.Bounds().Min)
+		for y := frame.Rect.Min.Y; y < frame.Rect.Max.Y; y++ {
+			for x := frame.Rect.Min.X; x < frame.Rect.Max.X; x++ {
+				if frame.NRGBAAt(x, y).A < 128 {
+					paletted.SetColorIndex(x, y, transparent)
+				}
+			}
+		}
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, int(a.Delays[i]/(10*time.Millisecond)))
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		return nil, fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pngSignature starts every PNG file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// APNG frame control values
+const (
+	apngDisposeNone       = 0
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+	apngBlendSource       = 0
+)
+
+// apngFrame is one frame of an APNG as stored: a region of the canvas and
+// how it is composited
+type apngFrame struct {
+	x, y, width, height int
+	delay               time.Duration
+	dispose, blend      byte
+	data                []byte // Compressed image data
+}
+
+// decodeAPNG composites the frames of an APNG onto its canvas. It returns nil
+// for a PNG without an animation control chunk.
+func decodeAPNG(data []byte) (*Animation, error) {
+	var ihdr []byte
+	var shared [][]byte // Chunks every frame's image needs, such as PLTE and tRNS
+	var frames []*apngFrame
+	var defaultFrame *apngFrame // The frame the IDAT chunks belong to, if any
+	var loops int
+	animated := false
+	seenIDAT := false
+
+	for i := len(pngSignature); i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("invalid PNG chunk")
+		}
+		chunkType := string(data[i+4 : i+8])
+		body := data[i+8 : i+8+length]
+		chunk := data[i:end]
+		i = end
+
+		switch chunkType {
+		case "IHDR":
+			ihdr = body
+		case "acTL":
+			if len(body) < 8 {
+				return nil, fmt.Errorf("invalid acTL chunk")
+			}
+			animated = true
+			loops = int(binary.BigEndian.Uint32(body[4:8]))
+		case "fcTL":
+			if len(body) < 26 {
+				return nil, fmt.Errorf("invalid fcTL chunk")
+			}
+			num, den := binary.BigEndian.Uint16(body[20:22]), binary.BigEndian.Uint16(body[22:24])
+			if den == 0 {
+				den = 100
+			}
+			frames = append(frames, &apngFrame{
+				width:   int(binary.BigEndian.Uint32(body[4:8])),
+				height:  int(binary.BigEndian.Uint32(body[8:12])),
+				x:       int(binary.BigEndian.Uint32(body[12:16])),
+				y:       int(binary.BigEndian.Uint32(body[16:20])),
+				delay:   time.Duration(num) * time.Second / time.Duration(den),
+				dispose: body[24],
+				blend:   body[25],
+			})
+		case "IDAT":
+			// The default image is the first frame only when an fcTL precedes it
+			if !seenIDAT && len(frames) == 1 {
+				defaultFrame = frames[0]
+			}
+			seenIDAT = true
+			if defaultFrame != nil {
+				defaultFrame.data = append(defaultFrame.data, body...)
+			}
+		case "fdAT":
+			if len(frames) > 0 && len(body) >= 4 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, body[4:]...)
+			}
+		case "IEND":
+		default:
+			if !seenIDAT {
+				shared = append(shared, chunk)
+			}
+		}
+	}
+	if !animated || len(ihdr) < 13 {
+		return nil, nil
+	}
+
+	canvasWidth := int(binary.BigEndian.Uint32(ihdr[0:4]))
+	canvasHeight := int(binary.BigEndian.Uint32(ihdr[4:8]))
+	anim := &Animation{Format: AnimationAPNG, LoopCount: loops}
+	canvas := image.NewNRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
+	for n, frame := range frames {
+		if len(frame.data) == 0 {
+			continue
+		}
+		img, err := decodeAPNGFrame(ihdr, shared, frame)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", n+1, err)
+		}
+		region := image.Rect(frame.x, frame.y, frame.x+frame.width, frame.y+frame.height)
+
+		var previous *image.NRGBA
+		if frame.dispose == apngDisposePrevious {
+			previous = cloneNRGBA(canvas)
+		}
+		op := draw.Over
+		if frame.blend == apngBlendSource {
+			op = draw.Src
+		}
+		draw.Draw(canvas, region, img, img.Bounds().Min, op)
+		anim.Frames = append(anim.Frames, cloneNRGBA(canvas))
+		anim.Delays = append(anim.Delays, frame.delay)
+
+		switch frame.dispose {
+		case apngDisposeBackground:
+			draw.Draw(canvas, region, image.Transparent, image.Point{}, draw.Src)
+		case apngDisposePrevious:
+			canvas = previous
+		}
+	}
+	return anim, nil
+}
+
+// decodeAPNGFrame decodes one frame's image data as a standalone PNG
+func decodeAPNGFrame(ihdr []byte, shared [][]byte, frame *apngFrame) (image.Image, error) {
+	header := append([]byte(nil), ihdr...)
+	binary.BigEndian.PutUint32(header[0:4], uint32(frame.width))
+	binary.BigEndian.PutUint32(header[4:8], uint32(frame.height))
+
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+	buf.Write(pngChunk("IHDR", header))
+	for _, chunk := range shared {
+		buf.Write(chunk)
+	}
+	buf.Write(pngChunk("IDAT", frame.data))
+	buf.Write(pngChunk("IEND", nil))
+	return png.Decode(&buf)
+}
+
+// encodeAPNG writes full-canvas 8-bit RGBA frames that replace each other
+func (a *Animation) encodeAPNG() ([]byte, error) {
+	bounds := a.Frames[0].Bounds()
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(bounds.Dy()))
+	ihdr[8], ihdr[9] = 8, 6 // 8-bit RGBA
+	buf.Write(pngChunk("IHDR", ihdr))
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:4], uint32(len(a.Frames)))
+	binary.BigEndian.PutUint32(actl[4:8], uint32(a.LoopCount))
+	buf.Write(pngChunk("acTL", actl))
+
+	var sequence uint32
+	for i, frame := range a.Frames {
+		if frame.Bounds().Size() != bounds.Size() {
+			return nil, fmt.Errorf("frame %d is %dx%d, not %dx%d", i+1, frame.Bounds().Dx(), frame.Bounds().Dy(), bounds.Dx(), bounds.Dy())
+		}
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:4], sequence)
+		binary.BigEndian.PutUint32(fctl[4:8], uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:12], uint32(bounds.Dy()))
+		binary.BigEndian.PutUint16(fctl[20:22], uint16(a.Delays[i]/time.Millisecond))
+		binary.BigEndian.PutUint16(fctl[22:24], 1000)
+		fctl[24], fctl[25] = apngDisposeNone, apngBlendSource
+		buf.Write(pngChunk("fcTL", fctl))
+		sequence++
+
+		compressed, err := compressRGBA(frame)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			buf.Write(pngChunk("IDAT", compressed))
+			continue
+		}
+		fdat := binary.BigEndian.AppendUint32(nil, sequence)
+		buf.Write(pngChunk("fdAT", append(fdat, compressed...)))
+		sequence++
+	}
+	buf.Write(pngChunk("IEND", nil))
+	return buf.Bytes(), nil
+}
+
+// compressRGBA returns the zlib-compressed, unfiltered scanlines of img, as
+// PNG image data for 8-bit RGBA
+func compressRGBA(img *image.NRGBA) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	width := img.Rect.Dx() * 4
+	for y := 0; y < img.Rect.Dy(); y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+width]
+		if _, err := w.Write(append([]byte{0}, row...)); err != nil {
+			return nil, fmt.Errorf("failed to compress frame: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// cloneNRGBA returns a copy of img
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	out := image.NewNRGBA(img.Rect)
+	copy(out.Pix, img.Pix)
+	return out
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// AutoCropOptions controls trimming of transparent borders
+type AutoCropOptions struct {
+	Padding     int    // Transparent margin kept around the subject, in pixels
+	AspectRatio string // Optional "square" or "W:H"; the crop is padded out to it
+}
+
+// ParseAspectRatio parses "square" or a "W:H" ratio into its width and height
+// terms
+func ParseAspectRatio(ratio string) (float64, float64, error) {
+	if ratio == "square" {
+		return 1, 1, nil
+	}
+	parts := strings.Split(ratio, ":")
+	if len(parts) == 2 {
+		w, errW := strconv.ParseFloat(parts[0], 64)
+		h, errH := strconv.ParseFloat(parts[1], 64)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			return w, h, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid aspect ratio %q: use square or W:H, e.g. 4:5", ratio)
+}
+
+// AutoCrop trims the transparent border of the image at path down to its
+// visible content, adds padding, and pads the result with transparency to the
+// requested aspect ratio, keeping the content centered. It returns the crop
+// as a PNG and the content's bounds within the original image.
+func AutoCrop(path string, opts AutoCropOptions) ([]byte, image.Rectangle, error) {
+	src, err := decodeFile(path)
+	if err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("failed to load image: %w", err)
+	}
+	if opts.Padding < 0 {
+		return nil, image.Rectangle{}, fmt.Errorf("padding must not be negative")
+	}
+
+	img := toNRGBA(src)
+	visible := opaqueBounds(img)
+	if visible.Empty() {
+		return nil, image.Rectangle{}, fmt.Errorf("the image is fully transparent")
+	}
+
+	width := visible.Dx() + 2*opts.Padding
+	height := visible.Dy() + 2*opts.Padding
+	if opts.AspectRatio != "" {
+		rw, rh, err := ParseAspectRatio(opts.AspectRatio)
+		if err != nil {
+			return nil, image.Rectangle{}, err
+		}
+		if float64(width)/float64(height) < rw/rh {
+			width = int(float64(height)*rw/rh + 0.5)
+		} else {
+			height = int(float64(width)*rh/rw + 0.5)
+		}
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	offset := image.Pt((width-visible.Dx())/2, (height-visible.Dy())/2)
+	draw.Draw(canvas, visible.Sub(visible.Min).Add(offset), img, visible.Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("failed to encode crop: %w", err)
+	}
+	return buf.Bytes(), visible, nil
+}
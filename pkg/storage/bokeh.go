@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+// BokehOptions controls how the background of a photo is blurred
+type BokehOptions struct {
+	Strength float64 // 0-1: blur of the farthest background, relative to the image size
+	Feather  float64 // 0-1: softness of the subject's edge
+}
+
+// blurLevels is the number of precomputed blur radii that depth-aware blur
+// interpolates between
+const blurLevels = 4
+
+// BlurBackground blurs everything but the subject of a photo and returns the
+// result as a PNG. The subject is taken from the alpha channel of cutoutPath,
+// a background-removed version of the photo. When depthPath is given, it is
+// read as a grayscale depth map (brighter is nearer), and background farther
+// from the camera than the subject is blurred more; otherwise the whole
+// background is blurred evenly. The cutout and depth map are resized to the
+// photo when their sizes differ.
+func BlurBackground(imagePath, cutoutPath, depthPath string, opts BokehOptions) ([]byte, error) {
+	src, err := decodeFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", err)
+	}
+	photo := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(photo, photo.Bounds(), src, src.Bounds().Min, draw.Src)
+	w, h := photo.Bounds().Dx(), photo.Bounds().Dy()
+
+	cutout, err := decodeFile(cutoutPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cutout: %w", err)
+	}
+	subject := make([]float64, w*h)
+	for i, a := range channel(resizeTo(cutout, w, h), 3) {
+		subject[i] = a / 255
+	}
+
+	// Soften the subject's edge so hair and fur blend into the blur
+	longEdge := max(w, h)
+	if feather := int(opts.Feather * float64(longEdge) / 200); feather > 0 {
+		subject = boxBlur(subject, w, h, feather)
+	}
+
+	// How far each pixel is blurred, 0-1
+	amount := make([]float64, w*h)
+	for i := range amount {
+		amount[i] = 1
+	}
+	if depthPath != "" {
+		depthImg, err := decodeFile(depthPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load depth map: %w", err)
+		}
+		depth := channel(resizeTo(depthImg, w, h), 0)
+
+		// The subject's typical depth is the in-focus plane; the farthest
+		// point gets the full blur
+		var subjectDepth, weight float64
+		farthest := 255.0
+		for i, d := range depth {
+			subjectDepth += d * subject[i]
+			weight += subject[i]
+			farthest = min(farthest, d)
+		}
+		if weight > 0 && subjectDepth/weight > farthest {
+			subjectDepth /= weight
+			for i, d := range depth {
+				amount[i] = min(1, max(0, (subjectDepth-d)/(subjectDepth-farthest)))
+			}
+		}
+	}
+
+	// Blur the background alone at each level, normalized by the blurred
+	// background weight so the subject's colors don't bleed into it
+	maxRadius := max(1, int(opts.Strength*float64(longEdge)/30))
+	background := make([]float64, w*h)
+	for i, s := range subject {
+		background[i] = 1 - s
+	}
+	var levels [blurLevels + 1][3][]float64
+	for ch := 0; ch < 3; ch++ {
+		levels[0][ch] = channel(photo, ch)
+	}
+	for level := 1; level <= blurLevels; level++ {
+		radius := max(1, maxRadius*level/blurLevels)
+		weights := blur2(background, w, h, radius)
+		for ch := 0; ch < 3; ch++ {
+			premultiplied := make([]float64, w*h)
+			for i, v := range levels[0][ch] {
+				premultiplied[i] = v * background[i]
+			}
+			blurred := blur2(premultiplied, w, h, radius)
+			for i := range blurred {
+				if weights[i] > 0.01 {
+					blurred[i] /= weights[i]
+				} else {
+					blurred[i] = levels[0][ch][i]
+				}
+			}
+			levels[level][ch] = blurred
+		}
+	}
+
+	out := image.NewRGBA(photo.Bounds())
+	copy(out.Pix, photo.Pix)
+	for i := range subject {
+		position := amount[i] * blurLevels
+		lower := min(int(position), blurLevels-1)
+		frac := position - float64(lower)
+		for ch := 0; ch < 3; ch++ {
+			blurred := levels[lower][ch][i]*(1-frac) + levels[lower+1][ch][i]*frac
+			value := levels[0][ch][i]*subject[i] + blurred*(1-subject[i])
+			out.Pix[i*4+ch] = uint8(min(255, max(0, value+0.5)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// resizeTo returns the image at exactly w x h
+func resizeTo(img image.Image, w, h int) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds() == image.Rect(0, 0, w, h) {
+		return rgba
+	}
+	return resizeImage(img, w, h)
+}
+
+// channel returns one channel of an RGBA image as a grid of values
+func channel(img *image.RGBA, ch int) []float64 {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	values := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < w; x++ {
+			values[y*w+x] = float64(row[x*4+ch])
+		}
+	}
+	return values
+}
+
+// blur2 applies two box blur passes, which approximate a Gaussian
+func blur2(src []float64, w, h, radius int) []float64 {
+	return boxBlur(boxBlur(src, w, h, radius), w, h, radius)
+}
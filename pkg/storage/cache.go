@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// cacheIndexFile maps request cache keys to storage IDs in the storage root
+const cacheIndexFile = "cache.json"
+
+// cacheMu serializes cache index updates within the process
+var cacheMu sync.Mutex
+
+// CachedResult is a previously completed operation that matches a request
+type CachedResult struct {
+	Metadata  *types.ImageMetadata
+	FilePaths []string
+}
+
+// CacheKey returns a stable hash of an operation, its model and the exact
+// model input. Map keys are marshalled in sorted order, so identical inputs
+// always produce the same key; inline files are hashed by their contents.
+func CacheKey(operation, model string, input map[string]interface{}) (string, error) {
+	hasher := sha256.New()
+	err := types.EncodeJSON(hasher, map[string]interface{}{
+		"operation": operation,
+		"model":     model,
+		"input":     input,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash request: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// LookupCache returns the stored result for a cache key. Entries whose
+// metadata or output files have since been removed are treated as misses.
+func (s *Storage) LookupCache(key string) (*CachedResult, bool) {
+	cacheMu.Lock()
+	index, err := s.readCacheIndex()
+	cacheMu.Unlock()
+	if err != nil {
+		return nil, false
+	}
+
+	id, ok := index[key]
+	if !ok {
+		return nil, false
+	}
+
+	metadata, err := s.LoadMetadata(id)
+	if err != nil || metadata.Result == nil {
+		return nil, false
+	}
+
+	files := metadata.Result.Files
+	if len(files) == 0 {
+		files = []string{metadata.Result.Filename}
+	}
+
+	paths := make([]string, len(files))
+	for i, name := range files {
+		paths[i] = s.GetImagePath(id, name)
+		if _, err := os.Stat(paths[i]); err != nil {
+			return nil, false
+		}
+	}
+
+	return &CachedResult{Metadata: metadata, FilePaths: paths}, true
+}
+
+// StoreCache records the storage ID holding the result for a cache key
+func (s *Storage) StoreCache(key, id string) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	index, err := s.readCacheIndex()
+	if err != nil {
+		return err
+	}
+	index[key] = id
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache index: %w", err)
+	}
+
+	// Write atomically so a crash never leaves a truncated index
+	path := filepath.Join(s.rootPath, cacheIndexFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	return nil
+}
+
+// readCacheIndex loads the cache index, returning an empty index if none exists
+func (s *Storage) readCacheIndex() (map[string]string, error) {
+	index := map[string]string{}
+	data, err := os.ReadFile(filepath.Join(s.rootPath, cacheIndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		return nil, fmt.Errorf("failed to read cache index: %w", err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		// A corrupt index only loses cache hits; start over rather than failing
+		slog.Warn("ignoring unreadable cache index", "error", err)
+		return map[string]string{}, nil
+	}
+	return index, nil
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+)
+
+// Comparison layout, in pixels
+const (
+	DefaultCropSize     = 256
+	comparisonMaxHeight = 1024
+	comparisonMinHeight = 512
+)
+
+// MaxComparisonTiles is the number of labeled tiles a comparison can hold
+const MaxComparisonTiles = 5
+
+// MaxSweepTiles is the number of values a parameter sweep strip can hold
+const MaxSweepTiles = 8
+
+// sweepTileHeight caps the height of sweep tiles, which keeps a strip of
+// full-size generations a reasonable width
+const sweepTileHeight = 512
+
+// CropImage returns a region of the upright image at path as a PNG, along
+// with the region actually cropped. The region is clamped to the image
+// bounds; an empty region selects a DefaultCropSize square at the center.
+func CropImage(path string, region image.Rectangle) ([]byte, image.Rectangle, error) {
+	img, err := decodeOriented(path)
+	if err != nil {
+		return nil, image.Rectangle{}, err
+	}
+	bounds := img.Bounds()
+
+	if region.Empty() {
+		size := min(DefaultCropSize, bounds.Dx(), bounds.Dy())
+		x := bounds.Min.X + (bounds.Dx()-size)/2
+		y := bounds.Min.Y + (bounds.Dy()-size)/2
+		region = image.Rect(x, y, x+size, y+size)
+	} else {
+		region = region.Add(bounds.Min).Intersect(bounds)
+		if region.Empty() {
+			return nil, image.Rectangle{}, fmt.Errorf("crop region lies outside the %dx%d image", bounds.Dx(), bounds.Dy())
+		}
+	}
+
+	crop := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(crop, crop.Bounds(), img, region.Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, crop); err != nil {
+		return nil, image.Rectangle{}, fmt.Errorf("failed to encode crop: %w", err)
+	}
+	return buf.Bytes(), region.Sub(bounds.Min), nil
+}
+
+// ComposeComparison places images side by side at a common height, labeled
+// 1, 2, 3... in order, and returns the result as a PNG. The height follows
+// the largest image so upscaled detail is shown at full size; smaller images
+// are enlarged with nearest-neighbor sampling so their pixels stay visible.
+func ComposeComparison(paths []string) ([]byte, error) {
+	if len(paths) == 0 || len(paths) > MaxComparisonTiles {
+		return nil, fmt.Errorf("a comparison needs 1-%d images", MaxComparisonTiles)
+	}
+
+	images := make([]image.Image, len(paths))
+	height := comparisonMinHeight
+	for i, path := range paths {
+		img, err := decodeFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		images[i] = img
+		height = max(height, img.Bounds().Dy())
+	}
+	height = min(height, comparisonMaxHeight)
+
+	labels := make([]string, len(images))
+	for i := range labels {
+		labels[i] = strconv.Itoa(i + 1)
+	}
+	return composeStrip(images, labels, height)
+}
+
+// ComposeSweep places the results of a parameter sweep side by side at a
+// common height, each labeled with its value, and returns the result as a
+// PNG. Tiles take the height of the smallest image, at most 512 pixels.
+func ComposeSweep(paths []string, labels []string) ([]byte, error) {
+	if len(paths) == 0 || len(paths) > MaxSweepTiles {
+		return nil, fmt.Errorf("a sweep strip needs 1-%d images", MaxSweepTiles)
+	}
+	if len(labels) != len(paths) {
+		return nil, fmt.Errorf("a sweep strip needs a label for each image")
+	}
+
+	images := make([]image.Image, len(paths))
+	height := sweepTileHeight
+	for i, path := range paths {
+		img, err := decodeOriented(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		images[i] = img
+		height = min(height, img.Bounds().Dy())
+	}
+	return composeStrip(images, labels, height)
+}
+
+// composeStrip draws images scaled to height in a row, with each label
+// centered in the band above its image, and encodes the result as a PNG
+func composeStrip(images []image.Image, labels []string, height int) ([]byte, error) {
+	widths := make([]int, len(images))
+	total := compositeGap * (len(images) - 1)
+	for i, img := range images {
+		b := img.Bounds()
+		widths[i] = max(1, b.Dx()*height/b.Dy())
+		total += widths[i]
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, total, compositeBand+height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	x := 0
+	for i, img := range images {
+		scaled := resizeImage(img, widths[i], height)
+		draw.Draw(canvas, image.Rect(x, compositeBand, x+widths[i], compositeBand+height), scaled, image.Point{}, draw.Over)
+		drawLabel(canvas, labels[i], x+widths[i]/2, compositeBand/2)
+		x += widths[i] + compositeGap
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode strip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
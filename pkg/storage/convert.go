@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConvertOutputs replaces every output of an operation with a copy in the
+// format of ext. convert writes dst, a temp file in the same directory with
+// that extension; the output is renamed to the new extension, and the
+// original removed, only when convert succeeds. Outputs that already have the
+// extension are left as they are. parameters are added to the operation's
+// metadata parameters, and the recorded filenames, size, and checksum are
+// refreshed. It returns the new path of each converted output by its old path.
+func (s *Storage) ConvertOutputs(id, ext string, parameters map[string]interface{}, convert func(src, dst string) error) (map[string]string, error) {
+	metadata, files, err := s.loadOutputs(id)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make(map[string]string)
+	var convertErr error
+	for i, name := range files {
+		if sameExt(filepath.Ext(name), ext) {
+			continue
+		}
+		newName := strings.TrimSuffix(name, filepath.Ext(name)) + ext
+		imagePath, newPath := s.GetImagePath(id, name), s.GetImagePath(id, newName)
+		if convertErr = convertFile(imagePath, newPath, convert); convertErr != nil {
+			convertErr = fmt.Errorf("%s: %w", name, convertErr)
+			break
+		}
+		converted[imagePath] = newPath
+
+		if i < len(metadata.Result.Files) {
+			metadata.Result.Files[i] = newName
+		}
+		if name == metadata.Result.Filename {
+			metadata.Result.Filename = newName
+			if err := refreshFileInfo(metadata.Result, newPath); err != nil {
+				convertErr = err
+				break
+			}
+		}
+	}
+
+	// Record the outputs converted so far even when a later one failed
+	if convertErr == nil {
+		if metadata.Parameters == nil {
+			metadata.Parameters = make(map[string]interface{})
+		}
+		for k, v := range parameters {
+			metadata.Parameters[k] = v
+		}
+	}
+	if err := s.SaveMetadata(id, metadata); err != nil {
+		return converted, err
+	}
+	return converted, convertErr
+}
+
+// convertFile writes the conversion of src to dst through a temp file with
+// dst's extension, removing src once dst is in place
+func convertFile(src, dst string, convert func(src, dst string) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), tempFilePrefix+"*"+filepath.Ext(dst))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	if err := convert(src, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return fmt.Errorf("failed to replace image: %w", err)
+	}
+	return os.Remove(src)
+}
+
+// sameExt reports whether two file extensions name the same format
+func sameExt(a, b string) bool {
+	normalize := func(ext string) string {
+		ext = strings.ToLower(ext)
+		if ext == ".jpeg" {
+			return ".jpg"
+		}
+		return ext
+	}
+	return normalize(a) == normalize(b)
+}
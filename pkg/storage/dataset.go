@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// DatasetArchive is the name of the zip written into a prepared dataset
+const DatasetArchive = "dataset.zip"
+
+// Crop modes for training images
+const (
+	CropCenter  = "center"  // Largest centered square
+	CropFace    = "face"    // Square around a face, with head and shoulders
+	CropSubject = "subject" // Square around a detected subject
+	CropNone    = "none"    // Whole image, fit within the resolution
+)
+
+// TrainingImage is a source image cropped and resized for training
+type TrainingImage struct {
+	PNG      []byte
+	Crop     image.Rectangle // Region of the source image that was used
+	Hash     uint64          // Difference hash for finding near-duplicates
+	Enlarged bool            // The crop was smaller than the training resolution
+}
+
+// datasetImageExtensions are the file extensions treated as images when
+// scanning a dataset folder
+var datasetImageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".webp": true,
+	".bmp":  true,
+	".gif":  true,
+}
+
+// ListImages returns the image files in dir, sorted by path. Hidden files and
+// directories are skipped; subdirectories are searched only when recursive is
+// set.
+func ListImages(dir string, recursive bool) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	var paths []string
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		hidden := strings.HasPrefix(entry.Name(), ".")
+		if entry.IsDir() {
+			if hidden || !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !hidden && datasetImageExtensions[strings.ToLower(filepath.Ext(path))] {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// CaptionPath returns the path of the .txt caption sidecar for an image
+func CaptionPath(imagePath string) string {
+	return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".txt"
+}
+
+// PrepareTrainingImage crops the image at path for training and resizes it.
+// For the face and subject modes, box is the detection in source pixel
+// coordinates and the crop is the square around it; the center and none
+// modes ignore it. Every mode but none produces a resolution x resolution
+// square; none keeps the aspect ratio with the long edge at resolution.
+func PrepareTrainingImage(path, mode string, box image.Rectangle, resolution int) (*TrainingImage, error) {
+	src, err := decodeOriented(path)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var crop image.Rectangle
+	switch mode {
+	case CropNone:
+		crop = image.Rect(0, 0, w, h)
+	case CropFace, CropSubject:
+		if box.Empty() {
+			return nil, fmt.Errorf("no detection to crop around")
+		}
+		side := max(box.Dx(), box.Dy())
+		if mode == CropFace {
+			// Keep the head and shoulders in frame
+			side = side * 5 / 2
+		} else {
+			side = side * 11 / 10
+		}
+		center := image.Pt((box.Min.X+box.Max.X)/2, (box.Min.Y+box.Max.Y)/2)
+		crop = squareAround(center, side, w, h)
+	default:
+		crop = squareAround(image.Pt(w/2, h/2), min(w, h), w, h)
+	}
+
+	outW, outH := resolution, resolution
+	if mode == CropNone {
+		if w >= h {
+			outH = max(1, h*resolution/w)
+		} else {
+			outW = max(1, w*resolution/h)
+		}
+	}
+
+	cropped := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), src, crop.Min.Add(bounds.Min), draw.Src)
+	resized := resizeImage(cropped, outW, outH)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resized); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return &TrainingImage{
+		PNG:      buf.Bytes(),
+		Crop:     crop,
+		Hash:     DifferenceHash(cropped),
+		Enlarged: crop.Dx() < outW || crop.Dy() < outH,
+	}, nil
+}
+
+// decodeOriented decodes the image at path upright. Phone photos are often
+// stored sideways with an EXIF rotation; it is applied as PrepareInput does,
+// so detections line up.
+func decodeOriented(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if data, _, err = normalizeExif(data); err != nil {
+		return nil, fmt.Errorf("failed to normalize EXIF orientation: %w", err)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image: %w", err)
+	}
+	return src, nil
+}
+
+// squareAround returns the square of the given side centered on center,
+// shrunk and shifted as needed to fit within a w x h image
+func squareAround(center image.Point, side, w, h int) image.Rectangle {
+	side = min(side, w, h)
+	x := min(max(0, center.X-side/2), w-side)
+	y := min(max(0, center.Y-side/2), h-side)
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// DifferenceHash returns a 64-bit perceptual hash of an image: each bit
+// records whether a pixel of a 9x8 grayscale thumbnail is brighter than its
+// right-hand neighbor. Near-identical images have hashes a few bits apart.
+func DifferenceHash(img image.Image) uint64 {
+	thumb := resizeImage(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := luminance(thumb, x, y)
+			right := luminance(thumb, x+1, y)
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashDistance returns the number of bits that differ between two hashes
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// luminance returns the brightness of one pixel of an RGBA image
+func luminance(img *image.RGBA, x, y int) int {
+	p := img.Pix[y*img.Stride+x*4:]
+	return (299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])) / 1000
+}
+
+// FinalizeDataset records the files written into an operation's directory as
+// a prepare_dataset result, zipping them into DatasetArchive first when
+// archive is set. It returns the archive's path, or "" without one.
+func (s *Storage) FinalizeDataset(id string, parameters map[string]interface{}, archive bool) (string, error) {
+	name := ""
+	if archive {
+		name = DatasetArchive
+	}
+	return s.FinalizeFiles(id, "prepare_dataset", parameters, name)
+}
+
+// FinalizeFiles records the files written into an operation's directory as
+// the result of operation, first zipping them into an archive with the given
+// name unless it is "". It returns the archive's path, or "" without one.
+func (s *Storage) FinalizeFiles(id, operation string, parameters map[string]interface{}, archive string) (string, error) {
+	dir := s.OperationDir(id)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", id, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && entry.Name() != archive && !strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			files = append(files, entry.Name())
+		}
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files were written")
+	}
+
+	result := &types.OperationResult{Filename: files[0], Files: files}
+	archivePath := ""
+	if archive != "" {
+		archivePath = filepath.Join(dir, archive)
+		if err := zipFiles(archivePath, dir, files); err != nil {
+			os.Remove(archivePath)
+			return "", err
+		}
+		result.Filename = archive
+		result.Files = append(result.Files, archive)
+	}
+	if err := refreshFileInfo(result, filepath.Join(dir, result.Filename)); err != nil {
+		return "", err
+	}
+
+	metadata := &types.ImageMetadata{
+		ID:         id,
+		Operation:  operation,
+		Timestamp:  time.Now(),
+		Model:      LocalModel,
+		Parameters: parameters,
+		Result:     result,
+	}
+	if err := s.SaveMetadata(id, metadata); err != nil {
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// zipFiles writes the named files from dir into a zip archive at path
+func zipFiles(path, dir string, names []string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, name := range names {
+		if err := addToZip(zw, filepath.Join(dir, name), name); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return out.Close()
+}
+
+// addToZip copies one file into a zip archive
+func addToZip(zw *zip.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer file.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// debugBundleFile is the name of the debug bundle written to each storage directory
+const debugBundleFile = "debug.json"
+
+// DebugBundle collects everything needed to report or reproduce an operation:
+// the resolved model, its input (without binary data), the raw prediction
+// responses, and a timing breakdown. A nil bundle is valid and records nothing.
+type DebugBundle struct {
+	StorageID   string                 `json:"storage_id"`
+	Operation   string                 `json:"operation"`
+	Model       string                 `json:"model"`
+	Input       map[string]interface{} `json:"input,omitempty"`
+	Predictions []interface{}          `json:"predictions,omitempty"`
+	Timings     []DebugTiming          `json:"timings"`
+	TotalTime   float64                `json:"total_time"`
+	Error       string                 `json:"error,omitempty"`
+	StartedAt   time.Time              `json:"started_at"`
+
+	lastMark   time.Time
+	lastStatus string
+}
+
+// DebugTiming is the duration of one stage of an operation
+type DebugTiming struct {
+	Stage   string  `json:"stage"`
+	Seconds float64 `json:"seconds"`
+}
+
+// NewDebugBundle starts a debug bundle for an operation. It returns nil when
+// debug mode is disabled.
+func NewDebugBundle(enabled bool, id, operation, model string) *DebugBundle {
+	if !enabled {
+		return nil
+	}
+	now := time.Now()
+	return &DebugBundle{
+		StorageID: id,
+		Operation: operation,
+		Model:     model,
+		Timings:   []DebugTiming{},
+		StartedAt: now,
+		lastMark:  now,
+	}
+}
+
+// SetInput records the model input, replacing data URLs with a size summary
+func (b *DebugBundle) SetInput(input map[string]interface{}) {
+	if b == nil {
+		return
+	}
+	b.Input = SummarizeInput(input)
+}
+
+// SummarizeInput returns a copy of a model input with inline files and data
+// URLs replaced by a size summary, fit for logs and responses
+func SummarizeInput(input map[string]interface{}) map[string]interface{} {
+	summary, _ := sanitizeDebugValue(input).(map[string]interface{})
+	return summary
+}
+
+// Record adds a raw prediction response. Consecutive responses with the same
+// status replace each other so polling does not flood the bundle.
+func (b *DebugBundle) Record(prediction *types.ReplicatePredictionResponse) {
+	if b == nil || prediction == nil {
+		return
+	}
+
+	// Round-trip through JSON to capture the response exactly as received
+	raw, err := json.Marshal(prediction)
+	if err != nil {
+		return
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return
+	}
+	entry := sanitizeDebugValue(generic)
+
+	if prediction.Status == b.lastStatus && len(b.Predictions) > 0 {
+		b.Predictions[len(b.Predictions)-1] = entry
+		return
+	}
+	b.Predictions = append(b.Predictions, entry)
+	b.lastStatus = prediction.Status
+}
+
+// Stage records the time spent since the previous stage
+func (b *DebugBundle) Stage(name string) {
+	if b == nil {
+		return
+	}
+	now := time.Now()
+	b.Timings = append(b.Timings, DebugTiming{Stage: name, Seconds: now.Sub(b.lastMark).Seconds()})
+	b.lastMark = now
+}
+
+// SaveDebugBundle writes the bundle to debug.json in its storage directory,
+// recording the operation's final error, if any
+func (s *Storage) SaveDebugBundle(b *DebugBundle, opErr error) error {
+	if b == nil {
+		return nil
+	}
+	if opErr != nil {
+		b.Error = opErr.Error()
+	}
+	b.TotalTime = time.Since(b.StartedAt).Seconds()
+
+	stored := *b
+	if s.options.Prompts != nil {
+		stored.Input = s.options.Prompts.sealMap(b.Input)
+		stored.Predictions, _ = s.options.Prompts.transform(b.Predictions, "", s.options.Prompts.Seal).([]interface{})
+	}
+
+	data, err := json.MarshalIndent(&stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal debug bundle: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.rootPath, b.StorageID, debugBundleFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write debug bundle: %w", err)
+	}
+	return nil
+}
+
+// sanitizeDebugValue copies a JSON-like value, replacing data URLs with their size
+func sanitizeDebugValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		if strings.HasPrefix(val, "data:") {
+			if idx := strings.Index(val, ","); idx != -1 {
+				return fmt.Sprintf("%s<%d bytes>", val[:idx+1], len(val)-idx-1)
+			}
+		}
+		return val
+	case *types.FileData:
+		return val.String()
+	case []*types.FileData:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = item.String()
+		}
+		return out
+	case []string:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = sanitizeDebugValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = sanitizeDebugValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = sanitizeDebugValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Download pool defaults
+const (
+	DefaultMaxParallelDownloads = 4
+	downloadAttempts            = 3
+	downloadBackoff             = 500 * time.Millisecond
+)
+
+// newDownloadClient returns an HTTP client whose transport keeps enough idle
+// connections per host for the download pool to reuse them
+func newDownloadClient(parallel int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = parallel
+	return &http.Client{Transport: transport}
+}
+
+// MaxParallelDownloads returns the size of the download pool, which also
+// bounds how many input images an operation prepares at once
+func (s *Storage) MaxParallelDownloads() int {
+	return s.options.MaxParallelDownloads
+}
+
+// ErrPrivateAddress is returned for an input URL whose host resolves to a
+// loopback, private, link-local or otherwise non-public address
+var ErrPrivateAddress = errors.New("URL resolves to a non-public address")
+
+// nonPublicNets are the ranges, beyond those net.IP classifies, that an input
+// URL may not reach: "this network", carrier-grade NAT, IETF protocol
+// assignments and benchmarking
+var nonPublicNets = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),
+	mustCIDR("100.64.0.0/10"),
+	mustCIDR("192.0.0.0/24"),
+	mustCIDR("198.18.0.0/15"),
+}
+
+func mustCIDR(cidr string) *net.IPNet {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// isPublicIP reports whether ip is an address on the public internet
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newInputClient returns the HTTP client that downloads input URLs. Its
+// dialer refuses non-public addresses after DNS resolution, redirects
+// included, so a URL passed as a tool argument cannot reach this machine or
+// its network. It ignores proxy settings, whose address would be checked
+// instead of the destination's.
+func newInputClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport}
+}
+
+// fetch downloads a URL with client using a slot from the download pool,
+// retrying transient failures (network errors, 429 and 5xx responses) with
+// backoff. The returned release function frees the pool slot and must be
+// called once the response body has been consumed.
+func (s *Storage) fetch(client *http.Client, url string) (*http.Response, func(), error) {
+	s.downloadSlots <- struct{}{}
+	release := func() { <-s.downloadSlots }
+
+	var lastErr error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(downloadBackoff * time.Duration(1<<(attempt-2)))
+		}
+
+		resp, err := client.Get(url)
+		if err != nil {
+			lastErr = &DownloadError{Err: err}
+			if !isTransientNetError(err) {
+				break
+			}
+			slog.Debug("download failed, retrying", "attempt", attempt, "error", err)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return resp, release, nil
+		}
+		resp.Body.Close()
+
+		lastErr = &DownloadError{StatusCode: resp.StatusCode}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			// 4xx responses (such as expired URLs) will not succeed on retry
+			break
+		}
+		slog.Debug("download failed, retrying", "attempt", attempt, "status", resp.StatusCode)
+	}
+
+	release()
+	return nil, nil, lastErr
+}
+
+// isTransientNetError reports whether a request error is worth retrying
+func isTransientNetError(err error) bool {
+	if errors.Is(err, ErrPrivateAddress) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
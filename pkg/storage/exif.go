@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+// EXIF tags used during input preparation
+const (
+	exifTagOrientation = 0x0112
+	exifTagGPSInfo     = 0x8825
+)
+
+// exifInfo contains the EXIF fields relevant to input preparation
+type exifInfo struct {
+	start       int // Offset of the APP1 segment in the JPEG
+	end         int // Offset just past the APP1 segment
+	orientation int
+	hasGPS      bool
+}
+
+// normalizeExif applies EXIF orientation to JPEG data and strips EXIF blocks
+// containing GPS coordinates. Non-JPEG data is returned unchanged.
+func normalizeExif(data []byte) ([]byte, []string, error) {
+	info := findExif(data)
+	if info == nil {
+		return data, nil, nil
+	}
+
+	var notes []string
+
+	// Rotation requires re-encoding, which drops all EXIF (including GPS)
+	if info.orientation > 1 && info.orientation <= 8 {
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, applyOrientation(img, info.orientation), &jpeg.Options{Quality: 95}); err != nil {
+			return nil, nil, err
+		}
+
+		notes = append(notes, "applied EXIF orientation and removed EXIF metadata")
+		return buf.Bytes(), notes, nil
+	}
+
+	// Drop the EXIF segment losslessly when it contains location data
+	if info.hasGPS {
+		stripped := make([]byte, 0, len(data)-(info.end-info.start))
+		stripped = append(stripped, data[:info.start]...)
+		stripped = append(stripped, data[info.end:]...)
+		notes = append(notes, "removed EXIF metadata containing GPS location")
+		return stripped, notes, nil
+	}
+
+	return data, nil, nil
+}
+
+// findExif locates and parses the EXIF APP1 segment of a JPEG
+func findExif(data []byte) *exifInfo {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+
+		// Stop at start of scan or end of image; no metadata follows
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+
+		payload := data[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			info := parseExifIFD0(payload[6:])
+			info.start = i
+			info.end = end
+			return info
+		}
+
+		i = end
+	}
+
+	return nil
+}
+
+// parseExifIFD0 reads the orientation and GPS pointer from the first TIFF IFD
+func parseExifIFD0(tiff []byte) *exifInfo {
+	info := &exifInfo{orientation: 1}
+	if len(tiff) < 8 {
+		return info
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return info
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return info
+	}
+
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+
+		switch order.Uint16(tiff[entry : entry+2]) {
+		case exifTagOrientation:
+			info.orientation = int(order.Uint16(tiff[entry+8 : entry+10]))
+		case exifTagGPSInfo:
+			info.hasGPS = true
+		}
+	}
+
+	return info
+}
+
+// applyOrientation transforms an image so it displays upright for the given EXIF orientation
+func applyOrientation(src image.Image, orientation int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	// Orientations 5-8 swap width and height
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for dy := 0; dy < dstH; dy++ {
+		for dx := 0; dx < dstW; dx++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirror horizontal
+				sx, sy = w-1-dx, dy
+			case 3: // Rotate 180
+				sx, sy = w-1-dx, h-1-dy
+			case 4: // Mirror vertical
+				sx, sy = dx, h-1-dy
+			case 5: // Transpose
+				sx, sy = dy, dx
+			case 6: // Rotate 90 CW
+				sx, sy = dy, h-1-dx
+			case 7: // Transverse
+				sx, sy = w-1-dy, h-1-dx
+			case 8: // Rotate 270 CW
+				sx, sy = w-1-dy, dx
+			default:
+				sx, sy = dx, dy
+			}
+
+			s := rgba.Pix[sy*rgba.Stride+sx*4 : sy*rgba.Stride+sx*4+4]
+			copy(dst.Pix[dy*dst.Stride+dx*4:], s)
+		}
+	}
+
+	return dst
+}
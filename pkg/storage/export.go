@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// MetadataFilter selects operations for a metadata export. Zero fields match
+// everything.
+type MetadataFilter struct {
+	Operation      string
+	Model          string
+	PromptContains string // Case-insensitive substring of the prompt
+	From           time.Time
+	To             time.Time // Exclusive
+	MinRating      int       // Only operations rated at least this
+}
+
+// matches reports whether an operation's metadata passes the filter
+func (f MetadataFilter) matches(metadata *types.ImageMetadata) bool {
+	if f.Operation != "" && metadata.Operation != f.Operation {
+		return false
+	}
+	if f.Model != "" && metadata.Model != f.Model {
+		return false
+	}
+	if f.PromptContains != "" {
+		prompt, _ := metadata.Parameters["prompt"].(string)
+		if !strings.Contains(strings.ToLower(prompt), strings.ToLower(f.PromptContains)) {
+			return false
+		}
+	}
+	if !f.From.IsZero() && metadata.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !metadata.Timestamp.Before(f.To) {
+		return false
+	}
+	return metadata.Rating >= f.MinRating
+}
+
+// QueryMetadata returns the metadata of every stored operation matching the
+// filter, oldest first
+func (s *Storage) QueryMetadata(filter MetadataFilter) ([]*types.ImageMetadata, error) {
+	dirs, err := os.ReadDir(s.rootPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var records []*types.ImageMetadata
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		metadata, err := s.LoadMetadata(dir.Name())
+		if err != nil {
+			continue // Not an operation directory
+		}
+		if filter.matches(metadata) {
+			records = append(records, metadata)
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	return records, nil
+}
+
+// exportsDir holds metadata exports under the storage root. Its name is not
+// a storage ID, so repair_storage and listings leave it alone.
+const exportsDir = "exports"
+
+// DefaultExportPath returns a timestamped file path for a metadata export
+func (s *Storage) DefaultExportPath(format string, now time.Time) string {
+	return filepath.Join(s.rootPath, exportsDir, fmt.Sprintf("metadata-%s.%s", now.Format("20060102-150405"), format))
+}
+
+// metadataColumns are the columns of a metadata export, in order
+var metadataColumns = []string{
+	"id", "timestamp", "operation", "status", "model", "provider", "prompt",
+	"outputs", "width", "height", "file_size", "generation_time", "predict_time",
+	"cost", "cost_basis", "prediction_id", "error", "rating", "note", "parameters",
+}
+
+// MetadataRecord flattens an operation's metadata into the columns of an
+// export
+func MetadataRecord(metadata *types.ImageMetadata) map[string]interface{} {
+	prompt, _ := metadata.Parameters["prompt"].(string)
+	row := map[string]interface{}{
+		"id":         metadata.ID,
+		"timestamp":  metadata.Timestamp.Format(time.RFC3339),
+		"operation":  metadata.Operation,
+		"status":     "succeeded",
+		"model":      metadata.Model,
+		"prompt":     prompt,
+		"parameters": metadata.Parameters,
+	}
+	if metadata.Rating > 0 {
+		row["rating"] = metadata.Rating
+	}
+	if metadata.Note != "" {
+		row["note"] = metadata.Note
+	}
+	if metadata.Error != nil {
+		row["status"] = "failed"
+		row["error"] = *metadata.Error
+	}
+	if result := metadata.Result; result != nil {
+		outputs := len(result.Files)
+		if outputs == 0 {
+			outputs = 1
+		}
+		row["provider"] = result.Provider
+		row["outputs"] = outputs
+		row["width"] = result.Width
+		row["height"] = result.Height
+		row["file_size"] = result.FileSize
+		row["generation_time"] = result.GenerationTime
+		row["predict_time"] = result.PredictTime
+		row["cost"] = result.CostEstimate
+		row["cost_basis"] = result.CostBasis
+		row["prediction_id"] = result.PredictionID
+	}
+	return row
+}
+
+// WriteMetadataJSON writes operations as a JSON array of flat records
+func WriteMetadataJSON(w io.Writer, records []*types.ImageMetadata) error {
+	rows := make([]map[string]interface{}, len(records))
+	for i, metadata := range records {
+		rows[i] = MetadataRecord(metadata)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+// WriteMetadataCSV writes operations as CSV with a header row. Parameters
+// are written as a JSON object in the last column.
+func WriteMetadataCSV(w io.Writer, records []*types.ImageMetadata) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(metadataColumns); err != nil {
+		return err
+	}
+
+	for _, metadata := range records {
+		row := MetadataRecord(metadata)
+		fields := make([]string, len(metadataColumns))
+		for i, column := range metadataColumns {
+			fields[i] = csvValue(row[column])
+		}
+		if err := writer.Write(fields); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvValue formats a record value for a CSV cell
+func csvValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case map[string]interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(value)
+	}
+}
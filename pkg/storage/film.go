@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FilmOptions controls the analog effects of ApplyFilmLook. An effect with a
+// zero strength is skipped.
+type FilmOptions struct {
+	Grain       float64 // 0-1: strength of the grain
+	GrainSize   float64 // Size of the grain, 1 being fine 35mm grain; scaled with the image
+	Vignette    float64 // 0-1: darkening of the corners
+	Halation    float64 // 0-1: red glow bleeding around highlights
+	LUT         *LUT    // Color grade applied first
+	LUTStrength float64 // 0-1: mix of the graded colors over the original
+	Seed        int64   // Seed of the grain pattern
+}
+
+// filmReferenceEdge is the long edge, in pixels, at which a GrainSize of 1
+// makes grain one pixel across
+const filmReferenceEdge = 1500
+
+// ApplyFilmLook grades the image at path with a LUT and adds halation, a
+// vignette, and grain, in that order, returning the result as a PNG. Alpha
+// is kept.
+func ApplyFilmLook(path string, opts FilmOptions) ([]byte, error) {
+	src, err := decodeOriented(path)
+	if err != nil {
+		return nil, err
+	}
+	img := toNRGBA(src)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	longEdge := max(w, h)
+
+	var planes [3][]float64
+	for ch := range planes {
+		planes[ch] = make([]float64, w*h)
+	}
+	for i := 0; i < w*h; i++ {
+		for ch := range planes {
+			planes[ch][i] = float64(img.Pix[i*4+ch]) / 255
+		}
+	}
+
+	if opts.LUT != nil && opts.LUTStrength > 0 {
+		for i := range planes[0] {
+			r, g, b := opts.LUT.Apply(planes[0][i], planes[1][i], planes[2][i])
+			planes[0][i] += (r - planes[0][i]) * opts.LUTStrength
+			planes[1][i] += (g - planes[1][i]) * opts.LUTStrength
+			planes[2][i] += (b - planes[2][i]) * opts.LUTStrength
+		}
+	}
+
+	// Halation: highlights glow red-orange, as light scatters back through
+	// the film base into the red-sensitive layer
+	if opts.Halation > 0 {
+		highlights := make([]float64, w*h)
+		for i := range highlights {
+			l := luma(planes[0][i], planes[1][i], planes[2][i])
+			highlights[i] = max(0, (l-0.7)/0.3)
+		}
+		glow := blur2(highlights, w, h, max(1, longEdge/120))
+		tint := [3]float64{1, 0.35, 0.12}
+		for i, g := range glow {
+			for ch := range planes {
+				// Screen blend, which brightens without clipping
+				amount := min(1, g*opts.Halation*tint[ch]*1.5)
+				planes[ch][i] = 1 - (1-planes[ch][i])*(1-amount)
+			}
+		}
+	}
+
+	if opts.Vignette > 0 {
+		cx, cy := float64(w)/2, float64(h)/2
+		radius := math.Hypot(cx, cy)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / radius
+				factor := 1 - 0.8*opts.Vignette*smoothstep(0.35, 1, d)
+				for ch := range planes {
+					planes[ch][y*w+x] *= factor
+				}
+			}
+		}
+	}
+
+	// Grain: monochrome noise on a grid of grain-sized cells, interpolated
+	// so the particles are soft, strongest in the midtones like film's
+	if opts.Grain > 0 {
+		size := max(1, opts.GrainSize*float64(longEdge)/filmReferenceEdge)
+		gw, gh := int(float64(w)/size)+2, int(float64(h)/size)+2
+		rng := rand.New(rand.NewSource(opts.Seed))
+		noise := make([]float64, gw*gh)
+		for i := range noise {
+			noise[i] = rng.NormFloat64()
+		}
+		for y := 0; y < h; y++ {
+			fy := float64(y) / size
+			y0 := int(fy)
+			wy := fy - float64(y0)
+			for x := 0; x < w; x++ {
+				fx := float64(x) / size
+				x0 := int(fx)
+				wx := fx - float64(x0)
+				n := (noise[y0*gw+x0]*(1-wx)+noise[y0*gw+x0+1]*wx)*(1-wy) +
+					(noise[(y0+1)*gw+x0]*(1-wx)+noise[(y0+1)*gw+x0+1]*wx)*wy
+
+				i := y*w + x
+				l := luma(planes[0][i], planes[1][i], planes[2][i])
+				amount := n * opts.Grain * 0.15 * (0.3 + 2.8*l*(1-l))
+				for ch := range planes {
+					planes[ch][i] += amount
+				}
+			}
+		}
+	}
+
+	for i := 0; i < w*h; i++ {
+		for ch := range planes {
+			img.Pix[i*4+ch] = uint8(min(1, max(0, planes[ch][i]))*255 + 0.5)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// luma returns the Rec. 709 luma of a color with 0-1 channels
+func luma(r, g, b float64) float64 {
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
+
+// smoothstep eases from 0 at edge0 to 1 at edge1
+func smoothstep(edge0, edge1, x float64) float64 {
+	t := min(1, max(0, (x-edge0)/(edge1-edge0)))
+	return t * t * (3 - 2*t)
+}
+
+// maxLUTSize bounds the entries of a LUT's table, so a malformed file cannot
+// allocate without limit: 64 per axis in 3D
+const maxLUTSize = 64 * 64 * 64
+
+// LUT is a color lookup table read from a .cube file
+type LUT struct {
+	Title     string
+	Size      int          // Entries per axis
+	ThreeD    bool         // A 3D table; otherwise a 1D curve per channel
+	DomainMin [3]float64   // Input value mapped to the first entry
+	DomainMax [3]float64   // Input value mapped to the last entry
+	Table     [][3]float64 // Red varies fastest, then green, then blue
+}
+
+// LoadCubeLUT reads a 1D or 3D LUT in the Adobe/Resolve .cube format
+func LoadCubeLUT(path string) (*LUT, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LUT: %w", err)
+	}
+	defer file.Close()
+
+	lut := &LUT{DomainMax: [3]float64{1, 1, 1}}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch keyword := fields[0]; keyword {
+		case "TITLE":
+			title := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "TITLE")
+			lut.Title = strings.Trim(strings.TrimSpace(title), `"`)
+		case "LUT_1D_SIZE", "LUT_3D_SIZE":
+			if lut.Size != 0 || len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: invalid %s", line, keyword)
+			}
+			size, err := strconv.Atoi(fields[1])
+			lut.ThreeD = keyword == "LUT_3D_SIZE"
+			entries := size
+			if lut.ThreeD {
+				entries = size * size * size
+			}
+			if err != nil || size < 2 || entries > maxLUTSize {
+				return nil, fmt.Errorf("line %d: unsupported %s %s", line, keyword, fields[1])
+			}
+			lut.Size = size
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			values, err := parseTriple(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", line, keyword, err)
+			}
+			if keyword == "DOMAIN_MIN" {
+				lut.DomainMin = values
+			} else {
+				lut.DomainMax = values
+			}
+		case "LUT_1D_INPUT_RANGE", "LUT_3D_INPUT_RANGE":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: invalid %s", line, keyword)
+			}
+			lo, errLo := strconv.ParseFloat(fields[1], 64)
+			hi, errHi := strconv.ParseFloat(fields[2], 64)
+			if errLo != nil || errHi != nil {
+				return nil, fmt.Errorf("line %d: invalid %s", line, keyword)
+			}
+			lut.DomainMin, lut.DomainMax = [3]float64{lo, lo, lo}, [3]float64{hi, hi, hi}
+		default:
+			values, err := parseTriple(fields)
+			if err != nil {
+				// Unknown keywords are allowed by the format
+				if _, numErr := strconv.ParseFloat(keyword, 64); numErr != nil {
+					continue
+				}
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if lut.Size == 0 {
+				return nil, fmt.Errorf("line %d: table data before LUT_1D_SIZE or LUT_3D_SIZE", line)
+			}
+			if len(lut.Table) == maxLUTSize {
+				return nil, fmt.Errorf("line %d: LUT has more entries than its size calls for", line)
+			}
+			lut.Table = append(lut.Table, values)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read LUT: %w", err)
+	}
+
+	if lut.Size == 0 {
+		return nil, fmt.Errorf("not a .cube LUT: LUT_1D_SIZE or LUT_3D_SIZE is missing")
+	}
+	want := lut.Size
+	if lut.ThreeD {
+		want = lut.Size * lut.Size * lut.Size
+	}
+	if len(lut.Table) != want {
+		return nil, fmt.Errorf("LUT has %d entries; its size calls for %d", len(lut.Table), want)
+	}
+	for ch := 0; ch < 3; ch++ {
+		if lut.DomainMax[ch] <= lut.DomainMin[ch] {
+			return nil, fmt.Errorf("LUT domain is empty")
+		}
+	}
+	return lut, nil
+}
+
+// parseTriple parses three numbers
+func parseTriple(fields []string) ([3]float64, error) {
+	var values [3]float64
+	if len(fields) != 3 {
+		return values, fmt.Errorf("expected 3 values, got %d", len(fields))
+	}
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return values, fmt.Errorf("invalid number %q", field)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// Apply maps a color with 0-1 channels through the LUT, interpolating
+// between entries
+func (l *LUT) Apply(r, g, b float64) (float64, float64, float64) {
+	// Position of each channel along the table, 0 to Size-1
+	var pos [3]float64
+	for ch, v := range [3]float64{r, g, b} {
+		t := (v - l.DomainMin[ch]) / (l.DomainMax[ch] - l.DomainMin[ch])
+		pos[ch] = min(1, max(0, t)) * float64(l.Size-1)
+	}
+
+	if !l.ThreeD {
+		var out [3]float64
+		for ch := range out {
+			i := min(int(pos[ch]), l.Size-2)
+			f := pos[ch] - float64(i)
+			out[ch] = l.Table[i][ch]*(1-f) + l.Table[i+1][ch]*f
+		}
+		return out[0], out[1], out[2]
+	}
+
+	// Trilinear interpolation between the eight surrounding entries
+	var i0 [3]int
+	var f [3]float64
+	for ch := range pos {
+		i0[ch] = min(int(pos[ch]), l.Size-2)
+		f[ch] = pos[ch] - float64(i0[ch])
+	}
+	var out [3]float64
+	for corner := 0; corner < 8; corner++ {
+		weight := 1.0
+		var idx [3]int
+		for ch := 0; ch < 3; ch++ {
+			if corner&(1<<ch) != 0 {
+				idx[ch] = i0[ch] + 1
+				weight *= f[ch]
+			} else {
+				idx[ch] = i0[ch]
+				weight *= 1 - f[ch]
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+		entry := l.Table[idx[0]+idx[1]*l.Size+idx[2]*l.Size*l.Size]
+		for ch := range out {
+			out[ch] += entry[ch] * weight
+		}
+	}
+	return out[0], out[1], out[2]
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+// DefaultIconSizes are the icon sizes exported when none are named: browser
+// favicons, the Apple touch icon (180), the Android and PWA icons (192,
+// 512), and a 1024 store icon
+var DefaultIconSizes = []int{16, 32, 48, 64, 128, 180, 192, 256, 512, 1024}
+
+// ICOSizes are the sizes bundled into favicon.ico
+var ICOSizes = []int{16, 32, 48}
+
+// AppleTouchIconSize is flattened onto the background, since iOS fills
+// transparent pixels of touch icons with black
+const AppleTouchIconSize = 180
+
+// MaskableIconSize is the size of the maskable icon
+const MaskableIconSize = 512
+
+// maskableSafeZone is the diameter of the circle, as a share of the icon,
+// that platforms never mask away
+const maskableSafeZone = 0.8
+
+// IconSource is an image cropped square to render icons from
+type IconSource struct {
+	image       *image.NRGBA
+	Crop        image.Rectangle // Region of the source image that was used
+	Cropped     bool            // The source was not square
+	Transparent bool            // The image has transparent pixels
+}
+
+// LoadIconSource loads the image at path and crops it to a centered square
+func LoadIconSource(path string) (*IconSource, error) {
+	src, err := decodeOriented(path)
+	if err != nil {
+		return nil, err
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	crop := squareAround(image.Pt(w/2, h/2), min(w, h), w, h)
+	img := image.NewNRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(img, img.Bounds(), src, crop.Min.Add(src.Bounds().Min), draw.Src)
+
+	transparent := false
+	for i := 3; i < len(img.Pix) && !transparent; i += 4 {
+		transparent = img.Pix[i] < 255
+	}
+	return &IconSource{image: img, Crop: crop, Cropped: w != h, Transparent: transparent}, nil
+}
+
+// Size returns the side of the square source
+func (s *IconSource) Size() int {
+	return s.image.Bounds().Dx()
+}
+
+// Icon renders the source at size x size as PNG. A non-nil background fills
+// transparent pixels.
+func (s *IconSource) Icon(size int, background *color.NRGBA) ([]byte, error) {
+	icon := scaleImage(s.image, size, size)
+	if background != nil && s.Transparent {
+		flat := image.NewRGBA(icon.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(*background), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), icon, image.Point{}, draw.Over)
+		icon = flat
+	}
+	return encodeIconPNG(icon)
+}
+
+// MaskableIcon renders a maskable icon of size x size as PNG. An opaque
+// source fills the icon edge to edge, as its own background. A transparent
+// source is drawn over background, scaled so its visible pixels fit in the
+// central safe zone that every platform mask keeps.
+func (s *IconSource) MaskableIcon(size int, background color.NRGBA) ([]byte, error) {
+	if !s.Transparent {
+		return encodeIconPNG(scaleImage(s.image, size, size))
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	content := opaqueBounds(s.image)
+	if content.Empty() {
+		return encodeIconPNG(canvas)
+	}
+
+	// Scale the content so the corners of its bounds touch the safe circle
+	diagonal := math.Hypot(float64(content.Dx()), float64(content.Dy()))
+	scale := maskableSafeZone * float64(size) / diagonal
+	w := max(1, int(float64(content.Dx())*scale+0.5))
+	h := max(1, int(float64(content.Dy())*scale+0.5))
+	scaled := scaleImage(s.image.SubImage(content), w, h)
+	at := image.Pt((size-w)/2, (size-h)/2)
+	draw.Draw(canvas, image.Rectangle{Min: at, Max: at.Add(image.Pt(w, h))}, scaled, image.Point{}, draw.Over)
+	return encodeIconPNG(canvas)
+}
+
+// EncodeICO bundles PNG images into an ICO file. Every browser and Windows
+// since Vista reads PNG entries.
+func EncodeICO(pngs [][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	header := []uint16{0, 1, uint16(len(pngs))} // Reserved, type 1 (icon), count
+	if err := binary.Write(&buf, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	offset := 6 + 16*len(pngs)
+	for i, data := range pngs {
+		config, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("icon %d is not a PNG: %w", i+1, err)
+		}
+		if config.Width > 256 || config.Height > 256 {
+			return nil, fmt.Errorf("icon %d is %dx%d; ICO entries are at most 256x256", i+1, config.Width, config.Height)
+		}
+		// Sizes of 256 are written as 0
+		entry := struct {
+			Width, Height, Colors, Reserved uint8
+			Planes, BitCount                uint16
+			Size, Offset                    uint32
+		}{uint8(config.Width), uint8(config.Height), 0, 0, 1, 32, uint32(len(data)), uint32(offset)}
+		if err := binary.Write(&buf, binary.LittleEndian, entry); err != nil {
+			return nil, err
+		}
+		offset += len(data)
+	}
+	for _, data := range pngs {
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleImage resizes img to width x height, averaging when shrinking and
+// interpolating bilinearly when enlarging
+func scaleImage(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	if width <= b.Dx() && height <= b.Dy() {
+		return resizeImage(img, width, height)
+	}
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	srcW, srcH := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		fy := math.Max(0, (float64(y)+0.5)*float64(srcH)/float64(height)-0.5)
+		y0 := min(int(fy), srcH-1)
+		y1 := min(y0+1, srcH-1)
+		wy := fy - float64(y0)
+		for x := 0; x < width; x++ {
+			fx := math.Max(0, (float64(x)+0.5)*float64(srcW)/float64(width)-0.5)
+			x0 := min(int(fx), srcW-1)
+			x1 := min(x0+1, srcW-1)
+			wx := fx - float64(x0)
+
+			d := dst.Pix[y*dst.Stride+x*4:]
+			for c := 0; c < 4; c++ {
+				top := float64(src.Pix[y0*src.Stride+x0*4+c])*(1-wx) + float64(src.Pix[y0*src.Stride+x1*4+c])*wx
+				bottom := float64(src.Pix[y1*src.Stride+x0*4+c])*(1-wx) + float64(src.Pix[y1*src.Stride+x1*4+c])*wx
+				d[c] = uint8(top*(1-wy) + bottom*wy + 0.5)
+			}
+		}
+	}
+	return dst
+}
+
+// encodeIconPNG encodes an icon as PNG
+func encodeIconPNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode icon: %w", err)
+	}
+	return buf.Bytes(), nil
+}
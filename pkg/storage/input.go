@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register GIF decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// Default limits applied to input images and downloaded outputs
+const (
+	DefaultMaxInputBytes    = 5 * 1024 * 1024
+	DefaultMaxDownloadBytes = 200 * 1024 * 1024
+)
+
+// Options configures how storage prepares and persists images
+type Options struct {
+	MaxInputBytes        int64         // Maximum encoded input size sent to a model
+	MaxInputEdge         int           // Maximum width or height of an input image, overriding each model's limit (0 = the model's limit)
+	MaxDownloadBytes     int64         // Maximum size of a downloaded output or input image
+	MaxParallelDownloads int           // Maximum concurrent output downloads, and input images prepared at once per operation
+	HTTPClient           *http.Client  // Client used to download outputs (pooled client when nil)
+	Prompts              *PromptSealer // Hashes or encrypts stored prompts (stored as they are when nil)
+	StripInputMetadata   bool          // Remove EXIF, XMP, and other metadata from inputs before upload
+}
+
+// InputImage is a local image prepared for upload to a model
+type InputImage struct {
+	Path           string
+	Data           *types.FileData // Encoded as a data URL when the request is sent
+	OriginalWidth  int
+	OriginalHeight int
+	Width          int
+	Height         int
+	Resized        bool
+	Notes          []string // Human-readable notes about changes made to the input
+}
+
+// PrepareInput loads a local image and converts it to a data URL, downscaling it
+// when it exceeds the configured size limit or maxEdge, the longest edge the
+// model takes (0 for any). Inputs prepared earlier are reused while their
+// file is unchanged, so a sweep over one parameter prepares its images only
+// once.
+func (s *Storage) PrepareInput(filePath string, maxEdge int) (*InputImage, error) {
+	if s.options.MaxInputEdge > 0 {
+		maxEdge = s.options.MaxInputEdge
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if cached := s.inputs.get(filePath, maxEdge, info); cached != nil {
+		return cached, nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	prepared := &InputImage{Path: filePath}
+	mimeType := mimeTypeForPath(filePath)
+
+	// Apply EXIF rotation (lost in base64 conversion) and strip location data
+	data, exifNotes, err := normalizeExif(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize EXIF orientation: %w", err)
+	}
+	prepared.Notes = append(prepared.Notes, exifNotes...)
+	rewritten := len(exifNotes) > 0
+
+	// Remove the remaining metadata, such as camera details, captions, and
+	// embedded generation parameters, when configured. Formats that cannot be
+	// stripped are sent as they are.
+	if s.options.StripInputMetadata {
+		if stripped, err := StripMetadata(data); err == nil && len(stripped.Removed) > 0 {
+			data = stripped.Data
+			prepared.Notes = append(prepared.Notes, stripNotes(filepath.Base(filePath), stripped)...)
+			rewritten = true
+		}
+	}
+
+	// Read dimensions without decoding the full image
+	cfg, format, cfgErr := image.DecodeConfig(bytes.NewReader(data))
+	if cfgErr == nil {
+		prepared.OriginalWidth, prepared.OriginalHeight = cfg.Width, cfg.Height
+		prepared.Width, prepared.Height = cfg.Width, cfg.Height
+	}
+
+	tooLarge := int64(len(data)) > s.options.MaxInputBytes
+	tooWide := maxEdge > 0 && (cfg.Width > maxEdge || cfg.Height > maxEdge)
+
+	if tooLarge || tooWide {
+		if cfgErr != nil {
+			// Formats without a standard library decoder (e.g. WebP) cannot be resized
+			if tooLarge {
+				return nil, fmt.Errorf("image file too large (max %dMB) and its format cannot be resized automatically", s.options.MaxInputBytes/(1024*1024))
+			}
+		} else {
+			resized, resizedMime, err := s.downscale(data, format, cfg.Width, cfg.Height, maxEdge)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resize oversized image: %w", err)
+			}
+			data = resized.data
+			mimeType = resizedMime
+			prepared.Width, prepared.Height = resized.width, resized.height
+			prepared.Resized = true
+			prepared.Notes = append(prepared.Notes, fmt.Sprintf("input %s was downscaled from %dx%d to %dx%d to fit model limits",
+				filepath.Base(filePath), prepared.OriginalWidth, prepared.OriginalHeight, resized.width, resized.height))
+		}
+	}
+
+	// Unmodified files are streamed from disk when the request is sent, so
+	// only rewritten (rotated, stripped, or resized) images stay in memory
+	prepared.Data = &types.FileData{MimeType: mimeType, Path: filePath, Size: int64(len(data))}
+	if prepared.Resized || rewritten {
+		prepared.Data.Data = data
+	}
+	s.inputs.put(filePath, maxEdge, info, prepared)
+	return prepared, nil
+}
+
+// encodedImage holds a re-encoded image and its dimensions
+type encodedImage struct {
+	data   []byte
+	width  int
+	height int
+}
+
+// downscale shrinks an image until it satisfies both the edge and byte limits
+func (s *Storage) downscale(data []byte, format string, width, height, maxEdge int) (*encodedImage, string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Start from the edge limit, then keep shrinking if the encoded size is still too large
+	scale := 1.0
+	if maxEdge > 0 {
+		longest := width
+		if height > longest {
+			longest = height
+		}
+		if longest > maxEdge {
+			scale = float64(maxEdge) / float64(longest)
+		}
+	}
+
+	const maxAttempts = 8
+	for i := 0; i < maxAttempts; i++ {
+		w := int(float64(width) * scale)
+		h := int(float64(height) * scale)
+		if w < 1 {
+			w = 1
+		}
+		if h < 1 {
+			h = 1
+		}
+
+		out, mimeType, err := encodeImage(resizeImage(src, w, h), format)
+		if err != nil {
+			return nil, "", err
+		}
+		if int64(len(out)) <= s.options.MaxInputBytes {
+			return &encodedImage{data: out, width: w, height: h}, mimeType, nil
+		}
+		scale *= 0.75
+	}
+
+	return nil, "", fmt.Errorf("could not reduce image below %dMB", s.options.MaxInputBytes/(1024*1024))
+}
+
+// encodeBuffers are reused by encodeImage, so downscaling a set of references
+// does not grow a new buffer for every attempt at every image
+var encodeBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// encodeImage encodes an image, keeping PNG/GIF sources lossless so alpha is preserved
+func encodeImage(img image.Image, format string) ([]byte, string, error) {
+	buf := encodeBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer encodeBuffers.Put(buf)
+
+	mimeType := "image/jpeg"
+	var err error
+	switch format {
+	case "png", "gif":
+		mimeType = "image/png"
+		err = png.Encode(buf, img)
+	default:
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	// The buffer goes back to the pool, so the caller gets its own copy
+	return bytes.Clone(buf.Bytes()), mimeType, nil
+}
+
+// resizeImage downscales an image to the given size using area averaging
+func resizeImage(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	srcW, srcH := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcH / height
+		y1 := (y + 1) * srcH / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := x * srcW / width
+			x1 := (x + 1) * srcW / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					b += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0] = uint8(r / n)
+			d[1] = uint8(g / n)
+			d[2] = uint8(b / n)
+			d[3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// mimeTypeForPath returns the image MIME type implied by a file extension
+func mimeTypeForPath(filePath string) string {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".webp":
+		return "image/webp"
+	case ".gif":
+		return "image/gif"
+	case ".bmp":
+		return "image/bmp"
+	default:
+		return "image/png"
+	}
+}
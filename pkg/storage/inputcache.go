@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Bounds of the prepared input cache. Sweeps send the same images with one
+// parameter changed, so a few recent inputs cover them.
+const (
+	inputCacheEntries = 32
+	inputCacheBytes   = 128 * 1024 * 1024 // Rewritten image bytes held across entries
+)
+
+// inputCache keeps recently prepared inputs, so repeated calls with the same
+// image skip reading, rotating, stripping, and resizing it again. Entries
+// are keyed by absolute path and edge limit, and only used while the file's
+// size and modification time are unchanged.
+type inputCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+	bytes   int64
+}
+
+// inputCacheEntry is a prepared input and the file state it was prepared from
+type inputCacheEntry struct {
+	path     string
+	size     int64
+	modTime  time.Time
+	prepared *InputImage
+}
+
+func newInputCache() *inputCache {
+	return &inputCache{entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// get returns a copy of the input prepared from path for maxEdge, or nil
+// when there is none or the file has changed since
+func (c *inputCache) get(path string, maxEdge int, info os.FileInfo) *InputImage {
+	key := inputCacheKey(path, maxEdge)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*inputCacheEntry)
+	if entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		c.remove(elem)
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.prepared.clone()
+}
+
+// put records the input prepared from path, evicting the least recently used
+// entries beyond the cache bounds
+func (c *inputCache) put(path string, maxEdge int, info os.FileInfo, prepared *InputImage) {
+	held := int64(len(prepared.Data.Data))
+	if held > inputCacheBytes {
+		return
+	}
+	key := inputCacheKey(path, maxEdge)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	entry := &inputCacheEntry{path: key, size: info.Size(), modTime: info.ModTime(), prepared: prepared.clone()}
+	c.entries[key] = c.order.PushFront(entry)
+	c.bytes += held
+	for c.order.Len() > inputCacheEntries || c.bytes > inputCacheBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops an entry from the cache
+func (c *inputCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*inputCacheEntry)
+	delete(c.entries, entry.path)
+	c.bytes -= int64(len(entry.prepared.Data.Data))
+}
+
+// inputCacheKey returns the absolute path, so relative and absolute
+// references to one file share an entry, with the edge limit the input was
+// prepared for
+func inputCacheKey(path string, maxEdge int) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return fmt.Sprintf("%s@%d", path, maxEdge)
+}
+
+// clone copies a prepared input, so callers appending to its notes never
+// change the cached one. The image bytes are shared; they are never modified.
+func (p *InputImage) clone() *InputImage {
+	out := *p
+	out.Notes = slices.Clip(slices.Clone(p.Notes))
+	if p.Data != nil {
+		data := *p.Data
+		out.Data = &data
+	}
+	return &out
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/pdf"
+)
+
+// inputsDir holds input images passed inline and pages extracted from PDFs
+// under the storage root, and the images an operation downloaded from URLs
+// within its own directory. Like referencesDir, its name is not a storage ID.
+const inputsDir = "inputs"
+
+// reservedKey is the context key of an operation ID reserved by ReserveID
+type reservedKey struct{}
+
+// reservedID is an operation ID generated before its operation starts
+type reservedID struct {
+	id    string
+	taken atomic.Bool
+}
+
+// ReserveID generates the ID of the operation a tool call is about to run,
+// so the call's inputs can be downloaded into its directory first. The first
+// operation started with the returned context takes the ID; call ReleaseID
+// once the call is done.
+func (s *Storage) ReserveID(ctx context.Context) (context.Context, string, error) {
+	id, err := s.GenerateID()
+	if err != nil {
+		return ctx, "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return context.WithValue(ctx, reservedKey{}, &reservedID{id: id}), id, nil
+}
+
+// OperationID returns the ID reserved in ctx the first time it is asked for,
+// and a newly generated ID otherwise
+func (s *Storage) OperationID(ctx context.Context) (string, error) {
+	if r, ok := ctx.Value(reservedKey{}).(*reservedID); ok && r.taken.CompareAndSwap(false, true) {
+		return r.id, nil
+	}
+	return s.GenerateID()
+}
+
+// ReleaseID removes the directory of the ID reserved in ctx, with the inputs
+// downloaded into it, unless an operation saved its results there
+func (s *Storage) ReleaseID(ctx context.Context) {
+	r, ok := ctx.Value(reservedKey{}).(*reservedID)
+	if !ok {
+		return
+	}
+	dir := filepath.Join(s.rootPath, r.id)
+	if !s.hasArtifacts(dir) {
+		os.RemoveAll(dir)
+	}
+}
+
+// IsRemote reports whether an image argument is an http(s) URL rather than a
+// local path
+func IsRemote(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// DownloadInput downloads an input image from an http(s) URL into the inputs
+// folder of operation id and returns its local path, so each operation keeps
+// the inputs it used. Only public addresses are fetched. The download is
+// limited to the maximum download size, and content that is not an image or
+// a PDF is rejected.
+func (s *Storage) DownloadInput(id, rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid image URL: %s", rawURL)
+	}
+
+	resp, release, err := s.fetch(s.inputHTTP, rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	defer resp.Body.Close()
+
+	if resp.ContentLength > s.options.MaxDownloadBytes {
+		return "", fmt.Errorf("image exceeds maximum download size (%d bytes > %d bytes)", resp.ContentLength, s.options.MaxDownloadBytes)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(resp.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("failed to read image data: %w", err)
+	}
+	head = head[:n]
+
+	// Servers often send error pages with a 200 status, so trust the content
+	// over the Content-Type header
+	if !isInput(head) {
+		return "", fmt.Errorf("URL does not point to an image or PDF (content type %q)", resp.Header.Get("Content-Type"))
+	}
+
+	sum := sha256.Sum256([]byte(rawURL))
+	name := hex.EncodeToString(sum[:8]) + inputExt(head, resp.Header.Get("Content-Type"), parsed.Path)
+	return s.saveInput(filepath.Join(s.rootPath, id, inputsDir), name, io.MultiReader(bytes.NewReader(head), resp.Body))
+}
+
+// SaveInlineInput saves an input image passed inline into the inputs
+// directory and returns its local path. Images are named by a hash of their
+// content, so the same image is stored once.
+func (s *Storage) SaveInlineInput(data []byte) (string, error) {
+	if int64(len(data)) > s.options.MaxDownloadBytes {
+		return "", fmt.Errorf("image exceeds maximum size (%d bytes > %d bytes)", len(data), s.options.MaxDownloadBytes)
+	}
+	if !isInput(data) {
+		return "", fmt.Errorf("data is not an image or PDF")
+	}
+	sum := sha256.Sum256(data)
+	return s.saveInput(filepath.Join(s.rootPath, inputsDir), hex.EncodeToString(sum[:8])+inputExt(data, "", ""), bytes.NewReader(data))
+}
+
+// isInput reports whether data starts like an image, or a PDF to take a
+// page of
+func isInput(head []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(head), "image/") || looksLikeSVG(head) || pdf.IsPDF(head)
+}
+
+// inputExt returns the file extension for an input
+func inputExt(head []byte, contentType, urlPath string) string {
+	if pdf.IsPDF(head) {
+		return ".pdf"
+	}
+	return detectImageFormat(head, contentType, urlPath)
+}
+
+// saveInput streams an input image into dir under name, limited to the
+// maximum download size
+func (s *Storage) saveInput(dir, name string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create inputs directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+"*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	written, err := io.Copy(tmp, io.LimitReader(r, s.options.MaxDownloadBytes+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	if written > s.options.MaxDownloadBytes {
+		return "", fmt.Errorf("image exceeds maximum download size (%d bytes)", s.options.MaxDownloadBytes)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+// ErrIncompleteImage is returned when a downloaded image is empty, shorter
+// than its Content-Length, or cut off before the end its format requires
+var ErrIncompleteImage = errors.New("incomplete image")
+
+// trailerWindow is how many bytes at the end of a file are read to find its
+// format's end marker
+const trailerWindow = 64
+
+// verifyImage checks that the file at path holds a whole image: it is not
+// empty, its header decodes, and it ends the way its format requires (a PNG
+// IEND chunk, a JPEG EOI marker, a GIF trailer, or the length a WebP or BMP
+// header declares). Formats it does not know are only checked for being
+// non-empty; SVG documents are parsed when they are cleaned.
+func verifyImage(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	if size == 0 {
+		return fmt.Errorf("%w: empty file", ErrIncompleteImage)
+	}
+
+	head := make([]byte, 32)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+
+	tail := make([]byte, min(size, trailerWindow))
+	if _, err := f.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		// The IEND chunk: zero length, type, and its fixed CRC
+		if !bytes.HasSuffix(tail, []byte("\x00\x00\x00\x00IEND\xae\x42\x60\x82")) {
+			return fmt.Errorf("%w: PNG has no IEND chunk", ErrIncompleteImage)
+		}
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		// Some encoders pad after the EOI marker
+		if !bytes.HasSuffix(bytes.TrimRight(tail, "\x00\r\n"), []byte{0xFF, 0xD9}) {
+			return fmt.Errorf("%w: JPEG has no end-of-image marker", ErrIncompleteImage)
+		}
+	case bytes.HasPrefix(head, []byte("GIF8")):
+		if tail[len(tail)-1] != 0x3B {
+			return fmt.Errorf("%w: GIF has no trailer", ErrIncompleteImage)
+		}
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WEBP":
+		// The RIFF size covers everything after the first 8 bytes
+		if declared := int64(binary.LittleEndian.Uint32(head[4:8])) + 8; size < declared {
+			return fmt.Errorf("%w: WebP is %d of %d bytes", ErrIncompleteImage, size, declared)
+		}
+		return nil
+	case len(head) >= 6 && bytes.HasPrefix(head, []byte("BM")):
+		if declared := int64(binary.LittleEndian.Uint32(head[2:6])); size < declared {
+			return fmt.Errorf("%w: BMP is %d of %d bytes", ErrIncompleteImage, size, declared)
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	// The end marker is there; make sure the header is too
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, _, err := image.DecodeConfig(f); err != nil {
+		return fmt.Errorf("%w: %v", ErrIncompleteImage, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// interruptedFile logs the tool calls a shutdown cut short, in the storage root
+const interruptedFile = "interrupted.jsonl"
+
+// interruptedMu serializes interrupted operation log updates within the process
+var interruptedMu sync.Mutex
+
+// InterruptedOperation records a tool call that a shutdown cancelled or never
+// started, with what is needed to recover or rerun it
+type InterruptedOperation struct {
+	Tool          string                 `json:"tool"`
+	Arguments     map[string]interface{} `json:"arguments"`
+	StartedAt     *time.Time             `json:"started_at,omitempty"` // Nil for chain nodes never started
+	InterruptedAt time.Time              `json:"interrupted_at"`
+	PredictionIDs []string               `json:"prediction_ids,omitempty"` // Predictions the call created, whose outputs may still be fetched
+	Reason        string                 `json:"reason"`
+}
+
+// RecordInterrupted appends an operation to the interrupted operations log.
+// Inline images in its arguments are replaced by a size summary.
+func (s *Storage) RecordInterrupted(op InterruptedOperation) error {
+	if op.InterruptedAt.IsZero() {
+		op.InterruptedAt = time.Now()
+	}
+	op.Arguments = s.options.Prompts.sealMap(SummarizeInput(op.Arguments))
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("failed to marshal interrupted operation: %w", err)
+	}
+
+	interruptedMu.Lock()
+	defer interruptedMu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(s.rootPath, interruptedFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open interrupted operations log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write interrupted operations log: %w", err)
+	}
+	return nil
+}
+
+// InterruptedOperations returns every logged interrupted operation, oldest
+// first, skipping malformed lines
+func (s *Storage) InterruptedOperations() ([]InterruptedOperation, error) {
+	interruptedMu.Lock()
+	defer interruptedMu.Unlock()
+
+	f, err := os.Open(filepath.Join(s.rootPath, interruptedFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []InterruptedOperation{}, nil
+		}
+		return nil, fmt.Errorf("failed to open interrupted operations log: %w", err)
+	}
+	defer f.Close()
+
+	ops := []InterruptedOperation{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // Arguments can be long
+	for scanner.Scan() {
+		var op InterruptedOperation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			continue
+		}
+		op.Arguments = s.options.Prompts.openMap(op.Arguments)
+		ops = append(ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read interrupted operations log: %w", err)
+	}
+	return ops, nil
+}
+
+// ClearInterrupted empties the interrupted operations log
+func (s *Storage) ClearInterrupted() error {
+	interruptedMu.Lock()
+	defer interruptedMu.Unlock()
+
+	if err := os.Remove(filepath.Join(s.rootPath, interruptedFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear interrupted operations log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// ledgerFile is the append-only spend ledger in the storage root
+const ledgerFile = "ledger.jsonl"
+
+// ledgerMu serializes ledger appends within the process
+var ledgerMu sync.Mutex
+
+// LedgerEntry records the spend of one completed operation
+type LedgerEntry struct {
+	Timestamp      time.Time `json:"timestamp"`
+	StorageID      string    `json:"storage_id"`
+	Operation      string    `json:"operation"`
+	Model          string    `json:"model"`
+	Provider       string    `json:"provider,omitempty"`
+	PredictionID   string    `json:"prediction_id,omitempty"`
+	PredictTime    float64   `json:"predict_time"`
+	GenerationTime float64   `json:"generation_time"`
+	Cost           float64   `json:"cost"`
+	CostBasis      string    `json:"cost_basis,omitempty"`
+	Outputs        int       `json:"outputs"`
+	Bytes          int64     `json:"bytes"`
+}
+
+// RecordSpend appends an operation's cost to the spend ledger
+func (s *Storage) RecordSpend(metadata *types.ImageMetadata) error {
+	if metadata == nil || metadata.Result == nil {
+		return nil
+	}
+	result := metadata.Result
+
+	outputs := len(result.Files)
+	if outputs == 0 {
+		outputs = 1
+	}
+
+	entry := LedgerEntry{
+		Timestamp:      metadata.Timestamp,
+		StorageID:      metadata.ID,
+		Operation:      metadata.Operation,
+		Model:          metadata.Model,
+		Provider:       result.Provider,
+		PredictionID:   result.PredictionID,
+		PredictTime:    result.PredictTime,
+		GenerationTime: result.GenerationTime,
+		Cost:           result.CostEstimate,
+		CostBasis:      result.CostBasis,
+		Outputs:        outputs,
+		Bytes:          result.FileSize,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger entry: %w", err)
+	}
+
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(s.rootPath, ledgerFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	return nil
+}
+
+// ReadLedger returns every entry in the spend ledger, skipping malformed lines
+func (s *Storage) ReadLedger() ([]LedgerEntry, error) {
+	f, err := os.Open(filepath.Join(s.rootPath, ledgerFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []LedgerEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	entries := []LedgerEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// coldStartOverhead is the wait beyond predict time above which an operation
+// is taken to have waited for its model to boot
+const coldStartOverhead = 20.0
+
+// Latency summarizes how long recent operations with one model took
+type Latency struct {
+	Samples       int       `json:"samples"`
+	MedianSeconds float64   `json:"median_seconds"` // Wall time of the whole operation
+	P90Seconds    float64   `json:"p90_seconds"`
+	MedianPredict float64   `json:"median_predict_seconds"` // Billed model run time
+	ColdStarts    int       `json:"cold_starts"`            // Operations that waited for the model to boot
+	LastUsed      time.Time `json:"last_used"`
+}
+
+// ModelLatency summarizes the most recent entries for a model in the ledger,
+// at most limit of them from since onward. Warm-ups count as uses but not as
+// samples. It returns nil when there are no samples.
+func ModelLatency(entries []LedgerEntry, modelID string, since time.Time, limit int) *Latency {
+	latency := &Latency{}
+	var recent []LedgerEntry
+	for i := len(entries) - 1; i >= 0 && len(recent) < limit; i-- {
+		entry := entries[i]
+		if entry.Model != modelID || entry.Timestamp.Before(since) {
+			continue
+		}
+		if entry.Timestamp.After(latency.LastUsed) {
+			latency.LastUsed = entry.Timestamp
+		}
+		if entry.Operation != "warm_model" && entry.GenerationTime > 0 {
+			recent = append(recent, entry)
+		}
+	}
+	if len(recent) == 0 {
+		return nil
+	}
+
+	latency.Samples = len(recent)
+	totals := make([]float64, len(recent))
+	predicts := make([]float64, len(recent))
+	for i, entry := range recent {
+		totals[i] = entry.GenerationTime
+		predicts[i] = entry.PredictTime
+		if entry.PredictTime > 0 && entry.GenerationTime-entry.PredictTime > coldStartOverhead {
+			latency.ColdStarts++
+		}
+	}
+	latency.MedianSeconds = percentile(totals, 0.5)
+	latency.P90Seconds = percentile(totals, 0.9)
+	latency.MedianPredict = percentile(predicts, 0.5)
+	return latency
+}
+
+// EstimateRemaining estimates how many seconds an operation with the model
+// that has run for elapsed has left: until the median duration while it is
+// faster than that, then until the 90th percentile. It returns 0 when the
+// operation has outlasted both.
+func (l *Latency) EstimateRemaining(elapsed time.Duration) int {
+	seconds := elapsed.Seconds()
+	for _, typical := range []float64{l.MedianSeconds, l.P90Seconds} {
+		if typical > seconds {
+			return int(math.Ceil(typical - seconds))
+		}
+	}
+	return 0
+}
+
+// percentile returns the p-th percentile of values by the nearest-rank method
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
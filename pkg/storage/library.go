@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// GetImage returns the metadata of a stored operation and the paths of its
+// outputs, which are empty for a failed operation
+func (s *Storage) GetImage(id string) (*types.ImageMetadata, []string, error) {
+	metadata, err := s.LoadMetadata(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	paths := []string{}
+	if metadata.Result != nil {
+		files := metadata.Result.Files
+		if len(files) == 0 && metadata.Result.Filename != "" {
+			files = []string{metadata.Result.Filename}
+		}
+		for _, name := range files {
+			paths = append(paths, s.GetImagePath(id, name))
+		}
+	}
+	return metadata, paths, nil
+}
+
+// DeleteImage removes a stored operation with every file in its directory
+// and returns its metadata. Only directories holding operation metadata are
+// removed, never the storage root's other folders.
+func (s *Storage) DeleteImage(id string) (*types.ImageMetadata, error) {
+	metadata, err := s.LoadMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(filepath.Join(s.rootPath, id)); err != nil {
+		return nil, fmt.Errorf("failed to delete %s: %w", id, err)
+	}
+	return metadata, nil
+}
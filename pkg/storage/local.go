@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// LocalModel is recorded as the model of outputs composed on this machine
+// rather than by a prediction
+const LocalModel = "local-composite"
+
+// SaveLocalResult stores an image produced locally, without a prediction, as
+// a new operation, under the ID reserved in ctx if there is one. It returns
+// the operation's ID and the saved file's path. SVG documents are cleaned as
+// downloaded SVG outputs are.
+func (s *Storage) SaveLocalResult(ctx context.Context, operation string, parameters map[string]interface{}, filename string, data []byte) (string, string, error) {
+	id, err := s.OperationID(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	defer s.CleanupIfEmpty(id)
+
+	var svgStats *types.SVGStats
+	if strings.EqualFold(filepath.Ext(filename), ".svg") {
+		if data, svgStats, err = CleanSVG(data); err != nil {
+			return "", "", err
+		}
+	}
+
+	filesMu.Lock()
+	path := uniquePath(s.OperationDir(id), filename)
+	err = os.WriteFile(path, data, 0644)
+	filesMu.Unlock()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to save image: %w", err)
+	}
+
+	result := &types.OperationResult{Filename: filepath.Base(path), SVG: svgStats}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		result.Width, result.Height = cfg.Width, cfg.Height
+	}
+	if err := refreshFileInfo(result, path); err != nil {
+		return "", "", err
+	}
+
+	metadata := &types.ImageMetadata{
+		ID:         id,
+		Operation:  operation,
+		Timestamp:  time.Now(),
+		Model:      LocalModel,
+		Parameters: parameters,
+		Result:     result,
+	}
+	if err := s.SaveMetadata(id, metadata); err != nil {
+		os.Remove(path)
+		return "", "", err
+	}
+	return id, path, nil
+}
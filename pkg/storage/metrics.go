@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+// Tuning of CompareImages
+const (
+	ssimWindowRadius = 2       // Box radius of the two-pass window, close to the usual Gaussian of sigma 1.5-2
+	ssimC1           = 6.5025  // (0.01 * 255)^2
+	ssimC2           = 58.5225 // (0.03 * 255)^2
+	changedThreshold = 8       // Channel difference, 0-255, above which a pixel counts as changed
+	heatmapFullScale = 64      // Channel difference shown at the hot end of the heatmap
+	maxAspectDrift   = 0.02    // Largest relative aspect ratio difference of compared images
+)
+
+// ImageComparison holds similarity metrics between two images
+type ImageComparison struct {
+	Width, Height     int        // Size the images were compared at
+	Resized           bool       // One image was scaled down to the other's size
+	SSIM              float64    // Structural similarity of the luma, 1 when identical
+	PSNR              float64    // Peak signal-to-noise ratio in dB; +Inf when identical
+	MeanAbsoluteError float64    // Mean channel difference, 0-255
+	ChangedShare      float64    // Share of pixels that changed visibly
+	Sharpness         [2]float64 // Variance of the Laplacian of each image's luma
+	Heatmap           []byte     // PNG of the differences, when requested
+}
+
+// CompareImages measures how the image at pathB differs from the one at
+// pathA. Images of different sizes are compared at the smaller size, and
+// must share an aspect ratio. Transparent pixels are compared as flattened
+// onto white. With heatmap set, the differences are drawn as a PNG over a
+// dimmed grayscale copy of the first image.
+func CompareImages(pathA, pathB string, heatmap bool) (*ImageComparison, error) {
+	a, err := decodeOriented(pathA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load first image: %w", err)
+	}
+	b, err := decodeOriented(pathB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load second image: %w", err)
+	}
+	aw, ah := a.Bounds().Dx(), a.Bounds().Dy()
+	bw, bh := b.Bounds().Dx(), b.Bounds().Dy()
+	aspectA, aspectB := float64(aw)/float64(ah), float64(bw)/float64(bh)
+	if math.Abs(aspectA-aspectB)/aspectA > maxAspectDrift {
+		return nil, fmt.Errorf("the images have different aspect ratios (%dx%d and %dx%d)", aw, ah, bw, bh)
+	}
+
+	w, h := min(aw, bw), min(ah, bh)
+	result := &ImageComparison{Width: w, Height: h, Resized: aw != bw || ah != bh}
+	imgA, imgB := flattenAt(a, w, h), flattenAt(b, w, h)
+
+	n := w * h
+	lumaA, lumaB := make([]float64, n), make([]float64, n)
+	diffs := make([]float64, n)
+	var squared, absolute float64
+	changed := 0
+	for i := 0; i < n; i++ {
+		pa, pb := imgA.Pix[i*4:i*4+3], imgB.Pix[i*4:i*4+3]
+		largest := 0.0
+		for ch := 0; ch < 3; ch++ {
+			d := float64(pa[ch]) - float64(pb[ch])
+			squared += d * d
+			absolute += math.Abs(d)
+			largest = max(largest, math.Abs(d))
+		}
+		diffs[i] = largest
+		if largest > changedThreshold {
+			changed++
+		}
+		lumaA[i] = 255 * luma(float64(pa[0])/255, float64(pa[1])/255, float64(pa[2])/255)
+		lumaB[i] = 255 * luma(float64(pb[0])/255, float64(pb[1])/255, float64(pb[2])/255)
+	}
+	mse := squared / float64(3*n)
+	result.PSNR = math.Inf(1)
+	if mse > 0 {
+		result.PSNR = 10 * math.Log10(255*255/mse)
+	}
+	result.MeanAbsoluteError = absolute / float64(3*n)
+	result.ChangedShare = float64(changed) / float64(n)
+	result.SSIM = meanSSIM(lumaA, lumaB, w, h)
+	result.Sharpness = [2]float64{laplacianVariance(lumaA, w, h), laplacianVariance(lumaB, w, h)}
+
+	if heatmap {
+		if result.Heatmap, err = differenceHeatmap(lumaA, diffs, w, h); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// flattenAt draws img onto white and scales it down to w x h
+func flattenAt(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+	return resizeTo(flat, w, h)
+}
+
+// meanSSIM returns the mean structural similarity of two luma planes, with
+// local statistics taken over a blurred window around each pixel
+func meanSSIM(x, y []float64, w, h int) float64 {
+	n := len(x)
+	xx, yy, xy := make([]float64, n), make([]float64, n), make([]float64, n)
+	for i := range x {
+		xx[i] = x[i] * x[i]
+		yy[i] = y[i] * y[i]
+		xy[i] = x[i] * y[i]
+	}
+	muX, muY := blur2(x, w, h, ssimWindowRadius), blur2(y, w, h, ssimWindowRadius)
+	muXX, muYY, muXY := blur2(xx, w, h, ssimWindowRadius), blur2(yy, w, h, ssimWindowRadius), blur2(xy, w, h, ssimWindowRadius)
+
+	var sum float64
+	for i := range x {
+		varX := muXX[i] - muX[i]*muX[i]
+		varY := muYY[i] - muY[i]*muY[i]
+		cov := muXY[i] - muX[i]*muY[i]
+		sum += ((2*muX[i]*muY[i] + ssimC1) * (2*cov + ssimC2)) /
+			((muX[i]*muX[i] + muY[i]*muY[i] + ssimC1) * (varX + varY + ssimC2))
+	}
+	return sum / float64(n)
+}
+
+// laplacianVariance returns the variance of the Laplacian of a luma plane, a
+// common measure of sharpness: blur lowers it, added detail raises it
+func laplacianVariance(l []float64, w, h int) float64 {
+	if w < 3 || h < 3 {
+		return 0
+	}
+	var sum, squared float64
+	count := 0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			v := l[i-1] + l[i+1] + l[i-w] + l[i+w] - 4*l[i]
+			sum += v
+			squared += v * v
+			count++
+		}
+	}
+	mean := sum / float64(count)
+	return squared/float64(count) - mean*mean
+}
+
+// differenceHeatmap draws each pixel's difference on a black, blue, red,
+// yellow ramp over a dimmed grayscale base
+func differenceHeatmap(base, diffs []float64, w, h int) ([]byte, error) {
+	ramp := []color.RGBA{{0, 0, 0, 255}, {30, 60, 255, 255}, {255, 40, 30, 255}, {255, 240, 40, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, d := range diffs {
+		t := min(1, d/heatmapFullScale) * float64(len(ramp)-1)
+		lo := min(int(t), len(ramp)-2)
+		f := t - float64(lo)
+		// Unchanged pixels show the image faintly; changes cover it
+		weight := min(1, d/changedThreshold)
+		gray := base[i] * 0.35
+		from, to := ramp[lo], ramp[lo+1]
+		p := img.Pix[i*4 : i*4+4]
+		for ch, ends := range [3][2]uint8{{from.R, to.R}, {from.G, to.G}, {from.B, to.B}} {
+			heat := float64(ends[0])*(1-f) + float64(ends[1])*f
+			p[ch] = uint8(gray*(1-weight) + heat*weight + 0.5)
+		}
+		p[3] = 255
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode heatmap: %w", err)
+	}
+	return buf.Bytes(), nil
+}
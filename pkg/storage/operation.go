@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+)
+
+// Operation is one model operation's place in storage: its ID and
+// directory, its debug bundle, and the model its inputs are prepared for
+type Operation struct {
+	ID     string
+	Bundle *DebugBundle // Nil unless debug mode is on
+	model  string
+	store  *Storage
+}
+
+// StartOperation creates the directory of a new operation, under the ID
+// reserved in ctx or a generated one. Defer Finish with the operation's error.
+func (s *Storage) StartOperation(ctx context.Context, debug bool, operation, model string) (*Operation, error) {
+	id, err := s.OperationID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return s.OpenOperation(id, debug, operation, model), nil
+}
+
+// OpenOperation is StartOperation for a directory already created under id
+func (s *Storage) OpenOperation(id string, debug bool, operation, model string) *Operation {
+	return &Operation{
+		ID:     id,
+		Bundle: NewDebugBundle(debug, id, operation, model),
+		model:  model,
+		store:  s,
+	}
+}
+
+// Finish writes the operation's debug bundle, if any, and removes its
+// directory again when the operation failed before saving anything
+func (op *Operation) Finish(opErr error) {
+	op.store.SaveDebugBundle(op.Bundle, opErr)
+	op.store.CleanupIfEmpty(op.ID)
+}
+
+// PrepareInput prepares an input image for the operation's model,
+// downscaling it if it exceeds the model's limits
+func (op *Operation) PrepareInput(filePath string) (*InputImage, error) {
+	return op.store.PrepareInput(filePath, models.InputEdge(op.model))
+}
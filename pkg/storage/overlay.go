@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Overlay positions
+const (
+	OverlayTopLeft     = "top-left"
+	OverlayTopRight    = "top-right"
+	OverlayBottomLeft  = "bottom-left"
+	OverlayBottomRight = "bottom-right"
+	OverlayCenter      = "center"
+)
+
+// ValidOverlayPosition reports whether position names an overlay position
+func ValidOverlayPosition(position string) bool {
+	switch position {
+	case OverlayTopLeft, OverlayTopRight, OverlayBottomLeft, OverlayBottomRight, OverlayCenter:
+		return true
+	}
+	return false
+}
+
+// OverlayImage composites overlay onto the image at src and writes the result
+// to dst, encoded in the format its extension names. The overlay is scaled to
+// scale times the image width and inset by margin times the image width.
+func OverlayImage(src, dst string, overlay image.Image, position string, scale, margin float64) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+	base, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := base.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), base, bounds.Min, draw.Src)
+
+	ob := overlay.Bounds()
+	width := max(1, int(float64(bounds.Dx())*scale))
+	height := max(1, width*ob.Dy()/ob.Dx())
+	inset := int(float64(bounds.Dx()) * margin)
+
+	var x, y int
+	switch position {
+	case OverlayTopLeft:
+		x, y = inset, inset
+	case OverlayTopRight:
+		x, y = bounds.Dx()-width-inset, inset
+	case OverlayBottomLeft:
+		x, y = inset, bounds.Dy()-height-inset
+	case OverlayCenter:
+		x, y = (bounds.Dx()-width)/2, (bounds.Dy()-height)/2
+	default:
+		x, y = bounds.Dx()-width-inset, bounds.Dy()-height-inset
+	}
+	draw.Draw(canvas, image.Rect(x, y, x+width, y+height), resizeImage(overlay, width, height), image.Point{}, draw.Over)
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(dst)), ".")
+	switch format {
+	case "png", "jpg", "jpeg":
+	default:
+		return fmt.Errorf("cannot write %s images", format)
+	}
+	data, _, err := encodeImage(canvas, format)
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+// Panorama limits
+const (
+	MaxPanoramaTiles  = 12
+	MaxPanoramaPixels = 100_000_000
+	MaxPanoramaLap    = 0.5 // Largest overlap, as a fraction of the narrower tile
+)
+
+// Overlap detection settings
+const (
+	panoramaMinLap       = 0.05 // Smallest overlap searched for
+	panoramaSearchHeight = 128  // Height tiles are searched at before refining
+	panoramaPoorMatch    = 24.0 // RMS difference above which a seam is suspect
+)
+
+// PanoramaOptions controls how tiles are stitched
+type PanoramaOptions struct {
+	Vertical bool    // Stack the tiles top to bottom instead of left to right
+	Overlap  float64 // Fraction of the narrower tile shared with the next, unless Detect is set
+	Detect   bool    // Detect each overlap instead
+	Width    int     // Size to crop and scale the result to; 0 keeps the stitched size
+	Height   int
+}
+
+// PanoramaSeam is the joint between a tile and the next
+type PanoramaSeam struct {
+	Overlap    int     `json:"overlap"`    // Pixels shared by the tiles, at the panorama's scale
+	Difference float64 `json:"difference"` // RMS difference across the overlap, 0-255
+	Matched    bool    `json:"matched"`    // The overlapping areas show the same content
+}
+
+// Panorama is a stitched image
+type Panorama struct {
+	Data     []byte
+	Width    int
+	Height   int
+	Seams    []PanoramaSeam
+	Enlarged bool // Scaled up to the requested size
+}
+
+// StitchPanorama joins overlapping tiles, given in order, into one image and
+// returns it as a PNG. Tiles are scaled to the height of the shortest one
+// (the width of the narrowest when vertical), so nothing is enlarged, and
+// each is cross-faded into the one before across their overlap. Overlaps
+// are detected by finding where the end of one tile best matches the start
+// of the next, between 5% and half of the narrower tile; tiles must line up
+// across the seam, as outpainted tiles do, since no offset or perspective
+// is corrected. With a size in opts, the result is cropped to its aspect
+// ratio over the most detailed stretch and scaled to it.
+func StitchPanorama(paths []string, opts PanoramaOptions) (*Panorama, error) {
+	if len(paths) < 2 || len(paths) > MaxPanoramaTiles {
+		return nil, fmt.Errorf("a panorama needs 2-%d images", MaxPanoramaTiles)
+	}
+	if opts.Overlap < 0 || opts.Overlap > MaxPanoramaLap {
+		return nil, fmt.Errorf("overlap must be between 0 and %g", MaxPanoramaLap)
+	}
+
+	// Tiles are stitched left to right; vertical ones are transposed first
+	// and the result back
+	tiles := make([]*image.RGBA, len(paths))
+	height := 0
+	for i, path := range paths {
+		img, err := decodeOriented(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		tile := toRGBA(img)
+		if opts.Vertical {
+			tile = transpose(tile)
+		}
+		tiles[i] = tile
+		if h := tile.Bounds().Dy(); i == 0 || h < height {
+			height = h
+		}
+	}
+	for i, tile := range tiles {
+		b := tile.Bounds()
+		if b.Dy() != height {
+			tiles[i] = scaleImage(tile, max(1, int(math.Round(float64(b.Dx()*height)/float64(b.Dy())))), height)
+		}
+	}
+
+	seams := make([]PanoramaSeam, len(tiles)-1)
+	width := tiles[0].Bounds().Dx()
+	for i := range seams {
+		a, b := tiles[i], tiles[i+1]
+		narrower := min(a.Bounds().Dx(), b.Bounds().Dx())
+		if opts.Detect {
+			seams[i].Overlap = findOverlap(a, b)
+		} else {
+			seams[i].Overlap = int(math.Round(opts.Overlap * float64(narrower)))
+		}
+		if seams[i].Overlap > 0 {
+			mse := seamDifference(brightness(a), brightness(b), a.Bounds().Dx(), b.Bounds().Dx(), height, seams[i].Overlap)
+			seams[i].Difference = math.Round(math.Sqrt(mse)*10) / 10
+		}
+		seams[i].Matched = seams[i].Difference <= panoramaPoorMatch
+		width += b.Bounds().Dx() - seams[i].Overlap
+	}
+	if width*height > MaxPanoramaPixels {
+		return nil, fmt.Errorf("the panorama would be %d pixels long and %d across, more than %d megapixels", width, height, MaxPanoramaPixels/1_000_000)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, tiles[0].Bounds(), tiles[0], image.Point{}, draw.Src)
+	x := tiles[0].Bounds().Dx()
+	for i, seam := range seams {
+		tile := tiles[i+1]
+		x -= seam.Overlap
+		target := image.Rect(x, 0, x+tile.Bounds().Dx(), height)
+
+		// The tile fades in across the overlap
+		mask := image.NewAlpha(tile.Bounds())
+		for col := 0; col < tile.Bounds().Dx(); col++ {
+			a := uint8(255)
+			if col < seam.Overlap {
+				a = uint8((float64(col)+0.5)/float64(seam.Overlap)*255 + 0.5)
+			}
+			for y := 0; y < height; y++ {
+				mask.Pix[y*mask.Stride+col] = a
+			}
+		}
+		draw.DrawMask(canvas, target, tile, image.Point{}, mask, image.Point{}, draw.Over)
+		x = target.Max.X
+	}
+
+	result := canvas
+	if opts.Vertical {
+		result = transpose(canvas)
+	}
+	enlarged := false
+	if opts.Width > 0 && opts.Height > 0 {
+		crop := smartCrop(result, float64(opts.Width)/float64(opts.Height), image.Rectangle{})
+		enlarged = crop.Dx() < opts.Width || crop.Dy() < opts.Height
+		result = scaleImage(result.SubImage(crop), opts.Width, opts.Height)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, result); err != nil {
+		return nil, fmt.Errorf("failed to encode panorama: %w", err)
+	}
+	return &Panorama{
+		Data:     buf.Bytes(),
+		Width:    result.Bounds().Dx(),
+		Height:   result.Bounds().Dy(),
+		Seams:    seams,
+		Enlarged: enlarged,
+	}, nil
+}
+
+// OutpaintStrip prepares the next tile of a strip grown by outpainting,
+// left to right or top to bottom. The image at path, the last tile so far,
+// is scaled to width x height and its last keep columns (rows when
+// vertical) start a canvas of that size; the rest is pre-filled by
+// stretching their edge. It returns the canvas and a mask marking the area
+// to paint white, both as PNGs. The mask reaches a few pixels into the kept
+// area so the seam is repainted too.
+func OutpaintStrip(path string, width, height, keep int, vertical bool) ([]byte, []byte, error) {
+	img, err := decodeOriented(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	tile := toRGBA(img)
+	if vertical {
+		tile = transpose(tile)
+		width, height = height, width
+	}
+	if keep <= 0 || keep >= width {
+		return nil, nil, fmt.Errorf("the kept part of a tile must be narrower than the tile")
+	}
+	if b := tile.Bounds(); b.Dx() != width || b.Dy() != height {
+		tile = scaleImage(tile, width, height)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, image.Rect(0, 0, keep, height), tile, image.Pt(width-keep, 0), draw.Src)
+	for y := 0; y < height; y++ {
+		edge := canvas.Pix[y*canvas.Stride+(keep-1)*4 : y*canvas.Stride+keep*4]
+		for x := keep; x < width; x++ {
+			copy(canvas.Pix[y*canvas.Stride+x*4:], edge)
+		}
+	}
+
+	// Overlap the seam by about 2% of the tile, at least a few pixels
+	seam := min(keep/2, max(4, min(width, height)/50))
+	mask := image.NewRGBA(canvas.Bounds())
+	draw.Draw(mask, mask.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(mask, image.Rect(0, 0, keep-seam, height), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	if vertical {
+		canvas, mask = transpose(canvas), transpose(mask)
+	}
+
+	var canvasBuf, maskBuf bytes.Buffer
+	if err := png.Encode(&canvasBuf, canvas); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode canvas: %w", err)
+	}
+	if err := png.Encode(&maskBuf, mask); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode mask: %w", err)
+	}
+	return canvasBuf.Bytes(), maskBuf.Bytes(), nil
+}
+
+// findOverlap returns the overlap, in pixels, at which the end of a best
+// matches the start of b. Both tiles have the same height. Overlaps are
+// searched on reduced copies first, then refined at full size around the
+// best one found.
+func findOverlap(a, b *image.RGBA) int {
+	wa, wb, height := a.Bounds().Dx(), b.Bounds().Dx(), a.Bounds().Dy()
+	narrower := min(wa, wb)
+	lo := max(1, int(math.Ceil(panoramaMinLap*float64(narrower))))
+	hi := max(lo, int(MaxPanoramaLap*float64(narrower)))
+
+	fullA, fullB := brightness(a), brightness(b)
+	if height <= panoramaSearchHeight {
+		return bestOverlap(fullA, fullB, height, lo, hi)
+	}
+
+	scale := float64(panoramaSearchHeight) / float64(height)
+	reduce := func(img *image.RGBA) []float64 {
+		w := max(1, int(math.Round(float64(img.Bounds().Dx())*scale)))
+		return brightness(resizeImage(img, w, panoramaSearchHeight))
+	}
+	coarse := bestOverlap(reduce(a), reduce(b), panoramaSearchHeight,
+		max(1, int(float64(lo)*scale)), max(1, int(math.Ceil(float64(hi)*scale))))
+	guess := int(math.Round(float64(coarse) / scale))
+	reach := int(math.Ceil(1/scale)) + 1
+	return bestOverlap(fullA, fullB, height, max(lo, guess-reach), min(hi, guess+reach))
+}
+
+// bestOverlap returns the overlap between lo and hi with the smallest mean
+// squared difference between the last columns of a and the first of b, both
+// grayscale images of the given height
+func bestOverlap(a, b []float64, height, lo, hi int) int {
+	best, bestScore := lo, math.Inf(1)
+	wa, wb := len(a)/height, len(b)/height
+	for overlap := lo; overlap <= min(hi, wa, wb); overlap++ {
+		if score := seamDifference(a, b, wa, wb, height, overlap); score < bestScore {
+			best, bestScore = overlap, score
+		}
+	}
+	return best
+}
+
+// seamDifference returns the mean squared difference between the last
+// overlap columns of a and the first of b, grayscale images of the given
+// widths and height
+func seamDifference(a, b []float64, wa, wb, height, overlap int) float64 {
+	var sum float64
+	for y := 0; y < height; y++ {
+		rowA := a[y*wa+wa-overlap : (y+1)*wa]
+		rowB := b[y*wb : y*wb+overlap]
+		for x, v := range rowA {
+			d := v - rowB[x]
+			sum += d * d
+		}
+	}
+	return sum / float64(overlap*height)
+}
+
+// brightness returns the luma of each pixel of img, 0-255, row by row
+func brightness(img *image.RGBA) []float64 {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	out := make([]float64, 0, w*h)
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < w; x++ {
+			p := row[x*4 : x*4+3]
+			out = append(out, luma(float64(p[0]), float64(p[1]), float64(p[2])))
+		}
+	}
+	return out
+}
+
+// toRGBA returns img as an RGBA image at the origin
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+// transpose mirrors img across its diagonal, swapping rows and columns
+func transpose(img *image.RGBA) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	out := image.NewRGBA(image.Rect(0, 0, h, w))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			copy(out.Pix[x*out.Stride+y*4:x*out.Stride+y*4+4], img.Pix[y*img.Stride+x*4:y*img.Stride+x*4+4])
+		}
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// OutputFailure is an output of a multi-file output that could not be saved
+type OutputFailure struct {
+	Index int // Position in the output, from 1
+	Err   error
+}
+
+// PartialOutputError is returned with the outputs that were saved when others
+// of the same multi-file output could not be
+type PartialOutputError struct {
+	Total    int
+	Failures []OutputFailure
+}
+
+func (e *PartialOutputError) Error() string {
+	return fmt.Sprintf("%d of %d outputs could not be saved; output %d: %v", len(e.Failures), e.Total, e.Failures[0].Index, e.Failures[0].Err)
+}
+
+// Notes describes each failed output, for the notes of a result. It returns
+// nil for a nil error.
+func (e *PartialOutputError) Notes() []string {
+	if e == nil {
+		return nil
+	}
+	notes := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		notes[i] = fmt.Sprintf("output %d of %d could not be saved: %v", failure.Index, e.Total, failure.Err)
+	}
+	return notes
+}
+
+// PartialOutputs returns the *PartialOutputError err is or wraps, or nil
+func PartialOutputs(err error) *PartialOutputError {
+	var partial *PartialOutputError
+	if errors.As(err, &partial) {
+		return partial
+	}
+	return nil
+}
+
+// OutputLog collects the paths of the outputs saved in a context, as each is
+// saved
+type OutputLog struct {
+	mu     sync.Mutex
+	paths  []string
+	parent *OutputLog // Log of the enclosing context, which records them too
+}
+
+type outputLogKey struct{}
+
+// WithOutputLog returns a context in which every output saved by
+// SaveOutputsWithRefresh is recorded in the returned OutputLog, as well as in
+// any log ctx already carries
+func WithOutputLog(ctx context.Context) (context.Context, *OutputLog) {
+	parent, _ := ctx.Value(outputLogKey{}).(*OutputLog)
+	l := &OutputLog{parent: parent}
+	return context.WithValue(ctx, outputLogKey{}, l), l
+}
+
+// Paths returns the recorded output paths in the order they were saved
+func (l *OutputLog) Paths() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.paths...)
+}
+
+// recordSaved adds an output to the log in ctx and the logs enclosing it, if
+// there are any
+func recordSaved(ctx context.Context, path string) {
+	l, _ := ctx.Value(outputLogKey{}).(*OutputLog)
+	for ; l != nil; l = l.parent {
+		l.mu.Lock()
+		l.paths = append(l.paths, path)
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pendingDir holds the operations still running in the background, or
+// finished but not yet collected, one file each, so they survive a restart.
+// Like referencesDir, its name is not a storage ID.
+const pendingDir = "pending_operations"
+
+// PendingOperation is a tool call that outlived its initial wait, with what
+// is needed to resume it after a restart
+type PendingOperation struct {
+	ID          string                 `json:"id"` // The prediction_id continue_operation takes
+	Tool        string                 `json:"tool"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	StartedAt   time.Time              `json:"started_at"`
+	OffPeak     bool                   `json:"off_peak,omitempty"` // Waits for the off-peak window when unfinished
+	Predictions []PendingPrediction    `json:"predictions,omitempty"`
+	FinishedAt  *time.Time             `json:"finished_at,omitempty"`
+	Result      map[string]interface{} `json:"result,omitempty"` // JSON response, once finished
+	Error       string                 `json:"error,omitempty"`  // Set when the call failed without a response
+}
+
+// PendingPrediction is a prediction a pending operation created
+type PendingPrediction struct {
+	ID       string `json:"id"`
+	Model    string `json:"model"`
+	InputKey string `json:"input_key,omitempty"` // Matches the prediction when the call is resumed
+}
+
+// SavePending writes a pending operation, replacing its earlier state.
+// Prompts are sealed like those in metadata.
+func (s *Storage) SavePending(op PendingOperation) error {
+	op.Arguments = s.options.Prompts.sealMap(op.Arguments)
+	op.Result = s.options.Prompts.sealMap(op.Result)
+	data, err := json.MarshalIndent(op, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending operation: %w", err)
+	}
+
+	dir := filepath.Join(s.rootPath, pendingDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create pending operations directory: %w", err)
+	}
+	// Write atomically so a crash never leaves a truncated file
+	path := filepath.Join(dir, pendingFilename(op.ID))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write pending operation: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write pending operation: %w", err)
+	}
+	return nil
+}
+
+// PendingOperations returns every saved pending operation, skipping
+// unreadable files
+func (s *Storage) PendingOperations() ([]PendingOperation, error) {
+	dir := filepath.Join(s.rootPath, pendingDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []PendingOperation{}, nil
+		}
+		return nil, fmt.Errorf("failed to read pending operations: %w", err)
+	}
+
+	ops := []PendingOperation{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			slog.Warn("skipping unreadable pending operation", "file", entry.Name(), "error", err)
+			continue
+		}
+		var op PendingOperation
+		if err := json.Unmarshal(data, &op); err != nil || op.ID == "" || op.Tool == "" {
+			slog.Warn("skipping unreadable pending operation", "file", entry.Name(), "error", err)
+			continue
+		}
+		op.Arguments = s.options.Prompts.openMap(op.Arguments)
+		op.Result = s.options.Prompts.openMap(op.Result)
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// RemovePending forgets a pending operation
+func (s *Storage) RemovePending(id string) error {
+	err := os.Remove(filepath.Join(s.rootPath, pendingDir, pendingFilename(id)))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove pending operation: %w", err)
+	}
+	return nil
+}
+
+// pendingFilename names the file of a pending operation. Prediction IDs of
+// other providers carry a provider prefix separated by a colon, which some
+// file systems reject.
+func pendingFilename(id string) string {
+	return strings.NewReplacer(":", "_", "/", "_", `\`, "_").Replace(id) + ".json"
+}
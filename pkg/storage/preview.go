@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// PreviewEdge is the longest edge of a preview image, in pixels
+const PreviewEdge = 512
+
+// previewMark is the watermark text repeated across a preview
+const previewMark = "PREVIEW"
+
+// WatermarkPreview returns the image at path scaled down to fit PreviewEdge
+// and stamped with rows of PREVIEW across it, encoded as PNG. The mark is
+// light with a dark edge, so it shows on any background.
+func WatermarkPreview(path string) ([]byte, error) {
+	src, err := decodeOriented(path)
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if longest := max(width, height); longest > PreviewEdge {
+		width = max(1, width*PreviewEdge/longest)
+		height = max(1, height*PreviewEdge/longest)
+	}
+	canvas := resizeImage(src, width, height)
+
+	// A word spans about half the width; rows alternate their offset so the
+	// mark cannot be cropped out
+	cols := 0
+	for _, c := range previewMark {
+		cols += len(glyphs[string(c)][0]) + 1
+	}
+	cell := max(1, width/(2*cols))
+	wordWidth := cols * cell
+	rowHeight := 7 * cell * 3
+	light := color.NRGBA{R: 255, G: 255, B: 255, A: 110}
+	dark := color.NRGBA{A: 70}
+	for row, y := 0, rowHeight/3; y < height; row, y = row+1, y+rowHeight {
+		for x := -(row % 2) * wordWidth / 2; x < width; x += wordWidth + 2*cell {
+			stampText(canvas, previewMark, x+max(1, cell/3), y+max(1, cell/3), cell, dark)
+			stampText(canvas, previewMark, x, y, cell, light)
+		}
+	}
+
+	data, _, err := encodeImage(canvas, "png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %w", err)
+	}
+	return data, nil
+}
+
+// stampText blends text onto dst with its top-left corner at (x, y), each
+// glyph cell cell pixels square
+func stampText(dst draw.Image, text string, x, y, cell int, c color.Color) {
+	fill := image.NewUniform(c)
+	for _, ch := range text {
+		rows := glyphs[string(ch)]
+		for row, line := range rows {
+			for col, bit := range line {
+				if bit != '#' {
+					continue
+				}
+				rect := image.Rect(x+col*cell, y+row*cell, x+(col+1)*cell, y+(row+1)*cell)
+				draw.Draw(dst, rect, fill, image.Point{}, draw.Over)
+			}
+		}
+		x += (len(rows[0]) + 1) * cell
+	}
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Prompt privacy modes
+const (
+	PromptPrivacyHash    = "hash"    // Store a SHA-256 hash; the prompt cannot be recovered
+	PromptPrivacyEncrypt = "encrypt" // Store AES-256-GCM ciphertext; the server decrypts it on read
+)
+
+// Prefixes of sealed prompt values
+const (
+	hashedPromptPrefix    = "sha256:"
+	encryptedPromptPrefix = "enc:"
+)
+
+// promptKeys are the keys whose string values are prompts, wherever they
+// appear in stored parameters, prediction inputs, or debug bundles
+var promptKeys = map[string]bool{
+	"prompt":           true,
+	"negative_prompt":  true,
+	"original_prompt":  true,
+	"selection_prompt": true,
+	"prompt_b":         true,
+}
+
+// PromptSealer hashes or encrypts prompts before storage writes them to disk
+// and decrypts them when it reads them back. A nil sealer stores prompts as
+// they are.
+type PromptSealer struct {
+	mode string
+	aead cipher.AEAD // Set in encrypt mode
+}
+
+// NewPromptSealer creates a sealer for a privacy mode, or returns nil when mode
+// is empty. Encrypt mode takes a 32-byte key, base64 or hex encoded.
+func NewPromptSealer(mode, key string) (*PromptSealer, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case PromptPrivacyHash:
+		return &PromptSealer{mode: mode}, nil
+	case PromptPrivacyEncrypt:
+	default:
+		return nil, fmt.Errorf("unknown prompt privacy mode %q (use %s or %s)", mode, PromptPrivacyHash, PromptPrivacyEncrypt)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		raw, err = hex.DecodeString(key)
+	}
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("prompt encryption key must be 32 bytes, base64 or hex encoded")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &PromptSealer{mode: mode, aead: aead}, nil
+}
+
+// RedactedPrompt reports whether a stored prompt was hashed and so cannot be
+// recovered
+func RedactedPrompt(value string) bool {
+	return strings.HasPrefix(value, hashedPromptPrefix)
+}
+
+// Seal returns prompt hashed or encrypted. Empty and already sealed values
+// are returned as they are.
+func (p *PromptSealer) Seal(prompt string) string {
+	if p == nil || prompt == "" || strings.HasPrefix(prompt, hashedPromptPrefix) || strings.HasPrefix(prompt, encryptedPromptPrefix) {
+		return prompt
+	}
+	if p.mode == PromptPrivacyHash {
+		sum := sha256.Sum256([]byte(prompt))
+		return hashedPromptPrefix + hex.EncodeToString(sum[:])
+	}
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// Never fall back to storing the prompt in the clear
+		sum := sha256.Sum256([]byte(prompt))
+		return hashedPromptPrefix + hex.EncodeToString(sum[:])
+	}
+	return encryptedPromptPrefix + base64.StdEncoding.EncodeToString(p.aead.Seal(nonce, nonce, []byte(prompt), nil))
+}
+
+// Open returns the prompt an encrypted value holds. Hashed values, values
+// this sealer cannot decrypt, and plain prompts are returned as they are.
+func (p *PromptSealer) Open(value string) string {
+	if p == nil || p.aead == nil || !strings.HasPrefix(value, encryptedPromptPrefix) {
+		return value
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPromptPrefix))
+	if err != nil || len(data) < p.aead.NonceSize() {
+		return value
+	}
+	nonce, ciphertext := data[:p.aead.NonceSize()], data[p.aead.NonceSize():]
+	plaintext, err := p.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return value
+	}
+	return string(plaintext)
+}
+
+// sealMap returns a copy of a parameter map with every prompt sealed,
+// including those in nested maps and lists
+func (p *PromptSealer) sealMap(m map[string]interface{}) map[string]interface{} {
+	if p == nil || m == nil {
+		return m
+	}
+	out, _ := p.transform(m, "", p.Seal).(map[string]interface{})
+	return out
+}
+
+// openMap returns a copy of a parameter map with every encrypted prompt
+// decrypted
+func (p *PromptSealer) openMap(m map[string]interface{}) map[string]interface{} {
+	if p == nil || m == nil {
+		return m
+	}
+	out, _ := p.transform(m, "", p.Open).(map[string]interface{})
+	return out
+}
+
+// transform copies a YAML- or JSON-decoded value, applying fn to the string
+// values of prompt keys
+func (p *PromptSealer) transform(v interface{}, key string, fn func(string) string) interface{} {
+	switch val := v.(type) {
+	case string:
+		if promptKeys[key] {
+			return fn(val)
+		}
+		return val
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = p.transform(item, k, fn)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = p.transform(item, key, fn)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promptsDir holds the prompt history and favorites under the storage root.
+// Its name is not a storage ID, so repair_storage and listings leave it alone.
+const promptsDir = "prompt_history"
+
+// promptHistoryFile is the append-only log of every prompt used
+const promptHistoryFile = "history.jsonl"
+
+// promptMarksFile holds the favorites and ratings of history entries
+const promptMarksFile = "favorites.json"
+
+// promptsMu serializes prompt history and favorites updates within the process
+var promptsMu sync.Mutex
+
+// Outcomes of a prompt
+const (
+	PromptSucceeded  = "succeeded"
+	PromptFailed     = "failed"
+	PromptProcessing = "processing" // Still running when the tool returned
+)
+
+// PromptEntry records one tool call with a prompt and how it went
+type PromptEntry struct {
+	ID             string    `json:"id"`
+	Timestamp      time.Time `json:"timestamp"`
+	Tool           string    `json:"tool"`
+	Prompt         string    `json:"prompt"`
+	NegativePrompt string    `json:"negative_prompt,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	StorageID      string    `json:"storage_id,omitempty"`
+	Outcome        string    `json:"outcome"`
+	ErrorType      string    `json:"error_type,omitempty"`
+
+	// Set from the entry's mark when the history is read
+	Favorite bool   `json:"favorite,omitempty"`
+	Rating   int    `json:"rating,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// PromptMark is what the user recorded about a history entry
+type PromptMark struct {
+	Favorite bool      `json:"favorite,omitempty"`
+	Rating   int       `json:"rating,omitempty"` // 1-5, or 0 when unrated
+	Name     string    `json:"name,omitempty"`   // Label to recall the prompt by
+	Updated  time.Time `json:"updated"`
+}
+
+// PromptFilter selects prompt history entries. Zero fields match everything.
+type PromptFilter struct {
+	Contains      string // Case-insensitive substring of the prompt or name
+	Tool          string
+	Model         string
+	Outcome       string
+	From          time.Time
+	To            time.Time // Exclusive
+	FavoritesOnly bool
+	MinRating     int
+}
+
+// matches reports whether a history entry passes the filter
+func (f PromptFilter) matches(entry PromptEntry) bool {
+	if f.Contains != "" {
+		contains := strings.ToLower(f.Contains)
+		if !strings.Contains(strings.ToLower(entry.Prompt), contains) && !strings.Contains(strings.ToLower(entry.Name), contains) {
+			return false
+		}
+	}
+	if f.Tool != "" && entry.Tool != f.Tool {
+		return false
+	}
+	if f.Model != "" && entry.Model != f.Model {
+		return false
+	}
+	if f.Outcome != "" && entry.Outcome != f.Outcome {
+		return false
+	}
+	if !f.From.IsZero() && entry.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !entry.Timestamp.Before(f.To) {
+		return false
+	}
+	if f.FavoritesOnly && !entry.Favorite {
+		return false
+	}
+	return entry.Rating >= f.MinRating
+}
+
+// RecordPrompt appends a prompt to the history and returns its entry ID
+func (s *Storage) RecordPrompt(entry PromptEntry) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate prompt ID: %w", err)
+	}
+	entry.ID = "p-" + hex.EncodeToString(b)
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Favorite, entry.Rating, entry.Name = false, 0, ""
+	entry.Prompt = s.options.Prompts.Seal(entry.Prompt)
+	entry.NegativePrompt = s.options.Prompts.Seal(entry.NegativePrompt)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal prompt history entry: %w", err)
+	}
+
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(s.rootPath, promptsDir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create prompt history directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(s.rootPath, promptsDir, promptHistoryFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open prompt history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write prompt history: %w", err)
+	}
+	return entry.ID, nil
+}
+
+// PromptHistory returns the history entries matching the filter, newest
+// first, with their favorites and ratings
+func (s *Storage) PromptHistory(filter PromptFilter) ([]PromptEntry, error) {
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+
+	entries, err := s.readPromptHistory()
+	if err != nil {
+		return nil, err
+	}
+	marks, err := s.readPromptMarks()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []PromptEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if mark, ok := marks[entry.ID]; ok {
+			entry.Favorite, entry.Rating, entry.Name = mark.Favorite, mark.Rating, mark.Name
+		}
+		if filter.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched, nil
+}
+
+// MarkPrompt updates the mark of a history entry, found by its entry ID or by
+// the storage ID of its result, and returns the marked entry
+func (s *Storage) MarkPrompt(ref string, update func(*PromptMark)) (*PromptEntry, error) {
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+
+	entries, err := s.readPromptHistory()
+	if err != nil {
+		return nil, err
+	}
+	var entry *PromptEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].ID == ref || entries[i].StorageID == ref {
+			entry = &entries[i]
+			break
+		}
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("no prompt history entry with id or storage id %s", ref)
+	}
+
+	marks, err := s.readPromptMarks()
+	if err != nil {
+		return nil, err
+	}
+	mark := marks[entry.ID]
+	if mark == nil {
+		mark = &PromptMark{}
+	}
+	update(mark)
+	mark.Updated = time.Now()
+	if !mark.Favorite && mark.Rating == 0 && mark.Name == "" {
+		delete(marks, entry.ID)
+	} else {
+		marks[entry.ID] = mark
+	}
+
+	data, err := json.MarshalIndent(marks, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal prompt favorites: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.rootPath, promptsDir, promptMarksFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save prompt favorites: %w", err)
+	}
+	entry.Favorite, entry.Rating, entry.Name = mark.Favorite, mark.Rating, mark.Name
+	return entry, nil
+}
+
+// readPromptHistory reads every history entry, oldest first, skipping
+// malformed lines; callers must hold promptsMu
+func (s *Storage) readPromptHistory() ([]PromptEntry, error) {
+	f, err := os.Open(filepath.Join(s.rootPath, promptsDir, promptHistoryFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []PromptEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open prompt history: %w", err)
+	}
+	defer f.Close()
+
+	entries := []PromptEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // Prompts can be long
+	for scanner.Scan() {
+		var entry PromptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entry.Prompt = s.options.Prompts.Open(entry.Prompt)
+		entry.NegativePrompt = s.options.Prompts.Open(entry.NegativePrompt)
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompt history: %w", err)
+	}
+	return entries, nil
+}
+
+// readPromptMarks reads the favorites index; callers must hold promptsMu
+func (s *Storage) readPromptMarks() (map[string]*PromptMark, error) {
+	data, err := os.ReadFile(filepath.Join(s.rootPath, promptsDir, promptMarksFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]*PromptMark{}, nil
+		}
+		return nil, fmt.Errorf("failed to read prompt favorites: %w", err)
+	}
+
+	marks := map[string]*PromptMark{}
+	if err := json.Unmarshal(data, &marks); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt favorites: %w", err)
+	}
+	return marks, nil
+}
@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// quarantineDir holds outputs held back by output moderation, one directory
+// per operation ID. Like referencesDir, its name is not a storage ID.
+const quarantineDir = "quarantine"
+
+// SaveQuarantined downloads outputs into the operation's quarantine directory
+// instead of its storage directory, so they stay out of listings and
+// regular results
+func (s *Storage) SaveQuarantined(id string, urls []string, filename string) ([]*SavedImage, error) {
+	dir := filepath.Join(quarantineDir, id)
+	if err := os.MkdirAll(filepath.Join(s.rootPath, dir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	return s.SaveOutputs(dir, urls, filename)
+}
+
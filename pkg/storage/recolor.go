@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+)
+
+// Tuning of RecolorToPalette
+const (
+	recolorSampleEdge = 256  // Colors are clustered on at most this many pixels per edge
+	recolorIterations = 12   // k-means rounds
+	recolorSpread     = 48.0 // RGB distance over which a pixel follows a cluster's shift
+	maxRecolorGroups  = 12
+)
+
+// ColorMapping records where one of an image's color groups was moved
+type ColorMapping struct {
+	From  string  `json:"from"`  // Average color of the group
+	To    string  `json:"to"`    // Palette color it was moved toward
+	Share float64 `json:"share"` // Share of the image's opaque pixels in the group
+}
+
+// RecolorToPalette moves the colors of the image at path toward palette and
+// returns the result as a PNG. The image's colors are grouped, each group is
+// matched with its nearest palette color, and every pixel is shifted by its
+// groups' offsets, so shading and texture survive. With exact set, pixels
+// then snap to the nearest palette color, for flat illustrations. strength
+// (0-1) scales the shift. Transparency is kept.
+func RecolorToPalette(path string, palette []color.RGBA, strength float64, exact bool) ([]byte, []ColorMapping, error) {
+	if len(palette) == 0 {
+		return nil, nil, fmt.Errorf("the palette is empty")
+	}
+	src, err := decodeOriented(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	img := toNRGBA(src)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// Group the opaque colors of a sample of the image
+	step := max(1, max(w, h)/recolorSampleEdge)
+	var samples [][3]float64
+	for y := 0; y < h; y += step {
+		for x := 0; x < w; x += step {
+			p := img.Pix[y*img.Stride+x*4:]
+			if p[3] >= 128 {
+				samples = append(samples, [3]float64{float64(p[0]), float64(p[1]), float64(p[2])})
+			}
+		}
+	}
+	if len(samples) == 0 {
+		return nil, nil, fmt.Errorf("the image has no opaque pixels")
+	}
+	centers, counts := kMeans(samples, min(maxRecolorGroups, len(palette)+3))
+
+	targets := make([][3]float64, len(centers))
+	mappings := make([]ColorMapping, len(centers))
+	for i, c := range centers {
+		t := nearestColor(c, palette)
+		targets[i] = [3]float64{float64(t.R), float64(t.G), float64(t.B)}
+		mappings[i] = ColorMapping{
+			From:  hexColor(c),
+			To:    hexColor(targets[i]),
+			Share: math.Round(float64(counts[i])/float64(len(samples))*1000) / 1000,
+		}
+	}
+	sort.SliceStable(mappings, func(i, j int) bool { return mappings[i].Share > mappings[j].Share })
+
+	weights := make([]float64, len(centers))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := img.Pix[y*img.Stride+x*4:]
+			if p[3] == 0 {
+				continue
+			}
+			pixel := [3]float64{float64(p[0]), float64(p[1]), float64(p[2])}
+
+			// Blend the groups' shifts by closeness, so neighbouring shades
+			// move together instead of banding at group edges
+			total := 0.0
+			for i, c := range centers {
+				d := colorDistance(pixel, c)
+				weights[i] = math.Exp(-d * d / (2 * recolorSpread * recolorSpread))
+				total += weights[i]
+			}
+			var shifted [3]float64
+			for ch := 0; ch < 3; ch++ {
+				offset := 0.0
+				for i := range centers {
+					if total > 0 {
+						offset += weights[i] / total * (targets[i][ch] - centers[i][ch])
+					}
+				}
+				shifted[ch] = pixel[ch] + offset
+			}
+			if total == 0 {
+				// Far from every group: follow the nearest
+				i := nearestCenter(pixel, centers)
+				for ch := 0; ch < 3; ch++ {
+					shifted[ch] = pixel[ch] + targets[i][ch] - centers[i][ch]
+				}
+			}
+			if exact {
+				t := nearestColor(shifted, palette)
+				shifted = [3]float64{float64(t.R), float64(t.G), float64(t.B)}
+			}
+			for ch := 0; ch < 3; ch++ {
+				v := pixel[ch] + (shifted[ch]-pixel[ch])*strength
+				p[ch] = uint8(min(255, max(0, v)) + 0.5)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), mappings, nil
+}
+
+// kMeans groups samples into at most k clusters, returning their centers and
+// sizes. It starts from spread-out samples so the result is deterministic.
+func kMeans(samples [][3]float64, k int) ([][3]float64, []int) {
+	// Farthest-point seeding from the first sample
+	centers := [][3]float64{samples[0]}
+	nearest := make([]float64, len(samples))
+	for i, s := range samples {
+		nearest[i] = colorDistance(s, centers[0])
+	}
+	for len(centers) < k {
+		far, farDist := 0, 0.0
+		for i, d := range nearest {
+			if d > farDist {
+				far, farDist = i, d
+			}
+		}
+		if farDist < 1 {
+			break // Fewer distinct colors than k
+		}
+		centers = append(centers, samples[far])
+		for i, s := range samples {
+			nearest[i] = min(nearest[i], colorDistance(s, samples[far]))
+		}
+	}
+
+	counts := make([]int, len(centers))
+	for round := 0; round < recolorIterations; round++ {
+		sums := make([][3]float64, len(centers))
+		for i := range counts {
+			counts[i] = 0
+		}
+		for _, s := range samples {
+			c := nearestCenter(s, centers)
+			counts[c]++
+			for ch := 0; ch < 3; ch++ {
+				sums[c][ch] += s[ch]
+			}
+		}
+		for i := range centers {
+			if counts[i] > 0 {
+				for ch := 0; ch < 3; ch++ {
+					centers[i][ch] = sums[i][ch] / float64(counts[i])
+				}
+			}
+		}
+	}
+
+	// Drop clusters left empty
+	kept, keptCounts := centers[:0], counts[:0]
+	for i, c := range centers {
+		if counts[i] > 0 {
+			kept = append(kept, c)
+			keptCounts = append(keptCounts, counts[i])
+		}
+	}
+	return kept, keptCounts
+}
+
+// nearestCenter returns the index of the center closest to c
+func nearestCenter(c [3]float64, centers [][3]float64) int {
+	best, bestDist := 0, math.MaxFloat64
+	for i, center := range centers {
+		if d := colorDistance(c, center); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// nearestColor returns the palette color closest to c
+func nearestColor(c [3]float64, palette []color.RGBA) color.RGBA {
+	best, bestDist := palette[0], math.MaxFloat64
+	for _, p := range palette {
+		if d := colorDistance(c, [3]float64{float64(p.R), float64(p.G), float64(p.B)}); d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	return best
+}
+
+// colorDistance returns the RGB distance between two colors
+func colorDistance(a, b [3]float64) float64 {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return math.Sqrt(dr*dr + dg*dg + db*db)
+}
+
+// hexColor formats a color as #RRGGBB
+func hexColor(c [3]float64) string {
+	return fmt.Sprintf("#%02X%02X%02X", uint8(c[0]+0.5), uint8(c[1]+0.5), uint8(c[2]+0.5))
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// referencesDir holds named reference sets under the storage root. Its name
+// is not a storage ID, so repair_storage and listings leave it alone.
+const referencesDir = "references"
+
+// referenceSetFile describes a reference set inside its directory
+const referenceSetFile = "set.yaml"
+
+// MaxReferenceImages is the most images a set (or a Gen-4 request) can hold
+const MaxReferenceImages = 3
+
+// Reference set kinds
+const (
+	ReferenceCharacter = "character"
+	ReferenceStyle     = "style"
+)
+
+// referenceNamePattern keeps names usable as Gen-4 tags (3-15 alphanumeric
+// characters) even with the digit suffix of a set's extra images
+var referenceNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]{2,13}$`)
+
+// ReferenceSet is a named character or style: reference images used together
+// under one canonical tag
+type ReferenceSet struct {
+	Name        string    `yaml:"name" json:"name"`
+	Kind        string    `yaml:"kind" json:"kind"`
+	Description string    `yaml:"description,omitempty" json:"description,omitempty"`
+	Images      []string  `yaml:"images" json:"images"` // Filenames within the set directory
+	Created     time.Time `yaml:"created" json:"created"`
+}
+
+// Tags returns the Gen-4 tag of each image: the set name for the first and
+// name2, name3 for the rest
+func (r *ReferenceSet) Tags() []string {
+	tags := make([]string, len(r.Images))
+	for i := range r.Images {
+		tags[i] = r.Name
+		if i > 0 {
+			tags[i] = fmt.Sprintf("%s%d", r.Name, i+1)
+		}
+	}
+	return tags
+}
+
+// ValidateReferenceName checks that a name can be used as a set name and tag
+func ValidateReferenceName(name string) error {
+	if !referenceNamePattern.MatchString(name) {
+		return fmt.Errorf("reference set name must be 3-14 letters or digits, starting with a letter")
+	}
+	return nil
+}
+
+// referenceSetDir returns the directory of a reference set
+func (s *Storage) referenceSetDir(name string) string {
+	return filepath.Join(s.rootPath, referencesDir, name)
+}
+
+// SaveReferenceSet stores a reference set, copying its images so the set no
+// longer depends on the original files. An existing set with the same name
+// is replaced only when replace is set.
+func (s *Storage) SaveReferenceSet(set *ReferenceSet, imagePaths []string, replace bool) error {
+	if err := ValidateReferenceName(set.Name); err != nil {
+		return err
+	}
+	if len(imagePaths) == 0 || len(imagePaths) > MaxReferenceImages {
+		return fmt.Errorf("a reference set needs 1-%d images", MaxReferenceImages)
+	}
+
+	dir := s.referenceSetDir(set.Name)
+	if _, err := os.Stat(dir); err == nil && !replace {
+		return fmt.Errorf("reference set %s already exists", set.Name)
+	}
+
+	// Read every image before touching the stored set
+	images := make([][]byte, len(imagePaths))
+	for i, path := range imagePaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read reference image: %w", err)
+		}
+		if !isImageData(data) {
+			return fmt.Errorf("%s is not a supported image", path)
+		}
+		images[i] = data
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to replace reference set: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create reference set directory: %w", err)
+	}
+
+	set.Images = make([]string, len(images))
+	for i, data := range images {
+		set.Images[i] = fmt.Sprintf("%d%s", i+1, detectImageFormat(data, "", imagePaths[i]))
+		if err := os.WriteFile(filepath.Join(dir, set.Images[i]), data, 0644); err != nil {
+			return fmt.Errorf("failed to save reference image: %w", err)
+		}
+	}
+	if set.Created.IsZero() {
+		set.Created = time.Now()
+	}
+
+	data, err := yaml.Marshal(set)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reference set: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, referenceSetFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to save reference set: %w", err)
+	}
+	return nil
+}
+
+// LoadReferenceSet loads a reference set by name
+func (s *Storage) LoadReferenceSet(name string) (*ReferenceSet, error) {
+	if ValidateReferenceName(name) != nil {
+		return nil, fmt.Errorf("no reference set named %s", name)
+	}
+	data, err := os.ReadFile(filepath.Join(s.referenceSetDir(name), referenceSetFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no reference set named %s", name)
+		}
+		return nil, fmt.Errorf("failed to read reference set: %w", err)
+	}
+
+	var set ReferenceSet
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse reference set: %w", err)
+	}
+	return &set, nil
+}
+
+// ReferenceImagePaths returns the full paths of a set's images
+func (s *Storage) ReferenceImagePaths(set *ReferenceSet) []string {
+	paths := make([]string, len(set.Images))
+	for i, name := range set.Images {
+		paths[i] = filepath.Join(s.referenceSetDir(set.Name), name)
+	}
+	return paths
+}
+
+// ListReferenceSets returns every stored reference set, sorted by name
+func (s *Storage) ListReferenceSets() ([]*ReferenceSet, error) {
+	entries, err := os.ReadDir(filepath.Join(s.rootPath, referencesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*ReferenceSet{}, nil
+		}
+		return nil, fmt.Errorf("failed to read reference sets: %w", err)
+	}
+
+	sets := []*ReferenceSet{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		set, err := s.LoadReferenceSet(entry.Name())
+		if err != nil {
+			continue
+		}
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Name < sets[j].Name })
+	return sets, nil
+}
+
+// DeleteReferenceSet removes a reference set and its images
+func (s *Storage) DeleteReferenceSet(name string) error {
+	if _, err := s.LoadReferenceSet(name); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.referenceSetDir(name)); err != nil {
+		return fmt.Errorf("failed to delete reference set: %w", err)
+	}
+	return nil
+}
+
+// isImageData reports whether data starts with a known image signature
+func isImageData(data []byte) bool {
+	if len(data) < 12 {
+		return false
+	}
+	switch detectImageFormat(data, "", "") {
+	case ".png", ".jpg", ".gif", ".bmp":
+		return true
+	case ".webp":
+		return string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+	}
+	return false
+}
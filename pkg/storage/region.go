@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+// MaxPasteBackPixels bounds the size of an image a region is pasted back
+// into
+const MaxPasteBackPixels = 64_000_000
+
+// PadRegion grows region by margin on every side, within a w x h image
+func PadRegion(region image.Rectangle, margin, w, h int) image.Rectangle {
+	return image.Rect(region.Min.X-margin, region.Min.Y-margin, region.Max.X+margin, region.Max.Y+margin).
+		Intersect(image.Rect(0, 0, w, h))
+}
+
+// PasteRegion pastes the image at patchPath, an enhanced copy of the crop
+// region of the image at basePath, back into it and returns the result as a
+// PNG. The base is first enlarged scale times, so an upscaled patch keeps
+// its resolution; with a scale of 1 the patch is resized down to fit. The
+// patch fades in across the margin between crop and the inner region it
+// was padded from, so no seam shows, except along the image's edges.
+func PasteRegion(basePath, patchPath string, crop, region image.Rectangle, scale int) ([]byte, error) {
+	base, err := decodeOriented(basePath)
+	if err != nil {
+		return nil, err
+	}
+	w, h := base.Bounds().Dx()*scale, base.Bounds().Dy()*scale
+	if w*h > MaxPasteBackPixels {
+		return nil, fmt.Errorf("the image would be %dx%d pasted back at %dx, more than %d megapixels", w, h, scale, MaxPasteBackPixels/1_000_000)
+	}
+	patchImg, err := decodeFile(patchPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load enhanced region: %w", err)
+	}
+
+	canvas := scaleImage(base, w, h)
+	target := image.Rectangle{Min: crop.Min.Mul(scale), Max: crop.Max.Mul(scale)}
+	patch := scaleImage(patchImg, target.Dx(), target.Dy())
+
+	// Opacity ramps up across each side's margin; sides on the image's
+	// edge have none and are pasted hard
+	inner := image.Rectangle{Min: region.Min.Mul(scale), Max: region.Max.Mul(scale)}.Intersect(target)
+	mask := image.NewAlpha(image.Rect(0, 0, target.Dx(), target.Dy()))
+	ramp := func(distance, margin int) float64 {
+		if margin <= 0 {
+			return 1
+		}
+		return min(1, (float64(distance)+0.5)/float64(margin))
+	}
+	for y := 0; y < target.Dy(); y++ {
+		for x := 0; x < target.Dx(); x++ {
+			a := min(
+				ramp(x, inner.Min.X-target.Min.X),
+				ramp(target.Dx()-1-x, target.Max.X-inner.Max.X),
+				ramp(y, inner.Min.Y-target.Min.Y),
+				ramp(target.Dy()-1-y, target.Max.Y-inner.Max.Y),
+			)
+			mask.Pix[y*mask.Stride+x] = uint8(a*255 + 0.5)
+		}
+	}
+	draw.DrawMask(canvas, target, patch, image.Point{}, mask, image.Point{}, draw.Over)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempFilePrefix marks in-progress downloads inside an operation directory
+const tempFilePrefix = ".download-"
+
+// repairMinAge is how long a directory or temp file must go unmodified
+// before a repair removes it, so one an operation is still writing is kept
+const repairMinAge = time.Hour
+
+// RepairReport summarizes the changes made by a storage repair pass
+type RepairReport struct {
+	RemovedIDs       []string `json:"removed_ids"`
+	RemovedTempFiles []string `json:"removed_temp_files"`
+	MissingMetadata  []string `json:"missing_metadata"` // Directories with images but no metadata (left in place)
+	SkippedIDs       []string `json:"skipped_ids"`      // Directories in use or modified recently (left in place)
+	RemovedInputs    []string `json:"removed_inputs"`   // Inputs in the inputs directory no operation refers to
+	Scanned          int      `json:"scanned"`
+	DryRun           bool     `json:"dry_run"`
+}
+
+// CleanupIfEmpty removes an operation directory that holds no saved artifacts.
+// It is safe to defer after GenerateID: directories with outputs are left alone,
+// as are those holding inputs, which ReleaseID removes once the call is done.
+func (s *Storage) CleanupIfEmpty(id string) {
+	dir := filepath.Join(s.rootPath, id)
+	if s.hasArtifacts(dir) {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, inputsDir)); err == nil {
+		return
+	}
+	os.RemoveAll(dir)
+}
+
+// RepairStorage removes orphaned operation directories and stale temp files
+// left behind by failed or interrupted operations. The directories in inUse,
+// and any directory or temp file modified within repairMinAge, belong to
+// operations that may still be running and are left alone.
+func (s *Storage) RepairStorage(dryRun bool, inUse map[string]bool) (*RepairReport, error) {
+	report := &RepairReport{
+		RemovedIDs:       []string{},
+		RemovedTempFiles: []string{},
+		MissingMetadata:  []string{},
+		SkippedIDs:       []string{},
+		RemovedInputs:    []string{},
+		DryRun:           dryRun,
+	}
+
+	entries, err := os.ReadDir(s.rootPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return report, nil
+		}
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		// Only consider directories that look like storage IDs
+		if !entry.IsDir() || !isStorageID(entry.Name()) {
+			continue
+		}
+		report.Scanned++
+
+		id := entry.Name()
+		dir := filepath.Join(s.rootPath, id)
+		if inUse[id] {
+			report.SkippedIDs = append(report.SkippedIDs, id)
+			continue
+		}
+
+		// Remove stale partial downloads; a recent one may still be written
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		active := recentlyModified(entry)
+		for _, file := range files {
+			if !strings.HasPrefix(file.Name(), tempFilePrefix) {
+				continue
+			}
+			if recentlyModified(file) {
+				active = true
+				continue
+			}
+			report.RemovedTempFiles = append(report.RemovedTempFiles, filepath.Join(id, file.Name()))
+			if !dryRun {
+				os.Remove(filepath.Join(dir, file.Name()))
+			}
+		}
+
+		if !s.hasArtifacts(dir) {
+			// A fresh directory may belong to an operation yet to save
+			if active {
+				report.SkippedIDs = append(report.SkippedIDs, id)
+				continue
+			}
+			report.RemovedIDs = append(report.RemovedIDs, id)
+			if !dryRun {
+				if err := os.RemoveAll(dir); err != nil {
+					return nil, fmt.Errorf("failed to remove %s: %w", id, err)
+				}
+			}
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "metadata.yaml")); os.IsNotExist(err) {
+			report.MissingMetadata = append(report.MissingMetadata, id)
+		}
+	}
+
+	if err := s.repairInputs(report, dryRun); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// repairInputs removes the files in the inputs directory that no stored or
+// pending operation refers to, once they are older than repairMinAge. Inputs
+// an operation used are kept, so regenerate can still read them.
+func (s *Storage) repairInputs(report *RepairReport, dryRun bool) error {
+	dir := filepath.Join(s.rootPath, inputsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read inputs directory: %w", err)
+	}
+	var stale []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !recentlyModified(entry) {
+			stale = append(stale, entry.Name())
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	// Operations refer to inputs by path in their metadata, and pending
+	// operations in their saved arguments
+	referenced := make(map[string]bool)
+	err = filepath.WalkDir(s.rootPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path == dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "metadata.yaml" && !(filepath.Base(filepath.Dir(path)) == pendingDir && filepath.Ext(path) == ".json") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, name := range stale {
+			if !referenced[name] && bytes.Contains(data, []byte(name)) {
+				referenced[name] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan metadata: %w", err)
+	}
+
+	for _, name := range stale {
+		if referenced[name] {
+			continue
+		}
+		if strings.HasPrefix(name, tempFilePrefix) {
+			report.RemovedTempFiles = append(report.RemovedTempFiles, filepath.Join(inputsDir, name))
+		} else {
+			report.RemovedInputs = append(report.RemovedInputs, name)
+		}
+		if !dryRun {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+	return nil
+}
+
+// recentlyModified reports whether an entry was modified within repairMinAge.
+// An entry that cannot be read counts as recent, so it is never removed.
+func recentlyModified(entry os.DirEntry) bool {
+	info, err := entry.Info()
+	if err != nil {
+		return true
+	}
+	return time.Since(info.ModTime()) < repairMinAge
+}
+
+// StorageID returns the ID of the operation directory holding path, or ""
+// when path is not inside one
+func (s *Storage) StorageID(path string) string {
+	rel, err := filepath.Rel(s.rootPath, path)
+	if err != nil {
+		return ""
+	}
+	id, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	if !isStorageID(id) {
+		return ""
+	}
+	return id
+}
+
+// hasArtifacts reports whether a directory contains anything besides temp
+// files and downloaded inputs
+func (s *Storage) hasArtifacts(dir string) bool {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), tempFilePrefix) || (file.IsDir() && file.Name() == inputsDir) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// isStorageID reports whether a name matches the format produced by GenerateID
+func isStorageID(name string) bool {
+	if len(name) != 8 {
+		return false
+	}
+	for _, r := range name {
+		if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+// Product placements within a scene
+const (
+	PlaceBottom = "bottom" // Standing on the scene's floor, centered
+	PlaceCenter = "center"
+	PlaceLeft   = "left"  // On the floor, left third
+	PlaceRight  = "right" // On the floor, right third
+)
+
+// SceneOptions controls how a product cutout is composited into a scene
+type SceneOptions struct {
+	Placement  string  // bottom, center, left, or right (default: bottom)
+	Scale      float64 // Product height as a fraction of the scene height (default: 0.6)
+	Shadow     float64 // Drop shadow opacity, 0 for none
+	ColorMatch float64 // 0-1: how far to shift the product's color balance toward the scene's
+}
+
+// floorLine is where the base of a product placed on the floor sits, as a
+// fraction of the scene height
+const floorLine = 0.92
+
+// ComposeProductScene composites a product cutout with transparency onto a
+// scene and returns the result as a PNG
+func ComposeProductScene(productPath, scenePath string, opts SceneOptions) ([]byte, error) {
+	product, err := decodeFile(productPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load product: %w", err)
+	}
+	scene, err := decodeFile(scenePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scene: %w", err)
+	}
+	if opts.Scale <= 0 {
+		opts.Scale = 0.6
+	}
+
+	// Trim the transparent margin so placement follows the visible product
+	cutout := image.NewNRGBA(product.Bounds())
+	draw.Draw(cutout, cutout.Bounds(), product, product.Bounds().Min, draw.Src)
+	visible := opaqueBounds(cutout)
+	if visible.Empty() {
+		return nil, fmt.Errorf("the product cutout is fully transparent")
+	}
+	cutout = cutout.SubImage(visible).(*image.NRGBA)
+
+	canvas := image.NewRGBA(image.Rect(0, 0, scene.Bounds().Dx(), scene.Bounds().Dy()))
+	draw.Draw(canvas, canvas.Bounds(), scene, scene.Bounds().Min, draw.Src)
+	sceneW, sceneH := canvas.Bounds().Dx(), canvas.Bounds().Dy()
+
+	height := max(1, int(float64(sceneH)*opts.Scale))
+	width := max(1, visible.Dx()*height/visible.Dy())
+	if limit := sceneW * 9 / 10; width > limit {
+		width, height = limit, max(1, visible.Dy()*limit/visible.Dx())
+	}
+	scaled := toNRGBA(resizeImage(cutout, width, height))
+
+	if opts.ColorMatch > 0 {
+		matchColors(scaled, canvas, opts.ColorMatch)
+	}
+
+	x := (sceneW - width) / 2
+	y := int(float64(sceneH)*floorLine) - height
+	switch opts.Placement {
+	case PlaceCenter:
+		y = (sceneH - height) / 2
+	case PlaceLeft:
+		x = sceneW/3 - width/2
+	case PlaceRight:
+		x = sceneW*2/3 - width/2
+	}
+	x = min(max(x, 0), sceneW-width)
+	y = min(max(y, 0), sceneH-height)
+
+	if opts.Shadow > 0 {
+		drawShadow(canvas, scaled, x, y, opts.Shadow)
+	}
+	draw.Draw(canvas, image.Rect(x, y, x+width, y+height), scaled, image.Point{}, draw.Over)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode scene: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeFile decodes the image at path
+func decodeFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// toNRGBA converts an image to non-premultiplied RGBA at the origin
+func toNRGBA(img image.Image) *image.NRGBA {
+	out := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+	return out
+}
+
+// opaqueBounds returns the bounds of the pixels that are not nearly
+// transparent
+func opaqueBounds(img *image.NRGBA) image.Rectangle {
+	b := img.Bounds()
+	found := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.NRGBAAt(x, y).A > 8 {
+				found = found.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return found
+}
+
+// matchColors scales the product's channels toward the scene's average
+// color, so the product picks up the scene's lighting tint
+func matchColors(product *image.NRGBA, scene *image.RGBA, strength float64) {
+	var sceneSum, productSum [3]float64
+	var sceneN, productWeight float64
+	sb := scene.Bounds()
+	step := max(1, max(sb.Dx(), sb.Dy())/256)
+	for y := sb.Min.Y; y < sb.Max.Y; y += step {
+		for x := sb.Min.X; x < sb.Max.X; x += step {
+			c := scene.RGBAAt(x, y)
+			sceneSum[0] += float64(c.R)
+			sceneSum[1] += float64(c.G)
+			sceneSum[2] += float64(c.B)
+			sceneN++
+		}
+	}
+	pix := product.Pix
+	for i := 0; i+3 < len(pix); i += 4 {
+		a := float64(pix[i+3]) / 255
+		productSum[0] += float64(pix[i]) * a
+		productSum[1] += float64(pix[i+1]) * a
+		productSum[2] += float64(pix[i+2]) * a
+		productWeight += a
+	}
+	if sceneN == 0 || productWeight == 0 {
+		return
+	}
+
+	// Match the tint, not the brightness: gains are normalized so the
+	// product's overall luminance is kept
+	var gains [3]float64
+	var sceneMean, productMean float64
+	for ch := 0; ch < 3; ch++ {
+		sceneMean += sceneSum[ch] / sceneN / 3
+		productMean += productSum[ch] / productWeight / 3
+	}
+	for ch := 0; ch < 3; ch++ {
+		s := (sceneSum[ch]/sceneN + 1) / (sceneMean + 1)
+		p := (productSum[ch]/productWeight + 1) / (productMean + 1)
+		gains[ch] = 1 + strength*(s/p-1)
+	}
+	for i := 0; i+3 < len(pix); i += 4 {
+		for ch := 0; ch < 3; ch++ {
+			pix[i+ch] = uint8(min(255, max(0, float64(pix[i+ch])*gains[ch])))
+		}
+	}
+}
+
+// drawShadow draws a soft shadow of the product's silhouette, offset down
+// and to the right, onto the canvas
+func drawShadow(canvas *image.RGBA, product *image.NRGBA, x, y int, opacity float64) {
+	b := product.Bounds()
+	radius := max(1, b.Dy()/40)
+	offset := max(1, b.Dy()/50)
+	pad := radius * 2
+
+	// Silhouette alpha on a padded grid, blurred
+	w, h := b.Dx()+2*pad, b.Dy()+2*pad
+	mask := make([]float64, w*h)
+	for py := 0; py < b.Dy(); py++ {
+		for px := 0; px < b.Dx(); px++ {
+			mask[(py+pad)*w+px+pad] = float64(product.NRGBAAt(b.Min.X+px, b.Min.Y+py).A) / 255 * opacity
+		}
+	}
+	for pass := 0; pass < 2; pass++ {
+		mask = boxBlur(mask, w, h, radius)
+	}
+
+	shadow := image.NewAlpha(image.Rect(0, 0, w, h))
+	for i, a := range mask {
+		shadow.Pix[i] = uint8(min(255, a*255))
+	}
+	origin := image.Pt(x-pad+offset, y-pad+offset)
+	draw.DrawMask(canvas, image.Rectangle{Min: origin, Max: origin.Add(image.Pt(w, h))},
+		image.NewUniform(color.Black), image.Point{}, shadow, image.Point{}, draw.Over)
+}
+
+// boxBlur blurs a w x h grid horizontally and then vertically
+func boxBlur(src []float64, w, h, radius int) []float64 {
+	tmp := make([]float64, len(src))
+	out := make([]float64, len(src))
+	span := float64(2*radius + 1)
+	for y := 0; y < h; y++ {
+		var sum float64
+		for x := -radius; x <= radius; x++ {
+			sum += src[y*w+min(max(x, 0), w-1)]
+		}
+		for x := 0; x < w; x++ {
+			tmp[y*w+x] = sum / span
+			sum += src[y*w+min(x+radius+1, w-1)] - src[y*w+max(x-radius, 0)]
+		}
+	}
+	for x := 0; x < w; x++ {
+		var sum float64
+		for y := -radius; y <= radius; y++ {
+			sum += tmp[min(max(y, 0), h-1)*w+x]
+		}
+		for y := 0; y < h; y++ {
+			out[y*w+x] = sum / span
+			sum += tmp[min(y+radius+1, h-1)*w+x] - tmp[max(y-radius, 0)*w+x]
+		}
+	}
+	return out
+}
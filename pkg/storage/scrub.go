@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrStripUnsupported is returned when metadata cannot be stripped from a format
+var ErrStripUnsupported = errors.New("stripping metadata is only supported for JPEG, PNG, and WebP images")
+
+// Metadata kinds reported by StripMetadata
+const (
+	MetadataEXIF    = "exif"
+	MetadataGPS     = "gps"
+	MetadataXMP     = "xmp"
+	MetadataIPTC    = "iptc"
+	MetadataComment = "comment"
+	MetadataText    = "text" // PNG text chunks, such as generation parameters
+)
+
+// StrippedImage is an image with its metadata removed
+type StrippedImage struct {
+	Data    []byte
+	Ext     string   // Extension of the image format: .jpg, .png, or .webp
+	Removed []string // Kinds of metadata removed, sorted; empty when there was none
+	Rotated bool     // EXIF orientation was applied, re-encoding the JPEG
+}
+
+// StripMetadataFile removes EXIF (including GPS), XMP, IPTC, comments, and
+// text chunks from the image at path. See StripMetadata.
+func StripMetadataFile(path string) (*StrippedImage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return StripMetadata(data)
+}
+
+// StripMetadata removes EXIF (including GPS), XMP, IPTC, comments, and text
+// chunks from a JPEG, PNG, or WebP image. The pixel data and color profile
+// are kept as they are, except that a JPEG whose EXIF orientation rotates it
+// is re-encoded upright, since the orientation goes with the EXIF.
+func StripMetadata(data []byte) (*StrippedImage, error) {
+	removed := map[string]bool{}
+	var out []byte
+	var err error
+	rotated := false
+	ext := detectImageFormat(data, "", "")
+	switch ext {
+	case ".jpg":
+		if info := findExif(data); info != nil && info.orientation > 1 && info.orientation <= 8 {
+			// Re-encoding drops every segment but the image itself
+			for _, kind := range jpegMetadata(data) {
+				removed[kind] = true
+			}
+			if out, _, err = normalizeExif(data); err != nil {
+				return nil, fmt.Errorf("failed to apply EXIF orientation: %w", err)
+			}
+			rotated = true
+			break
+		}
+		out, err = stripJPEG(data, removed)
+	case ".png":
+		out, err = stripPNG(data, removed)
+	case ".webp":
+		out, err = stripWebP(data, removed)
+	default:
+		return nil, ErrStripUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stripped := &StrippedImage{Data: out, Ext: ext, Removed: []string{}, Rotated: rotated}
+	for kind := range removed {
+		stripped.Removed = append(stripped.Removed, kind)
+	}
+	sort.Strings(stripped.Removed)
+	return stripped, nil
+}
+
+// jpegMetadata returns the kinds of metadata a JPEG holds
+func jpegMetadata(data []byte) []string {
+	found := map[string]bool{}
+	if _, err := stripJPEG(data, found); err != nil {
+		return nil
+	}
+	kinds := make([]string, 0, len(found))
+	for kind := range found {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// stripJPEG drops the EXIF, XMP, IPTC, and comment segments of a JPEG,
+// recording what it dropped in removed. JFIF, ICC profile, and Adobe color
+// segments are kept.
+func stripJPEG(data []byte, removed map[string]bool) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("invalid JPEG file")
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("invalid JPEG segment")
+		}
+		payload := data[i+4 : end]
+
+		kind := ""
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00")):
+			kind = MetadataEXIF
+			if info := parseExifIFD0(payload[min(6, len(payload)):]); info.hasGPS {
+				removed[MetadataGPS] = true
+			}
+		case marker == 0xE1:
+			kind = MetadataXMP // Standard and extended XMP
+		case marker == 0xED:
+			kind = MetadataIPTC // Photoshop IRB
+		case marker == 0xFE:
+			kind = MetadataComment
+		}
+		if kind != "" {
+			removed[kind] = true
+		} else {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return append(out, data[i:]...), nil
+}
+
+// stripPNG drops the text, EXIF, and time chunks of a PNG, recording what it
+// dropped in removed. Color chunks such as iCCP and sRGB are kept.
+func stripPNG(data []byte, removed map[string]bool) ([]byte, error) {
+	const signatureLen = 8
+	if len(data) < signatureLen+12 {
+		return nil, fmt.Errorf("invalid PNG file")
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:signatureLen]...)
+	for i := signatureLen; i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if end > len(data) {
+			return nil, fmt.Errorf("invalid PNG chunk")
+		}
+		body := data[i+8 : i+8+length]
+
+		kind := ""
+		switch string(data[i+4 : i+8]) {
+		case "eXIf":
+			kind = MetadataEXIF
+			if info := parseExifIFD0(body); info.hasGPS {
+				removed[MetadataGPS] = true
+			}
+		case "iTXt":
+			kind = MetadataText
+			if bytes.HasPrefix(body, []byte(pngXMPKeyword+"\x00")) {
+				kind = MetadataXMP
+			}
+		case "tEXt", "zTXt", "tIME":
+			kind = MetadataText
+		}
+		if kind != "" {
+			removed[kind] = true
+		} else {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// stripWebP drops the EXIF and XMP chunks of a WebP, clearing their flags in
+// the extended header, and records what it dropped in removed
+func stripWebP(data []byte, removed map[string]bool) ([]byte, error) {
+	const headerLen = 12
+	if len(data) < headerLen || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("invalid WebP file")
+	}
+	const (
+		flagXMP  = 0x04
+		flagEXIF = 0x08
+	)
+	out := make([]byte, headerLen, len(data))
+	copy(out, data[:headerLen])
+	vp8x := -1 // Offset of the VP8X flags in out
+	for i := headerLen; i+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		end := i + 8 + size
+		if end > len(data) {
+			return nil, fmt.Errorf("invalid WebP chunk")
+		}
+		if size%2 == 1 && end < len(data) {
+			end++ // Chunks are padded to an even size
+		}
+
+		switch string(data[i : i+4]) {
+		case "EXIF":
+			removed[MetadataEXIF] = true
+			if body := data[i+8 : i+8+size]; parseExifIFD0(bytes.TrimPrefix(body, []byte("Exif\x00\x00"))).hasGPS {
+				removed[MetadataGPS] = true
+			}
+		case "XMP ":
+			removed[MetadataXMP] = true
+		case "VP8X":
+			vp8x = len(out) + 8
+			out = append(out, data[i:end]...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	if vp8x >= 0 && vp8x < len(out) {
+		out[vp8x] &^= flagXMP | flagEXIF
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+// stripNotes describes the metadata removed from an input for its notes
+func stripNotes(name string, stripped *StrippedImage) []string {
+	if len(stripped.Removed) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("removed %s metadata from input %s before upload", strings.Join(stripped.Removed, ", "), name)}
+}
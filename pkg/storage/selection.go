@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+// SelectionMask draws a mask for the image at path that selects the given
+// boxes, in the image's pixel coordinates: white inside the boxes, grown by
+// about 2% of the image so the repainted area overlaps its surroundings, and
+// black elsewhere. It returns the mask as PNG.
+func SelectionMask(path string, boxes []image.Rectangle) ([]byte, error) {
+	src, err := decodeOriented(path)
+	if err != nil {
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy())
+	grow := max(4, min(bounds.Dx(), bounds.Dy())/50)
+
+	mask := image.NewGray(bounds)
+	selected := false
+	for _, box := range boxes {
+		box = box.Inset(-grow).Intersect(bounds)
+		if box.Empty() {
+			continue
+		}
+		draw.Draw(mask, box, image.NewUniform(color.White), image.Point{}, draw.Src)
+		selected = true
+	}
+	if !selected {
+		return nil, fmt.Errorf("no selected area lies within the image")
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, mask); err != nil {
+		return nil, fmt.Errorf("failed to encode mask: %w", err)
+	}
+	return buf.Bytes(), nil
+}
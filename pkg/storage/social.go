@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+// SocialFormat is an image size a social platform expects
+type SocialFormat struct {
+	Name   string // Used in tool arguments and output filenames
+	Label  string
+	Width  int
+	Height int
+}
+
+// Aspect returns the format's width-to-height ratio
+func (f SocialFormat) Aspect() float64 {
+	return float64(f.Width) / float64(f.Height)
+}
+
+// SocialFormats lists the supported targets, square and portrait posts first
+var SocialFormats = []SocialFormat{
+	{Name: "instagram_square", Label: "Instagram square post (1:1)", Width: 1080, Height: 1080},
+	{Name: "instagram_portrait", Label: "Instagram portrait post (4:5)", Width: 1080, Height: 1350},
+	{Name: "instagram_story", Label: "Instagram story or reel (9:16)", Width: 1080, Height: 1920},
+	{Name: "tiktok", Label: "TikTok (9:16)", Width: 1080, Height: 1920},
+	{Name: "pinterest_pin", Label: "Pinterest pin (2:3)", Width: 1000, Height: 1500},
+	{Name: "facebook_post", Label: "Facebook post (1.91:1)", Width: 1200, Height: 630},
+	{Name: "linkedin_post", Label: "LinkedIn post (1.91:1)", Width: 1200, Height: 627},
+	{Name: "x_post", Label: "X post (16:9)", Width: 1600, Height: 900},
+	{Name: "youtube_thumbnail", Label: "YouTube thumbnail (16:9)", Width: 1280, Height: 720},
+	{Name: "facebook_cover", Label: "Facebook cover (2.63:1)", Width: 1640, Height: 624},
+	{Name: "x_header", Label: "X header (3:1)", Width: 1500, Height: 500},
+	{Name: "linkedin_cover", Label: "LinkedIn cover (4:1)", Width: 1584, Height: 396},
+	{Name: "youtube_banner", Label: "YouTube channel banner (16:9)", Width: 2560, Height: 1440},
+}
+
+// DefaultSocialFormats are exported when no targets are named: one of each
+// common aspect ratio plus the cover sizes
+var DefaultSocialFormats = []string{
+	"instagram_square",
+	"instagram_portrait",
+	"instagram_story",
+	"youtube_thumbnail",
+	"facebook_cover",
+	"x_header",
+	"linkedin_cover",
+}
+
+// LookupSocialFormat returns the format with the given name
+func LookupSocialFormat(name string) (SocialFormat, bool) {
+	for _, f := range SocialFormats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return SocialFormat{}, false
+}
+
+// ImageSize returns the upright size of the image at path
+func ImageSize(path string) (int, int, error) {
+	src, err := decodeOriented(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return src.Bounds().Dx(), src.Bounds().Dy(), nil
+}
+
+// CropCoverage returns the share of a w x h image that survives cropping it
+// to the given aspect ratio
+func CropCoverage(w, h int, aspect float64) float64 {
+	source := float64(w) / float64(h)
+	return math.Min(source, aspect) / math.Max(source, aspect)
+}
+
+// SocialImage is an image cropped and resized to a social format
+type SocialImage struct {
+	PNG      []byte
+	Crop     image.Rectangle // Region of the source image that was used
+	Enlarged bool            // The crop was smaller than the format
+}
+
+// RenderSocialFormat crops the image at path to the aspect ratio of f and
+// resizes it to f's size. The crop is centered on focus, in source pixel
+// coordinates, or on the most detailed region of the image when focus is
+// empty.
+func RenderSocialFormat(path string, f SocialFormat, focus image.Rectangle) (*SocialImage, error) {
+	src, err := decodeOriented(path)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	crop := smartCrop(src, f.Aspect(), focus)
+
+	cropped := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), src, crop.Min.Add(bounds.Min), draw.Src)
+	resized := resizeImage(cropped, f.Width, f.Height)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resized); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return &SocialImage{
+		PNG:      buf.Bytes(),
+		Crop:     crop,
+		Enlarged: crop.Dx() < f.Width || crop.Dy() < f.Height,
+	}, nil
+}
+
+// smartCrop returns the largest region of img with the given aspect ratio,
+// positioned around focus when it is set and otherwise over the stretch of
+// the image with the most edge detail
+func smartCrop(img image.Image, aspect float64, focus image.Rectangle) image.Rectangle {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	cw, ch := w, h
+	if float64(w)/float64(h) > aspect {
+		cw = max(1, min(w, int(float64(h)*aspect+0.5)))
+	} else {
+		ch = max(1, min(h, int(float64(w)/aspect+0.5)))
+	}
+	if cw == w && ch == h {
+		return image.Rect(0, 0, w, h)
+	}
+
+	var x, y int
+	if !focus.Empty() {
+		center := image.Pt((focus.Min.X+focus.Max.X)/2, (focus.Min.Y+focus.Max.Y)/2)
+		x, y = center.X-cw/2, center.Y-ch/2
+	} else {
+		x, y = detailOffset(img, cw, ch)
+	}
+	x = min(max(0, x), w-cw)
+	y = min(max(0, y), h-ch)
+	return image.Rect(x, y, x+cw, y+ch)
+}
+
+// detailOffset slides a cw x ch window along the axis img is cropped on and
+// returns the position covering the most edge detail. Detail is measured on a
+// thumbnail and weighted gently toward the center, so flat images crop
+// centered.
+func detailOffset(img image.Image, cw, ch int) (int, int) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	const thumbEdge = 96
+	scale := float64(thumbEdge) / float64(max(w, h))
+	tw, th := max(2, int(float64(w)*scale)), max(2, int(float64(h)*scale))
+	thumb := resizeImage(img, tw, th)
+
+	// Sum edge strength per column or row of the thumbnail
+	horizontal := cw < w
+	n := th
+	if horizontal {
+		n = tw
+	}
+	profile := make([]float64, n)
+	for y := 0; y < th-1; y++ {
+		for x := 0; x < tw-1; x++ {
+			l := luminance(thumb, x, y)
+			edge := math.Abs(float64(l-luminance(thumb, x+1, y))) + math.Abs(float64(l-luminance(thumb, x, y+1)))
+			if horizontal {
+				profile[x] += edge
+			} else {
+				profile[y] += edge
+			}
+		}
+	}
+
+	window := int(float64(ch)*scale + 0.5)
+	if horizontal {
+		window = int(float64(cw)*scale + 0.5)
+	}
+	window = min(max(1, window), n)
+	best, bestScore := (n-window)/2, -1.0
+	for start := 0; start+window <= n; start++ {
+		score := 0.0
+		for i := start; i < start+window; i++ {
+			score += profile[i]
+		}
+		offCenter := math.Abs(float64(start)-float64(n-window)/2) / float64(n)
+		score *= 1 - 0.5*offCenter
+		if score > bestScore {
+			best, bestScore = start, score
+		}
+	}
+
+	offset := int(float64(best)/scale + 0.5)
+	if horizontal {
+		return offset, 0
+	}
+	return 0, offset
+}
+
+// OutpaintCanvas places the image at path, centered, on a canvas with the
+// aspect ratio of f, and returns the canvas and a mask marking the added
+// border white, both as PNGs. The border is pre-filled by stretching the
+// image's edge pixels outward, and the mask reaches a few pixels into the
+// image so the seam is repainted too. Images already at the aspect ratio have
+// nothing to outpaint and are an error.
+func OutpaintCanvas(path string, f SocialFormat) ([]byte, []byte, error) {
+	src, err := decodeOriented(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	cw, ch := w, h
+	if float64(w)/float64(h) < f.Aspect() {
+		cw = int(float64(h)*f.Aspect() + 0.5)
+	} else {
+		ch = int(float64(w)/f.Aspect() + 0.5)
+	}
+	if cw == w && ch == h {
+		return nil, nil, fmt.Errorf("the image already has the aspect ratio of %s", f.Name)
+	}
+	placed := image.Rect((cw-w)/2, (ch-h)/2, (cw-w)/2+w, (ch-h)/2+h)
+
+	canvas := image.NewRGBA(image.Rect(0, 0, cw, ch))
+	draw.Draw(canvas, placed, src, bounds.Min, draw.Src)
+	for y := 0; y < ch; y++ {
+		sy := min(max(y, placed.Min.Y), placed.Max.Y-1)
+		for x := 0; x < cw; x++ {
+			if image.Pt(x, y).In(placed) {
+				continue
+			}
+			sx := min(max(x, placed.Min.X), placed.Max.X-1)
+			canvas.SetRGBA(x, y, canvas.RGBAAt(sx, sy))
+		}
+	}
+
+	// Overlap the seam by about 2% of the image, at least a few pixels
+	overlap := max(4, min(w, h)/50)
+	keep := placed.Inset(overlap)
+	if keep.Empty() {
+		keep = placed
+	}
+	// Edges of the image that touch the canvas edge need no seam
+	if placed.Min.X == 0 {
+		keep.Min.X = 0
+		keep.Max.X = cw
+	}
+	if placed.Min.Y == 0 {
+		keep.Min.Y = 0
+		keep.Max.Y = ch
+	}
+	mask := image.NewGray(image.Rect(0, 0, cw, ch))
+	draw.Draw(mask, mask.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(mask, keep, image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	var canvasBuf, maskBuf bytes.Buffer
+	if err := png.Encode(&canvasBuf, canvas); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode canvas: %w", err)
+	}
+	if err := png.Encode(&maskBuf, mask); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode mask: %w", err)
+	}
+	return canvasBuf.Bytes(), maskBuf.Bytes(), nil
+}
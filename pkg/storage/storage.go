@@ -2,17 +2,24 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/gomcpgo/replicate_image_ai/pkg/tracing"
 	"github.com/gomcpgo/replicate_image_ai/pkg/types"
 	"gopkg.in/yaml.v3"
 )
@@ -20,12 +27,43 @@ import (
 // Storage handles local file storage for images
 type Storage struct {
 	rootPath string
+	options  Options
+
+	downloadSlots chan struct{} // Bounds concurrent downloads across all operations
+	inputs        *inputCache   // Recently prepared inputs, reused by sweeps
+	inputHTTP     *http.Client  // Downloads input URLs, from public addresses only
+	derived       *idLocks      // Operations running under derived IDs
 }
 
-// NewStorage creates a new storage instance
+// NewStorage creates a new storage instance with default options
 func NewStorage(rootPath string) *Storage {
+	return NewStorageWithOptions(rootPath, Options{})
+}
+
+// NewStorageWithOptions creates a new storage instance with custom options
+func NewStorageWithOptions(rootPath string, opts Options) *Storage {
+	if opts.MaxInputBytes <= 0 {
+		opts.MaxInputBytes = DefaultMaxInputBytes
+	}
+	if opts.MaxInputEdge < 0 {
+		opts.MaxInputEdge = 0
+	}
+	if opts.MaxDownloadBytes <= 0 {
+		opts.MaxDownloadBytes = DefaultMaxDownloadBytes
+	}
+	if opts.MaxParallelDownloads <= 0 {
+		opts.MaxParallelDownloads = DefaultMaxParallelDownloads
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = newDownloadClient(opts.MaxParallelDownloads)
+	}
 	return &Storage{
-		rootPath: rootPath,
+		rootPath:      rootPath,
+		options:       opts,
+		downloadSlots: make(chan struct{}, opts.MaxParallelDownloads),
+		inputs:        newInputCache(),
+		inputHTTP:     newInputClient(),
+		derived:       &idLocks{locks: map[string]*idLock{}},
 	}
 }
 
@@ -44,6 +82,8 @@ func detectImageFormat(data []byte, contentType string, url string) string {
 		return ".gif"
 	case strings.Contains(contentType, "image/bmp"):
 		return ".bmp"
+	case strings.Contains(contentType, "image/svg+xml"):
+		return ".svg"
 	}
 	
 	// 2. Check magic bytes (file signatures) - most reliable for actual content
@@ -74,6 +114,11 @@ func detectImageFormat(data []byte, contentType string, url string) string {
 		if bytes.HasPrefix(data, []byte{0x42, 0x4D}) {
 			return ".bmp"
 		}
+		
+		// SVG: an XML document whose root is <svg>
+		if looksLikeSVG(data) {
+			return ".svg"
+		}
 	}
 	
 	// 3. Try to parse from URL as fallback
@@ -93,6 +138,9 @@ func detectImageFormat(data []byte, contentType string, url string) string {
 	if strings.Contains(urlLower, ".bmp") {
 		return ".bmp"
 	}
+	if strings.Contains(urlLower, ".svg") {
+		return ".svg"
+	}
 	
 	// 4. Default to WebP for Replicate (most common output format)
 	return ".webp"
@@ -104,6 +152,11 @@ func (s *Storage) GenerateID() (string, error) {
 	const idLength = 8
 	maxRetries := 100
 
+	// Create the root folder once; each ID directory is then created exclusively
+	if err := os.MkdirAll(s.rootPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
 	for i := 0; i < maxRetries; i++ {
 		b := make([]byte, idLength)
 		if _, err := rand.Read(b); err != nil {
@@ -117,32 +170,170 @@ func (s *Storage) GenerateID() (string, error) {
 
 		idStr := string(id)
 		
-		// Check if this ID already exists
+		// os.Mkdir fails if the directory exists, so creation doubles as the
+		// collision check and is safe under concurrent callers
 		idPath := filepath.Join(s.rootPath, idStr)
-		if _, err := os.Stat(idPath); os.IsNotExist(err) {
-			// ID is unique, create the directory
-			if err := os.MkdirAll(idPath, 0755); err != nil {
-				return "", fmt.Errorf("failed to create directory: %w", err)
-			}
+		err := os.Mkdir(idPath, 0755)
+		if err == nil {
 			return idStr, nil
 		}
+		if os.IsExist(err) {
+			continue
+		}
+		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 
 	return "", fmt.Errorf("failed to generate unique ID after %d attempts", maxRetries)
 }
 
+// DerivedID returns an 8-character ID derived from a request key, such as a
+// cache key, so repeating a request lands in the same directory. The
+// directory is created if needed; an earlier run's files are left until
+// ClearOperation is called, so a failed rerun does not lose them.
+//
+// The ID is held until release is called: an identical request made
+// meanwhile waits for it, rather than clearing files this one is writing.
+func (s *Storage) DerivedID(key string) (id string, release func(), err error) {
+	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+	const idLength = 8
+
+	sum := sha256.Sum256([]byte(key))
+	b := make([]byte, idLength)
+	for j := 0; j < idLength; j++ {
+		b[j] = charset[sum[j]%byte(len(charset))]
+	}
+	id = string(b)
+
+	release = s.derived.lock(id)
+	if err := os.MkdirAll(filepath.Join(s.rootPath, id), 0755); err != nil {
+		release()
+		return "", nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return id, release, nil
+}
+
+// idLocks holds a lock for each derived ID in use
+type idLocks struct {
+	mu    sync.Mutex
+	locks map[string]*idLock
+}
+
+// idLock is the lock of one derived ID and the number of callers holding or
+// waiting for it
+type idLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+// lock waits until no one else holds id, takes it, and returns the function
+// that releases it
+func (l *idLocks) lock(id string) func() {
+	l.mu.Lock()
+	lk := l.locks[id]
+	if lk == nil {
+		lk = &idLock{}
+		l.locks[id] = lk
+	}
+	lk.users++
+	l.mu.Unlock()
+
+	lk.mu.Lock()
+	return func() {
+		lk.mu.Unlock()
+		l.mu.Lock()
+		if lk.users--; lk.users == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// ClearOperation removes everything stored for an operation, keeping its
+// empty directory, before a rerun with a derived ID writes new results
+func (s *Storage) ClearOperation(id string) error {
+	dir := filepath.Join(s.rootPath, id)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", id, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return nil
+}
+
+// ErrOutputExpired is returned when an output can no longer be downloaded, even
+// after re-fetching the prediction
+var ErrOutputExpired = errors.New("output is no longer available for download")
+
+// DownloadError is returned when an output URL cannot be fetched
+type DownloadError struct {
+	StatusCode int   // HTTP status, or 0 when the request itself failed
+	Err        error // Underlying request error, if any
+}
+
+func (e *DownloadError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("failed to download image: %v", e.Err)
+	}
+	return fmt.Sprintf("failed to download image: status %d", e.StatusCode)
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// SavedImage describes an image persisted to storage
+type SavedImage struct {
+	Path   string
+	Size   int64
+	SHA256 string
+	SVG    *types.SVGStats // Set for SVG outputs, which are cleaned on save
+	URL    string          // URL or data URL the output was saved from
+	Index  int             // Position in a multi-file output, from 0
+}
+
 // SaveImage saves an image from a URL or base64 data
 func (s *Storage) SaveImage(id string, imageURL string, filename string) (string, error) {
-	// Download or decode the image first to detect format
-	var imageData []byte
-	var contentType string
+	saved, err := s.SaveOutput(id, imageURL, filename)
+	if err != nil {
+		return "", err
+	}
+	return saved.Path, nil
+}
+
+// SaveOutput streams an image from a URL or base64 data to disk, enforcing the
+// maximum download size and computing a SHA-256 checksum during the copy.
+// A download that turns out truncated or corrupt is fetched again; if every
+// attempt is incomplete the returned DownloadError wraps ErrIncompleteImage,
+// so callers refreshing expired URLs retry it too.
+func (s *Storage) SaveOutput(id string, imageURL string, filename string) (*SavedImage, error) {
 	var err error
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(downloadBackoff)
+		}
+		var saved *SavedImage
+		saved, err = s.saveOutput(id, imageURL, filename)
+		if !errors.Is(err, ErrIncompleteImage) || strings.HasPrefix(imageURL, "data:") {
+			return saved, err
+		}
+		slog.Warn("downloaded image is incomplete, retrying", "storage_id", id, "attempt", attempt, "error", err)
+	}
+	return nil, &DownloadError{Err: err}
+}
+
+// saveOutput makes one attempt at SaveOutput
+func (s *Storage) saveOutput(id string, imageURL string, filename string) (*SavedImage, error) {
+	var body io.Reader
+	var contentType string
+	var expectedSize int64 = -1 // Unknown for base64 data and unsized responses
+	sourceURL := imageURL
 
 	if strings.HasPrefix(imageURL, "data:") {
 		// Base64 encoded data
 		parts := strings.SplitN(imageURL, ",", 2)
 		if len(parts) != 2 {
-			return "", fmt.Errorf("invalid base64 data")
+			return nil, fmt.Errorf("invalid base64 data")
 		}
 		
 		// Extract MIME type from data URL if present
@@ -154,34 +345,69 @@ func (s *Storage) SaveImage(id string, imageURL string, filename string) (string
 			}
 		}
 		
-		imageData, err = base64.StdEncoding.DecodeString(parts[1])
-		if err != nil {
-			return "", fmt.Errorf("failed to decode base64: %w", err)
-		}
+		body = base64.NewDecoder(base64.StdEncoding, strings.NewReader(parts[1]))
+		sourceURL = "" // Don't log or sniff the inline data
 	} else {
 		// URL - download the image
-		resp, err := http.Get(imageURL)
+		resp, release, err := s.fetch(s.options.HTTPClient, imageURL)
 		if err != nil {
-			return "", fmt.Errorf("failed to download image: %w", err)
+			return nil, err
 		}
+		defer release()
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("failed to download image: status %d", resp.StatusCode)
+		if resp.ContentLength > s.options.MaxDownloadBytes {
+			return nil, fmt.Errorf("image exceeds maximum download size (%d bytes > %d bytes)", resp.ContentLength, s.options.MaxDownloadBytes)
 		}
 
 		// Get Content-Type header
 		contentType = resp.Header.Get("Content-Type")
+		expectedSize = resp.ContentLength
+		body = resp.Body
+	}
 
-		imageData, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("failed to read image data: %w", err)
-		}
+	// Read the first bytes for format detection, then stream the rest
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+	head = head[:n]
+
+	// Stream into a temp file in the operation directory
+	dir := filepath.Join(s.rootPath, id)
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	hasher := sha256.New()
+	limited := io.LimitReader(io.MultiReader(bytes.NewReader(head), body), s.options.MaxDownloadBytes+1)
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), limited)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: connection closed after %d bytes", ErrIncompleteImage, written)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	if written > s.options.MaxDownloadBytes {
+		return nil, fmt.Errorf("image exceeds maximum download size (%d bytes)", s.options.MaxDownloadBytes)
+	}
+	if expectedSize >= 0 && written != expectedSize {
+		return nil, fmt.Errorf("%w: received %d of %d bytes", ErrIncompleteImage, written, expectedSize)
+	}
+	if err := verifyImage(tmpPath); err != nil {
+		return nil, err
 	}
 
 	// Detect the actual image format
-	detectedExt := detectImageFormat(imageData, contentType, imageURL)
-	log.Printf("[Storage] Detected image format: %s (Content-Type: %s, URL: %s)", detectedExt, contentType, imageURL)
+	detectedExt := detectImageFormat(head, contentType, sourceURL)
+	slog.Debug("detected image format", "storage_id", id, "format", detectedExt, "content_type", contentType, "url", sourceURL)
 	
 	// Determine final filename
 	if filename == "" {
@@ -193,28 +419,277 @@ func (s *Storage) SaveImage(id string, imageURL string, filename string) (string
 		if existingExt == "" {
 			// Add the detected extension
 			filename = filename + detectedExt
-			log.Printf("[Storage] Added extension to filename: %s", filename)
+			slog.Debug("added extension to filename", "storage_id", id, "filename", filename)
+		} else if !sameExtension(existingExt, detectedExt) {
+			// The saved file must be opened as the image it is, whatever
+			// extension the caller asked for
+			filename = strings.TrimSuffix(filename, existingExt) + detectedExt
+			slog.Warn("replaced extension with detected format", "storage_id", id, "extension", existingExt, "detected", detectedExt)
+		}
+	}
+
+	// Make SVG outputs safe to embed before they are put in place; an SVG
+	// that cannot be parsed is kept as downloaded for validation to reject
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	var svgStats *types.SVGStats
+	if detectedExt == ".svg" {
+		if cleaned, stats, err := cleanSVGFile(tmpPath); err != nil {
+			slog.Warn("failed to clean SVG output", "storage_id", id, "error", err)
 		} else {
-			// Filename already has an extension
-			// Log if it differs from detected format
-			if existingExt != detectedExt {
-				log.Printf("[Storage] Warning: Provided extension %s differs from detected %s", existingExt, detectedExt)
+			sum := sha256.Sum256(cleaned)
+			written, checksum, svgStats = int64(len(cleaned)), hex.EncodeToString(sum[:]), stats
+		}
+	}
+
+	// Move the completed download into place under a name no other file has
+	filesMu.Lock()
+	imagePath := uniquePath(dir, filename)
+	err = os.Rename(tmpPath, imagePath)
+	filesMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	if err := os.Chmod(imagePath, 0644); err != nil {
+		return nil, fmt.Errorf("failed to set image permissions: %w", err)
+	}
+
+	return &SavedImage{
+		Path:   imagePath,
+		Size:   written,
+		SHA256: checksum,
+		SVG:    svgStats,
+		URL:    imageURL,
+	}, nil
+}
+
+// sameExtension reports whether a filename extension names the detected
+// image format, in any case and with .jpeg and .jpg alike
+func sameExtension(ext, detected string) bool {
+	ext = strings.ToLower(ext)
+	return ext == detected || (ext == ".jpeg" && detected == ".jpg")
+}
+
+// filesMu serializes picking an unused filename and claiming it, so outputs
+// saved concurrently into one directory never overwrite each other
+var filesMu sync.Mutex
+
+// reservedFiles are the files storage keeps in an operation directory
+var reservedFiles = map[string]bool{
+	"metadata.yaml": true,
+	debugBundleFile: true,
+}
+
+// uniquePath returns the path of filename in dir, with a numeric suffix
+// (name_2.png, name_3.png, ...) when a file of that name already exists or
+// the name is one storage reserves. Callers must hold filesMu until the file
+// is created.
+func uniquePath(dir, filename string) string {
+	filename = filepath.Base(filename)
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	name := filename
+	for n := 2; ; n++ {
+		if !reservedFiles[strings.ToLower(name)] {
+			if _, err := os.Lstat(filepath.Join(dir, name)); os.IsNotExist(err) {
+				return filepath.Join(dir, name)
+			}
+		}
+		name = fmt.Sprintf("%s_%d%s", base, n, ext)
+	}
+}
+
+// SaveOutputs saves every file in a multi-file output. A single file keeps the
+// given filename; multiple files get indexed names (name_1.png, name_2.png, ...),
+// with additional files named by their detected format.
+func (s *Storage) SaveOutputs(id string, urls []string, filename string) ([]*SavedImage, error) {
+	saved := make([]*SavedImage, len(urls))
+	errs := s.saveEach(context.Background(), id, urls, filename, saved)
+	for i, err := range errs {
+		if err != nil {
+			// Remove the files that did save, so a retry does not leave
+			// them beside its own
+			for _, img := range saved {
+				if img != nil {
+					os.Remove(img.Path)
+				}
+			}
+			return nil, outputError(i, len(urls), err)
+		}
+	}
+	return saved, nil
+}
+
+// saveEach downloads the outputs whose slot in saved is still empty, at
+// most as many at once as the download pool allows, and returns the error of
+// each. Each saved file is recorded in the output log of ctx as it completes.
+func (s *Storage) saveEach(ctx context.Context, id string, urls []string, filename string, saved []*SavedImage) []error {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	if base == "" {
+		base = "image"
+	}
+
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		if saved[i] != nil {
+			continue
+		}
+		name := filename
+		if len(urls) > 1 {
+			name = fmt.Sprintf("%s_%d", base, i+1)
+			if i == 0 {
+				name += ext
 			}
 		}
+		wg.Add(1)
+		go func(i int, url, name string) {
+			defer wg.Done()
+			saved[i], errs[i] = s.SaveOutput(id, url, name)
+			if errs[i] == nil {
+				saved[i].Index = i
+				recordSaved(ctx, saved[i].Path)
+			}
+		}(i, url, name)
+	}
+	wg.Wait()
+	return errs
+}
+
+// outputError reports the failure of one output of a multi-file output,
+// naming its position when there are several
+func outputError(i, total int, err error) error {
+	if total == 1 {
+		return err
+	}
+	return fmt.Errorf("output %d of %d: %w", i+1, total, err)
+}
+
+// SaveOutputsWithRefresh saves a multi-file output like SaveOutputs. Replicate
+// delivery URLs expire, so when a download fails it calls refresh to obtain fresh
+// URLs for the prediction and retries the failed outputs once. A download that
+// also fails the retry is reported as wrapping ErrOutputExpired. When only some
+// outputs fail, the others are kept and returned with a *PartialOutputError.
+func (s *Storage) SaveOutputsWithRefresh(ctx context.Context, id string, urls []string, filename string, refresh func() ([]string, error)) (saved []*SavedImage, err error) {
+	_, span := tracing.Start(ctx, "storage.save_outputs", "storage.id", id, "storage.files", len(urls))
+	defer func() {
+		var total int64
+		for _, img := range saved {
+			total += img.Size
+		}
+		span.SetAttributes("storage.bytes", total)
+		span.RecordError(err)
+		span.End()
+	}()
+
+	slots := make([]*SavedImage, len(urls))
+	errs := s.saveEach(ctx, id, urls, filename, slots)
+	refreshed := false
+	if firstErr := firstError(errs); firstErr != nil && refresh != nil && downloadFailed(errs) {
+		span.SetAttributes("storage.refreshed", true)
+		refreshed = true
+		slog.Warn("output download failed, re-fetching prediction for fresh URLs", "storage_id", id, "error", firstErr)
+		if freshURLs, refreshErr := refresh(); refreshErr == nil && len(freshURLs) == len(urls) {
+			errs = s.saveEach(ctx, id, freshURLs, filename, slots)
+		}
+	}
+
+	var failures []OutputFailure
+	for i, img := range slots {
+		if img != nil {
+			saved = append(saved, img)
+			continue
+		}
+		failErr := errs[i]
+		var downloadErr *DownloadError
+		if refreshed && errors.As(failErr, &downloadErr) {
+			failErr = expiredOrIncomplete(failErr)
+		}
+		failures = append(failures, OutputFailure{Index: i + 1, Err: failErr})
+	}
+	switch {
+	case len(failures) == 0:
+		return saved, nil
+	case len(saved) == 0:
+		return nil, outputError(failures[0].Index-1, len(urls), failures[0].Err)
+	}
+	slog.Warn("some outputs could not be saved", "storage_id", id, "saved", len(saved), "failed", len(failures))
+	return saved, &PartialOutputError{Total: len(urls), Failures: failures}
+}
+
+// firstError returns the first non-nil error in errs
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadFailed reports whether any of errs is a failed download, which
+// fresh URLs may fix
+func downloadFailed(errs []error) bool {
+	for _, err := range errs {
+		var downloadErr *DownloadError
+		if errors.As(err, &downloadErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// expiredOrIncomplete reports a download failure that survived a refresh:
+// incomplete images as they are, anything else as an expired output
+func expiredOrIncomplete(err error) error {
+	if errors.Is(err, ErrIncompleteImage) {
+		return err
 	}
+	return fmt.Errorf("%w: %v", ErrOutputExpired, err)
+}
+
+// Paths returns the file paths of saved outputs
+func Paths(saved []*SavedImage) []string {
+	paths := make([]string, len(saved))
+	for i, img := range saved {
+		paths[i] = img.Path
+	}
+	return paths
+}
 
-	imagePath := filepath.Join(s.rootPath, id, filename)
+// URLs returns the URLs saved outputs were downloaded from, in the order of
+// saved, so they pair with Paths even when some outputs failed to save
+func URLs(saved []*SavedImage) []string {
+	urls := make([]string, len(saved))
+	for i, img := range saved {
+		urls[i] = img.URL
+	}
+	return urls
+}
 
-	// Save the image
-	if err := os.WriteFile(imagePath, imageData, 0644); err != nil {
-		return "", fmt.Errorf("failed to save image: %w", err)
+// Filenames returns the filenames of a multi-file output, or nil for a single file
+func Filenames(saved []*SavedImage) []string {
+	if len(saved) <= 1 {
+		return nil
+	}
+	names := make([]string, len(saved))
+	for i, img := range saved {
+		names[i] = filepath.Base(img.Path)
 	}
+	return names
+}
 
-	return imagePath, nil
+// validOperationID reports whether id names a directory directly under the
+// storage root, so it cannot reach outside it
+func validOperationID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
 }
 
 // SaveMetadata saves metadata for an operation
 func (s *Storage) SaveMetadata(id string, metadata *types.ImageMetadata) error {
+	if !validOperationID(id) {
+		return fmt.Errorf("invalid ID %q", id)
+	}
 	metadataPath := filepath.Join(s.rootPath, id, "metadata.yaml")
 	
 	// Ensure version is set
@@ -227,7 +702,11 @@ func (s *Storage) SaveMetadata(id string, metadata *types.ImageMetadata) error {
 		metadata.Timestamp = time.Now()
 	}
 
-	data, err := yaml.Marshal(metadata)
+	// Seal prompts in a copy so the caller keeps the plain parameters
+	stored := *metadata
+	stored.Parameters = s.options.Prompts.sealMap(metadata.Parameters)
+
+	data, err := yaml.Marshal(&stored)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
@@ -241,6 +720,9 @@ func (s *Storage) SaveMetadata(id string, metadata *types.ImageMetadata) error {
 
 // LoadMetadata loads metadata for an operation
 func (s *Storage) LoadMetadata(id string) (*types.ImageMetadata, error) {
+	if !validOperationID(id) {
+		return nil, fmt.Errorf("invalid ID %q", id)
+	}
 	metadataPath := filepath.Join(s.rootPath, id, "metadata.yaml")
 
 	data, err := os.ReadFile(metadataPath)
@@ -252,12 +734,27 @@ func (s *Storage) LoadMetadata(id string) (*types.ImageMetadata, error) {
 	if err := yaml.Unmarshal(data, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
+	metadata.Parameters = s.options.Prompts.openMap(metadata.Parameters)
 
 	return &metadata, nil
 }
 
-// ListImages lists all stored images
-func (s *Storage) ListImages() ([]types.ImageInfo, error) {
+// UpdateMetadata applies update to the stored metadata of an operation and
+// saves it
+func (s *Storage) UpdateMetadata(id string, update func(*types.ImageMetadata)) (*types.ImageMetadata, error) {
+	metadata, err := s.LoadMetadata(id)
+	if err != nil {
+		return nil, err
+	}
+	update(metadata)
+	if err := s.SaveMetadata(id, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// ListImages lists the stored images matching filter, newest first
+func (s *Storage) ListImages(filter MetadataFilter) ([]types.ImageInfo, error) {
 	entries, err := os.ReadDir(s.rootPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -278,6 +775,9 @@ func (s *Storage) ListImages() ([]types.ImageInfo, error) {
 			// Skip entries without valid metadata
 			continue
 		}
+		if !filter.matches(metadata) {
+			continue
+		}
 
 		// Find the image file
 		imagePath := ""
@@ -302,10 +802,15 @@ func (s *Storage) ListImages() ([]types.ImageInfo, error) {
 			Timestamp: metadata.Timestamp,
 			FilePath:  imagePath,
 			Model:     metadata.Model,
+			Rating:    metadata.Rating,
+			Note:      metadata.Note,
 			Metadata:  metadata.Parameters,
 		})
 	}
 
+	sort.SliceStable(images, func(i, j int) bool {
+		return images[i].Timestamp.After(images[j].Timestamp)
+	})
 	return images, nil
 }
 
@@ -314,38 +819,33 @@ func (s *Storage) GetImagePath(id string, filename string) string {
 	return filepath.Join(s.rootPath, id, filename)
 }
 
+// OperationDir returns the directory holding an operation's files
+func (s *Storage) OperationDir(id string) string {
+	return filepath.Join(s.rootPath, id)
+}
+
 // FileToDataURL converts a local file to a data URL
 func (s *Storage) FileToDataURL(filePath string) (string, error) {
 	return ImageToBase64(filePath)
 }
 
-// ImageToBase64 converts an image file to base64 data URL
+// ImageToBase64 converts an image file to base64 data URL, encoding it
+// straight from disk into a single pre-sized buffer
 func ImageToBase64(filePath string) (string, error) {
-	data, err := os.ReadFile(filePath)
+	info, err := os.Stat(filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Detect MIME type
-	mimeType := "image/png" // default
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".jpg", ".jpeg":
-		mimeType = "image/jpeg"
-	case ".webp":
-		mimeType = "image/webp"
-	case ".gif":
-		mimeType = "image/gif"
-	case ".bmp":
-		mimeType = "image/bmp"
-	}
-
 	// Check file size (5MB limit)
-	if len(data) > 5*1024*1024 {
+	if info.Size() > 5*1024*1024 {
 		return "", fmt.Errorf("image file too large (max 5MB)")
 	}
 
-	// Create data URL
-	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
-	return dataURL, nil
-}
\ No newline at end of file
+	file := &types.FileData{
+		MimeType: mimeTypeForPath(filePath),
+		Path:     filePath,
+		Size:     info.Size(),
+	}
+	return file.DataURL()
+}
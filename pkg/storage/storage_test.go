@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// TestGenerateIDConcurrent generates thousands of IDs from parallel callers
+// and checks that no two are the same and each has its own directory
+func TestGenerateIDConcurrent(t *testing.T) {
+	const workers = 32
+	const perWorker = 200
+
+	s := NewStorage(t.TempDir())
+	ids := make(chan string, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id, err := s.GenerateID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool, workers*perWorker)
+	for id := range ids {
+		if !isStorageID(id) {
+			t.Errorf("GenerateID returned %q, not an 8-character storage ID", id)
+		}
+		if seen[id] {
+			t.Errorf("GenerateID returned %s twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != workers*perWorker {
+		t.Fatalf("got %d unique IDs, want %d", len(seen), workers*perWorker)
+	}
+
+	entries, err := os.ReadDir(s.rootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(seen) {
+		t.Errorf("found %d directories for %d IDs", len(entries), len(seen))
+	}
+}
+
+// TestMetadataRejectsTraversal checks that IDs reaching outside the storage
+// root are refused before any metadata is read or written
+func TestMetadataRejectsTraversal(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	s := NewStorage(root)
+	id, err := s.GenerateID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveMetadata(id, &types.ImageMetadata{ID: id, Operation: "generate_image"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A metadata file outside the root, which a traversing ID would reach
+	outside := filepath.Join(parent, "metadata.yaml")
+	if err := os.WriteFile(outside, []byte("operation: outside\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []string{"", ".", "..", "../..", "../root/" + id, id + "/..", `..\` + id} {
+		if _, err := s.LoadMetadata(bad); err == nil {
+			t.Errorf("LoadMetadata(%q) succeeded", bad)
+		}
+		if _, err := s.UpdateMetadata(bad, func(m *types.ImageMetadata) { m.Note = "changed" }); err == nil {
+			t.Errorf("UpdateMetadata(%q) succeeded", bad)
+		}
+		if err := s.SaveMetadata(bad, &types.ImageMetadata{}); err == nil {
+			t.Errorf("SaveMetadata(%q) succeeded", bad)
+		}
+	}
+	data, err := os.ReadFile(outside)
+	if err != nil || string(data) != "operation: outside\n" {
+		t.Errorf("metadata outside the root was changed: %q, %v", data, err)
+	}
+
+	updated, err := s.UpdateMetadata(id, func(m *types.ImageMetadata) { m.Note = "kept" })
+	if err != nil || updated.Note != "kept" {
+		t.Fatalf("UpdateMetadata(%q) = %v, %v", id, updated, err)
+	}
+}
+
+// TestDownloadInputRefusesPrivateAddress checks that input URLs reaching this
+// machine are refused before anything is fetched or saved
+func TestDownloadInputRefusesPrivateAddress(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer server.Close()
+
+	s := NewStorage(t.TempDir())
+	ctx, id, err := s.ReserveID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{
+		server.URL + "/image.png",
+		strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/image.png",
+	}
+	for _, url := range urls {
+		if _, err := s.DownloadInput(id, url); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("DownloadInput(%s) = %v, want ErrPrivateAddress", url, err)
+		}
+	}
+	if hits != 0 {
+		t.Errorf("server was reached %d times", hits)
+	}
+
+	s.ReleaseID(ctx)
+	if _, err := os.Stat(filepath.Join(s.rootPath, id)); !os.IsNotExist(err) {
+		t.Errorf("reserved directory %s was not removed: %v", id, err)
+	}
+}
+
+// TestSaveOutputForcesDetectedExtension checks that a saved image is named
+// with the extension of its detected format, whatever the filename asks for
+func TestSaveOutputForcesDetectedExtension(t *testing.T) {
+	png := "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"", "image.png"},
+		{"sunset", "sunset.png"},
+		{"sunset.PNG", "sunset.PNG"},
+		{"report.html", "report.png"},
+		{"run.sh", "run.png"},
+		{"photo.jpg", "photo.png"},
+	}
+	s := NewStorage(t.TempDir())
+	for _, tt := range tests {
+		id, err := s.GenerateID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved, err := s.SaveOutput(id, png, tt.filename)
+		if err != nil {
+			t.Fatalf("SaveOutput(%q): %v", tt.filename, err)
+		}
+		if got := filepath.Base(saved.Path); got != tt.want {
+			t.Errorf("SaveOutput(%q) saved %s, want %s", tt.filename, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// looksLikeSVG reports whether the start of a file is an SVG document
+func looksLikeSVG(head []byte) bool {
+	text := bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")))
+	if !bytes.HasPrefix(text, []byte("<?xml")) && !bytes.HasPrefix(text, []byte("<svg")) && !bytes.HasPrefix(text, []byte("<!DOCTYPE svg")) {
+		return false
+	}
+	return bytes.Contains(text, []byte("<svg"))
+}
+
+// ValidateSVG checks that data is a well-formed SVG document with a usable
+// size: the root element is <svg> and it has a viewBox or a width and height.
+// It also returns the number of <path> elements, which is zero for an SVG
+// that draws nothing.
+func ValidateSVG(data []byte) (int, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	var root *xml.StartElement
+	paths := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("invalid SVG: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root == nil {
+			if start.Name.Local != "svg" {
+				return 0, fmt.Errorf("invalid SVG: root element is <%s>", start.Name.Local)
+			}
+			root = &start
+		}
+		if start.Name.Local == "path" {
+			paths++
+		}
+	}
+	if root == nil {
+		return 0, fmt.Errorf("invalid SVG: no <svg> element")
+	}
+
+	attrs := map[string]string{}
+	for _, attr := range root.Attr {
+		attrs[attr.Name.Local] = attr.Value
+	}
+	if viewBox := strings.Fields(strings.ReplaceAll(attrs["viewBox"], ",", " ")); len(viewBox) == 4 {
+		w, errW := strconv.ParseFloat(viewBox[2], 64)
+		h, errH := strconv.ParseFloat(viewBox[3], 64)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			return paths, nil
+		}
+		return 0, fmt.Errorf("invalid SVG: viewBox %q has no area", attrs["viewBox"])
+	}
+	if attrs["width"] == "" || attrs["height"] == "" {
+		return 0, fmt.Errorf("invalid SVG: the <svg> element needs a viewBox or a width and height")
+	}
+	return paths, nil
+}
+
+// cleanSVGFile cleans the SVG document at path in place and returns the
+// cleaned document
+func cleanSVGFile(path string) ([]byte, *types.SVGStats, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read SVG: %w", err)
+	}
+	cleaned, stats, err := CleanSVG(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(path, cleaned, 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write SVG: %w", err)
+	}
+	return cleaned, stats, nil
+}
+
+// Elements that run code or embed other documents, dropped with their content
+var activeSVGElements = map[string]string{
+	"script":        "scripts",
+	"handler":       "scripts",
+	"listener":      "scripts",
+	"foreignObject": "foreign_content",
+	"iframe":        "foreign_content",
+	"embed":         "foreign_content",
+	"object":        "foreign_content",
+	"audio":         "foreign_content",
+	"video":         "foreign_content",
+}
+
+// Namespace prefixes of editor data, which browsers ignore
+var editorSVGPrefixes = map[string]bool{
+	"inkscape": true,
+	"sodipodi": true,
+	"sketch":   true,
+	"serif":    true,
+	"figma":    true,
+}
+
+// Attributes holding coordinates, whose numbers are rounded
+var geometrySVGAttributes = map[string]bool{
+	"d": true, "points": true, "transform": true, "viewBox": true,
+	"x": true, "y": true, "x1": true, "y1": true, "x2": true, "y2": true,
+	"cx": true, "cy": true, "r": true, "rx": true, "ry": true,
+	"width": true, "height": true, "stroke-width": true,
+}
+
+// svgPrecision is the number of decimals coordinates are rounded to
+const svgPrecision = 3
+
+var (
+	svgURLReference = regexp.MustCompile(`url\(\s*['"]?\s*([^'")\s]*)`)
+	svgLongDecimal  = regexp.MustCompile(`-?\d*\.\d{4,}(?:[eE][-+]?\d+)?`)
+)
+
+// CleanSVG sanitizes and minifies an SVG document so it is safe to embed in
+// a web page. Scripts, event handlers, embedded documents, and references to
+// anything outside the document other than links and inline images are
+// removed; comments, metadata, editor data, and formatting whitespace are
+// dropped, and coordinates are rounded. It returns the cleaned document with
+// its element counts and what was removed.
+func CleanSVG(data []byte) ([]byte, *types.SVGStats, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	stats := &types.SVGStats{
+		Elements:     make(map[string]int),
+		Removed:      make(map[string]int),
+		OriginalSize: int64(len(data)),
+	}
+	var out bytes.Buffer
+	var stack []string // Open elements, innermost last
+	skip := 0          // Depth inside a dropped element
+	pending := false   // A start tag is waiting for its closing bracket
+	var style *bytes.Buffer
+	rootSeen := false
+
+	closePending := func() {
+		if pending {
+			out.WriteByte('>')
+			pending = false
+		}
+	}
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid SVG: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			name := qualifiedName(t.Name)
+			if !rootSeen {
+				if t.Name.Local != "svg" {
+					return nil, nil, fmt.Errorf("invalid SVG: root element is <%s>", name)
+				}
+				rootSeen = true
+			}
+			if kind, ok := activeSVGElements[t.Name.Local]; ok {
+				stats.Removed[kind]++
+				skip = 1
+				continue
+			}
+			if t.Name.Local == "metadata" || editorSVGPrefixes[t.Name.Space] {
+				stats.Removed["metadata"]++
+				skip = 1
+				continue
+			}
+			attrs, dropElement := cleanSVGAttributes(t, stats.Removed)
+			if dropElement {
+				skip = 1
+				continue
+			}
+
+			closePending()
+			out.WriteByte('<')
+			out.WriteString(name)
+			for _, attr := range attrs {
+				out.WriteByte(' ')
+				out.WriteString(qualifiedName(attr.Name))
+				out.WriteString(`="`)
+				writeSVGAttribute(&out, attr.Value)
+				out.WriteByte('"')
+			}
+			pending = true
+			stack = append(stack, name)
+			stats.Elements[t.Name.Local]++
+			if t.Name.Local == "style" {
+				style = &bytes.Buffer{}
+			}
+
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(stack) == 0 {
+				return nil, nil, fmt.Errorf("invalid SVG: unexpected </%s>", qualifiedName(t.Name))
+			}
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if style != nil && t.Name.Local == "style" {
+				css := style.String()
+				style = nil
+				if unsafeCSS(css) {
+					stats.Removed["external_references"]++
+				} else if css = strings.TrimSpace(css); css != "" {
+					closePending()
+					xml.EscapeText(&out, []byte(css))
+				}
+			}
+			if pending {
+				out.WriteString("/>")
+				pending = false
+				continue
+			}
+			out.WriteString("</")
+			out.WriteString(name)
+			out.WriteByte('>')
+
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if style != nil {
+				style.Write(t)
+				continue
+			}
+			// Whitespace between elements only formats the file
+			if len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+			closePending()
+			xml.EscapeText(&out, t)
+
+		case xml.Comment:
+			if skip == 0 {
+				stats.Removed["comments"]++
+			}
+
+		case xml.ProcInst, xml.Directive:
+			// The XML declaration, stylesheets, and DOCTYPEs are not needed
+			// inline, and a stylesheet would load an external file
+			if pi, ok := t.(xml.ProcInst); ok && pi.Target == "xml-stylesheet" {
+				stats.Removed["external_references"]++
+			}
+		}
+	}
+	if !rootSeen {
+		return nil, nil, fmt.Errorf("invalid SVG: no <svg> element")
+	}
+	if len(stack) > 0 {
+		return nil, nil, fmt.Errorf("invalid SVG: <%s> is not closed", stack[len(stack)-1])
+	}
+	for kind, n := range stats.Removed {
+		if n == 0 {
+			delete(stats.Removed, kind)
+		}
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), stats, nil
+}
+
+// cleanSVGAttributes returns the attributes of an element that are safe to
+// keep, counting those removed. It reports whether the element should be
+// dropped instead, because what it displays or uses was external.
+func cleanSVGAttributes(start xml.StartElement, removed map[string]int) ([]xml.Attr, bool) {
+	var kept []xml.Attr
+	for _, attr := range start.Attr {
+		local, prefix := attr.Name.Local, attr.Name.Space
+		value := attr.Value
+		lower := strings.ToLower(strings.Join(strings.Fields(value), ""))
+
+		switch {
+		case editorSVGPrefixes[prefix] || (prefix == "xmlns" && editorSVGPrefixes[local]):
+			removed["editor_data"]++
+			continue
+		case strings.HasPrefix(strings.ToLower(local), "on"):
+			removed["event_handlers"]++
+			continue
+		case strings.Contains(lower, "javascript:") || strings.Contains(lower, "vbscript:"):
+			removed["scripts"]++
+			continue
+		case local == "href" && (prefix == "" || prefix == "xlink"):
+			if !safeSVGReference(lower, start.Name.Local == "a") {
+				removed["external_references"]++
+				switch start.Name.Local {
+				case "image", "use", "feImage":
+					return nil, true
+				}
+				continue
+			}
+		case local == "style" && prefix == "" && unsafeCSS(value):
+			removed["external_references"]++
+			continue
+		case strings.Contains(lower, "url("):
+			if unsafeCSS(value) {
+				removed["external_references"]++
+				continue
+			}
+		}
+
+		if geometrySVGAttributes[local] && prefix == "" {
+			value = minifyCoordinates(value)
+		}
+		kept = append(kept, xml.Attr{Name: attr.Name, Value: value})
+	}
+	return kept, false
+}
+
+// safeSVGReference reports whether an href stays inside the document: a
+// fragment or an inline raster image. Links may also point to web pages.
+func safeSVGReference(href string, link bool) bool {
+	switch {
+	case strings.HasPrefix(href, "#"):
+		return true
+	case strings.HasPrefix(href, "data:image/") && !strings.HasPrefix(href, "data:image/svg"):
+		return true
+	case link:
+		return strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "mailto:")
+	}
+	return false
+}
+
+// unsafeCSS reports whether CSS imports or references anything outside the
+// document
+func unsafeCSS(css string) bool {
+	lower := strings.ToLower(css)
+	if strings.Contains(lower, "@import") || strings.Contains(lower, "expression(") || strings.Contains(lower, "javascript:") {
+		return true
+	}
+	for _, m := range svgURLReference.FindAllStringSubmatch(lower, -1) {
+		if !safeSVGReference(m[1], false) {
+			return true
+		}
+	}
+	return false
+}
+
+// minifyCoordinates collapses whitespace and rounds long decimals
+func minifyCoordinates(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	return svgLongDecimal.ReplaceAllStringFunc(value, func(number string) string {
+		f, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return number
+		}
+		rounded := strconv.FormatFloat(f, 'f', svgPrecision, 64)
+		rounded = strings.TrimRight(strings.TrimRight(rounded, "0"), ".")
+		if rounded == "-0" {
+			rounded = "0"
+		}
+		return rounded
+	})
+}
+
+// qualifiedName writes a name with its prefix, as RawToken leaves it
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// writeSVGAttribute writes an attribute value escaped for double quotes
+func writeSVGAttribute(out *bytes.Buffer, value string) {
+	for _, r := range value {
+		switch r {
+		case '&':
+			out.WriteString("&amp;")
+		case '<':
+			out.WriteString("&lt;")
+		case '"':
+			out.WriteString("&quot;")
+		case '\n':
+			out.WriteString("&#xA;")
+		case '\t':
+			out.WriteString("&#x9;")
+		case '\r':
+			out.WriteString("&#xD;")
+		default:
+			out.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// UsageTotals aggregates usage for a group of operations
+type UsageTotals struct {
+	Operations     int     `json:"operations"`
+	Outputs        int     `json:"outputs"`
+	GenerationTime float64 `json:"generation_time"` // seconds of wall-clock time
+	PredictTime    float64 `json:"predict_time"`    // seconds of billed model time
+	Bytes          int64   `json:"bytes"`
+	Cost           float64 `json:"cost"`
+}
+
+// UsageSummary reports usage over a period, grouped by model and by day
+type UsageSummary struct {
+	From    time.Time               `json:"from"`
+	To      time.Time               `json:"to"`
+	Total   UsageTotals             `json:"total"`
+	ByModel map[string]*UsageTotals `json:"by_model"`
+	ByDay   map[string]*UsageTotals `json:"by_day"` // keyed by YYYY-MM-DD in local time
+	Days    []string                `json:"days"`   // sorted keys of ByDay
+}
+
+// UsageSummary assembles usage between from and to (inclusive of from,
+// exclusive of to) from the spend ledger. Operations that predate the ledger
+// are included from their metadata.
+func (s *Storage) UsageSummary(from, to time.Time) (*UsageSummary, error) {
+	entries, err := s.ReadLedger()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in operations recorded only in metadata
+	recorded := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		recorded[entry.StorageID] = true
+	}
+	dirs, err := os.ReadDir(s.rootPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || recorded[dir.Name()] {
+			continue
+		}
+		metadata, err := s.LoadMetadata(dir.Name())
+		if err != nil || metadata.Result == nil {
+			continue
+		}
+		outputs := len(metadata.Result.Files)
+		if outputs == 0 {
+			outputs = 1
+		}
+		entries = append(entries, LedgerEntry{
+			Timestamp:      metadata.Timestamp,
+			StorageID:      metadata.ID,
+			Operation:      metadata.Operation,
+			Model:          metadata.Model,
+			PredictTime:    metadata.Result.PredictTime,
+			GenerationTime: metadata.Result.GenerationTime,
+			Cost:           metadata.Result.CostEstimate,
+			Outputs:        outputs,
+			Bytes:          metadata.Result.FileSize,
+		})
+	}
+
+	summary := &UsageSummary{
+		From:    from,
+		To:      to,
+		ByModel: map[string]*UsageTotals{},
+		ByDay:   map[string]*UsageTotals{},
+		Days:    []string{},
+	}
+
+	for _, entry := range entries {
+		if entry.Timestamp.Before(from) || !entry.Timestamp.Before(to) {
+			continue
+		}
+
+		day := entry.Timestamp.Local().Format("2006-01-02")
+		if summary.ByDay[day] == nil {
+			summary.ByDay[day] = &UsageTotals{}
+			summary.Days = append(summary.Days, day)
+		}
+		if summary.ByModel[entry.Model] == nil {
+			summary.ByModel[entry.Model] = &UsageTotals{}
+		}
+
+		for _, totals := range []*UsageTotals{&summary.Total, summary.ByDay[day], summary.ByModel[entry.Model]} {
+			totals.Operations++
+			totals.Outputs += entry.Outputs
+			totals.GenerationTime += entry.GenerationTime
+			totals.PredictTime += entry.PredictTime
+			totals.Bytes += entry.Bytes
+			totals.Cost += entry.Cost
+		}
+	}
+
+	sort.Strings(summary.Days)
+	return summary, nil
+}
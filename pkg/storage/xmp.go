@@ -0,0 +1,401 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// IPTC digital source types describing how an image was made
+// (https://cv.iptc.org/newscodes/digitalsourcetype/)
+const (
+	SourceTrainedAlgorithmic      = "http://cv.iptc.org/newscodes/digitalsourcetype/trainedAlgorithmicMedia"
+	SourceCompositeWithAlgorithm  = "http://cv.iptc.org/newscodes/digitalsourcetype/compositeWithTrainedAlgorithmicMedia"
+	SourceAlgorithmicallyEnhanced = "http://cv.iptc.org/newscodes/digitalsourcetype/algorithmicallyEnhanced"
+)
+
+// xmpNamespace prefixes the XMP packet in JPEG APP1 segments
+const xmpNamespace = "http://ns.adobe.com/xap/1.0/\x00"
+
+// pngXMPKeyword names the PNG iTXt chunk holding XMP
+const pngXMPKeyword = "XML:com.adobe.xmp"
+
+// ErrEmbedUnsupported is returned when XMP cannot be embedded in a format
+var ErrEmbedUnsupported = errors.New("embedding XMP is supported for PNG and JPEG only")
+
+// DAMFields are the IPTC fields written for digital asset management systems
+type DAMFields struct {
+	Title             string
+	Description       string
+	Creator           string
+	UsageTerms        string
+	Keywords          []string
+	DigitalSourceType string
+	CreatorTool       string
+	Created           time.Time
+	Rating            int // 1-5 stars, or 0 when unrated
+}
+
+// BuildXMP renders fields as an XMP packet using the IPTC Core and Extension
+// schemas, omitting empty fields
+func BuildXMP(f DAMFields) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\xEF\xBB\xBF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	b.WriteString("    xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	b.WriteString("    xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n")
+	b.WriteString("    xmlns:xmpRights=\"http://ns.adobe.com/xap/1.0/rights/\"\n")
+	b.WriteString("    xmlns:Iptc4xmpExt=\"http://iptc.org/std/Iptc4xmpExt/2008-02-29/\">\n")
+
+	simple := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "   <%s>%s</%s>\n", name, escapeXML(value), name)
+		}
+	}
+	container := func(name, kind string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "   <%s>\n    <rdf:%s>\n", name, kind)
+		for _, v := range values {
+			if kind == "Alt" {
+				fmt.Fprintf(&b, "     <rdf:li xml:lang=\"x-default\">%s</rdf:li>\n", escapeXML(v))
+			} else {
+				fmt.Fprintf(&b, "     <rdf:li>%s</rdf:li>\n", escapeXML(v))
+			}
+		}
+		fmt.Fprintf(&b, "    </rdf:%s>\n   </%s>\n", kind, name)
+	}
+	nonEmpty := func(v string) []string {
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	}
+
+	container("dc:title", "Alt", nonEmpty(f.Title))
+	container("dc:description", "Alt", nonEmpty(f.Description))
+	container("dc:creator", "Seq", nonEmpty(f.Creator))
+	container("dc:subject", "Bag", f.Keywords)
+	container("xmpRights:UsageTerms", "Alt", nonEmpty(f.UsageTerms))
+	if !f.Created.IsZero() {
+		simple("xmp:CreateDate", f.Created.Format(time.RFC3339))
+	}
+	simple("xmp:CreatorTool", f.CreatorTool)
+	if f.Rating > 0 {
+		simple("xmp:Rating", strconv.Itoa(f.Rating))
+	}
+	simple("Iptc4xmpExt:DigitalSourceType", f.DigitalSourceType)
+
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+// escapeXML escapes text for use in XML content
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// SidecarPath returns the XMP sidecar path for an image: the image name with
+// an .xmp extension, as DAM and photo tools expect
+func SidecarPath(imagePath string) string {
+	return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".xmp"
+}
+
+// WriteXMPSidecar writes an XMP packet next to an image and returns its path
+func WriteXMPSidecar(imagePath string, packet []byte) (string, error) {
+	sidecar := SidecarPath(imagePath)
+	if err := os.WriteFile(sidecar, packet, 0644); err != nil {
+		return "", fmt.Errorf("failed to write XMP sidecar: %w", err)
+	}
+	return sidecar, nil
+}
+
+// EmbedXMP writes an XMP packet into a PNG or JPEG file, replacing any XMP
+// already present. The pixel data is not re-encoded.
+func EmbedXMP(imagePath string, packet []byte) error {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+
+	var out []byte
+	switch detectImageFormat(data, "", imagePath) {
+	case ".png":
+		out, err = embedPNGXMP(data, packet)
+	case ".jpg":
+		out, err = embedJPEGXMP(data, packet)
+	default:
+		return ErrEmbedUnsupported
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write through a temp file so a failure never leaves a truncated image;
+	// repair_storage cleans up any left behind
+	tmp, err := os.CreateTemp(filepath.Dir(imagePath), tempFilePrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := os.Rename(tmpPath, imagePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace image: %w", err)
+	}
+	return nil
+}
+
+// DAMExport lists the files written by ExportForDAM
+type DAMExport struct {
+	Images   []string `json:"images"`
+	Sidecars []string `json:"sidecars,omitempty"`
+	Embedded []string `json:"embedded,omitempty"`
+}
+
+// ExportForDAM writes an XMP packet for every output of an operation, as a
+// sidecar, embedded in the image, or both. Embedding changes the file, so the
+// recorded size and checksum are refreshed.
+func (s *Storage) ExportForDAM(id string, packet []byte, sidecar, embed bool) (*DAMExport, error) {
+	metadata, files, err := s.loadOutputs(id)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &DAMExport{}
+	for _, name := range files {
+		imagePath := s.GetImagePath(id, name)
+		if _, err := os.Stat(imagePath); err != nil {
+			return nil, fmt.Errorf("output %s is missing: %w", name, err)
+		}
+		export.Images = append(export.Images, imagePath)
+
+		if sidecar {
+			path, err := WriteXMPSidecar(imagePath, packet)
+			if err != nil {
+				return nil, err
+			}
+			export.Sidecars = append(export.Sidecars, path)
+		}
+		if embed {
+			if err := EmbedXMP(imagePath, packet); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			export.Embedded = append(export.Embedded, imagePath)
+
+			if name == metadata.Result.Filename {
+				if err := refreshFileInfo(metadata.Result, imagePath); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if embed {
+		if err := s.SaveMetadata(id, metadata); err != nil {
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+// RewriteOutputs replaces every output of an operation with the file rewrite
+// produces from it. rewrite writes dst, a temp file in the same directory with
+// the same extension; it replaces the output only when rewrite succeeds. The
+// recorded size and checksum are refreshed.
+func (s *Storage) RewriteOutputs(id string, rewrite func(src, dst string) error) ([]string, error) {
+	metadata, files, err := s.loadOutputs(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var rewritten []string
+	for _, name := range files {
+		imagePath := s.GetImagePath(id, name)
+		tmp, err := os.CreateTemp(filepath.Dir(imagePath), tempFilePrefix+"*"+filepath.Ext(name))
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		tmpPath := tmp.Name()
+		tmp.Close()
+
+		if err := rewrite(imagePath, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return rewritten, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := os.Chmod(tmpPath, 0644); err != nil {
+			os.Remove(tmpPath)
+			return rewritten, fmt.Errorf("failed to write image: %w", err)
+		}
+		if err := os.Rename(tmpPath, imagePath); err != nil {
+			os.Remove(tmpPath)
+			return rewritten, fmt.Errorf("failed to replace image: %w", err)
+		}
+		rewritten = append(rewritten, imagePath)
+
+		if name == metadata.Result.Filename {
+			if err := refreshFileInfo(metadata.Result, imagePath); err != nil {
+				return rewritten, err
+			}
+		}
+	}
+
+	if err := s.SaveMetadata(id, metadata); err != nil {
+		return rewritten, err
+	}
+	return rewritten, nil
+}
+
+// loadOutputs returns an operation's metadata and the names of its outputs
+func (s *Storage) loadOutputs(id string) (*types.ImageMetadata, []string, error) {
+	metadata, err := s.LoadMetadata(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if metadata.Result == nil {
+		return nil, nil, fmt.Errorf("operation %s has no output images", id)
+	}
+
+	files := metadata.Result.Files
+	if len(files) == 0 {
+		files = []string{metadata.Result.Filename}
+	}
+	return metadata, files, nil
+}
+
+// refreshFileInfo updates the recorded size and checksum of a changed output
+func refreshFileInfo(result *types.OperationResult, imagePath string) error {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	result.FileSize = int64(len(data))
+	result.SHA256 = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// embedPNGXMP inserts an uncompressed iTXt chunk after IHDR, dropping any
+// existing XMP chunk
+func embedPNGXMP(data, packet []byte) ([]byte, error) {
+	const signatureLen = 8
+	if len(data) < signatureLen+12 {
+		return nil, fmt.Errorf("invalid PNG file")
+	}
+
+	// keyword, null, compression flag, compression method, empty language
+	// tag and translated keyword, then the text
+	var payload bytes.Buffer
+	payload.WriteString(pngXMPKeyword)
+	payload.Write([]byte{0, 0, 0, 0, 0})
+	payload.Write(packet)
+	chunk := pngChunk("iTXt", payload.Bytes())
+
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:signatureLen]...)
+	for i := signatureLen; i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if end > len(data) {
+			return nil, fmt.Errorf("invalid PNG chunk")
+		}
+		chunkType := string(data[i+4 : i+8])
+		body := data[i+8 : i+8+length]
+
+		if chunkType != "iTXt" || !bytes.HasPrefix(body, []byte(pngXMPKeyword+"\x00")) {
+			out = append(out, data[i:end]...)
+		}
+		if chunkType == "IHDR" {
+			out = append(out, chunk...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// pngChunk encodes a PNG chunk with its length and CRC
+func pngChunk(chunkType string, body []byte) []byte {
+	chunk := make([]byte, 8, 12+len(body))
+	binary.BigEndian.PutUint32(chunk[:4], uint32(len(body)))
+	copy(chunk[4:8], chunkType)
+	chunk = append(chunk, body...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// embedJPEGXMP inserts an XMP APP1 segment after the JFIF and EXIF segments,
+// dropping any existing XMP segment
+func embedJPEGXMP(data, packet []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("invalid JPEG file")
+	}
+
+	payloadLen := len(xmpNamespace) + len(packet)
+	if payloadLen+2 > 0xFFFF {
+		return nil, fmt.Errorf("XMP packet is too large to embed in a JPEG (%d bytes)", payloadLen)
+	}
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(payloadLen+2))
+	segment = append(segment, xmpNamespace...)
+	segment = append(segment, packet...)
+
+	out := make([]byte, 0, len(data)+len(segment))
+	out = append(out, data[:2]...)
+	inserted := false
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("invalid JPEG segment")
+		}
+		payload := data[i+4 : end]
+
+		// Keep the leading JFIF/EXIF segments first, as readers expect
+		if !inserted && marker != 0xE0 && !(marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00"))) {
+			out = append(out, segment...)
+			inserted = true
+		}
+		if !(marker == 0xE1 && bytes.HasPrefix(payload, []byte(xmpNamespace))) {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	if !inserted {
+		out = append(out, segment...)
+	}
+	return append(out, data[i:]...), nil
+}
@@ -0,0 +1,302 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export batching limits
+const (
+	exportBatchSize = 64
+	exportInterval  = 5 * time.Second
+	exportQueueSize = 2048
+)
+
+// exporter batches finished spans and sends them to an OTLP/HTTP endpoint
+// (JSON encoding) or writes them to stderr
+type exporter struct {
+	endpoint    string // Empty for console output
+	headers     map[string]string
+	serviceName string
+	version     string
+	httpClient  *http.Client
+	console     io.Writer
+
+	queue chan *Span
+	done  chan struct{}
+}
+
+// Setup configures tracing from the standard OpenTelemetry environment variables:
+//
+//	OTEL_SDK_DISABLED                  true disables tracing
+//	OTEL_TRACES_EXPORTER               otlp, console, or none
+//	OTEL_EXPORTER_OTLP_ENDPOINT        base URL; /v1/traces is appended
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT full traces URL (takes precedence)
+//	OTEL_EXPORTER_OTLP_HEADERS         comma-separated key=value pairs
+//	OTEL_EXPORTER_OTLP_PROTOCOL        http/json, the only protocol supported
+//	OTEL_SERVICE_NAME                  service name (default replicate-image-ai)
+//
+// Tracing stays disabled unless an exporter or endpoint is configured. Any
+// other OTLP protocol, such as grpc or http/protobuf, is an error rather than
+// spans a collector would reject. The returned function flushes pending spans
+// and stops the exporter.
+func Setup(version string) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled {
+		return noop, nil
+	}
+
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+
+	kind := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER"))
+	if kind == "" && endpoint != "" {
+		kind = "otlp"
+	}
+
+	exp := &exporter{
+		serviceName: os.Getenv("OTEL_SERVICE_NAME"),
+		version:     version,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		queue:       make(chan *Span, exportQueueSize),
+		done:        make(chan struct{}),
+	}
+	if exp.serviceName == "" {
+		exp.serviceName = "replicate-image-ai"
+	}
+
+	switch kind {
+	case "", "none":
+		return noop, nil
+	case "console":
+		exp.console = os.Stderr
+	case "otlp":
+		if endpoint == "" {
+			endpoint = "http://localhost:4318/v1/traces"
+		}
+		protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+		if protocol == "" {
+			protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+		}
+		if protocol != "" && protocol != "http/json" {
+			return nil, fmt.Errorf("unsupported OTLP protocol %q (only http/json is supported)", protocol)
+		}
+		headers, err := parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS"))
+		if err != nil {
+			return nil, err
+		}
+		exp.endpoint = endpoint
+		exp.headers = headers
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q (use otlp, console, or none)", kind)
+	}
+
+	go exp.run()
+
+	tracerMu.Lock()
+	tracer = exp
+	tracerMu.Unlock()
+
+	slog.Info("tracing enabled", "exporter", kind, "endpoint", exp.endpoint, "service", exp.serviceName)
+	return exp.shutdown, nil
+}
+
+// enqueue queues a finished span, dropping it if the queue is full
+func (e *exporter) enqueue(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+		slog.Debug("trace queue full, dropping span", "span", span.name)
+	}
+}
+
+// run batches spans until the queue is closed
+func (e *exporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case span, ok := <-e.queue:
+			if !ok {
+				e.export(batch)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				e.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = nil
+		}
+	}
+}
+
+// shutdown stops accepting spans and waits for the final export
+func (e *exporter) shutdown(ctx context.Context) error {
+	tracerMu.Lock()
+	if tracer == e {
+		tracer = nil
+	}
+	tracerMu.Unlock()
+
+	close(e.queue)
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// export sends a batch of spans
+func (e *exporter) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(e.payload(batch))
+	if err != nil {
+		slog.Warn("failed to encode trace batch", "error", err)
+		return
+	}
+
+	if e.console != nil {
+		fmt.Fprintln(e.console, string(body))
+		return
+	}
+
+	req, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("failed to create trace export request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		slog.Warn("failed to export traces", "endpoint", e.endpoint, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		slog.Warn("trace export rejected", "endpoint", e.endpoint, "status", resp.StatusCode)
+	}
+}
+
+// payload builds an OTLP ExportTraceServiceRequest in its JSON encoding
+func (e *exporter) payload(batch []*Span) map[string]interface{} {
+	spans := make([]map[string]interface{}, 0, len(batch))
+	for _, s := range batch {
+		s.mu.Lock()
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parentID != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		if s.errMsg != "" {
+			span["status"] = map[string]interface{}{"code": 2, "message": s.errMsg} // STATUS_CODE_ERROR
+		}
+		s.mu.Unlock()
+		spans = append(spans, span)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{
+						"service.name":    e.serviceName,
+						"service.version": e.version,
+					}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "github.com/gomcpgo/replicate_image_ai", "version": e.version},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// otlpAttributes converts attributes to OTLP key/value form
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(attrs))
+	for key, v := range attrs {
+		var value map[string]interface{}
+		switch val := v.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": val}
+		case bool:
+			value = map[string]interface{}{"boolValue": val}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(val)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": val}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprintf("%v", val)}
+		}
+		out = append(out, map[string]interface{}{"key": key, "value": value})
+	}
+	return out
+}
+
+// parseHeaders parses OTLP header lists ("key=value,key2=value2")
+func parseHeaders(lists ...string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, list := range lists {
+		for _, pair := range strings.Split(list, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid OTLP header %q", pair)
+			}
+			decoded, err := url.QueryUnescape(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid OTLP header %q: %w", pair, err)
+			}
+			headers[strings.TrimSpace(key)] = decoded
+		}
+	}
+	return headers, nil
+}
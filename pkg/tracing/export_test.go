@@ -0,0 +1,206 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// otlpRequest is the part of an OTLP/JSON ExportTraceServiceRequest the
+// tests check
+type otlpRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Scope struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"scope"`
+			Spans []struct {
+				TraceID           string          `json:"traceId"`
+				SpanID            string          `json:"spanId"`
+				ParentSpanID      string          `json:"parentSpanId"`
+				Name              string          `json:"name"`
+				Kind              int             `json:"kind"`
+				StartTimeUnixNano string          `json:"startTimeUnixNano"`
+				EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+				Attributes        []otlpAttribute `json:"attributes"`
+				Status            *struct {
+					Code    int    `json:"code"`
+					Message string `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// attributeValues maps each attribute key to its typed OTLP value
+func attributeValues(attrs []otlpAttribute) map[string]map[string]interface{} {
+	values := make(map[string]map[string]interface{}, len(attrs))
+	for _, attr := range attrs {
+		values[attr.Key] = attr.Value
+	}
+	return values
+}
+
+// TestSetupRejectsUnsupportedProtocol checks that an OTLP protocol other
+// than http/json fails at startup instead of exporting spans a collector
+// cannot read
+func TestSetupRejectsUnsupportedProtocol(t *testing.T) {
+	tests := []struct {
+		protocol string
+		traces   string
+		ok       bool
+	}{
+		{"", "", true},
+		{"http/json", "", true},
+		{"grpc", "", false},
+		{"http/protobuf", "", false},
+		{"http/json", "grpc", false},
+		{"grpc", "http/json", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("OTEL_SDK_DISABLED", "")
+		t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", tt.protocol)
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", tt.traces)
+
+		shutdown, err := Setup("test")
+		if (err == nil) != tt.ok {
+			t.Errorf("Setup with protocol %q and traces protocol %q: %v, want ok %v", tt.protocol, tt.traces, err, tt.ok)
+		}
+		if err == nil {
+			shutdown(context.Background())
+		}
+	}
+}
+
+// TestExportPayload exports a parent and a failed child span to a collector
+// and checks the request it receives
+func TestExportPayload(t *testing.T) {
+	type received struct {
+		path, contentType, apiKey string
+		body                      []byte
+	}
+	requests := make(chan received, 4)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("X-Api-Key"), body}
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_SDK_DISABLED", "")
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-api-key=secret%20key")
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+	t.Setenv("OTEL_SERVICE_NAME", "image-test")
+	shutdown, err := Setup("1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := Start(context.Background(), "tools/call upscale_image", "mcp.tool", "upscale_image")
+	_, child := Start(ctx, "replicate.predict", "attempts", 3, "cached", false, "cost", 0.25, "bytes", int64(1024))
+	child.RecordError(errors.New("prediction failed"))
+	child.End()
+	parent.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var req received
+	select {
+	case req = <-requests:
+	default:
+		t.Fatal("no spans were exported")
+	}
+	if req.path != "/v1/traces" {
+		t.Errorf("path = %s, want /v1/traces", req.path)
+	}
+	if req.contentType != "application/json" {
+		t.Errorf("Content-Type = %s, want application/json", req.contentType)
+	}
+	if req.apiKey != "secret key" {
+		t.Errorf("X-Api-Key = %q, want %q", req.apiKey, "secret key")
+	}
+
+	var payload otlpRequest
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("payload is not OTLP JSON: %v\n%s", err, req.body)
+	}
+	if len(payload.ResourceSpans) != 1 || len(payload.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("payload has %d resource spans, want 1 with one scope:\n%s", len(payload.ResourceSpans), req.body)
+	}
+	resource := attributeValues(payload.ResourceSpans[0].Resource.Attributes)
+	if resource["service.name"]["stringValue"] != "image-test" || resource["service.version"]["stringValue"] != "1.2.3" {
+		t.Errorf("resource attributes = %v", resource)
+	}
+	scope := payload.ResourceSpans[0].ScopeSpans[0]
+	if scope.Scope.Name != "github.com/gomcpgo/replicate_image_ai" || scope.Scope.Version != "1.2.3" {
+		t.Errorf("scope = %+v", scope.Scope)
+	}
+	if len(scope.Spans) != 2 {
+		t.Fatalf("payload has %d spans, want 2", len(scope.Spans))
+	}
+
+	spans := scope.Spans
+	childSpan, parentSpan := spans[0], spans[1]
+	if childSpan.Name != "replicate.predict" || parentSpan.Name != "tools/call upscale_image" {
+		t.Fatalf("spans are %s and %s, want the child then its parent", childSpan.Name, parentSpan.Name)
+	}
+	if len(parentSpan.TraceID) != 32 || len(parentSpan.SpanID) != 16 {
+		t.Errorf("parent trace ID %q and span ID %q are not 16 and 8 hex bytes", parentSpan.TraceID, parentSpan.SpanID)
+	}
+	if childSpan.TraceID != parentSpan.TraceID || childSpan.ParentSpanID != parentSpan.SpanID {
+		t.Errorf("child trace %s and parent %s, want %s and %s", childSpan.TraceID, childSpan.ParentSpanID, parentSpan.TraceID, parentSpan.SpanID)
+	}
+	if parentSpan.ParentSpanID != "" || parentSpan.Status != nil {
+		t.Errorf("root span has parent %q and status %+v", parentSpan.ParentSpanID, parentSpan.Status)
+	}
+	if childSpan.Status == nil || childSpan.Status.Code != 2 || childSpan.Status.Message != "prediction failed" {
+		t.Errorf("child status = %+v, want error code 2 with its message", childSpan.Status)
+	}
+	for _, span := range spans {
+		start, err1 := strconv.ParseInt(span.StartTimeUnixNano, 10, 64)
+		end, err2 := strconv.ParseInt(span.EndTimeUnixNano, 10, 64)
+		if err1 != nil || err2 != nil || start <= 0 || end < start {
+			t.Errorf("%s times are %s to %s", span.Name, span.StartTimeUnixNano, span.EndTimeUnixNano)
+		}
+		if span.Kind != 1 {
+			t.Errorf("%s kind = %d, want 1", span.Name, span.Kind)
+		}
+	}
+
+	attrs := attributeValues(childSpan.Attributes)
+	want := map[string]map[string]interface{}{
+		"attempts": {"intValue": "3"},
+		"cached":   {"boolValue": false},
+		"cost":     {"doubleValue": 0.25},
+		"bytes":    {"intValue": "1024"},
+	}
+	for key, value := range want {
+		got, _ := json.Marshal(attrs[key])
+		expected, _ := json.Marshal(value)
+		if string(got) != string(expected) {
+			t.Errorf("attribute %s = %s, want %s", key, got, expected)
+		}
+	}
+	if attributeValues(parentSpan.Attributes)["mcp.tool"]["stringValue"] != "upscale_image" {
+		t.Errorf("parent attributes = %v", parentSpan.Attributes)
+	}
+}
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// tracer is the process-wide tracer; nil when tracing is disabled
+var (
+	tracerMu sync.RWMutex
+	tracer   *exporter
+)
+
+// spanKey is the context key for the active span
+type spanKey struct{}
+
+// Span is a single timed operation within a trace. A nil span is valid and
+// records nothing, so instrumented code pays no cost when tracing is disabled.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]interface{}
+	errMsg   string
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// Start begins a span as a child of the span in ctx, if any. Attributes are
+// given as alternating keys and values, like slog.
+func Start(ctx context.Context, name string, kv ...interface{}) (context.Context, *Span) {
+	tracerMu.RLock()
+	enabled := tracer != nil
+	tracerMu.RUnlock()
+	if !enabled {
+		return ctx, nil
+	}
+
+	span := &Span{
+		name:  name,
+		start: time.Now(),
+		attrs: map[string]interface{}{},
+	}
+	if parent := FromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+	span.SetAttributes(kv...)
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the active span in ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttributes adds alternating key/value attributes to the span
+func (s *Span) SetAttributes(kv ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		if key, ok := kv[i].(string); ok {
+			s.attrs[key] = kv[i+1]
+		}
+	}
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.errMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	// Hold the lock while queueing so shutdown cannot close the queue mid-send
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+	if tracer != nil {
+		tracer.enqueue(s)
+	}
+}
+
+// TraceID returns the span's trace ID as a hex string
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
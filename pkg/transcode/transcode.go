@@ -0,0 +1,146 @@
+// Package transcode re-encodes saved outputs into a format the model that
+// made them cannot produce
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register GIF decoder
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats
+const (
+	FormatJPG  = "jpg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+	FormatAVIF = "avif"
+)
+
+// DefaultTool is the ImageMagick binary used when no path is configured
+const DefaultTool = "magick"
+
+// encodeTimeout bounds a single ImageMagick run
+const encodeTimeout = 60 * time.Second
+
+// errUnsupported is returned by encodeNative for sources it cannot decode
+var errUnsupported = errors.New("unsupported source format")
+
+// Valid reports whether format is an output format
+func Valid(format string) bool {
+	switch format {
+	case FormatJPG, FormatPNG, FormatWebP, FormatAVIF:
+		return true
+	}
+	return false
+}
+
+// Encoder re-encodes images. JPEG and PNG are written natively from PNG,
+// JPEG, and GIF sources; WebP, AVIF, and other sources go through ImageMagick.
+type Encoder struct {
+	tool    string
+	toolErr error // Why ImageMagick cannot be used, if it cannot
+}
+
+// New creates an encoder running the ImageMagick binary at toolPath, or
+// DefaultTool on PATH when toolPath is empty. A missing binary only fails
+// the encodes that need it.
+func New(toolPath string) *Encoder {
+	if toolPath == "" {
+		toolPath = DefaultTool
+	}
+	path, err := exec.LookPath(toolPath)
+	return &Encoder{tool: path, toolErr: err}
+}
+
+// Encode writes the image at src to dst in format. quality (1-100, or 0 for
+// the encoder's default) applies to the lossy formats.
+func (e *Encoder) Encode(ctx context.Context, src, dst, format string, quality int) error {
+	if !Valid(format) {
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+	if format == FormatJPG || format == FormatPNG {
+		if err := encodeNative(src, dst, format, quality); !errors.Is(err, errUnsupported) {
+			return err
+		}
+	}
+	if e.toolErr != nil {
+		return fmt.Errorf("encoding %s requires ImageMagick: %w", format, e.toolErr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, encodeTimeout)
+	defer cancel()
+
+	args := []string{src}
+	if format == FormatJPG {
+		// JPEG has no alpha channel
+		args = append(args, "-background", "white", "-flatten")
+	}
+	if quality > 0 && format != FormatPNG {
+		args = append(args, "-quality", strconv.Itoa(quality))
+	}
+	args = append(args, format+":"+dst)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.tool, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ImageMagick failed: %s", msg)
+		}
+		return fmt.Errorf("ImageMagick failed: %w", err)
+	}
+	return nil
+}
+
+// encodeNative encodes JPEG or PNG with the standard library, returning
+// errUnsupported when it cannot decode src
+func encodeNative(src, dst, format string, quality int) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return errUnsupported
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create image: %w", err)
+	}
+	if format == FormatPNG {
+		err = png.Encode(out, img)
+	} else {
+		// JPEG has no alpha channel, so flatten onto white
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		opts := &jpeg.Options{Quality: jpeg.DefaultQuality}
+		if quality > 0 {
+			opts.Quality = quality
+		}
+		err = jpeg.Encode(out, flat, opts)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package types
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// FileData is a file sent to a model inline as a base64 data URL. The data URL
+// is encoded on demand while the request body is written, so it never has to be
+// held in memory alongside the raw bytes and the marshalled request.
+type FileData struct {
+	MimeType string
+	Path     string // Streamed from disk when Data is nil
+	Data     []byte // Prepared bytes (e.g. after resizing), if they differ from the file
+	Size     int64  // Raw size in bytes
+}
+
+// EncodedLen returns the length of the base64 payload
+func (f *FileData) EncodedLen() int {
+	return base64.StdEncoding.EncodedLen(int(f.Size))
+}
+
+// Open returns a reader for the raw file bytes, for APIs that take uploads
+// rather than data URLs
+func (f *FileData) Open() (io.ReadCloser, error) {
+	if f.Data != nil {
+		return io.NopCloser(bytes.NewReader(f.Data)), nil
+	}
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return file, nil
+}
+
+// WriteTo streams the data URL to w
+func (f *FileData) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, "data:"+f.MimeType+";base64,"); err != nil {
+		return cw.n, err
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return cw.n, err
+	}
+	defer src.Close()
+
+	enc := base64.NewEncoder(base64.StdEncoding, cw)
+	if _, err := io.Copy(enc, src); err != nil {
+		return cw.n, err
+	}
+	err = enc.Close()
+	return cw.n, err
+}
+
+// DataURL returns the complete data URL, built in a single pre-sized buffer
+func (f *FileData) DataURL() (string, error) {
+	var b strings.Builder
+	b.Grow(len("data:;base64,") + len(f.MimeType) + f.EncodedLen())
+	if _, err := f.WriteTo(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// String summarizes the data URL without its payload, so logging or printing
+// an input never dumps the encoded file
+func (f *FileData) String() string {
+	return fmt.Sprintf("data:%s;base64,<%d bytes>", f.MimeType, f.EncodedLen())
+}
+
+// MarshalJSON encodes the full data URL. Request bodies use EncodeJSON instead,
+// which streams it.
+func (f *FileData) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJSON writes v as JSON to w, streaming any FileData values it contains
+// rather than materializing their data URLs. Map keys are written in sorted
+// order, matching encoding/json.
+func EncodeJSON(w io.Writer, v interface{}) error {
+	switch val := v.(type) {
+	case *FileData:
+		// Base64 and the data URL prefix contain no characters that need escaping
+		if _, err := io.WriteString(w, `"`); err != nil {
+			return err
+		}
+		if _, err := val.WriteTo(w); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, `"`)
+		return err
+	case []*FileData:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = item
+		}
+		return EncodeJSON(w, items)
+	case []interface{}:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		for i, item := range val {
+			if i > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			if err := EncodeJSON(w, item); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "]")
+		return err
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		if _, err := io.WriteString(w, "{"); err != nil {
+			return err
+		}
+		for i, k := range keys {
+			if i > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			key, _ := json.Marshal(k)
+			if _, err := w.Write(append(key, ':')); err != nil {
+				return err
+			}
+			if err := EncodeJSON(w, val[k]); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "}")
+		return err
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -4,54 +4,6 @@ import (
 	"time"
 )
 
-// Model IDs for Replicate models - ALL VERIFIED TO EXIST
-const (
-	// ============== GENERATION MODELS ==============
-	// All of these are CONFIRMED to exist on Replicate
-	
-	ModelFluxSchnell   = "black-forest-labs/flux-schnell"        // Fast generation (default)
-	ModelFluxDev       = "black-forest-labs/flux-dev"            // Development version  
-	ModelFluxPro       = "black-forest-labs/flux-pro"            // High quality (paid)
-	ModelSDXL          = "stability-ai/sdxl:7762fd07cf82c948538e41f63f77d685e02b063e37e496e96eefd46c929f9bdc"                     // Stable Diffusion XL
-	ModelSDXLLightning = "bytedance/sdxl-lightning-4step:6f7a773af6fc3e8de9d5a3c00be77c17308914bf67772726aff83496ba1e3bbe"        // Fast SDXL variant
-	ModelSeedream3     = "bytedance/seedream-3"                  // High quality
-	ModelIdeogramTurbo = "ideogram-ai/ideogram-v3-turbo"         // Text in images
-	ModelRecraft       = "recraft-ai/recraft-v3"                 // Raster images
-	ModelRecraftSVG    = "recraft-ai/recraft-v3-svg"             // SVG generation
-	ModelImagen4       = "google/imagen-4"                       // Google's photorealistic image generation
-	ModelGen4Image     = "runwayml/gen4-image"                   // RunwayML Gen-4 with reference image support
-	
-	// ============== ENHANCEMENT MODELS ==============
-	// All with version hashes for reliability
-	
-	ModelGFPGAN        = "tencentarc/gfpgan:297a243ce8643961d52f745f9b6c8c1bd96850a51c92be5f43628a0d3e08321a"                     // Face restoration
-	ModelCodeFormer    = "sczhou/codeformer:cc4956dd26fa5a7185d5660cc9100fab1b8070a1d1654a8bb5eb6d443b020bb2"                     // Face enhancement  
-	ModelRealESRGAN    = "nightmareai/real-esrgan:f121d640bd286e1fdc67f9799164c1d5be36ff74576ee11c803ae5b665dd46aa"               // Image upscaling
-	ModelClarityUpscaler = "philz1337x/clarity-upscaler:dfad41707589d68ecdccd1dfa600d55a208f9310748e44bfe35b4a6291453d5e"         // Advanced upscaling
-	
-	// ============== BACKGROUND REMOVAL ==============
-	// All with version hashes for reliability
-	
-	ModelRemoveBG      = "lucataco/remove-bg:95fcc2a26d3899cd6c2691c900465aaeff466285a65c14638cc5f36f34befaf1"                    // Fast removal
-	ModelRembg         = "cjwbw/rembg:fb8af171cfa1616ddcf1242c093f9c46bcada5ad4cf6f2fbe8b81b330ec5c003"                           // Alternative BG removal
-	ModelDISBGRemoval  = "lucataco/dis-background-removal:5b67a0da2b417b71066754fa8550d668a12edccfe3f32de062f7e20ac0b5d55e"       // High accuracy removal
-	
-	// ============== IMAGE EDITING ==============
-	
-	ModelInpainting    = "stability-ai/stable-diffusion-inpainting:95b7223104132402a9ae91cc677285bc5eb997834bd2349fa486f53910fd68b3"  // SD inpainting
-	
-	// ============== FLUX KONTEXT TEXT-BASED EDITING ==============
-	// Natural language image editing without masks
-	
-	ModelFluxKontextPro = "black-forest-labs/flux-kontext-pro"  // Balanced speed/quality (recommended default)
-	ModelFluxKontextMax = "black-forest-labs/flux-kontext-max"  // Highest quality, premium tier
-	ModelFluxKontextDev = "black-forest-labs/flux-kontext-dev"  // Advanced controls, more parameters
-	
-	// ============== PHOTO RESTORATION ==============
-	
-	ModelOldPhotoRestore = "microsoft/bringing-old-photos-back-to-life:c75db81db6cbd809d93cc3b7e7a088a351a3349c9fa02b6d393e35e0d51ba799"  // Old photo restoration
-)
-
 // Prediction statuses from Replicate
 const (
 	StatusStarting   = "starting"
@@ -71,16 +23,32 @@ type ImageMetadata struct {
 	Parameters  map[string]interface{} `yaml:"parameters"`
 	Result      *OperationResult       `yaml:"result,omitempty"`
 	Error       *string                `yaml:"error,omitempty"`
+	Rating      int                    `yaml:"rating,omitempty"` // 1-5 stars set by rate_image
+	Note        string                 `yaml:"note,omitempty"`   // Free-text note set by annotate_image
 }
 
 // OperationResult contains the result of an operation
 type OperationResult struct {
 	Filename        string  `yaml:"filename"`
 	GenerationTime  float64 `yaml:"generation_time"`
-	CostEstimate    float64 `yaml:"cost_estimate,omitempty"`
+	CostEstimate    float64 `yaml:"cost_estimate,omitempty"` // Actual cost computed from prediction metrics
+	CostBasis       string  `yaml:"cost_basis,omitempty"`
+	PredictTime     float64 `yaml:"predict_time,omitempty"` // Billed model time reported by Replicate
 	PredictionID    string  `yaml:"prediction_id"`
 	Width           int     `yaml:"width,omitempty"`
 	Height          int     `yaml:"height,omitempty"`
+	FileSize        int64   `yaml:"file_size,omitempty"`
+	SHA256          string  `yaml:"sha256,omitempty"`
+	Files           []string `yaml:"files,omitempty"` // All saved filenames for multi-file outputs
+	Provider        string   `yaml:"provider,omitempty"` // Inference provider that ran the prediction
+	SVG             *SVGStats `yaml:"svg,omitempty"`     // How an SVG output was cleaned
+}
+
+// SVGStats describes an SVG output after sanitization and optimization
+type SVGStats struct {
+	Elements     map[string]int `yaml:"elements"`          // Elements kept, by name
+	Removed      map[string]int `yaml:"removed,omitempty"` // What was stripped, by kind
+	OriginalSize int64          `yaml:"original_size"`
 }
 
 // ReplicatePredictionRequest represents a request to create a prediction
@@ -99,6 +67,8 @@ type ReplicatePredictionResponse struct {
 	Output      interface{}            `json:"output"`
 	Error       interface{}            `json:"error"`
 	Logs        string                 `json:"logs"`
+	Metrics     *PredictionMetrics     `json:"metrics,omitempty"`
+	Provider    string                 `json:"-"` // Provider that served the prediction
 	CreatedAt   string                 `json:"created_at"`
 	StartedAt   *string                `json:"started_at"`
 	CompletedAt *string                `json:"completed_at"`
@@ -108,6 +78,20 @@ type ReplicatePredictionResponse struct {
 	} `json:"urls"`
 }
 
+// PredictionMetrics contains timing reported by Replicate for a prediction
+type PredictionMetrics struct {
+	PredictTime float64 `json:"predict_time"`
+	TotalTime   float64 `json:"total_time,omitempty"`
+}
+
+// PredictTime returns the billed predict time in seconds, or 0 if not reported
+func (p *ReplicatePredictionResponse) PredictTime() float64 {
+	if p == nil || p.Metrics == nil {
+		return 0
+	}
+	return p.Metrics.PredictTime
+}
+
 // GenerateImageParams represents parameters for image generation
 type GenerateImageParams struct {
 	Prompt          string  `json:"prompt"`
@@ -187,6 +171,8 @@ type ImageInfo struct {
 	Timestamp time.Time              `json:"timestamp"`
 	FilePath  string                 `json:"file_path"`
 	Model     string                 `json:"model,omitempty"`
+	Rating    int                    `json:"rating,omitempty"`
+	Note      string                 `json:"note,omitempty"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
@@ -0,0 +1,404 @@
+// Package vectorize converts raster images such as logos and icons to SVG
+// with an embedded tracer, for when no vectorization model is used.
+package vectorize
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Tracing limits
+const (
+	DefaultColors = 8
+	MinColors     = 2
+	MaxColors     = 32
+	traceMaxEdge  = 512 // Images are traced on at most this many pixels per edge
+	kmeansRounds  = 12
+)
+
+// Options controls tracing
+type Options struct {
+	Colors    int     // Number of fill colors (default: DefaultColors)
+	Smoothing float64 // 0-1: 0 keeps pixel-exact outlines, 1 gives the smoothest curves
+}
+
+// Trace converts an image to an SVG document: its colors are reduced to
+// opts.Colors, and each color's regions are traced into filled paths.
+// Transparent pixels are left empty. The SVG keeps the image's pixel size.
+func Trace(img image.Image, opts Options) ([]byte, error) {
+	if opts.Colors == 0 {
+		opts.Colors = DefaultColors
+	}
+	if opts.Colors < MinColors || opts.Colors > MaxColors {
+		return nil, fmt.Errorf("colors must be between %d and %d", MinColors, MaxColors)
+	}
+	opts.Smoothing = math.Min(1, math.Max(0, opts.Smoothing))
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("image is empty")
+	}
+	width, height := bounds.Dx(), bounds.Dy()
+	w, h := width, height
+	if edge := max(w, h); edge > traceMaxEdge {
+		w, h = max(1, w*traceMaxEdge/edge), max(1, h*traceMaxEdge/edge)
+	}
+	pixels := sample(img, w, h)
+
+	palette, labels := quantize(pixels, opts.Colors)
+	if palette == nil {
+		return nil, fmt.Errorf("image is fully transparent")
+	}
+	if opts.Smoothing > 0 {
+		labels = despeckle(labels, w, h)
+	}
+
+	// Paint the largest regions first so small details sit on top
+	counts := make([]int, len(palette))
+	for _, label := range labels {
+		if label >= 0 {
+			counts[label]++
+		}
+	}
+	order := make([]int, len(palette))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d">`, w, h, width, height)
+	buf.WriteByte('\n')
+	tolerance := opts.Smoothing * 1.5
+	for _, index := range order {
+		if counts[index] == 0 {
+			continue
+		}
+		loops := traceLoops(labels, w, h, index)
+		var d bytes.Buffer
+		for _, loop := range loops {
+			loop = straighten(loop)
+			if tolerance > 0 {
+				loop = simplify(loop, tolerance)
+			}
+			if len(loop) < 3 {
+				continue
+			}
+			writePath(&d, loop, opts.Smoothing > 0)
+		}
+		if d.Len() == 0 {
+			continue
+		}
+		c := palette[index]
+		fmt.Fprintf(&buf, `<path fill="#%02x%02x%02x" d="%s"/>`, c.R, c.G, c.B, d.String())
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// TraceFile traces the PNG, JPEG, or GIF image at path; see Trace
+func TraceFile(path string, opts Options) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return Trace(img, opts)
+}
+
+// sample returns the image's pixels at w x h, non-premultiplied
+func sample(img image.Image, w, h int) []color.NRGBA {
+	src := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	pixels := make([]color.NRGBA, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pixels[y*w+x] = src.NRGBAAt(x*sw/w+sw/w/2, y*sh/h+sh/h/2)
+		}
+	}
+	return pixels
+}
+
+// quantize reduces the opaque pixels to at most k colors with k-means and
+// returns the palette and each pixel's palette index, or -1 for transparent
+// pixels. The palette is nil when no pixel is opaque.
+func quantize(pixels []color.NRGBA, k int) ([]color.NRGBA, []int) {
+	var opaque [][3]float64
+	for _, p := range pixels {
+		if p.A >= 128 {
+			opaque = append(opaque, [3]float64{float64(p.R), float64(p.G), float64(p.B)})
+		}
+	}
+	if len(opaque) == 0 {
+		return nil, nil
+	}
+
+	// Seed the centers evenly across the pixels sorted by brightness
+	sorted := make([][3]float64, len(opaque))
+	copy(sorted, opaque)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i][0]+sorted[i][1]+sorted[i][2] < sorted[j][0]+sorted[j][1]+sorted[j][2]
+	})
+	k = min(k, len(sorted))
+	centers := make([][3]float64, k)
+	for i := range centers {
+		centers[i] = sorted[(2*i+1)*len(sorted)/(2*k)]
+	}
+
+	assign := make([]int, len(opaque))
+	for round := 0; round < kmeansRounds; round++ {
+		var sums = make([][4]float64, k)
+		for i, p := range opaque {
+			assign[i] = nearest(centers, p)
+			for ch := 0; ch < 3; ch++ {
+				sums[assign[i]][ch] += p[ch]
+			}
+			sums[assign[i]][3]++
+		}
+		for c := range centers {
+			if sums[c][3] > 0 {
+				for ch := 0; ch < 3; ch++ {
+					centers[c][ch] = sums[c][ch] / sums[c][3]
+				}
+			}
+		}
+	}
+
+	palette := make([]color.NRGBA, k)
+	for i, c := range centers {
+		palette[i] = color.NRGBA{uint8(math.Round(c[0])), uint8(math.Round(c[1])), uint8(math.Round(c[2])), 255}
+	}
+	labels := make([]int, len(pixels))
+	next := 0
+	for i, p := range pixels {
+		if p.A < 128 {
+			labels[i] = -1
+			continue
+		}
+		labels[i] = nearest(centers, opaque[next])
+		next++
+	}
+	return palette, labels
+}
+
+// nearest returns the index of the center closest to p
+func nearest(centers [][3]float64, p [3]float64) int {
+	best, bestDist := 0, math.MaxFloat64
+	for i, c := range centers {
+		dr, dg, db := c[0]-p[0], c[1]-p[1], c[2]-p[2]
+		if dist := dr*dr + dg*dg + db*db; dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// despeckle replaces pixels that differ from most of their 3x3 neighborhood
+// with the neighborhood's majority label, removing isolated specks
+func despeckle(labels []int, w, h int) []int {
+	out := make([]int, len(labels))
+	copy(out, labels)
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			counts := map[int]int{}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					counts[labels[(y+dy)*w+x+dx]]++
+				}
+			}
+			if counts[labels[y*w+x]] >= 3 {
+				continue
+			}
+			best, bestCount := labels[y*w+x], 0
+			for label, count := range counts {
+				if count > bestCount || count == bestCount && label < best {
+					best, bestCount = label, count
+				}
+			}
+			out[y*w+x] = best
+		}
+	}
+	return out
+}
+
+// point is a pixel corner
+type point struct{ x, y int }
+
+// traceLoops returns the outlines of the pixels with the given label as
+// closed loops of pixel corners. Every boundary edge is directed with the
+// region on its right, so outer outlines run clockwise and holes run
+// counterclockwise, and the nonzero fill rule fills exactly the region.
+func traceLoops(labels []int, w, h, label int) [][]point {
+	inside := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && labels[y*w+x] == label
+	}
+
+	// Directed boundary edges, keyed by their start corner
+	edges := map[point][]point{}
+	add := func(from, to point) { edges[from] = append(edges[from], to) }
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !inside(x, y) {
+				continue
+			}
+			if !inside(x, y-1) {
+				add(point{x, y}, point{x + 1, y})
+			}
+			if !inside(x+1, y) {
+				add(point{x + 1, y}, point{x + 1, y + 1})
+			}
+			if !inside(x, y+1) {
+				add(point{x + 1, y + 1}, point{x, y + 1})
+			}
+			if !inside(x-1, y) {
+				add(point{x, y + 1}, point{x, y})
+			}
+		}
+	}
+
+	// Chain the edges into loops in a fixed order for stable output
+	starts := make([]point, 0, len(edges))
+	for p := range edges {
+		starts = append(starts, p)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		if starts[i].y != starts[j].y {
+			return starts[i].y < starts[j].y
+		}
+		return starts[i].x < starts[j].x
+	})
+
+	var loops [][]point
+	for _, start := range starts {
+		for len(edges[start]) > 0 {
+			loop := []point{start}
+			current := start
+			for {
+				next := edges[current]
+				to := next[len(next)-1]
+				edges[current] = next[:len(next)-1]
+				if to == start {
+					break
+				}
+				loop = append(loop, to)
+				current = to
+				if len(edges[current]) == 0 {
+					break
+				}
+			}
+			loops = append(loops, loop)
+		}
+	}
+	return loops
+}
+
+// straighten drops the corners in the middle of straight runs
+func straighten(loop []point) []point {
+	n := len(loop)
+	if n < 3 {
+		return loop
+	}
+	var out []point
+	for i, p := range loop {
+		prev, next := loop[(i+n-1)%n], loop[(i+1)%n]
+		if (prev.x == p.x && p.x == next.x) || (prev.y == p.y && p.y == next.y) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// simplify removes corners that lie within tolerance of the outline, using
+// Douglas-Peucker on the loop split at its first and middle corners
+func simplify(loop []point, tolerance float64) []point {
+	if len(loop) < 4 {
+		return loop
+	}
+	mid := len(loop) / 2
+	first := douglasPeucker(loop[:mid+1], tolerance)
+	second := douglasPeucker(append(append([]point{}, loop[mid:]...), loop[0]), tolerance)
+	return append(first[:len(first)-1], second[:len(second)-1]...)
+}
+
+// douglasPeucker simplifies an open polyline, keeping its end points
+func douglasPeucker(line []point, tolerance float64) []point {
+	if len(line) < 3 {
+		return line
+	}
+	a, b := line[0], line[len(line)-1]
+	farthest, farthestDist := 0, 0.0
+	for i := 1; i < len(line)-1; i++ {
+		if dist := distanceToSegment(line[i], a, b); dist > farthestDist {
+			farthest, farthestDist = i, dist
+		}
+	}
+	if farthestDist <= tolerance {
+		return []point{a, b}
+	}
+	left := douglasPeucker(line[:farthest+1], tolerance)
+	right := douglasPeucker(line[farthest:], tolerance)
+	return append(left[:len(left)-1], right...)
+}
+
+// distanceToSegment returns the distance from p to the segment a-b
+func distanceToSegment(p, a, b point) float64 {
+	dx, dy := float64(b.x-a.x), float64(b.y-a.y)
+	px, py := float64(p.x-a.x), float64(p.y-a.y)
+	length := dx*dx + dy*dy
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+	t := math.Min(1, math.Max(0, (px*dx+py*dy)/length))
+	return math.Hypot(px-t*dx, py-t*dy)
+}
+
+// writePath appends a closed subpath through the loop's corners. Smooth
+// paths run through the midpoints of the edges with each corner as a
+// quadratic Bezier control point.
+func writePath(d *bytes.Buffer, loop []point, smooth bool) {
+	n := len(loop)
+	if !smooth {
+		for i, p := range loop {
+			if i == 0 {
+				d.WriteString("M")
+			} else {
+				d.WriteString("L")
+			}
+			d.WriteString(strconv.Itoa(p.x) + " " + strconv.Itoa(p.y))
+		}
+		d.WriteString("Z")
+		return
+	}
+
+	mid := func(a, b point) string {
+		return formatCoord(float64(a.x+b.x)/2) + " " + formatCoord(float64(a.y+b.y)/2)
+	}
+	d.WriteString("M" + mid(loop[n-1], loop[0]))
+	for i, p := range loop {
+		d.WriteString("Q" + strconv.Itoa(p.x) + " " + strconv.Itoa(p.y) + " " + mid(p, loop[(i+1)%n]))
+	}
+	d.WriteString("Z")
+}
+
+// formatCoord formats a coordinate that is a whole or half pixel
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
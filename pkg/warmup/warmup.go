@@ -0,0 +1,283 @@
+// Package warmup boots community models ahead of use with minimal
+// predictions, so real calls do not pay a cold start.
+package warmup
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gomcpgo/replicate_image_ai/pkg/client"
+	"github.com/gomcpgo/replicate_image_ai/pkg/models"
+	"github.com/gomcpgo/replicate_image_ai/pkg/storage"
+	"github.com/gomcpgo/replicate_image_ai/pkg/types"
+)
+
+// Outcomes of a warm-up
+const (
+	StatusWarmed  = "warmed"  // A warm-up prediction ran
+	StatusStarted = "started" // A warm-up prediction was started without waiting for it
+	StatusSkipped = "skipped" // The model does not cold start
+)
+
+// coldStartThreshold is the startup time above which a prediction is taken to
+// have waited for the model to boot rather than just for a free worker
+const coldStartThreshold = 15 * time.Second
+
+// Result describes one warm-up
+type Result struct {
+	Model        string
+	Status       string
+	Reason       string  // Why the model was skipped
+	PredictionID string
+	ColdStart    bool    // The model was booted by this warm-up
+	StartupTime  float64 // Seconds from creating the prediction to it starting
+	PredictTime  float64
+	Cost         float64
+	Error        string // The warm-up prediction failed after the model booted
+}
+
+// Warmer sends warm-up predictions
+type Warmer struct {
+	router   *client.Router
+	storage  *storage.Storage
+	timeouts client.Timeouts
+}
+
+// New creates a warmer sending predictions through router and recording their
+// spend in store
+func New(router *client.Router, store *storage.Storage) *Warmer {
+	return NewWithTimeouts(router, store, client.Timeouts{})
+}
+
+// NewWithTimeouts creates a warmer polling its predictions with timeouts
+// instead of the defaults
+func NewWithTimeouts(router *client.Router, store *storage.Storage, timeouts client.Timeouts) *Warmer {
+	return &Warmer{router: router, storage: store, timeouts: timeouts}
+}
+
+// Skip returns why a model never needs warming, or "" if it can cold start.
+// Official Replicate models and other hosted providers are always ready.
+func (w *Warmer) Skip(modelID string) string {
+	p := w.router.ProviderFor(modelID)
+	switch {
+	case p == nil:
+		return ""
+	case p.Name() == client.ProviderLocal:
+		return "the model runs on the local server"
+	case p.Name() != client.ProviderReplicate:
+		return fmt.Sprintf("%s serves the model without cold starts", p.Name())
+	}
+	if pricing, ok := models.GetPricing(modelID); ok && pricing.PerOutput > 0 {
+		return "official Replicate models are always warm"
+	}
+	return ""
+}
+
+// Warm boots a model with a minimal prediction. input replaces the built-in
+// warm-up input and is required for models without one. Without wait, Warm
+// returns once the prediction is created and finishes it in the background.
+func (w *Warmer) Warm(ctx context.Context, modelID string, input map[string]interface{}, wait bool) (*Result, error) {
+	if reason := w.Skip(modelID); reason != "" {
+		return &Result{Model: modelID, Status: StatusSkipped, Reason: reason}, nil
+	}
+	if input == nil {
+		var ok bool
+		if input, ok = Input(modelID); !ok {
+			return nil, fmt.Errorf("no warm-up input is known for %s; pass input with the model's required parameters", modelID)
+		}
+	}
+
+	startTime := time.Now()
+	prediction, err := w.router.CreatePrediction(ctx, modelID, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %w", err)
+	}
+	slog.Debug("warming model", "model", modelID, "prediction_id", prediction.ID)
+	if !wait {
+		go func() {
+			if _, err := w.finish(context.WithoutCancel(ctx), modelID, prediction.ID, startTime); err != nil {
+				slog.Warn("model warm-up failed", "model", modelID, "error", err)
+			}
+		}()
+		return &Result{Model: modelID, Status: StatusStarted, PredictionID: prediction.ID}, nil
+	}
+	return w.finish(ctx, modelID, prediction.ID, startTime)
+}
+
+// finish polls a warm-up prediction to the end and records its spend. A
+// prediction that fails after starting still booted the model.
+func (w *Warmer) finish(ctx context.Context, modelID, predictionID string, startTime time.Time) (*Result, error) {
+	// Booting a large model can take several minutes
+	poll := w.timeouts.NewPoll(ctx, 5*time.Minute)
+
+	var result *types.ReplicatePredictionResponse
+	var err error
+	for poll.Next() {
+		result, err = w.router.GetPrediction(ctx, predictionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get prediction status: %w", err)
+		}
+		if result.Status == types.StatusSucceeded || result.Status == types.StatusFailed || result.Status == types.StatusCanceled {
+			break
+		}
+	}
+	if result.StartedAt == nil {
+		return nil, fmt.Errorf("the warm-up prediction %s did not start in time (status %s)", predictionID, result.Status)
+	}
+
+	warmed := &Result{
+		Model:        modelID,
+		Status:       StatusWarmed,
+		PredictionID: predictionID,
+		StartupTime:  startupTime(result),
+		PredictTime:  result.PredictTime(),
+	}
+	warmed.ColdStart = warmed.StartupTime > coldStartThreshold.Seconds()
+	if result.Status != types.StatusSucceeded {
+		_, warmed.Error = client.ClassifyFailure(result.Error, result.Logs)
+		if warmed.Error == "" {
+			warmed.Error = "still " + result.Status
+		}
+	}
+
+	opResult := &types.OperationResult{
+		GenerationTime: time.Since(startTime).Seconds(),
+		PredictionID:   predictionID,
+		PredictTime:    warmed.PredictTime,
+		Provider:       result.Provider,
+	}
+	opResult.CostEstimate, opResult.CostBasis = models.ActualCost(result.Provider, modelID, opResult.PredictTime, 1)
+	warmed.Cost = opResult.CostEstimate
+	metadata := &types.ImageMetadata{
+		Version:   "1.0",
+		Operation: "warm_model",
+		Timestamp: time.Now(),
+		Model:     modelID,
+		Result:    opResult,
+	}
+	if err := w.storage.RecordSpend(metadata); err != nil {
+		slog.Warn("failed to record spend", "prediction_id", predictionID, "error", err)
+	}
+	return warmed, nil
+}
+
+// KeepWarm warms each model now and every interval, skipping models that got
+// a real prediction since the last round. Warm-ups wait behind interactive
+// predictions. It runs until ctx is done.
+func (w *Warmer) KeepWarm(ctx context.Context, modelIDs []string, interval time.Duration) {
+	var warm []string
+	for _, modelID := range modelIDs {
+		if reason := w.Skip(modelID); reason != "" {
+			slog.Info("not keeping model warm", "model", modelID, "reason", reason)
+			continue
+		}
+		warm = append(warm, modelID)
+	}
+	if len(warm) == 0 {
+		return
+	}
+	ctx = client.WithPriority(ctx, client.PriorityBatch)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var lastRound time.Time
+		for {
+			var wg sync.WaitGroup
+			for _, modelID := range warm {
+				if w.router.LastUsed(modelID).After(lastRound) && !lastRound.IsZero() {
+					continue
+				}
+				wg.Add(1)
+				go func(modelID string) {
+					defer wg.Done()
+					result, err := w.Warm(ctx, modelID, nil, true)
+					if err != nil {
+						slog.Warn("keep-warm prediction failed", "model", modelID, "error", err)
+						return
+					}
+					slog.Debug("kept model warm", "model", modelID, "cold_start", result.ColdStart, "cost", result.Cost)
+				}(modelID)
+			}
+			wg.Wait()
+			lastRound = time.Now()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// startupTime returns the seconds a prediction waited before starting
+func startupTime(p *types.ReplicatePredictionResponse) float64 {
+	created, err := time.Parse(time.RFC3339Nano, p.CreatedAt)
+	if err != nil || p.StartedAt == nil {
+		return 0
+	}
+	started, err := time.Parse(time.RFC3339Nano, *p.StartedAt)
+	if err != nil || started.Before(created) {
+		return 0
+	}
+	return started.Sub(created).Seconds()
+}
+
+// sampleImage is a small gray PNG data URL given to image models
+var sampleImage = func() string {
+	img := image.NewGray(image.Rect(0, 0, 256, 256))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}()
+
+// Input returns the cheapest valid input for a community model, reporting
+// false for models without one
+func Input(modelID string) (map[string]interface{}, bool) {
+	input, ok := warmupInputs[modelID]
+	if !ok {
+		return nil, false
+	}
+	copied := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		copied[k] = v
+	}
+	return copied, true
+}
+
+// warmupInputs are minimal inputs for the community models, keyed as each
+// operation sends them. Image parameters get sampleImage.
+var warmupInputs = map[string]map[string]interface{}{
+	models.ModelSDXL:            {"prompt": "a gray square", "width": 512, "height": 512, "num_inference_steps": 1},
+	models.ModelSDXLLightning:   {"prompt": "a gray square", "width": 512, "height": 512},
+	models.ModelRemoveBG:        {"image": sampleImage},
+	models.ModelRembg:           {"image": sampleImage},
+	models.ModelDISBGRemoval:    {"image": sampleImage},
+	models.ModelRealESRGAN:      {"img": sampleImage, "scale": 2},
+	models.ModelESRGAN:          {"image": sampleImage, "scale": 2},
+	models.ModelSwinIR:          {"image": sampleImage, "task_type": "Real-World Image Super-Resolution", "scale": 2},
+	models.ModelClarityUpscaler: {"image": sampleImage, "scale_factor": 2, "output_format": "png"},
+	models.ModelSUPIR:           {"image": sampleImage, "upscale": 1},
+	models.ModelGFPGAN:          {"img": sampleImage, "version": "v1.4", "scale": 1},
+	models.ModelCodeFormer:      {"image": sampleImage, "codeformer_fidelity": 0.5, "upscale": 1},
+	models.ModelRestoreFormer:   {"image": sampleImage},
+	models.ModelOldPhotoRestore: {"image": sampleImage, "HR": false, "with_scratch": false},
+	models.ModelDDColor:         {"image": sampleImage},
+	models.ModelDepthAnythingV2: {"image": sampleImage, "encoder": "vitl"},
+	models.ModelBLIP:            {"image": sampleImage, "task": "image_captioning"},
+	models.ModelLLaVA13:         {"image": sampleImage, "prompt": "What color is this image?", "max_tokens": 8},
+	models.ModelGroundingDINO:   {"image": sampleImage, "query": "square", "box_threshold": 0.3, "text_threshold": 0.25, "show_visualisation": false},
+	models.ModelNSFWDetection:   {"image": sampleImage},
+	models.ModelInpainting:      {"image": sampleImage, "mask": sampleImage, "prompt": "a gray square", "num_inference_steps": 1},
+}
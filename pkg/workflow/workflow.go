@@ -0,0 +1,495 @@
+// Package workflow runs small graphs of tool calls server-side. Nodes are tool
+// calls; edges order them, wire one node's output into another's arguments,
+// and branch on whether a node succeeded or failed.
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxNodes caps the size of a workflow
+const MaxNodes = 20
+
+// Edge conditions
+const (
+	OnSuccess = "success" // Follow the edge when the source node succeeds (default)
+	OnFailure = "failure" // Follow the edge when the source node fails
+	OnAlways  = "always"  // Follow the edge once the source node finishes either way
+)
+
+// DefaultOutput is the result field wired through an edge when none is named
+const DefaultOutput = "paths.file_path"
+
+// Node states
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateSkipped   = "skipped" // An incoming edge's condition was not met
+)
+
+// Run states
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed" // A node failed with no failure edge to handle it
+)
+
+// Node is one tool call
+type Node struct {
+	ID   string                 `json:"id"`
+	Tool string                 `json:"tool"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// Edge makes To wait for From. When From succeeds and Input is set, the
+// Output field of From's result is passed to To as the Input argument.
+type Edge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Output string `json:"output,omitempty"` // Dotted path into the result, e.g. paths.file_path
+	Input  string `json:"input,omitempty"`  // Argument of To receiving the value, e.g. file_path
+	On     string `json:"on,omitempty"`     // success (default), failure, or always
+}
+
+// Workflow is a graph of tool calls
+type Workflow struct {
+	Nodes    []Node `json:"nodes"`
+	Edges    []Edge `json:"edges,omitempty"`
+	LockSeed *int64 `json:"lock_seed,omitempty"` // Root seed every node's seed is derived from; see LockSeeds
+}
+
+// Parse decodes a workflow from a tool argument, given either as a JSON
+// object or a JSON string
+func Parse(raw interface{}) (*Workflow, error) {
+	var data []byte
+	switch v := raw.(type) {
+	case string:
+		data = []byte(v)
+	case map[string]interface{}:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("invalid workflow: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("workflow must be an object with nodes and edges")
+	}
+	var w Workflow
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("invalid workflow: %w", err)
+	}
+	return &w, nil
+}
+
+// Validate checks that node IDs are unique, every tool is allowed, every edge
+// joins two nodes with a known condition, and the graph has no cycles
+func (w *Workflow) Validate(allowed func(tool string) bool) error {
+	if len(w.Nodes) == 0 {
+		return fmt.Errorf("the workflow has no nodes")
+	}
+	if len(w.Nodes) > MaxNodes {
+		return fmt.Errorf("the workflow has %d nodes; at most %d are allowed", len(w.Nodes), MaxNodes)
+	}
+	ids := make(map[string]bool, len(w.Nodes))
+	for _, node := range w.Nodes {
+		if node.ID == "" {
+			return fmt.Errorf("every node needs an id")
+		}
+		if ids[node.ID] {
+			return fmt.Errorf("duplicate node id %q", node.ID)
+		}
+		ids[node.ID] = true
+		if !allowed(node.Tool) {
+			return fmt.Errorf("node %q: tool %q cannot be used in a workflow", node.ID, node.Tool)
+		}
+	}
+	for i, edge := range w.Edges {
+		if !ids[edge.From] || !ids[edge.To] {
+			return fmt.Errorf("edge %d joins unknown nodes %q and %q", i+1, edge.From, edge.To)
+		}
+		if edge.From == edge.To {
+			return fmt.Errorf("edge %d joins node %q to itself", i+1, edge.From)
+		}
+		switch edge.On {
+		case "", OnSuccess, OnAlways:
+		case OnFailure:
+			if edge.Input != "" {
+				return fmt.Errorf("edge %d: a failure edge carries no output to wire into %q", i+1, edge.Input)
+			}
+		default:
+			return fmt.Errorf("edge %d: on must be success, failure, or always", i+1)
+		}
+	}
+	if cycle := w.findCycle(); cycle != "" {
+		return fmt.Errorf("the workflow has a cycle through node %q", cycle)
+	}
+	return nil
+}
+
+// LockSeeds makes a run reproducible by giving every node whose tool takes a
+// seed, per takesSeed, the seed NodeSeed derives from w.LockSeed, unless the
+// node sets one itself or receives one through an edge. It does nothing when
+// LockSeed is unset.
+func (w *Workflow) LockSeeds(takesSeed func(tool string) bool) {
+	if w.LockSeed == nil {
+		return
+	}
+	wired := make(map[string]bool)
+	for _, edge := range w.Edges {
+		if edge.Input == "seed" {
+			wired[edge.To] = true
+		}
+	}
+	for i := range w.Nodes {
+		node := &w.Nodes[i]
+		if _, ok := node.Args["seed"]; ok || wired[node.ID] || !takesSeed(node.Tool) {
+			continue
+		}
+		if node.Args == nil {
+			node.Args = make(map[string]interface{})
+		}
+		// Numbers in arguments are float64, as decoded from JSON
+		node.Args["seed"] = float64(NodeSeed(*w.LockSeed, node.ID))
+	}
+}
+
+// NodeSeed derives the seed of a node from a root seed and the node's ID, so
+// a node keeps its seed when others are added or reordered. Seeds are between
+// 1 and 2^31-1, within the range every model accepts and never 0, which tools
+// take as no seed.
+func NodeSeed(root int64, nodeID string) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s", root, nodeID)
+	return int64(h.Sum64()%(1<<31-1)) + 1
+}
+
+// findCycle returns a node on a cycle, or "" when the graph is acyclic
+func (w *Workflow) findCycle() string {
+	indegree := make(map[string]int, len(w.Nodes))
+	for _, edge := range w.Edges {
+		indegree[edge.To]++
+	}
+	var ready []string
+	for _, node := range w.Nodes {
+		if indegree[node.ID] == 0 {
+			ready = append(ready, node.ID)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, edge := range w.Edges {
+			if edge.From == id {
+				indegree[edge.To]--
+				if indegree[edge.To] == 0 {
+					ready = append(ready, edge.To)
+				}
+			}
+		}
+	}
+	if visited == len(w.Nodes) {
+		return ""
+	}
+	for _, node := range w.Nodes {
+		if indegree[node.ID] > 0 {
+			return node.ID
+		}
+	}
+	return ""
+}
+
+// Result is the outcome of one tool call
+type Result struct {
+	Success bool
+	Output  map[string]interface{} // The tool's JSON response
+	Error   string
+	Cost    float64
+}
+
+// Executor runs one tool call
+type Executor func(ctx context.Context, tool string, args map[string]interface{}) Result
+
+// NodeStatus reports the progress of one node
+type NodeStatus struct {
+	ID       string                 `json:"id"`
+	Tool     string                 `json:"tool"`
+	State    string                 `json:"state"`
+	Reason   string                 `json:"reason,omitempty"` // Why the node was skipped
+	Error    string                 `json:"error,omitempty"`
+	Duration float64                `json:"duration,omitempty"` // Seconds
+	Cost     float64                `json:"cost,omitempty"`
+	Seed     int64                  `json:"seed,omitempty"` // Seed the node ran with, when seeds are locked
+	Result   map[string]interface{} `json:"result,omitempty"`
+}
+
+// Status reports the progress of a whole run
+type Status struct {
+	ID        string       `json:"chain_id"`
+	State     string       `json:"status"`
+	StartedAt time.Time    `json:"started_at"`
+	Duration  float64      `json:"duration"` // Seconds so far, or in total once finished
+	TotalCost float64      `json:"total_cost"`
+	LockSeed  *int64       `json:"lock_seed,omitempty"`
+	Nodes     []NodeStatus `json:"nodes"`
+}
+
+// Run is a workflow executing in the background
+type Run struct {
+	id       string
+	workflow *Workflow
+	exec     Executor
+	started  time.Time
+	done     chan struct{}
+
+	mu       sync.Mutex
+	nodes    map[string]*NodeStatus
+	finished time.Time
+}
+
+// Start runs the workflow in the background, calling exec for every node
+// whose incoming edges are satisfied. Independent nodes run concurrently.
+// The run is not tied to ctx's cancellation, so it outlives the tool call
+// that started it.
+func Start(ctx context.Context, id string, w *Workflow, exec Executor) *Run {
+	r := &Run{
+		id:       id,
+		workflow: w,
+		exec:     exec,
+		started:  time.Now(),
+		done:     make(chan struct{}),
+		nodes:    make(map[string]*NodeStatus, len(w.Nodes)),
+	}
+	for _, node := range w.Nodes {
+		r.nodes[node.ID] = &NodeStatus{ID: node.ID, Tool: node.Tool, State: StatePending}
+		if seed, ok := node.Args["seed"].(float64); ok && w.LockSeed != nil {
+			r.nodes[node.ID].Seed = int64(seed)
+		}
+	}
+	go r.run(context.WithoutCancel(ctx))
+	return r
+}
+
+// Wait blocks until the run finishes or timeout elapses, reporting whether
+// it finished
+func (r *Run) Wait(timeout time.Duration) bool {
+	select {
+	case <-r.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Finished reports when the run finished, or the zero time while it runs
+func (r *Run) Finished() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finished
+}
+
+// Status returns a snapshot of the run
+func (r *Run) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := Status{
+		ID:        r.id,
+		State:     RunRunning,
+		StartedAt: r.started,
+		Duration:  time.Since(r.started).Seconds(),
+		LockSeed:  r.workflow.LockSeed,
+	}
+	if !r.finished.IsZero() {
+		status.State = RunSucceeded
+		status.Duration = r.finished.Sub(r.started).Seconds()
+	}
+	for _, node := range r.workflow.Nodes {
+		ns := *r.nodes[node.ID]
+		status.Nodes = append(status.Nodes, ns)
+		status.TotalCost += ns.Cost
+		if ns.State == StateFailed && !r.handlesFailure(node.ID) && !r.finished.IsZero() {
+			status.State = RunFailed
+		}
+	}
+	return status
+}
+
+// handlesFailure reports whether a node has an edge followed on failure
+func (r *Run) handlesFailure(id string) bool {
+	for _, edge := range r.workflow.Edges {
+		if edge.From == id && (edge.On == OnFailure || edge.On == OnAlways) {
+			return true
+		}
+	}
+	return false
+}
+
+// run schedules nodes as their dependencies finish
+func (r *Run) run(ctx context.Context) {
+	defer close(r.done)
+
+	finishedCh := make(chan string)
+	running := 0
+	for {
+		// Start or skip every pending node whose sources have all finished
+		r.mu.Lock()
+		var start []Node
+		for _, node := range r.workflow.Nodes {
+			state := r.nodes[node.ID]
+			if state.State != StatePending {
+				continue
+			}
+			ready, skip := r.readiness(node.ID)
+			switch {
+			case skip != "":
+				state.State = StateSkipped
+				state.Reason = skip
+			case ready:
+				state.State = StateRunning
+				start = append(start, node)
+			}
+		}
+		r.mu.Unlock()
+
+		for _, node := range start {
+			args := r.arguments(node)
+			running++
+			go func(node Node, args map[string]interface{}) {
+				begin := time.Now()
+				result := r.exec(ctx, node.Tool, args)
+				r.mu.Lock()
+				state := r.nodes[node.ID]
+				state.Duration = time.Since(begin).Seconds()
+				state.Result = result.Output
+				state.Cost = result.Cost
+				if result.Success {
+					state.State = StateSucceeded
+				} else {
+					state.State = StateFailed
+					state.Error = result.Error
+				}
+				r.mu.Unlock()
+				finishedCh <- node.ID
+			}(node, args)
+		}
+
+		if running == 0 {
+			// Skipping a node can make others skippable; loop until stable
+			if len(start) == 0 && !r.skippedAny() {
+				break
+			}
+			continue
+		}
+		<-finishedCh
+		running--
+	}
+
+	r.mu.Lock()
+	r.finished = time.Now()
+	r.mu.Unlock()
+}
+
+// skippedAny reports whether a pending node can now be skipped. Callers loop
+// while it returns true so skips propagate down the graph.
+func (r *Run) skippedAny() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, node := range r.workflow.Nodes {
+		if r.nodes[node.ID].State != StatePending {
+			continue
+		}
+		if _, skip := r.readiness(node.ID); skip != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// readiness reports whether a node can start, or why it will never run. The
+// caller holds r.mu.
+func (r *Run) readiness(id string) (bool, string) {
+	for _, edge := range r.workflow.Edges {
+		if edge.To != id {
+			continue
+		}
+		source := r.nodes[edge.From]
+		switch source.State {
+		case StatePending, StateRunning:
+			return false, ""
+		case StateSkipped:
+			return false, edge.From + " was skipped"
+		}
+		switch edge.On {
+		case OnFailure:
+			if source.State != StateFailed {
+				return false, edge.From + " did not fail"
+			}
+		case OnAlways:
+		default:
+			if source.State != StateSucceeded {
+				return false, edge.From + " failed"
+			}
+		}
+	}
+	return true, ""
+}
+
+// arguments returns a node's arguments with the outputs of its sources wired
+// in
+func (r *Run) arguments(node Node) map[string]interface{} {
+	args := make(map[string]interface{}, len(node.Args))
+	for k, v := range node.Args {
+		args[k] = v
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, edge := range r.workflow.Edges {
+		if edge.To != node.ID || edge.Input == "" {
+			continue
+		}
+		source := r.nodes[edge.From]
+		if source.State != StateSucceeded {
+			continue
+		}
+		output := edge.Output
+		if output == "" {
+			output = DefaultOutput
+		}
+		if value, ok := lookup(source.Result, output); ok {
+			args[edge.Input] = value
+		}
+	}
+	return args
+}
+
+// lookup follows a dotted path such as paths.file_path or files.0.file_path
+// into a decoded JSON value
+func lookup(value interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			var index int
+			if _, err := fmt.Sscanf(key, "%d", &index); err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
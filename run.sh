@@ -198,6 +198,10 @@ case "$1" in
         eval $cmd
         ;;
     
+    "doctor")
+        go run ./cmd doctor
+        ;;
+    
     "run")
         echo "Running Replicate Image AI MCP server..."
         go run ./cmd
@@ -213,7 +217,7 @@ case "$1" in
         echo "Replicate Image AI MCP Server Build Script"
         echo "=========================================="
         echo ""
-        echo "Usage: $0 {build|test|integration-test|generate|gen4|imagen4|edit|enhance|list-models|test-all|test-id|run|clean}"
+        echo "Usage: $0 {build|test|integration-test|generate|gen4|imagen4|edit|enhance|list-models|test-all|test-id|doctor|run|clean}"
         echo ""
         echo "Commands:"
         echo "  build                       - Build the server binary"
@@ -227,6 +231,7 @@ case "$1" in
         echo "  list-models                 - List available models"
         echo "  test-all                    - Test all models"
         echo "  test-id <model-id>          - Test a specific model ID directly"
+        echo "  doctor                      - Check the configuration, token, models, and storage"
         echo "  run                         - Run the MCP server"
         echo "  clean                       - Remove build artifacts"
         echo ""